@@ -556,7 +556,7 @@ resource "null_resource" "masters" {
     # because the kubelet cannot prooperly detect a valid hostname
     #nodename = "${aws_instance.masters.private_dns}"
     nodename = "${element(aws_instance.masters.*.private_dns, count.index)}"
-    role      = "master"
+    role      = "control-plane"
     join      = "${count.index == 0 ? "" : aws_instance.masters.0.private_ip}"
 
     install {
@@ -328,7 +328,7 @@ resource "docker_container" "master" {
 
   provisioner "kubeadm" {
     config    = "${kubeadm.main.config}"
-    role      = "master"
+    role      = "control-plane"
     join      = "${count.index == 0 ? "" : docker_container.master.0.ip_address}"
     manifests = "${var.manifests}"
 
@@ -19,7 +19,6 @@ require (
 	github.com/googleapis/gnostic v0.2.0 // indirect
 	github.com/gookit/color v1.1.7
 	github.com/hashicorp/terraform v0.12.3
-	github.com/helm/helm v2.14.3+incompatible
 	github.com/huandu/xstrings v1.2.0 // indirect
 	github.com/imdario/mergo v0.3.7 // indirect
 	github.com/jmoiron/sqlx v1.2.0 // indirect
@@ -33,13 +32,14 @@ require (
 	github.com/xlab/handysort v0.0.0-20150421192137-fb3537ed64a1 // indirect
 	github.com/ziutek/mymysql v1.5.4 // indirect
 	gopkg.in/gorp.v1 v1.7.2 // indirect
+	k8s.io/api v0.0.0-20190726022912-69e1bce1dad5
 	k8s.io/apiextensions-apiserver v0.0.0-20190315093550-53c4693659ed // indirect
+	k8s.io/apimachinery v0.0.0-20190726022757-641a75999153
 	k8s.io/apiserver v0.0.0-20190424053242-2200fef3ea67 // indirect
 	k8s.io/cli-runtime v0.0.0-20190726024606-74a61cd71909 // indirect
 	k8s.io/client-go v11.0.1-0.20190409021438-1a26190bd76a+incompatible
 	k8s.io/cloud-provider v0.0.0-20190405093944-6c8b65ee8f98 // indirect
-	k8s.io/cluster-bootstrap v0.0.0-20190626010831-cd8eb24ea488 // indirect
-	k8s.io/helm v2.14.3+incompatible
+	k8s.io/cluster-bootstrap v0.0.0-20190626010831-cd8eb24ea488
 	k8s.io/kube-proxy v0.0.0-20190314002154-4d735c31b054 // indirect
 	k8s.io/kubelet v0.0.0-20190314002251-f6da02f58325 // indirect
 	k8s.io/kubernetes v1.14.1
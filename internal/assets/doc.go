@@ -14,11 +14,18 @@
 
 package assets
 
-//go:generate ../../utils/generate.sh --out-var KubeadmSetupScriptCode --out-package assets  --out-file generated_kubeadm_setup.go ./static/kubeadm-setup.sh
+//go:generate ../../utils/generate.sh --out-var KubeadmSetupTemplates --out-package assets  --out-file generated_kubeadm_setup.go ./static/setup/*.sh.tmpl
+//go:generate ../../utils/generate.sh --out-var KubeadmSetupWindowsScriptCode --out-package assets  --out-file generated_kubeadm_setup_win.go ./static/kubeadm-setup.ps1
 //go:generate ../../utils/generate.sh --out-var KubeletSysconfigCode --out-package assets --out-file generated_kubelet_sysconfig.go ./static/kubelet.sysconfig
 //go:generate ../../utils/generate.sh --out-var KubeadmDropinCode --out-package assets --out-file generated_kubeadm_dropin.go ./static/kubeadm-dropin.conf
 //go:generate ../../utils/generate.sh --out-var KubeletServiceCode --out-package assets --out-file generated_kubelet_service.go ./static/service.conf
 //go:generate ../../utils/generate.sh --out-var CNIDefConfCode --out-package assets --out-file generated_cni_conf.go ./static/cni-default.conflist
 //go:generate ../../utils/generate.sh --out-var FlannelManifestCode --out-package assets --out-file generated_flannel_manifest.go ./static/kube-flannel.yml
 //go:generate ../../utils/generate.sh --out-var CloudProviderCode --out-package assets --out-file cloud_provider_manifest.go ./static/cloud-provider.yml
+//go:generate ../../utils/generate.sh --out-var CloudProviderAWSCode --out-package assets --out-file generated_cloud_provider_aws_manifest.go ./static/cloud-provider-aws.yml
+//go:generate ../../utils/generate.sh --out-var CloudProviderOpenStackCode --out-package assets --out-file generated_cloud_provider_openstack_manifest.go ./static/cloud-provider-openstack.yml
+//go:generate ../../utils/generate.sh --out-var CloudProviderVSphereCode --out-package assets --out-file generated_cloud_provider_vsphere_manifest.go ./static/cloud-provider-vsphere.yml
+//go:generate ../../utils/generate.sh --out-var CloudProviderAzureCode --out-package assets --out-file generated_cloud_provider_azure_manifest.go ./static/cloud-provider-azure.yml
 //go:generate ../../utils/generate.sh --out-var WeaveManifestCode --out-package assets --out-file weave_manifest.go ./static/weave.yml
+//go:generate ../../utils/generate.sh --out-var CalicoManifestCode --out-package assets --out-file generated_calico_manifest.go ./static/calico.yml
+//go:generate ../../utils/generate.sh --out-var NodeLocalDNSManifestCode --out-package assets --out-file generated_node_local_dns_manifest.go ./static/node-local-dns.yml
@@ -0,0 +1,22 @@
+// Code generated automatically with go generate; DO NOT EDIT.
+
+package assets
+
+const CalicoManifestCode = `# Calico is deployed with the Tigera operator: this is the
+# Installation resource the operator uses for configuring it
+apiVersion: operator.tigera.io/v1
+kind: Installation
+metadata:
+  name: default
+spec:
+  calicoNetwork:
+{{- with .calico_mtu}}
+    mtu: {{.}}
+{{- end}}
+    ipPools:
+      - blockSize: 26
+        cidr: {{.cni_pod_cidr}}
+        encapsulation: {{.calico_encapsulation}}
+        natOutgoing: Enabled
+        nodeSelector: all()
+`
@@ -0,0 +1,111 @@
+// Code generated automatically with go generate; DO NOT EDIT.
+
+package assets
+
+const CloudProviderAWSCode = `# from https://github.com/kubernetes/cloud-provider-aws/tree/master/examples/existing-cluster/base
+
+{{- if .cloud_config}}
+apiVersion: v1
+kind: Secret
+metadata:
+  name: cloud-provider-config
+  namespace: kube-system
+type: Opaque
+data:
+  # "cloud_config" contains the Base64 encoded configuration file
+  cloud.conf: {{.cloud_config}}
+{{- end}}
+
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+  - kind: ServiceAccount
+    name: cloud-controller-manager
+    namespace: kube-system
+
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  labels:
+    k8s-app: aws-cloud-controller-manager
+  name: aws-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      k8s-app: aws-cloud-controller-manager
+  updateStrategy:
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        k8s-app: aws-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      hostNetwork: true
+      priorityClassName: system-node-critical
+      containers:
+        - name: aws-cloud-controller-manager
+          image: registry.k8s.io/provider-aws/cloud-controller-manager:{{.cloud_manager_version}}
+          args:
+            - --cloud-provider=aws
+            - --v=2
+            - --use-service-account-credentials=true
+            - --configure-cloud-routes=false
+{{- if .cloud_config}}
+            - --cloud-config=/etc/kubernetes/cloud/cloud.conf
+{{- end}}
+{{- if .cloud_provider_flags}}
+            - {{.cloud_provider_flags}}
+{{- end}}
+          resources:
+            requests:
+              cpu: 200m
+{{- if .cloud_config}}
+          volumeMounts:
+            - name: cloud-provider-config
+              mountPath: "/etc/kubernetes/cloud"
+              readOnly: true
+{{- end}}
+      tolerations:
+        # this is required so CCM can bootstrap itself
+        - key: node.cloudprovider.kubernetes.io/uninitialized
+          value: "true"
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/master
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/control-plane
+          effect: NoSchedule
+      # run the CCM only in the control plane
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/control-plane
+                    operator: Exists
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/master
+                    operator: Exists
+{{- if .cloud_config}}
+      volumes:
+        - name: cloud-provider-config
+          secret:
+            secretName: cloud-provider-config
+{{- end}}
+`
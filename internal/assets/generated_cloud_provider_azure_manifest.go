@@ -0,0 +1,182 @@
+// Code generated automatically with go generate; DO NOT EDIT.
+
+package assets
+
+const CloudProviderAzureCode = `# from https://github.com/kubernetes-sigs/cloud-provider-azure/tree/master/examples/out-of-tree
+# (the azure.json is uploaded to /etc/kubernetes/azure.json in the control plane nodes)
+
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+  - kind: ServiceAccount
+    name: cloud-controller-manager
+    namespace: kube-system
+
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-node-manager
+  namespace: kube-system
+
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: cloud-node-manager
+rules:
+  - apiGroups: [""]
+    resources: ["nodes"]
+    verbs: ["watch", "list", "get", "update", "patch"]
+  - apiGroups: [""]
+    resources: ["nodes/status"]
+    verbs: ["patch"]
+
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: cloud-node-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cloud-node-manager
+subjects:
+  - kind: ServiceAccount
+    name: cloud-node-manager
+    namespace: kube-system
+
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  labels:
+    k8s-app: azure-cloud-controller-manager
+  name: azure-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      k8s-app: azure-cloud-controller-manager
+  updateStrategy:
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        k8s-app: azure-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      hostNetwork: true
+      priorityClassName: system-node-critical
+      containers:
+        - name: cloud-controller-manager
+          image: mcr.microsoft.com/oss/kubernetes/azure-cloud-controller-manager:{{.cloud_manager_version}}
+          command:
+            - cloud-controller-manager
+            - --cloud-provider=azure
+            - --cloud-config=/etc/kubernetes/azure.json
+            - --controllers=*,-cloud-node
+            - --configure-cloud-routes=false
+            - --allocate-node-cidrs=false
+            - --leader-elect=true
+            - --secure-port=10268
+            - --v=2
+{{- if .cloud_provider_flags}}
+            - {{.cloud_provider_flags}}
+{{- end}}
+          resources:
+            requests:
+              cpu: 100m
+          volumeMounts:
+            - name: azure-config
+              mountPath: /etc/kubernetes/azure.json
+              readOnly: true
+            - name: ssl-certs
+              mountPath: /etc/ssl
+              readOnly: true
+      tolerations:
+        # this is required so CCM can bootstrap itself
+        - key: node.cloudprovider.kubernetes.io/uninitialized
+          value: "true"
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/master
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/control-plane
+          effect: NoSchedule
+      # run the CCM only in the control plane (where the azure.json is)
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/control-plane
+                    operator: Exists
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/master
+                    operator: Exists
+      volumes:
+        - name: azure-config
+          hostPath:
+            path: /etc/kubernetes/azure.json
+            type: File
+        - name: ssl-certs
+          hostPath:
+            path: /etc/ssl
+            type: Directory
+
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  labels:
+    k8s-app: azure-cloud-node-manager
+  name: azure-cloud-node-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      k8s-app: azure-cloud-node-manager
+  updateStrategy:
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        k8s-app: azure-cloud-node-manager
+    spec:
+      serviceAccountName: cloud-node-manager
+      hostNetwork: true
+      priorityClassName: system-node-critical
+      nodeSelector:
+        kubernetes.io/os: linux
+      tolerations:
+        - operator: Exists
+      containers:
+        - name: cloud-node-manager
+          image: mcr.microsoft.com/oss/kubernetes/azure-cloud-node-manager:{{.cloud_manager_version}}
+          command:
+            - cloud-node-manager
+            - --node-name=$(NODE_NAME)
+            - --wait-routes=false
+            - --v=2
+          env:
+            - name: NODE_NAME
+              valueFrom:
+                fieldRef:
+                  fieldPath: spec.nodeName
+          resources:
+            requests:
+              cpu: 50m
+`
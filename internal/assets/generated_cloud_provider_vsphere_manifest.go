@@ -0,0 +1,105 @@
+// Code generated automatically with go generate; DO NOT EDIT.
+
+package assets
+
+const CloudProviderVSphereCode = `# from https://github.com/kubernetes/cloud-provider-vsphere/tree/master/releases
+
+apiVersion: v1
+kind: Secret
+metadata:
+  name: cloud-config
+  namespace: kube-system
+type: Opaque
+data:
+  # "cloud_config" contains the Base64 encoded configuration file
+  vsphere.conf: {{.cloud_config}}
+
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cloud-controller-manager
+  namespace: kube-system
+
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:cloud-controller-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+  - kind: ServiceAccount
+    name: cloud-controller-manager
+    namespace: kube-system
+
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  labels:
+    k8s-app: vsphere-cloud-controller-manager
+  name: vsphere-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      k8s-app: vsphere-cloud-controller-manager
+  updateStrategy:
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        k8s-app: vsphere-cloud-controller-manager
+    spec:
+      serviceAccountName: cloud-controller-manager
+      hostNetwork: true
+      priorityClassName: system-node-critical
+      securityContext:
+        runAsUser: 1001
+      containers:
+        - name: vsphere-cloud-controller-manager
+          image: registry.k8s.io/cloud-pv-vsphere/cloud-provider-vsphere:{{.cloud_manager_version}}
+          args:
+            - --v=2
+            - --cloud-provider=vsphere
+            - --cloud-config=/etc/cloud/vsphere.conf
+{{- if .cloud_provider_flags}}
+            - {{.cloud_provider_flags}}
+{{- end}}
+          resources:
+            requests:
+              cpu: 200m
+          volumeMounts:
+            - name: vsphere-config-volume
+              mountPath: /etc/cloud
+              readOnly: true
+      tolerations:
+        # this is required so CCM can bootstrap itself
+        - key: node.cloudprovider.kubernetes.io/uninitialized
+          value: "true"
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/master
+          effect: NoSchedule
+        - key: node-role.kubernetes.io/control-plane
+          effect: NoSchedule
+        - key: node.kubernetes.io/not-ready
+          effect: NoSchedule
+      # run the CCM only in the control plane
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/control-plane
+                    operator: Exists
+              - matchExpressions:
+                  - key: node-role.kubernetes.io/master
+                    operator: Exists
+      volumes:
+        - name: vsphere-config-volume
+          secret:
+            secretName: cloud-config
+`
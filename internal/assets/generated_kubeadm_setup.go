@@ -2,34 +2,274 @@
 
 package assets
 
-const KubeadmSetupScriptCode = `#!/bin/sh
+const KubeadmSetupTemplates=`{{ define "amzn" }}
+# installation for Amazon Linux 2 and Amazon Linux 2023
+install_amzn() {
+    log "Installing for Amazon Linux $1..."
+    # there are no Amazon Linux specific Kubernetes repos: use the EL7 ones
+    RELEASE=7
+
+    # containerd and docker are provided by Amazon (there is no Docker CE for Amazon Linux)
+    PKG_YUM_RUNTIME_containerd="containerd"
+    PKG_YUM_DOCKER_CE_REPO=""
+
+    case $1 in
+    2)
+        if [ "$RUNTIME" = "docker" ] && command -v amazon-linux-extras >/dev/null 2>&1 ; then
+            amazon-linux-extras enable docker >/dev/null || warn "could not enable the docker extras repository"
+        fi
+        ;;
+    *)
+        YUM="dnf"
+        ;;
+    esac
 
-##########################################################################################
-# kubeadm setup script
-##########################################################################################
+    install_yum
+}
+{{ end }}
+{{ define "apt" }}
+# installation for Debian variants: debian/Ubuntu...
+install_apt() {
+    log "installing for Ubuntu|Debian ($ARCH)..."
+    remove_legacy_repo $PKG_APT_SRCLST
+    if [ ! -f $PKG_APT_SRCLST ] ; then
+        apt-get update && apt-get install -y $PKG_APT_PACKAGES_PRE || \
+            (abort "could not finish the installation of the requirements" && rm -f $PKG_APT_SRCLST)
+        log "adding repo from $PKG_APT_REPO..."
+        if [ "$PKG_REPO_GPG_CHECK" = "true" ] ; then
+            local key=$(mktemp)
+            get_gpg_key "$PKG_APT_GPG" $key
+            mkdir -p $(dirname $PKG_APT_KEYRING)
+            # the legacy repositories publish a binary key, that does not need to be dearmored
+            if grep -q "BEGIN PGP" $key ; then
+                gpg --dearmor --yes -o $PKG_APT_KEYRING $key
+            else
+                cp -f $key $PKG_APT_KEYRING
+            fi || { rm -f $key ; abort "could not import the repository key from $PKG_APT_GPG" ; }
+            rm -f $key
+            echo "deb [signed-by=$PKG_APT_KEYRING] $PKG_APT_REPO/ $PKG_APT_SUITE" > $PKG_APT_SRCLST
+        else
+            warn "the signatures of the packages in $PKG_APT_REPO will not be checked"
+            echo "deb [trusted=yes] $PKG_APT_REPO/ $PKG_APT_SUITE" > $PKG_APT_SRCLST
+        fi
+    else
+        log "repository already found: skipping installation of the repo"
+    fi
 
+    if [ "$RUNTIME" = "crio" ] && [ ! -f $PKG_APT_CRIO_SRCLST ] ; then
+        . /etc/os-release
+        local os="Debian_$VERSION_ID"
+        [ "$ID" = "ubuntu" ] && os="xUbuntu_$VERSION_ID"
+        log "adding the CRI-O $CRIO_VERSION repository for $os..."
+        local opts=""
+        if [ "$PKG_REPO_GPG_CHECK" = "true" ] ; then
+            curl -s "$CRIO_REPO_BASE/$os/Release.key" | apt-key add -
+            curl -s "$CRIO_REPO_BASE:/cri-o:/$CRIO_VERSION/$os/Release.key" | apt-key add -
+        else
+            opts="[trusted=yes] "
+        fi
+        cat <<EOF > $PKG_APT_CRIO_SRCLST
+deb $opts$CRIO_REPO_BASE/$os/ /
+deb $opts$CRIO_REPO_BASE:/cri-o:/$CRIO_VERSION/$os/ /
+EOF
+    fi
+    apt-get update
+
+    log "checking we have everything we need..."
+    [ -x $KUBEADM_EXE ] || apt-get install -y $PKG_APT_PACKAGES $(runtime_packages APT) || \
+        (abort "could not finish the installation of kubeadm" && rm -f $PKG_APT_SRCLST)
+    configure_apparmor "apt-get install -y" "apparmor apparmor-utils"
+    log "... everything installed"
+    hold_packages
+    restart_services
+}
+{{ end }}
+{{ define "binaries" }}
+# installation from the release binaries, for OSes without a package manager
+# (or with a read-only /usr), like Flatcar/Container Linux
+install_binaries() {
+    log "installing Kubernetes $KUBE_VERSION release binaries ($BIN_ARCH) in $BIN_DIR..."
+    mkdir -p $BIN_DIR $CNI_BIN_DIR
+
+    log "downloading CNI plugins $CNI_VERSION..."
+    curl -sSL "$CNI_URL/$CNI_VERSION/cni-plugins-linux-$BIN_ARCH-$CNI_VERSION.tgz" | tar -C $CNI_BIN_DIR -xz || \
+        abort "could not download the CNI plugins"
+
+    log "downloading crictl $CRICTL_VERSION..."
+    curl -sSL "$CRICTL_URL/$CRICTL_VERSION/crictl-$CRICTL_VERSION-linux-$BIN_ARCH.tar.gz" | tar -C $BIN_DIR -xz || \
+        warn "could not download crictl"
+
+    for bin in kubeadm kubelet kubectl ; do
+        log "downloading $bin..."
+        curl -sSL -o $BIN_DIR/$bin "$BIN_KUBE_URL/$KUBE_VERSION/bin/linux/$BIN_ARCH/$bin" || \
+            abort "could not download $bin"
+        chmod +x $BIN_DIR/$bin
+    done
+    KUBEADM_EXE="$BIN_DIR/kubeadm"
+
+    log "installing the kubelet service in $BIN_KUBELET_SERVICE..."
+    cat <<EOF > $BIN_KUBELET_SERVICE
+[Unit]
+Description=kubelet: The Kubernetes Node Agent
+Documentation=http://kubernetes.io/docs/
+
+[Service]
+ExecStart=$BIN_DIR/kubelet
+Restart=always
+StartLimitInterval=0
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+EOF
+    mkdir -p $(dirname $BIN_KUBELET_DROPIN)
+    cat <<'EOF' | sed -e "s|/usr/bin/kubelet|$BIN_DIR/kubelet|" > $BIN_KUBELET_DROPIN
+[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=/etc/kubernetes/kubelet.conf"
+Environment="KUBELET_CONFIG_ARGS=--config=/var/lib/kubelet/config.yaml"
+EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
+EnvironmentFile=-/etc/sysconfig/kubelet
+ExecStart=
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
+EOF
+    systemctl daemon-reload
+    log "... everything installed"
+    restart_services
+}
+{{ end }}
+{{ define "common" }}
 LSB_RELEASE="/usr/bin/lsb_release"
 
 # the executable that packages will install, and the packages per distro
 KUBEADM_EXE="/usr/bin/kubeadm"
 
+# the container runtime to install: docker, containerd or crio
+RUNTIME={{ or .Runtime "docker" | quote }}
+
+# the cgroup driver for the runtime (it must be the same used in the kubelet):
+# "systemd" by default for containerd/crio and "cgroupfs" for docker
+CGROUP_DRIVER=${CGROUP_DRIVER:-}
+if [ -z "$CGROUP_DRIVER" ] ; then
+    [ "$RUNTIME" = "docker" ] && CGROUP_DRIVER="cgroupfs" || CGROUP_DRIVER="systemd"
+fi
+
+# the sandbox (pause) image used by containerd/crio
+SANDBOX_IMAGE=${SANDBOX_IMAGE:-k8s.gcr.io/pause:3.1}
+
+# install cri-dockerd, the CRI shim for docker (the dockershim was removed in Kubernetes 1.24)
+CRI_DOCKERD=${CRI_DOCKERD:-}
+CRI_DOCKERD_VERSION=${CRI_DOCKERD_VERSION:-0.3.4}
+CRI_DOCKERD_URL="https://github.com/Mirantis/cri-dockerd/releases/download"
+CRI_DOCKERD_SERVICE="/etc/systemd/system/cri-docker.service"
+CRI_DOCKERD_SOCKET="/etc/systemd/system/cri-docker.socket"
+
+# custom directories for the containerd data and state (empty for the containerd defaults)
+CONTAINERD_ROOT_DIR=${CONTAINERD_ROOT_DIR:-}
+CONTAINERD_STATE_DIR=${CONTAINERD_STATE_DIR:-}
+
+# the Kubernetes version: CRI-O must be installed from the same minor version stream
+KUBE_VERSION={{ or .KubeVersion "v1.15.0" | quote }}
+KUBE_MINOR=$(echo $KUBE_VERSION | sed -e 's/^v//' | cut -d. -f1,2)
+
+# the version of the packages (without the "v"), so we do not install a kubeadm/kubelet
+# newer than the cluster version
+PKG_VERSION=$(echo $KUBE_VERSION | sed -e 's/^v//')
+PKG_HOLD="kubeadm kubelet kubectl"
+CRIO_VERSION=$KUBE_MINOR
+
+# SELinux mode: "permissive", "enforcing" (installing the container-selinux policies) or "disabled"
+SELINUX=${SELINUX:-permissive}
+
+# AppArmor: "enabled" (installing the tools needed for loading the runtime profiles),
+# "disabled" or empty for leaving it untouched
+APPARMOR=${APPARMOR:-}
+
+# the installation mode: "packages" (with the distro package manager) or
+# "binaries" (downloading the release binaries, for distros without a package
+# manager or with a read-only /usr, like Flatcar)
+INSTALL_MODE=${INSTALL_MODE:-packages}
+
+# air-gapped installations: a directory (in this machine) with all the packages
+# or the URL of a repository in some internal mirror (only one of them should be used)
+OFFLINE_PACKAGES_DIR=${OFFLINE_PACKAGES_DIR:-}
+OFFLINE_REPO_URL=${OFFLINE_REPO_URL:-}
+OFFLINE_REPO_NAME="kubernetes-offline"
+
+# release binaries installation
+BIN_DIR="/opt/bin"
+BIN_ARCH=
+BIN_KUBE_URL="https://storage.googleapis.com/kubernetes-release/release"
+CNI_VERSION=${CNI_VERSION:-v0.8.2}
+CNI_BIN_DIR="/opt/cni/bin"
+CNI_URL="https://github.com/containernetworking/plugins/releases/download"
+CRICTL_VERSION=${CRICTL_VERSION:-v$KUBE_MINOR.0}
+CRICTL_URL="https://github.com/kubernetes-sigs/cri-tools/releases/download"
+BIN_KUBELET_SERVICE="/etc/systemd/system/kubelet.service"
+BIN_KUBELET_DROPIN="/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"
+
+CONTAINERD_CONFIG="/etc/containerd/config.toml"
+DOCKER_DAEMON_CONFIG="/etc/docker/daemon.json"
+
+CRIO_CONFIG="/etc/crio/crio.conf"
+CRIO_CONFIG_DROPIN="/etc/crio/crio.conf.d/01-kubeadm.conf"
+CRIO_REGISTRIES_CONFIG="/etc/containers/registries.conf"
+CRIO_REPO_BASE={{ or .Repo.CRIOURL "https://download.opensuse.org/repositories/devel:/kubic:/libcontainers:/stable" | quote }}
+
 PKG_SUSE="kubernetes-kubeadm"
 PKG_SUSE_REPO="https://download.opensuse.org/repositories/devel:/kubic/openSUSE_Leap_15.1/"
 PKG_SUSE_REPOFILE="/etc/zypp/repos.d/kubernetes.repo"
 PKG_SUSE_PACKAGES="$PKG_SUSE kubernetes-kubelet kubernetes-client"
+PKG_SUSE_HOLD="$PKG_SUSE kubernetes-kubelet kubernetes-client"
+PKG_SUSE_RUNTIME_docker=""
+PKG_SUSE_RUNTIME_containerd="containerd"
+PKG_SUSE_RUNTIME_crio="cri-o cri-tools"
+
+# the community-owned repositories (one per minor version), that can be replaced by
+# some mirror (with the same layout) with PKG_REPO and PKG_REPO_GPG
+PKG_REPO={{ quote .Repo.URL }}
+PKG_REPO_GPG={{ quote .Repo.GPGKey }}
+# the signatures of the repositories can be disabled (for mirrors that are not signed),
+# and the GPG key can be verified against a fingerprint (for mirrors that are re-signed)
+PKG_REPO_GPG_CHECK={{ not .Repo.SkipGPGCheck }}
+PKG_REPO_GPG_FINGERPRINT={{ quote .Repo.GPGFingerprint }}
+PKG_REPO_BASE="https://pkgs.k8s.io/core:/stable:/v$KUBE_MINOR"
+# pkgs.k8s.io only hosts Kubernetes 1.24 and higher: older versions are installed from
+# the legacy (and frozen) Google-hosted repositories (see the end of this section)
+PKG_REPO_LEGACY=false
 
 PKG_APT="kubeadm"
-PKG_APT_REPO="http://apt.kubernetes.io/"
-PKG_APT_GPG="https://packages.cloud.google.com/apt/doc/apt-key.gpg"
-PKG_APT_PACKAGES="$PKG_APT kubelet kubectl docker.io kubernetes-cni"
-PKG_APT_PACKAGES_PRE="apt-transport-https ebtables ethtool"
+PKG_APT_REPO=${PKG_REPO:-$PKG_REPO_BASE/deb}
+PKG_APT_GPG=${PKG_REPO_GPG:-$PKG_APT_REPO/Release.key}
+PKG_APT_KEYRING="/etc/apt/keyrings/kubernetes-apt-keyring.gpg"
+PKG_APT_SUITE="/"
+PKG_APT_PACKAGES="$PKG_APT kubelet kubectl kubernetes-cni"
+[ -n "$PKG_VERSION" ] && PKG_APT_PACKAGES="$PKG_APT=$PKG_VERSION-* kubelet=$PKG_VERSION-* kubectl=$PKG_VERSION-* kubernetes-cni"
+PKG_APT_RUNTIME_docker="docker.io"
+PKG_APT_RUNTIME_containerd="containerd"
+PKG_APT_RUNTIME_crio="cri-o cri-o-runc"
+PKG_APT_CRIO_SRCLST="/etc/apt/sources.list.d/cri-o.list"
+PKG_APT_PACKAGES_PRE="apt-transport-https ca-certificates curl gpg ebtables ethtool"
 PKG_APT_SRCLST="/etc/apt/sources.list.d/kubernetes.list"
 
 PKG_YUM="kubeadm"
+PKG_YUM_REPO=${PKG_REPO:-$PKG_REPO_BASE/rpm}
+PKG_YUM_GPG=${PKG_REPO_GPG:-$PKG_YUM_REPO/repodata/repomd.xml.key}
 PKG_YUM_REPOFILE="/etc/yum.repos.d/kubernetes.repo"
-PKG_YUM_PACKAGES="$PKG_YUM kubelet kubernetes-cni docker kubectl"
+PKG_YUM_GPG_FILE="/etc/pki/rpm-gpg/RPM-GPG-KEY-kubernetes"
+PKG_YUM_PACKAGES="$PKG_YUM kubelet kubernetes-cni kubectl"
+[ -n "$PKG_VERSION" ] && PKG_YUM_PACKAGES="$PKG_YUM-$PKG_VERSION kubelet-$PKG_VERSION kubernetes-cni kubectl-$PKG_VERSION"
+PKG_YUM_RUNTIME_docker="docker"
+PKG_YUM_RUNTIME_containerd="containerd.io"
+PKG_YUM_RUNTIME_crio="cri-o"
+PKG_YUM_CRIO_REPOFILE="/etc/yum.repos.d/cri-o.repo"
+PKG_YUM_LIBCONTAINERS_REPOFILE="/etc/yum.repos.d/libcontainers.repo"
+PKG_YUM_DOCKER_CE_REPO={{ or .Repo.DockerURL "https://download.docker.com/linux/centos/docker-ce.repo" | quote }}
+PKG_YUM_DOCKER_CE_REPOFILE="/etc/yum.repos.d/docker-ce.repo"
 PKG_YUM_DEF_RELEASE=7
 
+# the yum-compatible package manager (yum or dnf)
+YUM="yum"
+
 ZYPPER_AR_ARGS="--non-interactive"
 ZYPPER_IN_ARGS="-y --no-recommends --auto-agree-with-licenses"
 
@@ -38,178 +278,538 @@ ID=
 DIST=
 RELEASE=
 
+# the machine architecture (as reported by "uname -m") and the equivalent
+# architecture name used by Kubernetes for binaries and images
+MACHINE=$(uname -m)
+case $MACHINE in
+x86_64)  ARCH="amd64" ;;
+aarch64) ARCH="arm64" ;;
+armv7l)  ARCH="arm" ;;
+ppc64le) ARCH="ppc64le" ;;
+s390x)   ARCH="s390x" ;;
+*)       ARCH="$MACHINE" ;;
+esac
+BIN_ARCH=$ARCH
+
+# the architecture name used in the yum repos
+case $MACHINE in
+armv7l) YUM_ARCH="armhfp" ;;
+*)      YUM_ARCH="$MACHINE" ;;
+esac
+
+# the legacy repositories (or some mirror with the same layout) for versions before 1.24
+if [ "$(echo $KUBE_MINOR | cut -d. -f1)" = "1" ] && [ "$(echo $KUBE_MINOR | cut -d. -f2)" -lt 24 ] ; then
+    PKG_REPO_LEGACY=true
+    PKG_APT_REPO=${PKG_REPO:-https://apt.kubernetes.io}
+    PKG_APT_GPG=${PKG_REPO_GPG:-https://packages.cloud.google.com/apt/doc/apt-key.gpg}
+    PKG_APT_SUITE="kubernetes-xenial main"
+    PKG_YUM_REPO=${PKG_REPO:-https://packages.cloud.google.com/yum/repos/kubernetes-el7-$YUM_ARCH}
+    PKG_YUM_GPG=${PKG_REPO_GPG:-https://packages.cloud.google.com/yum/doc/rpm-package-key.gpg}
+fi
+
 ##########################################################################################
 
 log()    { echo "[kubeadm setup script] $@" ; }
 warn()   { log "WARNING!!!!: $@" ; }
 abort()  { log "FATAL!!!!: $@" ; exit 1 ; }
 
-restart_services() {
-    log "starting services"
-    systemctl enable --now docker  || abort "could not start docker"
-    systemctl enable --now kubelet || abort "could not start kubelet"
+# get the packages for the container runtime for a packages manager (SUSE, APT or YUM)
+runtime_packages() {
+    eval echo "\$PKG_$1_RUNTIME_$RUNTIME"
 }
 
-##########################################################################################
+configure_containerd() {
+    log "configuring containerd in $CONTAINERD_CONFIG"
 
-# installation for SUSE variants: OpenSUSE/SLE/CaaSP...
-install_zypper() {
-    source /etc/os-release
-    local repo_name=$(basename $PKG_SUSE_REPOFILE .repo)
+    mkdir -p $(dirname $CONTAINERD_CONFIG)
+    containerd config default > $CONTAINERD_CONFIG || abort "could not generate the containerd configuration"
+    sed -i -e "s|sandbox_image = .*|sandbox_image = \"$SANDBOX_IMAGE\"|" $CONTAINERD_CONFIG
+    if [ -n "$CONTAINERD_ROOT_DIR" ] ; then
+        mkdir -p $CONTAINERD_ROOT_DIR
+        sed -i -e "s|^root = .*|root = \"$CONTAINERD_ROOT_DIR\"|" $CONTAINERD_CONFIG
+    fi
+    if [ -n "$CONTAINERD_STATE_DIR" ] ; then
+        mkdir -p $CONTAINERD_STATE_DIR
+        sed -i -e "s|^state = .*|state = \"$CONTAINERD_STATE_DIR\"|" $CONTAINERD_CONFIG
+    fi
 
-    if [ ! -f $PKG_SUSE_REPOFILE ] ; then
-        log "adding repo from $PKG_SUSE_REPO..."
-        zypper $ZYPPER_AR_ARGS --quiet addrepo --refresh $PKG_SUSE_REPO $repo_name
+    # set the cgroup driver (the way of setting it depends on the containerd version)
+    local systemd_cgroup=false
+    [ "$CGROUP_DRIVER" = "systemd" ] && systemd_cgroup=true
+    if grep -q 'SystemdCgroup' $CONTAINERD_CONFIG ; then
+        sed -i -e "s|SystemdCgroup = .*|SystemdCgroup = $systemd_cgroup|" $CONTAINERD_CONFIG
+    elif grep -q 'runtimes.runc.options\]' $CONTAINERD_CONFIG ; then
+        sed -i -e "/runtimes.runc.options\]/a\            SystemdCgroup = $systemd_cgroup" $CONTAINERD_CONFIG
     else
-        log "repository already found: skipping installation of the repo"
+        sed -i -e "s|systemd_cgroup = .*|systemd_cgroup = $systemd_cgroup|" $CONTAINERD_CONFIG
     fi
-    log "refreshing packages..."
-    zypper $ZYPPER_AR_ARGS --gpg-auto-import-keys refresh $repo_name
-
-    log "checking we have everything we need..."
-    zypper in $ZYPPER_IN_ARGS $PKG_SUSE_PACKAGES || \
-        (abort "could not finish the installation of kubeadm" && rm -f $PKG_SUSE_REPOFILE)
-    log "... everything installed"
-    restart_services
 }
 
-# installation for RedHat variants: RedHat/CentOS...
-install_yum() {
-    log "Installing for RedHat..."
-    if [ ! -f $PKG_YUM_REPOFILE ] ; then
-        [ -n "$RELEASE" ] || RELEASE=$PKG_YUM_DEF_RELEASE
-        cat <<EOF > $PKG_YUM_REPOFILE
-[kubernetes]
-name=Kubernetes
-baseurl=http://yum.kubernetes.io/repos/kubernetes-el$RELEASE-x86_64
-enabled=1
-gpgcheck=1
-repo_gpgcheck=1
-gpgkey=https://packages.cloud.google.com/yum/doc/yum-key.gpg
-       https://packages.cloud.google.com/yum/doc/rpm-package-key.gpg
+configure_crio() {
+    log "configuring CRI-O"
+
+    # use our cgroup manager and sandbox image
+    # (conmon must run in the pod cgroup when using cgroupfs)
+    local conmon_cgroup="system.slice"
+    [ "$CGROUP_DRIVER" = "systemd" ] || conmon_cgroup="pod"
+    if [ -f $CRIO_CONFIG ] && ! [ -d $(dirname $CRIO_CONFIG_DROPIN) ] ; then
+        sed -i -e "s|^#* *cgroup_manager = .*|cgroup_manager = \"$CGROUP_DRIVER\"|" \
+               -e "s|^#* *conmon_cgroup = .*|conmon_cgroup = \"$conmon_cgroup\"|" \
+               -e "s|^#* *pause_image = .*|pause_image = \"$SANDBOX_IMAGE\"|" $CRIO_CONFIG
+    else
+        mkdir -p $(dirname $CRIO_CONFIG_DROPIN)
+        cat <<EOF > $CRIO_CONFIG_DROPIN
+[crio.runtime]
+cgroup_manager = "$CGROUP_DRIVER"
+conmon_cgroup = "$conmon_cgroup"
+
+[crio.image]
+pause_image = "$SANDBOX_IMAGE"
 EOF
-        setenforce 0
-
-        # Set SELinux in permissive mode (effectively disabling it)
-        sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config
+    fi
 
-        cat <<EOF >  /etc/sysctl.d/k8s.conf
-net.bridge.bridge-nf-call-ip6tables = 1
-net.bridge.bridge-nf-call-iptables = 1
+    # make sure unqualified images can be pulled from the usual registries
+    if [ ! -f $CRIO_REGISTRIES_CONFIG ] ; then
+        mkdir -p $(dirname $CRIO_REGISTRIES_CONFIG)
+        cat <<EOF > $CRIO_REGISTRIES_CONFIG
+unqualified-search-registries = ["docker.io", "quay.io"]
 EOF
-        sysctl --system
-    else
-        log "repository already found: skipping installation of the repo"
     fi
+}
 
-    log "checking we have everything we need..."
-    yum install -y $PKG_YUM_PACKAGES || \
-        (abort "could not finish the installation of kubeadm" && rm -f $PKG_YUM_REPOFILE)
-    log "... everything installed"
+configure_docker() {
+    log "configuring docker with the $CGROUP_DRIVER cgroup driver"
+    local unit=/usr/lib/systemd/system/docker.service
+    [ -f $unit ] || unit=/lib/systemd/system/docker.service
+
+    # some distros set the cgroup driver in the command line: docker would refuse
+    # to start if it was also set in the daemon.json
+    if [ -f $unit ] && grep -q 'cgroupdriver=' $unit ; then
+        cp $unit /etc/systemd/system/docker.service
+        sed -i "s/cgroupdriver=[a-z]*/cgroupdriver=$CGROUP_DRIVER/" /etc/systemd/system/docker.service
+        systemctl daemon-reload
+    elif [ ! -f $DOCKER_DAEMON_CONFIG ] ; then
+        mkdir -p $(dirname $DOCKER_DAEMON_CONFIG)
+        echo "{\"exec-opts\":[\"native.cgroupdriver=$CGROUP_DRIVER\"]}" > $DOCKER_DAEMON_CONFIG
+    elif ! grep -q "native.cgroupdriver=$CGROUP_DRIVER" $DOCKER_DAEMON_CONFIG ; then
+        warn "$DOCKER_DAEMON_CONFIG already exists: make sure docker uses the $CGROUP_DRIVER cgroup driver"
+    fi
+}
 
-    # we must use the "cgroupfs"
-    cp /usr/lib/systemd/system/docker.service /etc/systemd/system/
-    sed -i 's/cgroupdriver=systemd/cgroupdriver=cgroupfs/' /etc/systemd/system/docker.service
+# install (when it is not already installed) and start cri-dockerd
+install_cri_dockerd() {
+    local exe=$(command -v cri-dockerd)
+    if [ -z "$exe" ] ; then
+        log "downloading cri-dockerd $CRI_DOCKERD_VERSION..."
+        mkdir -p $BIN_DIR
+        curl -sSL "$CRI_DOCKERD_URL/v$CRI_DOCKERD_VERSION/cri-dockerd-$CRI_DOCKERD_VERSION.$BIN_ARCH.tgz" | \
+            tar -C $BIN_DIR --strip-components=1 -xz cri-dockerd/cri-dockerd || abort "could not download cri-dockerd"
+        exe=$BIN_DIR/cri-dockerd
+    fi
 
-    restart_services
+    log "configuring cri-dockerd ($exe) with the $SANDBOX_IMAGE sandbox image"
+    cat <<EOF > $CRI_DOCKERD_SERVICE
+[Unit]
+Description=CRI Interface for Docker Application Container Engine
+After=network-online.target firewalld.service docker.service
+Wants=network-online.target
+Requires=cri-docker.socket
+
+[Service]
+Type=notify
+ExecStart=$exe --container-runtime-endpoint fd:// --network-plugin=cni --pod-infra-container-image=$SANDBOX_IMAGE
+ExecReload=/bin/kill -s HUP \$MAINPID
+TimeoutSec=0
+RestartSec=2
+Restart=always
+StartLimitBurst=3
+StartLimitInterval=60s
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+Delegate=yes
+KillMode=process
+
+[Install]
+WantedBy=multi-user.target
+EOF
+    cat <<EOF > $CRI_DOCKERD_SOCKET
+[Unit]
+Description=CRI Docker Socket for the API
+PartOf=cri-docker.service
+
+[Socket]
+ListenStream=/run/cri-dockerd.sock
+SocketMode=0660
+SocketUser=root
+SocketGroup=root
+
+[Install]
+WantedBy=sockets.target
+EOF
+    systemctl daemon-reload
+    systemctl enable --now cri-docker.socket || abort "could not start the cri-dockerd socket"
+    systemctl enable cri-docker.service      || abort "could not enable cri-dockerd"
+    systemctl restart cri-docker.service     || abort "could not start cri-dockerd"
 }
 
-# installation for Debian variants: debian/Ubuntu...
-install_apt() {
-    log "installing for Ubuntu|Debian..."
-    if [ ! -f $PKG_APT_SRCLST ] ; then
-        apt-get update && apt-get install -y $PKG_APT_PACKAGES_PRE || \
-            (abort "could not finish the installation of the requirements" && rm -f $PKG_APT_SRCLST)
-        curl -s "$PKG_APT_GPG" | apt-key add -
-        echo "deb $PKG_APT_REPO kubernetes-xenial main" >> $PKG_APT_SRCLST
-    else
-        log "repository already found: skipping installation of the repo"
+restart_services() {
+    log "starting services"
+    case $RUNTIME in
+    containerd)
+        configure_containerd
+        systemctl enable containerd  || abort "could not enable containerd"
+        systemctl restart containerd || abort "could not start containerd"
+        ;;
+    crio)
+        configure_crio
+        systemctl daemon-reload
+        systemctl enable crio  || abort "could not enable crio"
+        systemctl restart crio || abort "could not start crio"
+        ;;
+    *)
+        configure_docker
+        systemctl enable --now docker  || abort "could not start docker"
+        if [ "$CRI_DOCKERD" = "true" ] ; then
+            install_cri_dockerd
+        fi
+        ;;
+    esac
+    systemctl enable --now kubelet || abort "could not start kubelet"
+}
+
+# download the GPG key in $1 to the $2 file, checking the PKG_REPO_GPG_FINGERPRINT
+get_gpg_key() {
+    mkdir -p $(dirname $2)
+    curl -fsSL -o $2 "$1" || abort "could not get the repository key from $1"
+    [ -n "$PKG_REPO_GPG_FINGERPRINT" ] || return 0
+
+    command -v gpg >/dev/null 2>&1 || abort "gpg is required for checking the fingerprint of $1"
+    local fprs=$(gpg --show-keys --with-colons $2 2>/dev/null | awk -F: '/^fpr:/ { print $10 }')
+    echo "$fprs" | grep -qx "$PKG_REPO_GPG_FINGERPRINT" || \
+        { rm -f $2 ; abort "the key from $1 does not have the $PKG_REPO_GPG_FINGERPRINT fingerprint (found: $(echo $fprs))" ; }
+    log "key from $1 verified: $PKG_REPO_GPG_FINGERPRINT"
+}
+
+# remove a repository pointing to the (deprecated and frozen) Google-hosted
+# apt.kubernetes.io/yum.kubernetes.io repositories, so it is replaced by the new one
+# (unless the legacy repositories are the ones we need for this version)
+remove_legacy_repo() {
+    [ "$PKG_REPO_LEGACY" = "true" ] && return 0
+    if [ -f $1 ] && grep -qE "(apt|yum)\.kubernetes\.io|packages\.cloud\.google\.com" $1 ; then
+        log "removing legacy repository in $1"
+        rm -f $1
     fi
-    apt-get update
+}
 
-    log "checking we have everything we need..."
-    [ -x $KUBEADM_EXE ] || apt-get install -y $PKG_APT_PACKAGES || \
-        (abort "could not finish the installation of kubeadm" && rm -f $PKG_APT_SRCLST)
-    log "... everything installed"
-    restart_services
+# hold the Kubernetes packages in the installed version, so they are not
+# upgraded (breaking the version skew policy) by some unattended upgrade
+hold_packages() {
+    log "holding the Kubernetes packages in the installed version"
+    if command -v apt-mark >/dev/null 2>&1 ; then
+        apt-mark hold $PKG_HOLD >/dev/null || warn "could not hold the Kubernetes packages"
+    elif command -v zypper >/dev/null 2>&1 ; then
+        zypper $ZYPPER_AR_ARGS addlock $PKG_SUSE_HOLD >/dev/null || warn "could not lock the Kubernetes packages"
+    elif command -v dnf >/dev/null 2>&1 ; then
+        dnf install -y 'dnf-command(versionlock)' >/dev/null 2>&1
+        dnf versionlock add $PKG_HOLD >/dev/null || warn "could not lock the Kubernetes packages versions"
+    elif command -v yum >/dev/null 2>&1 ; then
+        yum install -y yum-plugin-versionlock >/dev/null 2>&1
+        yum versionlock add $PKG_HOLD >/dev/null || warn "could not lock the Kubernetes packages versions"
+    fi
 }
 
+# disable SELinux (or set it in permissive mode), as required by the kubelet
+configure_selinux() {
+    if ! command -v getenforce >/dev/null 2>&1 ; then
+        return
+    fi
+
+    case $SELINUX in
+    enforcing)
+        log "installing the container-selinux policies and setting SELinux in enforcing mode"
+        rpm -q container-selinux >/dev/null 2>&1 || $YUM install -y container-selinux || \
+            abort "could not install the container-selinux policies"
+        [ "$(getenforce)" = "Disabled" ] || setenforce 1 || warn "could not set SELinux in enforcing mode"
+        ;;
+    disabled)
+        log "disabling SELinux (it will be completely disabled after a reboot)"
+        [ "$(getenforce)" = "Disabled" ] || setenforce 0 || warn "could not set SELinux in permissive mode"
+        ;;
+    *)
+        if [ "$(getenforce)" != "Disabled" ] ; then
+            log "setting SELinux in permissive mode"
+            setenforce 0 || warn "could not set SELinux in permissive mode"
+        fi
+        ;;
+    esac
+
+    # persist the mode (but do not enable SELinux in the permissive mode if it was disabled)
+    if [ -f /etc/selinux/config ] ; then
+        if [ "$SELINUX" = "permissive" ] ; then
+            sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config
+        else
+            sed -i "s/^SELINUX=.*$/SELINUX=$SELINUX/" /etc/selinux/config
+        fi
+    fi
+}
+
+# install (and start) AppArmor, so the container runtimes can load their default profiles
+# arguments: the install command and the AppArmor packages
+configure_apparmor() {
+    case $APPARMOR in
+    enabled)
+        log "enabling AppArmor"
+        command -v apparmor_parser >/dev/null 2>&1 || $1 $2 || \
+            abort "could not install AppArmor"
+        systemctl enable apparmor.service >/dev/null 2>&1
+        systemctl start apparmor.service || warn "could not start AppArmor"
+        ;;
+    disabled)
+        warn "disabling AppArmor: containers will run without any AppArmor profile"
+        systemctl stop apparmor.service >/dev/null 2>&1
+        systemctl disable apparmor.service >/dev/null 2>&1
+        command -v aa-teardown >/dev/null 2>&1 && aa-teardown >/dev/null
+        ;;
+    esac
+}
+{{ end }}
+{{ define "detect" }}
 # installation for other OSes
 install_generic() {
     warn "Using generic installation"
-    RELEASE="$(curl -sSL https://dl.k8s.io/release/stable.txt)"
-    mkdir -p /opt/bin
-    cd /opt/bin
-    curl -L --remote-name-all https://storage.googleapis.com/kubernetes-release/release/${RELEASE}/bin/linux/amd64/{kubeadm,kubelet,kubectl}
-    chmod +x {kubeadm,kubelet,kubectl}
+    install_binaries
 }
 
-##########################################################################################
+# installation for a distro that must be detected in the node: there are two ways we can
+# identify the distro: with the help of lsb-release, or with some key files in /etc (like
+# /etc/debian_version)
+install_auto() {
+    if [ -n "$OFFLINE_PACKAGES_DIR" ] || [ -n "$OFFLINE_REPO_URL" ] ; then
+        install_offline
+    elif [ "$INSTALL_MODE" = "binaries" ] ; then
+        install_binaries
+    elif [ -x $LSB_RELEASE ] ; then
+        ID=$($LSB_RELEASE --short --id)
+        case $ID in
+        RedHatEnterpriseServer|CentOS|Fedora)
+            RELEASE=$($LSB_RELEASE --short --release | cut -d. -f1)
+            install_yum
+            ;;
 
-# there are two ways we can identify the distro: with the help of lsb-release, or
-# with some key files in /etc (like /etc/debian_version)
-if [ -x $LSB_RELEASE ] ; then
-    ID=$($LSB_RELEASE --short --id)
-    case $ID in
-    RedHatEnterpriseServer|CentOS|Fedora)
-        RELEASE=$($LSB_RELEASE --short --release | cut -d. -f1)
-        install_yum
-        ;;
+        Ubuntu|Debian)
+            install_apt
+            ;;
 
-    Ubuntu|Debian)
-        install_apt
-        ;;
+        Amazon*)
+            install_amzn $($LSB_RELEASE --short --release | cut -d. -f1)
+            ;;
 
-    *SUSE*)
-        desc=$($LSB_RELEASE --short --description)
-        RELEASE=$($LSB_RELEASE --short --release)
-        case $desc in
-        *openSUSE*)
-            DIST=opensuse$RELEASE
+        *SUSE*)
+            desc=$($LSB_RELEASE --short --description)
+            RELEASE=$($LSB_RELEASE --short --release)
+            case $desc in
+            *openSUSE*)
+                DIST=opensuse$RELEASE
+                ;;
+            *Enterprise*)
+                DIST=sles$RELEASE
+                ;;
+            esac
+            install_zypper
             ;;
-        *Enterprise*)
-            DIST=sles$RELEASE
+
+        *)
+            install_generic
             ;;
         esac
-        install_zypper
-        ;;
-
-    *)
-        install_generic
-        ;;
-    esac
-elif [ /etc/os-release ] ; then
-    source /etc/os-release
-    case $NAME in
-    RedHatEnterpriseServer|CentOS|Fedora)
-        install_yum
-        ;;
-    Ubuntu|Debian)
+    elif [ /etc/os-release ] ; then
+        source /etc/os-release
+        case $NAME in
+        RedHatEnterpriseServer|CentOS|Fedora)
+            install_yum
+            ;;
+        Ubuntu|Debian)
+            install_apt
+            ;;
+        "Amazon Linux"*)
+            install_amzn $VERSION_ID
+            ;;
+        *SUSE*)
+            install_zypper
+            ;;
+        *Flatcar*|*"Container Linux"*)
+            install_binaries
+            ;;
+        *)
+            install_generic
+            ;;
+        esac
+    elif [ -f /etc/debian_version ] ; then
         install_apt
-        ;;
-    *SUSE*)
+    elif [ -f /etc/fedora-release ] ; then
+        install_yum
+    elif [ -f /etc/system-release ] && grep -q "Amazon Linux" /etc/system-release ; then
+        install_amzn $(grep -q "2023" /etc/system-release && echo 2023 || echo 2)
+    elif [ -f /etc/redhat-release ] ; then
+        install_yum
+    elif [ -f /etc/SuSE-release ] ; then
         install_zypper
-        ;;
-    *)
+    elif [ -f /etc/centos-release ] ; then
+        install_yum
+    else
         install_generic
-        ;;
-    esac
-elif [ -f /etc/debian_version ] ; then
-        install_apt
-elif [ -f /etc/fedora-release ] ; then
-    install_yum
-elif [ -f /etc/redhat-release ] ; then
-    install_yum
-elif [ -f /etc/SuSE-release ] ; then
-    install_zypper
-elif [ -f /etc/centos-release ] ; then
-    install_yum
-else
-    install_generic
-fi
+    fi
+}
+{{ end }}
+{{ define "header" -}}
+#!/bin/sh
+
+##########################################################################################
+# kubeadm setup script (for {{ .Distro }})
+##########################################################################################
+{{- if .Proxy }}
+
+# the proxy for downloading packages and binaries
+{{- range $k, $v := .Proxy }}
+export {{ $k }}={{ quote $v }}
+{{- end }}
+{{- end }}
+{{ end }}
+{{ define "main" }}
+##########################################################################################
+{{ if .Release }}
+RELEASE={{ quote .Release }}
+{{- end }}
+install_{{ .Distro }} "$RELEASE"
 
 [ -x $KUBEADM_EXE ] || abort "no kubeadm executable available at $KUBEADM_EXE"
+{{ end }}
+{{ define "offline" }}
+# installation without access to the public repositories, from a directory with
+# packages or from a repository in an internal mirror
+install_offline() {
+    if [ -n "$OFFLINE_PACKAGES_DIR" ] ; then
+        log "installing packages from $OFFLINE_PACKAGES_DIR..."
+        if ls $OFFLINE_PACKAGES_DIR/*.deb >/dev/null 2>&1 ; then
+            dpkg -i $OFFLINE_PACKAGES_DIR/*.deb || abort "could not install the packages in $OFFLINE_PACKAGES_DIR"
+        elif ls $OFFLINE_PACKAGES_DIR/*.rpm >/dev/null 2>&1 ; then
+            rpm -Uvh --replacepkgs $OFFLINE_PACKAGES_DIR/*.rpm || abort "could not install the packages in $OFFLINE_PACKAGES_DIR"
+        else
+            abort "no packages found in $OFFLINE_PACKAGES_DIR"
+        fi
+    else
+        log "installing packages from the $OFFLINE_REPO_URL mirror..."
+        if command -v apt-get >/dev/null 2>&1 ; then
+            echo "deb [trusted=yes] $OFFLINE_REPO_URL ./" > /etc/apt/sources.list.d/$OFFLINE_REPO_NAME.list
+            apt-get update
+            apt-get install -y $PKG_APT_PACKAGES $(runtime_packages APT) || \
+                abort "could not finish the installation of kubeadm"
+        elif command -v zypper >/dev/null 2>&1 ; then
+            zypper $ZYPPER_AR_ARGS --quiet addrepo --no-gpgcheck $OFFLINE_REPO_URL $OFFLINE_REPO_NAME
+            zypper in $ZYPPER_IN_ARGS --repo $OFFLINE_REPO_NAME $PKG_SUSE_PACKAGES $(runtime_packages SUSE) || \
+                abort "could not finish the installation of kubeadm"
+        else
+            command -v dnf >/dev/null 2>&1 && YUM="dnf"
+            cat <<EOF > /etc/yum.repos.d/$OFFLINE_REPO_NAME.repo
+[$OFFLINE_REPO_NAME]
+name=Kubernetes (offline)
+baseurl=$OFFLINE_REPO_URL
+enabled=1
+gpgcheck=0
+EOF
+            $YUM install -y --disablerepo='*' --enablerepo=$OFFLINE_REPO_NAME $PKG_YUM_PACKAGES $(runtime_packages YUM) || \
+                abort "could not finish the installation of kubeadm"
+        fi
+    fi
 
+    configure_selinux
+    log "... everything installed"
+    hold_packages
+    restart_services
+}
+{{ end }}
+{{ define "yum" }}
+# installation for RedHat variants: RedHat/CentOS...
+install_yum() {
+    log "Installing for RedHat ($YUM_ARCH)..."
+    remove_legacy_repo $PKG_YUM_REPOFILE
+    if [ ! -f $PKG_YUM_REPOFILE ] ; then
+        log "adding repo from $PKG_YUM_REPO..."
+        local gpgcheck=1
+        local gpgkey=$PKG_YUM_GPG
+        if [ "$PKG_REPO_GPG_CHECK" != "true" ] ; then
+            warn "the signatures of the packages in $PKG_YUM_REPO will not be checked"
+            gpgcheck=0
+        elif [ -n "$PKG_REPO_GPG_FINGERPRINT" ] ; then
+            get_gpg_key "$PKG_YUM_GPG" $PKG_YUM_GPG_FILE
+            gpgkey=file://$PKG_YUM_GPG_FILE
+        fi
+        cat <<EOF > $PKG_YUM_REPOFILE
+[kubernetes]
+name=Kubernetes
+baseurl=$PKG_YUM_REPO/
+enabled=1
+gpgcheck=$gpgcheck
+repo_gpgcheck=$gpgcheck
+gpgkey=$gpgkey
+EOF
+    else
+        log "repository already found: skipping installation of the repo"
+    fi
 
+    if [ "$RUNTIME" = "containerd" ] && [ -n "$PKG_YUM_DOCKER_CE_REPO" ] && [ ! -f $PKG_YUM_DOCKER_CE_REPOFILE ] ; then
+        log "adding the Docker CE repository for containerd..."
+        curl -sSL -o $PKG_YUM_DOCKER_CE_REPOFILE $PKG_YUM_DOCKER_CE_REPO || \
+            abort "could not add the Docker CE repository"
+    fi
 
+    if [ "$RUNTIME" = "crio" ] && [ ! -f $PKG_YUM_CRIO_REPOFILE ] ; then
+        [ -n "$RELEASE" ] || RELEASE=$PKG_YUM_DEF_RELEASE
+        log "adding the CRI-O $CRIO_VERSION repository..."
+        curl -sSL -o $PKG_YUM_LIBCONTAINERS_REPOFILE \
+            $CRIO_REPO_BASE/CentOS_$RELEASE/devel:kubic:libcontainers:stable.repo || \
+            abort "could not add the libcontainers repository"
+        curl -sSL -o $PKG_YUM_CRIO_REPOFILE \
+            $CRIO_REPO_BASE:/cri-o:/$CRIO_VERSION/CentOS_$RELEASE/devel:kubic:libcontainers:stable:cri-o:$CRIO_VERSION.repo || \
+            abort "could not add the CRI-O repository"
+    fi
+
+    log "checking we have everything we need..."
+    $YUM install -y $PKG_YUM_PACKAGES $(runtime_packages YUM) || \
+        (abort "could not finish the installation of kubeadm" && rm -f $PKG_YUM_REPOFILE)
+    configure_selinux
+    log "... everything installed"
+    hold_packages
+
+    restart_services
+}
+{{ end }}
+{{ define "zypper" }}
+# installation for SUSE variants: OpenSUSE/SLE/CaaSP...
+install_zypper() {
+    source /etc/os-release
+    local repo_name=$(basename $PKG_SUSE_REPOFILE .repo)
+
+    if [ ! -f $PKG_SUSE_REPOFILE ] ; then
+        log "adding repo from $PKG_SUSE_REPO..."
+        local gpg_args=""
+        [ "$PKG_REPO_GPG_CHECK" = "true" ] || gpg_args="--no-gpgcheck"
+        zypper $ZYPPER_AR_ARGS --quiet addrepo --refresh $gpg_args $PKG_SUSE_REPO $repo_name
+    else
+        log "repository already found: skipping installation of the repo"
+    fi
+    log "refreshing packages..."
+    zypper $ZYPPER_AR_ARGS --gpg-auto-import-keys refresh $repo_name
+
+    log "checking we have everything we need..."
+    zypper in $ZYPPER_IN_ARGS $PKG_SUSE_PACKAGES $(runtime_packages SUSE) || \
+        (abort "could not finish the installation of kubeadm" && rm -f $PKG_SUSE_REPOFILE)
+    configure_apparmor "zypper in $ZYPPER_IN_ARGS" "apparmor-parser apparmor-utils"
+    log "... everything installed"
+    hold_packages
+    restart_services
+}
+{{ end }}
 `
@@ -0,0 +1,121 @@
+// Code generated automatically with go generate; DO NOT EDIT.
+
+package assets
+
+const KubeadmSetupWindowsScriptCode = `#
+# kubeadm-setup.ps1
+#
+# A script for installing containerd, kubelet and kubeadm in a Windows Server node
+# (Windows Server 2019 or higher), leaving the machine ready for doing a "kubeadm join".
+#
+param(
+    [string]$KubernetesVersion = "v1.15.0",
+    [string]$ContainerdVersion = "1.4.4",
+    [string]$InstallDir = "C:/k"
+)
+
+$ErrorActionPreference = "Stop"
+$ProgressPreference = "SilentlyContinue"
+
+function Log([string]$msg) { Write-Output ">>> $msg" }
+
+function Download([string]$url, [string]$dst) {
+    if (Test-Path $dst) {
+        Log "$dst already present: skipping download"
+        return
+    }
+    Log "Downloading $url"
+    [Net.ServicePointManager]::SecurityProtocol = [Net.SecurityProtocolType]::Tls12
+    Invoke-WebRequest -UseBasicParsing -Uri $url -OutFile $dst
+}
+
+Log "Installing Kubernetes $KubernetesVersion in $InstallDir"
+New-Item -ItemType Directory -Force -Path $InstallDir | Out-Null
+New-Item -ItemType Directory -Force -Path "C:/var/lib/kubelet/etc/kubernetes/pki" | Out-Null
+New-Item -ItemType Directory -Force -Path "C:/var/log/kubelet" | Out-Null
+New-Item -ItemType Directory -Force -Path "C:/etc/kubernetes/pki" | Out-Null
+
+#
+# Containers feature
+#
+$feature = Get-WindowsFeature -Name Containers
+if (-not $feature.Installed) {
+    Log "Installing the Containers feature"
+    $res = Install-WindowsFeature -Name Containers
+    if ($res.RestartNeeded -eq "Yes") {
+        Log "WARNING: a reboot is required for finishing the installation of the Containers feature"
+    }
+}
+
+#
+# containerd
+#
+$containerdDir = "$Env:ProgramFiles/containerd"
+if (-not (Test-Path "$containerdDir/containerd.exe")) {
+    $tarball = "$InstallDir/containerd.tar.gz"
+    Download "https://github.com/containerd/containerd/releases/download/v$ContainerdVersion/containerd-$ContainerdVersion-windows-amd64.tar.gz" $tarball
+    New-Item -ItemType Directory -Force -Path $containerdDir | Out-Null
+    tar.exe -xzf $tarball -C $containerdDir --strip-components=1
+    Remove-Item -Force $tarball
+}
+
+if (-not (Get-Service -Name containerd -ErrorAction SilentlyContinue)) {
+    Log "Registering the containerd service"
+    & "$containerdDir/containerd.exe" config default | Out-File -Encoding ascii "$containerdDir/config.toml"
+    & "$containerdDir/containerd.exe" --register-service
+}
+Set-Service -Name containerd -StartupType Automatic
+Start-Service -Name containerd
+
+#
+# Kubernetes binaries
+#
+foreach ($bin in @("kubelet.exe", "kubeadm.exe", "kubectl.exe")) {
+    Download "https://dl.k8s.io/$KubernetesVersion/bin/windows/amd64/$bin" "$InstallDir/$bin"
+}
+
+$path = [Environment]::GetEnvironmentVariable("Path", [EnvironmentVariableTarget]::Machine)
+if (-not $path.Contains($InstallDir)) {
+    Log "Adding $InstallDir to the PATH"
+    [Environment]::SetEnvironmentVariable("Path", "$path;$InstallDir;$containerdDir", [EnvironmentVariableTarget]::Machine)
+}
+$Env:Path += ";$InstallDir;$containerdDir"
+
+#
+# kubelet service
+#
+# kubeadm writes the kubelet flags in C:/var/lib/kubelet/kubeadm-flags.env, so we use a
+# wrapper script that loads these flags before starting the kubelet
+$startKubelet = @'
+$FileContent = Get-Content -Path "C:/var/lib/kubelet/kubeadm-flags.env"
+$KubeletArgs = $FileContent.TrimStart('KUBELET_KUBEADM_ARGS=').Trim('"')
+$cmd = "C:/k/kubelet.exe $KubeletArgs --cert-dir=C:/var/lib/kubelet/pki --config=C:/var/lib/kubelet/config.yaml --bootstrap-kubeconfig=C:/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=C:/etc/kubernetes/kubelet.conf --hostname-override=$(hostname) --enable-debugging-handlers --cgroups-per-qos=false --enforce-node-allocatable='' --resolv-conf='' --log-dir=C:/var/log/kubelet --logtostderr=false"
+Invoke-Expression $cmd
+'@
+$startKubelet | Out-File -Encoding ascii "$InstallDir/StartKubelet.ps1"
+
+if (-not (Get-Service -Name kubelet -ErrorAction SilentlyContinue)) {
+    Log "Registering the kubelet service"
+    $nssm = "$InstallDir/nssm.exe"
+    if (-not (Test-Path $nssm)) {
+        $zip = "$InstallDir/nssm.zip"
+        Download "https://nssm.cc/release/nssm-2.24.zip" $zip
+        Expand-Archive -Force -Path $zip -DestinationPath "$InstallDir/nssm"
+        Copy-Item "$InstallDir/nssm/nssm-2.24/win64/nssm.exe" $nssm
+        Remove-Item -Recurse -Force "$InstallDir/nssm", $zip
+    }
+    & $nssm install kubelet "$PSHOME/powershell.exe" "-ExecutionPolicy Bypass -NoProfile $InstallDir/StartKubelet.ps1"
+    & $nssm set kubelet DependOnService containerd
+    & $nssm set kubelet Start SERVICE_AUTO_START
+}
+
+#
+# firewall
+#
+if (-not (Get-NetFirewallRule -Name kubelet -ErrorAction SilentlyContinue)) {
+    Log "Opening the kubelet port in the firewall"
+    New-NetFirewallRule -Name kubelet -DisplayName "kubelet" -Enabled True -Direction Inbound -Protocol TCP -Action Allow -LocalPort 10250 | Out-Null
+}
+
+Log "kubeadm installed successfully"
+`
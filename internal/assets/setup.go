@@ -0,0 +1,130 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// SetupDistro is a family of distros that are installed the same way
+// by the kubeadm setup script
+type SetupDistro string
+
+const (
+	// SetupDistroAuto is used when the distro must be detected in the node
+	SetupDistroAuto = SetupDistro("auto")
+
+	// SetupDistroApt is used for Debian, Ubuntu and derivatives
+	SetupDistroApt = SetupDistro("apt")
+
+	// SetupDistroYum is used for RedHat, CentOS, Fedora and derivatives
+	SetupDistroYum = SetupDistro("yum")
+
+	// SetupDistroAmazon is used for Amazon Linux 2 and Amazon Linux 2023
+	SetupDistroAmazon = SetupDistro("amzn")
+
+	// SetupDistroZypper is used for openSUSE and SLES
+	SetupDistroZypper = SetupDistro("zypper")
+
+	// SetupDistroBinaries is used for installing the release binaries (ie, in Flatcar)
+	SetupDistroBinaries = SetupDistro("binaries")
+
+	// SetupDistroOffline is used for air-gapped installations
+	SetupDistroOffline = SetupDistro("offline")
+)
+
+// setupDistroUnits are the templates (in the order they must be rendered) that
+// are needed for installing each distro
+var setupDistroUnits = map[SetupDistro][]string{
+	SetupDistroAuto:     {"zypper", "yum", "amzn", "apt", "binaries", "offline", "detect"},
+	SetupDistroApt:      {"apt"},
+	SetupDistroYum:      {"yum"},
+	SetupDistroAmazon:   {"yum", "amzn"},
+	SetupDistroZypper:   {"zypper"},
+	SetupDistroBinaries: {"binaries"},
+	SetupDistroOffline:  {"offline"},
+}
+
+// SetupRepo is the packages repositories configuration for the setup script
+type SetupRepo struct {
+	// URL is a mirror of the Kubernetes repository
+	URL string
+
+	// GPGKey is the URL of the GPG key of the repository
+	GPGKey string
+
+	// GPGFingerprint is the fingerprint the GPG key must have
+	GPGFingerprint string
+
+	// SkipGPGCheck disables the signatures checks
+	SkipGPGCheck bool
+
+	// CRIOURL is a mirror of the CRI-O repository
+	CRIOURL string
+
+	// DockerURL is the Docker CE repository file
+	DockerURL string
+}
+
+// SetupParams are the parameters for rendering the setup script for a node
+type SetupParams struct {
+	// Distro is the distro in the node
+	Distro SetupDistro
+
+	// Release is the release of the distro (ie, "2023" for Amazon Linux)
+	Release string
+
+	// KubeVersion is the Kubernetes version to install
+	KubeVersion string
+
+	// Runtime is the container runtime to install
+	Runtime string
+
+	// Proxy is the proxy environment (ie, HTTP_PROXY) for downloading packages
+	Proxy map[string]string
+
+	// Repo is the packages repositories configuration
+	Repo SetupRepo
+}
+
+var setupTemplates = template.Must(template.New("setup").Funcs(template.FuncMap{
+	"quote": ssh.ShellQuote,
+}).Parse(KubeadmSetupTemplates))
+
+// RenderSetupScript renders the kubeadm setup script for a node, including
+// only the installation code for its distro
+func RenderSetupScript(params SetupParams) ([]byte, error) {
+	if len(params.Distro) == 0 {
+		params.Distro = SetupDistroAuto
+	}
+	units, ok := setupDistroUnits[params.Distro]
+	if !ok {
+		return nil, fmt.Errorf("unsupported distro %q", params.Distro)
+	}
+
+	units = append(append([]string{"header", "common"}, units...), "main")
+
+	var buf bytes.Buffer
+	for _, unit := range units {
+		if err := setupTemplates.ExecuteTemplate(&buf, unit, params); err != nil {
+			return nil, fmt.Errorf("could not render the %q setup unit: %s", unit, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
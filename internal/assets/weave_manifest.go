@@ -150,7 +150,11 @@ items:
                     fieldRef:
                       apiVersion: v1
                       fieldPath: spec.nodeName
-              image: 'docker.io/weaveworks/weave-kube:2.5.2'
+{{- with .cni_mtu}}
+                - name: WEAVE_MTU
+                  value: '{{.}}'
+{{- end}}
+              image: 'docker.io/weaveworks/weave-kube:{{.cni_version}}'
               readinessProbe:
                 httpGet:
                   host: 127.0.0.1
@@ -183,7 +187,7 @@ items:
                     fieldRef:
                       apiVersion: v1
                       fieldPath: spec.nodeName
-              image: 'docker.io/weaveworks/weave-npc:2.5.2'
+              image: 'docker.io/weaveworks/weave-npc:{{.cni_version}}'
               resources:
                 requests:
                   cpu: 10m
@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"strings"
 	"time"
 
@@ -92,6 +91,11 @@ func applyList(ctx context.Context, actions ActionList, ignoreErrors bool) Actio
 	// use a queue where we take and put things in the front
 	// note that we operate on a copy of the original actions list
 	for len(actions) > 0 {
+		// stop as soon as the context is cancelled (or the deadline is exceeded)
+		if err := ctx.Err(); err != nil {
+			return ActionError(fmt.Sprintf("cancelled: %s", err))
+		}
+
 		if !ignoreErrors {
 			// if some error is in the queue, just quit with that error
 			for _, action := range actions {
@@ -159,9 +163,9 @@ func DoNothing() Action {
 	})
 }
 
-// Debug prints a debug message
+// Debug prints a debug message (with the default logger)
 func Debug(format string, args ...interface{}) {
-	log.Printf("[DEBUG] [KUBEADM] "+format, args...)
+	defLogger.Debug(format, args...)
 }
 
 // DoMessageRaw prints a raw message
@@ -178,26 +182,39 @@ func DoMessageWithColor(msg string, c color.Color) Action {
 	return DoMessageRaw(commonMsgPrefix + c.Render(msg))
 }
 
+// doMessageWithLevel prints a message with some color, logging it too
+func doMessageWithLevel(level LogLevel, msg string, c color.Color) Action {
+	return ActionList{
+		DoLog(level, "%s", msg),
+		DoMessageWithColor(msg, c),
+	}
+}
+
 // DoMessage is a dummy action that just prints a message
 func DoMessage(format string, args ...interface{}) Action {
-	return DoMessageWithColor(fmt.Sprintf(format, args...), color.FgLightGreen)
+	return doMessageWithLevel(LogLevelInfo, fmt.Sprintf(format, args...), color.FgLightGreen)
 }
 
 // DoMessageWarn prints a warning message
 func DoMessageWarn(format string, args ...interface{}) Action {
 	msg := fmt.Sprintf("WARNING: "+format, args...)
-	return DoMessageWithColor(msg, color.FgRed)
+	return doMessageWithLevel(LogLevelWarn, msg, color.FgRed)
 }
 
 // DoMessageInfo prints an info message
 func DoMessageInfo(format string, args ...interface{}) Action {
-	return DoMessageWithColor(fmt.Sprintf(format, args...), color.FgGreen)
+	return doMessageWithLevel(LogLevelInfo, fmt.Sprintf(format, args...), color.FgGreen)
 }
 
 // DoMessageDebug prints a debug message
 func DoMessageDebug(format string, args ...interface{}) Action {
+	return DoLog(LogLevelDebug, format, args...)
+}
+
+// DoLog logs a message with the logger in the context
+func DoLog(level LogLevel, format string, args ...interface{}) Action {
 	return ActionFunc(func(ctx context.Context) Action {
-		Debug(format, args...)
+		GetLoggerFromContext(ctx).Log(level, format, args...)
 		return nil
 	})
 }
@@ -207,6 +224,7 @@ func DoAbort(format string, args ...interface{}) Action {
 	msg := fmt.Sprintf("FATAL: "+format, args...)
 	coloredMsg := color.Style{color.FgRed, color.OpBold}.Render(msg)
 	return ActionList{
+		DoLog(LogLevelError, format, args...),
 		DoMessageRaw(coloredMsg),
 		ActionError(fmt.Sprintf(format, args...)),
 	}
@@ -228,24 +246,29 @@ func (f CheckerFunc) Check(ctx context.Context) (bool, error) {
 }
 
 // DoWithCleanup runs some action(s) and
-// 1) despite the result, runs the cleanup function
+// 1) despite the result, runs the cleanup function (even if the context is done)
 // 2) returns the actions result
 func DoWithCleanup(actions Action, cleanup Action) Action {
 	return ActionFunc(func(ctx context.Context) Action {
 		res := ActionList{actions}.Apply(ctx)
-		_ = ActionList{cleanup}.Apply(ctx)
+
+		cleanupCtx, cancel := getCleanupContext(ctx)
+		defer cancel()
+		_ = ActionList{cleanup}.Apply(cleanupCtx)
 		return res
 	})
 }
 
 // DoWithException runs some action and
-// 1) if some error happens, runs the exception handler
+// 1) if some error happens, runs the exception handler (even if the context is done)
 // 2) returns the error
 func DoWithException(actions Action, exc Action) Action {
 	return ActionFunc(func(ctx context.Context) Action {
 		res := ActionList{actions}.Apply(ctx)
 		if IsError(res) {
-			_ = ActionList{exc}.Apply(ctx)
+			excCtx, cancel := getCleanupContext(ctx)
+			defer cancel()
+			_ = ActionList{exc}.Apply(excCtx)
 		}
 		return res
 	})
@@ -318,27 +341,50 @@ type Retry struct {
 
 	// Interval is the time between trials
 	Interval time.Duration
+
+	// Backoff is the factor the Interval is multiplied by after every trial
+	// (the Interval is constant when <= 1)
+	Backoff float64
+
+	// Cleanup is an (optional) action run after a failed trial, before retrying
+	Cleanup Action
 }
 
 // DoRetry runs an action `n` times until it succeedes
 func DoRetry(run Retry, actions ...Action) ActionFunc {
-	interval := 1 * time.Second
-	if run.Interval > 0 {
-		interval = run.Interval
-	}
-
 	return ActionFunc(func(ctx context.Context) Action {
+		interval := 1 * time.Second
+		if run.Interval > 0 {
+			interval = run.Interval
+		}
+
 		count := run.Times
 		var res Action
 		for count > 0 {
 			res = ActionList(actions).Apply(ctx)
-			if IsError(res) {
-				_ = DoMessageWarn("failed... retrying in %d seconds...", interval/time.Second).Apply(ctx)
-				time.Sleep(interval)
-				count--
-			} else {
+			if !IsError(res) {
 				return res
 			}
+
+			count--
+			if count == 0 {
+				break
+			}
+
+			if run.Cleanup != nil {
+				_ = DoMessageWarn("failed... cleaning up before retrying").Apply(ctx)
+				_ = ActionList{run.Cleanup}.Apply(ctx)
+			}
+
+			_ = DoMessageWarn("failed... retrying in %s (%d attempts left)...", interval, count).Apply(ctx)
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return ActionError(fmt.Sprintf("cancelled: %s", ctx.Err()))
+			}
+			if run.Backoff > 1 {
+				interval = time.Duration(float64(interval) * run.Backoff)
+			}
 		}
 		return res
 	})
@@ -350,8 +396,7 @@ func DoRetry(run Retry, actions ...Action) ActionFunc {
 // * make sure you strip spaces in the output, as some extra spaces can be before/after
 func DoSendingExecOutputToFunc(action Action, interceptor OutputFunc) Action {
 	return ActionFunc(func(ctx context.Context) Action {
-		newCtx := WithValues(ctx, GetUserOutputFromContext(ctx), interceptor, GetCommFromContext(ctx), GetUseSudoFromContext(ctx))
-		return ActionList{action}.Apply(newCtx)
+		return ActionList{action}.Apply(withExecOutput(ctx, interceptor))
 	})
 }
 
@@ -430,6 +430,39 @@ func TestDoRetry(t *testing.T) {
 	}
 }
 
+func TestDoRetryWithCleanup(t *testing.T) {
+	count, cleanups := 0, 0
+	start := time.Now()
+	res := DoRetry(
+		Retry{
+			Times:    3,
+			Interval: 50 * time.Millisecond,
+			Backoff:  2,
+			Cleanup: ActionFunc(func(context.Context) Action {
+				cleanups++
+				return nil
+			}),
+		},
+		ActionFunc(func(context.Context) Action {
+			count++
+			if count < 3 {
+				return ActionError("an error")
+			}
+			return nil
+		}),
+	).Apply(NewTestingContext())
+	if IsError(res) {
+		t.Fatalf("Error: error detected: %s", res)
+	}
+	if count != 3 || cleanups != 2 {
+		t.Fatalf("Error: unexpected number of retries/cleanups: %d/%d", count, cleanups)
+	}
+	// 50ms + 100ms between the trials
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("Error: no backoff between retries: %s", elapsed)
+	}
+}
+
 func doEcho(msg string) Action {
 	return DoLocalExec("/bin/echo", msg)
 }
@@ -49,7 +49,7 @@ func getFromCacheInContext(ctx context.Context, key string) (interface{}, bool)
 	}
 	c := getCacheFromContext(ctx)
 	value, ok := c[key]
-	Debug("[CACHE] getting %q [found:%t] = %v ", key, ok, value)
+	GetLoggerFromContext(ctx).With("component", "cache").Trace("getting %q [found:%t] = %v ", key, ok, value)
 	return value, ok
 }
 
@@ -59,7 +59,7 @@ func setInCacheInContext(ctx context.Context, key string, value interface{}) {
 		return
 	}
 	c := getCacheFromContext(ctx)
-	Debug("[CACHE] setting %q = %v", key, value)
+	GetLoggerFromContext(ctx).With("component", "cache").Trace("setting %q = %v", key, value)
 	c[key] = value
 }
 
@@ -69,7 +69,7 @@ func delInCacheInContext(ctx context.Context, key string) {
 		return
 	}
 	c := getCacheFromContext(ctx)
-	Debug("[CACHE] deleting %q", key)
+	GetLoggerFromContext(ctx).With("component", "cache").Trace("deleting %q", key)
 	delete(c, key)
 }
 
@@ -0,0 +1,95 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator"
+	"github.com/hashicorp/terraform/communicator/remote"
+)
+
+const (
+	// environment variable used for tagging the processes started by a remote command
+	execIDEnv = "KUBEADM_EXEC_ID"
+
+	// maximum time for killing the processes of a cancelled command
+	killTimeout = 30 * time.Second
+
+	// maximum time for running the cleanup handlers once the context is done
+	cleanupTimeout = 2 * time.Minute
+)
+
+// detachedContext is a context that keeps the values of its parent
+// but that is never cancelled (nor has a deadline)
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// getCleanupContext returns a context for running the cleanup handlers, with the
+// values in `ctx` but not cancelled when `ctx` is (ie, on a Ctrl-C or when the
+// deadline is exceeded), so the leftovers are always removed. It is bounded by
+// the `cleanupTimeout`.
+func getCleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, cleanupTimeout)
+}
+
+// newExecID returns a new random ID for tagging a remote command
+func newExecID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// getTaggedCommand returns a command that runs `command` in a shell where all
+// the processes are tagged with the `execID`, so they can be found (and killed)
+// later on
+func getTaggedCommand(command string, execID string) string {
+	return fmt.Sprintf("env %s=%s sh -c %s", execIDEnv, execID, ShellQuote(command))
+}
+
+// getKillCommand returns a command that kills all the processes tagged with `execID`
+func getKillCommand(execID string) string {
+	script := fmt.Sprintf(`for f in /proc/[0-9]*/environ ; do `+
+		`if tr '\0' '\n' < "$f" 2>/dev/null | grep -qx '%s=%s' ; then `+
+		`p=${f#/proc/} ; kill -TERM ${p%%/environ} 2>/dev/null ; fi ; done ; true`, execIDEnv, execID)
+	return "sh -c " + ShellQuote(script)
+}
+
+// killRemoteCommand kills all the processes started by a (cancelled) remote command.
+// Note well: the context of the command is already done, so we do not use it here.
+func killRemoteCommand(comm communicator.Communicator, execID string, useSudo bool) error {
+	command := getKillCommand(execID)
+	if useSudo {
+		command = "sudo " + sudoArgs + " " + command
+	}
+
+	cmd := &remote.Cmd{Command: command}
+	if err := comm.Start(cmd); err != nil {
+		return err
+	}
+	return waitWithTimeout(context.Background(), waitCmd(cmd), killTimeout)
+}
@@ -0,0 +1,164 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+)
+
+// shellCommunicator runs the commands in the local shell
+type shellCommunicator struct {
+	DummyCommunicator
+}
+
+func (shellCommunicator) Start(rc *remote.Cmd) error {
+	rc.Init()
+	cmd := exec.Command("sh", "-c", rc.Command)
+	cmd.Stdout = rc.Stdout
+	cmd.Stderr = rc.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if exitErr, ok := cmd.Wait().(*exec.ExitError); ok {
+			rc.SetExitStatus(exitErr.ExitCode(), nil)
+			return
+		}
+		rc.SetExitStatus(0, nil)
+	}()
+	return nil
+}
+
+// isProcessRunning returns true if there is a process with `cmdline`
+func isProcessRunning(cmdline string) bool {
+	files, _ := filepath.Glob("/proc/[0-9]*/cmdline")
+	for _, f := range files {
+		contents, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if strings.Replace(string(contents), "\x00", " ", -1) == cmdline+" " {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDoExecKillOnCancel(t *testing.T) {
+	if _, err := os.Stat("/proc/self/environ"); err != nil {
+		t.Skip("no /proc filesystem available")
+	}
+
+	ctx, cancel := context.WithCancel(NewTestingContextWithCommunicator(shellCommunicator{}))
+	SetKillProcessesInContext(ctx, true)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+	}()
+
+	res := DoExec("sleep 31337 ; true").Apply(ctx)
+	if !IsError(res) || !strings.Contains(res.Error(), "aborted") {
+		t.Fatalf("Error: the command was not aborted: %v", res)
+	}
+
+	for i := 0; i < 20 && isProcessRunning("sleep 31337"); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if isProcessRunning("sleep 31337") {
+		t.Fatalf("Error: the remote process was not killed")
+	}
+}
+
+func TestDoExecTimeoutNoLeaks(t *testing.T) {
+	if _, err := os.Stat("/proc/self/environ"); err != nil {
+		t.Skip("no /proc filesystem available")
+	}
+
+	ctx := NewTestingContextWithCommunicator(shellCommunicator{})
+	SetKillProcessesInContext(ctx, true)
+	SetExecTimeoutInContext(ctx, 500*time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	res := DoExec("sleep 31338 ; true").Apply(ctx)
+	if !IsError(res) || !strings.Contains(res.Error(), "did not finish") {
+		t.Fatalf("Error: the command did not time out: %v", res)
+	}
+
+	// the waiter and the goroutines copying the output must be gone
+	for i := 0; i < 20 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("Error: goroutines leaked after the timeout: %d before, %d after", before, after)
+	}
+}
+
+func TestApplyListCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(NewTestingContext())
+	cancel()
+
+	executed := false
+	res := ActionList{
+		ActionFunc(func(context.Context) Action {
+			executed = true
+			return nil
+		}),
+	}.Apply(ctx)
+	if !IsError(res) || executed {
+		t.Fatalf("Error: actions were run in a cancelled context: %v", res)
+	}
+}
+
+func TestCleanupAfterCancel(t *testing.T) {
+	commands := []string{}
+	ctx, cancel := context.WithCancel(NewTestingContextWithCommunicator(recordingCommunicator{commands: &commands}))
+	defer cancel()
+
+	// the action is cancelled, but the temporary file must be removed anyway
+	res := DoWithCleanup(
+		ActionFunc(func(context.Context) Action {
+			cancel()
+			return ActionError("cancelled")
+		}),
+		DoDeleteFile("/tmp/kubeadm-init.conf")).Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: an error was expected but we got %v", res)
+	}
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "rm -f") {
+		t.Fatalf("Error: the cleanup has not been run after cancelling: %v", commands)
+	}
+
+	// ... and the same for the exception handlers
+	res = DoWithException(
+		ActionError("failed"),
+		DoExec("echo 'dumping logs'")).Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: an error was expected but we got %v", res)
+	}
+	if len(commands) != 2 || commands[1] != "echo 'dumping logs'" {
+		t.Fatalf("Error: the exception handler has not been run after cancelling: %v", commands)
+	}
+}
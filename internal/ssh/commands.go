@@ -17,12 +17,16 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/armon/circbuf"
 	"github.com/hashicorp/terraform/communicator/remote"
@@ -40,6 +44,71 @@ const (
 	maxBufSize = 8 * 1024
 )
 
+var (
+	errExecTimeout = errors.New("timeout when running remote command")
+)
+
+// number of lines of output included in the error of a failed command
+const errorOutputLines = 20
+
+// outputTail keeps the last lines of the output of a command
+type outputTail struct {
+	sync.Mutex
+	lines []string
+	max   int
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{max: max}
+}
+
+// Add adds a line to the tail, discarding the oldest line when it is full
+func (t *outputTail) Add(line string) {
+	t.Lock()
+	defer t.Unlock()
+	t.lines = append(t.lines, strings.TrimRight(line, "\r\n"))
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+// String returns the lines in the tail, indented
+func (t *outputTail) String() string {
+	t.Lock()
+	defer t.Unlock()
+	res := make([]string, 0, len(t.lines))
+	for _, line := range t.lines {
+		res = append(res, "  | "+line)
+	}
+	return strings.Join(res, "\n")
+}
+
+// DoAppendingOutputToError runs an action and, when it fails, runs `extra` and
+// appends its output to the error message (ie, for attaching some logs to the error)
+func DoAppendingOutputToError(action Action, title string, extra Action) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		res := ActionList{action}.Apply(ctx)
+		if !IsError(res) {
+			return res
+		}
+
+		tail := newOutputTail(errorOutputLines)
+		_ = DoSendingExecOutputToFunc(extra, func(s string) {
+			for _, line := range strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n") {
+				if len(strings.TrimSpace(line)) > 0 {
+					tail.Add(line)
+				}
+			}
+		}).Apply(ctx)
+
+		lines := tail.String()
+		if len(lines) == 0 {
+			return res
+		}
+		return ActionError(Redact(fmt.Sprintf("%s\n%s:\n%s", res.Error(), title, lines)))
+	})
+}
+
 func copyOutput(output terraform.UIOutput, input io.Reader, done chan<- struct{}) {
 	defer close(done)
 	lr := linereader.New(input)
@@ -48,6 +117,38 @@ func copyOutput(output terraform.UIOutput, input io.Reader, done chan<- struct{}
 	}
 }
 
+// waitCmd waits for a remote command in the background, sending the result to
+// the (buffered) channel returned. The waiter exits when the session is finished,
+// even if nobody reads the result.
+func waitCmd(cmd *remote.Cmd) <-chan error {
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+	return waitCh
+}
+
+// waitWithTimeout waits for the result of a remote command, returning errExecTimeout if
+// it takes longer than `timeout` (or waiting forever when `timeout` is 0), or the context
+// error when the context is done before (ie, when the deadline is exceeded)
+func waitWithTimeout(ctx context.Context, waitCh <-chan error, timeout time.Duration) error {
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case res := <-waitCh:
+		return res
+	case <-timeoutCh:
+		return errExecTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // DoExec is a runner for remote Commands
 func DoExec(command string) Action {
 	return ActionFunc(func(ctx context.Context) (res Action) {
@@ -58,36 +159,103 @@ func DoExec(command string) Action {
 		execOutput := GetExecOutputFromContext(ctx)
 		comm := GetCommFromContext(ctx)
 
+		// tag the processes started, so we can kill them if the context is cancelled
+		// or the command does not finish in time (only in POSIX shells)
+		timeout := GetExecTimeoutFromContext(ctx)
+		remoteCommand := command
+		execID := ""
+		if GetKillProcessesFromContext(ctx) {
+			execID = newExecID()
+			remoteCommand = getTaggedCommand(command, execID)
+		}
+
 		if GetUseSudoFromContext(ctx) {
 			command = "sudo " + sudoArgs + " " + command
+			remoteCommand = "sudo " + sudoArgs + " " + remoteCommand
 		}
 
-		Debug("running %q", command)
+		logger := GetLoggerFromContext(ctx)
+		logger.Debug("running %q", command)
+		logSession(ctx, "$ %s", command)
+
+		// the output is sent to the exec output as well as to the session log,
+		// and we keep the last lines for the error message
+		tail := newOutputTail(errorOutputLines)
+		stdoutOutput := OutputFunc(func(s string) {
+			execOutput.Output(s)
+			logSession(ctx, "> %s", s)
+			tail.Add(s)
+		})
+		stderrOutput := OutputFunc(func(s string) {
+			execOutput.Output(s)
+			logSession(ctx, "! %s", s)
+			tail.Add(s)
+		})
 
 		outR, outW := io.Pipe()
 		errR, errW := io.Pipe()
 		outDoneCh := make(chan struct{})
 		errDoneCh := make(chan struct{})
 
-		go copyOutput(execOutput, outR, outDoneCh)
-		go copyOutput(execOutput, errR, errDoneCh)
+		go copyOutput(stdoutOutput, outR, outDoneCh)
+		go copyOutput(stderrOutput, errR, errDoneCh)
 
 		cmd := &remote.Cmd{
-			Command: command,
+			Command: remoteCommand,
 			Stdout:  outW,
 			Stderr:  errW,
 		}
 
 		if err := comm.Start(cmd); err != nil {
-			return ActionError(fmt.Sprintf("Error executing command %q: %v", cmd.Command, err))
+			_ = outW.Close()
+			_ = errW.Close()
+			logSession(ctx, "# error: %v", err)
+			return ActionError(Redact(fmt.Sprintf("Error executing command %q: %v", command, err)))
 		}
-		waitResult := cmd.Wait()
+
+		waitCh := waitCmd(cmd)
+
+		// stop copying the output and kill the processes started by the command, so
+		// the session is closed (and we do not leave anything running in the node).
+		// Then the waiter exits, or, when the processes cannot be killed, it will
+		// exit when the connection is closed.
+		abortCommand := func() {
+			_ = outW.Close()
+			_ = errW.Close()
+			<-outDoneCh
+			<-errDoneCh
+
+			if execID == "" {
+				return
+			}
+			logger.Info("killing the processes started by %q", command)
+			if err := killRemoteCommand(comm, execID, GetUseSudoFromContext(ctx)); err != nil {
+				logger.Warn("could not kill the processes started by %q: %s", command, err)
+				return
+			}
+			select {
+			case <-waitCh:
+			case <-time.After(killTimeout):
+				logger.Warn("the session for %q has not finished after killing its processes", command)
+			}
+		}
+
+		waitResult := waitWithTimeout(ctx, waitCh, timeout)
+		switch waitResult {
+		case errExecTimeout:
+			logSession(ctx, "# timeout after %s", timeout)
+			abortCommand()
+			return ActionError(Redact(fmt.Sprintf("Command %q did not finish after %s", command, timeout)))
+		case context.DeadlineExceeded, context.Canceled:
+			logSession(ctx, "# aborted: %s", waitResult)
+			abortCommand()
+			return ActionError(Redact(fmt.Sprintf("Command %q aborted: %s", command, waitResult)))
+		}
+
+		exitStatus := 0
 		if waitResult != nil {
-			cmdError, _ := waitResult.(*remote.ExitError)
-			if cmdError.ExitStatus != 0 {
-				msg := fmt.Sprintf("Command %q exited with non-zero exit status: %d", cmdError.Command, cmdError.ExitStatus)
-				Debug(msg)
-				res = ActionError(msg)
+			if cmdError, ok := waitResult.(*remote.ExitError); ok && cmdError.ExitStatus != 0 {
+				exitStatus = cmdError.ExitStatus
 			}
 			// otherwise, it is a communicator error
 		}
@@ -103,6 +271,17 @@ func DoExec(command string) Action {
 		case <-ctx.Done():
 		}
 
+		if exitStatus != 0 {
+			msg := fmt.Sprintf("Command %q exited with non-zero exit status: %d", command, exitStatus)
+			if lines := tail.String(); len(lines) > 0 {
+				msg += "\nLast lines of output:\n" + lines
+			}
+			msg = Redact(msg)
+			logger.With("exit_status", exitStatus).Debug("%s", msg)
+			res = ActionError(msg)
+		}
+
+		logSession(ctx, "# exit code: %d", exitStatus)
 		return
 	})
 }
@@ -123,6 +302,40 @@ func DoExecScript(contents []byte) Action {
 		})
 }
 
+// DoExecScriptWithEnv is a runner for a script, exporting some environment
+// variables at the beginning of the script
+func DoExecScriptWithEnv(contents []byte, env map[string]string) Action {
+	return DoExecScript(addEnvToScript(contents, env))
+}
+
+// addEnvToScript adds some "export VAR=value" lines after the shebang (if present)
+func addEnvToScript(contents []byte, env map[string]string) []byte {
+	if len(env) == 0 {
+		return contents
+	}
+
+	keys := []string{}
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	exports := bytes.Buffer{}
+	for _, k := range keys {
+		exports.WriteString(fmt.Sprintf("export %s=%s\n", k, ShellQuote(env[k])))
+	}
+
+	script := string(contents)
+	if strings.HasPrefix(script, "#!") {
+		nl := strings.Index(script, "\n")
+		if nl < 0 {
+			return []byte(script + "\n" + exports.String())
+		}
+		return []byte(script[:nl+1] + exports.String() + script[nl+1:])
+	}
+	return []byte(exports.String() + script)
+}
+
 // DoLocalExec executes a local command
 func DoLocalExec(command string, args ...string) Action {
 	return ActionFunc(func(ctx context.Context) Action {
@@ -130,7 +343,7 @@ func DoLocalExec(command string, args ...string) Action {
 		execOutput := GetExecOutputFromContext(ctx)
 
 		fullCmd := fmt.Sprintf("%s %s", command, strings.Join(args, " "))
-		userOutput.Output(fmt.Sprintf("Running local command %q...", fullCmd))
+		userOutput.Output(Redact(fmt.Sprintf("Running local command %q...", fullCmd)))
 
 		// Setup the reader that will read the output from the command.
 		// We use an os.Pipe so that the *os.File can be passed directly to the
@@ -201,22 +414,22 @@ func CheckExec(cmd string) CheckerFunc {
 	command := fmt.Sprintf("%s && echo '%s' || echo '%s'", cmd, success, failure)
 
 	return CheckerFunc(func(ctx context.Context) (bool, error) {
-		Debug("Checking condition: '%s'", cmd)
+		GetLoggerFromContext(ctx).Debug("Checking condition: '%s'", cmd)
 		var buf bytes.Buffer
 		if res := DoSendingExecOutputToWriter(DoExec(command), &buf).Apply(ctx); IsError(res) {
-			Debug("ERROR: when performing check %q: %s", cmd, res)
+			GetLoggerFromContext(ctx).Warn("when performing check %q: %s", cmd, res)
 			return false, res
 		}
 
 		// check _only_ the `success` appears, as some other error/log message about
 		// the command can contain both...
 		s := buf.String()
-		Debug("check: output: %q", s)
+		GetLoggerFromContext(ctx).Debug("check: output: %q", s)
 		if strings.Contains(s, success) && !strings.Contains(s, failure) {
-			Debug("check: %q succeeded (%q found in output)", cmd, success)
+			GetLoggerFromContext(ctx).Debug("check: %q succeeded (%q found in output)", cmd, success)
 			return true, nil
 		}
-		Debug("check: %q failed", cmd)
+		GetLoggerFromContext(ctx).Debug("check: %q failed", cmd)
 		return false, nil
 	})
 }
@@ -227,36 +440,36 @@ func CheckBinaryExists(cmd string) CheckerFunc {
 	command := fmt.Sprintf("sh -c \"command -v '%s'\"", cmd)
 
 	return CheckerFunc(func(ctx context.Context) (bool, error) {
-		Debug("Checking binary exists with: '%s'", cmd)
+		GetLoggerFromContext(ctx).Debug("Checking binary exists with: '%s'", cmd)
 		var buf bytes.Buffer
 		if res := DoSendingExecOutputToWriter(DoExec(command), &buf).Apply(ctx); IsError(res) {
-			Debug("ERROR: when performing check: %s", res)
+			GetLoggerFromContext(ctx).Warn("when performing check %q: %s", cmd, res)
 			return false, res
 		}
 
 		// if "command -v" doesn't print anything, it was not found
 		s := strings.TrimSpace(buf.String())
 		if s == "" {
-			Debug("%q NOT found: empty output: output == %q", cmd, s)
+			GetLoggerFromContext(ctx).Debug("%q NOT found: empty output: output == %q", cmd, s)
 			return false, nil
 		}
 
 		// sometimes it just returns the file name provided
 		if s == cmd {
-			Debug("%q found: output == %q", cmd, s)
+			GetLoggerFromContext(ctx).Debug("%q found: output == %q", cmd, s)
 			return true, nil
 		}
 
 		// if it prints the full path: check it is really there
 		if path.IsAbs(s) {
-			Debug("checking file %q exists at %q", cmd, s)
+			GetLoggerFromContext(ctx).Debug("checking file %q exists at %q", cmd, s)
 			return CheckOnce(
 				fmt.Sprintf("path-command-%s", s),
 				CheckFileExists(s)).Check(ctx)
 		}
 
 		// otherwise, just fail
-		Debug("%q NOT found: output == %q", cmd, s)
+		GetLoggerFromContext(ctx).Debug("%q NOT found: output == %q", cmd, s)
 		return false, nil
 	})
 }
@@ -15,7 +15,15 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator/remote"
 )
 
 func TestCheckBinaryExists(t *testing.T) {
@@ -33,3 +41,154 @@ func TestCheckBinaryExists(t *testing.T) {
 		t.Fatalf("Error: unexpected result for exists: %t", exists)
 	}
 }
+
+func TestDoExecTimeout(t *testing.T) {
+	// the DummyCommunicator never finishes the commands, so we should get a timeout
+	ctx := NewTestingContext()
+	SetExecTimeoutInContext(ctx, 100*time.Millisecond)
+
+	res := DoExec("sleep 1000").Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: a timeout was expected but we got %v", res)
+	}
+}
+
+// recordingCommunicator records the commands started, finishing them immediately
+type recordingCommunicator struct {
+	DummyCommunicator
+
+	commands *[]string
+}
+
+func (rc recordingCommunicator) Start(cmd *remote.Cmd) error {
+	cmd.Init()
+	*rc.commands = append(*rc.commands, cmd.Command)
+	cmd.SetExitStatus(0, nil)
+	return nil
+}
+
+func TestDoExecTimeoutTagging(t *testing.T) {
+	commands := []string{}
+	ctx := NewTestingContextWithCommunicator(recordingCommunicator{commands: &commands})
+	SetExecTimeoutInContext(ctx, 1*time.Minute)
+
+	// in Windows nodes the processes cannot be killed, and the commands must be run untouched
+	SetKillProcessesInContext(ctx, false)
+	if res := DoExec("Get-Service kubelet").Apply(ctx); IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if len(commands) != 1 || commands[0] != "Get-Service kubelet" {
+		t.Fatalf("Error: the command has been modified: %v", commands)
+	}
+
+	// in POSIX shells, the processes are tagged (so they can be killed on timeouts)
+	SetKillProcessesInContext(ctx, true)
+	if res := DoExec("kubeadm init").Apply(ctx); IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if len(commands) != 2 || !strings.HasPrefix(commands[1], "env "+execIDEnv+"=") {
+		t.Fatalf("Error: the command has not been tagged: %v", commands)
+	}
+}
+
+// failingCommunicator cannot start any command
+type failingCommunicator struct {
+	DummyCommunicator
+}
+
+func (failingCommunicator) Start(*remote.Cmd) error {
+	return errors.New("connection lost")
+}
+
+func TestDoExecStartErrorNoLeaks(t *testing.T) {
+	ctx := NewTestingContextWithCommunicator(failingCommunicator{})
+
+	before := runtime.NumGoroutine()
+	if res := DoExec("kubeadm init").Apply(ctx); !IsError(res) {
+		t.Fatalf("Error: an error was expected but we got %v", res)
+	}
+
+	// the goroutines copying the output must be gone
+	for i := 0; i < 20 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("Error: goroutines leaked after the error: %d before, %d after", before, after)
+	}
+}
+
+func TestDoExecDeadline(t *testing.T) {
+	// the DummyCommunicator never finishes the commands, so the deadline should be exceeded
+	ctx, cancel := context.WithTimeout(NewTestingContext(), 100*time.Millisecond)
+	defer cancel()
+
+	res := DoExec("kubeadm init").Apply(ctx)
+	if !IsError(res) || !strings.Contains(res.Error(), "deadline exceeded") {
+		t.Fatalf("Error: a deadline error was expected but we got %v", res)
+	}
+}
+
+func TestDoExecSessionLog(t *testing.T) {
+	responses := []string{
+		"some output",
+	}
+
+	ctx := NewTestingContextWithResponses(responses)
+	sessionLog := bytes.Buffer{}
+	SetSessionLogInContext(ctx, &sessionLog)
+
+	res := DoExec("ls /").Apply(ctx)
+	if IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+
+	for _, expected := range []string{"$ ls /", "> some output", "# exit code: 0"} {
+		if !strings.Contains(sessionLog.String(), expected) {
+			t.Fatalf("Error: %q not found in the session log:\n%s", expected, sessionLog.String())
+		}
+	}
+}
+
+func TestDoExecErrorOutput(t *testing.T) {
+	ctx := NewTestingContextWithCommunicator(shellCommunicator{})
+
+	res := DoExec("for i in $(seq 1 30) ; do echo line-$i ; done ; exit 3").Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: an error was expected")
+	}
+	msg := res.Error()
+	for _, expected := range []string{"exit status: 3", "  | line-30", "  | line-11"} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("Error: %q not found in the error:\n%s", expected, msg)
+		}
+	}
+	if strings.Contains(msg, "line-10\n") {
+		t.Fatalf("Error: too many lines in the error:\n%s", msg)
+	}
+
+	res = DoAppendingOutputToError(
+		DoExec("false"),
+		"Some logs",
+		DoExec("echo some log line")).Apply(ctx)
+	if !IsError(res) || !strings.Contains(res.Error(), "Some logs:\n  | some log line") {
+		t.Fatalf("Error: logs not appended to the error: %v", res)
+	}
+}
+
+func TestAddEnvToScript(t *testing.T) {
+	env := map[string]string{
+		"RUNTIME": "containerd",
+		"QUOTED":  "it's",
+	}
+
+	res := string(addEnvToScript([]byte("#!/bin/sh\necho $RUNTIME\n"), env))
+	expected := "#!/bin/sh\nexport QUOTED='it'\"'\"'s'\nexport RUNTIME='containerd'\necho $RUNTIME\n"
+	if res != expected {
+		t.Fatalf("Error: unexpected script:\n%s", res)
+	}
+
+	res = string(addEnvToScript([]byte("echo $RUNTIME\n"), map[string]string{"RUNTIME": "docker"}))
+	if res != "export RUNTIME='docker'\necho $RUNTIME\n" {
+		t.Fatalf("Error: unexpected script:\n%s", res)
+	}
+}
@@ -16,6 +16,10 @@ package ssh
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform/communicator"
 )
@@ -48,6 +52,21 @@ type sshContext struct {
 	comm       communicator.Communicator
 	cache      cache
 	leftovers  []string
+
+	// maximum time for running a remote command (0 means "no limit")
+	execTimeout time.Duration
+
+	// the remote shell is a POSIX shell where the processes of a command can be
+	// tagged and killed (when the context is cancelled or the command times out)
+	killProcesses bool
+
+	// (optional) log where all the commands and their output are written
+	sessionLog     io.Writer
+	sessionLogLock sync.Mutex
+
+	// (optional) writer for the progress events, and the node they refer to
+	progress     *ProgressWriter
+	progressNode string
 }
 
 // WithValues creates a new "internal" SSH context
@@ -90,6 +109,68 @@ func GetCommFromContext(ctx context.Context) communicator.Communicator {
 	return getSSHContext(ctx).comm
 }
 
+// SetExecTimeoutInContext sets the maximum time for running remote commands
+func SetExecTimeoutInContext(ctx context.Context, timeout time.Duration) {
+	getSSHContext(ctx).execTimeout = timeout
+}
+
+// GetExecTimeoutFromContext gets the maximum time for running remote commands
+func GetExecTimeoutFromContext(ctx context.Context) time.Duration {
+	return getSSHContext(ctx).execTimeout
+}
+
+// SetKillProcessesInContext enables killing the processes started by a remote
+// command when the context is cancelled or the command times out. This must be
+// enabled only for POSIX shells, as the commands are wrapped in a `sh -c`.
+func SetKillProcessesInContext(ctx context.Context, kill bool) {
+	getSSHContext(ctx).killProcesses = kill
+}
+
+// GetKillProcessesFromContext returns true if remote processes must be killed
+// when the context is cancelled or the command times out
+func GetKillProcessesFromContext(ctx context.Context) bool {
+	return getSSHContext(ctx).killProcesses
+}
+
+// withExecOutput returns a new context where the exec output is sent to `execOutput`,
+// keeping all the other settings of the current context
+func withExecOutput(ctx context.Context, execOutput UIOutput) context.Context {
+	sshc := getSSHContext(ctx)
+	return context.WithValue(ctx, sshContextKey, &sshContext{
+		useSudo:       sshc.useSudo,
+		userOutput:    sshc.userOutput,
+		execOutput:    execOutput,
+		comm:          sshc.comm,
+		cache:         sshc.cache,
+		leftovers:     []string{},
+		execTimeout:   sshc.execTimeout,
+		killProcesses: sshc.killProcesses,
+		sessionLog:    sshc.sessionLog,
+		progress:      sshc.progress,
+		progressNode:  sshc.progressNode,
+	})
+}
+
+// SetSessionLogInContext sets a writer where all the remote commands
+// (and their output) will be logged
+func SetSessionLogInContext(ctx context.Context, w io.Writer) {
+	getSSHContext(ctx).sessionLog = w
+}
+
+// logSession writes a (timestamped) line in the session log, if there is one
+func logSession(ctx context.Context, format string, args ...interface{}) {
+	sshc := getSSHContext(ctx)
+	if sshc.sessionLog == nil {
+		return
+	}
+
+	sshc.sessionLogLock.Lock()
+	defer sshc.sessionLogLock.Unlock()
+
+	ts := time.Now().Format(time.RFC3339)
+	_, _ = fmt.Fprintf(sshc.sessionLog, "%s %s\n", ts, Redact(fmt.Sprintf(format, args...)))
+}
+
 // getCacheFromContext gets the cache from the current context
 func getCacheFromContext(ctx context.Context) cache {
 	return getSSHContext(ctx).cache
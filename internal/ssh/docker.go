@@ -49,7 +49,7 @@ func GetContainer(ctx context.Context, pattern string) (string, error) {
 	output = strings.ReplaceAll(output, "\n", "")
 	output = strings.TrimSpace(output)
 
-	Debug("GetContainer(%s) output: %q", pattern, output)
+	GetLoggerFromContext(ctx).Debug("GetContainer(%s) output: %q", pattern, output)
 	return output, nil
 }
 
@@ -64,7 +64,7 @@ func DoDockerExec(pattern string, command string) Action {
 		// build the full `docker exec` command to run
 		dockerCommand := fmt.Sprintf("docker exec -ti '%s' /bin/sh -c '%s'", cid, command)
 
-		Debug("Running command in container %q: '%s'", cid, dockerCommand)
+		GetLoggerFromContext(ctx).Debug("Running command in container %q: '%s'", cid, dockerCommand)
 		return DoExec(dockerCommand)
 	})
 }
@@ -113,9 +113,13 @@ func doRealUploadFile(contents []byte, dst string) Action {
 			c := bytes.NewReader(contents)
 			comm := GetCommFromContext(ctx)
 
-			Debug("Doing the real upload to %s:\n%s\n", dst, contents)
+			logger := GetLoggerFromContext(ctx)
+			logger.Debug("uploading %d bytes to %s", len(contents), dst)
+			logger.Trace("contents of %s:\n%s\n", dst, contents)
+			logSession(ctx, "# uploading %d bytes to %s", len(contents), dst)
 			if err := comm.Upload(dst, c); err != nil {
-				Debug("ERROR: upload failed: %s", err)
+				logger.Warn("upload to %s failed: %s", dst, err)
+				logSession(ctx, "# upload failed: %s", err)
 				return ActionError(err.Error())
 			}
 
@@ -126,6 +130,25 @@ func doRealUploadFile(contents []byte, dst string) Action {
 	return actions
 }
 
+// DoUploadBytesToFileDirect uploads a file directly to a remote path, without
+// creating directories, using temporary files or `sudo` (ie, for Windows nodes)
+func DoUploadBytesToFileDirect(contents []byte, dst string) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		if len(dst) == 0 {
+			return ActionError("internal error: empty remote path in DoUploadBytesToFileDirect()")
+		}
+
+		GetLoggerFromContext(ctx).Debug("uploading %d bytes directly to %s", len(contents), dst)
+		GetLoggerFromContext(ctx).Trace("contents of %s:\n%s\n", dst, contents)
+		logSession(ctx, "# uploading %d bytes to %s", len(contents), dst)
+		if err := GetCommFromContext(ctx).Upload(dst, bytes.NewReader(contents)); err != nil {
+			logSession(ctx, "# upload failed: %s", err)
+			return ActionError(fmt.Sprintf("could not upload to %q: %s", dst, err))
+		}
+		return nil
+	})
+}
+
 // DoUploadBytesToFile uploads a file to a remote path, using a temporary file in /tmp
 // and then moving it to the final destination with `sudo`.
 // It is important to use a temporary file as uploads are performed as a regular
@@ -184,6 +207,46 @@ func DoUploadFileToFile(local string, remote string) Action {
 	})
 }
 
+// DoStreamFileToFile uploads a (potentially big) local file to a remote file,
+// streaming the contents instead of loading the whole file in memory
+func DoStreamFileToFile(local string, remote string) Action {
+	if local == "" {
+		return ActionError("empty local file name to upload")
+	}
+	if remote == "" {
+		return ActionError("empty remote file name to upload")
+	}
+
+	dstTmpPath, err := GetTempFilename()
+	if err != nil {
+		return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+	}
+
+	return DoWithCleanup(ActionList{
+		DoMessageInfo(fmt.Sprintf("Uploading %q to %q", local, remote)),
+		ActionFunc(func(ctx context.Context) Action {
+			f, err := os.Open(local)
+			if err != nil {
+				return ActionError(fmt.Sprintf("could not open local file %q for uploading to %q: %s", local, remote, err))
+			}
+			defer f.Close()
+
+			logger := GetLoggerFromContext(ctx)
+			logger.Debug("streaming %q to %s", local, dstTmpPath)
+			logSession(ctx, "# uploading %s to %s", local, dstTmpPath)
+			if err := GetCommFromContext(ctx).Upload(dstTmpPath, f); err != nil {
+				logger.Warn("upload to %s failed: %s", dstTmpPath, err)
+				logSession(ctx, "# upload failed: %s", err)
+				return ActionError(err.Error())
+			}
+			return nil
+		}),
+		DoMoveFile(dstTmpPath, remote),
+	}, ActionList{
+		DoTry(DoDeleteFile(dstTmpPath)),
+	})
+}
+
 // DoDownloadFileToWriter downloads a file to a writer
 func DoDownloadFileToWriter(remote string, contents io.WriteCloser) Action {
 	if remote == "" {
@@ -15,6 +15,7 @@
 package ssh
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -130,3 +131,31 @@ func TestLeftovers(t *testing.T) {
 		t.Fatalf("Error: when running actions: %s", res)
 	}
 }
+
+func TestDoStreamFileToFile(t *testing.T) {
+	ctx, uploads := NewTestingContextForUploads([]string{})
+
+	f, err := ioutil.TempFile("", "stream")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(f.Name())
+	s := "this is a big file"
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	f.Close()
+
+	if res := DoStreamFileToFile(f.Name(), "/var/cache/images.tar").Apply(ctx); IsError(res) {
+		t.Fatalf("Error: when running actions: %s", res)
+	}
+	found := false
+	for _, contents := range *uploads {
+		if contents == s {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Error: upload not found in %+v", *uploads)
+	}
+}
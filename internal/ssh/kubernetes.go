@@ -82,12 +82,13 @@ func (m *Manifest) ReplaceConfig(config map[string]interface{}) error {
 }
 
 // isValidURL tests a string to determine if it is a url or not.
+// (note that absolute paths are valid request URIs, so we need a scheme and a host)
 func isValidURL(toTest string) bool {
-	_, err := url.ParseRequestURI(toTest)
+	u, err := url.ParseRequestURI(toTest)
 	if err != nil {
 		return false
 	}
-	return true
+	return len(u.Scheme) > 0 && len(u.Host) > 0
 }
 
 /////////////////////////////////////////////////////////////////////////////////
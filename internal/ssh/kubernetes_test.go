@@ -0,0 +1,34 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"testing"
+)
+
+func TestNewManifest(t *testing.T) {
+	if m := NewManifest("https://example.com/manifest.yaml"); m.URL == "" {
+		t.Fatalf("Error: not recognized as an URL: %+v", m)
+	}
+	if m := NewManifest("/tmp"); m.Path == "" {
+		t.Fatalf("Error: not recognized as a local file: %+v", m)
+	}
+	if m := NewManifest("/some/file/that/does/not/exist"); m.Inline == "" {
+		t.Fatalf("Error: not recognized as an inline manifest: %+v", m)
+	}
+	if m := NewManifest("kind: DaemonSet"); m.Inline == "" {
+		t.Fatalf("Error: not recognized as an inline manifest: %+v", m)
+	}
+}
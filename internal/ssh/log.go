@@ -0,0 +1,195 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	loggerContextKey = contextKey("logger")
+
+	// a tag added to all our log messages
+	logTag = "[KUBEADM]"
+)
+
+// LogLevel is the severity of a log message
+type LogLevel int
+
+const (
+	LogLevelTrace LogLevel = iota
+	LogLevelDebug
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogLevelTrace: "TRACE",
+	LogLevelDebug: "DEBUG",
+	LogLevelInfo:  "INFO",
+	LogLevelWarn:  "WARN",
+	LogLevelError: "ERROR",
+}
+
+// String returns the name of the level, as used by TF_LOG
+func (l LogLevel) String() string {
+	return logLevelNames[l]
+}
+
+// getMinLogLevel returns the minimum level we should log, obtained from
+// TF_LOG_PROVIDER or TF_LOG. When not set (or set to some unknown level),
+// everything is logged and Terraform will do the filtering.
+func getMinLogLevel() LogLevel {
+	for _, env := range []string{"TF_LOG_PROVIDER", "TF_LOG"} {
+		value := strings.ToUpper(strings.TrimSpace(os.Getenv(env)))
+		if value == "" {
+			continue
+		}
+		for level, name := range logLevelNames {
+			if name == value {
+				return level
+			}
+		}
+		return LogLevelTrace
+	}
+	return LogLevelTrace
+}
+
+// LogFields are some key/value pairs added to all the messages of a Logger
+type LogFields map[string]interface{}
+
+// String returns the fields as a sorted list of "key=value"
+func (f LogFields) String() string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := fmt.Sprintf("%v", f[k])
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		res = append(res, k+"="+v)
+	}
+	return strings.Join(res, " ")
+}
+
+// Logger is a leveled logger that adds some fields (like the host,
+// the role or the current step) to all the messages, using the format
+// expected by Terraform for filtering messages with TF_LOG.
+type Logger struct {
+	fields LogFields
+}
+
+// NewLogger creates a new logger with some fields
+func NewLogger(fields LogFields) *Logger {
+	l := &Logger{fields: LogFields{}}
+	for k, v := range fields {
+		l.fields[k] = v
+	}
+	return l
+}
+
+// With returns a copy of the logger with an additional field
+func (l *Logger) With(key string, value interface{}) *Logger {
+	n := NewLogger(l.fields)
+	n.fields[key] = value
+	return n
+}
+
+// Log logs a message with some level
+func (l *Logger) Log(level LogLevel, format string, args ...interface{}) {
+	if level < getMinLogLevel() {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if len(l.fields) > 0 {
+		msg = msg + " " + l.fields.String()
+	}
+	log.Printf("[%s] %s %s", level, logTag, Redact(msg))
+}
+
+// Trace logs a message with the TRACE level
+func (l *Logger) Trace(format string, args ...interface{}) {
+	l.Log(LogLevelTrace, format, args...)
+}
+
+// Debug logs a message with the DEBUG level
+func (l *Logger) Debug(format string, args ...interface{}) {
+	l.Log(LogLevelDebug, format, args...)
+}
+
+// Info logs a message with the INFO level
+func (l *Logger) Info(format string, args ...interface{}) {
+	l.Log(LogLevelInfo, format, args...)
+}
+
+// Warn logs a message with the WARN level
+func (l *Logger) Warn(format string, args ...interface{}) {
+	l.Log(LogLevelWarn, format, args...)
+}
+
+// Error logs a message with the ERROR level
+func (l *Logger) Error(format string, args ...interface{}) {
+	l.Log(LogLevelError, format, args...)
+}
+
+// defLogger is the logger used when there is no logger in the context
+var defLogger = NewLogger(nil)
+
+// WithLogger returns a new context with a logger
+func WithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// GetLoggerFromContext gets the logger from the context (or a default logger)
+func GetLoggerFromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*Logger); ok && logger != nil {
+		return logger
+	}
+	return defLogger
+}
+
+// DoWithLogStep runs an action with a "step" field added to all the log messages,
+// emitting the progress events for the step
+func DoWithLogStep(step string, action Action) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		logger := GetLoggerFromContext(ctx).With("step", step)
+		logger.Info("step started")
+		emitProgress(ctx, step, ProgressStarted, 0, nil)
+
+		start := time.Now()
+		res := ActionList{action}.Apply(WithLogger(ctx, logger))
+		if IsError(res) {
+			logger.Error("step failed after %s: %s", time.Since(start).Round(time.Millisecond), res.Error())
+			emitProgress(ctx, step, ProgressFailed, time.Since(start), res)
+		} else {
+			logger.Info("step finished in %s", time.Since(start).Round(time.Millisecond))
+			emitProgress(ctx, step, ProgressCompleted, time.Since(start), nil)
+		}
+		return res
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	defer os.Setenv("TF_LOG", os.Getenv("TF_LOG"))
+	os.Setenv("TF_LOG", "info")
+
+	logger := NewLogger(LogFields{"host": "10.0.0.1"})
+	ctx := WithLogger(context.Background(), logger.With("role", "master"))
+
+	res := DoWithLogStep("init", ActionList{
+		DoLog(LogLevelDebug, "this should be filtered"),
+		DoLog(LogLevelWarn, "something %s", "happened"),
+	}).Apply(ctx)
+	if IsError(res) {
+		t.Fatalf("Error: unexpected error: %s", res)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "filtered") {
+		t.Fatalf("Error: DEBUG message not filtered with TF_LOG=info:\n%s", out)
+	}
+	expected := `[WARN] [KUBEADM] something happened host=10.0.0.1 role=master step=init`
+	if !strings.Contains(out, expected) {
+		t.Fatalf("Error: %q not found in:\n%s", expected, out)
+	}
+	if !strings.Contains(out, "[INFO] [KUBEADM] step started") {
+		t.Fatalf("Error: step start not logged:\n%s", out)
+	}
+
+	// the parent logger must not be modified
+	if len(logger.fields) != 1 {
+		t.Fatalf("Error: parent logger modified: %+v", logger.fields)
+	}
+
+	if GetLoggerFromContext(context.Background()) != defLogger {
+		t.Fatalf("Error: default logger not returned for an empty context")
+	}
+}
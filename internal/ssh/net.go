@@ -15,7 +15,9 @@
 package ssh
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 )
 
 // AllMatchesIPv4 return all matches of IPs in a string
@@ -30,3 +32,45 @@ func AllMatchesIPv4(s string) (ips []string) {
 	}
 	return
 }
+
+// CheckFirewalldActive checks if firewalld is running
+func CheckFirewalldActive() CheckerFunc {
+	return CheckExec("systemctl --no-pager is-active --quiet firewalld.service")
+}
+
+// CheckUfwActive checks if ufw is active
+func CheckUfwActive() CheckerFunc {
+	return CheckExec("ufw status 2>/dev/null | grep -q 'Status: active'")
+}
+
+// DoOpenFirewallPorts opens some ports (ie, "10250/tcp" or "2379-2380/tcp") in the local
+// firewall (firewalld or ufw), doing nothing if there is no firewall active
+func DoOpenFirewallPorts(ports ...string) Action {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	firewalldCmds := []string{}
+	ufwCmds := []string{}
+	for _, port := range ports {
+		firewalldCmds = append(firewalldCmds, fmt.Sprintf("firewall-cmd --permanent --add-port=%s", port))
+		// ufw uses ":" for port ranges (ie, "2379:2380/tcp")
+		ufwCmds = append(ufwCmds, fmt.Sprintf("ufw allow %s", strings.Replace(port, "-", ":", 1)))
+	}
+	firewalldCmds = append(firewalldCmds, "firewall-cmd --reload")
+
+	return ActionList{
+		DoIf(
+			CheckFirewalldActive(),
+			ActionList{
+				DoMessageInfo(fmt.Sprintf("Opening ports %s in firewalld", strings.Join(ports, ", "))),
+				DoExec(fmt.Sprintf("sh -c '%s'", strings.Join(firewalldCmds, " && "))),
+			}),
+		DoIf(
+			CheckUfwActive(),
+			ActionList{
+				DoMessageInfo(fmt.Sprintf("Opening ports %s in ufw", strings.Join(ports, ", "))),
+				DoExec(fmt.Sprintf("sh -c '%s'", strings.Join(ufwCmds, " && "))),
+			}),
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator"
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const (
+	// DefMaxSessions is the default maximum number of concurrent sessions in a
+	// connection (below the default `MaxSessions` in sshd)
+	DefMaxSessions = 8
+
+	// DefPoolIdleTimeout is the time a connection is kept open after it has been
+	// released, so the next step for the same host can reuse it
+	DefPoolIdleTimeout = 1 * time.Minute
+)
+
+var (
+	// ErrConnReleased is returned when a communicator is used after being released
+	ErrConnReleased = errors.New("the connection has been released")
+)
+
+// ConnPool is a pool of connections, with one connection per host that is shared
+// by all the provisioners running in this process (ie, all the steps of an apply
+// for the same node). The sessions (commands and uploads) are multiplexed in the
+// connection, and the connection is re-established when it has been lost.
+type ConnPool struct {
+	idleTimeout time.Duration
+
+	lock  sync.Mutex
+	conns map[string]*pooledConn
+}
+
+// NewConnPool creates a new pool of connections. The connections are closed
+// after being released for `idleTimeout` (or immediately when it is 0).
+func NewConnPool(idleTimeout time.Duration) *ConnPool {
+	return &ConnPool{
+		idleTimeout: idleTimeout,
+		conns:       map[string]*pooledConn{},
+	}
+}
+
+// DefaultConnPool is the pool of connections used by the provisioners
+var DefaultConnPool = NewConnPool(DefPoolIdleTimeout)
+
+// Get returns a communicator for the host identified by `key`. When there is no
+// connection for that host in the pool, a new (connected) communicator is obtained
+// with `connect`. A maximum of `maxSessions` sessions will be run concurrently in
+// the connection (or DefMaxSessions when it is 0). Note well: the limit is set by
+// the first caller for that host, and the `maxSessions` of the following callers is
+// ignored while the connection is in the pool.
+// The communicator must be released with `Disconnect()`.
+func (p *ConnPool) Get(key string, maxSessions int, connect func() (communicator.Communicator, error)) (communicator.Communicator, error) {
+	if maxSessions <= 0 {
+		maxSessions = DefMaxSessions
+	}
+
+	p.lock.Lock()
+	conn, ok := p.conns[key]
+	if !ok {
+		conn = &pooledConn{
+			key:         key,
+			idleTimeout: p.idleTimeout,
+			sessions:    make(chan struct{}, maxSessions),
+		}
+		p.conns[key] = conn
+	} else if cap(conn.sessions) != maxSessions {
+		Debug("pool: ignoring max sessions=%d for %s: using %d", maxSessions, key, cap(conn.sessions))
+	}
+	p.lock.Unlock()
+
+	return conn.acquire(connect)
+}
+
+// pooledConn is a connection in the pool
+type pooledConn struct {
+	key         string
+	idleTimeout time.Duration
+
+	// the communicator, the references to it and the timer for closing it when idle
+	lock sync.Mutex
+	comm communicator.Communicator
+	refs int
+	idle *time.Timer
+
+	// the connection being established (nil when nobody is connecting)
+	connecting *pendingConn
+
+	// serializes the reconnections, with a generation number for detecting
+	// the connection has already been re-established by someone else
+	reconnectLock sync.Mutex
+	generation    int
+
+	// semaphore for the concurrent sessions
+	sessions chan struct{}
+}
+
+// pendingConn is a connection being established: the other callers for the same
+// host wait for it (and get the same error) instead of connecting again
+type pendingConn struct {
+	done chan struct{}
+	err  error
+}
+
+// acquire gets a new reference to the connection, connecting when necessary.
+// The connection is established without holding any lock, so a host that is not
+// reachable does not block the pool (or the references to it being released).
+func (c *pooledConn) acquire(connect func() (communicator.Communicator, error)) (communicator.Communicator, error) {
+	c.lock.Lock()
+
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+
+	if c.comm == nil && c.connecting != nil {
+		pending := c.connecting
+		c.lock.Unlock()
+
+		Debug("pool: waiting for the connection to %s", c.key)
+		<-pending.done
+		if pending.err != nil {
+			return nil, pending.err
+		}
+		return c.acquire(connect)
+	}
+
+	if c.comm != nil {
+		Debug("pool: reusing the connection to %s", c.key)
+		c.refs++
+		c.lock.Unlock()
+		return &pooledComm{conn: c}, nil
+	}
+
+	pending := &pendingConn{done: make(chan struct{})}
+	c.connecting = pending
+	c.lock.Unlock()
+
+	Debug("pool: opening a new connection to %s", c.key)
+	comm, err := connect()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.connecting = nil
+	pending.err = err
+	close(pending.done)
+	if err != nil {
+		return nil, err
+	}
+
+	c.comm = comm
+	c.refs++
+	return &pooledComm{conn: c}, nil
+}
+
+// release drops a reference to the connection, closing it after the idle timeout
+// when nobody else is using it
+func (c *pooledConn) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	if c.idleTimeout <= 0 {
+		c.close()
+		return
+	}
+	c.idle = time.AfterFunc(c.idleTimeout, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.refs == 0 {
+			c.close()
+		}
+	})
+}
+
+// close closes the connection (the lock must be held)
+func (c *pooledConn) close() {
+	if c.comm == nil {
+		return
+	}
+	Debug("pool: closing the connection to %s", c.key)
+	_ = c.comm.Disconnect()
+	c.comm = nil
+}
+
+// reconnect re-establishes the connection, unless it has already been
+// re-established since `generation`
+func (c *pooledConn) reconnect(comm communicator.Communicator, o terraform.UIOutput, generation int) error {
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+
+	if c.generation != generation {
+		return nil
+	}
+
+	Debug("pool: reconnecting to %s", c.key)
+	ctx, cancel := context.WithTimeout(context.Background(), comm.Timeout())
+	defer cancel()
+	if err := communicator.Retry(ctx, func() error { return comm.Connect(o) }); err != nil {
+		return err
+	}
+	c.generation++
+	return nil
+}
+
+// currentGeneration returns the current generation of the connection
+func (c *pooledConn) currentGeneration() int {
+	c.reconnectLock.Lock()
+	defer c.reconnectLock.Unlock()
+	return c.generation
+}
+
+// pooledComm is a reference to a connection in the pool, implementing
+// the communicator.Communicator interface
+type pooledComm struct {
+	conn *pooledConn
+
+	// protected by the lock in the connection
+	released bool
+}
+
+// getComm returns the communicator of the connection, or an error when this
+// reference has been released or the connection has been closed
+func (pc *pooledComm) getComm() (communicator.Communicator, error) {
+	pc.conn.lock.Lock()
+	defer pc.conn.lock.Unlock()
+	if pc.released || pc.conn.comm == nil {
+		return nil, ErrConnReleased
+	}
+	return pc.conn.comm, nil
+}
+
+// Connect re-establishes the connection
+func (pc *pooledComm) Connect(o terraform.UIOutput) error {
+	comm, err := pc.getComm()
+	if err != nil {
+		return err
+	}
+	return pc.conn.reconnect(comm, o, pc.conn.currentGeneration())
+}
+
+// Disconnect releases the connection: it will be closed when nobody is using it
+func (pc *pooledComm) Disconnect() error {
+	pc.conn.lock.Lock()
+	released := pc.released
+	pc.released = true
+	pc.conn.lock.Unlock()
+
+	if !released {
+		pc.conn.release()
+	}
+	return nil
+}
+
+// Timeout returns the configured connection timeout (or 0 when released)
+func (pc *pooledComm) Timeout() time.Duration {
+	comm, err := pc.getComm()
+	if err != nil {
+		return 0
+	}
+	return comm.Timeout()
+}
+
+// ScriptPath returns the configured script path (or "" when released)
+func (pc *pooledComm) ScriptPath() string {
+	comm, err := pc.getComm()
+	if err != nil {
+		return ""
+	}
+	return comm.ScriptPath()
+}
+
+// Start runs a command in a new session, reconnecting when the connection has been lost
+func (pc *pooledComm) Start(cmd *remote.Cmd) error {
+	comm, err := pc.getComm()
+	if err != nil {
+		return err
+	}
+
+	pc.conn.sessions <- struct{}{}
+
+	generation := pc.conn.currentGeneration()
+	err = comm.Start(cmd)
+	if err != nil {
+		Debug("pool: could not start a session in %s: %s", pc.conn.key, err)
+		if err = pc.conn.reconnect(comm, nil, generation); err == nil {
+			err = comm.Start(cmd)
+		}
+	}
+	if err != nil {
+		<-pc.conn.sessions
+		return err
+	}
+
+	go func() {
+		_ = cmd.Wait()
+		<-pc.conn.sessions
+	}()
+	return nil
+}
+
+// withSession runs `f` with the communicator in a session slot
+func (pc *pooledComm) withSession(f func(comm communicator.Communicator) error) error {
+	comm, err := pc.getComm()
+	if err != nil {
+		return err
+	}
+
+	pc.conn.sessions <- struct{}{}
+	defer func() { <-pc.conn.sessions }()
+	return f(comm)
+}
+
+// Upload uploads a single file
+func (pc *pooledComm) Upload(path string, input io.Reader) error {
+	return pc.withSession(func(comm communicator.Communicator) error { return comm.Upload(path, input) })
+}
+
+// UploadScript uploads a file as an executable script
+func (pc *pooledComm) UploadScript(path string, input io.Reader) error {
+	return pc.withSession(func(comm communicator.Communicator) error { return comm.UploadScript(path, input) })
+}
+
+// UploadDir uploads a directory
+func (pc *pooledComm) UploadDir(dst string, src string) error {
+	return pc.withSession(func(comm communicator.Communicator) error { return comm.UploadDir(dst, src) })
+}
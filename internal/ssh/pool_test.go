@@ -0,0 +1,337 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator"
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// poolTestCommunicator counts the connections and the concurrent sessions,
+// failing in the first `failures` sessions
+type poolTestCommunicator struct {
+	DummyCommunicator
+
+	lock        sync.Mutex
+	connects    int
+	disconnects int
+	running     int
+	maxRunning  int
+	failures    int
+	release     chan struct{}
+}
+
+func (c *poolTestCommunicator) Connect(terraform.UIOutput) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.connects++
+	return nil
+}
+
+func (c *poolTestCommunicator) Disconnect() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.disconnects++
+	return nil
+}
+
+func (c *poolTestCommunicator) Start(cmd *remote.Cmd) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("connection lost")
+	}
+
+	cmd.Init()
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	go func() {
+		<-c.release
+		c.lock.Lock()
+		c.running--
+		c.lock.Unlock()
+		cmd.SetExitStatus(0, nil)
+	}()
+	return nil
+}
+
+func TestConnPool(t *testing.T) {
+	pool := NewConnPool(0)
+	comm := &poolTestCommunicator{release: make(chan struct{})}
+	connect := func() (communicator.Communicator, error) {
+		return comm, comm.Connect(nil)
+	}
+
+	c1, err := pool.Get("ssh://root@10.0.0.1:22", 2, connect)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	c2, err := pool.Get("ssh://root@10.0.0.1:22", 2, connect)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if comm.connects != 1 {
+		t.Fatalf("Error: the connection has not been reused: %d connections", comm.connects)
+	}
+
+	// no more than 2 sessions should run at the same time
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := &remote.Cmd{Command: "true"}
+			if err := c1.Start(cmd); err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			_ = cmd.Wait()
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case comm.release <- struct{}{}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Error: timeout waiting for the sessions")
+		}
+	}
+	wg.Wait()
+	if comm.maxRunning != 2 {
+		t.Fatalf("Error: unexpected number of concurrent sessions: %d", comm.maxRunning)
+	}
+
+	// the connection is closed when the last reference is released
+	_ = c1.Disconnect()
+	_ = c1.Disconnect()
+	if comm.disconnects != 0 {
+		t.Fatalf("Error: the connection has been closed while in use")
+	}
+	_ = c2.Disconnect()
+	if comm.disconnects != 1 {
+		t.Fatalf("Error: the connection has not been closed: %d disconnections", comm.disconnects)
+	}
+}
+
+func TestConnPoolReconnect(t *testing.T) {
+	pool := NewConnPool(0)
+	comm := &poolTestCommunicator{release: make(chan struct{}, 1), failures: 1}
+	c, err := pool.Get("ssh://root@10.0.0.1:22", 0, func() (communicator.Communicator, error) {
+		return comm, comm.Connect(nil)
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer c.Disconnect()
+
+	comm.release <- struct{}{}
+	cmd := &remote.Cmd{Command: "true"}
+	if err := c.Start(cmd); err != nil {
+		t.Fatalf("Error: the command should have been run after reconnecting: %s", err)
+	}
+	_ = cmd.Wait()
+	if comm.connects != 2 {
+		t.Fatalf("Error: unexpected number of connections: %d", comm.connects)
+	}
+}
+
+func TestConnPoolUseAfterRelease(t *testing.T) {
+	pool := NewConnPool(0)
+	comm := &poolTestCommunicator{release: make(chan struct{}, 1)}
+	c, err := pool.Get("ssh://root@10.0.0.1:22", 0, func() (communicator.Communicator, error) {
+		return comm, comm.Connect(nil)
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	_ = c.Disconnect()
+	if comm.disconnects != 1 {
+		t.Fatalf("Error: the connection has not been closed: %d disconnections", comm.disconnects)
+	}
+
+	// using the communicator after releasing it must fail (and not panic)
+	if err := c.Start(&remote.Cmd{Command: "true"}); err != ErrConnReleased {
+		t.Fatalf("Error: unexpected error when starting a command after releasing: %v", err)
+	}
+	if err := c.Upload("/tmp/file", strings.NewReader("")); err != ErrConnReleased {
+		t.Fatalf("Error: unexpected error when uploading after releasing: %v", err)
+	}
+	if err := c.Connect(nil); err != ErrConnReleased {
+		t.Fatalf("Error: unexpected error when reconnecting after releasing: %v", err)
+	}
+	if timeout := c.Timeout(); timeout != 0 {
+		t.Fatalf("Error: unexpected timeout after releasing: %s", timeout)
+	}
+}
+
+func TestConnPoolKeepsReleasedReferences(t *testing.T) {
+	pool := NewConnPool(0)
+	comm := &poolTestCommunicator{release: make(chan struct{}, 1)}
+	connect := func() (communicator.Communicator, error) {
+		return comm, comm.Connect(nil)
+	}
+	c1, err := pool.Get("ssh://root@10.0.0.1:22", 0, connect)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	c2, err := pool.Get("ssh://root@10.0.0.1:22", 0, connect)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer c2.Disconnect()
+	_ = c1.Disconnect()
+
+	// a released reference cannot be used, even if the connection is still open
+	if err := c1.Start(&remote.Cmd{Command: "true"}); err != ErrConnReleased {
+		t.Fatalf("Error: unexpected error when starting a command after releasing: %v", err)
+	}
+
+	comm.release <- struct{}{}
+	cmd := &remote.Cmd{Command: "true"}
+	if err := c2.Start(cmd); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	_ = cmd.Wait()
+}
+
+func TestConnPoolConcurrentConnect(t *testing.T) {
+	pool := NewConnPool(0)
+
+	// the connection to the first host does not finish until we say so
+	unblock := make(chan struct{})
+	slow := &poolTestCommunicator{release: make(chan struct{})}
+	connects := 0
+	lock := sync.Mutex{}
+	connectSlow := func() (communicator.Communicator, error) {
+		lock.Lock()
+		connects++
+		lock.Unlock()
+		<-unblock
+		return slow, slow.Connect(nil)
+	}
+
+	results := make(chan error, 2)
+	comms := make(chan communicator.Communicator, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			c, err := pool.Get("ssh://root@10.0.0.1:22", 0, connectSlow)
+			if err == nil {
+				comms <- c
+			}
+			results <- err
+		}()
+	}
+
+	// other hosts are not blocked by the one we are connecting to
+	fast := &poolTestCommunicator{release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		c, err := pool.Get("ssh://root@10.0.0.2:22", 0, func() (communicator.Communicator, error) {
+			return fast, fast.Connect(nil)
+		})
+		if err == nil {
+			_ = c.Disconnect()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error: the connection to another host is blocked")
+	}
+
+	// all the callers for the same host share the connection being established
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Error: timeout waiting for the connection")
+		}
+	}
+	if connects != 1 || slow.connects != 1 {
+		t.Fatalf("Error: the host has been connected more than once: %d connections", connects)
+	}
+	for i := 0; i < 2; i++ {
+		_ = (<-comms).Disconnect()
+	}
+}
+
+func TestConnPoolConnectError(t *testing.T) {
+	pool := NewConnPool(0)
+
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+	connects := 0
+	lock := sync.Mutex{}
+	connect := func() (communicator.Communicator, error) {
+		lock.Lock()
+		connects++
+		first := connects == 1
+		lock.Unlock()
+		if first {
+			close(started)
+		}
+		<-unblock
+		return nil, errors.New("host unreachable")
+	}
+
+	results := make(chan error, 2)
+	go func() {
+		_, err := pool.Get("ssh://root@10.0.0.1:22", 0, connect)
+		results <- err
+	}()
+	<-started
+	go func() {
+		_, err := pool.Get("ssh://root@10.0.0.1:22", 0, connect)
+		results <- err
+	}()
+
+	// wait for the second caller to be waiting for the first connection
+	time.Sleep(50 * time.Millisecond)
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err == nil || !strings.Contains(err.Error(), "host unreachable") {
+			t.Fatalf("Error: unexpected error: %v", err)
+		}
+	}
+	if connects != 1 {
+		t.Fatalf("Error: the waiting caller connected again: %d connections", connects)
+	}
+
+	// the next caller tries to connect again
+	_, _ = pool.Get("ssh://root@10.0.0.1:22", 0, func() (communicator.Communicator, error) {
+		connects++
+		return nil, errors.New("host unreachable")
+	})
+	if connects != 2 {
+		t.Fatalf("Error: the failed connection has been cached")
+	}
+}
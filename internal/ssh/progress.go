@@ -0,0 +1,100 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressStatus is the status of a phase in a progress event
+type ProgressStatus string
+
+const (
+	// ProgressStarted is emitted when a phase starts
+	ProgressStarted = ProgressStatus("started")
+
+	// ProgressCompleted is emitted when a phase finishes successfully
+	ProgressCompleted = ProgressStatus("completed")
+
+	// ProgressFailed is emitted when a phase fails
+	ProgressFailed = ProgressStatus("failed")
+)
+
+// ProgressEvent is a machine-readable event about the provisioning of a node
+type ProgressEvent struct {
+	Time     time.Time      `json:"time"`
+	Node     string         `json:"node"`
+	Phase    string         `json:"phase"`
+	Status   ProgressStatus `json:"status"`
+	Duration float64        `json:"duration_seconds,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// ProgressWriter writes the progress events in JSON lines format
+type ProgressWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// NewProgressWriter creates a new writer for the progress events
+func NewProgressWriter(w io.Writer) *ProgressWriter {
+	return &ProgressWriter{w: w}
+}
+
+// Write writes an event (in just one write, so many writers can append to the same file)
+func (p *ProgressWriter) Write(event ProgressEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, err = p.w.Write(append(b, '\n'))
+	return err
+}
+
+// SetProgressInContext sets the writer for the progress events of the `node`
+func SetProgressInContext(ctx context.Context, node string, w *ProgressWriter) {
+	sshc := getSSHContext(ctx)
+	sshc.progress = w
+	sshc.progressNode = node
+}
+
+// emitProgress writes a progress event for a phase, if there is a progress writer
+func emitProgress(ctx context.Context, phase string, status ProgressStatus, duration time.Duration, res Action) {
+	sshc, ok := ctx.Value(sshContextKey).(*sshContext)
+	if !ok || sshc.progress == nil {
+		return
+	}
+
+	event := ProgressEvent{
+		Time:     time.Now(),
+		Node:     sshc.progressNode,
+		Phase:    phase,
+		Status:   status,
+		Duration: duration.Round(time.Millisecond).Seconds(),
+	}
+	if IsError(res) {
+		event.Error = Redact(res.Error())
+	}
+	if err := sshc.progress.Write(event); err != nil {
+		GetLoggerFromContext(ctx).Warn("could not write the progress event: %s", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProgressEvents(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewTestingContext()
+	SetProgressInContext(ctx, "10.0.0.1", NewProgressWriter(&buf))
+
+	res := ActionList{
+		DoWithLogStep("setup", DoNothing()),
+		DoWithLogStep("init", ActionError("kubeadm init failed")),
+		DoWithLogStep("addons", DoNothing()),
+	}.Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: an error was expected")
+	}
+
+	expected := []struct {
+		phase  string
+		status ProgressStatus
+	}{
+		{"setup", ProgressStarted},
+		{"setup", ProgressCompleted},
+		{"init", ProgressStarted},
+		{"init", ProgressFailed},
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Error: unexpected number of events:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		event := ProgressEvent{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Error: could not parse the event %q: %s", line, err)
+		}
+		if event.Node != "10.0.0.1" || event.Phase != expected[i].phase || event.Status != expected[i].status {
+			t.Fatalf("Error: unexpected event: %+v", event)
+		}
+		if event.Status == ProgressFailed && !strings.Contains(event.Error, "kubeadm init failed") {
+			t.Fatalf("Error: the error is not in the event: %+v", event)
+		}
+	}
+
+	// no events without a progress writer
+	buf.Reset()
+	DoWithLogStep("setup", DoNothing()).Apply(NewTestingContext())
+	if buf.Len() > 0 {
+		t.Fatalf("Error: unexpected events:\n%s", buf.String())
+	}
+}
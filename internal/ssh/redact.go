@@ -0,0 +1,63 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// the text that replaces secrets in logs
+	redactedSecret = "<redacted>"
+
+	// secrets shorter than this are not redacted (it would redact too much)
+	minSecretLength = 6
+)
+
+var (
+	secrets     = map[string]struct{}{}
+	secretsLock sync.RWMutex
+)
+
+// AddSecret registers a secret (a token, a password, a private key...) that
+// must be redacted in any log, debug message or error
+func AddSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+
+	secrets[secret] = struct{}{}
+
+	// multi-line secrets (like keys) are usually printed quoted in debug messages
+	if quoted := strconv.Quote(secret); quoted[1:len(quoted)-1] != secret {
+		secrets[quoted[1:len(quoted)-1]] = struct{}{}
+	}
+}
+
+// Redact replaces all the secrets registered in a string
+func Redact(s string) string {
+	secretsLock.RLock()
+	defer secretsLock.RUnlock()
+
+	for secret := range secrets {
+		s = strings.Replace(s, secret, redactedSecret, -1)
+	}
+	return s
+}
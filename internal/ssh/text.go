@@ -16,9 +16,16 @@ package ssh
 
 import (
 	"bytes"
+	"strings"
 	"text/template"
 )
 
+// ShellQuote quotes a string with single quotes (escaping any single
+// quote in it), so it can be used as a single word in a shell
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
 // ReplaceInTemplate performs replacements in an input text
 func ReplaceInTemplate(text string, replacements map[string]interface{}) (string, error) {
 	tmpl, err := template.New("template").Parse(text)
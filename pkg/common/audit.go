@@ -0,0 +1,134 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+const (
+	// DefAuditPolicyPath is the path of the audit policy in the control plane nodes
+	DefAuditPolicyPath = "/etc/kubernetes/audit/policy.yaml"
+
+	// DefAuditWebhookConfigPath is the path of the kubeconfig for the audit webhook in the control plane nodes
+	DefAuditWebhookConfigPath = "/etc/kubernetes/audit/webhook-kubeconfig.yaml"
+
+	// DefAuditLogPath is the default path of the audit log in the control plane nodes
+	DefAuditLogPath = "/var/log/kubernetes/audit/audit.log"
+
+	// DefAuditPolicy is the audit policy used when no policy is provided: the metadata of
+	// all the requests is logged, except for the health checks
+	DefAuditPolicy = `apiVersion: audit.k8s.io/v1
+kind: Policy
+omitStages:
+  - "RequestReceived"
+rules:
+  - level: None
+    nonResourceURLs:
+      - "/healthz*"
+      - "/livez*"
+      - "/readyz*"
+      - "/version"
+  - level: Metadata
+`
+)
+
+var (
+	// AuditWebhookModes are the modes of the audit webhook backend
+	AuditWebhookModes = []string{"batch", "blocking", "blocking-strict"}
+)
+
+// AuditSpec describes the audit backends of the API server
+type AuditSpec struct {
+	// path of the audit policy in the control plane nodes (empty when auditing is disabled)
+	PolicyPath string
+
+	// path of the audit log (empty for not logging to a file)
+	LogPath       string
+	LogMaxAge     int
+	LogMaxBackups int
+	LogMaxSize    int
+
+	// path of the kubeconfig for the webhook (empty for not using a webhook)
+	WebhookConfigPath string
+
+	// mode of the webhook, and the batching parameters (zero/empty for the API server defaults)
+	WebhookMode           string
+	WebhookBatchMaxSize   int
+	WebhookBatchMaxWait   string
+	WebhookInitialBackoff string
+}
+
+// IsEnabled returns true when the API server audits the requests
+func (s AuditSpec) IsEnabled() bool {
+	return len(s.PolicyPath) > 0
+}
+
+// setAuditArgs sets the flags (and mounts the files) for auditing in the API server
+func setAuditArgs(spec AuditSpec, component *kubeadmapi.ControlPlaneComponent) {
+	if !spec.IsEnabled() {
+		return
+	}
+
+	mountConfigFile(component, "audit-policy", "audit-policy-file", spec.PolicyPath)
+
+	args := map[string]string{}
+	if len(spec.LogPath) > 0 {
+		// the directory of the log must be writable
+		component.ExtraVolumes = append(component.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "audit-log",
+			HostPath:  filepath.Dir(spec.LogPath),
+			MountPath: filepath.Dir(spec.LogPath),
+			PathType:  corev1.HostPathDirectoryOrCreate,
+		})
+		args["audit-log-path"] = spec.LogPath
+		if spec.LogMaxAge > 0 {
+			args["audit-log-maxage"] = fmt.Sprintf("%d", spec.LogMaxAge)
+		}
+		if spec.LogMaxBackups > 0 {
+			args["audit-log-maxbackup"] = fmt.Sprintf("%d", spec.LogMaxBackups)
+		}
+		if spec.LogMaxSize > 0 {
+			args["audit-log-maxsize"] = fmt.Sprintf("%d", spec.LogMaxSize)
+		}
+	}
+
+	if len(spec.WebhookConfigPath) > 0 {
+		mountConfigFile(component, "audit-webhook-config", "audit-webhook-config-file", spec.WebhookConfigPath)
+		if len(spec.WebhookMode) > 0 {
+			args["audit-webhook-mode"] = spec.WebhookMode
+		}
+		if spec.WebhookBatchMaxSize > 0 {
+			args["audit-webhook-batch-max-size"] = fmt.Sprintf("%d", spec.WebhookBatchMaxSize)
+		}
+		if len(spec.WebhookBatchMaxWait) > 0 {
+			args["audit-webhook-batch-max-wait"] = spec.WebhookBatchMaxWait
+		}
+		if len(spec.WebhookInitialBackoff) > 0 {
+			args["audit-webhook-initial-backoff"] = spec.WebhookInitialBackoff
+		}
+	}
+
+	// the extra args win
+	for k, v := range args {
+		if _, ok := component.ExtraArgs[k]; !ok {
+			component.ExtraArgs[k] = v
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestInitConfigAudit(t *testing.T) {
+	spec := ClusterSpec{
+		Audit: AuditSpec{
+			PolicyPath:          DefAuditPolicyPath,
+			LogPath:             DefAuditLogPath,
+			LogMaxAge:           30,
+			WebhookConfigPath:   DefAuditWebhookConfigPath,
+			WebhookMode:         "batch",
+			WebhookBatchMaxWait: "10s",
+		},
+		Runtime: RuntimeSpec{
+			APIServerArgs: map[string]string{"audit-log-maxage": "7"},
+		},
+	}
+	initConfig, err := NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	expected := map[string]string{
+		"audit-policy-file":            DefAuditPolicyPath,
+		"audit-log-path":               DefAuditLogPath,
+		"audit-log-maxage":             "7", // (the extra args win)
+		"audit-webhook-config-file":    DefAuditWebhookConfigPath,
+		"audit-webhook-mode":           "batch",
+		"audit-webhook-batch-max-wait": "10s",
+	}
+	args := initConfig.APIServer.ExtraArgs
+	for k, v := range expected {
+		if args[k] != v {
+			t.Fatalf("Error: unexpected value for %q: %q", k, args[k])
+		}
+	}
+	if _, ok := args["audit-log-maxbackup"]; ok {
+		t.Fatalf("Error: unexpected audit-log-maxbackup: %v", args)
+	}
+
+	volumes := map[string]string{}
+	for _, v := range initConfig.APIServer.ExtraVolumes {
+		volumes[v.Name] = v.HostPath
+	}
+	if volumes["audit-policy"] != DefAuditPolicyPath || volumes["audit-webhook-config"] != DefAuditWebhookConfigPath || volumes["audit-log"] != "/var/log/kubernetes/audit" {
+		t.Fatalf("Error: unexpected volumes: %v", volumes)
+	}
+
+	// no auditing
+	initConfig, err = NewInitConfig(ClusterSpec{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(initConfig.APIServer.ExtraVolumes) > 0 {
+		t.Fatalf("Error: unexpected volumes: %+v", initConfig.APIServer.ExtraVolumes)
+	}
+}
@@ -15,12 +15,15 @@
 package common
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	certutil "k8s.io/client-go/util/cert"
@@ -28,6 +31,8 @@ import (
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 	"k8s.io/kubernetes/cmd/kubeadm/app/phases/certs"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pubkeypin"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
@@ -273,3 +278,54 @@ func CreateCerts(d *schema.ResourceData, initCfg *kubeadmapi.InitConfiguration)
 
 	return m, nil
 }
+
+// GetCACertHash returns the hash of the CA public key, as expected
+// in the `--discovery-token-ca-cert-hash` argument of `kubeadm join`
+func GetCACertHash(caCrt string) (string, error) {
+	certs, err := certutil.ParseCertsPEM([]byte(caCrt))
+	if err != nil {
+		return "", fmt.Errorf("could not parse the CA certificate: %s", err)
+	}
+	return pubkeypin.Hash(certs[0]), nil
+}
+
+// GetCertExpiration returns the expiration time of the (first) certificate
+// in the PEM-encoded data provided
+func GetCertExpiration(crt string) (time.Time, error) {
+	certs, err := certutil.ParseCertsPEM([]byte(crt))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse the certificate: %s", err)
+	}
+	return certs[0].NotAfter, nil
+}
+
+// NewClientCert creates a client certificate (and its key) for `commonName` in the
+// `organizations`, signed by the CA, returning them PEM-encoded
+func NewClientCert(caCrt []byte, caKey []byte, commonName string, organizations []string) ([]byte, []byte, error) {
+	certs, err := certutil.ParseCertsPEM(caCrt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse the CA certificate: %s", err)
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse the CA key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("the CA key is not a RSA key")
+	}
+
+	cert, clientKey, err := pkiutil.NewCertAndKey(certs[0], rsaKey, &certutil.Config{
+		CommonName:   commonName,
+		Organization: organizations,
+		Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the certificate for %q: %s", commonName, err)
+	}
+	clientKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(clientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkiutil.EncodeCertPEM(cert), clientKeyPEM, nil
+}
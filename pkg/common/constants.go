@@ -15,6 +15,8 @@
 package common
 
 import (
+	"time"
+
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
@@ -31,8 +33,17 @@ const (
 
 	DefDNSDomain = "cluster.local"
 
+	// DefNodeLocalDNSIP is the link-local address used by NodeLocal DNSCache
+	DefNodeLocalDNSIP = "169.254.20.10"
+
 	DefRuntimeEngine = "docker"
 
+	// DefImagesRepository is the default repository for the Kubernetes images
+	DefImagesRepository = "k8s.gcr.io"
+
+	// DefSandboxImageName is the name (and tag) of the sandbox image used by the CRI
+	DefSandboxImageName = "pause:3.1"
+
 	DefKubeadmInitConfPath = "/etc/kubernetes/kubeadm-init.conf"
 
 	DefKubeadmJoinConfPath = "/etc/kubernetes/kubeadm-join.conf"
@@ -47,6 +58,22 @@ const (
 
 	DefFlannelImageVersion = "v0.11.0"
 
+	DefWeaveVersion = "2.5.2"
+
+	// Calico version deployed, and Tigera operator used for deploying it (for a given version)
+	DefCalicoVersion                = "v3.26.4"
+	DefCalicoOperatorManifestFormat = "https://raw.githubusercontent.com/projectcalico/calico/%s/manifests/tigera-operator.yaml"
+
+	// Default encapsulation for the Calico IP pool
+	DefCalicoEncapsulation = "IPIP"
+
+	// Cilium version deployed, and version of the cilium CLI used for deploying it
+	DefCiliumVersion    = "1.14.5"
+	DefCiliumCLIVersion = "v0.15.19"
+
+	// Default tunnel mode for Cilium
+	DefCiliumTunnel = "vxlan"
+
 	// Full path where we should upload the kubelet sysconfig file
 	DefKubeletSysconfigPath = "/etc/sysconfig/kubelet"
 
@@ -56,11 +83,128 @@ const (
 	// Full path where we should upload the kubeadm dropin file
 	DefKubeadmDropinPath = "/usr/lib/systemd/system/kubelet.service.d/10-kubeadm.conf"
 
+	// Default installation mode: with the packages manager
+	DefInstallMode = "packages"
+
+	// Directory where kubeadm, kubelet and kubectl are installed when using the "binaries" installation mode
+	DefBinariesDir = "/opt/bin"
+
+	// Full path where we should upload the kubelet.service file when using the "binaries" installation mode
+	DefBinariesKubeletServicePath = "/etc/systemd/system/kubelet.service"
+
+	// Full path where we should upload the kubeadm dropin file when using the "binaries" installation mode
+	DefBinariesKubeadmDropinPath = "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"
+
+	// Directory where the packages and images are uploaded for offline installations
+	DefOfflineDir = "/var/cache/kubeadm-offline"
+
+	// Files where the kernel modules and sysctls required by Kubernetes are persisted
+	DefKernelModulesPath = "/etc/modules-load.d/kubernetes.conf"
+	DefSysctlsPath       = "/etc/sysctl.d/99-kubernetes.conf"
+
+	// Configuration files and directories for the registries in the container runtimes
+	DefContainerdConfigPath   = "/etc/containerd/config.toml"
+	DefContainerdCertsDir     = "/etc/containerd/certs.d"
+	DefDockerDaemonConfigPath = "/etc/docker/daemon.json"
+	DefDockerCertsDir         = "/etc/docker/certs.d"
+	DefCrioRegistriesDir      = "/etc/containers/registries.conf.d"
+	DefCrioCertsDir           = "/etc/containers/certs.d"
+
 	// Default PKI dir
 	DefPKIDir = "/etc/kubernetes/pki"
 
+	// Default path for the scheduler configuration in the control plane nodes
+	DefSchedulerConfigPath = "/etc/kubernetes/scheduler-config.yaml"
+
+	// Default directory for the etcd data
+	DefEtcdDataDir = "/var/lib/etcd"
+
+	// etcd topologies: etcd running in the control plane nodes or in an external cluster
+	EtcdModeStacked  = "stacked"
+	EtcdModeExternal = "external"
+
+	// Default directory for the kubelet data
+	DefKubeletRootDir = "/var/lib/kubelet"
+
+	// Default disk usage thresholds (in percent) for the images garbage collection in the kubelet
+	DefImageGCHighThresholdPercent = 85
+	DefImageGCLowThresholdPercent  = 80
+
+	// Address used for the control plane components when exposing their metrics
+	DefMetricsBindAddress = "0.0.0.0"
+
+	// Port used by etcd for exposing metrics
+	DefEtcdMetricsPort = 2381
+
+	// Port used by etcd for the clients
+	DefEtcdClientPort = 2379
+
+	// Default common name for the etcd client certificate created by the provider
+	DefEtcdClientCommonName = "kube-etcd-monitoring"
+
+	// Directory where kubeadm, kubelet and kubectl are installed in Windows nodes
+	DefWindowsInstallDir = "C:/k"
+
+	// Full path where we should upload the kubeadm-setup script in Windows nodes
+	DefWindowsSetupScriptPath = DefWindowsInstallDir + "/kubeadm-setup.ps1"
+
+	// Full path for the kubeadm join configuration in Windows nodes
+	DefWindowsKubeadmJoinConfPath = DefWindowsInstallDir + "/kubeadm-join.conf"
+
+	// CRI socket used in Windows nodes
+	DefWindowsCriSocket = "npipe:////./pipe/containerd-containerd"
+
+	// Default prefix for the labels added from the hardware detected in the nodes
+	DefHardwareLabelsPrefix = "hardware.kubeadm.io"
+
+	// Default filesystem for the dedicated disks
+	DefStorageFilesystem = "ext4"
+
 	DefAPIServerPort = 6443
 
+	// manifest for the NVIDIA device plugin
+	DefNvidiaDevicePluginManifest = "https://raw.githubusercontent.com/NVIDIA/k8s-device-plugin/v0.14.5/nvidia-device-plugin.yml"
+
+	// Helm version installed in the first master
+	DefHelmVersion = "v3.13.3"
+
+	// Default namespace for the Helm charts
+	DefHelmNamespace = "default"
+
+	// manifest for the metrics-server
+	DefMetricsServerManifest = "https://github.com/kubernetes-sigs/metrics-server/releases/download/v0.6.4/components.yaml"
+
+	// chart for the NGINX ingress controller
+	DefIngressNginxChartRepo    = "https://kubernetes.github.io/ingress-nginx"
+	DefIngressNginxChartVersion = "4.8.3"
+	DefIngressNginxNamespace    = "ingress-nginx"
+
+	// manifest for the local-path provisioner
+	DefLocalPathManifest     = "https://raw.githubusercontent.com/rancher/local-path-provisioner/v0.0.24/deploy/local-path-storage.yaml"
+	DefLocalPathStorageClass = "local-path"
+
+	// chart for the NFS client provisioner
+	DefNFSChartRepo    = "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner/"
+	DefNFSChartVersion = "4.0.18"
+	DefNFSNamespace    = "nfs-provisioner"
+	DefNFSStorageClass = "nfs-client"
+
+	// DefKubeVipVersion is the version of kube-vip used for the control plane VIP
+	DefKubeVipVersion = "v0.6.4"
+
+	// chart for cert-manager
+	DefCertManagerChartRepo    = "https://charts.jetstack.io"
+	DefCertManagerChartVersion = "v1.13.3"
+	DefCertManagerNamespace    = "cert-manager"
+
+	// DefACMEServer is the ACME server used by default in the cert-manager issuer
+	DefACMEServer = "https://acme-v02.api.letsencrypt.org/directory"
+
+	// chart for MetalLB
+	DefMetalLBChartRepo    = "https://metallb.github.io/metallb"
+	DefMetalLBChartVersion = "0.13.12"
+	DefMetalLBNamespace    = "metallb-system"
+
 	// manifest for loading the dashboard
 	DefDashboardManifest = "https://raw.githubusercontent.com/kubernetes/dashboard/v1.10.1/src/deploy/recommended/kubernetes-dashboard.yaml"
 
@@ -72,6 +216,11 @@ const (
 
 	// resolv.conf for pods when upstream servers are provided
 	DefResolvUpstreamConf = "/etc/resolv.conf-kubeadm"
+
+	// default timeouts for provisioning nodes (can be changed with a `timeouts` block)
+	DefTimeoutCreate = 30 * time.Minute
+	DefTimeoutUpdate = 20 * time.Minute
+	DefTimeoutDelete = 10 * time.Minute
 )
 
 var (
@@ -79,13 +228,88 @@ var (
 	CNIPluginsManifestsTemplates = map[string]ssh.Manifest{
 		"flannel": {Inline: assets.FlannelManifestCode},
 		"weave":   {Inline: assets.WeaveManifestCode},
+		"calico":  {Inline: assets.CalicoManifestCode},
 	}
 
-	// CNIPluginsList gets the list of supported CNI plugins (will be filled by the init())
-	CNIPluginsList = []string{}
+	// CNIPluginsList gets the list of supported CNI plugins (will be filled by the init()
+	// with the plugins in CNIPluginsManifestsTemplates)
+	CNIPluginsList = []string{
+		"cilium", // installed with the cilium CLI
+	}
 )
 
 var (
+	// DefStorageFilesystems is the list of filesystems supported for the dedicated disks
+	DefStorageFilesystems = []string{
+		"ext4",
+		"xfs",
+	}
+
+	// DefControlPlaneMetricsPorts is the list of ports used for the control plane metrics
+	DefControlPlaneMetricsPorts = []string{
+		"10251/tcp", // kube-scheduler (http)
+		"10259/tcp", // kube-scheduler (https)
+		"10252/tcp", // kube-controller-manager (http)
+		"10257/tcp", // kube-controller-manager (https)
+		"2381/tcp",  // etcd metrics
+	}
+
+	// DefMasterFirewallPorts is the list of ports opened in the masters when managing the firewall
+	DefMasterFirewallPorts = []string{
+		"6443/tcp",        // API server
+		"2379-2380/tcp",   // etcd
+		"10250-10259/tcp", // kubelet, kube-scheduler and kube-controller-manager
+		"30000-32767/tcp", // NodePort services
+	}
+
+	// DefWorkerFirewallPorts is the list of ports opened in the workers when managing the firewall
+	DefWorkerFirewallPorts = []string{
+		"10250/tcp",       // kubelet
+		"30000-32767/tcp", // NodePort services
+	}
+
+	// DefCNIFirewallPorts is the list of ports used by the CNI drivers
+	DefCNIFirewallPorts = map[string][]string{
+		"flannel": {"8472/udp"}, // VXLAN
+		"weave":   {"6783/tcp", "6783-6784/udp"},
+		"calico":  {"179/tcp", "4789/udp", "5473/tcp"},              // BGP, VXLAN and Typha
+		"cilium":  {"8472/udp", "6081/udp", "4240/tcp", "4244/tcp"}, // VXLAN, Geneve, health and Hubble
+	}
+
+	// DefMetalLBFirewallPorts is the list of ports used by the MetalLB speakers
+	DefMetalLBFirewallPorts = []string{"7946/tcp", "7946/udp"} // memberlist
+
+	// DefCNIVersions is the version deployed by default for each CNI plugin
+	DefCNIVersions = map[string]string{
+		"flannel": DefFlannelImageVersion,
+		"weave":   DefWeaveVersion,
+		"calico":  DefCalicoVersion,
+		"cilium":  DefCiliumVersion,
+	}
+
+	// DefCalicoEncapsulations is the list of encapsulations supported for the Calico IP pool
+	DefCalicoEncapsulations = []string{
+		"IPIP",
+		"IPIPCrossSubnet",
+		"VXLAN",
+		"VXLANCrossSubnet",
+		"None",
+	}
+
+	// DefCiliumTunnels is the list of tunnel modes supported for Cilium
+	DefCiliumTunnels = []string{
+		"vxlan",
+		"geneve",
+		"disabled",
+	}
+
+	// DefIngressServiceTypes is the list of ways the ingress controller can be exposed
+	DefIngressServiceTypes = []string{
+		"NodePort",
+		"LoadBalancer",
+		"HostNetwork",
+	}
+
 	// DefaultCriSocket info
 	DefCriSocket = map[string]string{
 		"docker":     "/var/run/dockershim.sock",
@@ -93,6 +317,21 @@ var (
 		"containerd": "/var/run/containerd/containerd.sock",
 	}
 
+	// DefCriSocketCandidates are the CRI sockets probed in the nodes for each runtime engine,
+	// in order of preference (ie, cri-dockerd is preferred over the dockershim)
+	DefCriSocketCandidates = map[string][]string{
+		"docker":     {DefCriDockerdSocket, "/var/run/dockershim.sock"},
+		"crio":       {"/var/run/crio/crio.sock"},
+		"containerd": {"/var/run/containerd/containerd.sock", "/run/containerd/containerd.sock"},
+	}
+
+	// DefCgroupDriver is the cgroup driver used by default with each runtime engine
+	DefCgroupDriver = map[string]string{
+		"docker":     "cgroupfs",
+		"crio":       "systemd",
+		"containerd": "systemd",
+	}
+
 	DefIgnorePreflightChecks = []string{
 		"NumCPU",
 		"FileContent--proc-sys-net-bridge-bridge-nf-call-iptables",
@@ -104,6 +343,19 @@ var (
 		"NumCPU", // we will not always have >=2 CPUs in our VMs
 	}
 
+	// DefKernelModules is the list of kernel modules loaded in all the nodes
+	DefKernelModules = []string{
+		"overlay",
+		"br_netfilter",
+	}
+
+	// DefSysctls are the sysctls set in all the nodes
+	DefSysctls = map[string]string{
+		"net.bridge.bridge-nf-call-iptables":  "1",
+		"net.bridge.bridge-nf-call-ip6tables": "1",
+		"net.ipv4.ip_forward":                 "1",
+	}
+
 	DefKubeletSettings = map[string]string{
 		"network-plugin": "cni",
 	}
@@ -125,11 +377,43 @@ var (
 
 	// DefCloudConfigMandatory is the list of Cloud Providers where the cloud-config is mandatory
 	DefCloudConfigMandatory = []string{
+		"azure",
 		"openstack",
+		"vsphere",
 	}
 
 	// DefCloudConfigFilename  is the default cloud config inn the nodes
 	DefCloudConfigFilename = "/etc/kubernetes/cloud.conf"
+
+	// DefAzureCloudConfigFilename is the cloud config for Azure in the control plane nodes
+	DefAzureCloudConfigFilename = "/etc/kubernetes/azure.json"
+
+	// CloudProviderManifests are the manifests for the cloud controller managers of
+	// the out-of-tree providers (the generic manifest is used for the other providers)
+	CloudProviderManifests = map[string]string{
+		"aws":       assets.CloudProviderAWSCode,
+		"openstack": assets.CloudProviderOpenStackCode,
+		"vsphere":   assets.CloudProviderVSphereCode,
+		"azure":     assets.CloudProviderAzureCode,
+	}
+
+	// DefCloudControllerManagerVersions is the version of the cloud controller
+	// manager deployed by default for the out-of-tree providers
+	DefCloudControllerManagerVersions = map[string]string{
+		"aws":       "v1.28.3",
+		"openstack": "v1.28.1",
+		"vsphere":   "v1.28.0",
+		"azure":     "v1.28.5",
+	}
+
+	// manifest for the vSphere CSI driver (the configuration must be in a
+	// `vsphere-config-secret` secret in the `vmware-system-csi` namespace)
+	DefVSphereCSIManifest  = "https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v3.1.2/manifests/vanilla/vsphere-csi-driver.yaml"
+	DefVSphereCSINamespace = "vmware-system-csi"
+
+	// chart for the OpenStack Cinder CSI driver
+	DefCinderCSIChartRepo    = "https://kubernetes.github.io/cloud-provider-openstack"
+	DefCinderCSIChartVersion = "2.28.1"
 )
 
 func init() {
@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// DefCriDockerdSocket is the CRI socket of cri-dockerd
+	DefCriDockerdSocket = "/var/run/cri-dockerd.sock"
+)
+
+var (
+	// CriDockerdMinVersion is the first Kubernetes version without the dockershim,
+	// where the docker runtime engine must be used through cri-dockerd
+	CriDockerdMinVersion = version.MustParseGeneric("v1.24.0")
+)
+
+// NeedsCriDockerd returns true when the runtime `engine` needs cri-dockerd
+// for the Kubernetes version provided (an empty version means the default one)
+func NeedsCriDockerd(engine string, kubeVersion string) bool {
+	if engine != "docker" {
+		return false
+	}
+	if len(kubeVersion) == 0 {
+		kubeVersion = DefKubernetesVersion
+	}
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return false
+	}
+	return !v.LessThan(CriDockerdMinVersion)
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNeedsCriDockerd(t *testing.T) {
+	tests := []struct {
+		engine   string
+		version  string
+		expected bool
+	}{
+		{"docker", "", false},
+		{"docker", "v1.23.17", false},
+		{"docker", "v1.24.0", true},
+		{"docker", "1.28.2", true},
+		{"containerd", "v1.28.2", false},
+		{"docker", "latest", false},
+	}
+	for _, test := range tests {
+		if res := NeedsCriDockerd(test.engine, test.version); res != test.expected {
+			t.Fatalf("Error: unexpected result for %s %q: %t", test.engine, test.version, res)
+		}
+	}
+}
+
+func TestJoinConfigCriDockerd(t *testing.T) {
+	spec := ClusterSpec{
+		Version: "v1.26.3",
+		Token:   "82eb2m.999999idy9l74yha",
+		Runtime: RuntimeSpec{Engine: "docker"},
+	}
+
+	joinConfig, err := NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if joinConfig.NodeRegistration.CRISocket != DefCriDockerdSocket {
+		t.Fatalf("Error: unexpected CRI socket: %q", joinConfig.NodeRegistration.CRISocket)
+	}
+	args := joinConfig.NodeRegistration.KubeletExtraArgs
+	if args["container-runtime-endpoint"] != "unix://"+DefCriDockerdSocket || args["container-runtime"] != "remote" {
+		t.Fatalf("Error: unexpected kubelet args: %v", args)
+	}
+
+	// the CRI socket provided by the user takes precedence
+	spec.Runtime.CRISocket = "/run/my-cri-dockerd.sock"
+	joinConfig, err = NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if joinConfig.NodeRegistration.CRISocket != spec.Runtime.CRISocket {
+		t.Fatalf("Error: unexpected CRI socket: %q", joinConfig.NodeRegistration.CRISocket)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// prefix of the username of the kubelets
+	nodeUserPrefix = "system:node:"
+
+	// group of the kubelets
+	nodesGroup = "system:nodes"
+)
+
+var (
+	// KubeletServingCSRUsages are the usages requested by the kubelets for their serving certificates
+	KubeletServingCSRUsages = []certificatesv1beta1.KeyUsage{
+		certificatesv1beta1.UsageDigitalSignature,
+		certificatesv1beta1.UsageKeyEncipherment,
+		certificatesv1beta1.UsageServerAuth,
+	}
+)
+
+// IsPendingCSR returns true when a CSR has not been approved or denied yet
+func IsPendingCSR(csr *certificatesv1beta1.CertificateSigningRequest) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificatesv1beta1.CertificateApproved || c.Type == certificatesv1beta1.CertificateDenied {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCSRNodename returns the name of the node that created a CSR (or an empty
+// string when it has not been created by a kubelet)
+func GetCSRNodename(csr *certificatesv1beta1.CertificateSigningRequest) string {
+	if !strings.HasPrefix(csr.Spec.Username, nodeUserPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(csr.Spec.Username, nodeUserPrefix)
+}
+
+// CheckKubeletServingCSR checks that a CSR has been created by the kubelet in a node for
+// its serving certificate, and that it only requests the names and addresses of that node
+func CheckKubeletServingCSR(csr *certificatesv1beta1.CertificateSigningRequest, node *corev1.Node) error {
+	if GetCSRNodename(csr) != node.Name {
+		return fmt.Errorf("CSR %q has not been created by node %q", csr.Name, node.Name)
+	}
+
+	inGroup := false
+	for _, g := range csr.Spec.Groups {
+		if g == nodesGroup {
+			inGroup = true
+		}
+	}
+	if !inGroup {
+		return fmt.Errorf("CSR %q has not been created by a member of %s", csr.Name, nodesGroup)
+	}
+
+	hasServerAuth := false
+	for _, usage := range csr.Spec.Usages {
+		allowed := false
+		for _, u := range KubeletServingCSRUsages {
+			if usage == u {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("CSR %q requests an unexpected usage %q", csr.Name, usage)
+		}
+		if usage == certificatesv1beta1.UsageServerAuth {
+			hasServerAuth = true
+		}
+	}
+	if !hasServerAuth {
+		return fmt.Errorf("CSR %q does not request a serving certificate", csr.Name)
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return errors.New("could not decode the certificate request")
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if req.Subject.CommonName != csr.Spec.Username {
+		return fmt.Errorf("CSR %q requests an unexpected common name %q", csr.Name, req.Subject.CommonName)
+	}
+	if len(req.Subject.Organization) != 1 || req.Subject.Organization[0] != nodesGroup {
+		return fmt.Errorf("CSR %q requests unexpected organizations %v", csr.Name, req.Subject.Organization)
+	}
+	if len(req.EmailAddresses) > 0 || len(req.URIs) > 0 {
+		return fmt.Errorf("CSR %q requests unexpected email or URI SANs", csr.Name)
+	}
+
+	// all the names and addresses must be in the node status
+	addresses := map[string]bool{node.Name: true}
+	for _, a := range node.Status.Addresses {
+		addresses[a.Address] = true
+	}
+	for _, name := range req.DNSNames {
+		if !addresses[name] {
+			return fmt.Errorf("CSR %q requests a name %q that does not belong to the node", csr.Name, name)
+		}
+	}
+	for _, ip := range req.IPAddresses {
+		found := false
+		for a := range addresses {
+			if aIP := net.ParseIP(a); aIP != nil && aIP.Equal(ip) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("CSR %q requests an IP %s that does not belong to the node", csr.Name, ip)
+		}
+	}
+	return nil
+}
+
+// GetPendingKubeletServingCSRs returns the pending CSRs created by the kubelet in a node
+// for its serving certificate
+func GetPendingKubeletServingCSRs(csrs []certificatesv1beta1.CertificateSigningRequest, node *corev1.Node) []certificatesv1beta1.CertificateSigningRequest {
+	res := []certificatesv1beta1.CertificateSigningRequest{}
+	for i := range csrs {
+		csr := &csrs[i]
+		if !IsPendingCSR(csr) || GetCSRNodename(csr) != node.Name {
+			continue
+		}
+		if err := CheckKubeletServingCSR(csr, node); err != nil {
+			ssh.Debug("CSR %q will not be approved: %s", csr.Name, err)
+			continue
+		}
+		res = append(res, *csr)
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCSR(t *testing.T, name string, cn string, dnsNames []string, ips []string, usages []certificatesv1beta1.KeyUsage) certificatesv1beta1.CertificateSigningRequest {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: cn, Organization: []string{"system:nodes"}},
+		DNSNames: dnsNames,
+	}
+	for _, ip := range ips {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	return certificatesv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+			Request:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}),
+			Username: cn,
+			Groups:   []string{"system:nodes", "system:authenticated"},
+			Usages:   usages,
+		},
+	}
+}
+
+func TestKubeletServingCSRs(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "worker-0"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.5"},
+			},
+		},
+	}
+
+	approved := newTestCSR(t, "csr-approved", "system:node:worker-0", []string{"worker-0"}, []string{"10.0.0.5"}, KubeletServingCSRUsages)
+	approved.Status.Conditions = []certificatesv1beta1.CertificateSigningRequestCondition{{Type: certificatesv1beta1.CertificateApproved}}
+
+	csrs := []certificatesv1beta1.CertificateSigningRequest{
+		newTestCSR(t, "csr-valid", "system:node:worker-0", []string{"worker-0"}, []string{"10.0.0.5"}, KubeletServingCSRUsages),
+		approved,
+		newTestCSR(t, "csr-other-node", "system:node:worker-1", []string{"worker-1"}, nil, KubeletServingCSRUsages),
+		newTestCSR(t, "csr-client", "system:node:worker-0", nil, nil,
+			[]certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageClientAuth}),
+		newTestCSR(t, "csr-foreign-ip", "system:node:worker-0", []string{"worker-0"}, []string{"10.0.0.99"}, KubeletServingCSRUsages),
+		newTestCSR(t, "csr-foreign-name", "system:node:worker-0", []string{"kubernetes.default"}, nil, KubeletServingCSRUsages),
+	}
+
+	pending := GetPendingKubeletServingCSRs(csrs, node)
+	if len(pending) != 1 || pending[0].Name != "csr-valid" {
+		t.Fatalf("Error: unexpected pending CSRs: %v", pending)
+	}
+
+	// the CN must match the requestor
+	csr := newTestCSR(t, "csr-cn", "system:node:worker-1", []string{"worker-0"}, nil, KubeletServingCSRUsages)
+	csr.Spec.Username = "system:node:worker-0"
+	if err := CheckKubeletServingCSR(&csr, node); err == nil {
+		t.Fatalf("Error: a CSR with a different CN should not be valid")
+	}
+}
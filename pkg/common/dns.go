@@ -0,0 +1,79 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DNSForwardZoneSpec describes a DNS zone that is resolved by some specific
+// servers (instead of the upstream ones)
+type DNSForwardZoneSpec struct {
+	// the zone (ie, "corp.example.com")
+	Zone string `json:"zone"`
+
+	// the servers for this zone (ie, "10.0.0.53" or "10.0.0.53:5353")
+	Servers []string `json:"servers"`
+}
+
+// NewResolvConf returns the contents of a resolv.conf file with some nameservers
+// and (optionally) some search domains
+func NewResolvConf(servers []string, search []string) []byte {
+	b := strings.Builder{}
+	for _, server := range servers {
+		b.WriteString(fmt.Sprintf("nameserver %s\n", server))
+	}
+	if len(search) > 0 {
+		b.WriteString(fmt.Sprintf("search %s\n", strings.Join(search, " ")))
+	}
+	return []byte(b.String())
+}
+
+// NewCorefileForwardZones returns the CoreDNS server blocks for some forward zones
+func NewCorefileForwardZones(zones []DNSForwardZoneSpec) string {
+	b := strings.Builder{}
+	for _, zone := range zones {
+		b.WriteString(fmt.Sprintf("%s:53 {\n", zone.Zone))
+		b.WriteString("    errors\n")
+		b.WriteString("    cache 30\n")
+		b.WriteString(fmt.Sprintf("    forward . %s\n", strings.Join(zone.Servers, " ")))
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// DNSForwardZonesToTerraformSafeString serializes a list of forward zones for the provisioner
+func DNSForwardZonesToTerraformSafeString(zones []DNSForwardZoneSpec) (string, error) {
+	data, err := json.Marshal(zones)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// DNSForwardZonesFromTerraformSafeString deserializes a list of forward zones
+func DNSForwardZonesFromTerraformSafeString(s string) ([]DNSForwardZoneSpec, error) {
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		return nil, err
+	}
+	zones := []DNSForwardZoneSpec{}
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNewResolvConf(t *testing.T) {
+	if s := string(NewResolvConf([]string{"8.8.8.8"}, nil)); s != "nameserver 8.8.8.8\n" {
+		t.Fatalf("Error: unexpected resolv.conf: %q", s)
+	}
+	if s := string(NewResolvConf([]string{"8.8.8.8", "8.8.4.4"}, []string{"a.com", "b.com"})); s != "nameserver 8.8.8.8\nnameserver 8.8.4.4\nsearch a.com b.com\n" {
+		t.Fatalf("Error: unexpected resolv.conf: %q", s)
+	}
+}
+
+func TestDNSForwardZones(t *testing.T) {
+	zones := []DNSForwardZoneSpec{
+		{Zone: "corp.example.com", Servers: []string{"10.0.0.53", "10.0.0.54:5353"}},
+	}
+
+	s, err := DNSForwardZonesToTerraformSafeString(zones)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	decoded, err := DNSForwardZonesFromTerraformSafeString(s)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(decoded) != 1 || decoded[0].Zone != "corp.example.com" || len(decoded[0].Servers) != 2 {
+		t.Fatalf("Error: unexpected zones: %+v", decoded)
+	}
+
+	expected := "corp.example.com:53 {\n    errors\n    cache 30\n    forward . 10.0.0.53 10.0.0.54:5353\n}\n"
+	if corefile := NewCorefileForwardZones(decoded); corefile != expected {
+		t.Fatalf("Error: unexpected Corefile:\n%s", corefile)
+	}
+}
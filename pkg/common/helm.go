@@ -0,0 +1,60 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+)
+
+// HelmChartSpec describes a chart installed with Helm after creating the cluster
+type HelmChartSpec struct {
+	// name of the release
+	Name string `json:"name"`
+
+	// chart (ie, "ingress-nginx", "stable/mysql" or a URL), from a repository (optional)
+	Chart string `json:"chart"`
+	Repo  string `json:"repo,omitempty"`
+
+	// version of the chart (the latest one when empty)
+	Version string `json:"version,omitempty"`
+
+	// namespace for the release (it will be created when it does not exist)
+	Namespace string `json:"namespace,omitempty"`
+
+	// values (in YAML)
+	Values string `json:"values,omitempty"`
+}
+
+// HelmChartsToTerraformSafeString serializes a list of charts for the provisioner
+func HelmChartsToTerraformSafeString(charts []HelmChartSpec) (string, error) {
+	data, err := json.Marshal(charts)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// HelmChartsFromTerraformSafeString deserializes a list of charts
+func HelmChartsFromTerraformSafeString(s string) ([]HelmChartSpec, error) {
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		return nil, err
+	}
+	charts := []HelmChartSpec{}
+	if err := json.Unmarshal(data, &charts); err != nil {
+		return nil, err
+	}
+	return charts, nil
+}
@@ -0,0 +1,109 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+const (
+	// DefKonnectivityDir is the directory (in the control plane nodes) with the
+	// egress selector configuration and the socket of the konnectivity-server
+	DefKonnectivityDir = "/etc/kubernetes/konnectivity-server"
+
+	// DefEgressSelectorConfigPath is the path of the egress selector configuration for the API server
+	DefEgressSelectorConfigPath = DefKonnectivityDir + "/egress-selector-configuration.yaml"
+
+	// DefKonnectivitySocketPath is the UNIX socket where the konnectivity-server listens for the API server
+	DefKonnectivitySocketPath = DefKonnectivityDir + "/konnectivity-server.socket"
+
+	// DefKonnectivityKubeconfigPath is the kubeconfig used by the konnectivity-server
+	DefKonnectivityKubeconfigPath = "/etc/kubernetes/konnectivity-server.conf"
+
+	// DefKonnectivityVersion is the version of the konnectivity-server/agent images
+	DefKonnectivityVersion = "v0.0.37"
+
+	// DefKonnectivityImagesRepo is the repository for the konnectivity-server/agent images
+	DefKonnectivityImagesRepo = "registry.k8s.io/kas-network-proxy"
+
+	// DefKonnectivityAgentPort is the port where the konnectivity-server listens for the agents
+	DefKonnectivityAgentPort = 8132
+)
+
+var (
+	// KonnectivityMinVersion is the first Kubernetes version with the egress selector in the API server
+	KonnectivityMinVersion = version.MustParseGeneric("v1.18.0")
+
+	// the egress selector configuration is v1beta1 from this version on
+	egressSelectorBetaVersion = version.MustParseGeneric("v1.20.0")
+)
+
+// egressSelectorConfigFormat is the egress selector configuration for the API server, sending the
+// traffic to the cluster (ie, to the kubelets) through the konnectivity-server
+const egressSelectorConfigFormat = `apiVersion: apiserver.k8s.io/%s
+kind: EgressSelectorConfiguration
+egressSelections:
+  - name: cluster
+    connection:
+      proxyProtocol: GRPC
+      transport:
+        uds:
+          udsName: %s
+`
+
+// CheckKonnectivityVersion checks that a Kubernetes version supports the konnectivity service
+func CheckKonnectivityVersion(kubeVersion string) error {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return err
+	}
+	if v.LessThan(KonnectivityMinVersion) {
+		return fmt.Errorf("the konnectivity service requires Kubernetes %s or later (got %s)", KonnectivityMinVersion, kubeVersion)
+	}
+	return nil
+}
+
+// NewEgressSelectorConfig returns the egress selector configuration for the API server
+func NewEgressSelectorConfig(kubeVersion string) (string, error) {
+	if err := CheckKonnectivityVersion(kubeVersion); err != nil {
+		return "", err
+	}
+	apiVersion := "v1beta1"
+	if version.MustParseGeneric(kubeVersion).LessThan(egressSelectorBetaVersion) {
+		apiVersion = "v1alpha1"
+	}
+	return fmt.Sprintf(egressSelectorConfigFormat, apiVersion, DefKonnectivitySocketPath), nil
+}
+
+// setEgressSelector makes the API server use the konnectivity-server for reaching the cluster
+func setEgressSelector(component *kubeadmapi.ControlPlaneComponent) {
+	// the socket is created by the konnectivity-server, so the directory must be writable
+	component.ExtraVolumes = append(component.ExtraVolumes, kubeadmapi.HostPathMount{
+		Name:      "konnectivity-uds",
+		HostPath:  DefKonnectivityDir,
+		MountPath: DefKonnectivityDir,
+		PathType:  corev1.HostPathDirectoryOrCreate,
+	})
+	if component.ExtraArgs == nil {
+		component.ExtraArgs = map[string]string{}
+	}
+	if _, ok := component.ExtraArgs["egress-selector-config-file"]; !ok {
+		component.ExtraArgs["egress-selector-config-file"] = DefEgressSelectorConfigPath
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewEgressSelectorConfig(t *testing.T) {
+	if _, err := NewEgressSelectorConfig("v1.17.4"); err == nil {
+		t.Fatalf("Error: no error for a version without the egress selector")
+	}
+
+	tests := map[string]string{
+		"v1.18.0": "apiserver.k8s.io/v1alpha1",
+		"v1.19.9": "apiserver.k8s.io/v1alpha1",
+		"v1.20.0": "apiserver.k8s.io/v1beta1",
+		"v1.28.1": "apiserver.k8s.io/v1beta1",
+	}
+	for kubeVersion, apiVersion := range tests {
+		config, err := NewEgressSelectorConfig(kubeVersion)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if !strings.HasPrefix(config, "apiVersion: "+apiVersion+"\n") {
+			t.Fatalf("Error: unexpected egress selector configuration for %s:\n%s", kubeVersion, config)
+		}
+		if !strings.Contains(config, "udsName: "+DefKonnectivitySocketPath) {
+			t.Fatalf("Error: no socket in the egress selector configuration:\n%s", config)
+		}
+	}
+}
+
+func TestInitConfigKonnectivity(t *testing.T) {
+	initConfig, err := NewInitConfig(ClusterSpec{Version: "v1.27.3", Konnectivity: true})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if f := initConfig.APIServer.ExtraArgs["egress-selector-config-file"]; f != DefEgressSelectorConfigPath {
+		t.Fatalf("Error: unexpected egress selector config file: %q", f)
+	}
+	if len(initConfig.APIServer.ExtraVolumes) != 1 || initConfig.APIServer.ExtraVolumes[0].HostPath != DefKonnectivityDir || initConfig.APIServer.ExtraVolumes[0].ReadOnly {
+		t.Fatalf("Error: unexpected volumes: %+v", initConfig.APIServer.ExtraVolumes)
+	}
+}
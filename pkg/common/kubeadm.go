@@ -22,6 +22,7 @@ import (
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmscheme "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/scheme"
 	kubeadmapiv1beta1 "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1beta1"
+	"k8s.io/kubernetes/cmd/kubeadm/app/componentconfigs"
 	kubeadmutil "k8s.io/kubernetes/cmd/kubeadm/app/util"
 	"k8s.io/kubernetes/cmd/kubeadm/app/util/config"
 )
@@ -49,6 +50,7 @@ var (
 func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error) {
 	var initConfig *kubeadmapi.InitConfiguration
 	var clusterConfig *kubeadmapi.ClusterConfiguration
+	componentConfigs := map[componentconfigs.RegistrationKind][]byte{}
 
 	objects, err := kubeadmutil.SplitYAMLDocuments(configBytes)
 	if err != nil {
@@ -79,6 +81,9 @@ func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error)
 			}
 
 			clusterConfig = cfg2
+		} else if _, ok := componentconfigs.Known[componentconfigs.RegistrationKind(k.Kind)]; ok {
+			// (ie, a KubeletConfiguration)
+			componentConfigs[componentconfigs.RegistrationKind(k.Kind)] = v
 		}
 	}
 
@@ -86,6 +91,19 @@ func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error)
 		initConfig.ClusterConfiguration = *clusterConfig
 	}
 
+	if initConfig != nil {
+		for kind, v := range componentConfigs {
+			registration := componentconfigs.Known[kind]
+			obj, err := registration.Unmarshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if !registration.SetToInternalConfig(obj, &initConfig.ClusterConfiguration) {
+				return nil, fmt.Errorf("could not load the %s", kind)
+			}
+		}
+	}
+
 	return initConfig, nil
 }
 
@@ -245,3 +263,70 @@ func JoinConfigToResourceData(d *schema.ResourceData, joinConfig *kubeadmapi.Joi
 	}
 	return nil
 }
+
+// SetTokenInConfig sets the bootstrap token in the `token`, `init` and `join`
+// in the provisioner config (or removes it, when the token is empty)
+func SetTokenInConfig(config map[string]interface{}, token string) error {
+	oldToken, _ := config["token"].(string)
+	config["token"] = token
+
+	if cfg, ok := config["init"]; ok {
+		configBytes, err := FromTerraformSafeString(cfg.(string))
+		if err != nil {
+			return err
+		}
+		initConfig, err := YAMLToInitConfig(configBytes)
+		if err != nil {
+			return err
+		}
+
+		// replace the previous token, keeping the additional bootstrap tokens
+		tokens := []kubeadmapi.BootstrapToken{}
+		if len(token) > 0 {
+			t, err := NewBootstrapToken(token)
+			if err != nil {
+				return err
+			}
+			t.Expires = nil
+			t.Description = TokenDescription
+			tokens = append(tokens, t)
+		}
+		for _, t := range initConfig.BootstrapTokens {
+			if t.Token != nil && t.Token.String() == oldToken {
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+		initConfig.BootstrapTokens = tokens
+
+		configBytes, err = InitConfigToYAML(initConfig)
+		if err != nil {
+			return err
+		}
+		config["init"] = ToTerraformSafeString(configBytes)
+	}
+
+	if cfg, ok := config["join"]; ok {
+		configBytes, err := FromTerraformSafeString(cfg.(string))
+		if err != nil {
+			return err
+		}
+		joinConfig, err := YAMLToJoinConfig(configBytes)
+		if err != nil {
+			return err
+		}
+
+		if joinConfig.Discovery.BootstrapToken != nil {
+			joinConfig.Discovery.BootstrapToken.Token = token
+		}
+		joinConfig.Discovery.TLSBootstrapToken = token
+
+		configBytes, err = JoinConfigToYAML(joinConfig)
+		if err != nil {
+			return err
+		}
+		config["join"] = ToTerraformSafeString(configBytes)
+	}
+
+	return nil
+}
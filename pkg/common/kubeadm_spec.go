@@ -0,0 +1,671 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	"k8s.io/kubernetes/cmd/kubeadm/app/componentconfigs"
+	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// ClusterSpec is a plain description of a cluster, used for generating
+// the kubeadm init/join configurations without a Terraform runtime
+type ClusterSpec struct {
+	// Kubernetes version (ie, "v1.15.0")
+	Version string
+
+	// name of the cluster (empty for the kubeadm default)
+	ClusterName string
+
+	// bootstrap token used for joining the cluster
+	Token string
+
+	// additional bootstrap tokens (with their own TTLs, usages and groups)
+	BootstrapTokens []BootstrapTokenSpec
+
+	API     APISpec
+	Network NetworkSpec
+	Images  ImagesSpec
+	Runtime RuntimeSpec
+	CNI     CNISpec
+
+	// cloud provider (empty if no cloud provider is used)
+	CloudProvider string
+
+	// path of the cloud config in the control plane nodes (empty if there is no cloud config)
+	CloudConfigPath string
+
+	// typed settings for the controller manager
+	ControllerManager ControllerManagerSpec
+
+	// path of the scheduler configuration in the control plane nodes (empty if not used)
+	SchedulerConfigPath string
+
+	// audit backends of the API server
+	Audit AuditSpec
+
+	// the API server reaches the cluster through the konnectivity-server
+	Konnectivity bool
+
+	// path of the admission configuration (for the Pod Security admission) in the control plane nodes (empty if not used)
+	AdmissionConfigPath string
+
+	// endpoints for an external etcd cluster
+	EtcdEndpoints []string
+
+	// settings for the local (stacked) etcd
+	EtcdLocal LocalEtcdSpec
+
+	// expose the control plane metrics in all the interfaces
+	ExposeControlPlaneMetrics bool
+
+	// the kubelets request serving certificates signed by the cluster CA (instead of self-signed ones)
+	KubeletServingCerts bool
+
+	// directory for the kubelet data (empty for the default one)
+	KubeletRootDir string
+
+	// eviction thresholds, images garbage collection and logs rotation in the kubelets
+	KubeletGC KubeletGCSpec
+}
+
+// KubeletGCSpec describes the eviction thresholds and the garbage collection
+// settings of the kubelets (empty/zero values for the kubelet defaults)
+type KubeletGCSpec struct {
+	// hard and soft eviction thresholds (ie, "nodefs.available" -> "10%")
+	EvictionHard map[string]string
+	EvictionSoft map[string]string
+
+	// grace periods for the soft eviction thresholds (ie, "nodefs.available" -> "1m30s")
+	EvictionSoftGracePeriod map[string]string
+
+	// disk usage (in percent) that triggers the images garbage collection...
+	ImageGCHighThresholdPercent int
+
+	// ... and the usage it tries to free to
+	ImageGCLowThresholdPercent int
+
+	// maximum size of a container log file before it is rotated (ie, "10Mi")
+	ContainerLogMaxSize string
+
+	// maximum number of log files for a container
+	ContainerLogMaxFiles int
+}
+
+// IsEmpty returns true when no setting has been provided
+func (s KubeletGCSpec) IsEmpty() bool {
+	return len(s.EvictionHard) == 0 && len(s.EvictionSoft) == 0 && len(s.EvictionSoftGracePeriod) == 0 &&
+		s.ImageGCHighThresholdPercent == 0 && s.ImageGCLowThresholdPercent == 0 &&
+		len(s.ContainerLogMaxSize) == 0 && s.ContainerLogMaxFiles == 0
+}
+
+// BootstrapTokenSpec describes an additional bootstrap token
+type BootstrapTokenSpec struct {
+	Token       string
+	Description string
+
+	// time to live for the token (zero for a token that never expires)
+	TTL time.Duration
+
+	// usages and extra groups (the kubeadm defaults are used when empty)
+	Usages []string
+	Groups []string
+}
+
+// APISpec describes the API server
+type APISpec struct {
+	// external address (with or without port)
+	External string
+
+	// internal address, as "host:port"
+	Internal string
+
+	// additional names for the API server certificate
+	AltNames []string
+
+	// add the external host and the API service FQDN to the API server certificate
+	AutoSANs bool
+}
+
+// NetworkSpec describes the cluster network
+type NetworkSpec struct {
+	Pods        string
+	Services    string
+	DNSDomain   string
+	DNSUpstream []string
+
+	// address of the NodeLocal DNSCache (empty if not used)
+	NodeLocalDNS string
+}
+
+// ImagesSpec describes the images used in the cluster
+type ImagesSpec struct {
+	KubeRepo       string
+	EtcdRepo       string
+	EtcdVersion    string
+	CoreDNSRepo    string
+	CoreDNSVersion string
+}
+
+// LocalEtcdSpec describes the local (stacked) etcd running in the control plane nodes
+type LocalEtcdSpec struct {
+	// data directory (empty for the kubeadm default)
+	DataDir string
+
+	// extra args for etcd (ie, "quota-backend-bytes" or "heartbeat-interval")
+	ExtraArgs map[string]string
+
+	// extra SANs for the etcd server and peer certificates
+	ServerCertSANs []string
+	PeerCertSANs   []string
+}
+
+// IsEmpty returns true when no setting has been provided for the local etcd
+func (s LocalEtcdSpec) IsEmpty() bool {
+	return len(s.DataDir) == 0 && len(s.ExtraArgs) == 0 && len(s.ServerCertSANs) == 0 && len(s.PeerCertSANs) == 0
+}
+
+// RuntimeSpec describes the runtime engine and the extra args for the components
+type RuntimeSpec struct {
+	// runtime engine: docker, containerd or crio (empty for not setting any CRI socket)
+	Engine string
+
+	// cgroup driver (systemd or cgroupfs) used by the kubelet and the runtime (empty for the engine's default)
+	CgroupDriver string
+
+	// CRI socket, overriding the engine's default (and the socket detected in the nodes)
+	CRISocket string
+
+	APIServerArgs         map[string]string
+	ControllerManagerArgs map[string]string
+	SchedulerArgs         map[string]string
+	KubeletArgs           map[string]string
+}
+
+// ControllerManagerSpec describes some networking and signing settings of the controller manager
+type ControllerManagerSpec struct {
+	// mask size for the CIDRs allocated to the nodes (zero for the default)
+	NodeCIDRMaskSize int
+
+	// do not allocate CIDRs for the nodes (ie, when the CNI plugin does its own IPAM)
+	DisableNodeCIDRAllocation bool
+
+	// cert/key used for signing the cluster-scoped certificates (empty for the cluster CA)
+	SigningCertFile string
+	SigningKeyFile  string
+
+	// number of terminated pods that can exist before they are garbage collected (zero for the default)
+	TerminatedPodGCThreshold int
+}
+
+// CNISpec describes the CNI directories
+type CNISpec struct {
+	BinDir  string
+	ConfDir string
+}
+
+// NewInitConfig creates a kubeadm init configuration from a cluster spec
+func NewInitConfig(spec ClusterSpec) (*kubeadmapi.InitConfiguration, error) {
+	ssh.Debug("creating initialization configuration...")
+
+	initConfig := &kubeadmapi.InitConfiguration{
+		ClusterConfiguration: kubeadmapi.ClusterConfiguration{
+			APIServer: kubeadmapi.APIServer{
+				CertSANs: []string{},
+			},
+			UseHyperKubeImage: true,
+		},
+	}
+
+	if len(spec.ClusterName) > 0 {
+		initConfig.ClusterName = spec.ClusterName
+	}
+
+	if len(spec.API.External) > 0 {
+		initConfig.ControlPlaneEndpoint = AddressWithPort(spec.API.External, DefAPIServerPort)
+	}
+
+	if len(spec.API.Internal) > 0 {
+		host, port, err := net.SplitHostPort(spec.API.Internal)
+		if err != nil {
+			return nil, err
+		}
+
+		initConfig.LocalAPIEndpoint.AdvertiseAddress = host
+		if port != "" {
+			i, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, err
+			}
+			initConfig.LocalAPIEndpoint.BindPort = int32(i)
+		}
+
+		initConfig.APIServer.CertSANs = append(initConfig.APIServer.CertSANs, host)
+	}
+	initConfig.APIServer.CertSANs = append(initConfig.APIServer.CertSANs, spec.API.AltNames...)
+	if spec.API.AutoSANs {
+		initConfig.APIServer.CertSANs = AppendCertSANs(initConfig.APIServer.CertSANs, getAutoCertSANs(spec)...)
+	}
+
+	initConfig.Networking.PodSubnet = spec.Network.Pods
+	initConfig.Networking.ServiceSubnet = spec.Network.Services
+
+	if len(spec.Network.DNSDomain) > 0 {
+		// validate the DNS domain... otherwise we will get an error when
+		// we run `kubeadm init`
+		r, _ := regexp.Compile(`[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*`)
+		if !r.MatchString(spec.Network.DNSDomain) {
+			return nil, fmt.Errorf("invalid DNS name '%s': a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com')", spec.Network.DNSDomain)
+		}
+		initConfig.Networking.DNSDomain = spec.Network.DNSDomain
+	}
+
+	initConfig.ImageRepository = spec.Images.KubeRepo
+	initConfig.DNS.ImageRepository = spec.Images.CoreDNSRepo
+	initConfig.DNS.ImageTag = spec.Images.CoreDNSVersion
+	if spec.Images.EtcdVersion != "" || spec.Images.EtcdRepo != "" {
+		initConfig.Etcd = kubeadmapi.Etcd{
+			Local: &kubeadmapi.LocalEtcd{
+				ImageMeta: kubeadmapi.ImageMeta{
+					ImageRepository: spec.Images.EtcdRepo,
+					ImageTag:        spec.Images.EtcdVersion,
+				},
+			},
+		}
+	}
+
+	if err := setNodeRegistration(spec, &initConfig.NodeRegistration); err != nil {
+		return nil, err
+	}
+
+	if len(spec.Runtime.APIServerArgs) > 0 {
+		initConfig.APIServer.ExtraArgs = copyArgs(spec.Runtime.APIServerArgs)
+	}
+	if len(spec.Runtime.ControllerManagerArgs) > 0 {
+		initConfig.ControllerManager.ExtraArgs = copyArgs(spec.Runtime.ControllerManagerArgs)
+	}
+	if len(spec.Runtime.SchedulerArgs) > 0 {
+		initConfig.Scheduler.ExtraArgs = copyArgs(spec.Runtime.SchedulerArgs)
+	}
+
+	setControllerManagerArgs(spec.ControllerManager, &initConfig.ControllerManager)
+
+	if spec.KubeletServingCerts {
+		enableKubeletServingCerts(initConfig)
+	}
+
+	if !spec.KubeletGC.IsEmpty() {
+		setKubeletGC(spec.KubeletGC, initConfig)
+	}
+
+	// check if we have some cloud-provider
+	// if that is the case, we use the "external" cloud provider.
+	// the provisioner will have to load a "manifest" for running this external cloud provider manager
+	if len(spec.CloudProvider) > 0 {
+		if initConfig.APIServer.ExtraArgs == nil {
+			initConfig.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.APIServer.ExtraArgs["cloud-provider"] = "external"
+
+		if initConfig.ControllerManager.ExtraArgs == nil {
+			initConfig.ControllerManager.ExtraArgs = map[string]string{}
+		}
+		initConfig.ControllerManager.ExtraArgs["cloud-provider"] = "external"
+
+		if len(spec.CloudConfigPath) > 0 {
+			mountConfigFile(&initConfig.APIServer.ControlPlaneComponent, "cloud-config", "cloud-config", spec.CloudConfigPath)
+			mountConfigFile(&initConfig.ControllerManager, "cloud-config", "cloud-config", spec.CloudConfigPath)
+		}
+	}
+
+	if len(spec.SchedulerConfigPath) > 0 {
+		mountConfigFile(&initConfig.Scheduler, "scheduler-config", "config", spec.SchedulerConfigPath)
+	}
+
+	setAuditArgs(spec.Audit, &initConfig.APIServer.ControlPlaneComponent)
+
+	if spec.Konnectivity {
+		setEgressSelector(&initConfig.APIServer.ControlPlaneComponent)
+	}
+
+	if len(spec.AdmissionConfigPath) > 0 {
+		mountConfigFile(&initConfig.APIServer.ControlPlaneComponent, "admission-config", "admission-control-config-file", spec.AdmissionConfigPath)
+		if IsPodSecurityFeatureGateRequired(spec.Version) {
+			addFeatureGate(&initConfig.APIServer.ControlPlaneComponent, "PodSecurity")
+		}
+	}
+
+	if len(spec.CNI.BinDir) > 0 {
+		initConfig.NodeRegistration.KubeletExtraArgs["cni-bin-dir"] = spec.CNI.BinDir
+	}
+	if len(spec.CNI.ConfDir) > 0 {
+		initConfig.NodeRegistration.KubeletExtraArgs["cni-conf-dir"] = spec.CNI.ConfDir
+	}
+
+	initConfig.KubernetesVersion = spec.Version
+
+	if len(spec.EtcdEndpoints) > 0 {
+		if initConfig.Etcd.External == nil {
+			initConfig.Etcd.External = &kubeadmapi.ExternalEtcd{}
+		}
+		initConfig.Etcd.External.Endpoints = spec.EtcdEndpoints
+	}
+
+	if !spec.EtcdLocal.IsEmpty() {
+		if initConfig.Etcd.Local == nil {
+			initConfig.Etcd.Local = &kubeadmapi.LocalEtcd{}
+		}
+		initConfig.Etcd.Local.DataDir = spec.EtcdLocal.DataDir
+		if len(spec.EtcdLocal.ExtraArgs) > 0 {
+			initConfig.Etcd.Local.ExtraArgs = copyArgs(spec.EtcdLocal.ExtraArgs)
+		}
+		initConfig.Etcd.Local.ServerCertSANs = append([]string{}, spec.EtcdLocal.ServerCertSANs...)
+		initConfig.Etcd.Local.PeerCertSANs = append([]string{}, spec.EtcdLocal.PeerCertSANs...)
+	}
+
+	if spec.ExposeControlPlaneMetrics {
+		exposeControlPlaneMetrics(initConfig)
+	}
+
+	if len(spec.Token) > 0 {
+		t, err := NewBootstrapToken(spec.Token)
+		if err != nil {
+			return nil, err
+		}
+		t.Expires = nil
+		t.Description = TokenDescription
+		initConfig.BootstrapTokens = []kubeadmapi.BootstrapToken{t}
+	}
+	for _, tokenSpec := range spec.BootstrapTokens {
+		t, err := NewBootstrapTokenFromSpec(tokenSpec)
+		if err != nil {
+			return nil, err
+		}
+		initConfig.BootstrapTokens = append(initConfig.BootstrapTokens, t)
+	}
+
+	return initConfig, nil
+}
+
+// NewJoinConfig creates a kubeadm join configuration from a cluster spec
+func NewJoinConfig(spec ClusterSpec) (*kubeadmapi.JoinConfiguration, error) {
+	joinConfig := &kubeadmapi.JoinConfiguration{
+		Discovery: kubeadmapi.Discovery{
+			BootstrapToken: &kubeadmapi.BootstrapTokenDiscovery{
+				Token:                    spec.Token,
+				UnsafeSkipCAVerification: true,
+			},
+		},
+	}
+
+	if err := setNodeRegistration(spec, &joinConfig.NodeRegistration); err != nil {
+		return nil, err
+	}
+
+	return joinConfig, nil
+}
+
+// setNodeRegistration sets the CRI socket and the kubelet args in a node registration
+func setNodeRegistration(spec ClusterSpec, nr *kubeadmapi.NodeRegistrationOptions) error {
+	// make a copy, so we do not modify the default kubelet settings
+	kubeletArgs := copyArgs(DefKubeletSettings)
+
+	if len(spec.Network.DNSUpstream) > 0 {
+		kubeletArgs["resolv-conf"] = DefResolvUpstreamConf
+	}
+
+	if len(spec.Network.NodeLocalDNS) > 0 {
+		kubeletArgs["cluster-dns"] = spec.Network.NodeLocalDNS
+	}
+
+	if len(spec.Runtime.Engine) > 0 {
+		socket, ok := DefCriSocket[spec.Runtime.Engine]
+		if !ok {
+			return fmt.Errorf("unknown runtime engine %s", spec.Runtime.Engine)
+		}
+		if NeedsCriDockerd(spec.Runtime.Engine, spec.Version) {
+			socket = DefCriDockerdSocket
+		}
+		if len(spec.Runtime.CRISocket) > 0 {
+			socket = spec.Runtime.CRISocket
+		}
+
+		ssh.Debug("setting CRI socket '%s'", socket)
+		nr.CRISocket = socket
+		kubeletArgs["container-runtime-endpoint"] = fmt.Sprintf("unix://%s", socket)
+		if socket != DefCriSocket["docker"] {
+			kubeletArgs["container-runtime"] = "remote"
+		}
+		// the setup script configures the runtime with this same cgroup driver
+		driver := spec.Runtime.CgroupDriver
+		if len(driver) == 0 {
+			driver = DefCgroupDriver[spec.Runtime.Engine]
+		}
+		kubeletArgs["cgroup-driver"] = driver
+	}
+
+	if len(spec.KubeletRootDir) > 0 {
+		kubeletArgs["root-dir"] = spec.KubeletRootDir
+	}
+
+	for k, v := range spec.Runtime.KubeletArgs {
+		kubeletArgs[k] = v
+	}
+
+	if len(spec.CloudProvider) > 0 {
+		kubeletArgs["cloud-provider"] = "external"
+	}
+
+	nr.KubeletExtraArgs = kubeletArgs
+	return nil
+}
+
+// mountConfigFile mounts a file (uploaded by the provisioner) in a control plane component
+// as the `name` volume, setting the `flag` unless it has been provided in the extra args
+func mountConfigFile(component *kubeadmapi.ControlPlaneComponent, name string, flag string, path string) {
+	component.ExtraVolumes = append(component.ExtraVolumes, kubeadmapi.HostPathMount{
+		Name:      name,
+		HostPath:  path,
+		MountPath: path,
+		ReadOnly:  true,
+		PathType:  corev1.HostPathFile,
+	})
+	if component.ExtraArgs == nil {
+		component.ExtraArgs = map[string]string{}
+	}
+	if _, ok := component.ExtraArgs[flag]; !ok {
+		component.ExtraArgs[flag] = path
+	}
+}
+
+// addFeatureGate enables a feature gate in a control plane component, keeping
+// the feature gates provided in the extra args
+func addFeatureGate(component *kubeadmapi.ControlPlaneComponent, gate string) {
+	if component.ExtraArgs == nil {
+		component.ExtraArgs = map[string]string{}
+	}
+	gates := []string{}
+	for _, g := range strings.Split(component.ExtraArgs["feature-gates"], ",") {
+		g = strings.TrimSpace(g)
+		if len(g) == 0 {
+			continue
+		}
+		if strings.SplitN(g, "=", 2)[0] == gate {
+			return // (the user has decided)
+		}
+		gates = append(gates, g)
+	}
+	component.ExtraArgs["feature-gates"] = strings.Join(append(gates, gate+"=true"), ",")
+}
+
+// getAutoCertSANs returns the names that are automatically added to the API server
+// certificate: the external host and the FQDN of the API service
+func getAutoCertSANs(spec ClusterSpec) []string {
+	res := []string{}
+	if len(spec.API.External) > 0 {
+		if host, _, err := SplitHostPort(spec.API.External, DefAPIServerPort); err == nil {
+			res = append(res, host)
+		}
+	}
+	domain := spec.Network.DNSDomain
+	if len(domain) == 0 {
+		domain = DefDNSDomain
+	}
+	return append(res, "kubernetes.default.svc."+domain)
+}
+
+// setControllerManagerArgs sets the controller manager flags for the typed settings,
+// unless they have been provided in the extra args
+func setControllerManagerArgs(spec ControllerManagerSpec, component *kubeadmapi.ControlPlaneComponent) {
+	args := map[string]string{}
+	if spec.DisableNodeCIDRAllocation {
+		args["allocate-node-cidrs"] = "false"
+	}
+	if spec.NodeCIDRMaskSize > 0 {
+		args["node-cidr-mask-size"] = strconv.Itoa(spec.NodeCIDRMaskSize)
+	}
+	if spec.TerminatedPodGCThreshold > 0 {
+		args["terminated-pod-gc-threshold"] = strconv.Itoa(spec.TerminatedPodGCThreshold)
+	}
+	if len(args) == 0 && len(spec.SigningCertFile) == 0 && len(spec.SigningKeyFile) == 0 {
+		return
+	}
+
+	if component.ExtraArgs == nil {
+		component.ExtraArgs = map[string]string{}
+	}
+	for k, v := range args {
+		if _, ok := component.ExtraArgs[k]; !ok {
+			component.ExtraArgs[k] = v
+		}
+	}
+
+	// the PKI dir is already mounted by kubeadm, so we only need to mount files in other places
+	for _, f := range []struct{ name, flag, path string }{
+		{"cluster-signing-cert", "cluster-signing-cert-file", spec.SigningCertFile},
+		{"cluster-signing-key", "cluster-signing-key-file", spec.SigningKeyFile},
+	} {
+		if len(f.path) == 0 {
+			continue
+		}
+		if strings.HasPrefix(f.path, DefPKIDir+"/") {
+			if _, ok := component.ExtraArgs[f.flag]; !ok {
+				component.ExtraArgs[f.flag] = f.path
+			}
+		} else {
+			mountConfigFile(component, f.name, f.flag, f.path)
+		}
+	}
+}
+
+// getKubeletConfig returns the kubelet configuration in the `initConfig`, creating it
+// when it is not there
+func getKubeletConfig(initConfig *kubeadmapi.InitConfiguration) *kubeletconfig.KubeletConfiguration {
+	if initConfig.ComponentConfigs.Kubelet == nil {
+		// start from the kubeadm defaults, as all the fields in the
+		// KubeletConfiguration will be written to the config file
+		componentconfigs.Known[componentconfigs.KubeletConfigurationKind].DefaulterFunc(&initConfig.ClusterConfiguration)
+		if len(initConfig.ComponentConfigs.Kubelet.ClusterDomain) == 0 {
+			initConfig.ComponentConfigs.Kubelet.ClusterDomain = DefDNSDomain
+		}
+	}
+	return initConfig.ComponentConfigs.Kubelet
+}
+
+// enableKubeletServingCerts sets `serverTLSBootstrap` in the kubelet configuration, so
+// the kubelets request their serving certificates with a CSR
+func enableKubeletServingCerts(initConfig *kubeadmapi.InitConfiguration) {
+	getKubeletConfig(initConfig).ServerTLSBootstrap = true
+}
+
+// setKubeletGC sets the eviction thresholds and the garbage collection settings
+// in the kubelet configuration (that is shared by all the kubelets in the cluster)
+func setKubeletGC(gc KubeletGCSpec, initConfig *kubeadmapi.InitConfiguration) {
+	kubelet := getKubeletConfig(initConfig)
+
+	if len(gc.EvictionHard) > 0 {
+		kubelet.EvictionHard = copyArgs(gc.EvictionHard)
+	}
+	if len(gc.EvictionSoft) > 0 {
+		kubelet.EvictionSoft = copyArgs(gc.EvictionSoft)
+	}
+	if len(gc.EvictionSoftGracePeriod) > 0 {
+		kubelet.EvictionSoftGracePeriod = copyArgs(gc.EvictionSoftGracePeriod)
+	}
+	if gc.ImageGCHighThresholdPercent > 0 {
+		kubelet.ImageGCHighThresholdPercent = int32(gc.ImageGCHighThresholdPercent)
+	}
+	if gc.ImageGCLowThresholdPercent > 0 {
+		kubelet.ImageGCLowThresholdPercent = int32(gc.ImageGCLowThresholdPercent)
+	}
+	if len(gc.ContainerLogMaxSize) > 0 {
+		kubelet.ContainerLogMaxSize = gc.ContainerLogMaxSize
+	}
+	if gc.ContainerLogMaxFiles > 0 {
+		kubelet.ContainerLogMaxFiles = int32(gc.ContainerLogMaxFiles)
+	}
+}
+
+// exposeControlPlaneMetrics changes the bind addresses of the scheduler, the controller
+// manager and etcd, so their metrics can be scraped from other machines
+func exposeControlPlaneMetrics(initConfig *kubeadmapi.InitConfiguration) {
+	ssh.Debug("exposing the control plane metrics in %s", DefMetricsBindAddress)
+
+	if initConfig.Scheduler.ExtraArgs == nil {
+		initConfig.Scheduler.ExtraArgs = map[string]string{}
+	}
+	initConfig.Scheduler.ExtraArgs["address"] = DefMetricsBindAddress
+	initConfig.Scheduler.ExtraArgs["bind-address"] = DefMetricsBindAddress
+
+	if initConfig.ControllerManager.ExtraArgs == nil {
+		initConfig.ControllerManager.ExtraArgs = map[string]string{}
+	}
+	initConfig.ControllerManager.ExtraArgs["address"] = DefMetricsBindAddress
+	initConfig.ControllerManager.ExtraArgs["bind-address"] = DefMetricsBindAddress
+
+	// nothing to do for an external etcd: it is not managed by kubeadm
+	if initConfig.Etcd.External != nil {
+		return
+	}
+	if initConfig.Etcd.Local == nil {
+		initConfig.Etcd.Local = &kubeadmapi.LocalEtcd{}
+	}
+	if initConfig.Etcd.Local.ExtraArgs == nil {
+		initConfig.Etcd.Local.ExtraArgs = map[string]string{}
+	}
+	initConfig.Etcd.Local.ExtraArgs["listen-metrics-urls"] = fmt.Sprintf("http://%s:%d", DefMetricsBindAddress, DefEtcdMetricsPort)
+
+	// the etcd server certificate should be valid for the same names as the API server,
+	// so etcd can be also scraped (with TLS) at the addresses used for the API server
+	initConfig.Etcd.Local.ServerCertSANs = append(initConfig.Etcd.Local.ServerCertSANs, initConfig.APIServer.CertSANs...)
+}
+
+func copyArgs(args map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range args {
+		res[k] = v
+	}
+	return res
+}
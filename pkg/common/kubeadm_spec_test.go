@@ -0,0 +1,217 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+func TestNewInitConfig(t *testing.T) {
+	spec := ClusterSpec{
+		Version: "v1.15.0",
+		Token:   "82eb2m.999999idy9l74yha",
+		API: APISpec{
+			External: "k8s.example.com",
+			Internal: "10.10.0.1:6443",
+			AltNames: []string{"api.example.com"},
+		},
+		Network: NetworkSpec{
+			Pods:         "10.244.0.0/16",
+			DNSDomain:    "my-local.cluster",
+			DNSUpstream:  []string{"8.8.8.8"},
+			NodeLocalDNS: DefNodeLocalDNSIP,
+		},
+		Runtime: RuntimeSpec{
+			Engine:        "containerd",
+			APIServerArgs: map[string]string{"feature-gates": "DynamicKubeletConfig=true"},
+		},
+		CloudProvider:       "aws",
+		CloudConfigPath:     DefCloudConfigFilename,
+		SchedulerConfigPath: DefSchedulerConfigPath,
+		KubeletRootDir:      "/mnt/kubelet",
+	}
+
+	initConfig, err := NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	if initConfig.ControlPlaneEndpoint != "k8s.example.com:6443" {
+		t.Fatalf("Error: wrong control plane endpoint: %q", initConfig.ControlPlaneEndpoint)
+	}
+	if initConfig.LocalAPIEndpoint.AdvertiseAddress != "10.10.0.1" || initConfig.LocalAPIEndpoint.BindPort != 6443 {
+		t.Fatalf("Error: wrong local API endpoint: %+v", initConfig.LocalAPIEndpoint)
+	}
+	if len(initConfig.APIServer.CertSANs) != 2 {
+		t.Fatalf("Error: wrong API server SANs: %v", initConfig.APIServer.CertSANs)
+	}
+	if initConfig.Networking.DNSDomain != "my-local.cluster" {
+		t.Fatalf("Error: wrong DNS domain: %q", initConfig.Networking.DNSDomain)
+	}
+	if initConfig.BootstrapTokens[0].Token.String() != spec.Token {
+		t.Fatalf("Error: wrong bootstrap token: %v", initConfig.BootstrapTokens[0].Token.String())
+	}
+	if initConfig.NodeRegistration.CRISocket != DefCriSocket["containerd"] {
+		t.Fatalf("Error: wrong CRI socket: %q", initConfig.NodeRegistration.CRISocket)
+	}
+
+	args := initConfig.NodeRegistration.KubeletExtraArgs
+	for k, v := range map[string]string{
+		"resolv-conf":    DefResolvUpstreamConf,
+		"cloud-provider": "external",
+		"cgroup-driver":  "systemd",
+		"root-dir":       "/mnt/kubelet",
+		"cluster-dns":    DefNodeLocalDNSIP,
+	} {
+		if args[k] != v {
+			t.Fatalf("Error: wrong kubelet arg %q: %q", k, args[k])
+		}
+	}
+	if initConfig.APIServer.ExtraArgs["feature-gates"] != "DynamicKubeletConfig=true" {
+		t.Fatalf("Error: wrong API server args: %v", initConfig.APIServer.ExtraArgs)
+	}
+	if initConfig.APIServer.ExtraArgs["cloud-provider"] != "external" {
+		t.Fatalf("Error: no cloud provider in the API server args: %v", initConfig.APIServer.ExtraArgs)
+	}
+	for _, component := range []kubeadmapi.ControlPlaneComponent{initConfig.APIServer.ControlPlaneComponent, initConfig.ControllerManager} {
+		if component.ExtraArgs["cloud-config"] != DefCloudConfigFilename {
+			t.Fatalf("Error: no cloud config in the args: %v", component.ExtraArgs)
+		}
+		if len(component.ExtraVolumes) != 1 || component.ExtraVolumes[0].HostPath != DefCloudConfigFilename {
+			t.Fatalf("Error: the cloud config has not been mounted: %+v", component.ExtraVolumes)
+		}
+	}
+	if initConfig.Scheduler.ExtraArgs["config"] != DefSchedulerConfigPath {
+		t.Fatalf("Error: no scheduler config in the args: %v", initConfig.Scheduler.ExtraArgs)
+	}
+	if len(initConfig.Scheduler.ExtraVolumes) != 1 || initConfig.Scheduler.ExtraVolumes[0].MountPath != DefSchedulerConfigPath {
+		t.Fatalf("Error: the scheduler config has not been mounted: %+v", initConfig.Scheduler.ExtraVolumes)
+	}
+
+	// the spec should not be modified
+	if _, ok := spec.Runtime.APIServerArgs["cloud-provider"]; ok {
+		t.Fatalf("Error: the spec has been modified")
+	}
+	if _, ok := DefKubeletSettings["resolv-conf"]; ok {
+		t.Fatalf("Error: the default kubelet settings have been modified")
+	}
+
+	if _, err := InitConfigToYAML(initConfig); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	spec.API.AutoSANs = true
+	initConfig, err = NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expectedSANs := []string{"10.10.0.1", "api.example.com", "k8s.example.com", "kubernetes.default.svc.my-local.cluster"}
+	if len(initConfig.APIServer.CertSANs) != len(expectedSANs) {
+		t.Fatalf("Error: wrong API server SANs: %v", initConfig.APIServer.CertSANs)
+	}
+	for i, san := range expectedSANs {
+		if initConfig.APIServer.CertSANs[i] != san {
+			t.Fatalf("Error: wrong API server SANs: %v", initConfig.APIServer.CertSANs)
+		}
+	}
+}
+
+func TestExposeControlPlaneMetrics(t *testing.T) {
+	initConfig := &kubeadmapi.InitConfiguration{}
+	exposeControlPlaneMetrics(initConfig)
+
+	for component, args := range map[string]map[string]string{
+		"scheduler":          initConfig.Scheduler.ExtraArgs,
+		"controller-manager": initConfig.ControllerManager.ExtraArgs,
+	} {
+		if args["address"] != DefMetricsBindAddress || args["bind-address"] != DefMetricsBindAddress {
+			t.Fatalf("Error: wrong addresses in the %s: %v", component, args)
+		}
+	}
+	if initConfig.Etcd.Local.ExtraArgs["listen-metrics-urls"] == "" {
+		t.Fatalf("Error: no metrics URL for etcd: %v", initConfig.Etcd.Local.ExtraArgs)
+	}
+}
+
+func TestNewInitConfigErrors(t *testing.T) {
+	specs := []ClusterSpec{
+		{API: APISpec{Internal: "10.10.0.1"}},
+		{API: APISpec{Internal: "10.10.0.1:port"}},
+		{Runtime: RuntimeSpec{Engine: "rkt"}},
+		{Token: "not-a-token"},
+	}
+	for _, spec := range specs {
+		if _, err := NewInitConfig(spec); err == nil {
+			t.Fatalf("Error: no error for %+v", spec)
+		}
+	}
+}
+
+func TestNewJoinConfig(t *testing.T) {
+	spec := ClusterSpec{
+		Token: "82eb2m.999999idy9l74yha",
+		Runtime: RuntimeSpec{
+			Engine:      "docker",
+			KubeletArgs: map[string]string{"max-pods": "50"},
+		},
+	}
+
+	joinConfig, err := NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if joinConfig.Discovery.BootstrapToken.Token != spec.Token {
+		t.Fatalf("Error: wrong discovery token: %q", joinConfig.Discovery.BootstrapToken.Token)
+	}
+
+	args := joinConfig.NodeRegistration.KubeletExtraArgs
+	if args["max-pods"] != "50" || args["network-plugin"] != "cni" {
+		t.Fatalf("Error: wrong kubelet args: %v", args)
+	}
+	if _, ok := args["container-runtime"]; ok {
+		t.Fatalf("Error: unexpected container runtime for docker: %v", args)
+	}
+	if args["cgroup-driver"] != "cgroupfs" {
+		t.Fatalf("Error: wrong default cgroup driver for docker: %v", args)
+	}
+
+	spec.Runtime.CgroupDriver = "systemd"
+	joinConfig, err = NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if driver := joinConfig.NodeRegistration.KubeletExtraArgs["cgroup-driver"]; driver != "systemd" {
+		t.Fatalf("Error: wrong cgroup driver: %q", driver)
+	}
+
+	spec.Runtime.CRISocket = "/var/run/cri-dockerd.sock"
+	joinConfig, err = NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if joinConfig.NodeRegistration.CRISocket != spec.Runtime.CRISocket {
+		t.Fatalf("Error: the CRI socket has not been overridden: %q", joinConfig.NodeRegistration.CRISocket)
+	}
+	args = joinConfig.NodeRegistration.KubeletExtraArgs
+	if args["container-runtime-endpoint"] != "unix:///var/run/cri-dockerd.sock" || args["container-runtime"] != "remote" {
+		t.Fatalf("Error: wrong kubelet args for the CRI socket: %v", args)
+	}
+
+	if _, err := JoinConfigToYAML(joinConfig); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+}
@@ -16,7 +16,9 @@ package common
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInitConfigSerialization(t *testing.T) {
@@ -80,6 +82,85 @@ networking:
 	}
 }
 
+func TestInitConfigKubeletServingCerts(t *testing.T) {
+	initConfig, err := NewInitConfig(ClusterSpec{
+		Network:             NetworkSpec{Services: "10.100.0.0/16"},
+		KubeletServingCerts: true,
+	})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	configContents, err := InitConfigToYAML(initConfig)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for _, expected := range []string{"kind: KubeletConfiguration", "serverTLSBootstrap: true", "clusterDomain: cluster.local", "- 10.100.0.10"} {
+		if !strings.Contains(string(configContents), expected) {
+			t.Fatalf("Error: %q not found in the configuration:\n%s", expected, configContents)
+		}
+	}
+
+	// the kubelet configuration must survive a round trip
+	initConfig, err = YAMLToInitConfig(configContents)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if initConfig.ComponentConfigs.Kubelet == nil || !initConfig.ComponentConfigs.Kubelet.ServerTLSBootstrap {
+		t.Fatalf("Error: the kubelet configuration has been lost: %+v", initConfig.ComponentConfigs.Kubelet)
+	}
+	if !initConfig.ComponentConfigs.Kubelet.EnableControllerAttachDetach {
+		t.Fatalf("Error: the kubelet configuration has not been defaulted: %+v", initConfig.ComponentConfigs.Kubelet)
+	}
+}
+
+func TestInitConfigKubeletGC(t *testing.T) {
+	initConfig, err := NewInitConfig(ClusterSpec{
+		Network: NetworkSpec{Services: "10.100.0.0/16"},
+		KubeletGC: KubeletGCSpec{
+			EvictionHard:                map[string]string{"nodefs.available": "15%", "memory.available": "200Mi"},
+			EvictionSoft:                map[string]string{"nodefs.available": "20%"},
+			EvictionSoftGracePeriod:     map[string]string{"nodefs.available": "1m30s"},
+			ImageGCHighThresholdPercent: 70,
+			ImageGCLowThresholdPercent:  60,
+			ContainerLogMaxSize:         "50Mi",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	configContents, err := InitConfigToYAML(initConfig)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	for _, expected := range []string{
+		"kind: KubeletConfiguration",
+		"imageGCHighThresholdPercent: 70",
+		"imageGCLowThresholdPercent: 60",
+		"containerLogMaxSize: 50Mi",
+		"nodefs.available: 15%",
+		"memory.available: 200Mi",
+		"nodefs.available: 1m30s",
+	} {
+		if !strings.Contains(string(configContents), expected) {
+			t.Fatalf("Error: %q not found in the configuration:\n%s", expected, configContents)
+		}
+	}
+
+	// the settings not provided keep the kubelet defaults
+	initConfig, err = YAMLToInitConfig(configContents)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if initConfig.ComponentConfigs.Kubelet.ContainerLogMaxFiles != 5 {
+		t.Fatalf("Error: unexpected max log files: %d", initConfig.ComponentConfigs.Kubelet.ContainerLogMaxFiles)
+	}
+	if initConfig.ComponentConfigs.Kubelet.EvictionSoft["nodefs.available"] != "20%" {
+		t.Fatalf("Error: the soft eviction thresholds have been lost: %v", initConfig.ComponentConfigs.Kubelet.EvictionSoft)
+	}
+}
+
 func TestJoinConfigSerialization(t *testing.T) {
 	configContents := `
 apiVersion: kubeadm.k8s.io/v1beta1
@@ -111,3 +192,71 @@ controlPlane:
 	}
 	fmt.Printf("----------------- join configuration ---------------- \n%s", configContentsAgain)
 }
+
+func TestSetTokenInConfig(t *testing.T) {
+	const token = "82eb2m.999999idy9l74yha"
+
+	const workersToken = "0a1b2c.0123456789abcdef"
+
+	spec := ClusterSpec{
+		Version: "v1.15.0",
+		Token:   token,
+		BootstrapTokens: []BootstrapTokenSpec{
+			{Token: workersToken, Description: "workers", TTL: time.Hour},
+		},
+	}
+	initConfig, err := NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	joinConfig, err := NewJoinConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	initBytes, err := InitConfigToYAML(initConfig)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	joinBytes, err := JoinConfigToYAML(joinConfig)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	config := map[string]interface{}{
+		"token": token,
+		"init":  ToTerraformSafeString(initBytes),
+		"join":  ToTerraformSafeString(joinBytes),
+	}
+
+	// remove the token...
+	if err := SetTokenInConfig(config, ""); err != nil {
+		t.Fatalf("Error: could not remove the token: %s", err)
+	}
+	for _, k := range []string{"token", "init", "join"} {
+		b, _ := FromTerraformSafeString(config[k].(string))
+		if strings.Contains(config[k].(string), token) || strings.Contains(string(b), token) {
+			t.Fatalf("Error: the token is still in %q", k)
+		}
+	}
+
+	// ... and restore it
+	if err := SetTokenInConfig(config, token); err != nil {
+		t.Fatalf("Error: could not set the token: %s", err)
+	}
+	initBytes, _ = FromTerraformSafeString(config["init"].(string))
+	initConfig, err = YAMLToInitConfig(initBytes)
+	if err != nil || initConfig.BootstrapTokens[0].Token.String() != token {
+		t.Fatalf("Error: the token was not restored in the init configuration: %v", err)
+	}
+	if len(initConfig.BootstrapTokens) != 2 || initConfig.BootstrapTokens[1].Token.String() != workersToken {
+		t.Fatalf("Error: the additional bootstrap token was not kept: %+v", initConfig.BootstrapTokens)
+	}
+	if extra := initConfig.BootstrapTokens[1]; extra.TTL.Duration != time.Hour || extra.Description != "workers" || len(extra.Groups) != 1 {
+		t.Fatalf("Error: unexpected additional bootstrap token: %+v", extra)
+	}
+	joinBytes, _ = FromTerraformSafeString(config["join"].(string))
+	joinConfig, err = YAMLToJoinConfig(joinBytes)
+	if err != nil || joinConfig.Discovery.BootstrapToken.Token != token || joinConfig.Discovery.TLSBootstrapToken != token {
+		t.Fatalf("Error: the token was not restored in the join configuration: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"k8s.io/client-go/tools/clientcmd"
+	kubeconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/kubeconfig"
+)
+
+const (
+	// DefAdminUser is the user in the admin kubeconfig
+	DefAdminUser = "kubernetes-admin"
+
+	// DefClusterName is the cluster name in the kubeconfig files
+	DefClusterName = "kubernetes"
+)
+
+// NewAdminKubeconfig creates a kubeconfig with a client certificate for the
+// admin (signed by the CA), for accessing the API server at `server`
+func NewAdminKubeconfig(caCrt []byte, caKey []byte, server string) ([]byte, error) {
+	return NewUserKubeconfig(caCrt, caKey, server, DefAdminUser, []string{"system:masters"})
+}
+
+// NewUserKubeconfig creates a kubeconfig with a client certificate for the
+// `user` in the `groups` (signed by the CA), for accessing the API server at `server`
+func NewUserKubeconfig(caCrt []byte, caKey []byte, server string, user string, groups []string) ([]byte, error) {
+	certPEM, clientKeyPEM, err := NewClientCert(caCrt, caKey, user, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	config := kubeconfigutil.CreateWithCerts(server, DefClusterName, user,
+		caCrt, clientKeyPEM, certPEM)
+	return clientcmd.Write(*config)
+}
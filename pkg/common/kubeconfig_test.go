@@ -0,0 +1,64 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestNewAdminKubeconfig(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode the CA key: %s", err)
+	}
+
+	kubeconfig, err := NewAdminKubeconfig(pkiutil.EncodeCertPEM(caCert), caKeyPEM, "https://k8s.example.com:6443")
+	if err != nil {
+		t.Fatalf("Error: could not create the kubeconfig: %s", err)
+	}
+
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		t.Fatalf("Error: could not parse the kubeconfig: %s", err)
+	}
+	if cluster, ok := config.Clusters[DefClusterName]; !ok || cluster.Server != "https://k8s.example.com:6443" {
+		t.Fatalf("Error: unexpected clusters in the kubeconfig: %+v", config.Clusters)
+	}
+	user, ok := config.AuthInfos[DefAdminUser]
+	if !ok || len(user.ClientCertificateData) == 0 || len(user.ClientKeyData) == 0 {
+		t.Fatalf("Error: no client certificate in the kubeconfig")
+	}
+
+	certs, err := certutil.ParseCertsPEM(user.ClientCertificateData)
+	if err != nil || certs[0].Subject.Organization[0] != "system:masters" {
+		t.Fatalf("Error: unexpected client certificate: %v", err)
+	}
+	if err := certs[0].CheckSignatureFrom(caCert); err != nil {
+		t.Fatalf("Error: the client certificate is not signed by the CA: %s", err)
+	}
+
+	if _, err := NewAdminKubeconfig([]byte("garbage"), caKeyPEM, "https://k8s.example.com:6443"); err == nil {
+		t.Fatalf("Error: an invalid CA should fail")
+	}
+}
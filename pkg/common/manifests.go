@@ -0,0 +1,42 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+)
+
+// ManifestsToTerraformSafeString serializes a list of manifests (URLs or
+// inline contents) for the provisioner
+func ManifestsToTerraformSafeString(manifests []string) (string, error) {
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// ManifestsFromTerraformSafeString deserializes a list of manifests
+func ManifestsFromTerraformSafeString(s string) ([]string, error) {
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		return nil, err
+	}
+	manifests := []string{}
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
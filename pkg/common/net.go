@@ -19,6 +19,8 @@ import (
 	"net"
 	"strconv"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // AddressWithPort return an address as expectedHost:expectedPort (setting a default expectedPort p if there was no expectedPort specified)
@@ -45,3 +47,43 @@ func SplitHostPort(hp string, defaultPort int) (string, int, error) {
 
 	return h, pi, nil
 }
+
+// GetClusterDNSIP returns the IP of the cluster DNS service for a services CIDR
+// (kubeadm uses the 10th address in the range)
+func GetClusterDNSIP(services string) (string, error) {
+	_, cidr, err := net.ParseCIDR(services)
+	if err != nil {
+		return "", err
+	}
+	ip := cidr.IP.To4()
+	if ip == nil {
+		ip = cidr.IP.To16()
+	}
+	res := make(net.IP, len(ip))
+	copy(res, ip)
+	res[len(res)-1] += 10
+	if !cidr.Contains(res) {
+		return "", fmt.Errorf("services range %q is too small for the cluster DNS", services)
+	}
+	return res.String(), nil
+}
+
+// AppendCertSANs appends some names to a list of certificate SANs, skipping
+// duplicates and the names that are not valid IPs or DNS names
+func AppendCertSANs(sans []string, names ...string) []string {
+	existing := map[string]bool{}
+	for _, san := range sans {
+		existing[san] = true
+	}
+	for _, name := range names {
+		if len(name) == 0 || existing[name] {
+			continue
+		}
+		if net.ParseIP(name) == nil && len(validation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*."))) > 0 {
+			continue
+		}
+		sans = append(sans, name)
+		existing[name] = true
+	}
+	return sans
+}
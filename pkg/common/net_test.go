@@ -59,3 +59,38 @@ func TestSplitHostPort(t *testing.T) {
 		}
 	}
 }
+
+func TestGetClusterDNSIP(t *testing.T) {
+	testsCases := []struct {
+		services string
+		expected string
+	}{
+		{"10.96.0.0/12", "10.96.0.10"},
+		{"172.30.16.0/20", "172.30.16.10"},
+		{"fd00:10:96::/112", "fd00:10:96::a"},
+	}
+	for _, testCase := range testsCases {
+		ip, err := GetClusterDNSIP(testCase.services)
+		if err != nil || ip != testCase.expected {
+			t.Fatalf("Error: unexpected DNS IP for %q: %q (%v)", testCase.services, ip, err)
+		}
+	}
+
+	if _, err := GetClusterDNSIP("10.96.0.0/30"); err == nil {
+		t.Fatalf("Error: a /30 should be too small")
+	}
+}
+
+func TestAppendCertSANs(t *testing.T) {
+	sans := AppendCertSANs([]string{"10.10.0.1"},
+		"10.10.0.1", "", "k8s.example.com", "node_0", "*.example.com", "k8s.example.com", "fd00::1")
+	expected := []string{"10.10.0.1", "k8s.example.com", "*.example.com", "fd00::1"}
+	if len(sans) != len(expected) {
+		t.Fatalf("Error: unexpected SANs: %v", sans)
+	}
+	for i := range expected {
+		if sans[i] != expected[i] {
+			t.Fatalf("Error: unexpected SANs: %v", sans)
+		}
+	}
+}
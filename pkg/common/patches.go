@@ -0,0 +1,208 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// DefPatchesDir is the directory (in the control plane nodes) with the
+	// patches for the static pods
+	DefPatchesDir = "/etc/kubernetes/patches"
+
+	// DefPatchType is the default type of the user-provided patches
+	DefPatchType = "strategic"
+)
+
+var (
+	// PatchTargets are the static pods that can be patched
+	PatchTargets = []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+	// PatchTypes are the types of patches supported by kubeadm
+	PatchTypes = []string{"strategic", "merge", "json"}
+
+	// PatchesMinVersion is the first Kubernetes version where kubeadm supports patches
+	PatchesMinVersion = version.MustParseGeneric("v1.19.0")
+
+	// the `--experimental-patches` flag was renamed to `--patches` in this version
+	patchesFlagVersion = version.MustParseGeneric("v1.22.0")
+)
+
+// StaticPodPatchSpec describes some modifications in a control plane static pod
+type StaticPodPatchSpec struct {
+	// Target is the static pod (ie, "kube-apiserver")
+	Target string
+
+	// Requests and Limits are the resources for the container
+	Requests map[string]string
+	Limits   map[string]string
+
+	// Env are some additional environment variables for the container
+	Env map[string]string
+
+	// PriorityClassName is the priority class for the pod
+	PriorityClassName string
+
+	// Patch is a raw patch (in YAML or JSON), of type Type
+	Patch string
+	Type  string
+}
+
+// CheckPatchesVersion checks that a Kubernetes version supports kubeadm patches
+func CheckPatchesVersion(kubeVersion string) error {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return err
+	}
+	if v.LessThan(PatchesMinVersion) {
+		return fmt.Errorf("patches for the static pods require Kubernetes %s or later (got %s)", PatchesMinVersion, kubeVersion)
+	}
+	return nil
+}
+
+// GetPatchesFlag returns the kubeadm flag for using the patches in `dir`
+func GetPatchesFlag(kubeVersion string, dir string) string {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err == nil && v.LessThan(patchesFlagVersion) {
+		return fmt.Sprintf("--experimental-patches=%s", dir)
+	}
+	return fmt.Sprintf("--patches=%s", dir)
+}
+
+// checkResources checks the resources are valid quantities
+func checkResources(resources map[string]string) error {
+	for k, v := range resources {
+		if _, err := resource.ParseQuantity(v); err != nil {
+			return fmt.Errorf("invalid quantity %q for %q: %s", v, k, err)
+		}
+	}
+	return nil
+}
+
+// newStrategicPatch returns a strategic merge patch with the resources, env
+// and priority class in a patch spec (or nil if there is nothing to patch)
+func newStrategicPatch(p StaticPodPatchSpec) (map[string]interface{}, error) {
+	if len(p.Requests) == 0 && len(p.Limits) == 0 && len(p.Env) == 0 && len(p.PriorityClassName) == 0 {
+		return nil, nil
+	}
+
+	container := map[string]interface{}{"name": p.Target}
+	if len(p.Requests) > 0 || len(p.Limits) > 0 {
+		if err := checkResources(p.Requests); err != nil {
+			return nil, err
+		}
+		if err := checkResources(p.Limits); err != nil {
+			return nil, err
+		}
+		resources := map[string]interface{}{}
+		if len(p.Requests) > 0 {
+			resources["requests"] = p.Requests
+		}
+		if len(p.Limits) > 0 {
+			resources["limits"] = p.Limits
+		}
+		container["resources"] = resources
+	}
+	if len(p.Env) > 0 {
+		names := []string{}
+		for k := range p.Env {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		env := []map[string]string{}
+		for _, k := range names {
+			env = append(env, map[string]string{"name": k, "value": p.Env[k]})
+		}
+		container["env"] = env
+	}
+
+	spec := map[string]interface{}{"containers": []interface{}{container}}
+	if len(p.PriorityClassName) > 0 {
+		spec["priorityClassName"] = p.PriorityClassName
+	}
+	return map[string]interface{}{"spec": spec}, nil
+}
+
+// NewStaticPodPatchFiles returns the files (name -> contents) that must be created
+// in the patches directory for kubeadm, named as `target[suffix][+type].json`
+func NewStaticPodPatchFiles(patches []StaticPodPatchSpec) (map[string]string, error) {
+	res := map[string]string{}
+	for i, p := range patches {
+		if !StringSliceContains(PatchTargets, p.Target) {
+			return nil, fmt.Errorf("invalid target %q for patch #%d: must be one of %v", p.Target, i, PatchTargets)
+		}
+
+		strategic, err := newStrategicPatch(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid patch #%d for %q: %s", i, p.Target, err)
+		}
+		if strategic != nil {
+			data, err := json.Marshal(strategic)
+			if err != nil {
+				return nil, err
+			}
+			res[fmt.Sprintf("%s%da+strategic.json", p.Target, i)] = string(data)
+		}
+
+		if len(p.Patch) > 0 {
+			patchType := p.Type
+			if len(patchType) == 0 {
+				patchType = DefPatchType
+			}
+			if !StringSliceContains(PatchTypes, patchType) {
+				return nil, fmt.Errorf("invalid type %q for patch #%d: must be one of %v", patchType, i, PatchTypes)
+			}
+			// (JSON is passed through as it is, so we must check it is valid)
+			data, err := yaml.ToJSON([]byte(p.Patch))
+			if err != nil {
+				return nil, fmt.Errorf("invalid patch #%d for %q: %s", i, p.Target, err)
+			}
+			if !json.Valid(data) {
+				return nil, fmt.Errorf("invalid patch #%d for %q: not valid YAML or JSON", i, p.Target)
+			}
+			res[fmt.Sprintf("%s%db+%s.json", p.Target, i, patchType)] = string(data)
+		}
+	}
+	return res, nil
+}
+
+// PatchFilesToTerraformSafeString serializes the patch files for the provisioner
+func PatchFilesToTerraformSafeString(files map[string]string) (string, error) {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// PatchFilesFromTerraformSafeString deserializes the patch files
+func PatchFilesFromTerraformSafeString(s string) (map[string]string, error) {
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNewStaticPodPatchFiles(t *testing.T) {
+	files, err := NewStaticPodPatchFiles([]StaticPodPatchSpec{
+		{
+			Target:            "kube-apiserver",
+			Requests:          map[string]string{"cpu": "500m", "memory": "1Gi"},
+			Limits:            map[string]string{"memory": "2Gi"},
+			Env:               map[string]string{"GOMAXPROCS": "4", "GODEBUG": "x509sha1=1"},
+			PriorityClassName: "system-node-critical",
+		},
+		{
+			Target: "etcd",
+			Patch:  "- op: add\n  path: /spec/containers/0/command/-\n  value: --quota-backend-bytes=8589934592\n",
+			Type:   "json",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Error: unexpected number of files: %v", files)
+	}
+
+	expected := `{"spec":{"containers":[{"env":[{"name":"GODEBUG","value":"x509sha1=1"},{"name":"GOMAXPROCS","value":"4"}],` +
+		`"name":"kube-apiserver","resources":{"limits":{"memory":"2Gi"},"requests":{"cpu":"500m","memory":"1Gi"}}}],` +
+		`"priorityClassName":"system-node-critical"}}`
+	if files["kube-apiserver0a+strategic.json"] != expected {
+		t.Fatalf("Error: unexpected patch for the API server:\n%s\nexpected:\n%s", files["kube-apiserver0a+strategic.json"], expected)
+	}
+	if files["etcd1b+json.json"] != `[{"op":"add","path":"/spec/containers/0/command/-","value":"--quota-backend-bytes=8589934592"}]` {
+		t.Fatalf("Error: unexpected patch for etcd: %s", files["etcd1b+json.json"])
+	}
+
+	invalid := []StaticPodPatchSpec{
+		{Target: "kube-proxy", PriorityClassName: "high"},
+		{Target: "etcd", Requests: map[string]string{"cpu": "a lot"}},
+		{Target: "etcd", Patch: "{ not valid", Type: "merge"},
+		{Target: "etcd", Patch: "spec: {}", Type: "unknown"},
+	}
+	for _, p := range invalid {
+		if _, err := NewStaticPodPatchFiles([]StaticPodPatchSpec{p}); err == nil {
+			t.Fatalf("Error: no error for invalid patch %+v", p)
+		}
+	}
+}
+
+func TestGetPatchesFlag(t *testing.T) {
+	if err := CheckPatchesVersion("v1.18.5"); err == nil {
+		t.Fatalf("Error: patches should not be supported in v1.18")
+	}
+	if flag := GetPatchesFlag("v1.20.1", DefPatchesDir); flag != "--experimental-patches=/etc/kubernetes/patches" {
+		t.Fatalf("Error: unexpected flag for v1.20: %s", flag)
+	}
+	if flag := GetPatchesFlag("v1.27.3", DefPatchesDir); flag != "--patches=/etc/kubernetes/patches" {
+		t.Fatalf("Error: unexpected flag for v1.27: %s", flag)
+	}
+}
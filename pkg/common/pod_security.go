@@ -0,0 +1,170 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// DefAdmissionConfigPath is the path of the admission configuration in the control plane nodes
+	DefAdmissionConfigPath = "/etc/kubernetes/admission-config.yaml"
+
+	// DefPodSecurityVersion is the version of the Pod Security Standards enforced by default
+	DefPodSecurityVersion = "latest"
+)
+
+var (
+	// PodSecurityLevels are the levels of the Pod Security Standards
+	PodSecurityLevels = []string{"privileged", "baseline", "restricted"}
+
+	// DefPodSecurityExemptNamespaces are the namespaces exempted when no exemption is provided
+	// (the system components need privileges)
+	DefPodSecurityExemptNamespaces = []string{"kube-system"}
+
+	// PodSecurityMinVersion is the first Kubernetes version with the Pod Security admission
+	PodSecurityMinVersion = version.MustParseGeneric("v1.22.0")
+
+	// the Pod Security admission is enabled by default from this version on
+	podSecurityBetaVersion = version.MustParseGeneric("v1.23.0")
+
+	// the configuration of the Pod Security admission is GA from this version on
+	podSecurityGAVersion = version.MustParseGeneric("v1.25.0")
+)
+
+// PodSecuritySpec describes the cluster-wide defaults of the Pod Security admission
+type PodSecuritySpec struct {
+	// levels for the namespaces without the `pod-security.kubernetes.io` labels
+	Enforce string
+	Audit   string
+	Warn    string
+
+	// version of the policies ("latest" or "v1.NN")
+	Version string
+
+	// namespaces where the policies are not applied
+	ExemptNamespaces []string
+}
+
+// IsPodSecurityFeatureGateRequired returns true when the PodSecurity feature
+// gate must be enabled in the API server for using the Pod Security admission
+func IsPodSecurityFeatureGateRequired(kubeVersion string) bool {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return false
+	}
+	return v.LessThan(podSecurityBetaVersion)
+}
+
+// CheckPodSecurityVersion checks that a Kubernetes version supports the Pod Security admission
+func CheckPodSecurityVersion(kubeVersion string) error {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return err
+	}
+	if v.LessThan(PodSecurityMinVersion) {
+		return fmt.Errorf("the Pod Security admission requires Kubernetes %s or later (got %s)", PodSecurityMinVersion, kubeVersion)
+	}
+	return nil
+}
+
+// NewPodSecurityAdmissionConfig returns an AdmissionConfiguration (passed to the API server
+// with `--admission-control-config-file`) for the Pod Security admission in some Kubernetes version
+func NewPodSecurityAdmissionConfig(spec PodSecuritySpec, kubeVersion string) (string, error) {
+	if err := CheckPodSecurityVersion(kubeVersion); err != nil {
+		return "", err
+	}
+	v := version.MustParseGeneric(kubeVersion)
+
+	// the API version of the plugin configuration follows the maturity of the Pod Security admission
+	configAPIVersion := "pod-security.admission.config.k8s.io/v1"
+	switch {
+	case v.LessThan(podSecurityBetaVersion):
+		configAPIVersion = "pod-security.admission.config.k8s.io/v1alpha1"
+	case v.LessThan(podSecurityGAVersion):
+		configAPIVersion = "pod-security.admission.config.k8s.io/v1beta1"
+	}
+
+	policiesVersion := spec.Version
+	if len(policiesVersion) == 0 {
+		policiesVersion = DefPodSecurityVersion
+	}
+
+	type podSecurityDefaults struct {
+		Enforce        string `json:"enforce"`
+		EnforceVersion string `json:"enforce-version"`
+		Audit          string `json:"audit"`
+		AuditVersion   string `json:"audit-version"`
+		Warn           string `json:"warn"`
+		WarnVersion    string `json:"warn-version"`
+	}
+	type podSecurityExemptions struct {
+		Usernames      []string `json:"usernames"`
+		RuntimeClasses []string `json:"runtimeClasses"`
+		Namespaces     []string `json:"namespaces"`
+	}
+	type podSecurityConfiguration struct {
+		APIVersion string                `json:"apiVersion"`
+		Kind       string                `json:"kind"`
+		Defaults   podSecurityDefaults   `json:"defaults"`
+		Exemptions podSecurityExemptions `json:"exemptions"`
+	}
+	type admissionPlugin struct {
+		Name          string                   `json:"name"`
+		Configuration podSecurityConfiguration `json:"configuration"`
+	}
+	type admissionConfiguration struct {
+		APIVersion string            `json:"apiVersion"`
+		Kind       string            `json:"kind"`
+		Plugins    []admissionPlugin `json:"plugins"`
+	}
+
+	config := admissionConfiguration{
+		APIVersion: "apiserver.config.k8s.io/v1",
+		Kind:       "AdmissionConfiguration",
+		Plugins: []admissionPlugin{
+			{
+				Name: "PodSecurity",
+				Configuration: podSecurityConfiguration{
+					APIVersion: configAPIVersion,
+					Kind:       "PodSecurityConfiguration",
+					Defaults: podSecurityDefaults{
+						Enforce:        spec.Enforce,
+						EnforceVersion: policiesVersion,
+						Audit:          spec.Audit,
+						AuditVersion:   policiesVersion,
+						Warn:           spec.Warn,
+						WarnVersion:    policiesVersion,
+					},
+					Exemptions: podSecurityExemptions{
+						Usernames:      []string{},
+						RuntimeClasses: []string{},
+						Namespaces:     append([]string{}, spec.ExemptNamespaces...),
+					},
+				},
+			},
+		},
+	}
+
+	// (JSON is valid YAML)
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewPodSecurityAdmissionConfig(t *testing.T) {
+	spec := PodSecuritySpec{
+		Enforce:          "baseline",
+		Audit:            "restricted",
+		Warn:             "restricted",
+		ExemptNamespaces: []string{"kube-system", "monitoring"},
+	}
+
+	if _, err := NewPodSecurityAdmissionConfig(spec, "v1.21.3"); err == nil {
+		t.Fatalf("Error: no error for a version without the Pod Security admission")
+	}
+
+	tests := map[string]string{
+		"v1.22.4":  "pod-security.admission.config.k8s.io/v1alpha1",
+		"v1.24.0":  "pod-security.admission.config.k8s.io/v1beta1",
+		"v1.28.2":  "pod-security.admission.config.k8s.io/v1",
+		"v1.25.0":  "pod-security.admission.config.k8s.io/v1",
+		"1.23.1":   "pod-security.admission.config.k8s.io/v1beta1",
+		"v1.30.0":  "pod-security.admission.config.k8s.io/v1",
+		"v1.26.10": "pod-security.admission.config.k8s.io/v1",
+	}
+	for kubeVersion, expectedAPIVersion := range tests {
+		config, err := NewPodSecurityAdmissionConfig(spec, kubeVersion)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+
+		parsed := struct {
+			Kind    string `json:"kind"`
+			Plugins []struct {
+				Name          string `json:"name"`
+				Configuration struct {
+					APIVersion string            `json:"apiVersion"`
+					Defaults   map[string]string `json:"defaults"`
+					Exemptions struct {
+						Namespaces []string `json:"namespaces"`
+					} `json:"exemptions"`
+				} `json:"configuration"`
+			} `json:"plugins"`
+		}{}
+		if err := json.Unmarshal([]byte(config), &parsed); err != nil {
+			t.Fatalf("Error: could not parse the admission config: %s", err)
+		}
+		if parsed.Kind != "AdmissionConfiguration" || len(parsed.Plugins) != 1 || parsed.Plugins[0].Name != "PodSecurity" {
+			t.Fatalf("Error: unexpected admission config:\n%s", config)
+		}
+		c := parsed.Plugins[0].Configuration
+		if c.APIVersion != expectedAPIVersion {
+			t.Fatalf("Error: unexpected apiVersion for %s: %q", kubeVersion, c.APIVersion)
+		}
+		if c.Defaults["enforce"] != "baseline" || c.Defaults["warn"] != "restricted" || c.Defaults["enforce-version"] != DefPodSecurityVersion {
+			t.Fatalf("Error: unexpected defaults: %v", c.Defaults)
+		}
+		if !reflect.DeepEqual(c.Exemptions.Namespaces, spec.ExemptNamespaces) {
+			t.Fatalf("Error: unexpected exempt namespaces: %v", c.Exemptions.Namespaces)
+		}
+	}
+}
+
+func TestInitConfigAdmissionConfig(t *testing.T) {
+	spec := ClusterSpec{
+		Version:             "v1.22.4",
+		AdmissionConfigPath: DefAdmissionConfigPath,
+		Runtime: RuntimeSpec{
+			APIServerArgs: map[string]string{"feature-gates": "EphemeralContainers=true"},
+		},
+	}
+	initConfig, err := NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	args := initConfig.APIServer.ExtraArgs
+	if args["admission-control-config-file"] != DefAdmissionConfigPath {
+		t.Fatalf("Error: no admission config in the API server args: %v", args)
+	}
+	if args["feature-gates"] != "EphemeralContainers=true,PodSecurity=true" {
+		t.Fatalf("Error: unexpected feature gates: %q", args["feature-gates"])
+	}
+	if len(initConfig.APIServer.ExtraVolumes) != 1 || initConfig.APIServer.ExtraVolumes[0].HostPath != DefAdmissionConfigPath {
+		t.Fatalf("Error: admission config not mounted: %+v", initConfig.APIServer.ExtraVolumes)
+	}
+
+	// the user can disable the feature gate...
+	spec.Runtime.APIServerArgs = map[string]string{"feature-gates": "PodSecurity=false"}
+	initConfig, err = NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if fg := initConfig.APIServer.ExtraArgs["feature-gates"]; fg != "PodSecurity=false" {
+		t.Fatalf("Error: unexpected feature gates: %q", fg)
+	}
+
+	// ... and it is not needed in recent versions
+	spec.Version = "v1.25.0"
+	spec.Runtime.APIServerArgs = nil
+	initConfig, err = NewInitConfig(spec)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if fg, ok := initConfig.APIServer.ExtraArgs["feature-gates"]; ok {
+		t.Fatalf("Error: unexpected feature gates: %q", fg)
+	}
+}
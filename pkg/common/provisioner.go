@@ -16,6 +16,8 @@ package common
 
 import (
 	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
 
 // Rationale:
@@ -36,12 +38,14 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 	"init": {
 		Type: schema.TypeString,
 		// Computed: true,
-		Optional: true,
+		Optional:  true,
+		Sensitive: true,
 	},
 	"join": {
 		Type: schema.TypeString,
 		// Computed: true,
-		Optional: true,
+		Optional:  true,
+		Sensitive: true,
 	},
 	"kube_version": {
 		Type: schema.TypeString,
@@ -51,7 +55,8 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 	"kubeconfig": {
 		Type: schema.TypeString,
 		// Computed: true,
-		Optional: true,
+		Optional:  true,
+		Sensitive: true,
 	},
 	"token": {
 		Type: schema.TypeString,
@@ -59,6 +64,37 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		Optional:  true,
 		Sensitive: true,
 	},
+	"certificate_key": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"timeout_create": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"timeout_update": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"timeout_delete": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"vault_address": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"vault_secrets_path": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
 	"cni_plugin": {
 		Type: schema.TypeString,
 		// Computed: true,
@@ -89,6 +125,26 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"dns_search": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the search domains for the upstream DNS configuration",
+	},
+	"dns_forward_zones": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the DNS zones forwarded to some specific servers in CoreDNS (JSON encoded)",
+	},
+	"cni_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the version of the CNI plugin",
+	},
+	"cni_mtu": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the MTU for the pods network",
+	},
 	"flannel_backend": {
 		Type:        schema.TypeString,
 		Optional:    true,
@@ -99,21 +155,229 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		Optional:    true,
 		Description: "the flannel image version",
 	},
+	"calico_encapsulation": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the encapsulation for the Calico IP pool",
+	},
+	"calico_mtu": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the MTU used by Calico",
+	},
+	"cilium_tunnel": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the tunnel mode used by Cilium",
+	},
+	"cilium_kube_proxy_replacement": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "Cilium replaces kube-proxy",
+	},
+	"cilium_hubble": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "Hubble is enabled in Cilium",
+	},
 	"helm_enabled": {
 		Type: schema.TypeBool,
 		// Computed: true,
 		Optional: true,
 	},
+	"vip_address": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "virtual IP for the control plane (empty if not used)",
+	},
+	"vip_interface": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "network interface for the control plane VIP",
+	},
+	"vip_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "version of kube-vip",
+	},
+	"node_local_dns_ip": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "address of NodeLocal DNSCache (empty if not enabled)",
+	},
+	"cluster_dns_ip": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "address of the cluster DNS service",
+	},
+	"dns_domain": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "DNS domain of the cluster",
+	},
+	"local_path_enabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "deploy the local-path provisioner",
+	},
+	"local_path_default": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "mark the local-path StorageClass as the default one",
+	},
+	"metallb_manifest": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the MetalLB configuration, applied after installing the Helm charts",
+	},
+	"cert_manager_issuer": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the cert-manager ClusterIssuer, applied after installing the Helm charts",
+	},
+	"helm_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the Helm version installed in the first master",
+	},
+	"helm_charts": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the charts installed with Helm (serialized)",
+	},
 	"cloud_provider": {
 		Type: schema.TypeString,
 		// Computed: true,
 		Optional: true,
 	},
-	"cloud_config": {
+	"proxy_http": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"proxy_https": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"proxy_no_proxy": {
 		Type: schema.TypeString,
 		// Computed: true,
 		Optional: true,
 	},
+	"registries": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"cloud_config": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"scheduler_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "configuration for the scheduler",
+	},
+	"scheduler_config_path": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "path of the scheduler configuration in the control plane nodes",
+	},
+	"konnectivity_enabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the API server reaches the cluster through the konnectivity service",
+	},
+	"konnectivity_server_host": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "host the konnectivity agents connect to",
+	},
+	"konnectivity_agent_port": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "port where the konnectivity-server listens for the agents",
+	},
+	"konnectivity_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "version of the konnectivity-server and konnectivity-agent",
+	},
+	"audit_policy": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "audit policy for the API server",
+	},
+	"audit_webhook_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "kubeconfig for the audit webhook",
+	},
+	"rbac_manifest": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "RBAC objects created after the initialization of the cluster",
+	},
+	"static_pod_patches": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "patches for the control plane static pods",
+	},
+	"manifests": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "manifests applied after the initialization of the cluster",
+	},
+	"admission_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "admission configuration for the API server",
+	},
+	"admission_config_path": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "path of the admission configuration in the control plane nodes",
+	},
+	"kubelet_serving_certs": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "approve the CSRs for the kubelet serving certificates",
+	},
+	"api_auto_sans": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "add the control plane nodes addresses to the API server certificate",
+	},
+	"etcd_mode": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "etcd topology: stacked or external",
+	},
+	"cloud_config_path": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "path of the cloud config in the control plane nodes",
+	},
+	"vsphere_csi_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "configuration for the vSphere CSI driver",
+	},
+	"cloud_manager_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "version of the cloud controller manager (for the out-of-tree providers)",
+	},
 	"cloud_provider_flags": {
 		Type: schema.TypeString,
 		// Computed: true,
@@ -124,6 +388,96 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"metrics_server_enabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the metrics-server is deployed",
+	},
+	"metrics_server_insecure_tls": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the metrics-server does not verify the kubelets certificates",
+	},
+	"metrics_exposed": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the control plane metrics are exposed in all the interfaces",
+	},
+	"runtime_engine": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the container runtime engine",
+	},
+	"cgroup_driver": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the cgroup driver used by the kubelet and the container runtime",
+	},
+	"cri_dockerd": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "cri-dockerd must be installed for using the docker runtime engine",
+	},
+	"cri_socket": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the CRI socket forced by the user (otherwise it is detected in the nodes)",
+	},
+	"sandbox_image": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the sandbox (pause) image used by the container runtime",
+	},
+	"min_master_cpus": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum number of CPUs in the master nodes",
+	},
+	"min_master_memory": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum memory (in MB) in the master nodes",
+	},
+	"min_master_disk": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum free disk space (in GB) in the master nodes",
+	},
+	"min_worker_cpus": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum number of CPUs in the worker nodes",
+	},
+	"min_worker_memory": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum memory (in MB) in the worker nodes",
+	},
+	"min_worker_disk": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the minimum free disk space (in GB) in the worker nodes",
+	},
+	"kubelet_root_dir": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the directory for the kubelet data",
+	},
+	"etcd_data_dir": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the directory for the local etcd data",
+	},
+	"containerd_root_dir": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the directory for the persistent containerd data",
+	},
+	"containerd_state_dir": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the directory for the containerd state",
+	},
 	"config_path": {
 		Type: schema.TypeString,
 		// Computed: true,
@@ -187,6 +541,18 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 	},
 }
 
+// RegisterSecrets registers all the sensitive values in a provisioner
+// config, so they are redacted in logs
+func RegisterSecrets(config map[string]interface{}) {
+	for k, v := range config {
+		if elem, ok := ProvisionerConfigElements[k]; ok && elem.Sensitive {
+			if s, ok := v.(string); ok {
+				ssh.AddSecret(s)
+			}
+		}
+	}
+}
+
 // GetProvisionerConfig returns the config for a provisioner, stored in the "config" attribute
 func GetProvisionerConfig(d *schema.ResourceData) map[string]interface{} {
 	return d.Get("config").(map[string]interface{})
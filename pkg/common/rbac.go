@@ -0,0 +1,170 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ClusterRoleSpec describes a ClusterRole created after the initialization of the cluster
+type ClusterRoleSpec struct {
+	Name  string
+	Rules []RBACRuleSpec
+}
+
+// RBACRuleSpec describes a rule in a ClusterRole
+type RBACRuleSpec struct {
+	APIGroups       []string
+	Resources       []string
+	ResourceNames   []string
+	NonResourceURLs []string
+	Verbs           []string
+}
+
+// RoleBindingSpec describes a binding of a ClusterRole to some users, groups and service
+// accounts, in the whole cluster (ClusterRoleBinding) or in a namespace (RoleBinding)
+type RoleBindingSpec struct {
+	Name string
+
+	// namespace for a RoleBinding (empty for a ClusterRoleBinding)
+	Namespace string
+
+	// the ClusterRole (ie, "cluster-admin", "view" or one of the ClusterRoles created)
+	ClusterRole string
+
+	// subjects (the service accounts as "<namespace>/<name>")
+	Users           []string
+	Groups          []string
+	ServiceAccounts []string
+}
+
+// IsEmpty returns true when the binding has no subjects
+func (s RoleBindingSpec) IsEmpty() bool {
+	return len(s.Users) == 0 && len(s.Groups) == 0 && len(s.ServiceAccounts) == 0
+}
+
+// ValidateServiceAccountRef validates a service account reference, as "<namespace>/<name>"
+func ValidateServiceAccountRef(v interface{}, k string) (ws []string, errors []error) {
+	parts := strings.Split(v.(string), "/")
+	if len(parts) != 2 {
+		errors = append(errors, fmt.Errorf("%q must be a service account as <namespace>/<name> (got %q)", k, v.(string)))
+		return
+	}
+	for _, part := range parts {
+		for _, msg := range validation.IsDNS1123Subdomain(part) {
+			errors = append(errors, fmt.Errorf("%q is not a valid service account (%q): %s", k, v.(string), msg))
+		}
+	}
+	return
+}
+
+// NewRBACManifest returns a manifest (a `List`) with the ClusterRoles and the bindings
+func NewRBACManifest(roles []ClusterRoleSpec, bindings []RoleBindingSpec) (string, error) {
+	items := []interface{}{}
+
+	for _, role := range roles {
+		if len(role.Rules) == 0 {
+			return "", fmt.Errorf("the ClusterRole %q has no rules", role.Name)
+		}
+		clusterRole := rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: role.Name},
+		}
+		for _, rule := range role.Rules {
+			if len(rule.Resources) > 0 && len(rule.NonResourceURLs) > 0 {
+				return "", fmt.Errorf("a rule in the ClusterRole %q cannot have both resources and non-resource URLs", role.Name)
+			}
+			if len(rule.Resources) == 0 && len(rule.NonResourceURLs) == 0 {
+				return "", fmt.Errorf("a rule in the ClusterRole %q must have some resources or non-resource URLs", role.Name)
+			}
+			policyRule := rbacv1.PolicyRule{
+				Verbs:           append([]string{}, rule.Verbs...),
+				ResourceNames:   rule.ResourceNames,
+				NonResourceURLs: rule.NonResourceURLs,
+			}
+			if len(rule.Resources) > 0 {
+				// (the core API group is "")
+				policyRule.APIGroups = append([]string{}, rule.APIGroups...)
+				if len(policyRule.APIGroups) == 0 {
+					policyRule.APIGroups = []string{""}
+				}
+				policyRule.Resources = rule.Resources
+			}
+			clusterRole.Rules = append(clusterRole.Rules, policyRule)
+		}
+		items = append(items, clusterRole)
+	}
+
+	for _, binding := range bindings {
+		if binding.IsEmpty() {
+			return "", fmt.Errorf("the binding %q has no users, groups or service accounts", binding.Name)
+		}
+
+		subjects := []rbacv1.Subject{}
+		for _, user := range binding.Users {
+			subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: user})
+		}
+		for _, group := range binding.Groups {
+			subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: group})
+		}
+		for _, sa := range binding.ServiceAccounts {
+			if _, errs := ValidateServiceAccountRef(sa, binding.Name); len(errs) > 0 {
+				return "", errs[0]
+			}
+			parts := strings.Split(sa, "/")
+			subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: parts[0], Name: parts[1]})
+		}
+
+		roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: binding.ClusterRole}
+		if len(binding.Namespace) > 0 {
+			items = append(items, rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: binding.Name, Namespace: binding.Namespace},
+				Subjects:   subjects,
+				RoleRef:    roleRef,
+			})
+		} else {
+			items = append(items, rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: binding.Name},
+				Subjects:   subjects,
+				RoleRef:    roleRef,
+			})
+		}
+	}
+
+	if len(items) == 0 {
+		return "", nil
+	}
+
+	list := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}
+
+	// (JSON is valid YAML)
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
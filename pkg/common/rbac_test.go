@@ -0,0 +1,107 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestNewRBACManifest(t *testing.T) {
+	roles := []ClusterRoleSpec{
+		{
+			Name: "pods-reader",
+			Rules: []RBACRuleSpec{
+				{Resources: []string{"pods", "pods/log"}, Verbs: []string{"get", "list", "watch"}},
+				{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			},
+		},
+	}
+	bindings := []RoleBindingSpec{
+		{Name: "oidc-admins", ClusterRole: "cluster-admin", Groups: []string{"oidc:admins"}},
+		{Name: "ci-readers", Namespace: "ci", ClusterRole: "pods-reader", Users: []string{"jenkins"}, ServiceAccounts: []string{"ci/runner"}},
+	}
+
+	manifest, err := NewRBACManifest(roles, bindings)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	list := struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := json.Unmarshal([]byte(manifest), &list); err != nil {
+		t.Fatalf("Error: could not parse the manifest: %s", err)
+	}
+	if list.Kind != "List" || len(list.Items) != 3 {
+		t.Fatalf("Error: unexpected manifest:\n%s", manifest)
+	}
+
+	role := rbacv1.ClusterRole{}
+	if err := json.Unmarshal(list.Items[0], &role); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if role.Kind != "ClusterRole" || len(role.Rules) != 2 {
+		t.Fatalf("Error: unexpected ClusterRole: %+v", role)
+	}
+	if len(role.Rules[0].APIGroups) != 1 || role.Rules[0].APIGroups[0] != "" {
+		t.Fatalf("Error: the core API group is not used by default: %+v", role.Rules[0])
+	}
+	if len(role.Rules[1].APIGroups) != 0 {
+		t.Fatalf("Error: unexpected API groups for a non-resource URL: %+v", role.Rules[1])
+	}
+
+	crb := rbacv1.ClusterRoleBinding{}
+	if err := json.Unmarshal(list.Items[1], &crb); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if crb.Kind != "ClusterRoleBinding" || crb.RoleRef.Name != "cluster-admin" || len(crb.Subjects) != 1 || crb.Subjects[0].Kind != rbacv1.GroupKind {
+		t.Fatalf("Error: unexpected ClusterRoleBinding: %+v", crb)
+	}
+
+	rb := rbacv1.RoleBinding{}
+	if err := json.Unmarshal(list.Items[2], &rb); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if rb.Kind != "RoleBinding" || rb.Namespace != "ci" || len(rb.Subjects) != 2 {
+		t.Fatalf("Error: unexpected RoleBinding: %+v", rb)
+	}
+	if sa := rb.Subjects[1]; sa.Kind != rbacv1.ServiceAccountKind || sa.Namespace != "ci" || sa.Name != "runner" {
+		t.Fatalf("Error: unexpected service account subject: %+v", sa)
+	}
+
+	// nothing to create
+	if manifest, err := NewRBACManifest(nil, nil); err != nil || manifest != "" {
+		t.Fatalf("Error: unexpected manifest: %q (%v)", manifest, err)
+	}
+
+	invalid := []struct {
+		roles    []ClusterRoleSpec
+		bindings []RoleBindingSpec
+	}{
+		{roles: []ClusterRoleSpec{{Name: "empty"}}},
+		{roles: []ClusterRoleSpec{{Name: "mixed", Rules: []RBACRuleSpec{{Resources: []string{"pods"}, NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}}}}}},
+		{bindings: []RoleBindingSpec{{Name: "nobody", ClusterRole: "view"}}},
+		{bindings: []RoleBindingSpec{{Name: "bad-sa", ClusterRole: "view", ServiceAccounts: []string{"runner"}}}},
+	}
+	for _, c := range invalid {
+		if _, err := NewRBACManifest(c.roles, c.bindings); err == nil {
+			t.Fatalf("Error: no error for %+v", c)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RegistrySpec describes a container registry: the mirrors, the credentials
+// and the CA used for pulling from this registry (or its mirrors)
+type RegistrySpec struct {
+	// upstream registry (ie, "docker.io")
+	Upstream string `json:"upstream"`
+
+	// mirrors (ie, "https://mirror.internal:5000")
+	Mirrors []string `json:"mirrors,omitempty"`
+
+	// credentials for the registry and its mirrors
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// CA bundle (in PEM format) for self-signed registries
+	CA string `json:"ca,omitempty"`
+}
+
+// RegistryHost returns the host (and port) in a registry URL
+func RegistryHost(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	if i := strings.Index(url, "/"); i >= 0 {
+		url = url[:i]
+	}
+	return url
+}
+
+// RegistriesToTerraformSafeString serializes a list of registries for the provisioner
+func RegistriesToTerraformSafeString(registries []RegistrySpec) (string, error) {
+	data, err := json.Marshal(registries)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// RegistriesFromTerraformSafeString deserializes a list of registries
+func RegistriesFromTerraformSafeString(s string) ([]RegistrySpec, error) {
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		return nil, err
+	}
+	registries := []RegistrySpec{}
+	if err := json.Unmarshal(data, &registries); err != nil {
+		return nil, err
+	}
+	return registries, nil
+}
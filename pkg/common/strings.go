@@ -26,3 +26,13 @@ func StringSliceUnique(slice []string) []string {
 	}
 	return list
 }
+
+// StringSliceContains returns true if the slice contains the string
+func StringSliceContains(slice []string, s string) bool {
+	for _, entry := range slice {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
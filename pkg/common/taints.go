@@ -0,0 +1,110 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// the taints kubeadm sets in control plane nodes
+	masterTaintKey       = "node-role.kubernetes.io/master"
+	controlPlaneTaintKey = "node-role.kubernetes.io/control-plane"
+)
+
+var (
+	// kubeadm taints control plane nodes with the `control-plane` taint (as well as
+	// the `master` one) from this version...
+	controlPlaneTaintVersion = version.MustParseGeneric("v1.24.0")
+
+	// ... and it stops using the `master` taint in this version
+	masterTaintRemovedVersion = version.MustParseGeneric("v1.25.0")
+
+	taintEffects = []corev1.TaintEffect{
+		corev1.TaintEffectNoSchedule,
+		corev1.TaintEffectPreferNoSchedule,
+		corev1.TaintEffectNoExecute,
+	}
+)
+
+// ControlPlaneTaints returns the taints kubeadm sets by default in the
+// control plane nodes for some Kubernetes version
+func ControlPlaneTaints(kubeVersion string) []corev1.Taint {
+	if len(kubeVersion) == 0 {
+		kubeVersion = DefKubernetesVersion
+	}
+	keys := []string{masterTaintKey}
+	if v, err := version.ParseGeneric(kubeVersion); err == nil {
+		switch {
+		case !v.LessThan(masterTaintRemovedVersion):
+			keys = []string{controlPlaneTaintKey}
+		case !v.LessThan(controlPlaneTaintVersion):
+			keys = []string{masterTaintKey, controlPlaneTaintKey}
+		}
+	}
+
+	res := []corev1.Taint{}
+	for _, key := range keys {
+		res = append(res, corev1.Taint{Key: key, Effect: corev1.TaintEffectNoSchedule})
+	}
+	return res
+}
+
+// ParseTaint parses a taint in the `key[=value]:effect` format (as used in
+// `kubectl taint`), like `dedicated=gpu:NoSchedule`
+func ParseTaint(s string) (corev1.Taint, error) {
+	taint := corev1.Taint{}
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return taint, fmt.Errorf("invalid taint %q: the format must be key[=value]:effect", s)
+	}
+	taint.Effect = corev1.TaintEffect(s[i+1:])
+	validEffect := false
+	for _, effect := range taintEffects {
+		if taint.Effect == effect {
+			validEffect = true
+		}
+	}
+	if !validEffect {
+		return taint, fmt.Errorf("invalid taint %q: the effect must be one of %v", s, taintEffects)
+	}
+
+	kv := strings.SplitN(s[:i], "=", 2)
+	taint.Key = kv[0]
+	if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+		return taint, fmt.Errorf("invalid taint %q: %s", s, strings.Join(errs, "; "))
+	}
+	if len(kv) == 2 {
+		taint.Value = kv[1]
+		if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+			return taint, fmt.Errorf("invalid taint %q: %s", s, strings.Join(errs, "; "))
+		}
+	}
+	return taint, nil
+}
+
+// ValidateTaint validates a taint in the `key[=value]:effect` format
+func ValidateTaint(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := ParseTaint(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestControlPlaneTaints(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected []string
+	}{
+		{"v1.15.0", []string{masterTaintKey}},
+		{"v1.24.3", []string{masterTaintKey, controlPlaneTaintKey}},
+		{"v1.28.2", []string{controlPlaneTaintKey}},
+		{"", []string{masterTaintKey}},
+	}
+	for _, test := range tests {
+		taints := ControlPlaneTaints(test.version)
+		if len(taints) != len(test.expected) {
+			t.Fatalf("Error: unexpected taints for %q: %v", test.version, taints)
+		}
+		for i, taint := range taints {
+			if taint.Key != test.expected[i] || taint.Effect != corev1.TaintEffectNoSchedule {
+				t.Fatalf("Error: unexpected taint for %q: %v", test.version, taint)
+			}
+		}
+	}
+}
+
+func TestParseTaint(t *testing.T) {
+	taint, err := ParseTaint("dedicated=gpu:NoSchedule")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if taint.Key != "dedicated" || taint.Value != "gpu" || taint.Effect != corev1.TaintEffectNoSchedule {
+		t.Fatalf("Error: unexpected taint: %v", taint)
+	}
+
+	taint, err = ParseTaint("example.com/spot:PreferNoSchedule")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if taint.Key != "example.com/spot" || len(taint.Value) > 0 || taint.Effect != corev1.TaintEffectPreferNoSchedule {
+		t.Fatalf("Error: unexpected taint: %v", taint)
+	}
+
+	for _, s := range []string{"dedicated=gpu", "dedicated=gpu:NoWay", "=gpu:NoSchedule", "dedicated=g p u:NoExecute"} {
+		if _, err := ParseTaint(s); err == nil {
+			t.Fatalf("Error: %q should not be a valid taint", s)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 )
 
@@ -27,7 +28,27 @@ const (
 
 	TokenSecretBytes = 8
 
+	// CertificateKeyBytes is the size of the key used for encrypting the
+	// control plane certificates uploaded to the cluster (AES-256)
+	CertificateKeyBytes = 32
+
 	TokenRegex = `[a-z0-9]{6}\.[a-z0-9]{16}`
+
+	// TokenDescription is the description used for the tokens created by
+	// the provider/provisioner, so we can identify them later on
+	// (note: it must not contain spaces, as it is parsed from "kubeadm token list")
+	TokenDescription = "terraform-provider-kubeadm"
+)
+
+var (
+	// BootstrapTokenUsages are the valid usages for a bootstrap token
+	BootstrapTokenUsages = []string{"signing", "authentication"}
+
+	// DefBootstrapTokenGroups are the extra groups used when none is provided
+	DefBootstrapTokenGroups = []string{"system:bootstrappers:kubeadm:default-node-token"}
+
+	// BootstrapTokenGroupRegex is the format of the extra groups of a bootstrap token
+	BootstrapTokenGroupRegex = `^system:bootstrappers:[a-z0-9:-]{0,255}[a-z0-9]$`
 )
 
 func randBytes(length int) (string, error) {
@@ -56,6 +77,12 @@ func GetRandomToken() (string, error) {
 	return fmt.Sprintf("%s.%s", tokenID, tokenSecret), nil
 }
 
+// GetRandomCertificateKey generates a new key for the `kubeadm-certs` secret,
+// used by `kubeadm join --control-plane` for downloading the certificates.
+func GetRandomCertificateKey() (string, error) {
+	return randBytes(CertificateKeyBytes)
+}
+
 func NewBootstrapToken(token string) (kubeadmapi.BootstrapToken, error) {
 	var err error
 	bto := kubeadmapi.BootstrapToken{}
@@ -73,3 +100,26 @@ func NewRandomBootstrapToken() (kubeadmapi.BootstrapToken, error) {
 	}
 	return NewBootstrapToken(t)
 }
+
+// NewBootstrapTokenFromSpec creates a bootstrap token from a BootstrapTokenSpec
+func NewBootstrapTokenFromSpec(spec BootstrapTokenSpec) (kubeadmapi.BootstrapToken, error) {
+	t, err := NewBootstrapToken(spec.Token)
+	if err != nil {
+		return kubeadmapi.BootstrapToken{}, err
+	}
+
+	t.Description = spec.Description
+	if len(t.Description) == 0 {
+		t.Description = TokenDescription
+	}
+	t.TTL = &metav1.Duration{Duration: spec.TTL}
+	t.Usages = spec.Usages
+	if len(t.Usages) == 0 {
+		t.Usages = BootstrapTokenUsages
+	}
+	t.Groups = spec.Groups
+	if len(t.Groups) == 0 {
+		t.Groups = DefBootstrapTokenGroups
+	}
+	return t, nil
+}
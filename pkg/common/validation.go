@@ -20,8 +20,12 @@ import (
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/validation"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 const dnsRegex = `^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`
@@ -35,6 +39,14 @@ var ValidateDNSName = validation.StringMatch(DnsRegexMatcher,
 // ValidateDNSNameOrIP is a regular expression for validating a DNS name or an IP
 var ValidateDNSNameOrIP = validation.Any(validation.SingleIP(), ValidateDNSName)
 
+// ValidateBootstrapToken validates a bootstrap token (like "abcdef.0123456789abcdef")
+var ValidateBootstrapToken = validation.StringMatch(regexp.MustCompile("^"+TokenRegex+"$"),
+	"the token must have the <6 chars id>.<16 chars secret> format")
+
+// ValidateBootstrapTokenGroup validates an extra group for a bootstrap token
+var ValidateBootstrapTokenGroup = validation.StringMatch(regexp.MustCompile(BootstrapTokenGroupRegex),
+	"the group must start with 'system:bootstrappers:'")
+
 func ValidateAbsPath(v interface{}, k string) (ws []string, errors []error) {
 	if !filepath.IsAbs(v.(string)) {
 		errors = append(errors, fmt.Errorf("%q is not an absolute path", k))
@@ -43,8 +55,49 @@ func ValidateAbsPath(v interface{}, k string) (ws []string, errors []error) {
 }
 
 func ValidateHostPort(v interface{}, k string) (ws []string, errors []error) {
-	_, _, err := net.SplitHostPort(v.(string))
-	errors = append(errors, fmt.Errorf("%q is not an valid 'expectedHost:expectedPort': %s", k, err))
+	if _, _, err := net.SplitHostPort(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q is not an valid 'expectedHost:expectedPort': %s", k, err))
+	}
+	return
+}
+
+// ValidateHostOptionalPort validates a DNS name or IP, with an optional port (like "10.0.0.1:6443")
+func ValidateHostOptionalPort(v interface{}, k string) (ws []string, errors []error) {
+	host, port, err := SplitHostPort(v.(string), DefAPIServerPort)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid 'host[:port]': %s", k, err))
+		return
+	}
+	if port <= 0 || port > 65535 {
+		errors = append(errors, fmt.Errorf("%q has an invalid port: %d", k, port))
+	}
+	if net.ParseIP(host) == nil && !DnsRegexMatcher.MatchString(host) {
+		errors = append(errors, fmt.Errorf("%q is not a valid DNS name or IP: %q", k, host))
+	}
+	return
+}
+
+// ValidateIPOptionalPort validates an IP, with an optional port (like "10.0.0.53:5353")
+func ValidateIPOptionalPort(v interface{}, k string) (ws []string, errors []error) {
+	host, port, err := SplitHostPort(v.(string), 53)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid 'ip[:port]': %s", k, err))
+		return
+	}
+	if port <= 0 || port > 65535 {
+		errors = append(errors, fmt.Errorf("%q has an invalid port: %d", k, port))
+	}
+	if net.ParseIP(host) == nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid IP: %q", k, host))
+	}
+	return
+}
+
+// ValidateVersion validates a semantic version, with an optional "v" prefix (like "v1.15.0")
+func ValidateVersion(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := version.ParseSemantic(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid version: %s", k, err))
+	}
 	return
 }
 
@@ -55,3 +108,38 @@ func ValidateURL(v interface{}, k string) (ws []string, errors []error) {
 	}
 	return
 }
+
+// ValidateDuration validates a duration (like "30s" or "5m")
+func ValidateDuration(v interface{}, k string) (ws []string, errors []error) {
+	d, err := time.ParseDuration(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q does not seem a valid duration: %s", k, err))
+	} else if d < 0 {
+		errors = append(errors, fmt.Errorf("%q cannot be a negative duration", k))
+	}
+	return
+}
+
+// ValidateQuantity validates a resource quantity (like "10Mi" or "1G")
+func ValidateQuantity(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := resource.ParseQuantity(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q does not seem a valid quantity: %s", k, err))
+	}
+	return
+}
+
+var gpgFingerprintMatcher = regexp.MustCompile("^[0-9A-F]{40}$")
+
+// NormalizeGPGFingerprint returns a GPG fingerprint in uppercase and without spaces
+// (so "7f92 e05b ..." and "7F92E05B..." are the same fingerprint)
+func NormalizeGPGFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(fingerprint), ""))
+}
+
+// ValidateGPGFingerprint validates the (40 hex digits) fingerprint of a GPG key
+func ValidateGPGFingerprint(v interface{}, k string) (ws []string, errors []error) {
+	if !gpgFingerprintMatcher.MatchString(NormalizeGPGFingerprint(v.(string))) {
+		errors = append(errors, fmt.Errorf("%q is not a valid GPG key fingerprint (40 hex digits)", k))
+	}
+	return
+}
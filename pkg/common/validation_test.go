@@ -0,0 +1,85 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestValidateHostOptionalPort(t *testing.T) {
+	for hp, valid := range map[string]bool{
+		"k8s.example.com":      true,
+		"k8s.example.com:6443": true,
+		"10.0.0.1":             true,
+		"10.0.0.1:443":         true,
+		"10.0.0.1:99999":       false,
+		"k8s.example.com:abc":  false,
+		"bad_name!":            false,
+	} {
+		_, errs := ValidateHostOptionalPort(hp, "api.0.external")
+		if (len(errs) == 0) != valid {
+			t.Fatalf("Error: unexpected validation result for %q: %v", hp, errs)
+		}
+	}
+
+	if _, errs := ValidateHostPort("10.0.0.1:6443", "listen"); len(errs) > 0 {
+		t.Fatalf("Error: unexpected validation errors: %v", errs)
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	versions := []string{
+		DefKubernetesVersion,
+		DefHelmVersion,
+		DefKubeVipVersion,
+		DefFlannelImageVersion,
+		DefIngressNginxChartVersion,
+		DefCertManagerChartVersion,
+		DefMetalLBChartVersion,
+	}
+	for _, v := range DefCNIVersions {
+		versions = append(versions, v)
+	}
+	for _, v := range DefCloudControllerManagerVersions {
+		versions = append(versions, v)
+	}
+	for _, v := range versions {
+		if _, errs := ValidateVersion(v, "version"); len(errs) > 0 {
+			t.Fatalf("Error: default version %q is not valid: %v", v, errs)
+		}
+	}
+
+	for _, v := range []string{"1.15", "latest", "v1.15.x"} {
+		if _, errs := ValidateVersion(v, "version"); len(errs) == 0 {
+			t.Fatalf("Error: %q should not be a valid version", v)
+		}
+	}
+}
+
+func TestValidateGPGFingerprint(t *testing.T) {
+	for _, v := range []string{
+		"DE15B14486CD377B9E876E1A234654DA9A296436",
+		"de15 b144 86cd 377b 9e87  6e1a 2346 54da 9a29 6436",
+	} {
+		if _, errs := ValidateGPGFingerprint(v, "fingerprint"); len(errs) > 0 {
+			t.Fatalf("Error: %q should be a valid fingerprint: %v", v, errs)
+		}
+	}
+	for _, v := range []string{"", "9A296436", "XE15B14486CD377B9E876E1A234654DA9A296436"} {
+		if _, errs := ValidateGPGFingerprint(v, "fingerprint"); len(errs) == 0 {
+			t.Fatalf("Error: %q should not be a valid fingerprint", v)
+		}
+	}
+}
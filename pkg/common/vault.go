@@ -0,0 +1,134 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefVaultMount is the default mount point of the KV (v2) secrets engine
+	DefVaultMount = "secret"
+
+	// DefVaultPath is the default path (in the KV mount) for the secrets
+	DefVaultPath = "kubeadm"
+
+	// DefVaultTokenEnv is the environment variable with the Vault token
+	DefVaultTokenEnv = "VAULT_TOKEN"
+
+	// DefVaultAddressEnv is the environment variable with the Vault address
+	DefVaultAddressEnv = "VAULT_ADDR"
+
+	// timeout for any request to Vault
+	vaultClientTimeout = 15 * time.Second
+)
+
+// VaultSecretsKeys are the keys (in the provisioner config) stored
+// in Vault instead of the Terraform state
+var VaultSecretsKeys = []string{"token", "certificate_key"}
+
+// VaultClient is a minimal client for the KV (v2) secrets engine in Vault
+type VaultClient struct {
+	Address string
+	Token   string
+
+	client *http.Client
+}
+
+// NewVaultClient creates a new Vault client
+func NewVaultClient(address, token string) *VaultClient {
+	return &VaultClient{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		client:  &http.Client{Timeout: vaultClientTimeout},
+	}
+}
+
+// GetVaultSecretsPath returns the API path (without the `/v1/`) for the
+// secrets of a cluster, like `secret/data/kubeadm/<id>`
+func GetVaultSecretsPath(mount, path, id string) string {
+	return fmt.Sprintf("%s/data/%s/%s", strings.Trim(mount, "/"), strings.Trim(path, "/"), id)
+}
+
+func (v *VaultClient) do(method, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", v.Address, path), bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// ReadSecrets reads the secrets at `path`, returning an empty map
+// when nothing has been stored there
+func (v *VaultClient) ReadSecrets(path string) (map[string]string, error) {
+	status, body, err := v.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read secrets from Vault: %s", err)
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return map[string]string{}, nil
+	case status != http.StatusOK:
+		return nil, fmt.Errorf("could not read secrets from Vault at %q: status %d: %s", path, status, body)
+	}
+
+	resp := struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("could not parse the response from Vault: %s", err)
+	}
+	if resp.Data.Data == nil {
+		return map[string]string{}, nil
+	}
+	return resp.Data.Data, nil
+}
+
+// WriteSecrets writes the secrets at `path` (replacing any previous secrets there)
+func (v *VaultClient) WriteSecrets(path string, secrets map[string]string) error {
+	body, err := json.Marshal(map[string]interface{}{"data": secrets})
+	if err != nil {
+		return err
+	}
+	status, respBody, err := v.do(http.MethodPost, path, body)
+	if err != nil {
+		return fmt.Errorf("could not write secrets to Vault: %s", err)
+	}
+	if status != http.StatusOK && status != http.StatusNoContent {
+		return fmt.Errorf("could not write secrets to Vault at %q: status %d: %s", path, status, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultClient(t *testing.T) {
+	stored := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/kubeadm/1234" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			body, _ := ioutil.ReadAll(r.Body)
+			req := struct {
+				Data map[string]string `json:"data"`
+			}{}
+			if err := json.Unmarshal(body, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			stored = req.Data
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": stored},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewVaultClient(server.URL+"/", "s.token")
+	path := GetVaultSecretsPath(DefVaultMount, DefVaultPath, "1234")
+	if path != "secret/data/kubeadm/1234" {
+		t.Fatalf("Error: unexpected path: %q", path)
+	}
+
+	if err := client.WriteSecrets(path, map[string]string{"token": "abcdef.0123456789abcdef"}); err != nil {
+		t.Fatalf("Error: could not write the secrets: %s", err)
+	}
+	secrets, err := client.ReadSecrets(path)
+	if err != nil || secrets["token"] != "abcdef.0123456789abcdef" {
+		t.Fatalf("Error: unexpected secrets: %v (%v)", secrets, err)
+	}
+
+	secrets, err = client.ReadSecrets(GetVaultSecretsPath(DefVaultMount, DefVaultPath, "other"))
+	if err != nil || len(secrets) != 0 {
+		t.Fatalf("Error: unexpected secrets for a missing path: %v (%v)", secrets, err)
+	}
+}
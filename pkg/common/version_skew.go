@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// CheckKubeadmVersionSkew checks that a kubeadm can deploy some Kubernetes version:
+// kubeadm supports the control planes of its own minor version and the previous one
+func CheckKubeadmVersionSkew(kubeadmVersion string, kubeVersion string) error {
+	kubeadm, err := version.ParseGeneric(kubeadmVersion)
+	if err != nil {
+		return fmt.Errorf("invalid kubeadm version %q: %s", kubeadmVersion, err)
+	}
+	kube, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version %q: %s", kubeVersion, err)
+	}
+
+	if kube.Major() != kubeadm.Major() || kube.Minor() > kubeadm.Minor() {
+		return fmt.Errorf("kubeadm %s cannot deploy Kubernetes %s: the kubeadm version must be the same or one minor version newer than the cluster",
+			kubeadmVersion, kubeVersion)
+	}
+	if kube.Minor()+1 < kubeadm.Minor() {
+		return fmt.Errorf("kubeadm %s cannot deploy Kubernetes %s: kubeadm only supports the previous minor version (v%d.%d)",
+			kubeadmVersion, kubeVersion, kubeadm.Major(), kubeadm.Minor()-1)
+	}
+	return nil
+}
+
+// CheckUpgradeVersionSkew checks that a cluster running some version can be upgraded to
+// another version: downgrades and skipping minor versions are not supported
+func CheckUpgradeVersionSkew(current string, target string) error {
+	cur, err := version.ParseGeneric(current)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version %q: %s", current, err)
+	}
+	tgt, err := version.ParseGeneric(target)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version %q: %s", target, err)
+	}
+
+	switch {
+	case tgt.Major() != cur.Major():
+		return fmt.Errorf("the cluster cannot be upgraded from %s to a different major version (%s)", current, target)
+	case tgt.Minor() < cur.Minor():
+		return fmt.Errorf("the cluster cannot be downgraded from %s to %s", current, target)
+	case tgt.Minor() > cur.Minor()+1:
+		return fmt.Errorf("the cluster cannot be upgraded from %s to %s: minor versions cannot be skipped (upgrade to v%d.%d first)",
+			current, target, cur.Major(), cur.Minor()+1)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestCheckKubeadmVersionSkew(t *testing.T) {
+	valid := [][2]string{
+		{"v1.27.3", "v1.27.1"},
+		{"v1.27.3", "v1.27.9"},
+		{"1.27.0-00", "v1.26.5"},
+	}
+	for _, c := range valid {
+		if err := CheckKubeadmVersionSkew(c[0], c[1]); err != nil {
+			t.Fatalf("Error: unexpected error for kubeadm %s and %s: %s", c[0], c[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"v1.27.3", "v1.28.0"},
+		{"v1.27.3", "v1.25.0"},
+		{"v1.27.3", "v2.27.0"},
+		{"latest", "v1.27.0"},
+	}
+	for _, c := range invalid {
+		if err := CheckKubeadmVersionSkew(c[0], c[1]); err == nil {
+			t.Fatalf("Error: no error for kubeadm %s and %s", c[0], c[1])
+		}
+	}
+}
+
+func TestCheckUpgradeVersionSkew(t *testing.T) {
+	valid := [][2]string{
+		{"v1.26.5", "v1.26.9"},
+		{"v1.26.5", "v1.27.0"},
+		{"v1.26.5", "v1.26.1"}, // (patch downgrades are allowed)
+	}
+	for _, c := range valid {
+		if err := CheckUpgradeVersionSkew(c[0], c[1]); err != nil {
+			t.Fatalf("Error: unexpected error from %s to %s: %s", c[0], c[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"v1.26.5", "v1.28.0"},
+		{"v1.26.5", "v1.25.0"},
+		{"v1.26.5", "v2.26.5"},
+	}
+	for _, c := range invalid {
+		if err := CheckUpgradeVersionSkew(c[0], c[1]); err == nil {
+			t.Fatalf("Error: no error from %s to %s", c[0], c[1])
+		}
+	}
+}
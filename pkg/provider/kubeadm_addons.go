@@ -0,0 +1,298 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getIngressChart returns the chart for the NGINX ingress controller (if enabled)
+func getIngressChart(d *schema.ResourceData) (common.HelmChartSpec, bool) {
+	if _, ok := d.GetOk("addons.0.ingress"); !ok || !d.Get("addons.0.ingress.0.install").(bool) {
+		return common.HelmChartSpec{}, false
+	}
+
+	class := d.Get("addons.0.ingress.0.class").(string)
+	serviceType := d.Get("addons.0.ingress.0.service_type").(string)
+
+	values := []string{
+		"controller:",
+		fmt.Sprintf("  ingressClass: %s", class),
+		"  ingressClassResource:",
+		fmt.Sprintf("    name: %s", class),
+		fmt.Sprintf("    controllerValue: k8s.io/ingress-%s", class),
+	}
+	if serviceType == "HostNetwork" {
+		// one controller per node, listening in the ports 80/443 of the node
+		values = append(values,
+			"  kind: DaemonSet",
+			"  hostNetwork: true",
+			"  dnsPolicy: ClusterFirstWithHostNet",
+			"  service:",
+			"    type: ClusterIP")
+	} else {
+		values = append(values,
+			fmt.Sprintf("  replicaCount: %d", d.Get("addons.0.ingress.0.replicas").(int)),
+			"  service:",
+			fmt.Sprintf("    type: %s", serviceType))
+	}
+
+	return common.HelmChartSpec{
+		Name:      "ingress-nginx",
+		Chart:     "ingress-nginx",
+		Repo:      common.DefIngressNginxChartRepo,
+		Version:   d.Get("addons.0.ingress.0.version").(string),
+		Namespace: common.DefIngressNginxNamespace,
+		Values:    strings.Join(values, "\n") + "\n",
+	}, true
+}
+
+// isMetalLBEnabled returns true if MetalLB must be deployed
+func isMetalLBEnabled(d *schema.ResourceData) bool {
+	_, ok := d.GetOk("addons.0.metallb")
+	return ok && d.Get("addons.0.metallb.0.install").(bool)
+}
+
+// getMetalLBChart returns the chart for MetalLB (if enabled)
+func getMetalLBChart(d *schema.ResourceData) (common.HelmChartSpec, bool) {
+	if !isMetalLBEnabled(d) {
+		return common.HelmChartSpec{}, false
+	}
+	return common.HelmChartSpec{
+		Name:      "metallb",
+		Chart:     "metallb",
+		Repo:      common.DefMetalLBChartRepo,
+		Version:   d.Get("addons.0.metallb.0.version").(string),
+		Namespace: common.DefMetalLBNamespace,
+	}, true
+}
+
+// getMetalLBManifest returns the MetalLB configuration (the addresses pool and how
+// they are announced), that must be applied once MetalLB is running
+func getMetalLBManifest(d *schema.ResourceData) (string, error) {
+	if !isMetalLBEnabled(d) {
+		return "", nil
+	}
+
+	addresses := stringsFromResourceData(d, "addons.0.metallb.0.addresses")
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("no addresses provided for MetalLB")
+	}
+
+	docs := []string{}
+	pool := []string{
+		"apiVersion: metallb.io/v1beta1",
+		"kind: IPAddressPool",
+		"metadata:",
+		"  name: default",
+		"  namespace: " + common.DefMetalLBNamespace,
+		"spec:",
+		"  addresses:",
+	}
+	for _, address := range addresses {
+		pool = append(pool, "    - "+address)
+	}
+	docs = append(docs, strings.Join(pool, "\n"))
+
+	switch d.Get("addons.0.metallb.0.mode").(string) {
+	case "bgp":
+		peers := d.Get("addons.0.metallb.0.bgp_peer").([]interface{})
+		if len(peers) == 0 {
+			return "", fmt.Errorf("no BGP peers provided for MetalLB in bgp mode")
+		}
+		for i, p := range peers {
+			peer := p.(map[string]interface{})
+			docs = append(docs, strings.Join([]string{
+				"apiVersion: metallb.io/v1beta2",
+				"kind: BGPPeer",
+				"metadata:",
+				fmt.Sprintf("  name: peer-%d", i),
+				"  namespace: " + common.DefMetalLBNamespace,
+				"spec:",
+				fmt.Sprintf("  myASN: %d", peer["my_asn"].(int)),
+				fmt.Sprintf("  peerASN: %d", peer["asn"].(int)),
+				fmt.Sprintf("  peerAddress: %s", peer["address"].(string)),
+			}, "\n"))
+		}
+		docs = append(docs, strings.Join([]string{
+			"apiVersion: metallb.io/v1beta1",
+			"kind: BGPAdvertisement",
+			"metadata:",
+			"  name: default",
+			"  namespace: " + common.DefMetalLBNamespace,
+			"spec:",
+			"  ipAddressPools:",
+			"    - default",
+		}, "\n"))
+	default:
+		docs = append(docs, strings.Join([]string{
+			"apiVersion: metallb.io/v1beta1",
+			"kind: L2Advertisement",
+			"metadata:",
+			"  name: default",
+			"  namespace: " + common.DefMetalLBNamespace,
+			"spec:",
+			"  ipAddressPools:",
+			"    - default",
+		}, "\n"))
+	}
+
+	return strings.Join(docs, "\n---\n") + "\n", nil
+}
+
+// getStorageProvisioner returns the storage provisioner that must be deployed
+// (or an empty string if none)
+func getStorageProvisioner(d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("addons.0.storage"); !ok || !d.Get("addons.0.storage.0.install").(bool) {
+		return "", nil
+	}
+
+	provisioner := d.Get("addons.0.storage.0.provisioner").(string)
+	if provisioner == "nfs" {
+		if d.Get("addons.0.storage.0.nfs_server").(string) == "" || d.Get("addons.0.storage.0.nfs_path").(string) == "" {
+			return "", fmt.Errorf("nfs_server and nfs_path are required for the nfs storage provisioner")
+		}
+	}
+	return provisioner, nil
+}
+
+// getStorageChart returns the chart for the NFS client provisioner (if enabled)
+func getStorageChart(d *schema.ResourceData) (common.HelmChartSpec, bool) {
+	if provisioner, err := getStorageProvisioner(d); err != nil || provisioner != "nfs" {
+		return common.HelmChartSpec{}, false
+	}
+
+	values := []string{
+		"nfs:",
+		fmt.Sprintf("  server: %s", d.Get("addons.0.storage.0.nfs_server").(string)),
+		fmt.Sprintf("  path: %s", d.Get("addons.0.storage.0.nfs_path").(string)),
+		"storageClass:",
+		fmt.Sprintf("  name: %s", common.DefNFSStorageClass),
+		fmt.Sprintf("  defaultClass: %t", d.Get("addons.0.storage.0.default").(bool)),
+	}
+
+	return common.HelmChartSpec{
+		Name:      "nfs-provisioner",
+		Chart:     "nfs-subdir-external-provisioner",
+		Repo:      common.DefNFSChartRepo,
+		Version:   common.DefNFSChartVersion,
+		Namespace: common.DefNFSNamespace,
+		Values:    strings.Join(values, "\n") + "\n",
+	}, true
+}
+
+// isCertManagerEnabled returns true if cert-manager must be deployed
+func isCertManagerEnabled(d *schema.ResourceData) bool {
+	_, ok := d.GetOk("addons.0.cert_manager")
+	return ok && d.Get("addons.0.cert_manager.0.install").(bool)
+}
+
+// getCertManagerChart returns the chart for cert-manager (if enabled)
+func getCertManagerChart(d *schema.ResourceData) (common.HelmChartSpec, bool) {
+	if !isCertManagerEnabled(d) {
+		return common.HelmChartSpec{}, false
+	}
+	return common.HelmChartSpec{
+		Name:      "cert-manager",
+		Chart:     "cert-manager",
+		Repo:      common.DefCertManagerChartRepo,
+		Version:   d.Get("addons.0.cert_manager.0.version").(string),
+		Namespace: common.DefCertManagerNamespace,
+		Values:    "installCRDs: true\n",
+	}, true
+}
+
+// getCertManagerIssuerManifest returns the ClusterIssuer (if any), that
+// must be applied once cert-manager is running
+func getCertManagerIssuerManifest(d *schema.ResourceData) (string, error) {
+	if !isCertManagerEnabled(d) {
+		return "", nil
+	}
+	if _, ok := d.GetOk("addons.0.cert_manager.0.issuer"); !ok {
+		return "", nil
+	}
+
+	const prefix = "addons.0.cert_manager.0.issuer.0."
+	name := d.Get(prefix + "name").(string)
+
+	docs := []string{}
+	issuer := []string{
+		"apiVersion: cert-manager.io/v1",
+		"kind: ClusterIssuer",
+		"metadata:",
+		"  name: " + name,
+		"spec:",
+	}
+
+	switch d.Get(prefix + "type").(string) {
+	case "selfsigned":
+		issuer = append(issuer, "  selfSigned: {}")
+
+	case "ca":
+		caCert := d.Get(prefix + "ca_cert").(string)
+		caKey := d.Get(prefix + "ca_key").(string)
+		if len(caCert) == 0 || len(caKey) == 0 {
+			return "", fmt.Errorf("ca_cert and ca_key are required for a ca issuer")
+		}
+		// the ClusterIssuer looks for the secret in the cert-manager namespace
+		docs = append(docs, strings.Join([]string{
+			"apiVersion: v1",
+			"kind: Secret",
+			"metadata:",
+			fmt.Sprintf("  name: %s-ca", name),
+			"  namespace: " + common.DefCertManagerNamespace,
+			"type: kubernetes.io/tls",
+			"data:",
+			"  tls.crt: " + base64.StdEncoding.EncodeToString([]byte(caCert)),
+			"  tls.key: " + base64.StdEncoding.EncodeToString([]byte(caKey)),
+		}, "\n"))
+		issuer = append(issuer,
+			"  ca:",
+			fmt.Sprintf("    secretName: %s-ca", name))
+
+	case "acme":
+		email := d.Get(prefix + "acme_email").(string)
+		if len(email) == 0 {
+			return "", fmt.Errorf("acme_email is required for an acme issuer")
+		}
+		issuer = append(issuer,
+			"  acme:",
+			"    email: "+email,
+			"    server: "+d.Get(prefix+"acme_server").(string),
+			"    privateKeySecretRef:",
+			fmt.Sprintf("      name: %s-account-key", name),
+			"    solvers:",
+			"      - http01:",
+			"          ingress:",
+			"            ingressClassName: "+d.Get(prefix+"ingress_class").(string))
+	}
+
+	docs = append(docs, strings.Join(issuer, "\n"))
+	return strings.Join(docs, "\n---\n") + "\n", nil
+}
+
+// getNodeLocalDNS returns the address of NodeLocal DNSCache (or an empty string if not enabled)
+func getNodeLocalDNS(d resourceGetter) string {
+	if _, ok := d.GetOk("addons.0.node_local_dns"); !ok || !d.Get("addons.0.node_local_dns.0.install").(bool) {
+		return ""
+	}
+	return d.Get("addons.0.node_local_dns.0.ip").(string)
+}
@@ -0,0 +1,199 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetIngressChart(t *testing.T) {
+	ingressFor := func(ingress map[string]interface{}) (string, bool) {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"addons": []interface{}{
+				map[string]interface{}{
+					"ingress": []interface{}{ingress},
+				},
+			},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		chart, ok := getIngressChart(d)
+		if ok && !isHelmEnabled(d) {
+			t.Fatalf("Error: Helm should be enabled for the ingress controller")
+		}
+		return chart.Values, ok
+	}
+
+	values, ok := ingressFor(map[string]interface{}{"class": "public", "replicas": 2})
+	if !ok {
+		t.Fatalf("Error: no chart for the ingress controller")
+	}
+	for _, expected := range []string{"name: public", "replicaCount: 2", "type: NodePort"} {
+		if !strings.Contains(values, expected) {
+			t.Fatalf("Error: %q not found in the values:\n%s", expected, values)
+		}
+	}
+
+	values, _ = ingressFor(map[string]interface{}{"service_type": "HostNetwork"})
+	if !strings.Contains(values, "hostNetwork: true") || strings.Contains(values, "replicaCount") {
+		t.Fatalf("Error: unexpected values for HostNetwork:\n%s", values)
+	}
+
+	if _, ok := ingressFor(map[string]interface{}{"install": false}); ok {
+		t.Fatalf("Error: the ingress controller should not be installed")
+	}
+}
+
+func TestGetMetalLBManifest(t *testing.T) {
+	manifestFor := func(metallb map[string]interface{}) (string, error) {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"addons": []interface{}{
+				map[string]interface{}{
+					"metallb": []interface{}{metallb},
+				},
+			},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		if _, ok := getMetalLBChart(d); ok && !isHelmEnabled(d) {
+			t.Fatalf("Error: Helm should be enabled for MetalLB")
+		}
+		return getMetalLBManifest(d)
+	}
+
+	manifest, err := manifestFor(map[string]interface{}{
+		"addresses": []interface{}{"192.168.1.240-192.168.1.250"},
+	})
+	if err != nil {
+		t.Fatalf("Error: could not get the MetalLB manifest: %s", err)
+	}
+	for _, expected := range []string{"kind: IPAddressPool", "- 192.168.1.240-192.168.1.250", "kind: L2Advertisement"} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in the manifest:\n%s", expected, manifest)
+		}
+	}
+
+	manifest, err = manifestFor(map[string]interface{}{
+		"addresses": []interface{}{"10.0.0.0/24"},
+		"mode":      "bgp",
+		"bgp_peer": []interface{}{
+			map[string]interface{}{"address": "10.0.0.1", "asn": 64501, "my_asn": 64500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error: could not get the MetalLB manifest: %s", err)
+	}
+	for _, expected := range []string{"kind: BGPPeer", "peerASN: 64501", "myASN: 64500", "kind: BGPAdvertisement"} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in the manifest:\n%s", expected, manifest)
+		}
+	}
+
+	if _, err := manifestFor(map[string]interface{}{"addresses": []interface{}{"10.0.0.0/24"}, "mode": "bgp"}); err == nil {
+		t.Fatalf("Error: bgp mode without peers should fail")
+	}
+}
+
+func TestGetStorageProvisioner(t *testing.T) {
+	resourceFor := func(storage map[string]interface{}) *schema.ResourceData {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"addons": []interface{}{
+				map[string]interface{}{
+					"storage": []interface{}{storage},
+				},
+			},
+		}
+		return schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	}
+
+	d := resourceFor(map[string]interface{}{})
+	if p, err := getStorageProvisioner(d); err != nil || p != "local-path" {
+		t.Fatalf("Error: unexpected storage provisioner: %q (%v)", p, err)
+	}
+	if _, ok := getStorageChart(d); ok {
+		t.Fatalf("Error: no chart expected for the local-path provisioner")
+	}
+
+	d = resourceFor(map[string]interface{}{"provisioner": "nfs"})
+	if _, err := getStorageProvisioner(d); err == nil {
+		t.Fatalf("Error: the nfs provisioner without a server should fail")
+	}
+
+	d = resourceFor(map[string]interface{}{"provisioner": "nfs", "nfs_server": "10.0.0.5", "nfs_path": "/exports/k8s", "default": false})
+	chart, ok := getStorageChart(d)
+	if !ok || !isHelmEnabled(d) {
+		t.Fatalf("Error: no chart for the nfs provisioner")
+	}
+	for _, expected := range []string{"server: 10.0.0.5", "path: /exports/k8s", "defaultClass: false"} {
+		if !strings.Contains(chart.Values, expected) {
+			t.Fatalf("Error: %q not found in the values:\n%s", expected, chart.Values)
+		}
+	}
+}
+
+func TestGetCertManagerIssuerManifest(t *testing.T) {
+	manifestFor := func(issuer map[string]interface{}) (string, error) {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"addons": []interface{}{
+				map[string]interface{}{
+					"cert_manager": []interface{}{
+						map[string]interface{}{
+							"issuer": []interface{}{issuer},
+						},
+					},
+				},
+			},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		if _, ok := getCertManagerChart(d); !ok || !isHelmEnabled(d) {
+			t.Fatalf("Error: no chart for cert-manager")
+		}
+		return getCertManagerIssuerManifest(d)
+	}
+
+	manifest, err := manifestFor(map[string]interface{}{"type": "selfsigned"})
+	if err != nil || !strings.Contains(manifest, "selfSigned: {}") {
+		t.Fatalf("Error: unexpected selfsigned issuer (%v):\n%s", err, manifest)
+	}
+
+	manifest, err = manifestFor(map[string]interface{}{"type": "ca", "name": "internal", "ca_cert": "CERT", "ca_key": "KEY"})
+	if err != nil {
+		t.Fatalf("Error: could not get the ca issuer: %s", err)
+	}
+	for _, expected := range []string{"kind: Secret", "tls.crt: Q0VSVA==", "secretName: internal-ca"} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in the manifest:\n%s", expected, manifest)
+		}
+	}
+
+	manifest, err = manifestFor(map[string]interface{}{"type": "acme", "acme_email": "admin@example.com"})
+	if err != nil {
+		t.Fatalf("Error: could not get the acme issuer: %s", err)
+	}
+	for _, expected := range []string{"email: admin@example.com", "letsencrypt.org", "ingressClassName: nginx"} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in the manifest:\n%s", expected, manifest)
+		}
+	}
+
+	if _, err := manifestFor(map[string]interface{}{"type": "ca"}); err == nil {
+		t.Fatalf("Error: a ca issuer without the certificate should fail")
+	}
+}
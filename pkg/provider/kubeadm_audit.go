@@ -0,0 +1,122 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// validateAuditPolicy checks that the audit policy is a `Policy`
+func validateAuditPolicy(v interface{}, k string) ([]string, []error) {
+	return validateConfigObject(v.(string), k, "Policy", "audit.k8s.io")
+}
+
+// validateAuditWebhookKubeconfig checks that the kubeconfig for the audit webhook can be parsed
+func validateAuditWebhookKubeconfig(v interface{}, k string) ([]string, []error) {
+	config, err := clientcmd.Load([]byte(v.(string)))
+	if err != nil {
+		return nil, []error{fmt.Errorf("%q is not a valid kubeconfig: %s", k, err)}
+	}
+	if len(config.Clusters) == 0 {
+		return nil, []error{fmt.Errorf("%q must have a cluster with the address of the webhook", k)}
+	}
+	return nil, nil
+}
+
+// getAuditSpec returns the audit backends for the API server
+func getAuditSpec(d resourceGetter) common.AuditSpec {
+	spec := common.AuditSpec{}
+	if !hasBlock(d, "audit") {
+		return spec
+	}
+	spec.PolicyPath = common.DefAuditPolicyPath
+
+	if hasBlock(d, "audit.0.log") {
+		spec.LogPath = d.Get("audit.0.log.0.path").(string)
+		spec.LogMaxAge = d.Get("audit.0.log.0.max_age").(int)
+		spec.LogMaxBackups = d.Get("audit.0.log.0.max_backups").(int)
+		spec.LogMaxSize = d.Get("audit.0.log.0.max_size").(int)
+	}
+
+	if hasBlock(d, "audit.0.webhook") {
+		spec.WebhookConfigPath = common.DefAuditWebhookConfigPath
+		spec.WebhookMode = d.Get("audit.0.webhook.0.mode").(string)
+		spec.WebhookBatchMaxSize = d.Get("audit.0.webhook.0.batch_max_size").(int)
+		spec.WebhookBatchMaxWait = d.Get("audit.0.webhook.0.batch_max_wait").(string)
+		spec.WebhookInitialBackoff = d.Get("audit.0.webhook.0.initial_backoff").(string)
+	}
+	return spec
+}
+
+// getAuditPolicy returns the audit policy uploaded to the control plane nodes
+// (or an empty string when auditing is disabled)
+func getAuditPolicy(d resourceGetter) string {
+	if !hasBlock(d, "audit") {
+		return ""
+	}
+	if policy := d.Get("audit.0.policy").(string); len(strings.TrimSpace(policy)) > 0 {
+		return policy
+	}
+	return common.DefAuditPolicy
+}
+
+// getAuditWebhookKubeconfig returns the kubeconfig for the audit webhook (or an empty string)
+func getAuditWebhookKubeconfig(d resourceGetter) string {
+	if !hasBlock(d, "audit") || !hasBlock(d, "audit.0.webhook") {
+		return ""
+	}
+	return d.Get("audit.0.webhook.0.kubeconfig").(string)
+}
+
+// checkAudit checks the audit settings
+func checkAudit(d resourceGetter) error {
+	spec := getAuditSpec(d)
+	if !spec.IsEnabled() {
+		return nil
+	}
+
+	// the flags managed in `audit` cannot be provided in the extra args too
+	for flag := range mapFromResourceData(d, "runtime.0.extra_args.0.api_server") {
+		if strings.HasPrefix(flag, "audit-") {
+			return fmt.Errorf("%q cannot be provided in the API server extra_args when using audit", flag)
+		}
+	}
+
+	if len(spec.LogPath) == 0 && len(spec.WebhookConfigPath) == 0 {
+		return fmt.Errorf("audit requires a log and/or a webhook")
+	}
+	if len(spec.WebhookConfigPath) > 0 && spec.WebhookMode != "batch" {
+		if spec.WebhookBatchMaxSize > 0 || len(spec.WebhookBatchMaxWait) > 0 {
+			return fmt.Errorf("audit.webhook.batch_max_size and audit.webhook.batch_max_wait can only be used in the batch mode")
+		}
+	}
+	return nil
+}
+
+// customizeDiffAudit validates the audit settings at plan time
+func customizeDiffAudit(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"audit", "runtime"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkAudit(d)
+}
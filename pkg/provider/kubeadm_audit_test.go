@@ -0,0 +1,117 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const testAuditWebhookKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+  - name: siem
+    cluster:
+      server: https://siem.example.com/k8s-audit
+contexts:
+  - name: webhook
+    context:
+      cluster: siem
+      user: siem
+current-context: webhook
+users:
+  - name: siem
+    user:
+      token: some-token
+`
+
+func TestValidateAuditWebhookKubeconfig(t *testing.T) {
+	if _, errs := validateAuditWebhookKubeconfig(testAuditWebhookKubeconfig, "kubeconfig"); len(errs) > 0 {
+		t.Fatalf("Error: unexpected errors: %v", errs)
+	}
+	for _, config := range []string{"apiVersion: v1\nkind: Config\n", "clusters: ["} {
+		if _, errs := validateAuditWebhookKubeconfig(config, "kubeconfig"); len(errs) == 0 {
+			t.Fatalf("Error: no errors for %q", config)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if spec := getAuditSpec(d); spec.IsEnabled() {
+		t.Fatalf("Error: unexpected audit spec: %+v", spec)
+	}
+	if policy := getAuditPolicy(d); policy != "" {
+		t.Fatalf("Error: unexpected audit policy: %q", policy)
+	}
+
+	// no backends
+	raw["audit"] = []interface{}{
+		map[string]interface{}{},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkAudit(d); err == nil {
+		t.Fatalf("Error: no error when there are no audit backends")
+	}
+
+	raw["audit"] = []interface{}{
+		map[string]interface{}{
+			"log": []interface{}{
+				map[string]interface{}{
+					"max_size": 100,
+				},
+			},
+			"webhook": []interface{}{
+				map[string]interface{}{
+					"kubeconfig":     testAuditWebhookKubeconfig,
+					"batch_max_size": 200,
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkAudit(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if policy := getAuditPolicy(d); policy != common.DefAuditPolicy {
+		t.Fatalf("Error: unexpected audit policy: %q", policy)
+	}
+	if config := getAuditWebhookKubeconfig(d); config != testAuditWebhookKubeconfig {
+		t.Fatalf("Error: unexpected kubeconfig for the webhook: %q", config)
+	}
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	args := initConfig.APIServer.ExtraArgs
+	if args["audit-log-path"] != common.DefAuditLogPath || args["audit-log-maxsize"] != "100" ||
+		args["audit-webhook-mode"] != "batch" || args["audit-webhook-batch-max-size"] != "200" {
+		t.Fatalf("Error: unexpected API server args: %v", args)
+	}
+
+	// the batching parameters require the batch mode
+	raw["audit"].([]interface{})[0].(map[string]interface{})["webhook"].([]interface{})[0].(map[string]interface{})["mode"] = "blocking"
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkAudit(d); err == nil {
+		t.Fatalf("Error: no error for batching parameters in the blocking mode")
+	}
+}
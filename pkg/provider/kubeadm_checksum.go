@@ -0,0 +1,99 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// configChecksumInputs are the arguments that are not fed to kubeadm but are
+// still part of the cluster definition (ie, the addons and their versions)
+var configChecksumInputs = []string{
+	"addons",
+	"helm",
+	"rbac",
+	"static_pod_patch",
+	"registry",
+}
+
+// getConfigChecksum returns a stable checksum of the effective cluster configuration:
+// the init/join configurations (with the bootstrap tokens redacted, so they do not
+// change the checksum), the CNI manifest, the manifests and the addons.
+func getConfigChecksum(d resourceGetter) (string, error) {
+	initConfig, joinConfig, err := renderConfigs(d, renderedTokenPlaceholder)
+	if err != nil {
+		return "", err
+	}
+	cniManifestHash, err := getCNIManifestHash(d)
+	if err != nil {
+		return "", err
+	}
+	manifestsHashes, err := getManifestsHashes(d)
+	if err != nil {
+		return "", err
+	}
+
+	values := map[string]interface{}{
+		"init":      initConfig,
+		"join":      joinConfig,
+		"cni":       cniManifestHash,
+		"manifests": manifestsHashes,
+	}
+	for _, k := range configChecksumInputs {
+		values[k] = d.Get(k)
+	}
+
+	// maps are marshalled with their keys sorted, so the result is stable
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// setConfigChecksum sets the `config_checksum` attribute
+func setConfigChecksum(d *schema.ResourceData) error {
+	checksum, err := getConfigChecksum(d)
+	if err != nil {
+		return err
+	}
+	return d.Set("config_checksum", checksum)
+}
+
+// customizeDiffConfigChecksum updates the checksum of the cluster configuration in the plan
+func customizeDiffConfigChecksum(d *schema.ResourceDiff, meta interface{}) error {
+	inputs := append(append(renderedConfigInputs, renderedConfigNestedInputs...), configChecksumInputs...)
+	for _, k := range append(inputs, "manifests") {
+		if !d.NewValueKnown(k) {
+			return d.SetNewComputed("config_checksum")
+		}
+	}
+
+	checksum, err := getConfigChecksum(d)
+	if err != nil {
+		return err
+	}
+
+	old, _ := d.GetChange("config_checksum")
+	if old.(string) == checksum {
+		return nil
+	}
+	return d.SetNew("config_checksum", checksum)
+}
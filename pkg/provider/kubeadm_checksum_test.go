@@ -0,0 +1,97 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestConfigChecksum(t *testing.T) {
+	planChecksum := func(raw map[string]interface{}) string {
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("Error: could not create the raw config: %s", err)
+		}
+		diff, err := dataSourceKubeadm().Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+		if err != nil {
+			t.Fatalf("Error: could not compute the diff: %s", err)
+		}
+		attr, ok := diff.Attributes["config_checksum"]
+		if !ok || len(attr.New) == 0 {
+			t.Fatalf("Error: no checksum in the plan: %+v", diff.Attributes)
+		}
+		return attr.New
+	}
+
+	newRaw := func() map[string]interface{} {
+		return map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"version":     "v1.15.0",
+			"api": []interface{}{
+				map[string]interface{}{
+					"internal": "10.10.0.1:6443",
+				},
+			},
+		}
+	}
+
+	checksum := planChecksum(newRaw())
+	if checksum != planChecksum(newRaw()) {
+		t.Fatalf("Error: the checksum is not stable for the same configuration")
+	}
+
+	raw := newRaw()
+	raw["version"] = "v1.16.0"
+	if checksum == planChecksum(raw) {
+		t.Fatalf("Error: the checksum did not change with a new version")
+	}
+
+	raw = newRaw()
+	raw["addons"] = []interface{}{
+		map[string]interface{}{
+			"dashboard": true,
+		},
+	}
+	if checksum == planChecksum(raw) {
+		t.Fatalf("Error: the checksum did not change with a new addon")
+	}
+}
+
+func TestConfigChecksumUnknown(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"api": []interface{}{
+			map[string]interface{}{
+				"external":  "k8s.example.com",
+				"alt_names": []interface{}{config.UnknownVariableValue},
+			},
+		},
+	}
+
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("Error: could not create the raw config: %s", err)
+	}
+	diff, err := dataSourceKubeadm().Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+	if err != nil {
+		t.Fatalf("Error: could not compute the diff: %s", err)
+	}
+	if !diff.Attributes["config_checksum"].NewComputed {
+		t.Fatalf("Error: the checksum should be computed: %+v", diff.Attributes["config_checksum"])
+	}
+}
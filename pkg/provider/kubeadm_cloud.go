@@ -0,0 +1,302 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// cloudConfigOption is an option in a section of the cloud config,
+// obtained from an attribute in the provider-specific block
+type cloudConfigOption struct {
+	key  string
+	attr string
+}
+
+// getCloudConfigSection returns a section of the cloud config (or an
+// empty string when none of the attributes has been provided)
+func getCloudConfigSection(d *schema.ResourceData, prefix string, section string, opts []cloudConfigOption) string {
+	lines := []string{}
+	for _, opt := range opts {
+		if value := d.Get(prefix + opt.attr).(string); len(value) > 0 {
+			lines = append(lines, fmt.Sprintf("%s=%s", opt.key, value))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%s]\n%s\n", section, strings.Join(lines, "\n"))
+}
+
+// getAWSCloudConfig generates the cloud config for AWS from the `cloud.aws` block
+func getAWSCloudConfig(d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("cloud.0.aws"); !ok {
+		return "", nil
+	}
+
+	return getCloudConfigSection(d, "cloud.0.aws.0.", "Global", []cloudConfigOption{
+		{"KubernetesClusterID", "cluster_id"},
+		{"VPC", "vpc"},
+		{"SubnetID", "subnet_id"},
+		{"Zone", "zone"},
+	}), nil
+}
+
+// getOpenStackCloudConfig generates the cloud config for OpenStack from the `cloud.openstack` block
+func getOpenStackCloudConfig(d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("cloud.0.openstack"); !ok {
+		return "", nil
+	}
+
+	const prefix = "cloud.0.openstack.0."
+	hasPassword := len(d.Get(prefix+"username").(string)) > 0 && len(d.Get(prefix+"password").(string)) > 0
+	hasAppCredential := len(d.Get(prefix+"application_credential_id").(string)) > 0 && len(d.Get(prefix+"application_credential_secret").(string)) > 0
+	if !hasPassword && !hasAppCredential {
+		return "", fmt.Errorf("the OpenStack cloud provider requires a username and password or an application credential")
+	}
+
+	global := getCloudConfigSection(d, prefix, "Global", []cloudConfigOption{
+		{"auth-url", "auth_url"},
+		{"username", "username"},
+		{"password", "password"},
+		{"application-credential-id", "application_credential_id"},
+		{"application-credential-secret", "application_credential_secret"},
+		{"tenant-id", "project_id"},
+		{"domain-name", "domain_name"},
+		{"region", "region"},
+	})
+	lb := getCloudConfigSection(d, prefix, "LoadBalancer", []cloudConfigOption{
+		{"subnet-id", "subnet_id"},
+		{"floating-network-id", "floating_network_id"},
+	})
+	if len(lb) > 0 {
+		return global + "\n" + lb, nil
+	}
+	return global, nil
+}
+
+// getVSphereConfig generates the vSphere configuration from the `cloud.vsphere`
+// block, for the cloud controller manager or (with a cluster ID) the CSI driver
+func getVSphereConfig(d *schema.ResourceData, clusterID string) string {
+	const prefix = "cloud.0.vsphere.0."
+	datacenters := stringsFromResourceData(d, prefix+"datacenters")
+	server := d.Get(prefix + "server").(string)
+	port := strconv.Itoa(d.Get(prefix + "port").(int))
+	insecure := strconv.FormatBool(d.Get(prefix + "insecure").(bool))
+
+	lines := []string{"[Global]"}
+	if len(clusterID) > 0 {
+		lines = append(lines, "cluster-id = "+strconv.Quote(clusterID))
+	} else {
+		lines = append(lines,
+			"port = "+strconv.Quote(port),
+			"insecure-flag = "+strconv.Quote(insecure))
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("[VirtualCenter %s]", strconv.Quote(server)),
+		"user = "+strconv.Quote(d.Get(prefix+"username").(string)),
+		"password = "+strconv.Quote(d.Get(prefix+"password").(string)),
+		"datacenters = "+strconv.Quote(strings.Join(datacenters, ",")))
+	if len(clusterID) > 0 {
+		lines = append(lines,
+			"port = "+strconv.Quote(port),
+			"insecure-flag = "+strconv.Quote(insecure))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// getVSphereCloudConfig generates the cloud config for vSphere from the `cloud.vsphere` block
+func getVSphereCloudConfig(d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("cloud.0.vsphere"); !ok {
+		return "", nil
+	}
+	return getVSphereConfig(d, ""), nil
+}
+
+// getVSphereCSIConfig generates the configuration for the vSphere CSI driver (if enabled)
+func getVSphereCSIConfig(d *schema.ResourceData) string {
+	if d.Get("cloud.0.provider").(string) != "vsphere" {
+		return ""
+	}
+	if _, ok := d.GetOk("cloud.0.vsphere"); !ok || !d.Get("cloud.0.vsphere.0.csi").(bool) {
+		return ""
+	}
+	return getVSphereConfig(d, d.Get("cloud.0.vsphere.0.cluster_id").(string))
+}
+
+// azureCloudConfig is the azure.json used by the Azure cloud controller manager
+type azureCloudConfig struct {
+	Cloud                       string `json:"cloud"`
+	TenantID                    string `json:"tenantId"`
+	SubscriptionID              string `json:"subscriptionId"`
+	AADClientID                 string `json:"aadClientId,omitempty"`
+	AADClientSecret             string `json:"aadClientSecret,omitempty"`
+	UseManagedIdentityExtension bool   `json:"useManagedIdentityExtension"`
+	ResourceGroup               string `json:"resourceGroup"`
+	Location                    string `json:"location"`
+	VnetName                    string `json:"vnetName,omitempty"`
+	VnetResourceGroup           string `json:"vnetResourceGroup,omitempty"`
+	SubnetName                  string `json:"subnetName,omitempty"`
+	SecurityGroupName           string `json:"securityGroupName,omitempty"`
+	RouteTableName              string `json:"routeTableName,omitempty"`
+	VMType                      string `json:"vmType"`
+	LoadBalancerSku             string `json:"loadBalancerSku"`
+	UseInstanceMetadata         bool   `json:"useInstanceMetadata"`
+}
+
+// getAzureCloudConfig generates the azure.json from the `cloud.azure` block
+func getAzureCloudConfig(d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("cloud.0.azure"); !ok {
+		return "", nil
+	}
+
+	const prefix = "cloud.0.azure.0."
+	config := azureCloudConfig{
+		Cloud:                       d.Get(prefix + "cloud").(string),
+		TenantID:                    d.Get(prefix + "tenant_id").(string),
+		SubscriptionID:              d.Get(prefix + "subscription_id").(string),
+		AADClientID:                 d.Get(prefix + "client_id").(string),
+		AADClientSecret:             d.Get(prefix + "client_secret").(string),
+		UseManagedIdentityExtension: d.Get(prefix + "use_managed_identity").(bool),
+		ResourceGroup:               d.Get(prefix + "resource_group").(string),
+		Location:                    d.Get(prefix + "location").(string),
+		VnetName:                    d.Get(prefix + "vnet_name").(string),
+		VnetResourceGroup:           d.Get(prefix + "vnet_resource_group").(string),
+		SubnetName:                  d.Get(prefix + "subnet_name").(string),
+		SecurityGroupName:           d.Get(prefix + "security_group_name").(string),
+		RouteTableName:              d.Get(prefix + "route_table_name").(string),
+		VMType:                      d.Get(prefix + "vm_type").(string),
+		LoadBalancerSku:             d.Get(prefix + "load_balancer_sku").(string),
+		UseInstanceMetadata:         true,
+	}
+	if !config.UseManagedIdentityExtension && (len(config.AADClientID) == 0 || len(config.AADClientSecret) == 0) {
+		return "", fmt.Errorf("the Azure cloud provider requires a client_id and client_secret or use_managed_identity")
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// getCloudConfigPath returns the path where the cloud config is uploaded in the
+// control plane nodes (or an empty string when there is no cloud config)
+func getCloudConfigPath(d resourceGetter) string {
+	cloudProvider := d.Get("cloud.0.provider").(string)
+	if len(cloudProvider) == 0 {
+		return ""
+	}
+	if c, ok := d.GetOk("cloud.0.config"); !ok || len(c.(string)) == 0 {
+		if _, ok := d.GetOk(fmt.Sprintf("cloud.0.%s", cloudProvider)); !ok {
+			return ""
+		}
+	}
+
+	if path, ok := d.GetOk("cloud.0.config_path"); ok && len(path.(string)) > 0 {
+		return path.(string)
+	}
+	if cloudProvider == "azure" {
+		return common.DefAzureCloudConfigFilename
+	}
+	return common.DefCloudConfigFilename
+}
+
+// getCloudConfig returns the cloud config, either provided by the user in
+// `cloud.config` or generated from the provider-specific block
+func getCloudConfig(d *schema.ResourceData) (string, error) {
+	cloudProvider := d.Get("cloud.0.provider").(string)
+
+	cloudConfig := ""
+	if c, ok := d.GetOk("cloud.0.config"); ok && len(c.(string)) > 0 {
+		cloudConfig = c.(string)
+	} else {
+		var err error
+		switch cloudProvider {
+		case "aws":
+			cloudConfig, err = getAWSCloudConfig(d)
+		case "openstack":
+			cloudConfig, err = getOpenStackCloudConfig(d)
+		case "vsphere":
+			cloudConfig, err = getVSphereCloudConfig(d)
+		case "azure":
+			cloudConfig, err = getAzureCloudConfig(d)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(cloudConfig) == 0 {
+		for _, mandatory := range common.DefCloudConfigMandatory {
+			if mandatory == cloudProvider {
+				return "", fmt.Errorf("a cloud config is required for the %q cloud provider", cloudProvider)
+			}
+		}
+	}
+	return cloudConfig, nil
+}
+
+// getCloudManagerVersion returns the version of the cloud controller
+// manager (for the out-of-tree cloud providers)
+func getCloudManagerVersion(d *schema.ResourceData) string {
+	cloudProvider := d.Get("cloud.0.provider").(string)
+	if version, ok := d.GetOk(fmt.Sprintf("cloud.0.%s.0.version", cloudProvider)); ok {
+		return version.(string)
+	}
+	return common.DefCloudControllerManagerVersions[cloudProvider]
+}
+
+// getCinderCSIChart returns the chart for the OpenStack Cinder CSI driver (if enabled)
+func getCinderCSIChart(d *schema.ResourceData) (common.HelmChartSpec, bool) {
+	if d.Get("cloud.0.provider").(string) != "openstack" {
+		return common.HelmChartSpec{}, false
+	}
+	if _, ok := d.GetOk("cloud.0.openstack"); !ok || !d.Get("cloud.0.openstack.0.cinder_csi").(bool) {
+		return common.HelmChartSpec{}, false
+	}
+
+	// use the `cloud-config` secret created with the cloud controller manager
+	values := []string{
+		"secret:",
+		"  enabled: true",
+		"  create: false",
+		"  name: cloud-config",
+	}
+	if dir := getKubeletRootDir(d); dir != common.DefKubeletRootDir {
+		values = append(values,
+			"csi:",
+			"  plugin:",
+			"    nodePlugin:",
+			fmt.Sprintf("      kubeletDir: %s", dir))
+	}
+
+	return common.HelmChartSpec{
+		Name:      "cinder-csi",
+		Chart:     "openstack-cinder-csi",
+		Repo:      common.DefCinderCSIChartRepo,
+		Version:   common.DefCinderCSIChartVersion,
+		Namespace: "kube-system",
+		Values:    strings.Join(values, "\n") + "\n",
+	}, true
+}
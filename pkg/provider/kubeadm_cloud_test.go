@@ -0,0 +1,157 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetCloudConfig(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"cloud": []interface{}{
+			map[string]interface{}{
+				"provider": "aws",
+				"aws": []interface{}{
+					map[string]interface{}{
+						"cluster_id": "my-cluster",
+						"vpc":        "vpc-0a1b2c3d",
+					},
+				},
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	expected := "[Global]\nKubernetesClusterID=my-cluster\nVPC=vpc-0a1b2c3d\n"
+	if cloudConfig, _ := getCloudConfig(d); cloudConfig != expected {
+		t.Fatalf("Error: unexpected cloud config:\n%s\nexpected:\n%s", cloudConfig, expected)
+	}
+	if path := getCloudConfigPath(d); path != common.DefCloudConfigFilename {
+		t.Fatalf("Error: unexpected cloud config path: %q", path)
+	}
+
+	raw["cloud"] = []interface{}{
+		map[string]interface{}{
+			"provider":    "openstack",
+			"config":      "[Global]\nusername=user\n",
+			"config_path": "/etc/openstack/cloud.conf",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if path := getCloudConfigPath(d); path != "/etc/openstack/cloud.conf" {
+		t.Fatalf("Error: unexpected cloud config path: %q", path)
+	}
+	if cloudConfig, _ := getCloudConfig(d); cloudConfig != "[Global]\nusername=user\n" {
+		t.Fatalf("Error: unexpected cloud config:\n%s", cloudConfig)
+	}
+	if _, ok := getCinderCSIChart(d); ok {
+		t.Fatalf("Error: the Cinder CSI driver requires the openstack block")
+	}
+
+	raw["cloud"] = []interface{}{
+		map[string]interface{}{
+			"provider": "openstack",
+			"openstack": []interface{}{
+				map[string]interface{}{
+					"auth_url":  "https://keystone:5000/v3",
+					"username":  "user",
+					"password":  "pass",
+					"subnet_id": "6937f8fa",
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	expected = "[Global]\nauth-url=https://keystone:5000/v3\nusername=user\npassword=pass\n\n[LoadBalancer]\nsubnet-id=6937f8fa\n"
+	if cloudConfig, err := getCloudConfig(d); err != nil || cloudConfig != expected {
+		t.Fatalf("Error: unexpected cloud config (%v):\n%s\nexpected:\n%s", err, cloudConfig, expected)
+	}
+	if _, ok := getCinderCSIChart(d); !ok || !isHelmEnabled(d) {
+		t.Fatalf("Error: no chart for the Cinder CSI driver")
+	}
+	if version := getCloudManagerVersion(d); version != common.DefCloudControllerManagerVersions["openstack"] {
+		t.Fatalf("Error: unexpected cloud controller manager version: %q", version)
+	}
+
+	raw["cloud"] = []interface{}{
+		map[string]interface{}{
+			"provider": "vsphere",
+			"vsphere": []interface{}{
+				map[string]interface{}{
+					"server":      "vc.example.com",
+					"username":    "admin@vsphere.local",
+					"password":    `pa"ss`,
+					"datacenters": []interface{}{"dc1", "dc2"},
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	cloudConfig, err := getCloudConfig(d)
+	if err != nil {
+		t.Fatalf("Error: could not get the vSphere cloud config: %s", err)
+	}
+	for _, expected := range []string{`[VirtualCenter "vc.example.com"]`, `password = "pa\"ss"`, `datacenters = "dc1,dc2"`, `port = "443"`} {
+		if !strings.Contains(cloudConfig, expected) {
+			t.Fatalf("Error: %q not found in the cloud config:\n%s", expected, cloudConfig)
+		}
+	}
+	if csiConfig := getVSphereCSIConfig(d); !strings.Contains(csiConfig, `cluster-id = "kubernetes"`) {
+		t.Fatalf("Error: unexpected vSphere CSI config:\n%s", csiConfig)
+	}
+
+	raw["cloud"] = []interface{}{
+		map[string]interface{}{
+			"provider": "azure",
+			"azure": []interface{}{
+				map[string]interface{}{
+					"tenant_id":            "tenant",
+					"subscription_id":      "subscription",
+					"use_managed_identity": true,
+					"resource_group":       "k8s",
+					"location":             "westeurope",
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	cloudConfig, err = getCloudConfig(d)
+	if err != nil {
+		t.Fatalf("Error: could not get the Azure cloud config: %s", err)
+	}
+	for _, expected := range []string{`"tenantId": "tenant"`, `"useManagedIdentityExtension": true`, `"vmType": "standard"`} {
+		if !strings.Contains(cloudConfig, expected) {
+			t.Fatalf("Error: %q not found in the cloud config:\n%s", expected, cloudConfig)
+		}
+	}
+	if strings.Contains(cloudConfig, "aadClientSecret") {
+		t.Fatalf("Error: unexpected client secret in the cloud config:\n%s", cloudConfig)
+	}
+
+	raw["cloud"] = []interface{}{
+		map[string]interface{}{
+			"provider": "openstack",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := getCloudConfig(d); err == nil {
+		t.Fatalf("Error: a cloud config should be required for openstack")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// resourceGetter can get values from a ResourceData as well as from a ResourceDiff
+type resourceGetter interface {
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+}
+
+// getCNIVersion returns the version of the pre-defined CNI plugin (or the default one)
+func getCNIVersion(d resourceGetter) string {
+	if v := d.Get("cni.0.version").(string); len(v) > 0 {
+		return v
+	}
+	return common.DefCNIVersions[strings.ToLower(d.Get("cni.0.plugin").(string))]
+}
+
+// getCNIManifestHash returns a hash that identifies the CNI manifest applied in the
+// cluster: the contents of the user-provided manifest (or its URL), or the plugin and
+// version of the pre-defined ones
+func getCNIManifestHash(d resourceGetter) (string, error) {
+	contents := ""
+	if m := strings.TrimSpace(d.Get("cni.0.plugin_manifest").(string)); len(m) > 0 {
+		manifest := ssh.NewManifest(m)
+		switch {
+		case manifest.Path != "":
+			data, err := ioutil.ReadFile(manifest.Path)
+			if err != nil {
+				return "", fmt.Errorf("could not read the CNI manifest %q: %s", manifest.Path, err)
+			}
+			contents = "manifest:" + string(data)
+		case manifest.URL != "":
+			// (the URL could have some variables, like the version)
+			contents = fmt.Sprintf("url:%s:%s", manifest.URL, d.Get("cni.0.version").(string))
+		default:
+			contents = "manifest:" + manifest.Inline
+		}
+	} else if plugin := strings.ToLower(d.Get("cni.0.plugin").(string)); len(plugin) > 0 {
+		contents = fmt.Sprintf("plugin:%s:%s", plugin, getCNIVersion(d))
+	} else {
+		return "", nil
+	}
+
+	hash := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// customizeDiffCNIManifest updates the hash of the CNI manifest in the plan, forcing
+// a new resource when it has changed (ie, when the contents of a local manifest change)
+func customizeDiffCNIManifest(d *schema.ResourceDiff, meta interface{}) error {
+	hash, err := getCNIManifestHash(d)
+	if err != nil {
+		return err
+	}
+
+	old, _ := d.GetChange("cni_manifest_hash")
+	if old.(string) == hash {
+		return nil
+	}
+	if len(d.Id()) > 0 && len(old.(string)) == 0 {
+		// created before we were tracking the manifest: we cannot know if it has changed
+		return nil
+	}
+
+	if err := d.SetNew("cni_manifest_hash", hash); err != nil {
+		return err
+	}
+	if len(d.Id()) > 0 {
+		return d.ForceNew("cni_manifest_hash")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetCNIManifestHash(t *testing.T) {
+	hashFor := func(cni map[string]interface{}) string {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"cni":         []interface{}{cni},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		hash, err := getCNIManifestHash(d)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return hash
+	}
+
+	flannel := hashFor(map[string]interface{}{"plugin": "flannel"})
+	if flannel == "" {
+		t.Fatalf("Error: no hash for the flannel plugin")
+	}
+	if flannel != hashFor(map[string]interface{}{"plugin": "flannel", "version": "v0.11.0"}) {
+		t.Fatalf("Error: the default version should have the same hash")
+	}
+	if flannel == hashFor(map[string]interface{}{"plugin": "flannel", "version": "v0.12.0"}) {
+		t.Fatalf("Error: a different version should have a different hash")
+	}
+
+	// a local manifest: the hash must change when the contents change
+	f, err := ioutil.TempFile("", "cni-manifest")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(f.Name())
+	_ = ioutil.WriteFile(f.Name(), []byte("kind: DaemonSet"), 0644)
+	first := hashFor(map[string]interface{}{"plugin_manifest": f.Name()})
+	_ = ioutil.WriteFile(f.Name(), []byte("kind: DaemonSet\n# changed"), 0644)
+	if first == hashFor(map[string]interface{}{"plugin_manifest": f.Name()}) {
+		t.Fatalf("Error: the hash should change when the manifest changes")
+	}
+}
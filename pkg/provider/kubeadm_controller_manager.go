@@ -0,0 +1,122 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// mask size used by the controller manager when no `node_cidr_mask_size` is provided
+	defNodeCIDRMaskSize = 24
+
+	// the controller manager refuses to split the pods CIDR in more than 2^16 node CIDRs
+	maxNodeCIDRMaskBits = 16
+)
+
+// controllerManagerFlags are the flags managed with the typed settings in `controller_manager`
+var controllerManagerFlags = map[string]string{
+	"node_cidr_mask_size":         "node-cidr-mask-size",
+	"allocate_node_cidrs":         "allocate-node-cidrs",
+	"cluster_signing_cert_file":   "cluster-signing-cert-file",
+	"cluster_signing_key_file":    "cluster-signing-key-file",
+	"terminated_pod_gc_threshold": "terminated-pod-gc-threshold",
+}
+
+// getControllerManagerSpec returns the typed settings for the controller manager
+func getControllerManagerSpec(d resourceGetter) common.ControllerManagerSpec {
+	spec := common.ControllerManagerSpec{}
+	if !hasBlock(d, "controller_manager") {
+		return spec
+	}
+	spec.NodeCIDRMaskSize = d.Get("controller_manager.0.node_cidr_mask_size").(int)
+	spec.DisableNodeCIDRAllocation = !d.Get("controller_manager.0.allocate_node_cidrs").(bool)
+	spec.SigningCertFile = d.Get("controller_manager.0.cluster_signing_cert_file").(string)
+	spec.SigningKeyFile = d.Get("controller_manager.0.cluster_signing_key_file").(string)
+	spec.TerminatedPodGCThreshold = d.Get("controller_manager.0.terminated_pod_gc_threshold").(int)
+	return spec
+}
+
+// getPodsCIDR returns the CIDR used for the pods
+func getPodsCIDR(d resourceGetter) string {
+	if hasBlock(d, "network") {
+		if pods := d.Get("network.0.pods").(string); len(pods) > 0 {
+			return pods
+		}
+	}
+	return common.DefPodCIDR
+}
+
+// checkControllerManager checks the controller manager settings, validating the
+// size of the node CIDRs against the pods CIDR
+func checkControllerManager(d resourceGetter) error {
+	if !hasBlock(d, "controller_manager") {
+		return nil
+	}
+	spec := getControllerManagerSpec(d)
+
+	// the typed settings cannot be provided in the extra args too
+	extraArgs := mapFromResourceData(d, "runtime.0.extra_args.0.controller_manager")
+	for attr, flag := range controllerManagerFlags {
+		if _, ok := extraArgs[flag]; ok {
+			return fmt.Errorf("%q must be set with controller_manager.%s, not in the controller manager extra_args", flag, attr)
+		}
+	}
+
+	if (len(spec.SigningCertFile) > 0) != (len(spec.SigningKeyFile) > 0) {
+		return fmt.Errorf("controller_manager.cluster_signing_cert_file and controller_manager.cluster_signing_key_file must be provided together")
+	}
+
+	if spec.DisableNodeCIDRAllocation {
+		if spec.NodeCIDRMaskSize > 0 {
+			return fmt.Errorf("controller_manager.node_cidr_mask_size cannot be used when the node CIDRs are not allocated")
+		}
+		return nil
+	}
+
+	_, podsNet, err := net.ParseCIDR(getPodsCIDR(d))
+	if err != nil {
+		return fmt.Errorf("invalid pods CIDR: %s", err)
+	}
+	prefix, bits := podsNet.Mask.Size()
+
+	maskSize := spec.NodeCIDRMaskSize
+	if maskSize == 0 {
+		maskSize = defNodeCIDRMaskSize
+	}
+	if maskSize <= prefix || maskSize > bits {
+		return fmt.Errorf("the node CIDR mask size (/%d) must be longer than the pods CIDR %s (/%d)", maskSize, podsNet, prefix)
+	}
+	if maskSize-prefix > maxNodeCIDRMaskBits {
+		return fmt.Errorf("the node CIDR mask size (/%d) would split the pods CIDR %s in more than %d node CIDRs",
+			maskSize, podsNet, 1<<maxNodeCIDRMaskBits)
+	}
+	return nil
+}
+
+// customizeDiffControllerManager validates the controller manager settings at plan time
+func customizeDiffControllerManager(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"controller_manager", "network", "runtime"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkControllerManager(d)
+}
@@ -0,0 +1,100 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestControllerManager(t *testing.T) {
+	tests := []struct {
+		cm      map[string]interface{}
+		pods    string
+		runtime map[string]interface{}
+		valid   bool
+	}{
+		{cm: nil, valid: true},
+		{cm: map[string]interface{}{"node_cidr_mask_size": 26}, valid: true},
+		{cm: map[string]interface{}{"node_cidr_mask_size": 26}, pods: "10.244.0.0/26", valid: false},
+		{cm: map[string]interface{}{"node_cidr_mask_size": 28}, pods: "10.0.0.0/8", valid: false},
+		{cm: map[string]interface{}{"terminated_pod_gc_threshold": 100}, pods: "10.244.0.0/25", valid: false},
+		{cm: map[string]interface{}{"allocate_node_cidrs": false}, pods: "10.244.0.0/25", valid: true},
+		{cm: map[string]interface{}{"allocate_node_cidrs": false, "node_cidr_mask_size": 26}, valid: false},
+		{cm: map[string]interface{}{"cluster_signing_cert_file": "/etc/signing/ca.crt"}, valid: false},
+		{
+			cm:      map[string]interface{}{"node_cidr_mask_size": 26},
+			runtime: map[string]interface{}{"controller_manager": map[string]interface{}{"node-cidr-mask-size": "25"}},
+			valid:   false,
+		},
+	}
+
+	for i, test := range tests {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+		}
+		if test.cm != nil {
+			raw["controller_manager"] = []interface{}{test.cm}
+		}
+		if len(test.pods) > 0 {
+			raw["network"] = []interface{}{map[string]interface{}{"pods": test.pods}}
+		}
+		if test.runtime != nil {
+			raw["runtime"] = []interface{}{map[string]interface{}{"extra_args": []interface{}{test.runtime}}}
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		if err := checkControllerManager(d); (err == nil) != test.valid {
+			t.Fatalf("Error: test %d: unexpected validation result: %v", i, err)
+		}
+	}
+
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"controller_manager": []interface{}{
+			map[string]interface{}{
+				"node_cidr_mask_size":         26,
+				"allocate_node_cidrs":         true,
+				"cluster_signing_cert_file":   "/etc/signing/ca.crt",
+				"cluster_signing_key_file":    "/etc/kubernetes/pki/signing.key",
+				"terminated_pod_gc_threshold": 500,
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	args := initConfig.ControllerManager.ExtraArgs
+	for k, v := range map[string]string{
+		"node-cidr-mask-size":         "26",
+		"terminated-pod-gc-threshold": "500",
+		"cluster-signing-cert-file":   "/etc/signing/ca.crt",
+		"cluster-signing-key-file":    "/etc/kubernetes/pki/signing.key",
+	} {
+		if args[k] != v {
+			t.Fatalf("Error: wrong controller manager arg %q: %q", k, args[k])
+		}
+	}
+	if _, ok := args["allocate-node-cidrs"]; ok {
+		t.Fatalf("Error: unexpected allocate-node-cidrs arg: %v", args)
+	}
+	// only the signing cert outside the PKI dir must be mounted
+	volumes := initConfig.ControllerManager.ExtraVolumes
+	if len(volumes) != 1 || volumes[0].HostPath != "/etc/signing/ca.crt" {
+		t.Fatalf("Error: unexpected controller manager volumes: %+v", volumes)
+	}
+}
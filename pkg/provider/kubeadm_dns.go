@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getDNSForwardZones returns the DNS zones forwarded to some specific servers
+func getDNSForwardZones(d resourceGetter) []common.DNSForwardZoneSpec {
+	res := []common.DNSForwardZoneSpec{}
+	zones, ok := d.Get("network.0.dns.0.forward_zone").([]interface{})
+	if !ok {
+		return res
+	}
+	for i := range zones {
+		prefix := fmt.Sprintf("network.0.dns.0.forward_zone.%d", i)
+		res = append(res, common.DNSForwardZoneSpec{
+			Zone:    d.Get(prefix + ".zone").(string),
+			Servers: stringsFromResourceData(d, prefix+".servers"),
+		})
+	}
+	return res
+}
+
+// checkDNS checks the DNS settings
+func checkDNS(d resourceGetter) error {
+	upstream := stringsFromResourceData(d, "network.0.dns.0.upstream")
+	if len(stringsFromResourceData(d, "network.0.dns.0.search")) > 0 && len(upstream) == 0 {
+		return fmt.Errorf("network.dns.search can only be used with some network.dns.upstream servers")
+	}
+
+	seen := map[string]bool{}
+	for _, zone := range getDNSForwardZones(d) {
+		if domain, ok := d.GetOk("network.0.dns.0.domain"); ok && zone.Zone == domain.(string) {
+			return fmt.Errorf("network.dns.forward_zone cannot be used for the cluster domain %q", zone.Zone)
+		}
+		if seen[zone.Zone] {
+			return fmt.Errorf("duplicate network.dns.forward_zone %q", zone.Zone)
+		}
+		seen[zone.Zone] = true
+	}
+	return nil
+}
+
+// customizeDiffDNS validates the DNS settings at plan time
+func customizeDiffDNS(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("network") {
+		return nil
+	}
+	return checkDNS(d)
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestDNS(t *testing.T) {
+	zone := func(name string) map[string]interface{} {
+		return map[string]interface{}{"zone": name, "servers": []interface{}{"10.0.0.53"}}
+	}
+
+	tests := []struct {
+		dns   map[string]interface{}
+		zones int
+		valid bool
+	}{
+		{dns: map[string]interface{}{"upstream": []interface{}{"8.8.8.8"}, "search": []interface{}{"example.com"}}, valid: true},
+		{dns: map[string]interface{}{"search": []interface{}{"example.com"}}, valid: false},
+		{dns: map[string]interface{}{"forward_zone": []interface{}{zone("corp.example.com"), zone("lab.example.com")}}, zones: 2, valid: true},
+		{dns: map[string]interface{}{"forward_zone": []interface{}{zone("corp.example.com"), zone("corp.example.com")}}, zones: 2, valid: false},
+		{dns: map[string]interface{}{"forward_zone": []interface{}{zone("cluster.local")}}, zones: 1, valid: false},
+	}
+
+	for i, test := range tests {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"network": []interface{}{
+				map[string]interface{}{
+					"dns": []interface{}{test.dns},
+				},
+			},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+		if zones := getDNSForwardZones(d); len(zones) != test.zones {
+			t.Fatalf("Error: test %d: unexpected number of forward zones: %d", i, len(zones))
+		}
+		if err := checkDNS(d); (err == nil) != test.valid {
+			t.Fatalf("Error: test %d: unexpected validation result: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getEtcdMode returns the etcd topology, inferring it from the endpoints when
+// no `etcd.mode` has been provided
+func getEtcdMode(d resourceGetter) string {
+	if mode, ok := d.GetOk("etcd.0.mode"); ok && len(mode.(string)) > 0 {
+		return mode.(string)
+	}
+	if len(stringsFromResourceData(d, "etcd.0.endpoints")) > 0 {
+		return common.EtcdModeExternal
+	}
+	return common.EtcdModeStacked
+}
+
+// getEtcdDataDir returns the data directory for the local etcd (or the default one)
+func getEtcdDataDir(d resourceGetter) string {
+	if dir, ok := d.GetOk("etcd.0.local.0.data_dir"); ok && len(dir.(string)) > 0 {
+		return dir.(string)
+	}
+	return common.DefEtcdDataDir
+}
+
+// checkEtcdMode checks that the settings are valid for the etcd topology
+func checkEtcdMode(d resourceGetter) error {
+	mode := getEtcdMode(d)
+	hasEndpoints := len(stringsFromResourceData(d, "etcd.0.endpoints")) > 0
+
+	switch mode {
+	case common.EtcdModeExternal:
+		if !hasEndpoints {
+			return fmt.Errorf("etcd.endpoints must be provided for an %q etcd", mode)
+		}
+		if hasBlock(d, "etcd.0.local") {
+			return fmt.Errorf("etcd.local cannot be used with an %q etcd", mode)
+		}
+		for _, k := range []string{"images.0.etcd_repo", "images.0.etcd_version"} {
+			if v, ok := d.GetOk(k); ok && len(v.(string)) > 0 {
+				return fmt.Errorf("%s cannot be used with an %q etcd", k, mode)
+			}
+		}
+		if hasBlock(d, "etcd_client_cert") {
+			return fmt.Errorf("etcd_client_cert cannot be used with an %q etcd: its CA is not managed by the provider", mode)
+		}
+	case common.EtcdModeStacked:
+		if hasEndpoints {
+			return fmt.Errorf("etcd.endpoints cannot be used with a %q etcd", mode)
+		}
+	}
+	return nil
+}
+
+// customizeDiffEtcd validates the etcd settings at plan time
+func customizeDiffEtcd(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"etcd", "images", "etcd_client_cert"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkEtcdMode(d)
+}
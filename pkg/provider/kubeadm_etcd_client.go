@@ -0,0 +1,155 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	certutil "k8s.io/client-go/util/cert"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// annotation where kubeadm stores the client URL in the etcd static pods
+	etcdAdvertiseClientURLsAnnotation = "kubeadm.kubernetes.io/etcd.advertise-client-urls"
+
+	// the etcd client certificate is renewed when it expires in less than this
+	etcdClientCertRenewMargin = 30 * 24 * time.Hour
+)
+
+// getEtcdEndpoints returns the client and metrics endpoints of the etcd members
+// running in the control plane nodes, from the etcd static pods. The metrics
+// endpoints are only reachable when the etcd metrics are exposed.
+func getEtcdEndpoints(client kubernetes.Interface, metricsExposed bool) ([]string, []string, error) {
+	pods, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+		LabelSelector: controlPlaneComponentLabel + "=etcd",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// sort the pods by name, so we get a stable list
+	items := pods.Items
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	endpoints, metricsEndpoints := []string{}, []string{}
+	for _, pod := range items {
+		host := pod.Status.HostIP
+		if len(host) == 0 {
+			continue
+		}
+		endpoint := pod.Annotations[etcdAdvertiseClientURLsAnnotation]
+		if len(endpoint) == 0 {
+			endpoint = "https://" + net.JoinHostPort(host, strconv.Itoa(common.DefEtcdClientPort))
+		}
+		endpoints = append(endpoints, endpoint)
+		if metricsExposed {
+			metricsEndpoints = append(metricsEndpoints, "http://"+net.JoinHostPort(host, strconv.Itoa(common.DefEtcdMetricsPort)))
+		}
+	}
+	return endpoints, metricsEndpoints, nil
+}
+
+// needsNewEtcdClientCert returns true if the current etcd client certificate must be
+// (re)created: when there is no certificate, when it is for a different common name,
+// when it has not been signed by the etcd CA or when it is about to expire
+func needsNewEtcdClientCert(crt string, caCrt string, commonName string, now time.Time) bool {
+	certs, err := certutil.ParseCertsPEM([]byte(crt))
+	if err != nil {
+		return true
+	}
+	cas, err := certutil.ParseCertsPEM([]byte(caCrt))
+	if err != nil {
+		return true
+	}
+	cert := certs[0]
+	switch {
+	case cert.Subject.CommonName != commonName:
+		return true
+	case cert.CheckSignatureFrom(cas[0]) != nil:
+		return true
+	case now.Add(etcdClientCertRenewMargin).After(cert.NotAfter):
+		return true
+	}
+	return false
+}
+
+// updateEtcdClient sets the `etcd_client` with a client certificate for etcd (signed by
+// the etcd CA) and the etcd endpoints, so some external tools (ie, for monitoring or
+// backups) can connect to etcd. The certificate is renewed when it is about to expire,
+// and the endpoints are refreshed (when the cluster is reachable) on every read.
+func updateEtcdClient(d *schema.ResourceData) error {
+	if !hasBlock(d, "etcd_client_cert") {
+		return d.Set("etcd_client", []interface{}{})
+	}
+
+	config := common.GetProvisionerConfig(d)
+	caCrt, _ := config["etcd_crt"].(string)
+	caKey, _ := config["etcd_key"].(string)
+	if len(caCrt) == 0 || len(caKey) == 0 {
+		ssh.Debug("no etcd CA found in the 'config': the etcd client certificate cannot be created")
+		return nil
+	}
+
+	crt := d.Get("etcd_client.0.crt").(string)
+	key := d.Get("etcd_client.0.key").(string)
+	commonName := d.Get("etcd_client_cert.0.common_name").(string)
+	if needsNewEtcdClientCert(crt, caCrt, commonName, time.Now()) {
+		ssh.Debug("creating a client certificate for etcd for %q", commonName)
+		crtBytes, keyBytes, err := common.NewClientCert([]byte(caCrt), []byte(caKey), commonName, nil)
+		if err != nil {
+			return fmt.Errorf("could not create the etcd client certificate: %s", err)
+		}
+		crt, key = string(crtBytes), string(keyBytes)
+	}
+	expiration, err := common.GetCertExpiration(crt)
+	if err != nil {
+		return err
+	}
+
+	// keep the previous endpoints when the cluster cannot be reached
+	endpoints := stringsFromResourceData(d, "etcd_client.0.endpoints")
+	metricsEndpoints := stringsFromResourceData(d, "etcd_client.0.metrics_endpoints")
+	if client, err := getKubeClient(d); err != nil {
+		ssh.Debug("cannot refresh the etcd endpoints: %s", err)
+	} else {
+		metricsExposed := d.Get("observability.0.expose_control_plane_metrics").(bool)
+		if e, m, err := getEtcdEndpoints(client, metricsExposed); err != nil {
+			ssh.Debug("cannot refresh the etcd endpoints: %s", err)
+		} else {
+			endpoints, metricsEndpoints = e, m
+		}
+	}
+
+	return d.Set("etcd_client", []map[string]interface{}{
+		{
+			"ca_crt":            caCrt,
+			"crt":               crt,
+			"key":               key,
+			"expires":           expiration.UTC().Format(time.RFC3339),
+			"endpoints":         endpoints,
+			"metrics_endpoints": metricsEndpoints,
+		},
+	})
+}
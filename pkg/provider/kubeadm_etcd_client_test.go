@@ -0,0 +1,149 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetEtcdEndpoints(t *testing.T) {
+	newEtcdPod := func(name, hostIP string, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   metav1.NamespaceSystem,
+				Labels:      map[string]string{controlPlaneComponentLabel: "etcd"},
+				Annotations: annotations,
+			},
+			Status: corev1.PodStatus{HostIP: hostIP},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		newEtcdPod("etcd-master-1", "10.0.0.2", nil),
+		newEtcdPod("etcd-master-0", "10.0.0.1", map[string]string{
+			etcdAdvertiseClientURLsAnnotation: "https://10.0.0.1:2379",
+		}),
+	)
+
+	endpoints, metricsEndpoints, err := getEtcdEndpoints(client, true)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(endpoints) != 2 || endpoints[0] != "https://10.0.0.1:2379" || endpoints[1] != "https://10.0.0.2:2379" {
+		t.Fatalf("Error: unexpected endpoints: %v", endpoints)
+	}
+	if len(metricsEndpoints) != 2 || metricsEndpoints[0] != "http://10.0.0.1:2381" {
+		t.Fatalf("Error: unexpected metrics endpoints: %v", metricsEndpoints)
+	}
+
+	// the metrics are only reachable when they are exposed
+	_, metricsEndpoints, err = getEtcdEndpoints(client, false)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(metricsEndpoints) > 0 {
+		t.Fatalf("Error: unexpected metrics endpoints: %v", metricsEndpoints)
+	}
+}
+
+func TestUpdateEtcdClient(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "etcd-ca"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	caCrt := string(pkiutil.EncodeCertPEM(caCert))
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"etcd_crt": caCrt,
+			"etcd_key": string(caKeyPEM),
+		},
+		"etcd_client_cert": []interface{}{
+			map[string]interface{}{},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := updateEtcdClient(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	crt := d.Get("etcd_client.0.crt").(string)
+	if len(d.Get("etcd_client.0.key").(string)) == 0 || d.Get("etcd_client.0.ca_crt").(string) != caCrt {
+		t.Fatalf("Error: unexpected etcd client: %v", d.Get("etcd_client"))
+	}
+	if needsNewEtcdClientCert(crt, caCrt, common.DefEtcdClientCommonName, time.Now()) {
+		t.Fatalf("Error: the etcd client certificate is not valid")
+	}
+
+	// the certificate is kept while it is valid...
+	if err := updateEtcdClient(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if d.Get("etcd_client.0.crt").(string) != crt {
+		t.Fatalf("Error: the etcd client certificate has been replaced")
+	}
+
+	// ... and renewed when it is about to expire, or for a different common name or CA
+	if !needsNewEtcdClientCert(crt, caCrt, common.DefEtcdClientCommonName, time.Now().Add(365*24*time.Hour)) {
+		t.Fatalf("Error: the etcd client certificate should be renewed when it is about to expire")
+	}
+	if !needsNewEtcdClientCert(crt, caCrt, "etcd-backup", time.Now()) {
+		t.Fatalf("Error: the etcd client certificate should be renewed for a different common name")
+	}
+	otherCA, _, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "etcd-ca"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	if !needsNewEtcdClientCert(crt, string(pkiutil.EncodeCertPEM(otherCA)), common.DefEtcdClientCommonName, time.Now()) {
+		t.Fatalf("Error: the etcd client certificate should be renewed for a different CA")
+	}
+
+	// nothing is created without an `etcd_client_cert`
+	delete(raw, "etcd_client_cert")
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := updateEtcdClient(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if l := d.Get("etcd_client").([]interface{}); len(l) > 0 {
+		t.Fatalf("Error: unexpected etcd client: %v", l)
+	}
+
+	// the CA of an external etcd is not managed by the provider
+	raw["etcd_client_cert"] = []interface{}{map[string]interface{}{}}
+	raw["etcd"] = []interface{}{
+		map[string]interface{}{
+			"endpoints": []interface{}{"https://etcd.example.com:2379"},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkEtcdMode(d); err == nil {
+		t.Fatalf("Error: no error for an etcd client certificate with an external etcd")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestEtcdMode(t *testing.T) {
+	endpoints := []interface{}{"https://etcd1.example.com:2379"}
+	local := []interface{}{
+		map[string]interface{}{
+			"data_dir": "/mnt/etcd",
+		},
+	}
+
+	tests := []struct {
+		etcd     map[string]interface{}
+		images   map[string]interface{}
+		expected string
+		valid    bool
+	}{
+		{etcd: nil, expected: common.EtcdModeStacked, valid: true},
+		{etcd: map[string]interface{}{"endpoints": endpoints}, expected: common.EtcdModeExternal, valid: true},
+		{etcd: map[string]interface{}{"mode": "external", "endpoints": endpoints}, expected: common.EtcdModeExternal, valid: true},
+		{etcd: map[string]interface{}{"mode": "stacked", "local": local}, expected: common.EtcdModeStacked, valid: true},
+		{etcd: map[string]interface{}{"mode": "external"}, expected: common.EtcdModeExternal, valid: false},
+		{etcd: map[string]interface{}{"mode": "stacked", "endpoints": endpoints}, expected: common.EtcdModeStacked, valid: false},
+		{
+			etcd:     map[string]interface{}{"endpoints": endpoints},
+			images:   map[string]interface{}{"etcd_version": "3.3.10"},
+			expected: common.EtcdModeExternal,
+			valid:    false,
+		},
+	}
+
+	for i, test := range tests {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+		}
+		if test.etcd != nil {
+			raw["etcd"] = []interface{}{test.etcd}
+		}
+		if test.images != nil {
+			raw["images"] = []interface{}{test.images}
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+		if mode := getEtcdMode(d); mode != test.expected {
+			t.Fatalf("Error: test %d: unexpected etcd mode %q", i, mode)
+		}
+		if err := checkEtcdMode(d); (err == nil) != test.valid {
+			t.Fatalf("Error: test %d: unexpected validation result: %v", i, err)
+		}
+	}
+}
+
+func TestEtcdDataDir(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if dir := getEtcdDataDir(d); dir != common.DefEtcdDataDir {
+		t.Fatalf("Error: unexpected default etcd data dir: %q", dir)
+	}
+
+	raw["etcd"] = []interface{}{
+		map[string]interface{}{
+			"local": []interface{}{
+				map[string]interface{}{
+					"data_dir": "/mnt/etcd",
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if dir := getEtcdDataDir(d); dir != "/mnt/etcd" {
+		t.Fatalf("Error: unexpected etcd data dir: %q", dir)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// status of the API server when it can be reached...
+	healthAPIServerOK = "ok"
+
+	// ... when it cannot be reached...
+	healthAPIServerUnreachable = "unreachable"
+
+	// ... or when we do not have a kubeconfig for trying it
+	healthAPIServerUnknown = "unknown"
+
+	// label used by kubeadm in the static pods of the control plane components
+	controlPlaneComponentLabel = "component"
+
+	// certificates expiring in less than this time make the cluster unhealthy
+	healthCertExpirationMargin = 30 * 24 * time.Hour
+)
+
+var (
+	// the control plane components we check in `kube-system`
+	controlPlaneComponents = []string{
+		"kube-apiserver",
+		"kube-controller-manager",
+		"kube-scheduler",
+		"etcd",
+	}
+)
+
+// clusterHealth is the health of the cluster, as stored in the `cluster_health`
+type clusterHealth struct {
+	APIServer    string
+	NodesTotal   int
+	NodesReady   int
+	Components   map[string]string
+	Certificates map[string]time.Time
+}
+
+// IsHealthy returns true if the API server is reachable, all the nodes are ready,
+// all the control plane pods are running and no certificate is about to expire
+func (h clusterHealth) IsHealthy(now time.Time) bool {
+	return len(h.Problems(now)) == 0
+}
+
+// Problems returns a (sorted) list of descriptions of the problems found in the cluster
+func (h clusterHealth) Problems(now time.Time) []string {
+	problems := []string{}
+	if h.APIServer != healthAPIServerOK {
+		problems = append(problems, fmt.Sprintf("API server is %s", h.APIServer))
+	}
+	if h.NodesReady < h.NodesTotal {
+		problems = append(problems, fmt.Sprintf("only %d of %d nodes are ready", h.NodesReady, h.NodesTotal))
+	}
+	for component, status := range h.Components {
+		if !isComponentHealthy(status) {
+			problems = append(problems, fmt.Sprintf("%s is %s", component, status))
+		}
+	}
+	for name, expiration := range h.Certificates {
+		if now.Add(healthCertExpirationMargin).After(expiration) {
+			problems = append(problems, fmt.Sprintf("certificate %s expires at %s", name, expiration.UTC().Format(time.RFC3339)))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// ToResourceData returns the health in a format that can be stored in the `cluster_health`
+func (h clusterHealth) ToResourceData(now time.Time) []map[string]interface{} {
+	certs := map[string]interface{}{}
+	for name, expiration := range h.Certificates {
+		certs[name] = expiration.UTC().Format(time.RFC3339)
+	}
+	components := map[string]interface{}{}
+	for component, status := range h.Components {
+		components[component] = status
+	}
+
+	return []map[string]interface{}{
+		{
+			"healthy":      h.IsHealthy(now),
+			"api_server":   h.APIServer,
+			"nodes_total":  h.NodesTotal,
+			"nodes_ready":  h.NodesReady,
+			"components":   components,
+			"certificates": certs,
+		},
+	}
+}
+
+// isComponentHealthy returns true if the status of a component (as returned by
+// getControlPlaneComponentsStatus) means it is healthy
+func isComponentHealthy(status string) bool {
+	var ready, total int
+	if _, err := fmt.Sscanf(status, "%d/%d ready", &ready, &total); err != nil {
+		return false
+	}
+	return total > 0 && ready == total
+}
+
+// isPodReady returns true if the pod has the "Ready" condition
+func isPodReady(pod corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// getControlPlaneComponentsStatus returns the status of the control plane components
+// (ie, "kube-scheduler" -> "2/3 ready"), from the static pods in `kube-system`.
+// Components with no pods (ie, etcd, when using an external etcd) are not reported.
+func getControlPlaneComponentsStatus(client kubernetes.Interface) (map[string]string, error) {
+	pods, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s in (%s)", controlPlaneComponentLabel, strings.Join(controlPlaneComponents, ",")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total, ready := map[string]int{}, map[string]int{}
+	for _, pod := range pods.Items {
+		component := pod.Labels[controlPlaneComponentLabel]
+		total[component]++
+		if isPodReady(pod) {
+			ready[component]++
+		}
+	}
+
+	res := map[string]string{}
+	for component, t := range total {
+		res[component] = fmt.Sprintf("%d/%d ready", ready[component], t)
+	}
+	return res, nil
+}
+
+// getClusterHealth checks the health of the API server, the nodes and
+// the control plane components of the cluster
+func getClusterHealth(client kubernetes.Interface) clusterHealth {
+	health := clusterHealth{
+		APIServer:    healthAPIServerUnreachable,
+		Components:   map[string]string{},
+		Certificates: map[string]time.Time{},
+	}
+
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		ssh.Debug("cannot reach the API server: %s", err)
+		return health
+	}
+	health.APIServer = healthAPIServerOK
+
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		ssh.Debug("cannot get list of nodes: %s", err)
+	} else {
+		health.NodesTotal = len(nodes.Items)
+		for _, node := range nodes.Items {
+			if isNodeReady(node) {
+				health.NodesReady++
+			}
+		}
+	}
+
+	components, err := getControlPlaneComponentsStatus(client)
+	if err != nil {
+		ssh.Debug("cannot get the status of the control plane components: %s", err)
+	} else {
+		health.Components = components
+	}
+
+	return health
+}
+
+// getCertificatesExpiration returns the expiration of the certificates we know about:
+// the CAs in the `config` and the admin certificate in the kubeconfig
+func getCertificatesExpiration(d *schema.ResourceData) map[string]time.Time {
+	res := map[string]time.Time{}
+
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil {
+		ssh.Debug("cannot load the certificates from the config: %s", err)
+	}
+
+	for name, crt := range map[string]string{
+		"ca":             certsConfig.CaCrt,
+		"etcd_ca":        certsConfig.EtcdCrt,
+		"front_proxy_ca": certsConfig.ProxyCrt,
+		"admin":          d.Get("client_certificate").(string),
+	} {
+		if len(crt) == 0 {
+			continue // (ie, the certificates are stored in Vault)
+		}
+		expiration, err := common.GetCertExpiration(crt)
+		if err != nil {
+			ssh.Debug("cannot get the expiration of the %s certificate: %s", name, err)
+			continue
+		}
+		res[name] = expiration
+	}
+	return res
+}
+
+// getAPIServerCertExpiration returns the expiration of the certificate
+// presented by the API server in the kubeconfig provided
+func getAPIServerCertExpiration(kubeconfig string) (time.Time, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if tlsConfig == nil {
+		return time.Time{}, fmt.Errorf("the API server does not use TLS")
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return time.Time{}, err
+	}
+	host := u.Host
+	if len(u.Port()) == 0 {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &net.Dialer{Timeout: kubeClientTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}, fmt.Errorf("no certificate presented by the API server")
+	}
+	return certs[0].NotAfter, nil
+}
+
+// updateClusterHealth refreshes the `cluster_health` with the current health of the cluster,
+// so a degraded cluster can be detected in a `terraform plan`. As with the nodes status,
+// failures when accessing the cluster are not considered errors.
+func updateClusterHealth(d *schema.ResourceData) error {
+	health := clusterHealth{
+		APIServer:    healthAPIServerUnknown,
+		Components:   map[string]string{},
+		Certificates: map[string]time.Time{},
+	}
+
+	client, err := getKubeClient(d)
+	if err != nil {
+		ssh.Debug("cannot check the cluster health: %s", err)
+	} else {
+		health = getClusterHealth(client)
+	}
+
+	for name, expiration := range getCertificatesExpiration(d) {
+		health.Certificates[name] = expiration
+	}
+	if health.APIServer == healthAPIServerOK {
+		expiration, err := getAPIServerCertExpiration(d.Get("config_path").(string))
+		if err != nil {
+			ssh.Debug("cannot get the API server certificate: %s", err)
+		} else {
+			health.Certificates["api_server"] = expiration
+		}
+	}
+
+	now := time.Now()
+	if problems := health.Problems(now); len(problems) > 0 && health.APIServer != healthAPIServerUnknown {
+		ssh.Debug("[WARN] the cluster is degraded: %s", strings.Join(problems, ", "))
+	}
+	return d.Set("cluster_health", health.ToResourceData(now))
+}
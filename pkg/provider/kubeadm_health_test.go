@@ -0,0 +1,150 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newControlPlanePod(component string, idx int, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-master-%d", component, idx),
+			Namespace: metav1.NamespaceSystem,
+			Labels:    map[string]string{controlPlaneComponentLabel: component, "tier": "control-plane"},
+		},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestGetClusterHealth(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "master-0"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}},
+			},
+		},
+		newControlPlanePod("kube-apiserver", 0, true),
+		newControlPlanePod("kube-apiserver", 1, true),
+		newControlPlanePod("kube-scheduler", 0, true),
+		newControlPlanePod("kube-scheduler", 1, false),
+		newControlPlanePod("kube-controller-manager", 0, true),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "coredns-1234",
+				Namespace: metav1.NamespaceSystem,
+				Labels:    map[string]string{"k8s-app": "kube-dns"},
+			},
+		},
+	)
+
+	health := getClusterHealth(client)
+	if health.APIServer != healthAPIServerOK {
+		t.Fatalf("Error: unexpected API server status: %q", health.APIServer)
+	}
+	if health.NodesTotal != 2 || health.NodesReady != 1 {
+		t.Fatalf("Error: unexpected nodes: %d ready of %d", health.NodesReady, health.NodesTotal)
+	}
+
+	expected := map[string]string{
+		"kube-apiserver":          "2/2 ready",
+		"kube-scheduler":          "1/2 ready",
+		"kube-controller-manager": "1/1 ready",
+	}
+	if len(health.Components) != len(expected) {
+		t.Fatalf("Error: unexpected components: %v", health.Components)
+	}
+	for component, status := range expected {
+		if health.Components[component] != status {
+			t.Fatalf("Error: unexpected status for %s: %q (expected %q)", component, health.Components[component], status)
+		}
+	}
+
+	now := time.Now()
+	if health.IsHealthy(now) {
+		t.Fatalf("Error: the cluster should not be healthy")
+	}
+	problems := health.Problems(now)
+	if len(problems) != 2 {
+		t.Fatalf("Error: unexpected problems: %v", problems)
+	}
+	if !strings.Contains(problems[0], "kube-scheduler") || !strings.Contains(problems[1], "1 of 2 nodes") {
+		t.Fatalf("Error: unexpected problems: %v", problems)
+	}
+}
+
+func TestClusterHealthProblems(t *testing.T) {
+	now := time.Now()
+	healthy := clusterHealth{
+		APIServer:    healthAPIServerOK,
+		NodesTotal:   3,
+		NodesReady:   3,
+		Components:   map[string]string{"etcd": "3/3 ready"},
+		Certificates: map[string]time.Time{"ca": now.Add(10 * 365 * 24 * time.Hour)},
+	}
+	if !healthy.IsHealthy(now) {
+		t.Fatalf("Error: the cluster should be healthy: %v", healthy.Problems(now))
+	}
+
+	res := healthy.ToResourceData(now)
+	if len(res) != 1 || res[0]["healthy"] != true || res[0]["nodes_ready"] != 3 {
+		t.Fatalf("Error: unexpected resource data: %v", res)
+	}
+	if _, err := time.Parse(time.RFC3339, res[0]["certificates"].(map[string]interface{})["ca"].(string)); err != nil {
+		t.Fatalf("Error: unexpected expiration for the CA: %s", err)
+	}
+
+	expiring := healthy
+	expiring.Certificates = map[string]time.Time{"admin": now.Add(24 * time.Hour)}
+	if problems := expiring.Problems(now); len(problems) != 1 || !strings.Contains(problems[0], "admin") {
+		t.Fatalf("Error: the expiring certificate was not detected: %v", problems)
+	}
+
+	unreachable := clusterHealth{APIServer: healthAPIServerUnreachable}
+	if unreachable.IsHealthy(now) {
+		t.Fatalf("Error: a cluster with an unreachable API server should not be healthy")
+	}
+
+	for status, expected := range map[string]bool{
+		"3/3 ready": true,
+		"2/3 ready": false,
+		"0/0 ready": false,
+		"unknown":   false,
+	} {
+		if isComponentHealthy(status) != expected {
+			t.Fatalf("Error: unexpected health for %q", status)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getHelmCharts returns the charts that must be installed after creating the cluster
+// (the charts for the addons are installed first)
+func getHelmCharts(d *schema.ResourceData) []common.HelmChartSpec {
+	res := []common.HelmChartSpec{}
+	if chart, ok := getCertManagerChart(d); ok {
+		res = append(res, chart)
+	}
+	if chart, ok := getMetalLBChart(d); ok {
+		res = append(res, chart)
+	}
+	if chart, ok := getCinderCSIChart(d); ok {
+		res = append(res, chart)
+	}
+	if chart, ok := getStorageChart(d); ok {
+		res = append(res, chart)
+	}
+	if chart, ok := getIngressChart(d); ok {
+		res = append(res, chart)
+	}
+
+	charts, ok := d.GetOk("helm.0.chart")
+	if !ok {
+		return res
+	}
+	for _, c := range charts.([]interface{}) {
+		m := c.(map[string]interface{})
+		res = append(res, common.HelmChartSpec{
+			Name:      m["name"].(string),
+			Chart:     m["chart"].(string),
+			Repo:      m["repo"].(string),
+			Version:   m["version"].(string),
+			Namespace: m["namespace"].(string),
+			Values:    m["values"].(string),
+		})
+	}
+	return res
+}
+
+// isHelmEnabled returns true if Helm must be installed
+// (it is needed when some charts must be installed)
+func isHelmEnabled(d *schema.ResourceData) bool {
+	return d.Get("helm.0.install").(bool) || len(getHelmCharts(d)) > 0
+}
@@ -17,6 +17,7 @@ package provider
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -47,3 +48,213 @@ func TestKubeadmInitConfigSerialization(t *testing.T) {
 	fmt.Printf("----------------- init configuration ---------------- \n%s", initConfigBytes)
 
 }
+
+func TestKubeadmInitConfigMetrics(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"observability": []interface{}{
+			map[string]interface{}{
+				"expose_control_plane_metrics": true,
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	if addr := initConfig.Scheduler.ExtraArgs["bind-address"]; addr != common.DefMetricsBindAddress {
+		t.Fatalf("Error: wrong scheduler bind address: %q", addr)
+	}
+	if addr := initConfig.ControllerManager.ExtraArgs["bind-address"]; addr != common.DefMetricsBindAddress {
+		t.Fatalf("Error: wrong controller manager bind address: %q", addr)
+	}
+	if initConfig.Etcd.Local == nil {
+		t.Fatalf("Error: no local etcd configuration")
+	}
+	if url := initConfig.Etcd.Local.ExtraArgs["listen-metrics-urls"]; url != "http://0.0.0.0:2381" {
+		t.Fatalf("Error: wrong etcd metrics URL: %q", url)
+	}
+
+	if _, err := common.InitConfigToYAML(initConfig); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+}
+
+func TestKubeadmInitConfigLocalEtcd(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"etcd": []interface{}{
+			map[string]interface{}{
+				"local": []interface{}{
+					map[string]interface{}{
+						"data_dir": "/mnt/fast/etcd",
+						"extra_args": map[string]interface{}{
+							"quota-backend-bytes": "8589934592",
+							"heartbeat-interval":  "250",
+						},
+						"server_cert_sans": []interface{}{"etcd.example.com"},
+						"peer_cert_sans":   []interface{}{"10.10.0.5"},
+					},
+				},
+			},
+		},
+		"observability": []interface{}{
+			map[string]interface{}{
+				"expose_control_plane_metrics": true,
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	local := initConfig.Etcd.Local
+	if local == nil || initConfig.Etcd.External != nil {
+		t.Fatalf("Error: unexpected etcd configuration: %+v", initConfig.Etcd)
+	}
+	if local.DataDir != "/mnt/fast/etcd" {
+		t.Fatalf("Error: wrong etcd data dir: %q", local.DataDir)
+	}
+	if local.ExtraArgs["quota-backend-bytes"] != "8589934592" || local.ExtraArgs["heartbeat-interval"] != "250" {
+		t.Fatalf("Error: wrong etcd extra args: %v", local.ExtraArgs)
+	}
+	if _, ok := local.ExtraArgs["listen-metrics-urls"]; !ok {
+		t.Fatalf("Error: the etcd metrics have not been exposed: %v", local.ExtraArgs)
+	}
+	if len(local.ServerCertSANs) == 0 || local.ServerCertSANs[0] != "etcd.example.com" {
+		t.Fatalf("Error: wrong etcd server SANs: %v", local.ServerCertSANs)
+	}
+	if len(local.PeerCertSANs) != 1 || local.PeerCertSANs[0] != "10.10.0.5" {
+		t.Fatalf("Error: wrong etcd peer SANs: %v", local.PeerCertSANs)
+	}
+
+	if _, err := common.InitConfigToYAML(initConfig); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+}
+
+func TestKubeadmInitConfigContainerd(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"runtime": []interface{}{
+			map[string]interface{}{
+				"engine": "containerd",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	if socket := initConfig.NodeRegistration.CRISocket; socket != common.DefCriSocket["containerd"] {
+		t.Fatalf("Error: wrong CRI socket: %q", socket)
+	}
+	args := initConfig.NodeRegistration.KubeletExtraArgs
+	if args["container-runtime"] != "remote" {
+		t.Fatalf("Error: wrong container runtime: %q", args["container-runtime"])
+	}
+	if args["cgroup-driver"] != "systemd" {
+		t.Fatalf("Error: wrong cgroup driver: %q", args["cgroup-driver"])
+	}
+	if _, ok := common.DefKubeletSettings["container-runtime"]; ok {
+		t.Fatalf("Error: the default kubelet settings have been modified")
+	}
+
+	if image := getSandboxImage(d); image != "k8s.gcr.io/pause:3.1" {
+		t.Fatalf("Error: wrong sandbox image: %q", image)
+	}
+}
+
+func TestKubeadmJoinConfigCrio(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"runtime": []interface{}{
+			map[string]interface{}{
+				"engine": "crio",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	joinConfig, err := dataSourceToJoinConfig(d, "82eb2m.999999idy9l74yha")
+	if err != nil {
+		t.Fatalf("could not create joinConfig from dataSource: %s", err)
+	}
+
+	if socket := joinConfig.NodeRegistration.CRISocket; socket != common.DefCriSocket["crio"] {
+		t.Fatalf("Error: wrong CRI socket: %q", socket)
+	}
+	args := joinConfig.NodeRegistration.KubeletExtraArgs
+	if args["container-runtime-endpoint"] != "unix:///var/run/crio/crio.sock" {
+		t.Fatalf("Error: wrong container runtime endpoint: %q", args["container-runtime-endpoint"])
+	}
+	if args["cgroup-driver"] != "systemd" {
+		t.Fatalf("Error: wrong cgroup driver: %q", args["cgroup-driver"])
+	}
+}
+
+func TestUpdateConfigForProvisioner(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"runtime": []interface{}{
+			map[string]interface{}{
+				"extra_args": []interface{}{
+					map[string]interface{}{
+						"api_server": map[string]interface{}{"feature-gates": "SomeFeature=true"},
+						"kubelet":    map[string]interface{}{"max-pods": "200"},
+					},
+				},
+			},
+		},
+		"kubelet": []interface{}{
+			map[string]interface{}{
+				"eviction_hard": map[string]interface{}{"nodefs.available": "5%"},
+			},
+		},
+		"config": map[string]interface{}{
+			"token": "82eb2m.999999idy9l74yha",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	if err := updateConfigForProvisioner(d); err != nil {
+		t.Fatalf("Error: could not update the config: %s", err)
+	}
+
+	config := common.GetProvisionerConfig(d)
+	initBytes, _ := common.FromTerraformSafeString(config["init"].(string))
+	initConfig, err := common.YAMLToInitConfig(initBytes)
+	if err != nil {
+		t.Fatalf("Error: could not parse the init config: %s", err)
+	}
+	if initConfig.APIServer.ExtraArgs["feature-gates"] != "SomeFeature=true" {
+		t.Fatalf("Error: the API server args were not updated: %v", initConfig.APIServer.ExtraArgs)
+	}
+	if initConfig.ComponentConfigs.Kubelet == nil || initConfig.ComponentConfigs.Kubelet.EvictionHard["nodefs.available"] != "5%" {
+		t.Fatalf("Error: the kubelet configuration was not updated: %+v", initConfig.ComponentConfigs.Kubelet)
+	}
+	if initConfig.BootstrapTokens[0].Token.String() != "82eb2m.999999idy9l74yha" {
+		t.Fatalf("Error: the token was not kept")
+	}
+	if _, err := time.ParseDuration(config["timeout_update"].(string)); err != nil {
+		t.Fatalf("Error: no valid update timeout in the config: %v", config["timeout_update"])
+	}
+
+	joinBytes, _ := common.FromTerraformSafeString(config["join"].(string))
+	joinConfig, err := common.YAMLToJoinConfig(joinBytes)
+	if err != nil {
+		t.Fatalf("Error: could not parse the join config: %s", err)
+	}
+	if joinConfig.NodeRegistration.KubeletExtraArgs["max-pods"] != "200" {
+		t.Fatalf("Error: the kubelet args were not updated: %v", joinConfig.NodeRegistration.KubeletExtraArgs)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// dataSourceKubeadmJoinInfo is the `kubeadm_join_info` data source, that
+// exposes everything needed for joining nodes out-of-band (ie, from a
+// cloud-init user data in an autoscaling group)
+func dataSourceKubeadmJoinInfo() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceKubeadmJoinInfoRead,
+		Schema: map[string]*schema.Schema{
+			"config": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Sensitive:   true,
+				Description: "the `config` of the kubeadm resource",
+			},
+			"api_endpoint": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "the API server endpoint nodes will join (defaults to the api.external of the cluster)",
+			},
+			"control_plane": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "return the join command for a control plane",
+			},
+			"token": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"ca_cert_hash": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"certificate_key": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"command": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+	}
+}
+
+// getJoinAPIEndpoint returns the endpoint used for joining (the `endpoint` provided or,
+// by default, the control plane endpoint), or an error when it is not known before
+// provisioning the first master
+func getJoinAPIEndpoint(d *schema.ResourceData, endpoint string) (string, error) {
+	if len(endpoint) > 0 {
+		return common.AddressWithPort(endpoint, common.DefAPIServerPort), nil
+	}
+
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return "", err
+	}
+	if len(initConfig.ControlPlaneEndpoint) == 0 {
+		return "", fmt.Errorf("the API server endpoint is unknown: set 'api.external' in the kubeadm resource or an 'api_endpoint'")
+	}
+	return initConfig.ControlPlaneEndpoint, nil
+}
+
+// getJoinInfoCommand returns the `kubeadm join` command line
+func getJoinInfoCommand(endpoint, token, caCertHash, certificateKey string, controlPlane bool) string {
+	args := []string{
+		"kubeadm", "join", endpoint,
+		"--token", token,
+		"--discovery-token-ca-cert-hash", caCertHash,
+	}
+	if controlPlane {
+		args = append(args, "--control-plane", "--certificate-key", certificateKey)
+	}
+	return strings.Join(args, " ")
+}
+
+func dataSourceKubeadmJoinInfoRead(d *schema.ResourceData, meta interface{}) error {
+	config, err := getConfigWithVaultSecrets(d, meta)
+	if err != nil {
+		return err
+	}
+
+	token, _ := config["token"].(string)
+	if len(token) == 0 {
+		return fmt.Errorf("no token found in the 'config'")
+	}
+
+	caCrt, _ := config["ca_crt"].(string)
+	caCertHash, err := common.GetCACertHash(caCrt)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := getJoinAPIEndpoint(d, d.Get("api_endpoint").(string))
+	if err != nil {
+		return err
+	}
+
+	controlPlane := d.Get("control_plane").(bool)
+	certificateKey, _ := config["certificate_key"].(string)
+	if controlPlane && len(certificateKey) == 0 {
+		return fmt.Errorf("no certificate key found in the 'config': the kubeadm resource must be re-created")
+	}
+
+	command := getJoinInfoCommand(endpoint, token, caCertHash, certificateKey, controlPlane)
+
+	for k, v := range map[string]interface{}{
+		"api_endpoint":    endpoint,
+		"token":           token,
+		"ca_cert_hash":    caCertHash,
+		"certificate_key": certificateKey,
+		"command":         command,
+	} {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+
+	hasher := md5.New()
+	hasher.Write([]byte(command))
+	d.SetId(hex.EncodeToString(hasher.Sum(nil)))
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pubkeypin"
+)
+
+func TestKubeadmJoinInfo(t *testing.T) {
+	caCert, _, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"token":           "abcdef.0123456789abcdef",
+			"ca_crt":          string(pkiutil.EncodeCertPEM(caCert)),
+			"certificate_key": "0123456789abcdef",
+		},
+		"api_endpoint":  "k8s.example.com",
+		"control_plane": true,
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadmJoinInfo().Schema, raw)
+	if err := dataSourceKubeadmJoinInfoRead(d, nil); err != nil {
+		t.Fatalf("Error: could not read the join info: %s", err)
+	}
+
+	if hash := d.Get("ca_cert_hash").(string); hash != pubkeypin.Hash(caCert) {
+		t.Fatalf("Error: unexpected CA cert hash: %q", hash)
+	}
+
+	command := d.Get("command").(string)
+	for _, expected := range []string{
+		"kubeadm join k8s.example.com:6443",
+		"--token abcdef.0123456789abcdef",
+		"--discovery-token-ca-cert-hash sha256:",
+		"--control-plane --certificate-key 0123456789abcdef",
+	} {
+		if !strings.Contains(command, expected) {
+			t.Fatalf("Error: %q not found in the join command: %s", expected, command)
+		}
+	}
+}
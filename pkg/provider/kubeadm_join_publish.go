@@ -0,0 +1,129 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getJoinPublishVault returns the Vault client and the API path where the join
+// command must be published, or nil when it is not published in Vault
+func getJoinPublishVault(d *schema.ResourceData, meta interface{}) (*common.VaultClient, string, error) {
+	path := d.Get("join_publish.0.vault_path").(string)
+	if len(path) == 0 {
+		return nil, "", nil
+	}
+
+	m, ok := meta.(*providerMeta)
+	if !ok || m.vault == nil {
+		return nil, "", fmt.Errorf("the join command must be published in Vault at %q, but Vault is not configured in the provider", path)
+	}
+	return m.vault, fmt.Sprintf("%s/data/%s", strings.Trim(m.vaultMount, "/"), strings.Trim(path, "/")), nil
+}
+
+// getJoinPublishSecrets returns the join command (and the things needed for building it)
+// for the workers of the cluster
+func getJoinPublishSecrets(d *schema.ResourceData, meta interface{}) (map[string]string, error) {
+	config, err := getConfigWithVaultSecrets(d, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _ := config["token"].(string)
+	if len(token) == 0 {
+		return nil, fmt.Errorf("no token found in the 'config'")
+	}
+
+	caCrt, _ := config["ca_crt"].(string)
+	caCertHash, err := common.GetCACertHash(caCrt)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := getJoinAPIEndpoint(d, d.Get("join_publish.0.api_endpoint").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"api_endpoint": endpoint,
+		"token":        token,
+		"ca_cert_hash": caCertHash,
+		"command":      getJoinInfoCommand(endpoint, token, caCertHash, "", false),
+	}, nil
+}
+
+// publishJoinCommand sets the `join_command` (and writes it to Vault, when a
+// `vault_path` is provided) with the current join token, so nodes that are not
+// managed by Terraform (ie, in an autoscaling group) can join the cluster at any time.
+// The `join_command` is left empty when the command is written to Vault, so
+// the token is not kept in the Terraform state.
+func publishJoinCommand(d *schema.ResourceData, meta interface{}) error {
+	if !hasBlock(d, "join_publish") {
+		return d.Set("join_command", "")
+	}
+
+	secrets, err := getJoinPublishSecrets(d, meta)
+	if err != nil {
+		return err
+	}
+
+	vault, path, err := getJoinPublishVault(d, meta)
+	if err != nil {
+		return err
+	}
+	if vault == nil {
+		return d.Set("join_command", secrets["command"])
+	}
+
+	current, err := vault.ReadSecrets(path)
+	if err != nil {
+		return err
+	}
+	if current["command"] != secrets["command"] {
+		ssh.Debug("publishing the join command in Vault at %q", path)
+		if err := vault.WriteSecrets(path, secrets); err != nil {
+			return err
+		}
+	}
+	return d.Set("join_command", "")
+}
+
+// checkJoinPublish checks the join command can be published
+func checkJoinPublish(d resourceGetter) error {
+	if !hasBlock(d, "join_publish") {
+		return nil
+	}
+	if len(d.Get("join_publish.0.api_endpoint").(string)) == 0 && len(d.Get("api.0.external").(string)) == 0 {
+		return fmt.Errorf("'join_publish' requires an 'api.external' or an 'api_endpoint': nodes would not know where to join")
+	}
+	return nil
+}
+
+// customizeDiffJoinPublish validates the `join_publish` settings at plan time
+func customizeDiffJoinPublish(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"join_publish", "api"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkJoinPublish(d)
+}
@@ -0,0 +1,166 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestPublishJoinCommand(t *testing.T) {
+	caCert, _, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+
+	writes := 0
+	stored := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/autoscaling/join" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			body, _ := ioutil.ReadAll(r.Body)
+			req := struct {
+				Data map[string]string `json:"data"`
+			}{}
+			if err := json.Unmarshal(body, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			stored = req.Data
+			writes++
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": stored},
+			})
+		}
+	}))
+	defer server.Close()
+
+	meta := &providerMeta{
+		vault:      common.NewVaultClient(server.URL, "s.token"),
+		vaultMount: common.DefVaultMount,
+		vaultPath:  common.DefVaultPath,
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"token":  "abcdef.0123456789abcdef",
+			"ca_crt": string(pkiutil.EncodeCertPEM(caCert)),
+		},
+		"join_publish": []interface{}{
+			map[string]interface{}{
+				"api_endpoint": "k8s.example.com",
+				"vault_path":   "autoscaling/join",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	for i := 0; i < 2; i++ {
+		if err := publishJoinCommand(d, meta); err != nil {
+			t.Fatalf("Error: could not publish the join command: %s", err)
+		}
+	}
+
+	// the join command is not kept in the state when it is written to Vault
+	if command := d.Get("join_command").(string); len(command) > 0 {
+		t.Fatalf("Error: the join command is in the state when using Vault: %s", command)
+	}
+	if !strings.HasPrefix(stored["command"], "kubeadm join k8s.example.com:6443 --token abcdef.0123456789abcdef") ||
+		stored["token"] != "abcdef.0123456789abcdef" {
+		t.Fatalf("Error: unexpected join command in Vault: %v", stored)
+	}
+	if writes != 1 {
+		t.Fatalf("Error: the join command should be written to Vault only when it changes: %d writes", writes)
+	}
+
+	// Vault must be configured in the provider
+	if err := publishJoinCommand(d, &providerMeta{}); err == nil {
+		t.Fatalf("Error: no error when Vault is not configured")
+	}
+
+	// without Vault, the join command is kept in the state
+	raw["join_publish"] = []interface{}{
+		map[string]interface{}{
+			"api_endpoint": "k8s.example.com",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := publishJoinCommand(d, &providerMeta{}); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if command := d.Get("join_command").(string); command != stored["command"] {
+		t.Fatalf("Error: unexpected join command: %s", command)
+	}
+
+	// ... even when Vault is configured in the provider (but there is no `vault_path`)
+	writes = 0
+	if err := publishJoinCommand(d, meta); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if command := d.Get("join_command").(string); command != stored["command"] {
+		t.Fatalf("Error: unexpected join command with Vault and no vault_path: %s", command)
+	}
+	if writes != 0 {
+		t.Fatalf("Error: the join command was written to Vault without a vault_path")
+	}
+
+	// nothing is published without a `join_publish`
+	delete(raw, "join_publish")
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := publishJoinCommand(d, meta); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if command := d.Get("join_command").(string); len(command) > 0 {
+		t.Fatalf("Error: unexpected join command: %s", command)
+	}
+}
+
+func TestCheckJoinPublish(t *testing.T) {
+	raw := map[string]interface{}{
+		"join_publish": []interface{}{
+			map[string]interface{}{
+				"vault_path": "autoscaling/join",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkJoinPublish(d); err == nil {
+		t.Fatalf("Error: no error when the API server endpoint is unknown")
+	}
+
+	raw["api"] = []interface{}{
+		map[string]interface{}{
+			"external": "k8s.example.com",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkJoinPublish(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// isKonnectivityEnabled returns true when the API server reaches the cluster through the konnectivity service
+func isKonnectivityEnabled(d resourceGetter) bool {
+	return hasBlock(d, "konnectivity")
+}
+
+// getKonnectivityServerHost returns the host the konnectivity agents connect to, or an empty
+// string when it must be the first control plane node
+func getKonnectivityServerHost(d resourceGetter) string {
+	if host := d.Get("konnectivity.0.server_host").(string); len(host) > 0 {
+		return host
+	}
+	if hasBlock(d, "api") {
+		if external := d.Get("api.0.external").(string); len(external) > 0 {
+			if host, _, err := common.SplitHostPort(external, common.DefAPIServerPort); err == nil {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// checkKonnectivity checks that the konnectivity service can be used
+func checkKonnectivity(d resourceGetter) error {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+	if err := common.CheckKonnectivityVersion(getKubernetesVersion(d)); err != nil {
+		return fmt.Errorf("konnectivity cannot be used: %s", err)
+	}
+	if _, ok := mapFromResourceData(d, "runtime.0.extra_args.0.api_server")["egress-selector-config-file"]; ok {
+		return fmt.Errorf("\"egress-selector-config-file\" cannot be provided in the API server extra_args when using konnectivity")
+	}
+	return nil
+}
+
+// customizeDiffKonnectivity validates the konnectivity settings at plan time
+func customizeDiffKonnectivity(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"konnectivity", "version", "runtime"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkKonnectivity(d)
+}
@@ -0,0 +1,75 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestKonnectivity(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path":  "/tmp/kubeconfig",
+		"version":      "v1.27.3",
+		"konnectivity": []interface{}{map[string]interface{}{}},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if !isKonnectivityEnabled(d) {
+		t.Fatalf("Error: konnectivity not enabled")
+	}
+	if err := checkKonnectivity(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if host := getKonnectivityServerHost(d); host != "" {
+		t.Fatalf("Error: unexpected server host: %q", host)
+	}
+
+	// the agents connect to the external API host by default
+	raw["api"] = []interface{}{
+		map[string]interface{}{
+			"external": "api.example.com:6443",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if host := getKonnectivityServerHost(d); host != "api.example.com" {
+		t.Fatalf("Error: unexpected server host: %q", host)
+	}
+
+	raw["konnectivity"] = []interface{}{
+		map[string]interface{}{
+			"server_host": "konnectivity.example.com",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if host := getKonnectivityServerHost(d); host != "konnectivity.example.com" {
+		t.Fatalf("Error: unexpected server host: %q", host)
+	}
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if _, ok := initConfig.APIServer.ExtraArgs["egress-selector-config-file"]; !ok {
+		t.Fatalf("Error: no egress selector in the API server args: %v", initConfig.APIServer.ExtraArgs)
+	}
+
+	// the egress selector is not available in old versions
+	raw["version"] = "v1.15.0"
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkKonnectivity(d); err == nil {
+		t.Fatalf("Error: no error for a version without the egress selector")
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getKubeconfigServer returns the API server URL used in the kubeconfig, or an
+// empty string when it is not known before provisioning the first master
+func getKubeconfigServer(initConfig *kubeadmapi.InitConfiguration) string {
+	if len(initConfig.ControlPlaneEndpoint) > 0 {
+		return fmt.Sprintf("https://%s", initConfig.ControlPlaneEndpoint)
+	}
+	if len(initConfig.LocalAPIEndpoint.AdvertiseAddress) > 0 {
+		port := int(initConfig.LocalAPIEndpoint.BindPort)
+		if port == 0 {
+			port = common.DefAPIServerPort
+		}
+		return fmt.Sprintf("https://%s", net.JoinHostPort(initConfig.LocalAPIEndpoint.AdvertiseAddress, strconv.Itoa(port)))
+	}
+	return ""
+}
+
+// setKubeconfig sets the `kubeconfig` attribute with an admin kubeconfig
+// signed by the CA we have created (when the API server address is known)
+func setKubeconfig(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration, certs map[string]string) error {
+	server := getKubeconfigServer(initConfig)
+	if len(server) == 0 {
+		ssh.Debug("API server address unknown: the kubeconfig will be loaded from %q", d.Get("config_path").(string))
+		return nil
+	}
+
+	kubeconfig, err := common.NewAdminKubeconfig([]byte(certs["ca_crt"]), []byte(certs["ca_key"]), server)
+	if err != nil {
+		return err
+	}
+	return d.Set("kubeconfig", string(kubeconfig))
+}
+
+// loadKubeconfig sets the `kubeconfig` attribute from the kubeconfig
+// downloaded by the provisioner (if we could not generate it before)
+func loadKubeconfig(d *schema.ResourceData) error {
+	if len(d.Get("kubeconfig").(string)) > 0 {
+		return nil
+	}
+
+	kubeconfig, err := ioutil.ReadFile(d.Get("config_path").(string))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return d.Set("kubeconfig", string(kubeconfig))
+}
+
+// setKubeconfigAttributes sets the `api_endpoint`, `cluster_ca_certificate`,
+// `client_certificate` and `client_key` attributes from the `kubeconfig`,
+// so other providers can use them without parsing the kubeconfig
+func setKubeconfigAttributes(d *schema.ResourceData) error {
+	kubeconfig := d.Get("kubeconfig").(string)
+	if len(kubeconfig) == 0 {
+		return nil
+	}
+
+	config, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return fmt.Errorf("could not parse the kubeconfig: %s", err)
+	}
+	context, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return fmt.Errorf("no current context found in the kubeconfig")
+	}
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return fmt.Errorf("cluster %q not found in the kubeconfig", context.Cluster)
+	}
+	user, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return fmt.Errorf("user %q not found in the kubeconfig", context.AuthInfo)
+	}
+
+	for k, v := range map[string]string{
+		"api_endpoint":           cluster.Server,
+		"cluster_ca_certificate": string(cluster.CertificateAuthorityData),
+		"client_certificate":     string(user.ClientCertificateData),
+		"client_key":             string(user.ClientKeyData),
+	} {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kubeconfigFileLock serializes the changes in the `kubeconfig_path` files,
+// as many clusters could be merged in the same file at the same time
+var kubeconfigFileLock sync.Mutex
+
+// getClusterName returns the name of the cluster (or the default one)
+func getClusterName(d resourceGetter) string {
+	if name, ok := d.GetOk("cluster_name"); ok && len(name.(string)) > 0 {
+		return name.(string)
+	}
+	return common.DefClusterName
+}
+
+// getKubeconfigNames returns the names of the cluster, the user and the context
+// used for a cluster in the `kubeconfig_path`
+func getKubeconfigNames(clusterName string) (string, string, string) {
+	user := clusterName + "-admin"
+	return clusterName, user, user + "@" + clusterName
+}
+
+// expandKubeconfigPath expands a leading `~` in the `kubeconfig_path`
+func expandKubeconfigPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// newNamedKubeconfig returns a kubeconfig with the current context in `kubeconfig`,
+// using the cluster, user and context names for `clusterName`
+func newNamedKubeconfig(kubeconfig []byte, clusterName string) (*clientcmdapi.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the kubeconfig: %s", err)
+	}
+	context, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("no current context found in the kubeconfig")
+	}
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q not found in the kubeconfig", context.Cluster)
+	}
+	user, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("user %q not found in the kubeconfig", context.AuthInfo)
+	}
+
+	clusterKey, userKey, contextKey := getKubeconfigNames(clusterName)
+	res := clientcmdapi.NewConfig()
+	res.Clusters[clusterKey] = cluster
+	res.AuthInfos[userKey] = user
+	res.Contexts[contextKey] = &clientcmdapi.Context{Cluster: clusterKey, AuthInfo: userKey}
+	res.CurrentContext = contextKey
+	return res, nil
+}
+
+// loadKubeconfigFile loads a kubeconfig file, returning an empty config when it does not exist
+func loadKubeconfigFile(path string) (*clientcmdapi.Config, error) {
+	config, err := clientcmd.LoadFromFile(path)
+	if os.IsNotExist(err) {
+		return clientcmdapi.NewConfig(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load the kubeconfig in %q: %s", path, err)
+	}
+	return config, nil
+}
+
+// mergeKubeconfig adds (or replaces) the clusters, users and contexts of `config` in
+// `existing`, keeping its current context (unless it has none)
+func mergeKubeconfig(existing *clientcmdapi.Config, config *clientcmdapi.Config) {
+	for k, v := range config.Clusters {
+		existing.Clusters[k] = v
+	}
+	for k, v := range config.AuthInfos {
+		existing.AuthInfos[k] = v
+	}
+	for k, v := range config.Contexts {
+		existing.Contexts[k] = v
+	}
+	if len(existing.CurrentContext) == 0 {
+		existing.CurrentContext = config.CurrentContext
+	}
+}
+
+// unmergeKubeconfig removes the cluster, user and context for `clusterName` from `existing`
+func unmergeKubeconfig(existing *clientcmdapi.Config, clusterName string) {
+	clusterKey, userKey, contextKey := getKubeconfigNames(clusterName)
+	delete(existing.Clusters, clusterKey)
+	delete(existing.AuthInfos, userKey)
+	delete(existing.Contexts, contextKey)
+	if existing.CurrentContext == contextKey {
+		existing.CurrentContext = ""
+	}
+}
+
+// writeKubeconfigFileIfChanged writes the kubeconfig contents to `path`, only if they have changed
+func writeKubeconfigFileIfChanged(path string, config *clientcmdapi.Config) error {
+	contents, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, contents) {
+		return nil
+	}
+	ssh.Debug("writing kubeconfig at %q", path)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0600)
+}
+
+// writeKubeconfigFile writes the admin kubeconfig to the `kubeconfig_path` (if any),
+// or merges it as an additional context when `kubeconfig_merge` is enabled
+func writeKubeconfigFile(d *schema.ResourceData) error {
+	kubeconfig := d.Get("kubeconfig").(string)
+	if len(kubeconfig) == 0 || len(d.Get("kubeconfig_path").(string)) == 0 {
+		return nil
+	}
+	path, err := expandKubeconfigPath(d.Get("kubeconfig_path").(string))
+	if err != nil {
+		return err
+	}
+
+	config, err := newNamedKubeconfig([]byte(kubeconfig), getClusterName(d))
+	if err != nil {
+		return err
+	}
+
+	kubeconfigFileLock.Lock()
+	defer kubeconfigFileLock.Unlock()
+
+	if d.Get("kubeconfig_merge").(bool) {
+		existing, err := loadKubeconfigFile(path)
+		if err != nil {
+			return err
+		}
+		mergeKubeconfig(existing, config)
+		config = existing
+	}
+	return writeKubeconfigFileIfChanged(path, config)
+}
+
+// removeKubeconfigFile removes the kubeconfig written at `path`, or just the
+// context for `clusterName` when it was merged in an existing kubeconfig
+func removeKubeconfigFile(path string, merge bool, clusterName string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	path, err := expandKubeconfigPath(path)
+	if err != nil {
+		return err
+	}
+
+	kubeconfigFileLock.Lock()
+	defer kubeconfigFileLock.Unlock()
+
+	if !merge {
+		ssh.Debug("removing kubeconfig at %q", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	existing, err := loadKubeconfigFile(path)
+	if err != nil {
+		return err
+	}
+	ssh.Debug("removing cluster %q from the kubeconfig at %q", clusterName, path)
+	unmergeKubeconfig(existing, clusterName)
+	return writeKubeconfigFileIfChanged(path, existing)
+}
+
+// updateKubeconfigFile removes the kubeconfig from the previous `kubeconfig_path`
+// when it (or the `kubeconfig_merge`) has been changed (the new one will be written on read)
+func updateKubeconfigFile(d *schema.ResourceData) error {
+	if !d.HasChange("kubeconfig_path") && !d.HasChange("kubeconfig_merge") {
+		return nil
+	}
+	oldPath, _ := d.GetChange("kubeconfig_path")
+	oldMerge, _ := d.GetChange("kubeconfig_merge")
+	return removeKubeconfigFile(oldPath.(string), oldMerge.(bool), getClusterName(d))
+}
@@ -0,0 +1,166 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestSetKubeconfigAttributes(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode the CA key: %s", err)
+	}
+	caCertPEM := string(pkiutil.EncodeCertPEM(caCert))
+
+	kubeconfig, err := common.NewAdminKubeconfig([]byte(caCertPEM), caKeyPEM, "https://k8s.example.com:6443")
+	if err != nil {
+		t.Fatalf("Error: could not create the kubeconfig: %s", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, map[string]interface{}{})
+	if err := d.Set("kubeconfig", string(kubeconfig)); err != nil {
+		t.Fatalf("Error: could not set the kubeconfig: %s", err)
+	}
+	if err := setKubeconfigAttributes(d); err != nil {
+		t.Fatalf("Error: could not set the attributes: %s", err)
+	}
+
+	if endpoint := d.Get("api_endpoint").(string); endpoint != "https://k8s.example.com:6443" {
+		t.Fatalf("Error: unexpected api_endpoint: %q", endpoint)
+	}
+	if ca := d.Get("cluster_ca_certificate").(string); ca != caCertPEM {
+		t.Fatalf("Error: unexpected cluster_ca_certificate: %q", ca)
+	}
+	if len(d.Get("client_certificate").(string)) == 0 || len(d.Get("client_key").(string)) == 0 {
+		t.Fatalf("Error: no client certificate/key")
+	}
+}
+
+func TestKubeconfigFile(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode the CA key: %s", err)
+	}
+	kubeconfig, err := common.NewAdminKubeconfig(pkiutil.EncodeCertPEM(caCert), caKeyPEM, "https://k8s.example.com:6443")
+	if err != nil {
+		t.Fatalf("Error: could not create the kubeconfig: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+
+	// an existing kubeconfig, with another cluster
+	existing := clientcmdapi.NewConfig()
+	existing.Clusters["other"] = &clientcmdapi.Cluster{Server: "https://other.example.com:6443"}
+	existing.AuthInfos["other-admin"] = &clientcmdapi.AuthInfo{Token: "secret"}
+	existing.Contexts["other"] = &clientcmdapi.Context{Cluster: "other", AuthInfo: "other-admin"}
+	existing.CurrentContext = "other"
+	if err := clientcmd.WriteToFile(*existing, path); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, map[string]interface{}{
+		"cluster_name":     "prod",
+		"kubeconfig_path":  path,
+		"kubeconfig_merge": true,
+	})
+	if err := d.Set("kubeconfig", string(kubeconfig)); err != nil {
+		t.Fatalf("Error: could not set the kubeconfig: %s", err)
+	}
+	if err := writeKubeconfigFile(d); err != nil {
+		t.Fatalf("Error: could not write the kubeconfig: %s", err)
+	}
+
+	merged, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if merged.CurrentContext != "other" {
+		t.Fatalf("Error: the current context has been changed: %q", merged.CurrentContext)
+	}
+	if _, ok := merged.Contexts["other"]; !ok {
+		t.Fatalf("Error: the existing context has been lost")
+	}
+	context, ok := merged.Contexts["prod-admin@prod"]
+	if !ok || context.Cluster != "prod" || context.AuthInfo != "prod-admin" {
+		t.Fatalf("Error: unexpected context for the cluster: %+v", merged.Contexts)
+	}
+	if cluster, ok := merged.Clusters["prod"]; !ok || cluster.Server != "https://k8s.example.com:6443" {
+		t.Fatalf("Error: unexpected cluster: %+v", merged.Clusters)
+	}
+	if user, ok := merged.AuthInfos["prod-admin"]; !ok || len(user.ClientCertificateData) == 0 {
+		t.Fatalf("Error: unexpected user: %+v", merged.AuthInfos)
+	}
+
+	if err := removeKubeconfigFile(path, true, "prod"); err != nil {
+		t.Fatalf("Error: could not remove the cluster from the kubeconfig: %s", err)
+	}
+	unmerged, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(unmerged.Contexts) != 1 || len(unmerged.Clusters) != 1 || len(unmerged.AuthInfos) != 1 || unmerged.CurrentContext != "other" {
+		t.Fatalf("Error: unexpected kubeconfig after removing the cluster: %+v", unmerged)
+	}
+
+	// without merging, the file is overwritten and removed
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, map[string]interface{}{
+		"kubeconfig_path": path,
+	})
+	if err := d.Set("kubeconfig", string(kubeconfig)); err != nil {
+		t.Fatalf("Error: could not set the kubeconfig: %s", err)
+	}
+	if err := writeKubeconfigFile(d); err != nil {
+		t.Fatalf("Error: could not write the kubeconfig: %s", err)
+	}
+	written, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(written.Contexts) != 1 || written.CurrentContext != "kubernetes-admin@kubernetes" {
+		t.Fatalf("Error: unexpected kubeconfig: %+v", written)
+	}
+	if err := removeKubeconfigFile(path, false, common.DefClusterName); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Error: the kubeconfig has not been removed")
+	}
+}
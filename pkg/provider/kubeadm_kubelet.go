@@ -0,0 +1,175 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getKubeletRootDir returns the kubelet root directory configured (or the default one)
+func getKubeletRootDir(d resourceGetter) string {
+	if dir, ok := d.GetOk("kubelet.0.root_dir"); ok && len(dir.(string)) > 0 {
+		return dir.(string)
+	}
+	return common.DefKubeletRootDir
+}
+
+// getContainerdDir returns the containerd directory ("root" or "state")
+// configured, or an empty string when the containerd default must be used
+func getContainerdDir(d resourceGetter, kind string) string {
+	if dir, ok := d.GetOk(fmt.Sprintf("runtime.0.containerd_%s_dir", kind)); ok {
+		return dir.(string)
+	}
+	return ""
+}
+
+// checkDataPaths checks the kubelet and runtime data directories
+func checkDataPaths(d resourceGetter) error {
+	if dir, ok := d.GetOk("kubelet.0.root_dir"); ok && len(dir.(string)) > 0 {
+		if _, ok := d.GetOk("runtime.0.extra_args.0.kubelet.root-dir"); ok {
+			return fmt.Errorf("kubelet.root_dir cannot be used with a 'root-dir' in runtime.extra_args.kubelet")
+		}
+	}
+
+	for _, kind := range []string{"root", "state"} {
+		if len(getContainerdDir(d, kind)) == 0 {
+			continue
+		}
+		if engine := getRuntimeEngine(d); engine != "containerd" {
+			return fmt.Errorf("runtime.containerd_%s_dir cannot be used with the %q runtime", kind, engine)
+		}
+	}
+	return nil
+}
+
+// customizeDiffDataPaths validates the data directories at plan time
+func customizeDiffDataPaths(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"kubelet", "runtime"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkDataPaths(d)
+}
+
+// evictionSignals are the signals that can be used in the eviction thresholds
+var evictionSignals = []string{
+	"memory.available",
+	"nodefs.available",
+	"nodefs.inodesFree",
+	"imagefs.available",
+	"imagefs.inodesFree",
+	"pid.available",
+}
+
+// getKubeletGC returns the eviction thresholds and the garbage collection
+// settings configured in the `kubelet` block
+func getKubeletGC(d resourceGetter) common.KubeletGCSpec {
+	if !hasBlock(d, "kubelet") {
+		return common.KubeletGCSpec{}
+	}
+	return common.KubeletGCSpec{
+		EvictionHard:                mapFromResourceData(d, "kubelet.0.eviction_hard"),
+		EvictionSoft:                mapFromResourceData(d, "kubelet.0.eviction_soft"),
+		EvictionSoftGracePeriod:     mapFromResourceData(d, "kubelet.0.eviction_soft_grace_period"),
+		ImageGCHighThresholdPercent: d.Get("kubelet.0.image_gc_high_threshold").(int),
+		ImageGCLowThresholdPercent:  d.Get("kubelet.0.image_gc_low_threshold").(int),
+		ContainerLogMaxSize:         d.Get("kubelet.0.container_log_max_size").(string),
+		ContainerLogMaxFiles:        d.Get("kubelet.0.container_log_max_files").(int),
+	}
+}
+
+// checkEvictionThresholds checks the signals and the values (quantities
+// or percentages) of some eviction thresholds
+func checkEvictionThresholds(key string, thresholds map[string]string) error {
+	for signal, value := range thresholds {
+		if !common.StringSliceContains(evictionSignals, signal) {
+			return fmt.Errorf("unknown eviction signal %q in %s (valid signals: %s)", signal, key, strings.Join(evictionSignals, ", "))
+		}
+		if strings.HasSuffix(value, "%") {
+			p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || p <= 0 || p > 100 {
+				return fmt.Errorf("invalid percentage %q for %q in %s", value, signal, key)
+			}
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("invalid quantity %q for %q in %s: %s", value, signal, key, err)
+		}
+	}
+	return nil
+}
+
+// checkKubeletGC checks the eviction thresholds and the garbage collection settings
+func checkKubeletGC(gc common.KubeletGCSpec) error {
+	if err := checkEvictionThresholds("kubelet.eviction_hard", gc.EvictionHard); err != nil {
+		return err
+	}
+	if err := checkEvictionThresholds("kubelet.eviction_soft", gc.EvictionSoft); err != nil {
+		return err
+	}
+
+	// every soft threshold needs a grace period (and the other way round)
+	for signal := range gc.EvictionSoft {
+		if _, ok := gc.EvictionSoftGracePeriod[signal]; !ok {
+			return fmt.Errorf("no grace period in kubelet.eviction_soft_grace_period for the soft eviction signal %q", signal)
+		}
+	}
+	for signal, period := range gc.EvictionSoftGracePeriod {
+		if _, ok := gc.EvictionSoft[signal]; !ok {
+			return fmt.Errorf("grace period for %q in kubelet.eviction_soft_grace_period without a soft eviction threshold", signal)
+		}
+		if d, err := time.ParseDuration(period); err != nil || d <= 0 {
+			return fmt.Errorf("invalid grace period %q for %q in kubelet.eviction_soft_grace_period", period, signal)
+		}
+	}
+
+	high, low := gc.ImageGCHighThresholdPercent, gc.ImageGCLowThresholdPercent
+	if high == 0 {
+		high = common.DefImageGCHighThresholdPercent
+	}
+	if low == 0 {
+		low = common.DefImageGCLowThresholdPercent
+	}
+	if low >= high {
+		return fmt.Errorf("kubelet.image_gc_low_threshold (%d) must be lower than kubelet.image_gc_high_threshold (%d)", low, high)
+	}
+	return nil
+}
+
+// customizeDiffKubeletGC validates the eviction and garbage collection settings at plan time
+func customizeDiffKubeletGC(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("kubelet") {
+		return nil
+	}
+	return checkKubeletGC(getKubeletGC(d))
+}
+
+// customizeDiffKubelet marks the `config` as changed when the kubelet settings
+// are updated in place (the `root_dir` and `serving_certs` force a new resource)
+func customizeDiffKubelet(d *schema.ResourceDiff, meta interface{}) error {
+	if len(d.Id()) == 0 || !d.HasChange("kubelet") {
+		return nil
+	}
+	return d.SetNewComputed("config")
+}
@@ -0,0 +1,117 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestDataPaths(t *testing.T) {
+	tests := []struct {
+		kubelet  map[string]interface{}
+		runtime  map[string]interface{}
+		expected string
+		valid    bool
+	}{
+		{expected: common.DefKubeletRootDir, valid: true},
+		{kubelet: map[string]interface{}{"root_dir": "/mnt/kubelet"}, expected: "/mnt/kubelet", valid: true},
+		{
+			kubelet:  map[string]interface{}{"root_dir": "/mnt/kubelet"},
+			runtime:  map[string]interface{}{"extra_args": []interface{}{map[string]interface{}{"kubelet": map[string]interface{}{"root-dir": "/data"}}}},
+			expected: "/mnt/kubelet",
+			valid:    false,
+		},
+		{
+			runtime:  map[string]interface{}{"engine": "containerd", "containerd_root_dir": "/mnt/containerd", "containerd_state_dir": "/run/ctr"},
+			expected: common.DefKubeletRootDir,
+			valid:    true,
+		},
+		{
+			runtime:  map[string]interface{}{"engine": "crio", "containerd_root_dir": "/mnt/containerd"},
+			expected: common.DefKubeletRootDir,
+			valid:    false,
+		},
+	}
+
+	for i, test := range tests {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+		}
+		if test.kubelet != nil {
+			raw["kubelet"] = []interface{}{test.kubelet}
+		}
+		if test.runtime != nil {
+			raw["runtime"] = []interface{}{test.runtime}
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+		if dir := getKubeletRootDir(d); dir != test.expected {
+			t.Fatalf("Error: test %d: unexpected kubelet root dir %q", i, dir)
+		}
+		if err := checkDataPaths(d); (err == nil) != test.valid {
+			t.Fatalf("Error: test %d: unexpected validation result: %v", i, err)
+		}
+	}
+}
+
+func TestKubeletGC(t *testing.T) {
+	tests := []struct {
+		kubelet map[string]interface{}
+		valid   bool
+	}{
+		{kubelet: map[string]interface{}{}, valid: true},
+		{
+			kubelet: map[string]interface{}{
+				"eviction_hard":              map[string]interface{}{"nodefs.available": "10%", "memory.available": "100Mi"},
+				"eviction_soft":              map[string]interface{}{"imagefs.available": "20%"},
+				"eviction_soft_grace_period": map[string]interface{}{"imagefs.available": "2m"},
+				"image_gc_high_threshold":    70,
+				"image_gc_low_threshold":     50,
+				"container_log_max_size":     "50Mi",
+			},
+			valid: true,
+		},
+		{kubelet: map[string]interface{}{"eviction_hard": map[string]interface{}{"disk.available": "10%"}}, valid: false},
+		{kubelet: map[string]interface{}{"eviction_hard": map[string]interface{}{"nodefs.available": "110%"}}, valid: false},
+		{kubelet: map[string]interface{}{"eviction_hard": map[string]interface{}{"memory.available": "lots"}}, valid: false},
+		{kubelet: map[string]interface{}{"eviction_soft": map[string]interface{}{"nodefs.available": "15%"}}, valid: false},
+		{kubelet: map[string]interface{}{"eviction_soft_grace_period": map[string]interface{}{"nodefs.available": "1m"}}, valid: false},
+		{
+			kubelet: map[string]interface{}{
+				"eviction_soft":              map[string]interface{}{"nodefs.available": "15%"},
+				"eviction_soft_grace_period": map[string]interface{}{"nodefs.available": "soon"},
+			},
+			valid: false,
+		},
+		{kubelet: map[string]interface{}{"image_gc_low_threshold": 90}, valid: false},
+		{kubelet: map[string]interface{}{"image_gc_high_threshold": 60, "image_gc_low_threshold": 60}, valid: false},
+	}
+
+	for i, test := range tests {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"kubelet":     []interface{}{test.kubelet},
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+		if err := checkKubeletGC(getKubeletGC(d)); (err == nil) != test.valid {
+			t.Fatalf("Error: test %d: unexpected validation result: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getManifests returns the manifests that must be applied after creating the cluster:
+// URLs are kept as they are, while local files are replaced by their contents (as
+// the provisioner will not have access to them)
+func getManifests(d resourceGetter) ([]string, error) {
+	res := []string{}
+	for i, v := range stringsFromResourceData(d, "manifests") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		manifest := ssh.NewManifest(v)
+		contents := ""
+		switch {
+		case manifest.URL != "":
+			contents = manifest.URL
+		case manifest.Path != "":
+			data, err := ioutil.ReadFile(manifest.Path)
+			if err != nil {
+				return nil, fmt.Errorf("could not read the manifest %q: %s", manifest.Path, err)
+			}
+			contents = string(data)
+		default:
+			contents = manifest.Inline
+		}
+
+		// the manifests are templates, where the provisioner will replace the cluster facts
+		if _, err := template.New("manifest").Parse(contents); err != nil {
+			return nil, fmt.Errorf("invalid template in manifest #%d: %s", i, err)
+		}
+		res = append(res, contents)
+	}
+	return res, nil
+}
+
+// manifestsDownloadTimeout is the timeout for downloading the manifests from URLs
+const manifestsDownloadTimeout = 30 * time.Second
+
+// getManifestsFacts returns the facts of the cluster that can be used in the URLs of
+// the manifests, so they can be downloaded before the provisioner is run
+func getManifestsFacts(d resourceGetter) (map[string]interface{}, error) {
+	facts := map[string]interface{}{
+		"kube_version":   common.DefKubernetesVersion,
+		"cni_pod_cidr":   common.DefPodCIDR,
+		"dns_domain":     common.DefDNSDomain,
+		"cloud_provider": d.Get("cloud.0.provider").(string),
+	}
+	if v, ok := d.GetOk("version"); ok && len(v.(string)) > 0 {
+		facts["kube_version"] = v.(string)
+	}
+	if v, ok := d.GetOk("network.0.pods"); ok && len(v.(string)) > 0 {
+		facts["cni_pod_cidr"] = v.(string)
+	}
+	if v, ok := d.GetOk("network.0.dns.0.domain"); ok && len(v.(string)) > 0 {
+		facts["dns_domain"] = v.(string)
+	}
+
+	services := common.DefServiceCIDR
+	if v, ok := d.GetOk("network.0.services"); ok && len(v.(string)) > 0 {
+		services = v.(string)
+	}
+	clusterDNS, err := common.GetClusterDNSIP(services)
+	if err != nil {
+		return nil, err
+	}
+	facts["cluster_dns_ip"] = clusterDNS
+	return facts, nil
+}
+
+// downloadManifest gets the contents of a manifest from a URL
+func downloadManifest(url string) ([]byte, error) {
+	client := &http.Client{Timeout: manifestsDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// getManifestsHashes returns a hash for each one of the manifests, so changes
+// in them (ie, in the contents of a local file or of a URL) can be detected
+func getManifestsHashes(d resourceGetter) ([]string, error) {
+	manifests, err := getManifests(d)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, m := range manifests {
+		contents := []byte(m)
+		if manifest := ssh.NewManifest(m); manifest.URL != "" {
+			facts, err := getManifestsFacts(d)
+			if err != nil {
+				return nil, err
+			}
+			if err := manifest.ReplaceConfig(facts); err != nil {
+				return nil, fmt.Errorf("could not replace variables in manifest %q: %s", m, err)
+			}
+			contents, err = downloadManifest(manifest.URL)
+			if err != nil {
+				return nil, fmt.Errorf("could not download the manifest %q: %s", manifest.URL, err)
+			}
+		}
+		hash := sha256.Sum256(contents)
+		res = append(res, hex.EncodeToString(hash[:]))
+	}
+	return res, nil
+}
+
+// setManifestsInConfig sets the manifests in the provisioner configuration, as well
+// as their hashes, so they can be (re)applied by the provisioner
+func setManifestsInConfig(d *schema.ResourceData, config map[string]interface{}) error {
+	manifests, err := getManifests(d)
+	if err != nil {
+		return err
+	}
+	delete(config, "manifests")
+	if len(manifests) > 0 {
+		s, err := common.ManifestsToTerraformSafeString(manifests)
+		if err != nil {
+			return err
+		}
+		config["manifests"] = s
+	}
+
+	hashes, err := getManifestsHashes(d)
+	if err != nil {
+		return err
+	}
+	return d.Set("manifests_hashes", hashes)
+}
+
+// customizeDiffManifests updates the hashes of the manifests in the plan. The resource
+// is updated in place, and the manifests are applied again in the "reconfigure" phase.
+func customizeDiffManifests(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("manifests") {
+		return d.SetNewComputed("manifests_hashes")
+	}
+
+	hashes, err := getManifestsHashes(d)
+	if err != nil {
+		return err
+	}
+
+	old, _ := d.GetChange("manifests_hashes")
+	oldHashes := []string{}
+	for _, h := range old.([]interface{}) {
+		oldHashes = append(oldHashes, h.(string))
+	}
+	if strings.Join(oldHashes, ",") == strings.Join(hashes, ",") {
+		return nil
+	}
+	return d.SetNew("manifests_hashes", hashes)
+}
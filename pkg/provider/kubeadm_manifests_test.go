@@ -0,0 +1,152 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestGetManifests(t *testing.T) {
+	f, err := ioutil.TempFile("", "manifest")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(f.Name())
+	_ = ioutil.WriteFile(f.Name(), []byte("kind: Namespace"), 0644)
+
+	remote := "kind: PriorityClass"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.27.3/manifest.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(remote))
+	}))
+	defer server.Close()
+	url := server.URL + "/{{.kube_version}}/manifest.yaml"
+
+	hashesFor := func(manifests ...interface{}) ([]string, []string) {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"version":     "v1.27.3",
+			"manifests":   manifests,
+		}
+		d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		contents, err := getManifests(d)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		hashes, err := getManifestsHashes(d)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return contents, hashes
+	}
+
+	contents, hashes := hashesFor(
+		url,
+		f.Name(),
+		"kind: PriorityClass\nvalue: 1000",
+		"  ")
+	if len(contents) != 3 || len(hashes) != 3 {
+		t.Fatalf("Error: unexpected number of manifests: %d", len(contents))
+	}
+	if contents[0] != url {
+		t.Fatalf("Error: the URL should not be modified: %q", contents[0])
+	}
+	if contents[1] != "kind: Namespace" {
+		t.Fatalf("Error: the contents of the file were not loaded: %q", contents[1])
+	}
+
+	// the hash must change when the contents of the local file change
+	_ = ioutil.WriteFile(f.Name(), []byte("kind: Namespace\n# changed"), 0644)
+	_, newHashes := hashesFor(f.Name())
+	if newHashes[0] == hashes[1] {
+		t.Fatalf("Error: the hash should change when the manifest changes")
+	}
+
+	// ... and when the contents of the URL change
+	remote = "kind: PriorityClass\n# changed"
+	_, newHashes = hashesFor(url)
+	if newHashes[0] == hashes[0] {
+		t.Fatalf("Error: the hash should change when the contents of the URL change")
+	}
+
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"manifests":   []interface{}{server.URL + "/missing.yaml"},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := getManifestsHashes(d); err == nil {
+		t.Fatalf("Error: no error for a manifest that cannot be downloaded")
+	}
+
+	raw = map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"manifests":   []interface{}{"name: {{.dns_domain"},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := getManifests(d); err == nil {
+		t.Fatalf("Error: no error for an invalid template")
+	}
+}
+
+func TestManifestsUpdatedInPlace(t *testing.T) {
+	diffFor := func(state *terraform.InstanceState, manifest string) *terraform.InstanceDiff {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"manifests":   []interface{}{manifest},
+		}
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("Error: could not create the raw config: %s", err)
+		}
+		diff, err := dataSourceKubeadm().Diff(state, terraform.NewResourceConfig(rawConfig), nil)
+		if err != nil {
+			t.Fatalf("Error: could not compute the diff: %s", err)
+		}
+		return diff
+	}
+
+	// the state of a cluster created with the original manifest
+	state := &terraform.InstanceState{ID: "some-id", Attributes: map[string]string{}}
+	for k, attr := range diffFor(nil, "kind: Namespace\nmetadata:\n  name: original").Attributes {
+		if !attr.NewComputed {
+			state.Attributes[k] = attr.New
+		}
+	}
+	oldHash := state.Attributes["manifests_hashes.0"]
+	if len(oldHash) == 0 {
+		t.Fatalf("Error: no hash for the manifest in the state: %+v", state.Attributes)
+	}
+
+	diff := diffFor(state, "kind: Namespace\nmetadata:\n  name: changed")
+	for k, attr := range diff.Attributes {
+		if attr.RequiresNew {
+			t.Fatalf("Error: %q forces a new cluster: %+v", k, attr)
+		}
+	}
+	if attr, ok := diff.Attributes["manifests_hashes.0"]; !ok || attr.New == oldHash {
+		t.Fatalf("Error: the hashes of the manifests have not been updated: %+v", diff.Attributes)
+	}
+}
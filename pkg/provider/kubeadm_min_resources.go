@@ -0,0 +1,83 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+// minResourcesRoles are the roles with some minimum resources
+var minResourcesRoles = []string{"master", "worker"}
+
+// minResourcesKinds are the resources that can be checked in the nodes
+var minResourcesKinds = []string{"cpus", "memory", "disk"}
+
+// minResourcesSchema returns the schema for the minimum resources of the nodes with some role
+func minResourcesSchema(role string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: fmt.Sprintf("minimum resources for the %s nodes", role),
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"cpus": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Description:  "minimum number of CPUs",
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+				"memory": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Description:  "minimum memory, in MB",
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+				"disk": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Description:  "minimum free disk space for the kubelet root directory, in GB",
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+		},
+	}
+}
+
+// setMinResourcesInConfig passes the minimum resources per role to the provisioner
+// (as "min_<role>_<resource>" values), where they are checked in the preflight checks
+func setMinResourcesInConfig(d *schema.ResourceData, config map[string]interface{}) {
+	for _, role := range minResourcesRoles {
+		for _, kind := range minResourcesKinds {
+			key := fmt.Sprintf("min_%s_%s", role, kind)
+			delete(config, key)
+			if v, ok := d.GetOk(fmt.Sprintf("min_resources.0.%s.0.%s", role, kind)); ok && v.(int) > 0 {
+				config[key] = fmt.Sprintf("%d", v.(int))
+			}
+		}
+	}
+}
+
+// customizeDiffMinResources marks the `config` as changed when the minimum
+// resources are updated, so they are used for the next nodes provisioned
+func customizeDiffMinResources(d *schema.ResourceDiff, meta interface{}) error {
+	if len(d.Id()) == 0 || !d.HasChange("min_resources") {
+		return nil
+	}
+	return d.SetNewComputed("config")
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestSetMinResourcesInConfig(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"min_resources": []interface{}{
+			map[string]interface{}{
+				"master": []interface{}{
+					map[string]interface{}{"cpus": 4, "memory": 8192},
+				},
+				"worker": []interface{}{
+					map[string]interface{}{"disk": 50},
+				},
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	config := map[string]interface{}{"min_worker_cpus": "8"}
+	setMinResourcesInConfig(d, config)
+
+	expected := map[string]interface{}{
+		"min_master_cpus":   "4",
+		"min_master_memory": "8192",
+		"min_worker_disk":   "50",
+	}
+	if len(config) != len(expected) {
+		t.Fatalf("Error: unexpected config: %v", config)
+	}
+	for k, v := range expected {
+		if config[k] != v {
+			t.Fatalf("Error: unexpected %q in the config: %v", k, config[k])
+		}
+	}
+}
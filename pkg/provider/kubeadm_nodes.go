@@ -0,0 +1,158 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// dataSourceKubeadmNodes is the `kubeadm_nodes` data source, that lists
+// the nodes in the live cluster
+func dataSourceKubeadmNodes() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceKubeadmNodesRead,
+		Schema: map[string]*schema.Schema{
+			"kubeconfig": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"config_path"},
+				Description:   "contents of the kubeconfig used for accessing the cluster",
+			},
+			"config_path": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"kubeconfig"},
+				Description:   "path of the kubeconfig used for accessing the cluster",
+			},
+			"role": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "only return the nodes with this role (ie, master)",
+			},
+			"nodes": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"roles": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"version": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"internal_ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ready": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getNodesInfo gets the list of nodes in the cluster (with the `role`, when not empty),
+// in a format that can be stored in the `nodes`
+func getNodesInfo(client kubernetes.Interface, role string) ([]map[string]interface{}, error) {
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// sort the nodes by name, so we get a stable list
+	items := nodes.Items
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	res := []map[string]interface{}{}
+	for _, node := range items {
+		roles := getNodeRoles(node)
+		if len(role) > 0 {
+			found := false
+			for _, r := range roles {
+				if r == role {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		res = append(res, map[string]interface{}{
+			"name":        node.Name,
+			"roles":       roles,
+			"version":     node.Status.NodeInfo.KubeletVersion,
+			"internal_ip": getNodeInternalIP(node),
+			"ready":       isNodeReady(node),
+		})
+	}
+	return res, nil
+}
+
+func dataSourceKubeadmNodesRead(d *schema.ResourceData, meta interface{}) error {
+	kubeconfig := []byte(d.Get("kubeconfig").(string))
+	if len(kubeconfig) == 0 {
+		path, ok := d.GetOk("config_path")
+		if !ok {
+			return fmt.Errorf("one of 'kubeconfig' or 'config_path' must be provided")
+		}
+
+		var err error
+		kubeconfig, err = ioutil.ReadFile(path.(string))
+		if err != nil {
+			return fmt.Errorf("could not read the kubeconfig: %s", err)
+		}
+	}
+
+	client, err := getKubeClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("could not create a client for the cluster: %s", err)
+	}
+
+	nodes, err := getNodesInfo(client, d.Get("role").(string))
+	if err != nil {
+		return fmt.Errorf("could not get the list of nodes: %s", err)
+	}
+	if err := d.Set("nodes", nodes); err != nil {
+		return err
+	}
+
+	hasher := md5.New()
+	for _, node := range nodes {
+		hasher.Write([]byte(node["name"].(string)))
+	}
+	d.SetId(hex.EncodeToString(hasher.Sum(nil)))
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetNodesInfo(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "worker-0",
+				Labels: map[string]string{"node-role.kubernetes.io/ingress": ""},
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeHostName, Address: "worker-0"},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.11"},
+				},
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.15.0"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "master-0",
+				Labels: map[string]string{nodeRoleMasterLabel: ""},
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
+				},
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.15.0"},
+			},
+		},
+	)
+
+	nodes, err := getNodesInfo(client, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("Error: unexpected number of nodes: %d", len(nodes))
+	}
+	if nodes[0]["name"] != "master-0" || nodes[0]["internal_ip"] != "10.0.0.10" || nodes[0]["ready"] != true {
+		t.Fatalf("Error: unexpected master: %v", nodes[0])
+	}
+	if !reflect.DeepEqual(nodes[1]["roles"], []string{"ingress"}) || nodes[1]["internal_ip"] != "10.0.0.11" {
+		t.Fatalf("Error: unexpected worker: %v", nodes[1])
+	}
+
+	nodes, err = getNodesInfo(client, "master")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(nodes) != 1 || nodes[0]["name"] != "master-0" {
+		t.Fatalf("Error: unexpected nodes with the master role: %v", nodes)
+	}
+}
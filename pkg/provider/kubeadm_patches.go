@@ -0,0 +1,66 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getStaticPodPatches returns the patches for the static pods in the `static_pod_patch` blocks
+func getStaticPodPatches(d resourceGetter) []common.StaticPodPatchSpec {
+	res := []common.StaticPodPatchSpec{}
+	patches, _ := d.Get("static_pod_patch").([]interface{})
+	for i := range patches {
+		prefix := fmt.Sprintf("static_pod_patch.%d.", i)
+		res = append(res, common.StaticPodPatchSpec{
+			Target:            d.Get(prefix + "target").(string),
+			Requests:          mapFromResourceData(d, prefix+"requests"),
+			Limits:            mapFromResourceData(d, prefix+"limits"),
+			Env:               mapFromResourceData(d, prefix+"env"),
+			PriorityClassName: d.Get(prefix + "priority_class_name").(string),
+			Patch:             d.Get(prefix + "patch").(string),
+			Type:              d.Get(prefix + "type").(string),
+		})
+	}
+	return res
+}
+
+// getStaticPodPatchFiles returns the files that must be created in the patches
+// directory in the control plane nodes (or nil when there are no patches)
+func getStaticPodPatchFiles(d resourceGetter) (map[string]string, error) {
+	patches := getStaticPodPatches(d)
+	if len(patches) == 0 {
+		return nil, nil
+	}
+	if err := common.CheckPatchesVersion(getKubernetesVersion(d)); err != nil {
+		return nil, err
+	}
+	return common.NewStaticPodPatchFiles(patches)
+}
+
+// customizeDiffStaticPodPatches validates the patches for the static pods at plan time
+func customizeDiffStaticPodPatches(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"static_pod_patch", "version"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	_, err := getStaticPodPatchFiles(d)
+	return err
+}
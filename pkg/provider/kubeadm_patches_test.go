@@ -0,0 +1,49 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetStaticPodPatchFiles(t *testing.T) {
+	patch := map[string]interface{}{
+		"target":   "kube-controller-manager",
+		"requests": map[string]interface{}{"cpu": "200m"},
+	}
+
+	raw := map[string]interface{}{
+		"config_path":      "/tmp/kubeconfig",
+		"version":          "v1.27.3",
+		"static_pod_patch": []interface{}{patch},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	files, err := getStaticPodPatchFiles(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if _, ok := files["kube-controller-manager0a+strategic.json"]; !ok || len(files) != 1 {
+		t.Fatalf("Error: unexpected patch files: %v", files)
+	}
+
+	// patches are not supported in old versions
+	raw["version"] = "v1.18.0"
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := getStaticPodPatchFiles(d); err == nil {
+		t.Fatalf("Error: no error for an old Kubernetes version")
+	}
+}
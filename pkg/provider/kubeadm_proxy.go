@@ -0,0 +1,64 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"net"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getNoProxy returns the list of hosts that must not use the proxy: the ones
+// provided by the user plus the pods and services CIDRs, the cluster
+// domain and the API server addresses
+func getNoProxy(d *schema.ResourceData) []string {
+	res := []string{"localhost", "127.0.0.1"}
+	res = append(res, stringsFromResourceData(d, "proxy.0.no_proxy")...)
+
+	pods, services, domain := common.DefPodCIDR, common.DefServiceCIDR, common.DefDNSDomain
+	if p, ok := d.GetOk("network.0.pods"); ok && len(p.(string)) > 0 {
+		pods = p.(string)
+	}
+	if s, ok := d.GetOk("network.0.services"); ok && len(s.(string)) > 0 {
+		services = s.(string)
+	}
+	if dom, ok := d.GetOk("network.0.dns.0.domain"); ok && len(dom.(string)) > 0 {
+		domain = dom.(string)
+	}
+	res = append(res, pods, services, ".svc", "."+domain)
+
+	for _, key := range []string{"api.0.external", "api.0.internal"} {
+		if addr, ok := d.GetOk(key); ok && len(addr.(string)) > 0 {
+			host := addr.(string)
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			res = append(res, host)
+		}
+	}
+
+	// remove duplicates
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, h := range res {
+		if len(h) > 0 && !seen[h] {
+			seen[h] = true
+			unique = append(unique, h)
+		}
+	}
+	return unique
+}
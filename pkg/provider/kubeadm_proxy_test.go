@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetNoProxy(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"api": []interface{}{
+			map[string]interface{}{
+				"external": "k8s.example.com:6443",
+			},
+		},
+		"proxy": []interface{}{
+			map[string]interface{}{
+				"http":     "http://proxy.internal:3128",
+				"no_proxy": []interface{}{".internal", "localhost"},
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	noProxy := strings.Join(getNoProxy(d), ",")
+	expected := "localhost,127.0.0.1,.internal,10.244.0.0/16,10.96.0.0/12,.svc,.cluster.local,k8s.example.com"
+	if noProxy != expected {
+		t.Fatalf("Error: unexpected no_proxy: %q", noProxy)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// clusterRolesFromResourceData returns the ClusterRoles in the `rbac` block
+func clusterRolesFromResourceData(d resourceGetter) []common.ClusterRoleSpec {
+	res := []common.ClusterRoleSpec{}
+	roles, _ := d.Get("rbac.0.cluster_role").([]interface{})
+	for i := range roles {
+		prefix := fmt.Sprintf("rbac.0.cluster_role.%d.", i)
+
+		role := common.ClusterRoleSpec{Name: d.Get(prefix + "name").(string)}
+		rules, _ := d.Get(prefix + "rule").([]interface{})
+		for j := range rules {
+			rulePrefix := fmt.Sprintf("%srule.%d.", prefix, j)
+			role.Rules = append(role.Rules, common.RBACRuleSpec{
+				APIGroups:       stringsFromResourceData(d, rulePrefix+"api_groups"),
+				Resources:       stringsFromResourceData(d, rulePrefix+"resources"),
+				ResourceNames:   stringsFromResourceData(d, rulePrefix+"resource_names"),
+				NonResourceURLs: stringsFromResourceData(d, rulePrefix+"non_resource_urls"),
+				Verbs:           stringsFromResourceData(d, rulePrefix+"verbs"),
+			})
+		}
+		res = append(res, role)
+	}
+	return res
+}
+
+// roleBindingsFromResourceData returns the bindings in the `rbac` block
+func roleBindingsFromResourceData(d resourceGetter) []common.RoleBindingSpec {
+	res := []common.RoleBindingSpec{}
+	bindings, _ := d.Get("rbac.0.binding").([]interface{})
+	for i := range bindings {
+		prefix := fmt.Sprintf("rbac.0.binding.%d.", i)
+		res = append(res, common.RoleBindingSpec{
+			Name:            d.Get(prefix + "name").(string),
+			Namespace:       d.Get(prefix + "namespace").(string),
+			ClusterRole:     d.Get(prefix + "cluster_role").(string),
+			Users:           stringsFromResourceData(d, prefix+"users"),
+			Groups:          stringsFromResourceData(d, prefix+"groups"),
+			ServiceAccounts: stringsFromResourceData(d, prefix+"service_accounts"),
+		})
+	}
+	return res
+}
+
+// getRBACManifest returns the manifest with the RBAC objects that are created after
+// the initialization of the cluster (or an empty string when there are no RBAC objects)
+func getRBACManifest(d resourceGetter) (string, error) {
+	if !hasBlock(d, "rbac") {
+		return "", nil
+	}
+	manifest, err := common.NewRBACManifest(clusterRolesFromResourceData(d), roleBindingsFromResourceData(d))
+	if err != nil {
+		return "", fmt.Errorf("invalid rbac: %s", err)
+	}
+	return manifest, nil
+}
+
+// customizeDiffRBAC validates the RBAC objects at plan time
+func customizeDiffRBAC(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("rbac") {
+		return nil
+	}
+	_, err := getRBACManifest(d)
+	return err
+}
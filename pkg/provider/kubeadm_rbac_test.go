@@ -0,0 +1,78 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetRBACManifest(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if manifest, err := getRBACManifest(d); err != nil || manifest != "" {
+		t.Fatalf("Error: unexpected RBAC manifest: %q (%v)", manifest, err)
+	}
+
+	raw["rbac"] = []interface{}{
+		map[string]interface{}{
+			"cluster_role": []interface{}{
+				map[string]interface{}{
+					"name": "deployments-scaler",
+					"rule": []interface{}{
+						map[string]interface{}{
+							"api_groups": []interface{}{"apps"},
+							"resources":  []interface{}{"deployments/scale"},
+							"verbs":      []interface{}{"get", "update"},
+						},
+					},
+				},
+			},
+			"binding": []interface{}{
+				map[string]interface{}{
+					"name":         "oidc-scalers",
+					"cluster_role": "deployments-scaler",
+					"groups":       []interface{}{"oidc:scalers"},
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	manifest, err := getRBACManifest(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	for _, expected := range []string{`"deployments/scale"`, `"ClusterRoleBinding"`, `"oidc:scalers"`} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %s not found in the RBAC manifest:\n%s", expected, manifest)
+		}
+	}
+
+	// a binding without subjects
+	raw["rbac"].([]interface{})[0].(map[string]interface{})["binding"] = []interface{}{
+		map[string]interface{}{
+			"name":         "nobody",
+			"cluster_role": "view",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := getRBACManifest(d); err == nil {
+		t.Fatalf("Error: no error for a binding without subjects")
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// defReleaseTimeout is the default timeout for the `helm` operations
+const defReleaseTimeout = "5m0s"
+
+// resourceKubeadmRelease is the `kubeadm_release` resource, that installs (and
+// upgrades) a Helm chart in the cluster with a local `helm`, using an admin
+// kubeconfig signed by the cluster CA
+func resourceKubeadmRelease() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKubeadmReleaseCreate,
+		Read:   resourceKubeadmReleaseRead,
+		Update: resourceKubeadmReleaseUpdate,
+		Delete: resourceKubeadmReleaseDelete,
+		Schema: map[string]*schema.Schema{
+			"config": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Sensitive:   true,
+				Description: "the `config` of the kubeadm resource",
+			},
+			"api_endpoint": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "URL of the API server (defaults to the api.external of the cluster)",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "name of the release",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     common.DefHelmNamespace,
+				Description: "namespace for the release",
+			},
+			"create_namespace": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "create the namespace when it does not exist",
+			},
+			"chart": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "chart (ie, `ingress-nginx`, `stable/mysql`, a local path or a URL)",
+			},
+			"repo": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "repository URL for the chart",
+			},
+			"version": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "version of the chart (the latest one when empty)",
+			},
+			"values": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "values for the chart (in YAML)",
+			},
+			"wait": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "wait until all the resources of the release are ready",
+			},
+			"timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      defReleaseTimeout,
+				Description:  "timeout for the helm operations (ie, `10m`)",
+				ValidateFunc: validateReleaseTimeout,
+			},
+			"revision": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "revision of the release",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "status of the release",
+			},
+			"chart_version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "version of the chart installed",
+			},
+			"app_version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "version of the application in the chart installed",
+			},
+		},
+	}
+}
+
+// validateReleaseTimeout checks the timeout is a valid duration
+func validateReleaseTimeout(v interface{}, k string) ([]string, []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%q is not a valid duration: %s", k, err)}
+	}
+	return validation.NoZeroValues(v, k)
+}
+
+// helmReleaseStatus is the (partial) output of `helm status -o json`
+type helmReleaseStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// runHelm runs the local `helm` with some arguments, returning the output
+// (it can be replaced in tests)
+var runHelm = func(args ...string) ([]byte, error) {
+	ssh.Debug("running helm %s", strings.Join(args, " "))
+	out, err := exec.Command("helm", args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("helm %s failed: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// isReleaseNotFound returns true if the error is about a missing release
+func isReleaseNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "release: not found")
+}
+
+// getReleaseID returns the ID of a release
+func getReleaseID(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// getReleaseArgs returns the `helm` arguments for installing (or upgrading) the release
+func getReleaseArgs(d *schema.ResourceData, kubeconfig string, valuesFile string) []string {
+	args := []string{"upgrade", "--install", d.Get("name").(string), d.Get("chart").(string),
+		"--namespace", d.Get("namespace").(string),
+		"--kubeconfig", kubeconfig,
+		"--timeout", d.Get("timeout").(string)}
+	if d.Get("create_namespace").(bool) {
+		args = append(args, "--create-namespace")
+	}
+	if d.Get("wait").(bool) {
+		args = append(args, "--wait")
+	}
+	if repo := d.Get("repo").(string); len(repo) > 0 {
+		args = append(args, "--repo", repo)
+	}
+	if version := d.Get("version").(string); len(version) > 0 {
+		args = append(args, "--version", version)
+	}
+	if len(valuesFile) > 0 {
+		args = append(args, "--values", valuesFile)
+	}
+	return args
+}
+
+// writeReleaseTempFile writes some contents to a temporary file, returning the name
+func writeReleaseTempFile(pattern string, contents []byte) (string, error) {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := f.Chmod(0600); err != nil {
+		return "", err
+	}
+	if _, err := f.Write(contents); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// withReleaseKubeconfig runs `fn` with the path to an admin kubeconfig for the cluster
+func withReleaseKubeconfig(d *schema.ResourceData, fn func(kubeconfig string) error) error {
+	server, err := getUserAPIServer(d)
+	if err != nil {
+		return err
+	}
+
+	config := common.GetProvisionerConfig(d)
+	caCrt, _ := config["ca_crt"].(string)
+	caKey, _ := config["ca_key"].(string)
+	if len(caCrt) == 0 || len(caKey) == 0 {
+		return fmt.Errorf("no CA certificate/key found in the 'config'")
+	}
+
+	kubeconfig, err := common.NewAdminKubeconfig([]byte(caCrt), []byte(caKey), server)
+	if err != nil {
+		return err
+	}
+	kubeconfigFile, err := writeReleaseTempFile("kubeconfig", kubeconfig)
+	if err != nil {
+		return fmt.Errorf("could not create a temporary kubeconfig: %s", err)
+	}
+	defer os.Remove(kubeconfigFile)
+
+	return fn(kubeconfigFile)
+}
+
+// doReleaseUpgrade installs (or upgrades) the release
+func doReleaseUpgrade(d *schema.ResourceData) error {
+	return withReleaseKubeconfig(d, func(kubeconfig string) error {
+		valuesFile := ""
+		if values := d.Get("values").(string); len(values) > 0 {
+			f, err := writeReleaseTempFile("values", []byte(values))
+			if err != nil {
+				return fmt.Errorf("could not create a temporary values file: %s", err)
+			}
+			defer os.Remove(f)
+			valuesFile = f
+		}
+
+		_, err := runHelm(getReleaseArgs(d, kubeconfig, valuesFile)...)
+		return err
+	})
+}
+
+// setReleaseStatus sets the computed attributes from the output of `helm status`
+func setReleaseStatus(d *schema.ResourceData, out []byte) error {
+	status := helmReleaseStatus{}
+	if err := json.Unmarshal(out, &status); err != nil {
+		return fmt.Errorf("could not parse the status of the release: %s", err)
+	}
+	if err := d.Set("revision", status.Version); err != nil {
+		return err
+	}
+	if err := d.Set("status", status.Info.Status); err != nil {
+		return err
+	}
+	if err := d.Set("chart_version", status.Chart.Metadata.Version); err != nil {
+		return err
+	}
+	return d.Set("app_version", status.Chart.Metadata.AppVersion)
+}
+
+func resourceKubeadmReleaseCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := doReleaseUpgrade(d); err != nil {
+		return err
+	}
+	d.SetId(getReleaseID(d.Get("namespace").(string), d.Get("name").(string)))
+	return resourceKubeadmReleaseRead(d, meta)
+}
+
+func resourceKubeadmReleaseUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := doReleaseUpgrade(d); err != nil {
+		return err
+	}
+	return resourceKubeadmReleaseRead(d, meta)
+}
+
+// resourceKubeadmReleaseRead refreshes the status of the release, removing it from
+// the state when it has been uninstalled (and keeping the previous status when the
+// API server is not reachable)
+func resourceKubeadmReleaseRead(d *schema.ResourceData, meta interface{}) error {
+	return withReleaseKubeconfig(d, func(kubeconfig string) error {
+		out, err := runHelm("status", d.Get("name").(string),
+			"--namespace", d.Get("namespace").(string),
+			"--kubeconfig", kubeconfig,
+			"--output", "json")
+		switch {
+		case isReleaseNotFound(err):
+			ssh.Debug("release %q not found: removing it from the state", d.Id())
+			d.SetId("")
+			return nil
+		case err != nil:
+			ssh.Debug("could not get the status of the release %q: %s", d.Id(), err)
+			return nil
+		}
+		return setReleaseStatus(d, out)
+	})
+}
+
+func resourceKubeadmReleaseDelete(d *schema.ResourceData, meta interface{}) error {
+	return withReleaseKubeconfig(d, func(kubeconfig string) error {
+		_, err := runHelm("uninstall", d.Get("name").(string),
+			"--namespace", d.Get("namespace").(string),
+			"--kubeconfig", kubeconfig)
+		if isReleaseNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
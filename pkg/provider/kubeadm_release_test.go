@@ -0,0 +1,105 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestKubeadmRelease(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode the CA key: %s", err)
+	}
+
+	installed := false
+	commands := []string{}
+	defer func(orig func(args ...string) ([]byte, error)) { runHelm = orig }(runHelm)
+	runHelm = func(args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(args, " "))
+		switch args[0] {
+		case "upgrade":
+			for i, arg := range args {
+				if arg == "--values" {
+					values, _ := ioutil.ReadFile(args[i+1])
+					if string(values) != "replicaCount: 2\n" {
+						t.Fatalf("Error: unexpected values: %q", values)
+					}
+				}
+			}
+			installed = true
+		case "status":
+			if !installed {
+				return nil, fmt.Errorf("helm status failed: Error: release: not found")
+			}
+			return []byte(`{"name":"ingress","namespace":"ingress","version":3,` +
+				`"info":{"status":"deployed"},"chart":{"metadata":{"version":"4.8.3","appVersion":"1.9.4"}}}`), nil
+		case "uninstall":
+			installed = false
+		}
+		return nil, nil
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"ca_crt": string(pkiutil.EncodeCertPEM(caCert)),
+			"ca_key": string(caKeyPEM),
+		},
+		"api_endpoint": "https://k8s.example.com:6443",
+		"name":         "ingress",
+		"namespace":    "ingress",
+		"chart":        "ingress-nginx",
+		"repo":         "https://kubernetes.github.io/ingress-nginx",
+		"values":       "replicaCount: 2\n",
+	}
+	d := schema.TestResourceDataRaw(t, resourceKubeadmRelease().Schema, raw)
+	if err := resourceKubeadmReleaseCreate(d, nil); err != nil {
+		t.Fatalf("Error: could not create the release: %s", err)
+	}
+	if d.Id() != "ingress/ingress" {
+		t.Fatalf("Error: unexpected ID: %q", d.Id())
+	}
+	if !strings.HasPrefix(commands[0], "upgrade --install ingress ingress-nginx --namespace ingress --kubeconfig ") ||
+		!strings.Contains(commands[0], "--timeout 5m0s --create-namespace --wait --repo https://kubernetes.github.io/ingress-nginx --values ") {
+		t.Fatalf("Error: unexpected helm command: %s", commands[0])
+	}
+	if d.Get("revision").(int) != 3 || d.Get("status").(string) != "deployed" || d.Get("chart_version").(string) != "4.8.3" {
+		t.Fatalf("Error: unexpected status: revision=%d status=%s chart_version=%s",
+			d.Get("revision").(int), d.Get("status").(string), d.Get("chart_version").(string))
+	}
+
+	// a release uninstalled out of band must be removed from the state
+	if err := resourceKubeadmReleaseDelete(d, nil); err != nil {
+		t.Fatalf("Error: could not delete the release: %s", err)
+	}
+	if err := resourceKubeadmReleaseRead(d, nil); err != nil {
+		t.Fatalf("Error: could not read the release: %s", err)
+	}
+	if d.Id() != "" {
+		t.Fatalf("Error: the release should have been removed from the state")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// placeholder used for rendering the configurations at plan time,
+	// when the real token has not been generated yet
+	renderedTokenPlaceholder = "abcdef.0123456789abcdef"
+
+	// string that replaces the bootstrap tokens in the rendered configurations
+	renderedTokenRedacted = "<bootstrap-token>"
+)
+
+// renderedConfigInputs are the arguments used for generating the init/join configurations
+var renderedConfigInputs = []string{
+	"version",
+	"cluster_name",
+	"api",
+	"audit",
+	"bootstrap_tokens",
+	"network",
+	"addons",
+	"images",
+	"runtime",
+	"cni",
+	"cloud",
+	"kubelet",
+	"konnectivity",
+	"controller_manager",
+	"etcd",
+	"scheduler",
+	"security",
+	"observability",
+}
+
+// renderedConfigNestedInputs are some nested arguments that must be checked on their own, as
+// an unknown element in a nested list does not make the whole block unknown (ie, alt names
+// that come from other resources)
+var renderedConfigNestedInputs = []string{
+	"api.0.alt_names",
+}
+
+// renderConfigs returns the init and join configurations (in YAML) that will
+// be fed to kubeadm, with the bootstrap tokens redacted
+func renderConfigs(d resourceGetter, token string) (string, string, error) {
+	initConfig, err := dataSourceToInitConfig(d, token)
+	if err != nil {
+		return "", "", err
+	}
+	joinConfig, err := dataSourceToJoinConfig(d, token)
+	if err != nil {
+		return "", "", err
+	}
+
+	initConfigBytes, err := common.InitConfigToYAML(initConfig)
+	if err != nil {
+		return "", "", err
+	}
+	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	tokens := []string{token}
+	for _, t := range bootstrapTokensFromResourceData(d) {
+		tokens = append(tokens, t.Token)
+	}
+	redact := func(b []byte) string {
+		res := string(b)
+		for _, t := range tokens {
+			if len(t) > 0 {
+				res = strings.Replace(res, t, renderedTokenRedacted, -1)
+			}
+		}
+		return res
+	}
+	return redact(initConfigBytes), redact(joinConfigBytes), nil
+}
+
+// setRenderedConfigs sets the `rendered_init_config` and `rendered_join_config` attributes
+func setRenderedConfigs(d *schema.ResourceData, token string) error {
+	initConfig, joinConfig, err := renderConfigs(d, token)
+	if err != nil {
+		return err
+	}
+	if err := d.Set("rendered_init_config", initConfig); err != nil {
+		return err
+	}
+	return d.Set("rendered_join_config", joinConfig)
+}
+
+// customizeDiffRenderedConfigs renders the init/join configurations at plan time,
+// so any change in the configuration fed to kubeadm is visible in `terraform plan`
+func customizeDiffRenderedConfigs(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range append(renderedConfigInputs, renderedConfigNestedInputs...) {
+		if !d.NewValueKnown(k) {
+			if err := d.SetNewComputed("rendered_init_config"); err != nil {
+				return err
+			}
+			return d.SetNewComputed("rendered_join_config")
+		}
+	}
+
+	initConfig, joinConfig, err := renderConfigs(d, renderedTokenPlaceholder)
+	if err != nil {
+		return err
+	}
+	if err := d.SetNew("rendered_init_config", initConfig); err != nil {
+		return err
+	}
+	return d.SetNew("rendered_join_config", joinConfig)
+}
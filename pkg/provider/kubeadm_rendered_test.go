@@ -0,0 +1,198 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestRenderedConfigs(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"api": []interface{}{
+			map[string]interface{}{
+				"internal": "10.10.0.1:6443",
+			},
+		},
+		"bootstrap_tokens": []interface{}{
+			map[string]interface{}{
+				"description": "workers",
+				"ttl":         "2h",
+			},
+		},
+	}
+
+	r := dataSourceKubeadm()
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("Error: could not create the raw config: %s", err)
+	}
+	diff, err := r.Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+	if err != nil {
+		t.Fatalf("Error: could not compute the diff: %s", err)
+	}
+	planInit := diff.Attributes["rendered_init_config"].New
+	planJoin := diff.Attributes["rendered_join_config"].New
+	if !strings.Contains(planInit, "10.10.0.1") {
+		t.Fatalf("Error: internal address not found in the rendered init config:\n%s", planInit)
+	}
+	if !strings.Contains(planInit, "ttl: 2h0m0s") {
+		t.Fatalf("Error: additional bootstrap token not found in the rendered init config:\n%s", planInit)
+	}
+
+	token, err := common.GetRandomToken()
+	if err != nil {
+		t.Fatalf("Error: could not generate a token: %s", err)
+	}
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+	if err := setBootstrapTokens(d); err != nil {
+		t.Fatalf("Error: could not generate the bootstrap tokens: %s", err)
+	}
+	extraToken := d.Get("bootstrap_tokens.0.token").(string)
+	if len(extraToken) == 0 {
+		t.Fatalf("Error: no token generated for the additional bootstrap token")
+	}
+	initConfig, joinConfig, err := renderConfigs(d, token)
+	if err != nil {
+		t.Fatalf("Error: could not render the configs: %s", err)
+	}
+	if strings.Contains(initConfig, token) || strings.Contains(joinConfig, token) || strings.Contains(initConfig, extraToken) {
+		t.Fatalf("Error: the token has not been redacted:\n%s", initConfig)
+	}
+	if initConfig != planInit || joinConfig != planJoin {
+		t.Fatalf("Error: the configs rendered at plan time differ:\n%s\n----\n%s", planInit, initConfig)
+	}
+}
+
+func TestRenderedConfigsUnknownAltNames(t *testing.T) {
+	// the alt names can come from other resources (ie, a load balancer DNS name)
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"api": []interface{}{
+			map[string]interface{}{
+				"external":  "k8s.example.com",
+				"alt_names": []interface{}{"api.example.com", config.UnknownVariableValue},
+			},
+		},
+	}
+
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("Error: could not create the raw config: %s", err)
+	}
+	diff, err := dataSourceKubeadm().Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+	if err != nil {
+		t.Fatalf("Error: could not compute the diff: %s", err)
+	}
+	if !diff.Attributes["rendered_init_config"].NewComputed {
+		t.Fatalf("Error: the rendered init config should be computed: %+v", diff.Attributes["rendered_init_config"])
+	}
+
+	// once they are known, the automatic SANs are added
+	raw["api"].([]interface{})[0].(map[string]interface{})["alt_names"] = []interface{}{"api.example.com", "lb-1234.elb.amazonaws.com"}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := []string{"api.example.com", "lb-1234.elb.amazonaws.com", "k8s.example.com", "kubernetes.default.svc.cluster.local"}
+	if strings.Join(initConfig.APIServer.CertSANs, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Error: unexpected API server SANs: %v", initConfig.APIServer.CertSANs)
+	}
+}
+
+func TestRenderedConfigsUnknownClusterName(t *testing.T) {
+	// the cluster name is used in the init configuration, so it must be known for rendering it
+	raw := map[string]interface{}{
+		"config_path":  "/tmp/kubeconfig",
+		"cluster_name": config.UnknownVariableValue,
+	}
+
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("Error: could not create the raw config: %s", err)
+	}
+	diff, err := dataSourceKubeadm().Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+	if err != nil {
+		t.Fatalf("Error: could not compute the diff: %s", err)
+	}
+	if !diff.Attributes["rendered_init_config"].NewComputed {
+		t.Fatalf("Error: the rendered init config should be computed: %+v", diff.Attributes["rendered_init_config"])
+	}
+
+	// once it is known, it is used in the rendered init config
+	raw["cluster_name"] = "prod"
+	rawConfig, err = config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("Error: could not create the raw config: %s", err)
+	}
+	diff, err = dataSourceKubeadm().Diff(nil, terraform.NewResourceConfig(rawConfig), nil)
+	if err != nil {
+		t.Fatalf("Error: could not compute the diff: %s", err)
+	}
+	if planInit := diff.Attributes["rendered_init_config"].New; !strings.Contains(planInit, "clusterName: prod") {
+		t.Fatalf("Error: cluster name not found in the rendered init config:\n%s", planInit)
+	}
+}
+
+func TestCoreDNSImageUpdatedInPlace(t *testing.T) {
+	diffFor := func(state *terraform.InstanceState, images map[string]interface{}) *terraform.InstanceDiff {
+		raw := map[string]interface{}{
+			"config_path": "/tmp/kubeconfig",
+			"images":      []interface{}{images},
+		}
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("Error: could not create the raw config: %s", err)
+		}
+		diff, err := dataSourceKubeadm().Diff(state, terraform.NewResourceConfig(rawConfig), nil)
+		if err != nil {
+			t.Fatalf("Error: could not compute the diff: %s", err)
+		}
+		return diff
+	}
+
+	// the state of a cluster created with the original CoreDNS image
+	state := &terraform.InstanceState{ID: "some-id", Attributes: map[string]string{}}
+	for k, attr := range diffFor(nil, map[string]interface{}{"coredns_version": "1.3.1"}).Attributes {
+		if !attr.NewComputed {
+			state.Attributes[k] = attr.New
+		}
+	}
+
+	diff := diffFor(state, map[string]interface{}{"coredns_repo": "registry.example.com", "coredns_version": "1.6.2"})
+	for k, attr := range diff.Attributes {
+		if attr.RequiresNew {
+			t.Fatalf("Error: %q forces a new cluster: %+v", k, attr)
+		}
+	}
+	rendered, ok := diff.Attributes["rendered_init_config"]
+	if !ok || !strings.Contains(rendered.New, "imageTag: 1.6.2") || !strings.Contains(rendered.New, "imageRepository: registry.example.com") {
+		t.Fatalf("Error: the CoreDNS image is not in the rendered init config: %+v", rendered)
+	}
+
+	// the repository for the control plane images still forces a new cluster
+	diff = diffFor(state, map[string]interface{}{"coredns_version": "1.3.1", "kube_repo": "registry.example.com"})
+	if attr, ok := diff.Attributes["images.0.kube_repo"]; !ok || !attr.RequiresNew {
+		t.Fatalf("Error: the kube_repo should force a new cluster: %+v", diff.Attributes)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getRuntimeEngine returns the runtime engine configured (or the default one)
+func getRuntimeEngine(d resourceGetter) string {
+	if engine, ok := d.GetOk("runtime.0.engine"); ok && len(engine.(string)) > 0 {
+		return engine.(string)
+	}
+	return common.DefRuntimeEngine
+}
+
+// getCgroupDriver returns the cgroup driver the kubelet will use: the one
+// set in the kubelet args, the one configured or the engine's default
+func getCgroupDriver(d *schema.ResourceData) string {
+	if driver, ok := d.GetOk("runtime.0.extra_args.0.kubelet.cgroup-driver"); ok && len(driver.(string)) > 0 {
+		return driver.(string)
+	}
+	if driver, ok := d.GetOk("runtime.0.cgroup_driver"); ok && len(driver.(string)) > 0 {
+		return driver.(string)
+	}
+	return common.DefCgroupDriver[getRuntimeEngine(d)]
+}
+
+// getSandboxImage returns the sandbox (pause) image, taking into account
+// any custom images repository
+func getSandboxImage(d *schema.ResourceData) string {
+	if repo, ok := d.GetOk("images.0.kube_repo"); ok && len(repo.(string)) > 0 {
+		return fmt.Sprintf("%s/%s", repo.(string), common.DefSandboxImageName)
+	}
+	return fmt.Sprintf("%s/%s", common.DefImagesRepository, common.DefSandboxImageName)
+}
+
+// getRegistries returns the list of registries configured
+func getRegistries(d *schema.ResourceData) []common.RegistrySpec {
+	res := []common.RegistrySpec{}
+	for _, r := range d.Get("registry").([]interface{}) {
+		m := r.(map[string]interface{})
+		registry := common.RegistrySpec{
+			Upstream: m["upstream"].(string),
+			Username: m["username"].(string),
+			Password: m["password"].(string),
+			CA:       m["ca"].(string),
+		}
+		for _, mirror := range m["mirrors"].([]interface{}) {
+			registry.Mirrors = append(registry.Mirrors, mirror.(string))
+		}
+		res = append(res, registry)
+	}
+	return res
+}
+
+// customizeDiffRuntime marks the `config` as changed when the runtime args are
+// updated in place, forcing a new resource when the kubelet cgroup driver changes
+// (as it must be the same in the kubelet and the runtime engine)
+func customizeDiffRuntime(d *schema.ResourceDiff, meta interface{}) error {
+	if len(d.Id()) == 0 || !d.HasChange("runtime") {
+		return nil
+	}
+
+	o, n := d.GetChange("runtime.0.extra_args.0.kubelet")
+	oldDriver, _ := o.(map[string]interface{})["cgroup-driver"]
+	newDriver, _ := n.(map[string]interface{})["cgroup-driver"]
+	if oldDriver != newDriver {
+		return d.ForceNew("runtime")
+	}
+
+	return d.SetNewComputed("config")
+}
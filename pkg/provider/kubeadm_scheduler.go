@@ -0,0 +1,80 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// kind of the scheduler configuration
+	schedulerConfigKind = "KubeSchedulerConfiguration"
+
+	// API group of the scheduler configuration
+	schedulerConfigGroup = "kubescheduler.config.k8s.io"
+)
+
+// validateSchedulerConfig checks that the scheduler config is a `KubeSchedulerConfiguration`
+func validateSchedulerConfig(v interface{}, k string) ([]string, []error) {
+	return validateConfigObject(v.(string), k, schedulerConfigKind, schedulerConfigGroup)
+}
+
+// validateConfigObject checks that some config (in YAML) is an object of some kind, in some API group
+func validateConfigObject(config string, k string, kind string, group string) ([]string, []error) {
+	if len(strings.TrimSpace(config)) == 0 {
+		return nil, nil
+	}
+
+	jsonConfig, err := yaml.ToJSON([]byte(config))
+	if err != nil {
+		return nil, []error{fmt.Errorf("%q is not valid YAML: %s", k, err)}
+	}
+
+	obj := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}{}
+	if err := json.Unmarshal(jsonConfig, &obj); err != nil {
+		return nil, []error{fmt.Errorf("%q is not a valid object: %s", k, err)}
+	}
+	if obj.Kind != kind {
+		return nil, []error{fmt.Errorf("%q must be a %s (got kind %q)", k, kind, obj.Kind)}
+	}
+	if !strings.HasPrefix(obj.APIVersion, group+"/") {
+		return nil, []error{fmt.Errorf("%q must have an apiVersion in the %s group (got %q)", k, group, obj.APIVersion)}
+	}
+	return nil, nil
+}
+
+// getSchedulerConfigPath returns the path where the scheduler config is uploaded in
+// the control plane nodes, or an empty string when no scheduler config has been provided
+func getSchedulerConfigPath(d resourceGetter) string {
+	if !hasBlock(d, "scheduler") {
+		return ""
+	}
+	if config := d.Get("scheduler.0.config").(string); len(strings.TrimSpace(config)) == 0 {
+		return ""
+	}
+	if path, ok := d.GetOk("scheduler.0.config_path"); ok && len(path.(string)) > 0 {
+		return path.(string)
+	}
+	return common.DefSchedulerConfigPath
+}
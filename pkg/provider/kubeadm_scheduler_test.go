@@ -0,0 +1,90 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const testSchedulerConfig = `apiVersion: kubescheduler.config.k8s.io/v1alpha1
+kind: KubeSchedulerConfiguration
+bindTimeoutSeconds: 300
+`
+
+func TestValidateSchedulerConfig(t *testing.T) {
+	valid := []string{
+		testSchedulerConfig,
+		"",
+	}
+	for _, config := range valid {
+		if _, errs := validateSchedulerConfig(config, "config"); len(errs) > 0 {
+			t.Fatalf("Error: unexpected errors for %q: %v", config, errs)
+		}
+	}
+
+	invalid := []string{
+		"apiVersion: kubeproxy.config.k8s.io/v1alpha1\nkind: KubeProxyConfiguration\n",
+		"apiVersion: v1\nkind: KubeSchedulerConfiguration\n",
+		"kind: [KubeSchedulerConfiguration",
+	}
+	for _, config := range invalid {
+		if _, errs := validateSchedulerConfig(config, "config"); len(errs) == 0 {
+			t.Fatalf("Error: no errors for %q", config)
+		}
+	}
+}
+
+func TestGetSchedulerConfigPath(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if path := getSchedulerConfigPath(d); path != "" {
+		t.Fatalf("Error: unexpected scheduler config path: %q", path)
+	}
+
+	raw["scheduler"] = []interface{}{
+		map[string]interface{}{
+			"config": testSchedulerConfig,
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if path := getSchedulerConfigPath(d); path != common.DefSchedulerConfigPath {
+		t.Fatalf("Error: unexpected scheduler config path: %q", path)
+	}
+
+	raw["scheduler"] = []interface{}{
+		map[string]interface{}{
+			"config":      testSchedulerConfig,
+			"config_path": "/etc/kubernetes/scheduler/config.yaml",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if path := getSchedulerConfigPath(d); path != "/etc/kubernetes/scheduler/config.yaml" {
+		t.Fatalf("Error: unexpected scheduler config path: %q", path)
+	}
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if initConfig.Scheduler.ExtraArgs["config"] != "/etc/kubernetes/scheduler/config.yaml" {
+		t.Fatalf("Error: no scheduler config in the args: %v", initConfig.Scheduler.ExtraArgs)
+	}
+}
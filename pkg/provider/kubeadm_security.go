@@ -0,0 +1,107 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// podSecurityVersionRegexp matches the versions of the Pod Security Standards
+var podSecurityVersionRegexp = regexp.MustCompile(`^(latest|v1\.[0-9]+)$`)
+
+// validatePodSecurityVersion checks the version of the Pod Security Standards
+func validatePodSecurityVersion(v interface{}, k string) ([]string, []error) {
+	if !podSecurityVersionRegexp.MatchString(v.(string)) {
+		return nil, []error{fmt.Errorf("%q must be \"latest\" or a Kubernetes minor version like \"v1.25\" (got %q)", k, v.(string))}
+	}
+	return nil, nil
+}
+
+// getKubernetesVersion returns the Kubernetes version of the cluster
+func getKubernetesVersion(d resourceGetter) string {
+	if v, ok := d.GetOk("version"); ok && len(v.(string)) > 0 {
+		return v.(string)
+	}
+	return common.DefKubernetesVersion
+}
+
+// getPodSecuritySpec returns the defaults for the Pod Security admission, or nil
+// when the Pod Security admission is not configured
+func getPodSecuritySpec(d resourceGetter) *common.PodSecuritySpec {
+	if !hasBlock(d, "security") || !hasBlock(d, "security.0.pod_security") {
+		return nil
+	}
+	const prefix = "security.0.pod_security.0."
+
+	exempt := stringsFromResourceData(d, prefix+"exempt_namespaces")
+	if len(exempt) == 0 {
+		exempt = common.DefPodSecurityExemptNamespaces
+	}
+	return &common.PodSecuritySpec{
+		Enforce:          d.Get(prefix + "enforce").(string),
+		Audit:            d.Get(prefix + "audit").(string),
+		Warn:             d.Get(prefix + "warn").(string),
+		Version:          d.Get(prefix + "version").(string),
+		ExemptNamespaces: exempt,
+	}
+}
+
+// getAdmissionConfigPath returns the path where the admission configuration is uploaded
+// in the control plane nodes, or an empty string when there is no admission configuration
+func getAdmissionConfigPath(d resourceGetter) string {
+	if getPodSecuritySpec(d) == nil {
+		return ""
+	}
+	return common.DefAdmissionConfigPath
+}
+
+// getAdmissionConfig returns the AdmissionConfiguration for the API server,
+// or an empty string when there is no admission configuration
+func getAdmissionConfig(d resourceGetter) (string, error) {
+	spec := getPodSecuritySpec(d)
+	if spec == nil {
+		return "", nil
+	}
+	return common.NewPodSecurityAdmissionConfig(*spec, getKubernetesVersion(d))
+}
+
+// checkPodSecurity checks that the Pod Security admission can be configured
+func checkPodSecurity(d resourceGetter) error {
+	if getPodSecuritySpec(d) == nil {
+		return nil
+	}
+	if err := common.CheckPodSecurityVersion(getKubernetesVersion(d)); err != nil {
+		return fmt.Errorf("security.pod_security cannot be used: %s", err)
+	}
+	if _, ok := mapFromResourceData(d, "runtime.0.extra_args.0.api_server")["admission-control-config-file"]; ok {
+		return fmt.Errorf("\"admission-control-config-file\" cannot be provided in the API server extra_args when using security.pod_security")
+	}
+	return nil
+}
+
+// customizeDiffPodSecurity validates the Pod Security settings at plan time
+func customizeDiffPodSecurity(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"security", "version", "runtime"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	return checkPodSecurity(d)
+}
@@ -0,0 +1,114 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestPodSecurity(t *testing.T) {
+	raw := map[string]interface{}{
+		"config_path": "/tmp/kubeconfig",
+		"version":     "v1.27.3",
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if spec := getPodSecuritySpec(d); spec != nil {
+		t.Fatalf("Error: unexpected Pod Security spec: %+v", spec)
+	}
+	if config, err := getAdmissionConfig(d); err != nil || config != "" {
+		t.Fatalf("Error: unexpected admission config: %q (%v)", config, err)
+	}
+
+	raw["security"] = []interface{}{
+		map[string]interface{}{
+			"pod_security": []interface{}{
+				map[string]interface{}{},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	spec := getPodSecuritySpec(d)
+	if spec == nil {
+		t.Fatalf("Error: no Pod Security spec")
+	}
+	if spec.Enforce != "baseline" || spec.Audit != "restricted" || spec.Warn != "restricted" || spec.Version != "latest" {
+		t.Fatalf("Error: unexpected Pod Security defaults: %+v", spec)
+	}
+	if !reflect.DeepEqual(spec.ExemptNamespaces, common.DefPodSecurityExemptNamespaces) {
+		t.Fatalf("Error: unexpected exempt namespaces: %v", spec.ExemptNamespaces)
+	}
+	if err := checkPodSecurity(d); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	config, err := getAdmissionConfig(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if !strings.Contains(config, "pod-security.admission.config.k8s.io/v1") {
+		t.Fatalf("Error: unexpected admission config:\n%s", config)
+	}
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if initConfig.APIServer.ExtraArgs["admission-control-config-file"] != common.DefAdmissionConfigPath {
+		t.Fatalf("Error: no admission config in the API server args: %v", initConfig.APIServer.ExtraArgs)
+	}
+
+	// the flag cannot be provided in the extra args
+	raw["runtime"] = []interface{}{
+		map[string]interface{}{
+			"extra_args": []interface{}{
+				map[string]interface{}{
+					"api_server": map[string]interface{}{
+						"admission-control-config-file": "/etc/kubernetes/admission.yaml",
+					},
+				},
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkPodSecurity(d); err == nil {
+		t.Fatalf("Error: no error when the admission config is in the extra args")
+	}
+
+	// old versions do not have a Pod Security admission
+	delete(raw, "runtime")
+	raw["version"] = "v1.20.0"
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if err := checkPodSecurity(d); err == nil {
+		t.Fatalf("Error: no error for a version without the Pod Security admission")
+	}
+}
+
+func TestValidatePodSecurityVersion(t *testing.T) {
+	for _, v := range []string{"latest", "v1.25", "v1.9"} {
+		if _, errs := validatePodSecurityVersion(v, "version"); len(errs) > 0 {
+			t.Fatalf("Error: unexpected errors for %q: %v", v, errs)
+		}
+	}
+	for _, v := range []string{"", "1.25", "v1.25.0", "newest"} {
+		if _, errs := validatePodSecurityVersion(v, "version"); len(errs) == 0 {
+			t.Fatalf("Error: no errors for %q", v)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// clusterSpecFromResourceData copies the settings from the
+// Terraform `data` definition to a cluster spec
+func clusterSpecFromResourceData(d resourceGetter, token string) common.ClusterSpec {
+	spec := common.ClusterSpec{
+		Token:           token,
+		BootstrapTokens: bootstrapTokensFromResourceData(d),
+	}
+
+	if versionOpt, ok := d.GetOk("version"); ok {
+		spec.Version = versionOpt.(string)
+	}
+	spec.ClusterName = getClusterName(d)
+
+	spec.API.AutoSANs = isAutoSANsEnabled(d)
+	if hasBlock(d, "api") {
+		spec.API.External = d.Get("api.0.external").(string)
+		spec.API.Internal = d.Get("api.0.internal").(string)
+		spec.API.AltNames = stringsFromResourceData(d, "api.0.alt_names")
+
+		// the VIP is the stable address for the control plane when there is no external one
+		if vip, ok := d.GetOk("api.0.vip.0.address"); ok && len(spec.API.External) == 0 {
+			spec.API.External = vip.(string)
+		}
+	}
+
+	if hasBlock(d, "network") {
+		spec.Network.Pods = d.Get("network.0.pods").(string)
+		spec.Network.Services = d.Get("network.0.services").(string)
+		if hasBlock(d, "network.0.dns") {
+			spec.Network.DNSDomain = d.Get("network.0.dns.0.domain").(string)
+			spec.Network.DNSUpstream = stringsFromResourceData(d, "network.0.dns.0.upstream")
+		}
+	}
+
+	spec.Network.NodeLocalDNS = getNodeLocalDNS(d)
+
+	if hasBlock(d, "images") {
+		spec.Images.KubeRepo = d.Get("images.0.kube_repo").(string)
+		spec.Images.EtcdRepo = d.Get("images.0.etcd_repo").(string)
+		spec.Images.EtcdVersion = d.Get("images.0.etcd_version").(string)
+		spec.Images.CoreDNSRepo = d.Get("images.0.coredns_repo").(string)
+		spec.Images.CoreDNSVersion = d.Get("images.0.coredns_version").(string)
+	}
+
+	if hasBlock(d, "runtime") {
+		spec.Runtime.Engine = getRuntimeEngine(d)
+		spec.Runtime.CgroupDriver = d.Get("runtime.0.cgroup_driver").(string)
+		spec.Runtime.CRISocket = d.Get("runtime.0.cri_socket").(string)
+		spec.Runtime.APIServerArgs = mapFromResourceData(d, "runtime.0.extra_args.0.api_server")
+		spec.Runtime.ControllerManagerArgs = mapFromResourceData(d, "runtime.0.extra_args.0.controller_manager")
+		spec.Runtime.SchedulerArgs = mapFromResourceData(d, "runtime.0.extra_args.0.scheduler")
+		spec.Runtime.KubeletArgs = mapFromResourceData(d, "runtime.0.extra_args.0.kubelet")
+	}
+
+	if hasBlock(d, "cni") {
+		spec.CNI.BinDir = d.Get("cni.0.bin_dir").(string)
+		spec.CNI.ConfDir = d.Get("cni.0.conf_dir").(string)
+	}
+
+	if cloudProvOpt, ok := d.GetOk("cloud.0.provider"); ok {
+		spec.CloudProvider = cloudProvOpt.(string)
+		spec.CloudConfigPath = getCloudConfigPath(d)
+	}
+
+	spec.ControllerManager = getControllerManagerSpec(d)
+	spec.SchedulerConfigPath = getSchedulerConfigPath(d)
+	spec.AdmissionConfigPath = getAdmissionConfigPath(d)
+	spec.Audit = getAuditSpec(d)
+	spec.Konnectivity = isKonnectivityEnabled(d)
+
+	spec.EtcdEndpoints = stringsFromResourceData(d, "etcd.0.endpoints")
+	if hasBlock(d, "etcd.0.local") {
+		spec.EtcdLocal.DataDir = d.Get("etcd.0.local.0.data_dir").(string)
+		spec.EtcdLocal.ExtraArgs = mapFromResourceData(d, "etcd.0.local.0.extra_args")
+		spec.EtcdLocal.ServerCertSANs = stringsFromResourceData(d, "etcd.0.local.0.server_cert_sans")
+		spec.EtcdLocal.PeerCertSANs = stringsFromResourceData(d, "etcd.0.local.0.peer_cert_sans")
+	}
+
+	spec.KubeletServingCerts = isKubeletServingCertsEnabled(d)
+	if dir, ok := d.GetOk("kubelet.0.root_dir"); ok {
+		spec.KubeletRootDir = dir.(string)
+	}
+	spec.KubeletGC = getKubeletGC(d)
+
+	if expose, ok := d.GetOk("observability.0.expose_control_plane_metrics"); ok {
+		spec.ExposeControlPlaneMetrics = expose.(bool)
+	}
+
+	return spec
+}
+
+// isAutoSANsEnabled returns true when the API server certificate must include the
+// automatic SANs (the default when there is no `api` block)
+func isAutoSANsEnabled(d resourceGetter) bool {
+	return !hasBlock(d, "api") || d.Get("api.0.auto_sans").(bool)
+}
+
+// isKubeletServingCertsEnabled returns true when the kubelets must use serving
+// certificates signed by the cluster CA
+func isKubeletServingCertsEnabled(d resourceGetter) bool {
+	return hasBlock(d, "kubelet") && d.Get("kubelet.0.serving_certs").(bool)
+}
+
+// hasBlock returns true when a block has been provided in the configuration
+// NOTE: we do not use `GetOk("<block>.0")` as a ResourceDiff returns the defaults
+// for missing blocks, and we must get the same results in plans and applies
+func hasBlock(d resourceGetter, key string) bool {
+	l, ok := d.Get(key).([]interface{})
+	return ok && len(l) > 0
+}
+
+// stringsFromResourceData returns a list of strings from the ResourceData
+func stringsFromResourceData(d resourceGetter, key string) []string {
+	res := []string{}
+	if opt, ok := d.GetOk(key); ok {
+		for _, s := range opt.([]interface{}) {
+			res = append(res, s.(string))
+		}
+	}
+	return res
+}
+
+// mapFromResourceData returns a map of strings from the ResourceData
+func mapFromResourceData(d resourceGetter, key string) map[string]string {
+	res := map[string]string{}
+	if opt, ok := d.GetOk(key); ok {
+		for k, v := range opt.(map[string]interface{}) {
+			res[k] = v.(string)
+		}
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// bootstrapTokensFromResourceData returns the additional bootstrap tokens
+// NOTE: the tokens that have not been generated yet (ie, at plan time) are
+// replaced by a placeholder
+func bootstrapTokensFromResourceData(d resourceGetter) []common.BootstrapTokenSpec {
+	res := []common.BootstrapTokenSpec{}
+	tokens, _ := d.Get("bootstrap_tokens").([]interface{})
+	for i := range tokens {
+		prefix := fmt.Sprintf("bootstrap_tokens.%d.", i)
+
+		token := d.Get(prefix + "token").(string)
+		if len(token) == 0 {
+			token = renderedTokenPlaceholder
+		}
+		ttl, _ := time.ParseDuration(d.Get(prefix + "ttl").(string))
+
+		res = append(res, common.BootstrapTokenSpec{
+			Token:       token,
+			Description: d.Get(prefix + "description").(string),
+			TTL:         ttl,
+			Usages:      stringsFromResourceData(d, prefix+"usages"),
+			Groups:      stringsFromResourceData(d, prefix+"groups"),
+		})
+	}
+	return res
+}
+
+// setBootstrapTokens generates the additional bootstrap tokens that have not been provided
+func setBootstrapTokens(d *schema.ResourceData) error {
+	tokens, _ := d.Get("bootstrap_tokens").([]interface{})
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	for _, t := range tokens {
+		token := t.(map[string]interface{})
+		if s, _ := token["token"].(string); len(s) > 0 {
+			continue
+		}
+
+		ssh.Debug("generating a random token for %q...", token["description"])
+		s, err := common.GetRandomToken()
+		if err != nil {
+			return err
+		}
+		token["token"] = s
+	}
+	return d.Set("bootstrap_tokens", tokens)
+}
@@ -0,0 +1,235 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// resourceKubeadmUser is the `kubeadm_user` resource, that creates a
+// client certificate (signed by the cluster CA) and a kubeconfig for
+// some additional user, optionally binding some cluster roles to it
+func resourceKubeadmUser() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKubeadmUserCreate,
+		Read:   resourceKubeadmUserRead,
+		Delete: resourceKubeadmUserDelete,
+		Schema: map[string]*schema.Schema{
+			"config": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "the `config` of the kubeadm resource",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "name of the user (the CN in the certificate)",
+			},
+			"groups": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "groups of the user (the O in the certificate)",
+			},
+			"cluster_roles": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "cluster roles bound to the user",
+			},
+			"api_endpoint": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "URL of the API server (defaults to the api.external of the cluster)",
+			},
+			"cluster_ca_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM-encoded CA certificate of the cluster",
+			},
+			"client_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM-encoded client certificate for the user",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM-encoded client key for the user",
+			},
+			"kubeconfig": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "kubeconfig for the user",
+			},
+		},
+	}
+}
+
+// getUserAPIServer returns the URL of the API server for the user kubeconfig
+func getUserAPIServer(d *schema.ResourceData) (string, error) {
+	if endpoint, ok := d.GetOk("api_endpoint"); ok {
+		return endpoint.(string), nil
+	}
+
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return "", err
+	}
+	server := getKubeconfigServer(initConfig)
+	if len(server) == 0 {
+		return "", fmt.Errorf("the API server address is unknown: set 'api.external' in the kubeadm resource or 'api_endpoint'")
+	}
+	return server, nil
+}
+
+// getUserStringList returns the list of strings in `key`
+func getUserStringList(d *schema.ResourceData, key string) []string {
+	res := []string{}
+	for _, s := range d.Get(key).([]interface{}) {
+		res = append(res, s.(string))
+	}
+	return res
+}
+
+// getUserClusterRoleBindingName returns the name of the ClusterRoleBinding for
+// binding a cluster role to a user
+func getUserClusterRoleBindingName(user, role string) string {
+	return fmt.Sprintf("kubeadm:user:%s:%s", user, role)
+}
+
+// getUserAdminClient returns a Kubernetes client with admin privileges, created
+// with a kubeconfig signed by the cluster CA
+func getUserAdminClient(d *schema.ResourceData, server string) (kubernetes.Interface, error) {
+	config := common.GetProvisionerConfig(d)
+	caCrt, _ := config["ca_crt"].(string)
+	caKey, _ := config["ca_key"].(string)
+
+	kubeconfig, err := common.NewAdminKubeconfig([]byte(caCrt), []byte(caKey), server)
+	if err != nil {
+		return nil, err
+	}
+	return getKubeClientFromKubeconfig(kubeconfig)
+}
+
+// bindUserClusterRoles creates a ClusterRoleBinding for every cluster role
+func bindUserClusterRoles(client kubernetes.Interface, user string, roles []string) error {
+	for _, role := range roles {
+		binding := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   getUserClusterRoleBindingName(user, role),
+				Labels: map[string]string{"app.kubernetes.io/managed-by": common.TokenDescription},
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     role,
+			},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: user},
+			},
+		}
+		ssh.Debug("binding cluster role %q to user %q", role, user)
+		if _, err := client.RbacV1().ClusterRoleBindings().Create(binding); err != nil {
+			return fmt.Errorf("could not bind the cluster role %q: %s", role, err)
+		}
+	}
+	return nil
+}
+
+func resourceKubeadmUserCreate(d *schema.ResourceData, meta interface{}) error {
+	name := d.Get("name").(string)
+	groups := getUserStringList(d, "groups")
+
+	server, err := getUserAPIServer(d)
+	if err != nil {
+		return err
+	}
+
+	config := common.GetProvisionerConfig(d)
+	caCrt, _ := config["ca_crt"].(string)
+	caKey, _ := config["ca_key"].(string)
+	if len(caCrt) == 0 || len(caKey) == 0 {
+		return fmt.Errorf("no CA certificate/key found in the 'config'")
+	}
+
+	ssh.Debug("creating a client certificate for user %q (groups: %v)", name, groups)
+	kubeconfig, err := common.NewUserKubeconfig([]byte(caCrt), []byte(caKey), server, name, groups)
+	if err != nil {
+		return err
+	}
+	if err := d.Set("kubeconfig", string(kubeconfig)); err != nil {
+		return err
+	}
+	if err := setKubeconfigAttributes(d); err != nil {
+		return err
+	}
+
+	if roles := getUserStringList(d, "cluster_roles"); len(roles) > 0 {
+		client, err := getUserAdminClient(d, server)
+		if err != nil {
+			return err
+		}
+		if err := bindUserClusterRoles(client, name, roles); err != nil {
+			return err
+		}
+	}
+
+	d.SetId(name)
+	return resourceKubeadmUserRead(d, meta)
+}
+
+func resourceKubeadmUserRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+// resourceKubeadmUserDelete removes the ClusterRoleBindings created for the user.
+// Note well: the certificate cannot be revoked, and it will be valid until it expires.
+func resourceKubeadmUserDelete(d *schema.ResourceData, meta interface{}) error {
+	roles := getUserStringList(d, "cluster_roles")
+	if len(roles) == 0 {
+		return nil
+	}
+
+	client, err := getUserAdminClient(d, d.Get("api_endpoint").(string))
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		name := getUserClusterRoleBindingName(d.Get("name").(string), role)
+		if err := client.RbacV1().ClusterRoleBindings().Delete(name, &metav1.DeleteOptions{}); err != nil {
+			// the cluster could be gone at this point: do not consider it an error
+			ssh.Debug("could not remove the ClusterRoleBinding %q: %s", name, err)
+		}
+	}
+	return nil
+}
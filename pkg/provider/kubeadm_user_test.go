@@ -0,0 +1,71 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestKubeadmUser(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create the CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode the CA key: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"ca_crt": string(pkiutil.EncodeCertPEM(caCert)),
+			"ca_key": string(caKeyPEM),
+		},
+		"name":         "ci",
+		"groups":       []interface{}{"ci-users"},
+		"api_endpoint": "https://k8s.example.com:6443",
+	}
+	d := schema.TestResourceDataRaw(t, resourceKubeadmUser().Schema, raw)
+	if err := resourceKubeadmUserCreate(d, nil); err != nil {
+		t.Fatalf("Error: could not create the user: %s", err)
+	}
+
+	certs, err := certutil.ParseCertsPEM([]byte(d.Get("client_certificate").(string)))
+	if err != nil {
+		t.Fatalf("Error: could not parse the client certificate: %s", err)
+	}
+	if certs[0].Subject.CommonName != "ci" || certs[0].Subject.Organization[0] != "ci-users" {
+		t.Fatalf("Error: unexpected subject in the client certificate: %v", certs[0].Subject)
+	}
+	if err := certs[0].CheckSignatureFrom(caCert); err != nil {
+		t.Fatalf("Error: the client certificate is not signed by the CA: %s", err)
+	}
+
+	client := fake.NewSimpleClientset()
+	if err := bindUserClusterRoles(client, "ci", []string{"view"}); err != nil {
+		t.Fatalf("Error: could not bind the cluster roles: %s", err)
+	}
+	binding, err := client.RbacV1().ClusterRoleBindings().Get(getUserClusterRoleBindingName("ci", "view"), metav1.GetOptions{})
+	if err != nil || binding.RoleRef.Name != "view" || binding.Subjects[0].Name != "ci" {
+		t.Fatalf("Error: unexpected ClusterRoleBinding: %v (%v)", binding, err)
+	}
+}
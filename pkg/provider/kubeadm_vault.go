@@ -0,0 +1,146 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// providerMeta is the provider configuration passed to the resources
+type providerMeta struct {
+	vault      *common.VaultClient
+	vaultMount string
+	vaultPath  string
+}
+
+// providerConfigure loads the provider configuration
+func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	meta := &providerMeta{}
+
+	if _, ok := d.GetOk("vault"); ok {
+		address := d.Get("vault.0.address").(string)
+		token := d.Get("vault.0.token").(string)
+		if len(address) == 0 || len(token) == 0 {
+			return nil, fmt.Errorf("the Vault address and token must be provided (or set in %s and %s)",
+				common.DefVaultAddressEnv, common.DefVaultTokenEnv)
+		}
+		meta.vault = common.NewVaultClient(address, token)
+		meta.vaultMount = d.Get("vault.0.mount").(string)
+		meta.vaultPath = d.Get("vault.0.path").(string)
+	}
+
+	return meta, nil
+}
+
+// getVaultClient returns the Vault client for the secrets in the `config`,
+// or nil when they are not stored in Vault
+func getVaultClient(d *schema.ResourceData, meta interface{}) (*common.VaultClient, string, error) {
+	path, _ := common.GetProvisionerConfig(d)["vault_secrets_path"].(string)
+	if len(path) == 0 {
+		return nil, "", nil
+	}
+
+	m, ok := meta.(*providerMeta)
+	if !ok || m.vault == nil {
+		return nil, "", fmt.Errorf("secrets are stored in Vault at %q, but Vault is not configured in the provider", path)
+	}
+	return m.vault, path, nil
+}
+
+// storeSecretsInVault moves the secrets in the `config` (the bootstrap token and the
+// certificate key) to Vault (when configured in the provider), so they are not kept
+// in the Terraform state
+func storeSecretsInVault(d *schema.ResourceData, meta interface{}) error {
+	m, ok := meta.(*providerMeta)
+	if !ok || m.vault == nil {
+		return nil
+	}
+
+	config := common.GetProvisionerConfig(d)
+	path := common.GetVaultSecretsPath(m.vaultMount, m.vaultPath, d.Id())
+
+	secrets := map[string]string{}
+	for _, k := range common.VaultSecretsKeys {
+		secrets[k], _ = config[k].(string)
+	}
+	ssh.Debug("storing secrets in Vault at %q", path)
+	if err := m.vault.WriteSecrets(path, secrets); err != nil {
+		return err
+	}
+
+	if err := common.SetTokenInConfig(config, ""); err != nil {
+		return err
+	}
+	delete(config, "certificate_key")
+	config["vault_address"] = m.vault.Address
+	config["vault_secrets_path"] = path
+	return d.Set("config", config)
+}
+
+// getConfigWithVaultSecrets returns a copy of the `config`, with the secrets stored in Vault
+func getConfigWithVaultSecrets(d *schema.ResourceData, meta interface{}) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	for k, v := range common.GetProvisionerConfig(d) {
+		config[k] = v
+	}
+
+	vault, path, err := getVaultClient(d, meta)
+	if err != nil || vault == nil {
+		return config, err
+	}
+	secrets, err := vault.ReadSecrets(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range common.VaultSecretsKeys {
+		config[k] = secrets[k]
+	}
+	return config, nil
+}
+
+// syncKubeconfigWithVault stores the `kubeconfig` in Vault (when the secrets are
+// stored there), removing it (and the client key) from the Terraform state
+func syncKubeconfigWithVault(d *schema.ResourceData, meta interface{}) error {
+	vault, path, err := getVaultClient(d, meta)
+	if err != nil || vault == nil {
+		return err
+	}
+
+	if kubeconfig := d.Get("kubeconfig").(string); len(kubeconfig) > 0 {
+		secrets, err := vault.ReadSecrets(path)
+		if err != nil {
+			return err
+		}
+		if secrets["kubeconfig"] != kubeconfig {
+			ssh.Debug("storing the kubeconfig in Vault at %q", path)
+			secrets["kubeconfig"] = kubeconfig
+			if err := vault.WriteSecrets(path, secrets); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, k := range []string{"kubeconfig", "client_key"} {
+		if err := d.Set(k, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
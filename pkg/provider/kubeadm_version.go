@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getRunningClusterVersion returns the version of the API server in the running
+// cluster (when there is a local kubeconfig and the cluster is reachable)
+func getRunningClusterVersion(d resourceGetter) (string, error) {
+	client, err := getKubeClient(d)
+	if err != nil {
+		return "", err
+	}
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+// checkVersionUpgrade checks that the cluster can be upgraded from the `current`
+// version (the previous version or the version running in the cluster) to the new one
+func checkVersionUpgrade(current string, target string) error {
+	if len(current) == 0 || len(target) == 0 || current == target {
+		return nil
+	}
+	if err := common.CheckUpgradeVersionSkew(current, target); err != nil {
+		return fmt.Errorf("invalid version: %s", err)
+	}
+	return nil
+}
+
+// customizeDiffVersion validates a change of the `version` at plan time, against the previous
+// version and against the version running in the cluster
+func customizeDiffVersion(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("version") || !d.NewValueKnown("version") {
+		return nil
+	}
+	oldVersion, newVersion := d.GetChange("version")
+	if err := checkVersionUpgrade(oldVersion.(string), newVersion.(string)); err != nil {
+		return err
+	}
+
+	running, err := getRunningClusterVersion(d)
+	if err != nil {
+		ssh.Debug("could not get the version of the running cluster: %s", err)
+		return nil
+	}
+	return checkVersionUpgrade(running, newVersion.(string))
+}
@@ -0,0 +1,43 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+)
+
+func TestCheckVersionUpgrade(t *testing.T) {
+	valid := [][2]string{
+		{"", "v1.27.3"},
+		{"v1.27.3", "v1.27.3"},
+		{"v1.26.5", "v1.27.3"},
+		{"v1.27.0+k3s1", "v1.27.3"},
+	}
+	for _, c := range valid {
+		if err := checkVersionUpgrade(c[0], c[1]); err != nil {
+			t.Fatalf("Error: unexpected error from %q to %q: %s", c[0], c[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"v1.25.5", "v1.27.3"},
+		{"v1.27.3", "v1.26.0"},
+	}
+	for _, c := range invalid {
+		if err := checkVersionUpgrade(c[0], c[1]); err == nil {
+			t.Fatalf("Error: no error from %q to %q", c[0], c[1])
+		}
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// timeout for any request to the API server done from the provider
+	kubeClientTimeout = 15 * time.Second
+
+	// label used by kubeadm for marking the control plane nodes
+	nodeRoleMasterLabel = "node-role.kubernetes.io/master"
+
+	// prefix for the labels used for the roles of the nodes
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
+	// the join token is replaced when it expires in less than this time...
+	joinTokenRefreshMargin = 1 * time.Hour
+
+	// ... by a new token with this TTL
+	joinTokenTTL = 24 * time.Hour
+)
+
+var (
+	// ErrNoKubeconfig is returned when the local kubeconfig is not (yet) available
+	ErrNoKubeconfig = errors.New("no local kubeconfig available")
+)
+
+// getKubeClient returns a Kubernetes client that uses the local kubeconfig
+// in `config_path`
+func getKubeClient(d resourceGetter) (kubernetes.Interface, error) {
+	kubeconfig := d.Get("config_path").(string)
+	if kubeconfig == "" {
+		return nil, ErrNoKubeconfig
+	}
+	if _, err := os.Stat(kubeconfig); err != nil {
+		return nil, ErrNoKubeconfig
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = kubeClientTimeout
+
+	return kubernetes.NewForConfig(config)
+}
+
+// getKubeClientFromKubeconfig returns a Kubernetes client for the
+// kubeconfig contents provided
+func getKubeClientFromKubeconfig(kubeconfig []byte) (kubernetes.Interface, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = kubeClientTimeout
+
+	return kubernetes.NewForConfig(config)
+}
+
+// getNodeRole returns the role of a node, as a string
+func getNodeRole(node corev1.Node) string {
+	if _, ok := node.Labels[nodeRoleMasterLabel]; ok {
+		return "master"
+	}
+	return "worker"
+}
+
+// getNodeRoles returns the roles of a node, from the `node-role.kubernetes.io/<role>` labels
+func getNodeRoles(node corev1.Node) []string {
+	roles := []string{}
+	for label := range node.Labels {
+		if strings.HasPrefix(label, nodeRoleLabelPrefix) {
+			if role := strings.TrimPrefix(label, nodeRoleLabelPrefix); len(role) > 0 {
+				roles = append(roles, role)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// getNodeInternalIP returns the `InternalIP` address of a node (if any)
+func getNodeInternalIP(node corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// isNodeReady returns true if the node has the "Ready" condition
+func isNodeReady(node corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// getNodeDistro returns the distribution and its release from the OS image reported
+// by the kubelet (ie, "ubuntu" and "22.04.3" for "Ubuntu 22.04.3 LTS", or "centos"
+// and "7" for "CentOS Linux 7 (Core)")
+func getNodeDistro(osImage string) (string, string) {
+	distro, release := []string{}, ""
+	for _, word := range strings.Fields(osImage) {
+		if word[0] >= '0' && word[0] <= '9' {
+			release = word
+			break
+		}
+		w := strings.ToLower(word)
+		if len(distro) > 0 && (w == "linux" || w == "gnu/linux") {
+			continue
+		}
+		distro = append(distro, w)
+	}
+	return strings.Join(distro, "-"), release
+}
+
+// getNodeFacts returns some facts about the OS, the runtime and the resources
+// of a node, as reported by its kubelet
+func getNodeFacts(node corev1.Node) map[string]interface{} {
+	info := node.Status.NodeInfo
+	distro, release := getNodeDistro(info.OSImage)
+
+	memory := int64(0)
+	if q, ok := node.Status.Allocatable[corev1.ResourceMemory]; ok {
+		memory = q.Value() / (1024 * 1024)
+	}
+	cpu := ""
+	if q, ok := node.Status.Allocatable[corev1.ResourceCPU]; ok {
+		cpu = q.String()
+	}
+
+	return map[string]interface{}{
+		"os_image":          info.OSImage,
+		"distro":            distro,
+		"release":           release,
+		"kernel_version":    info.KernelVersion,
+		"architecture":      info.Architecture,
+		"container_runtime": info.ContainerRuntimeVersion,
+		"cpu":               cpu,
+		"memory_mb":         int(memory),
+	}
+}
+
+// getNodesStatus gets the nodes in the cluster, with some info about them,
+// in a map indexed by the node name
+func getNodesStatus(client kubernetes.Interface) (map[string]map[string]interface{}, error) {
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]map[string]interface{}{}
+	for _, node := range nodes.Items {
+		status := map[string]interface{}{
+			"role":      getNodeRole(node),
+			"ready":     isNodeReady(node),
+			"version":   node.Status.NodeInfo.KubeletVersion,
+			"joined_at": node.CreationTimestamp.UTC().Format(time.RFC3339),
+		}
+		for k, v := range getNodeFacts(node) {
+			status[k] = v
+		}
+		res[node.Name] = status
+	}
+	return res, nil
+}
+
+// nodesStatusToMap converts the status of the nodes to the format stored in the
+// `nodes_status`: a map from the node name to a JSON document, as the SDK does not
+// support maps of objects (so it can be used as `jsondecode(nodes_status["name"])`)
+func nodesStatusToMap(status map[string]map[string]interface{}) (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	for name, s := range status {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = string(b)
+	}
+	return res, nil
+}
+
+// updateNodesStatus refreshes the `nodes_status` with the current status of the cluster.
+// The API server could be not reachable from the machine where Terraform is run, so
+// failures are not considered errors: we just keep the previous status.
+func updateNodesStatus(d *schema.ResourceData) error {
+	// keepPrevious makes sure we have some (maybe empty) value in the
+	// `nodes_status`, otherwise it would be always shown as <computed>
+	keepPrevious := func() error {
+		if _, ok := d.GetOk("nodes_status"); ok {
+			return nil
+		}
+		return d.Set("nodes_status", map[string]interface{}{})
+	}
+
+	client, err := getKubeClient(d)
+	if err != nil {
+		ssh.Debug("cannot refresh nodes status: %s", err)
+		return keepPrevious()
+	}
+
+	status, err := getNodesStatus(client)
+	if err != nil {
+		ssh.Debug("cannot get list of nodes: %s", err)
+		return keepPrevious()
+	}
+
+	ssh.Debug("%d nodes found in the cluster", len(status))
+	m, err := nodesStatusToMap(status)
+	if err != nil {
+		return err
+	}
+	return d.Set("nodes_status", m)
+}
+
+// cleanupExpiredTokens removes the bootstrap tokens created by the provider
+// (or the provisioner) that have already expired, returning the number of
+// tokens deleted
+func cleanupExpiredTokens(client kubernetes.Interface, now time.Time) (int, error) {
+	selector := fields.SelectorFromSet(fields.Set{"type": string(bootstrapapi.SecretTypeBootstrapToken)})
+	secrets, err := client.CoreV1().Secrets(metav1.NamespaceSystem).List(metav1.ListOptions{
+		FieldSelector: selector.String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, secret := range secrets.Items {
+		if secret.Type != bootstrapapi.SecretTypeBootstrapToken {
+			continue
+		}
+		if string(secret.Data[bootstrapapi.BootstrapTokenDescriptionKey]) != common.TokenDescription {
+			continue
+		}
+
+		expiration := string(secret.Data[bootstrapapi.BootstrapTokenExpirationKey])
+		if len(expiration) == 0 {
+			continue // tokens without an expiration never expire
+		}
+		expires, err := time.Parse(time.RFC3339, expiration)
+		if err != nil {
+			ssh.Debug("could not parse expiration time %q for token %q: %s", expiration, secret.Name, err)
+			continue
+		}
+		if now.Before(expires) {
+			continue
+		}
+
+		ssh.Debug("deleting expired token %q (expired at %s)", secret.Name, expiration)
+		if err := client.CoreV1().Secrets(metav1.NamespaceSystem).Delete(secret.Name, &metav1.DeleteOptions{}); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// refreshJoinToken checks the join token in the cluster, creating a new one when it
+// has expired (or it is about to expire, or it has been removed), so nodes can still
+// join the cluster long after it was created. It returns the new token (or an empty
+// string when the current token is still valid).
+func refreshJoinToken(client kubernetes.Interface, token string, now time.Time) (string, error) {
+	tokenID := strings.Split(token, ".")[0]
+	secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(bootstrapapi.BootstrapTokenSecretPrefix+tokenID, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		ssh.Debug("join token %q not found in the cluster", tokenID)
+	case err != nil:
+		return "", err
+	default:
+		expiration := string(secret.Data[bootstrapapi.BootstrapTokenExpirationKey])
+		if len(expiration) == 0 {
+			return "", nil // tokens without an expiration never expire
+		}
+		expires, err := time.Parse(time.RFC3339, expiration)
+		if err != nil {
+			return "", err
+		}
+		if now.Add(joinTokenRefreshMargin).Before(expires) {
+			return "", nil
+		}
+		ssh.Debug("join token %q expires at %s", tokenID, expiration)
+	}
+
+	newToken, err := common.GetRandomToken()
+	if err != nil {
+		return "", err
+	}
+	t, err := common.NewBootstrapTokenFromSpec(common.BootstrapTokenSpec{Token: newToken, TTL: joinTokenTTL})
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Create(t.ToSecret()); err != nil {
+		return "", err
+	}
+	return newToken, nil
+}
+
+// updateJoinToken replaces the join token in the `config` (or in Vault, when the secrets
+// are stored there) when it has expired.
+// As with the nodes status, failures when accessing the cluster are not considered errors.
+func updateJoinToken(d *schema.ResourceData, meta interface{}) error {
+	config := common.GetProvisionerConfig(d)
+	token, _ := config["token"].(string)
+
+	vault, path, err := getVaultClient(d, meta)
+	if err != nil {
+		return err
+	}
+	var secrets map[string]string
+	if vault != nil {
+		if secrets, err = vault.ReadSecrets(path); err != nil {
+			return err
+		}
+		token = secrets["token"]
+	}
+	if len(token) == 0 {
+		return nil
+	}
+
+	client, err := getKubeClient(d)
+	if err != nil {
+		ssh.Debug("cannot refresh the join token: %s", err)
+		return nil
+	}
+
+	newToken, err := refreshJoinToken(client, token, time.Now())
+	if err != nil {
+		ssh.Debug("cannot refresh the join token: %s", err)
+		return nil
+	}
+	if len(newToken) == 0 {
+		return nil
+	}
+
+	ssh.Debug("the join token has expired: replacing it by a new token")
+	if vault != nil {
+		secrets["token"] = newToken
+		return vault.WriteSecrets(path, secrets)
+	}
+	if err := common.SetTokenInConfig(config, newToken); err != nil {
+		return err
+	}
+	return d.Set("config", config)
+}
+
+// deleteExpiredTokens removes the expired tokens from the cluster.
+// As with the nodes status, failures are not considered errors.
+func deleteExpiredTokens(d *schema.ResourceData) {
+	client, err := getKubeClient(d)
+	if err != nil {
+		ssh.Debug("cannot cleanup expired tokens: %s", err)
+		return
+	}
+
+	deleted, err := cleanupExpiredTokens(client, time.Now())
+	if err != nil {
+		ssh.Debug("error when cleaning up expired tokens: %s", err)
+	}
+	ssh.Debug("%d expired tokens deleted", deleted)
+}
+
+// approveKubeletServingCSRs approves the pending CSRs created by the kubelets for their
+// serving certificates (after checking they only request the names and addresses of the
+// node), returning the number of CSRs approved
+func approveKubeletServingCSRs(client kubernetes.Interface) (int, error) {
+	csrs, err := client.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	approved := 0
+	nodes := map[string]*corev1.Node{}
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		nodename := common.GetCSRNodename(csr)
+		if !common.IsPendingCSR(csr) || len(nodename) == 0 {
+			continue
+		}
+
+		node, ok := nodes[nodename]
+		if !ok {
+			node, err = client.CoreV1().Nodes().Get(nodename, metav1.GetOptions{})
+			if err != nil {
+				ssh.Debug("cannot get node %q for CSR %q: %s", nodename, csr.Name, err)
+				continue
+			}
+			nodes[nodename] = node
+		}
+
+		for _, pending := range common.GetPendingKubeletServingCSRs([]certificatesv1beta1.CertificateSigningRequest{*csr}, node) {
+			pending.Status.Conditions = append(pending.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{
+				Type:    certificatesv1beta1.CertificateApproved,
+				Reason:  "KubeadmProviderApprove",
+				Message: "kubelet serving certificate approved by the kubeadm provider",
+			})
+			ssh.Debug("approving kubelet serving CSR %q for node %q", pending.Name, nodename)
+			if _, err := client.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(&pending); err != nil {
+				return approved, err
+			}
+			approved++
+		}
+	}
+	return approved, nil
+}
+
+// approvePendingServingCSRs approves the kubelet serving certificates requested
+// since the last refresh (ie, after a certificate rotation).
+// As with the nodes status, failures are not considered errors.
+func approvePendingServingCSRs(d *schema.ResourceData) {
+	if !isKubeletServingCertsEnabled(d) {
+		return
+	}
+
+	client, err := getKubeClient(d)
+	if err != nil {
+		ssh.Debug("cannot approve the kubelet serving CSRs: %s", err)
+		return
+	}
+
+	approved, err := approveKubeletServingCSRs(client)
+	if err != nil {
+		ssh.Debug("error when approving the kubelet serving CSRs: %s", err)
+	}
+	ssh.Debug("%d kubelet serving CSRs approved", approved)
+}
@@ -0,0 +1,307 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetNodesStatus(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "worker-0",
+				Labels: map[string]string{},
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+				},
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.15.0"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "master-0",
+				Labels: map[string]string{nodeRoleMasterLabel: ""},
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.15.0"},
+			},
+		},
+	)
+
+	status, err := getNodesStatus(client)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(status) != 2 {
+		t.Fatalf("Error: unexpected number of nodes: %d", len(status))
+	}
+
+	expected := []struct {
+		name  string
+		role  string
+		ready bool
+	}{
+		{"master-0", "master", true},
+		{"worker-0", "worker", false},
+	}
+	for _, e := range expected {
+		s, ok := status[e.name]
+		if !ok {
+			t.Fatalf("Error: node %q not found in %v", e.name, status)
+		}
+		if s["role"] != e.role {
+			t.Fatalf("Error: unexpected role for %q: %v", e.name, s["role"])
+		}
+		if s["ready"] != e.ready {
+			t.Fatalf("Error: unexpected ready status for %q: %v", e.name, s["ready"])
+		}
+		if s["version"] != "v1.15.0" {
+			t.Fatalf("Error: unexpected version for %q: %v", e.name, s["version"])
+		}
+	}
+
+	// the status is stored as a JSON document per node
+	m, err := nodesStatusToMap(status)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(m["master-0"].(string)), &decoded); err != nil {
+		t.Fatalf("Error: could not decode the status of master-0: %s", err)
+	}
+	if decoded["ready"] != true || decoded["role"] != "master" {
+		t.Fatalf("Error: unexpected status for master-0: %v", decoded)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, map[string]interface{}{})
+	if err := d.Set("nodes_status", m); err != nil {
+		t.Fatalf("Error: could not set the nodes status: %s", err)
+	}
+	if d.Get("nodes_status.worker-0").(string) != m["worker-0"] {
+		t.Fatalf("Error: unexpected status for worker-0: %v", d.Get("nodes_status"))
+	}
+}
+
+func TestGetNodeFacts(t *testing.T) {
+	node := corev1.Node{
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				OSImage:                 "Ubuntu 22.04.3 LTS",
+				KernelVersion:           "5.15.0-88-generic",
+				Architecture:            "arm64",
+				ContainerRuntimeVersion: "containerd://1.7.2",
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("3500m"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+	}
+
+	facts := getNodeFacts(node)
+	expected := map[string]interface{}{
+		"distro":            "ubuntu",
+		"release":           "22.04.3",
+		"kernel_version":    "5.15.0-88-generic",
+		"architecture":      "arm64",
+		"container_runtime": "containerd://1.7.2",
+		"cpu":               "3500m",
+		"memory_mb":         8192,
+	}
+	for k, v := range expected {
+		if facts[k] != v {
+			t.Fatalf("Error: unexpected %s: %v (expected %v)", k, facts[k], v)
+		}
+	}
+
+	distros := map[string][2]string{
+		"CentOS Linux 7 (Core)":          {"centos", "7"},
+		"Debian GNU/Linux 12 (bookworm)": {"debian", "12"},
+		"openSUSE Leap 15.5":             {"opensuse-leap", "15.5"},
+		"":                               {"", ""},
+	}
+	for image, e := range distros {
+		if distro, release := getNodeDistro(image); distro != e[0] || release != e[1] {
+			t.Fatalf("Error: unexpected distro/release for %q: %q/%q", image, distro, release)
+		}
+	}
+}
+
+func TestCleanupExpiredTokens(t *testing.T) {
+	now := time.Now()
+	tokenSecret := func(name, description string, expiration time.Time) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: metav1.NamespaceSystem,
+			},
+			Type: bootstrapapi.SecretTypeBootstrapToken,
+			Data: map[string][]byte{
+				bootstrapapi.BootstrapTokenDescriptionKey: []byte(description),
+				bootstrapapi.BootstrapTokenExpirationKey:  []byte(expiration.UTC().Format(time.RFC3339)),
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		tokenSecret("bootstrap-token-aaaaaa", common.TokenDescription, now.Add(-time.Hour)),
+		tokenSecret("bootstrap-token-bbbbbb", common.TokenDescription, now.Add(time.Hour)),
+		tokenSecret("bootstrap-token-cccccc", "created by someone else", now.Add(-time.Hour)),
+	)
+
+	deleted, err := cleanupExpiredTokens(client, now)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("Error: unexpected number of tokens deleted: %d", deleted)
+	}
+
+	secrets, err := client.CoreV1().Secrets(metav1.NamespaceSystem).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	for _, secret := range secrets.Items {
+		if secret.Name == "bootstrap-token-aaaaaa" {
+			t.Fatalf("Error: expired token %q has not been deleted", secret.Name)
+		}
+	}
+	if len(secrets.Items) != 2 {
+		t.Fatalf("Error: unexpected number of remaining tokens: %d", len(secrets.Items))
+	}
+}
+
+func TestRefreshJoinToken(t *testing.T) {
+	now := time.Now()
+	tokenSecret := func(id string, expiration string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      bootstrapapi.BootstrapTokenSecretPrefix + id,
+				Namespace: metav1.NamespaceSystem,
+			},
+			Type: bootstrapapi.SecretTypeBootstrapToken,
+			Data: map[string][]byte{
+				bootstrapapi.BootstrapTokenIDKey:         []byte(id),
+				bootstrapapi.BootstrapTokenExpirationKey: []byte(expiration),
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		tokenSecret("aaaaaa", now.Add(5*time.Hour).UTC().Format(time.RFC3339)),
+		tokenSecret("bbbbbb", now.Add(10*time.Minute).UTC().Format(time.RFC3339)),
+		tokenSecret("cccccc", ""),
+	)
+
+	for _, token := range []string{"aaaaaa.0123456789abcdef", "cccccc.0123456789abcdef"} {
+		newToken, err := refreshJoinToken(client, token, now)
+		if err != nil || len(newToken) > 0 {
+			t.Fatalf("Error: valid token %q should not be replaced: %q (%v)", token, newToken, err)
+		}
+	}
+
+	for _, token := range []string{"bbbbbb.0123456789abcdef", "dddddd.0123456789abcdef"} {
+		newToken, err := refreshJoinToken(client, token, now)
+		if err != nil || len(newToken) == 0 {
+			t.Fatalf("Error: token %q should be replaced: %v", token, err)
+		}
+		tokenID := strings.Split(newToken, ".")[0]
+		secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(bootstrapapi.BootstrapTokenSecretPrefix+tokenID, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error: the new token has not been created in the cluster: %s", err)
+		}
+		if string(secret.Data[bootstrapapi.BootstrapTokenDescriptionKey]) != common.TokenDescription {
+			t.Fatalf("Error: unexpected description in the new token: %+v", secret.Data)
+		}
+	}
+}
+
+func TestApproveKubeletServingCSRs(t *testing.T) {
+	newCSR := func(name string, nodename string, dnsName string) *certificatesv1beta1.CertificateSigningRequest {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: "system:node:" + nodename, Organization: []string{"system:nodes"}},
+			DNSNames: []string{dnsName},
+		}, key)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return &certificatesv1beta1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+				Request:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}),
+				Username: "system:node:" + nodename,
+				Groups:   []string{"system:nodes"},
+				Usages:   common.KubeletServingCSRUsages,
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		newCSR("csr-valid", "worker-0", "worker-0"),
+		newCSR("csr-foreign-name", "worker-0", "master-0"),
+		newCSR("csr-unknown-node", "worker-1", "worker-1"),
+	)
+
+	approved, err := approveKubeletServingCSRs(client)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if approved != 1 {
+		t.Fatalf("Error: %d CSRs approved", approved)
+	}
+
+	csr, err := client.CertificatesV1beta1().CertificateSigningRequests().Get("csr-valid", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if common.IsPendingCSR(csr) {
+		t.Fatalf("Error: CSR has not been approved: %+v", csr.Status)
+	}
+
+	// nothing else to approve
+	if approved, err := approveKubeletServingCSRs(client); err != nil || approved != 0 {
+		t.Fatalf("Error: %d CSRs approved (%v)", approved, err)
+	}
+}
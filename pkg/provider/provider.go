@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -37,6 +38,9 @@ func dataSourceKubeadmCreate(d *schema.ResourceData, meta interface{}) error {
 		if err := createConfigForProvisioner(d); err != nil {
 			return err
 		}
+		if err := storeSecretsInVault(d, meta); err != nil {
+			return err
+		}
 	} else {
 		ssh.Debug("using previous config")
 	}
@@ -50,11 +54,53 @@ func dataSourceKubeadmCreate(d *schema.ResourceData, meta interface{}) error {
 
 // dataSourceKubeadmReads is responsible for reading any resources
 func dataSourceKubeadmRead(d *schema.ResourceData, meta interface{}) error {
+	if err := loadKubeconfig(d); err != nil {
+		return err
+	}
+	if err := setKubeconfigAttributes(d); err != nil {
+		return err
+	}
+	if err := writeKubeconfigFile(d); err != nil {
+		return err
+	}
+	if err := syncKubeconfigWithVault(d, meta); err != nil {
+		return err
+	}
+
+	if err := updateNodesStatus(d); err != nil {
+		return err
+	}
+	if err := updateClusterHealth(d); err != nil {
+		return err
+	}
+	if err := updateEtcdClient(d); err != nil {
+		return err
+	}
+
+	// replace the join token when it has expired, so nodes can join the cluster at any time
+	if err := updateJoinToken(d, meta); err != nil {
+		return err
+	}
+
+	// ... and publish the join command for nodes that are not provisioned by Terraform
+	if err := publishJoinCommand(d, meta); err != nil {
+		return err
+	}
+
+	// keep kube-system tidy, removing the tokens we created and that have expired
+	deleteExpiredTokens(d)
+
+	// the kubelets request new serving certificates when they are about to expire
+	approvePendingServingCSRs(d)
 	return nil
 }
 
 // dataSourceKubeadmDelete is responsible for deleting all the kubeadm resources
 func dataSourceKubeadmDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := removeKubeconfigFile(d.Get("kubeconfig_path").(string), d.Get("kubeconfig_merge").(bool), getClusterName(d)); err != nil {
+		return err
+	}
+
 	kubeconfig, ok := d.GetOk("config_path")
 	if ok {
 		kubeconfigS := kubeconfig.(string)
@@ -69,9 +115,20 @@ func dataSourceKubeadmDelete(d *schema.ResourceData, meta interface{}) error {
 }
 
 // dataSourceKubeadmUpdate is responsible for updating things
+// Only some settings can be updated in place: the `config` is updated, and the
+// provisioners must be run in the "reconfigure" phase for applying it in the nodes.
 func dataSourceKubeadmUpdate(d *schema.ResourceData, meta interface{}) error {
 	// TODO: pass the responsability for creating the new token to the provisioner
-	return nil
+	if d.HasChange("runtime") || d.HasChange("kubelet") || d.HasChange("min_resources") ||
+		d.HasChange("manifests") || d.HasChange("manifests_hashes") || d.HasChange("images") {
+		if err := updateConfigForProvisioner(d); err != nil {
+			return err
+		}
+	}
+	if err := updateKubeconfigFile(d); err != nil {
+		return err
+	}
+	return dataSourceKubeadmRead(d, meta)
 }
 
 // dataSourceKubeadmExists checks if the kubeadm configuration already exists
@@ -100,6 +157,55 @@ func dataSourceKubeadmExists(d *schema.ResourceData, meta interface{}) (bool, er
 	return true, nil
 }
 
+// updateConfigForProvisioner updates the `init` and `join` configurations
+// in the config for the provisioner, keeping the same token and certificates
+func updateConfigForProvisioner(d *schema.ResourceData) error {
+	config := common.GetProvisionerConfig(d)
+	token, _ := config["token"].(string)
+
+	initConfig, err := dataSourceToInitConfig(d, token)
+	if err != nil {
+		return err
+	}
+	joinConfig, err := dataSourceToJoinConfig(d, token)
+	if err != nil {
+		return err
+	}
+
+	initConfigBytes, err := common.InitConfigToYAML(initConfig)
+	if err != nil {
+		return err
+	}
+	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+	if err != nil {
+		return err
+	}
+
+	ssh.Debug("updating the init and join configurations")
+	config["init"] = common.ToTerraformSafeString(initConfigBytes[:])
+	config["join"] = common.ToTerraformSafeString(joinConfigBytes[:])
+	setTimeoutsInConfig(d, config)
+	setMinResourcesInConfig(d, config)
+	if err := setManifestsInConfig(d, config); err != nil {
+		return err
+	}
+	if err := setRenderedConfigs(d, token); err != nil {
+		return err
+	}
+	if err := setConfigChecksum(d); err != nil {
+		return err
+	}
+	return d.Set("config", config)
+}
+
+// setTimeoutsInConfig passes the resource timeouts to the provisioner, where
+// they are used as deadlines for provisioning the nodes
+func setTimeoutsInConfig(d *schema.ResourceData, config map[string]interface{}) {
+	config["timeout_create"] = d.Timeout(schema.TimeoutCreate).String()
+	config["timeout_update"] = d.Timeout(schema.TimeoutUpdate).String()
+	config["timeout_delete"] = d.Timeout(schema.TimeoutDelete).String()
+}
+
 // createConfigForProvisioner computes and sets the config for the provisioner
 func createConfigForProvisioner(d *schema.ResourceData) error {
 	var err error
@@ -111,6 +217,10 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 	}
 	ssh.Debug("kubeadm token = %s", token)
 
+	if err := setBootstrapTokens(d); err != nil {
+		return err
+	}
+
 	ssh.Debug("creating kubeadm configuration for init and join")
 	initConfig, err := dataSourceToInitConfig(d, token)
 	if err != nil {
@@ -153,9 +263,40 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		"config_path":         kubeconfig,
 		"cni_plugin":          d.Get("cni.0.plugin").(string),
 		"cni_plugin_manifest": d.Get("cni.0.plugin_manifest").(string),
-		"helm_enabled":        fmt.Sprintf("%t", d.Get("helm.0.install").(bool)),
+		"helm_enabled":        fmt.Sprintf("%t", isHelmEnabled(d)),
 		"dashboard_enabled":   fmt.Sprintf("%t", d.Get("dashboard.0.install").(bool)),
 		"certs_dir":           initConfig.CertificatesDir,
+		"metrics_exposed":     fmt.Sprintf("%t", d.Get("observability.0.expose_control_plane_metrics").(bool)),
+		"runtime_engine":      getRuntimeEngine(d),
+		"cgroup_driver":       getCgroupDriver(d),
+		"sandbox_image":       getSandboxImage(d),
+		"kubelet_root_dir":    getKubeletRootDir(d),
+		"etcd_mode":           getEtcdMode(d),
+		"etcd_data_dir":       getEtcdDataDir(d),
+		"api_auto_sans":       fmt.Sprintf("%t", isAutoSANsEnabled(d)),
+	}
+
+	if isKubeletServingCertsEnabled(d) {
+		provConfig["kubelet_serving_certs"] = "true"
+	}
+
+	if socket, ok := d.GetOk("runtime.0.cri_socket"); ok && len(socket.(string)) > 0 {
+		provConfig["cri_socket"] = socket.(string)
+	}
+
+	setMinResourcesInConfig(d, provConfig)
+
+	for _, kind := range []string{"root", "state"} {
+		if dir := getContainerdDir(d, kind); len(dir) > 0 {
+			provConfig[fmt.Sprintf("containerd_%s_dir", kind)] = dir
+		}
+	}
+
+	if isKonnectivityEnabled(d) {
+		provConfig["konnectivity_enabled"] = "true"
+		provConfig["konnectivity_server_host"] = getKonnectivityServerHost(d)
+		provConfig["konnectivity_agent_port"] = fmt.Sprintf("%d", d.Get("konnectivity.0.agent_port").(int))
+		provConfig["konnectivity_version"] = d.Get("konnectivity.0.version").(string)
 	}
 
 	if cniConfigDir, ok := d.GetOk("cni.0.conf_dir"); ok {
@@ -182,12 +323,40 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		provConfig["flannel_backend"] = common.DefFlannelBackend
 	}
 
-	if v, ok := d.GetOk("cni.0.flannel.0.version"); ok {
+	cniVersion := getCNIVersion(d)
+	provConfig["cni_version"] = cniVersion
+	if v, ok := d.GetOk("cni.0.mtu"); ok && v.(int) > 0 {
+		provConfig["cni_mtu"] = fmt.Sprintf("%d", v.(int))
+	}
+
+	if v, ok := d.GetOk("cni.0.flannel.0.version"); ok && len(d.Get("cni.0.version").(string)) == 0 {
 		provConfig["flannel_image_version"] = v.(string)
+	} else if d.Get("cni.0.plugin").(string) == "flannel" {
+		provConfig["flannel_image_version"] = cniVersion
 	} else {
 		provConfig["flannel_image_version"] = common.DefFlannelImageVersion
 	}
 
+	if v, ok := d.GetOk("cni.0.calico.0.encapsulation"); ok {
+		provConfig["calico_encapsulation"] = v.(string)
+	} else {
+		provConfig["calico_encapsulation"] = common.DefCalicoEncapsulation
+	}
+
+	if v, ok := d.GetOk("cni.0.calico.0.mtu"); ok && v.(int) > 0 {
+		provConfig["calico_mtu"] = fmt.Sprintf("%d", v.(int))
+	} else if mtu, ok := provConfig["cni_mtu"]; ok {
+		provConfig["calico_mtu"] = mtu
+	}
+
+	if v, ok := d.GetOk("cni.0.cilium.0.tunnel"); ok {
+		provConfig["cilium_tunnel"] = v.(string)
+	} else {
+		provConfig["cilium_tunnel"] = common.DefCiliumTunnel
+	}
+	provConfig["cilium_kube_proxy_replacement"] = fmt.Sprintf("%t", d.Get("cni.0.cilium.0.kube_proxy_replacement").(bool))
+	provConfig["cilium_hubble"] = fmt.Sprintf("%t", d.Get("cni.0.cilium.0.hubble").(bool))
+
 	if v, ok := d.GetOk("network.0.dns.0.upstream"); ok {
 		dnsUp := v.([]interface{})
 		if len(dnsUp) > 0 {
@@ -198,12 +367,25 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 			provConfig["dns_upstream"] = res
 		}
 	}
+	if search := stringsFromResourceData(d, "network.0.dns.0.search"); len(search) > 0 {
+		provConfig["dns_search"] = strings.Join(search, " ")
+	}
+	if zones := getDNSForwardZones(d); len(zones) > 0 {
+		s, err := common.DNSForwardZonesToTerraformSafeString(zones)
+		if err != nil {
+			return err
+		}
+		provConfig["dns_forward_zones"] = s
+	}
 
 	if version, ok := d.GetOk("version"); ok {
 		provConfig["kube_version"] = version.(string)
 	} else {
 		provConfig["kube_version"] = common.DefKubernetesVersion
 	}
+	if common.NeedsCriDockerd(getRuntimeEngine(d), provConfig["kube_version"].(string)) {
+		provConfig["cri_dockerd"] = "true"
+	}
 
 	if cloudProviderRaw, ok := d.GetOk("cloud.0.provider"); ok && len(cloudProviderRaw.(string)) > 0 {
 		cloudProvider := cloudProviderRaw.(string)
@@ -216,10 +398,147 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		}
 
 		// ... and maybe if we have some cloud-provider config file
-		if cloudConfigRaw, ok := d.GetOk("cloud.0.config"); ok && len(cloudConfigRaw.(string)) > 0 {
-			cloudConfig := cloudConfigRaw.(string)
+		cloudConfig, err := getCloudConfig(d)
+		if err != nil {
+			return err
+		}
+		if len(cloudConfig) > 0 {
 			provConfig["cloud_config"] = common.ToTerraformSafeString([]byte(cloudConfig))
+			provConfig["cloud_config_path"] = getCloudConfigPath(d)
+		}
+
+		if version := getCloudManagerVersion(d); len(version) > 0 {
+			provConfig["cloud_manager_version"] = version
+		}
+
+		if csiConfig := getVSphereCSIConfig(d); len(csiConfig) > 0 {
+			provConfig["vsphere_csi_config"] = common.ToTerraformSafeString([]byte(csiConfig))
+		}
+	}
+
+	if path := getSchedulerConfigPath(d); len(path) > 0 {
+		provConfig["scheduler_config"] = common.ToTerraformSafeString([]byte(d.Get("scheduler.0.config").(string)))
+		provConfig["scheduler_config_path"] = path
+	}
+
+	if policy := getAuditPolicy(d); len(policy) > 0 {
+		provConfig["audit_policy"] = common.ToTerraformSafeString([]byte(policy))
+		if webhookConfig := getAuditWebhookKubeconfig(d); len(webhookConfig) > 0 {
+			provConfig["audit_webhook_config"] = common.ToTerraformSafeString([]byte(webhookConfig))
+		}
+	}
+
+	rbacManifest, err := getRBACManifest(d)
+	if err != nil {
+		return err
+	}
+	if len(rbacManifest) > 0 {
+		provConfig["rbac_manifest"] = common.ToTerraformSafeString([]byte(rbacManifest))
+	}
+
+	patchFiles, err := getStaticPodPatchFiles(d)
+	if err != nil {
+		return err
+	}
+	if len(patchFiles) > 0 {
+		s, err := common.PatchFilesToTerraformSafeString(patchFiles)
+		if err != nil {
+			return err
+		}
+		provConfig["static_pod_patches"] = s
+	}
+
+	if err := setManifestsInConfig(d, provConfig); err != nil {
+		return err
+	}
+
+	admissionConfig, err := getAdmissionConfig(d)
+	if err != nil {
+		return err
+	}
+	if len(admissionConfig) > 0 {
+		provConfig["admission_config"] = common.ToTerraformSafeString([]byte(admissionConfig))
+		provConfig["admission_config_path"] = getAdmissionConfigPath(d)
+	}
+
+	if _, ok := d.GetOk("proxy"); ok {
+		provConfig["proxy_http"] = d.Get("proxy.0.http").(string)
+		provConfig["proxy_https"] = d.Get("proxy.0.https").(string)
+		provConfig["proxy_no_proxy"] = strings.Join(getNoProxy(d), ",")
+	}
+
+	if _, ok := d.GetOk("addons.0.metrics_server"); ok {
+		provConfig["metrics_server_enabled"] = fmt.Sprintf("%t", d.Get("addons.0.metrics_server.0.install").(bool))
+		// (the kubelet certificates can be verified when they are signed by the cluster CA)
+		insecureTLS := d.Get("addons.0.metrics_server.0.kubelet_insecure_tls").(bool) && !isKubeletServingCertsEnabled(d)
+		provConfig["metrics_server_insecure_tls"] = fmt.Sprintf("%t", insecureTLS)
+	}
+
+	if vip, ok := d.GetOk("api.0.vip.0.address"); ok {
+		provConfig["vip_address"] = vip.(string)
+		provConfig["vip_interface"] = d.Get("api.0.vip.0.interface").(string)
+		provConfig["vip_version"] = d.Get("api.0.vip.0.version").(string)
+	}
+
+	if nodeLocalDNS := getNodeLocalDNS(d); len(nodeLocalDNS) > 0 {
+		services, domain := common.DefServiceCIDR, common.DefDNSDomain
+		if s, ok := d.GetOk("network.0.services"); ok && len(s.(string)) > 0 {
+			services = s.(string)
+		}
+		if s, ok := d.GetOk("network.0.dns.0.domain"); ok && len(s.(string)) > 0 {
+			domain = s.(string)
+		}
+		clusterDNS, err := common.GetClusterDNSIP(services)
+		if err != nil {
+			return err
 		}
+		provConfig["node_local_dns_ip"] = nodeLocalDNS
+		provConfig["cluster_dns_ip"] = clusterDNS
+		provConfig["dns_domain"] = domain
+	}
+
+	storageProvisioner, err := getStorageProvisioner(d)
+	if err != nil {
+		return err
+	}
+	if storageProvisioner == "local-path" {
+		provConfig["local_path_enabled"] = "true"
+		provConfig["local_path_default"] = fmt.Sprintf("%t", d.Get("addons.0.storage.0.default").(bool))
+	}
+
+	metalLBManifest, err := getMetalLBManifest(d)
+	if err != nil {
+		return err
+	}
+	if len(metalLBManifest) > 0 {
+		provConfig["metallb_manifest"] = common.ToTerraformSafeString([]byte(metalLBManifest))
+	}
+
+	issuerManifest, err := getCertManagerIssuerManifest(d)
+	if err != nil {
+		return err
+	}
+	if len(issuerManifest) > 0 {
+		provConfig["cert_manager_issuer"] = common.ToTerraformSafeString([]byte(issuerManifest))
+	}
+
+	if isHelmEnabled(d) {
+		provConfig["helm_version"] = d.Get("helm.0.version").(string)
+	}
+	if charts := getHelmCharts(d); len(charts) > 0 {
+		s, err := common.HelmChartsToTerraformSafeString(charts)
+		if err != nil {
+			return err
+		}
+		provConfig["helm_charts"] = s
+	}
+
+	if registries := getRegistries(d); len(registries) > 0 {
+		s, err := common.RegistriesToTerraformSafeString(registries)
+		if err != nil {
+			return err
+		}
+		provConfig["registries"] = s
 	}
 
 	// create all the certs and set them in some `d.config` fields, so the provisioner
@@ -232,10 +551,42 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		provConfig[k] = v
 	}
 
+	// the key used for sharing the control plane certs with nodes joined out-of-band
+	certificateKey, err := common.GetRandomCertificateKey()
+	if err != nil {
+		return err
+	}
+	provConfig["certificate_key"] = certificateKey
+
+	setTimeoutsInConfig(d, provConfig)
+
+	if err := setRenderedConfigs(d, token); err != nil {
+		return err
+	}
+
+	if err := setKubeconfig(d, initConfig, certConfig); err != nil {
+		return err
+	}
+
 	if err = d.Set("config", provConfig); err != nil {
 		return err
 	}
 
+	cniManifestHash, err := getCNIManifestHash(d)
+	if err != nil {
+		return err
+	}
+	if err = d.Set("cni_manifest_hash", cniManifestHash); err != nil {
+		return err
+	}
+
+	if err = setConfigChecksum(d); err != nil {
+		return err
+	}
+
+	// make sure the secrets are not printed in the debug messages
+	common.RegisterSecrets(provConfig)
+
 	ssh.Debug("-------------------------------------------------------------------------")
 	ssh.Debug("'data.config' after configuration:")
 	ssh.Debug("%s", spew.Sdump(provConfig))
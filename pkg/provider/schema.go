@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/hashicorp/terraform/helper/customdiff"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
@@ -30,9 +31,37 @@ func dataSourceKubeadm() *schema.Resource {
 		Create: dataSourceKubeadmCreate,
 		Read:   dataSourceKubeadmRead,
 		Delete: dataSourceKubeadmDelete,
-		//Update: dataSourceKubeadmUpdate,
+		Update: dataSourceKubeadmUpdate,
 		Exists: dataSourceKubeadmExists,
 
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(common.DefTimeoutCreate),
+			Update: schema.DefaultTimeout(common.DefTimeoutUpdate),
+			Delete: schema.DefaultTimeout(common.DefTimeoutDelete),
+		},
+
+		CustomizeDiff: customdiff.All(
+			customizeDiffVersion,
+			customizeDiffCNIManifest,
+			customizeDiffRuntime,
+			customizeDiffEtcd,
+			customizeDiffDataPaths,
+			customizeDiffDNS,
+			customizeDiffMinResources,
+			customizeDiffKubeletGC,
+			customizeDiffKubelet,
+			customizeDiffControllerManager,
+			customizeDiffPodSecurity,
+			customizeDiffAudit,
+			customizeDiffKonnectivity,
+			customizeDiffRBAC,
+			customizeDiffManifests,
+			customizeDiffStaticPodPatches,
+			customizeDiffRenderedConfigs,
+			customizeDiffJoinPublish,
+			customizeDiffConfigChecksum,
+		),
+
 		Schema: map[string]*schema.Schema{
 			"config_path": {
 				Type:        schema.TypeString,
@@ -40,6 +69,78 @@ func dataSourceKubeadm() *schema.Resource {
 				ForceNew:    true,
 				Description: "A local copy of the kubeconfig",
 			},
+			"kubeconfig": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "kubeconfig for the admin",
+			},
+			"cluster_name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      common.DefClusterName,
+				Description:  "name of the cluster, used in the kubeadm configuration and in the kubeconfig_path",
+				ValidateFunc: common.ValidateDNSName,
+			},
+			"kubeconfig_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "local path where the admin kubeconfig is written (ie, ~/.kube/config)",
+			},
+			"kubeconfig_merge": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "merge the cluster as an additional context in the kubeconfig_path, instead of overwriting it",
+			},
+			"api_endpoint": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "URL of the API server",
+			},
+			"cluster_ca_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM-encoded CA certificate of the cluster",
+			},
+			"client_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM-encoded client certificate for the admin",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM-encoded client key for the admin",
+			},
+			"cni_manifest_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Hash of the CNI manifest applied in the cluster",
+			},
+			"manifests_hashes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Hashes of the manifests applied in the cluster",
+			},
+			"rendered_init_config": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The kubeadm init configuration (in YAML), with the bootstrap token redacted",
+			},
+			"rendered_join_config": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The kubeadm join configuration (in YAML), with the bootstrap token redacted",
+			},
+			"config_checksum": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Checksum of the effective cluster configuration, for triggering changes in dependent resources",
+			},
 			"api": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -51,13 +152,13 @@ func dataSourceKubeadm() *schema.Resource {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Description:  "stable IP/DNS (and port) for the control plane (for example, the load balancer)",
-							ValidateFunc: common.ValidateDNSNameOrIP,
+							ValidateFunc: common.ValidateHostOptionalPort,
 						},
 						"internal": {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Description:  "IP/DNS and port the local API server advertises it's accessible",
-							ValidateFunc: common.ValidateDNSNameOrIP,
+							ValidateFunc: common.ValidateHostOptionalPort,
 						},
 						"alt_names": {
 							Type:        schema.TypeList,
@@ -65,6 +166,40 @@ func dataSourceKubeadm() *schema.Resource {
 							Optional:    true,
 							Description: "List of SANs to use in api-server certificate. Example: 'IP=127.0.0.1,IP=127.0.0.2,DNS=localhost', If empty, SANs will be obtained from the external and internal names/IPs",
 						},
+						"auto_sans": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "add the external host, the control plane nodes addresses and the API service FQDN to the api-server certificate",
+						},
+						"vip": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							MaxItems:    1,
+							Description: "floating VIP for the control plane, managed with kube-vip",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"address": {
+										Type:         schema.TypeString,
+										Required:     true,
+										Description:  "virtual IP address",
+										ValidateFunc: validation.SingleIP(),
+									},
+									"interface": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "network interface for the VIP (detected from the routes by default)",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefKubeVipVersion,
+										Description:  "version of kube-vip",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -79,7 +214,328 @@ func dataSourceKubeadm() *schema.Resource {
 							Type:        schema.TypeBool,
 							Default:     false,
 							Optional:    true,
-							Description: "install Helm",
+							Description: "install Helm in the first master",
+						},
+						"version": {
+							Type:         schema.TypeString,
+							Default:      common.DefHelmVersion,
+							Optional:     true,
+							Description:  "Helm version",
+							ValidateFunc: common.ValidateVersion,
+						},
+						"chart": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "charts installed after creating the cluster",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "name of the release",
+									},
+									"chart": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "chart to install",
+									},
+									"repo": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "URL of the repository for the chart",
+									},
+									"version": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "version of the chart (default: the latest version)",
+									},
+									"namespace": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     common.DefHelmNamespace,
+										Description: "namespace for the release",
+									},
+									"values": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Sensitive:   true,
+										Description: "values for the chart, in YAML",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"addons": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"metrics_server": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy the metrics-server",
+									},
+									"kubelet_insecure_tls": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "do not verify the (self-signed) certificates of the kubelets",
+									},
+								},
+							},
+						},
+						"ingress": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy the NGINX ingress controller",
+									},
+									"class": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "nginx",
+										Description: "name of the ingress class",
+									},
+									"service_type": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "NodePort",
+										Description:  "how the controller is exposed: NodePort, LoadBalancer or HostNetwork",
+										ValidateFunc: validation.StringInSlice(common.DefIngressServiceTypes, false),
+									},
+									"replicas": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Default:      1,
+										Description:  "number of replicas of the controller (ignored with HostNetwork)",
+										ValidateFunc: validation.IntAtLeast(1),
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefIngressNginxChartVersion,
+										Description:  "version of the ingress-nginx chart",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
+						},
+						"storage": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy a dynamic storage provisioner",
+									},
+									"provisioner": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "local-path",
+										Description:  "storage provisioner: local-path or nfs",
+										ValidateFunc: validation.StringInSlice([]string{"local-path", "nfs"}, false),
+									},
+									"nfs_server": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "NFS server (for the nfs provisioner)",
+										ValidateFunc: common.ValidateDNSNameOrIP,
+									},
+									"nfs_path": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "path exported by the NFS server (for the nfs provisioner)",
+										ValidateFunc: common.ValidateAbsPath,
+									},
+									"default": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "mark the StorageClass as the default one",
+									},
+								},
+							},
+						},
+						"node_local_dns": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy NodeLocal DNSCache",
+									},
+									"ip": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefNodeLocalDNSIP,
+										Description:  "link-local address for NodeLocal DNSCache",
+										ValidateFunc: validation.SingleIP(),
+									},
+								},
+							},
+						},
+						"cert_manager": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy cert-manager",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCertManagerChartVersion,
+										Description:  "version of the cert-manager chart",
+										ValidateFunc: common.ValidateVersion,
+									},
+									"issuer": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										MaxItems:    1,
+										Description: "ClusterIssuer created after installing cert-manager",
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"name": {
+													Type:        schema.TypeString,
+													Optional:    true,
+													Default:     "default",
+													Description: "name of the ClusterIssuer",
+												},
+												"type": {
+													Type:         schema.TypeString,
+													Required:     true,
+													Description:  "type of issuer: selfsigned, ca or acme",
+													ValidateFunc: validation.StringInSlice([]string{"selfsigned", "ca", "acme"}, false),
+												},
+												"ca_cert": {
+													Type:        schema.TypeString,
+													Optional:    true,
+													Description: "CA certificate (PEM) for the ca issuer",
+												},
+												"ca_key": {
+													Type:        schema.TypeString,
+													Optional:    true,
+													Sensitive:   true,
+													Description: "CA private key (PEM) for the ca issuer",
+												},
+												"acme_email": {
+													Type:        schema.TypeString,
+													Optional:    true,
+													Description: "email used for the ACME registration",
+												},
+												"acme_server": {
+													Type:         schema.TypeString,
+													Optional:     true,
+													Default:      common.DefACMEServer,
+													Description:  "ACME server",
+													ValidateFunc: common.ValidateURL,
+												},
+												"ingress_class": {
+													Type:        schema.TypeString,
+													Optional:    true,
+													Default:     "nginx",
+													Description: "ingress class used for solving the ACME HTTP-01 challenges",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						"metallb": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy MetalLB",
+									},
+									"addresses": {
+										Type:        schema.TypeList,
+										Required:    true,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Description: "addresses pool for the LoadBalancer services (CIDRs or ranges, ie, 192.168.1.240-192.168.1.250)",
+									},
+									"mode": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "l2",
+										Description:  "mode for announcing the addresses: l2 or bgp",
+										ValidateFunc: validation.StringInSlice([]string{"l2", "bgp"}, false),
+									},
+									"bgp_peer": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "BGP peers (in bgp mode)",
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"address": {
+													Type:        schema.TypeString,
+													Required:    true,
+													Description: "address of the peer",
+												},
+												"asn": {
+													Type:        schema.TypeInt,
+													Required:    true,
+													Description: "AS number of the peer",
+												},
+												"my_asn": {
+													Type:        schema.TypeInt,
+													Required:    true,
+													Description: "AS number for the nodes",
+												},
+											},
+										},
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefMetalLBChartVersion,
+										Description:  "version of the MetalLB chart",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
 						},
 					},
 				},
@@ -111,7 +567,7 @@ func dataSourceKubeadm() *schema.Resource {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Default:      "",
-							Description:  "CNI plugin to install. Currently supported: flannel",
+							Description:  "CNI plugin to install. Currently supported: flannel, weave, calico and cilium",
 							ValidateFunc: validation.StringInSlice(common.CNIPluginsList, true),
 						},
 						"plugin_manifest": {
@@ -120,6 +576,19 @@ func dataSourceKubeadm() *schema.Resource {
 							Default:     "",
 							Description: "Use a specific manifest for the CNI driver instead of the pre-defined manifests",
 						},
+						"version": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "Version of the pre-defined CNI plugin to deploy",
+							ValidateFunc: common.ValidateVersion,
+						},
+						"mtu": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      0,
+							Description:  "MTU for the pods network (auto-detected by default)",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
 						"bin_dir": {
 							Type:         schema.TypeString,
 							Optional:     true,
@@ -150,12 +619,11 @@ func dataSourceKubeadm() *schema.Resource {
 										ValidateFunc: validation.StringInSlice([]string{"vxlan", "host-gw", "udp", "ali-vpc", "aws-vpc", "gce", "ipip", "ipsec"}, true),
 									},
 									"version": {
-										Type:     schema.TypeString,
-										Optional: true,
-										Default:  common.DefFlannelBackend,
-										// see https://github.com/coreos/flannel/blob/master/Documentation/backends.md
-										Description:  "Flannel backend: vxlan, host-gw, udp, ali-vpc, aws-vpc, gce, ipip, ipsec",
-										ValidateFunc: validation.StringInSlice([]string{"vxlan", "host-gw", "udp", "ali-vpc", "aws-vpc", "gce", "ipip", "ipsec"}, true),
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefFlannelImageVersion,
+										Description:  "Flannel image version (deprecated: use the cni version)",
+										ValidateFunc: common.ValidateVersion,
 									},
 								},
 							},
@@ -171,6 +639,59 @@ func dataSourceKubeadm() *schema.Resource {
 								},
 							},
 						},
+						"calico": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"mtu": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Default:      0,
+										Description:  "MTU for the pods interfaces and tunnels (default: the cni mtu)",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+									"encapsulation": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCalicoEncapsulation,
+										Description:  "Encapsulation for the IP pool: IPIP, IPIPCrossSubnet, VXLAN, VXLANCrossSubnet or None",
+										ValidateFunc: validation.StringInSlice(common.DefCalicoEncapsulations, false),
+									},
+								},
+							},
+						},
+						"cilium": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"kube_proxy_replacement": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "Replace kube-proxy with Cilium (kube-proxy will not be deployed)",
+									},
+									"tunnel": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCiliumTunnel,
+										Description:  "Tunnel mode: vxlan, geneve or disabled (for native routing)",
+										ValidateFunc: validation.StringInSlice(common.DefCiliumTunnels, false),
+									},
+									"hubble": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "Enable Hubble (and the Hubble relay)",
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -213,7 +734,44 @@ func dataSourceKubeadm() *schema.Resource {
 										Type:        schema.TypeList,
 										Optional:    true,
 										Description: "upstream DNS servers",
-										Elem:        &schema.Schema{Type: schema.TypeString},
+										Elem: &schema.Schema{
+											Type:         schema.TypeString,
+											ValidateFunc: validation.SingleIP(),
+										},
+									},
+									"search": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "search domains for the nodes (and the pods) when using the upstream DNS servers",
+										Elem: &schema.Schema{
+											Type:         schema.TypeString,
+											ValidateFunc: common.ValidateDNSName,
+										},
+									},
+									"forward_zone": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "DNS zones resolved by some specific servers in CoreDNS",
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"zone": {
+													Type:         schema.TypeString,
+													Required:     true,
+													Description:  "DNS zone (ie, corp.example.com)",
+													ValidateFunc: common.ValidateDNSName,
+												},
+												"servers": {
+													Type:        schema.TypeList,
+													Required:    true,
+													MinItems:    1,
+													Description: "DNS servers for this zone (ie, 10.0.0.53 or 10.0.0.53:5353)",
+													Elem: &schema.Schema{
+														Type:         schema.TypeString,
+														ValidateFunc: common.ValidateIPOptionalPort,
+													},
+												},
+											},
+										},
 									},
 								},
 							},
@@ -224,50 +782,668 @@ func dataSourceKubeadm() *schema.Resource {
 			"images": {
 				Type:     schema.TypeList,
 				Optional: true,
-				ForceNew: true,
 				MaxItems: 1,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"kube_repo": {
 							Type:        schema.TypeString,
 							Optional:    true,
+							ForceNew:    true,
 							Description: "the kubernetes images repository",
 						},
 						"etcd_repo": {
 							Type:        schema.TypeString,
 							Optional:    true,
+							ForceNew:    true,
 							Description: "the etcd image repository",
 						},
 						"etcd_version": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "the etcd version",
+							ValidateFunc: common.ValidateVersion,
+						},
+						"coredns_repo": {
 							Type:        schema.TypeString,
 							Optional:    true,
-							Description: "the etcd version",
+							Description: "the CoreDNS image repository (updated in place)",
+						},
+						"coredns_version": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "the CoreDNS version (updated in place)",
+							ValidateFunc: common.ValidateVersion,
 						},
 					},
 				},
 			},
-			"etcd": {
+			"controller_manager": {
 				Type:     schema.TypeList,
 				Optional: true,
 				ForceNew: true,
 				MaxItems: 1,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"endpoints": {
-							Type:        schema.TypeList,
-							Elem:        &schema.Schema{Type: schema.TypeString},
+						"node_cidr_mask_size": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "mask size for the CIDRs allocated to the nodes from the pods CIDR (default: 24)",
+							ValidateFunc: validation.IntBetween(1, 32),
+						},
+						"allocate_node_cidrs": {
+							Type:        schema.TypeBool,
 							Optional:    true,
-							Description: "list of etcd servers URLs including host:port",
+							Default:     true,
+							Description: "allocate CIDRs for the nodes from the pods CIDR",
 						},
-					},
-				},
-			},
+						"cluster_signing_cert_file": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "certificate used for signing the cluster-scoped certificates, in the control plane nodes (default: the cluster CA)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"cluster_signing_key_file": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "key used for signing the cluster-scoped certificates, in the control plane nodes (default: the cluster CA key)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"terminated_pod_gc_threshold": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "number of terminated pods that can exist before they are garbage collected (default: 12500)",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+					},
+				},
+			},
+			"etcd": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "etcd topology: stacked or external (default: external when endpoints are provided)",
+							ValidateFunc: validation.StringInSlice([]string{common.EtcdModeStacked, common.EtcdModeExternal}, false),
+						},
+						"endpoints": {
+							Type: schema.TypeList,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: common.ValidateURL,
+							},
+							Optional:      true,
+							ConflictsWith: []string{"etcd.0.local"},
+							Description:   "list of etcd servers URLs including host:port",
+						},
+						"local": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"etcd.0.endpoints"},
+							Description:   "settings for the local etcd running in the control plane nodes",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"data_dir": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "directory where etcd stores its data (default: /var/lib/etcd)",
+										ValidateFunc: common.ValidateAbsPath,
+									},
+									"extra_args": {
+										Type:        schema.TypeMap,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Optional:    true,
+										Description: "Map of extra flags for running etcd (ie, quota-backend-bytes, heartbeat-interval or election-timeout)",
+									},
+									"server_cert_sans": {
+										Type:        schema.TypeList,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Optional:    true,
+										Description: "extra SANs for the etcd server certificate",
+									},
+									"peer_cert_sans": {
+										Type:        schema.TypeList,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Optional:    true,
+										Description: "extra SANs for the etcd peer certificate",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"etcd_client_cert": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "create a client certificate for etcd (ie, for monitoring or backups), exposed in `etcd_client`",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"common_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefEtcdClientCommonName,
+							Description: "common name of the client certificate",
+						},
+					},
+				},
+			},
+			"konnectivity": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"server_host": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "host the konnectivity agents connect to (default: the external API host, or the first control plane node)",
+						},
+						"agent_port": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      common.DefKonnectivityAgentPort,
+							Description:  "port where the konnectivity-server listens for the agents",
+							ValidateFunc: validation.IntBetween(1, 65535),
+						},
+						"version": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefKonnectivityVersion,
+							Description:  "version of the konnectivity-server and konnectivity-agent",
+							ValidateFunc: common.ValidateVersion,
+						},
+					},
+				},
+			},
+			"min_resources": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"master": minResourcesSchema("master"),
+						"worker": minResourcesSchema("worker"),
+					},
+				},
+			},
+			"kubelet": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "kubelet settings (they can be updated in place, except the root_dir and serving_certs: see the 'reconfigure' phase in the provisioner)",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"serving_certs": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							ForceNew:    true,
+							Default:     false,
+							Description: "the kubelets use serving certificates signed by the cluster CA (instead of self-signed ones), approving their CSRs automatically",
+						},
+						"root_dir": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "directory for the kubelet data (volumes, pods, plugins...), for example in a dedicated disk (default: " + common.DefKubeletRootDir + ")",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"eviction_hard": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "hard eviction thresholds, as a map of signals to quantities or percentages (ie, `nodefs.available = \"10%\"`)",
+						},
+						"eviction_soft": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "soft eviction thresholds, as a map of signals to quantities or percentages",
+						},
+						"eviction_soft_grace_period": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "grace periods for the soft eviction thresholds, as a map of signals to durations (ie, `nodefs.available = \"1m30s\"`)",
+						},
+						"image_gc_high_threshold": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "percent of disk usage that triggers the images garbage collection (default: 85)",
+							ValidateFunc: validation.IntBetween(1, 100),
+						},
+						"image_gc_low_threshold": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "percent of disk usage the images garbage collection tries to free to (default: 80)",
+							ValidateFunc: validation.IntBetween(1, 100),
+						},
+						"container_log_max_size": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum size of a container log file before it is rotated (ie, `50Mi`) (default: 10Mi)",
+							ValidateFunc: common.ValidateQuantity,
+						},
+						"container_log_max_files": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "maximum number of log files for a container (default: 5)",
+							ValidateFunc: validation.IntAtLeast(2),
+						},
+					},
+				},
+			},
+			"security": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"pod_security": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"enforce": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "baseline",
+										Description:  "level enforced for the namespaces without a pod-security.kubernetes.io/enforce label",
+										ValidateFunc: validation.StringInSlice(common.PodSecurityLevels, false),
+									},
+									"audit": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "restricted",
+										Description:  "level audited for the namespaces without a pod-security.kubernetes.io/audit label",
+										ValidateFunc: validation.StringInSlice(common.PodSecurityLevels, false),
+									},
+									"warn": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "restricted",
+										Description:  "level that produces warnings for the namespaces without a pod-security.kubernetes.io/warn label",
+										ValidateFunc: validation.StringInSlice(common.PodSecurityLevels, false),
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefPodSecurityVersion,
+										Description:  "version of the Pod Security Standards (\"latest\" or a Kubernetes minor version like \"v1.25\")",
+										ValidateFunc: validatePodSecurityVersion,
+									},
+									"exempt_namespaces": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "namespaces where the Pod Security Standards are not applied (default: kube-system)",
+										Elem: &schema.Schema{
+											Type: schema.TypeString,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"rbac": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cluster_role": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "ClusterRoles created after the initialization of the cluster",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "name of the ClusterRole",
+									},
+									"rule": {
+										Type:        schema.TypeList,
+										Required:    true,
+										Description: "rules of the ClusterRole",
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"api_groups": {
+													Type:        schema.TypeList,
+													Optional:    true,
+													Description: "API groups of the resources (default: the core API group)",
+													Elem: &schema.Schema{
+														Type: schema.TypeString,
+													},
+												},
+												"resources": {
+													Type:        schema.TypeList,
+													Optional:    true,
+													Description: "resources (ie, pods or deployments/scale)",
+													Elem: &schema.Schema{
+														Type: schema.TypeString,
+													},
+												},
+												"resource_names": {
+													Type:        schema.TypeList,
+													Optional:    true,
+													Description: "names of the resources (default: all the resources)",
+													Elem: &schema.Schema{
+														Type: schema.TypeString,
+													},
+												},
+												"non_resource_urls": {
+													Type:        schema.TypeList,
+													Optional:    true,
+													Description: "non-resource URLs (ie, /healthz)",
+													Elem: &schema.Schema{
+														Type: schema.TypeString,
+													},
+												},
+												"verbs": {
+													Type:        schema.TypeList,
+													Required:    true,
+													Description: "verbs (ie, get, list or watch)",
+													Elem: &schema.Schema{
+														Type: schema.TypeString,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						"binding": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "bindings of ClusterRoles to users, groups or service accounts created after the initialization of the cluster",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "name of the binding",
+									},
+									"namespace": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "namespace for a RoleBinding (default: a ClusterRoleBinding)",
+									},
+									"cluster_role": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "the ClusterRole bound",
+									},
+									"users": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "users (ie, the users in the OIDC tokens)",
+										Elem: &schema.Schema{
+											Type: schema.TypeString,
+										},
+									},
+									"groups": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "groups (ie, the groups in the OIDC tokens)",
+										Elem: &schema.Schema{
+											Type: schema.TypeString,
+										},
+									},
+									"service_accounts": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "service accounts, as <namespace>/<name>",
+										Elem: &schema.Schema{
+											Type:         schema.TypeString,
+											ValidateFunc: common.ValidateServiceAccountRef,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"manifests": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "list of manifests (URLs, local files or inline YAML) applied after creating the cluster",
+			},
+			"static_pod_patch": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "modifications in the control plane static pods, applied as kubeadm patches",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"target": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "static pod to patch: etcd, kube-apiserver, kube-controller-manager or kube-scheduler",
+							ValidateFunc: validation.StringInSlice(common.PatchTargets, false),
+						},
+						"requests": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "resource requests for the container (ie, cpu = \"500m\")",
+						},
+						"limits": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "resource limits for the container (ie, memory = \"2Gi\")",
+						},
+						"env": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "additional environment variables for the container",
+						},
+						"priority_class_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "priority class for the pod",
+						},
+						"patch": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "a raw patch for the pod (in YAML or JSON)",
+						},
+						"type": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefPatchType,
+							Description:  "type of the raw patch: strategic, merge or json",
+							ValidateFunc: validation.StringInSlice(common.PatchTypes, false),
+						},
+					},
+				},
+			},
+			"scheduler": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"config": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "a KubeSchedulerConfiguration (in YAML) for the scheduler (ie, profiles, plugin weights, bind timeout)",
+							ValidateFunc: validateSchedulerConfig,
+						},
+						"config_path": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  fmt.Sprintf("path where the scheduler config is uploaded in the control plane nodes (default: %s)", common.DefSchedulerConfigPath),
+							ValidateFunc: common.ValidateAbsPath,
+						},
+					},
+				},
+			},
 			"version": {
-				Type:        schema.TypeString,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      common.DefKubernetesVersion,
+				ForceNew:     true,
+				Description:  "Kubernetes version to use (Example: v1.15.0).",
+				ValidateFunc: common.ValidateVersion,
+			},
+			"audit": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"policy": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "the audit Policy (in YAML) (default: the metadata of all the requests)",
+							ValidateFunc: validateAuditPolicy,
+						},
+						"log": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"path": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefAuditLogPath,
+										Description:  "path of the audit log in the control plane nodes",
+										ValidateFunc: common.ValidateAbsPath,
+									},
+									"max_age": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "maximum number of days for keeping the old audit logs",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+									"max_backups": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "maximum number of old audit logs kept",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+									"max_size": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "maximum size (in megabytes) of the audit log before it is rotated",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+								},
+							},
+						},
+						"webhook": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"kubeconfig": {
+										Type:         schema.TypeString,
+										Required:     true,
+										Sensitive:    true,
+										Description:  "kubeconfig with the address (and credentials) of the audit webhook",
+										ValidateFunc: validateAuditWebhookKubeconfig,
+									},
+									"mode": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "batch",
+										Description:  "mode for sending the events: batch, blocking or blocking-strict",
+										ValidateFunc: validation.StringInSlice(common.AuditWebhookModes, false),
+									},
+									"batch_max_size": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "maximum number of events in a batch",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+									"batch_max_wait": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "maximum time for waiting before sending a batch (ie, 30s)",
+										ValidateFunc: common.ValidateDuration,
+									},
+									"initial_backoff": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "time to wait before retrying the first failed request (ie, 10s)",
+										ValidateFunc: common.ValidateDuration,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"bootstrap_tokens": {
+				Type:        schema.TypeList,
 				Optional:    true,
-				Default:     common.DefKubernetesVersion,
 				ForceNew:    true,
-				Description: "Kubernetes version to use (Example: v1.15.0).",
+				Description: "additional bootstrap tokens created in the cluster",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"token": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							Sensitive:    true,
+							Description:  "the token, with the <id>.<secret> format (default: a random token)",
+							ValidateFunc: common.ValidateBootstrapToken,
+						},
+						"description": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.TokenDescription,
+							Description: "description of the token",
+						},
+						"ttl": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "24h",
+							Description:  "time to live for the token ('0' for a token that never expires)",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"usages": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "usages of the token (default: signing and authentication)",
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(common.BootstrapTokenUsages, false),
+							},
+						},
+						"groups": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "extra groups the token authenticates as (default: the kubeadm nodes group)",
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: common.ValidateBootstrapTokenGroup,
+							},
+						},
+					},
+				},
 			},
 			"cloud": {
 				Type:     schema.TypeList,
@@ -282,15 +1458,302 @@ func dataSourceKubeadm() *schema.Resource {
 							Description:  fmt.Sprintf("cloud provider name: %s", strings.Join(common.DefSupportedCloudProviders, ",")),
 							ValidateFunc: validation.StringInSlice(common.DefSupportedCloudProviders, true),
 						},
-						"config": {
-							Type:        schema.TypeString,
-							Optional:    true,
-							Description: fmt.Sprintf("cloud provider configuration (mandatory for: %s)", strings.Join(common.DefCloudConfigMandatory, ",")),
+						"config": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: fmt.Sprintf("cloud provider configuration (mandatory for: %s)", strings.Join(common.DefCloudConfigMandatory, ",")),
+							Sensitive:   true,
+						},
+						"config_path": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  fmt.Sprintf("path where the cloud config is uploaded in the control plane nodes (default: %s, or %s for azure)", common.DefCloudConfigFilename, common.DefAzureCloudConfigFilename),
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"manager_flags": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "additional arguments for the cloud-controller-manager",
+						},
+						"aws": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"cloud.0.config"},
+							Description:   "configuration for the AWS cloud provider (used for generating the cloud config)",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"cluster_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "cluster ID, used in the kubernetes.io/cluster/<cluster_id> tag of the AWS resources",
+									},
+									"vpc": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "VPC where the cluster is running",
+									},
+									"subnet_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "subnet used for the load balancers",
+									},
+									"zone": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "availability zone",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCloudControllerManagerVersions["aws"],
+										Description:  "version of the AWS cloud controller manager",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
+						},
+						"openstack": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"cloud.0.config"},
+							Description:   "configuration for the OpenStack cloud provider (used for generating the cloud config)",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"auth_url": {
+										Type:         schema.TypeString,
+										Required:     true,
+										Description:  "Keystone URL",
+										ValidateFunc: common.ValidateURL,
+									},
+									"username": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "user name",
+									},
+									"password": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Sensitive:   true,
+										Description: "password",
+									},
+									"application_credential_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "application credential ID (instead of the username and password)",
+									},
+									"application_credential_secret": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Sensitive:   true,
+										Description: "application credential secret",
+									},
+									"project_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "project (tenant) ID",
+									},
+									"domain_name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "domain name",
+									},
+									"region": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "region",
+									},
+									"subnet_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "subnet used for the load balancers",
+									},
+									"floating_network_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "network used for the floating IPs of the load balancers",
+									},
+									"cinder_csi": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy the Cinder CSI driver",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCloudControllerManagerVersions["openstack"],
+										Description:  "version of the OpenStack cloud controller manager",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
 						},
-						"manager_flags": {
-							Type:        schema.TypeString,
-							Optional:    true,
-							Description: "additional arguments for the cloud-controller-manager",
+						"vsphere": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"cloud.0.config"},
+							Description:   "configuration for the vSphere cloud provider (used for generating the cloud config)",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"server": {
+										Type:         schema.TypeString,
+										Required:     true,
+										Description:  "vCenter server",
+										ValidateFunc: common.ValidateDNSNameOrIP,
+									},
+									"port": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Default:      443,
+										Description:  "vCenter port",
+										ValidateFunc: validation.IntBetween(1, 65535),
+									},
+									"insecure": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "do not verify the vCenter certificate",
+									},
+									"username": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "vCenter user name",
+									},
+									"password": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Sensitive:   true,
+										Description: "vCenter password",
+									},
+									"datacenters": {
+										Type:        schema.TypeList,
+										Required:    true,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Description: "datacenters where the nodes are running",
+									},
+									"cluster_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "kubernetes",
+										Description: "cluster ID, used by the CSI driver for tagging the volumes",
+									},
+									"csi": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "deploy the vSphere CSI driver",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCloudControllerManagerVersions["vsphere"],
+										Description:  "version of the vSphere cloud controller manager",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
+						},
+						"azure": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"cloud.0.config"},
+							Description:   "configuration for the Azure cloud provider (used for generating the azure.json)",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"cloud": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "AzurePublicCloud",
+										Description: "Azure cloud environment",
+									},
+									"tenant_id": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "tenant ID",
+									},
+									"subscription_id": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "subscription ID",
+									},
+									"client_id": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "client ID of the service principal",
+									},
+									"client_secret": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Sensitive:   true,
+										Description: "client secret of the service principal",
+									},
+									"use_managed_identity": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "use the managed identity of the VMs (instead of a service principal)",
+									},
+									"resource_group": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "resource group of the cluster",
+									},
+									"location": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "location of the cluster",
+									},
+									"vnet_name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "virtual network",
+									},
+									"vnet_resource_group": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "resource group of the virtual network",
+									},
+									"subnet_name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "subnet",
+									},
+									"security_group_name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "network security group",
+									},
+									"route_table_name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "route table",
+									},
+									"vm_type": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "standard",
+										Description:  "type of the VMs: standard or vmss",
+										ValidateFunc: validation.StringInSlice([]string{"standard", "vmss"}, false),
+									},
+									"load_balancer_sku": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "standard",
+										Description: "SKU of the load balancers",
+									},
+									"version": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCloudControllerManagerVersions["azure"],
+										Description:  "version of the Azure cloud controller manager",
+										ValidateFunc: common.ValidateVersion,
+									},
+								},
+							},
 						},
 					},
 				},
@@ -298,22 +1761,50 @@ func dataSourceKubeadm() *schema.Resource {
 			"runtime": {
 				Type:     schema.TypeList,
 				Optional: true,
-				ForceNew: true,
 				MaxItems: 1,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"engine": {
 							Type:         schema.TypeString,
 							Optional:     true,
+							ForceNew:     true,
 							Default:      common.DefRuntimeEngine,
 							Description:  "runtime engine: docker, containerd or crio",
 							ValidateFunc: validation.StringInSlice([]string{"crio", "containerd", "docker"}, true),
 						},
+						"cgroup_driver": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "cgroup driver used by the kubelet and the runtime: systemd or cgroupfs (default: the engine's default)",
+							ValidateFunc: validation.StringInSlice([]string{"systemd", "cgroupfs"}, false),
+						},
+						"cri_socket": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "CRI socket used by the kubelet (default: the socket detected in the nodes for the engine)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"containerd_root_dir": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "directory for the persistent containerd data (images, snapshots...) (default: containerd's default)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"containerd_state_dir": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "directory for the containerd state (sockets, mounts...) (default: containerd's default)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
 						"extra_args": {
-							Type:     schema.TypeList,
-							Optional: true,
-							ForceNew: true,
-							MaxItems: 1,
+							Type:        schema.TypeList,
+							Optional:    true,
+							MaxItems:    1,
+							Description: "extra args for the components (they can be updated in place: see the 'reconfigure' phase in the provisioner)",
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"api_server": {
@@ -346,6 +1837,42 @@ func dataSourceKubeadm() *schema.Resource {
 					},
 				},
 			},
+			"registry": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"upstream": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "upstream registry (ie, docker.io)",
+						},
+						"mirrors": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "list of mirrors (ie, https://mirror.internal:5000) for the upstream registry",
+						},
+						"username": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "username for the registry and its mirrors",
+						},
+						"password": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "password for the registry and its mirrors",
+						},
+						"ca": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "CA bundle (in PEM format) for the registry and its mirrors",
+						},
+					},
+				},
+			},
 			"certs": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -404,10 +1931,177 @@ func dataSourceKubeadm() *schema.Resource {
 					},
 				},
 			},
-			// the "config" must be a map of string that will be passed to the "provisioner"
-			"config": {
+			"proxy": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"http": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "HTTP proxy (ie, http://proxy.internal:3128)",
+							Sensitive:    true,
+							ValidateFunc: common.ValidateURL,
+						},
+						"https": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "HTTPS proxy (ie, http://proxy.internal:3128)",
+							Sensitive:    true,
+							ValidateFunc: common.ValidateURL,
+						},
+						"no_proxy": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "list of hosts, domains or CIDRs that must not use the proxy",
+						},
+					},
+				},
+			},
+			"observability": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"expose_control_plane_metrics": {
+							Type:        schema.TypeBool,
+							Default:     false,
+							Optional:    true,
+							Description: "bind the metrics of the scheduler, controller manager and etcd to all the interfaces",
+						},
+					},
+				},
+			},
+			"join_publish": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "publish a (always valid) join command for nodes that are not provisioned by Terraform",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"api_endpoint": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "the API server endpoint nodes will join (defaults to the api.external)",
+							ValidateFunc: common.ValidateHostOptionalPort,
+						},
+						"vault_path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "path (in the mount of the provider's Vault) where the join command is written",
+						},
+					},
+				},
+			},
+			"etcd_client": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "client certificate and endpoints for etcd, when `etcd_client_cert` is enabled",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ca_crt": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "etcd CA certificate",
+						},
+						"crt": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "client certificate",
+						},
+						"key": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Sensitive:   true,
+							Description: "client key",
+						},
+						"expires": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "expiration time (RFC3339) of the client certificate",
+						},
+						"endpoints": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "client URLs of the etcd members",
+						},
+						"metrics_endpoints": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "metrics URLs of the etcd members (only when the control plane metrics are exposed)",
+						},
+					},
+				},
+			},
+			"join_command": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "join command for workers, refreshed on every read when `join_publish` is enabled",
+			},
+			"nodes_status": {
 				Type:     schema.TypeMap,
 				Computed: true,
+				Description: "status of the nodes in the cluster, refreshed on every read: a map from the node " +
+					"name to a JSON document with the role, the readiness, the versions and some facts of the node",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"cluster_health": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "health of the cluster, refreshed on every read",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"healthy": {
+							Type:        schema.TypeBool,
+							Computed:    true,
+							Description: "true if the API server is reachable, all the nodes and control plane components are ready and no certificate is about to expire",
+						},
+						"api_server": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "status of the API server: ok, unreachable or unknown (when there is no kubeconfig)",
+						},
+						"nodes_total": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "number of nodes in the cluster",
+						},
+						"nodes_ready": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "number of nodes that are ready",
+						},
+						"components": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "status of the control plane components (ie, `kube-scheduler = 3/3 ready`)",
+						},
+						"certificates": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "expiration time (RFC3339) of the certificates (ie, `ca`, `admin`, `api_server`)",
+						},
+					},
+				},
+			},
+			// the "config" must be a map of string that will be passed to the "provisioner"
+			"config": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
 				Elem: &schema.Resource{
 					Schema: common.ProvisionerConfigElements,
 				},
@@ -418,8 +2112,52 @@ func dataSourceKubeadm() *schema.Resource {
 
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"vault": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "store the cluster secrets in Vault instead of the Terraform state",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc(common.DefVaultAddressEnv, ""),
+							Description: "address of the Vault server",
+						},
+						"token": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							DefaultFunc: schema.EnvDefaultFunc(common.DefVaultTokenEnv, ""),
+							Description: "token used for accessing Vault",
+						},
+						"mount": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefVaultMount,
+							Description: "mount point of the KV (v2) secrets engine",
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefVaultPath,
+							Description: "path (in the mount) where secrets are stored",
+						},
+					},
+				},
+			},
+		},
+		ConfigureFunc: providerConfigure,
 		ResourcesMap: map[string]*schema.Resource{
-			"kubeadm": dataSourceKubeadm(),
+			"kubeadm":         dataSourceKubeadm(),
+			"kubeadm_user":    resourceKubeadmUser(),
+			"kubeadm_release": resourceKubeadmRelease(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"kubeadm_join_info": dataSourceKubeadmJoinInfo(),
+			"kubeadm_nodes":     dataSourceKubeadmNodes(),
 		},
 	}
 }
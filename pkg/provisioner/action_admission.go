@@ -0,0 +1,50 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// doUploadAdmissionConfig uploads the admission configuration to the control plane nodes,
+// where it is mounted in the API server and passed with `--admission-control-config-file`
+func doUploadAdmissionConfig(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.admission_config")
+	if !ok || len(opt.(string)) == 0 {
+		return nil
+	}
+	config, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the admission config: %s", err))
+	}
+
+	path := d.Get("config.admission_config_path").(string)
+	if len(path) == 0 {
+		path = common.DefAdmissionConfigPath
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading the admission config to %s", path),
+		ssh.DoMkdir(filepath.Dir(path)),
+		ssh.DoUploadBytesToFile(config, path),
+		ssh.DoExec(fmt.Sprintf("chmod 600 %s", path)),
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// machineArchs maps the machine names (as reported by `uname -m`) to
+// the architecture names used by Kubernetes for binaries and images
+var machineArchs = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// getArchFromMachine returns the Kubernetes architecture for a machine name
+// (or an empty string if the machine is not supported)
+func getArchFromMachine(machine string) string {
+	return machineArchs[strings.TrimSpace(machine)]
+}
+
+// doCheckArch checks the architecture of the node is supported by Kubernetes,
+// warning about the addons that will not run in this node
+func doCheckArch(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		machine := ""
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExec("uname -m"),
+			func(s string) {
+				if len(strings.TrimSpace(s)) > 0 {
+					machine = strings.TrimSpace(s)
+				}
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+
+		arch := getArchFromMachine(machine)
+		if len(arch) == 0 {
+			return ssh.ActionError(fmt.Sprintf("unsupported architecture %q", machine))
+		}
+		ssh.GetLoggerFromContext(ctx).Debug("node architecture: %s (%s)", arch, machine)
+		if arch == "amd64" {
+			return nil
+		}
+
+		actions := ssh.ActionList{
+			ssh.DoMessageInfo("Node architecture: %s", arch),
+		}
+		if isConfigEnabled(d, "dashboard_enabled") {
+			actions = append(actions, ssh.DoMessageWarn("the Dashboard only provides amd64 images: it will not run in this node"))
+		}
+		return actions
+	})
+}
+
+// isConfigEnabled returns true if some boolean flag in the `config` is enabled
+func isConfigEnabled(d *schema.ResourceData, key string) bool {
+	opt, ok := d.GetOk("config." + key)
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(opt.(string))
+	return err == nil && enabled
+}
@@ -0,0 +1,33 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestGetArchFromMachine(t *testing.T) {
+	tests := map[string]string{
+		"x86_64":    "amd64",
+		"aarch64\n": "arm64",
+		"armv7l":    "arm",
+		"mips":      "",
+	}
+	for machine, expected := range tests {
+		if arch := getArchFromMachine(machine); arch != expected {
+			t.Fatalf("Error: unexpected architecture for %q: %q", machine, arch)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// doUploadAuditConfig uploads the audit policy (and the kubeconfig for the audit webhook)
+// to the control plane nodes, where they are mounted in the API server
+func doUploadAuditConfig(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.audit_policy")
+	if !ok || len(opt.(string)) == 0 {
+		return nil
+	}
+	policy, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the audit policy: %s", err))
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading the audit policy to %s", common.DefAuditPolicyPath),
+		ssh.DoMkdir(filepath.Dir(common.DefAuditPolicyPath)),
+		ssh.DoUploadBytesToFile(policy, common.DefAuditPolicyPath),
+		ssh.DoExec(fmt.Sprintf("chmod 600 %s", common.DefAuditPolicyPath)),
+	}
+
+	if opt, ok := d.GetOk("config.audit_webhook_config"); ok && len(opt.(string)) > 0 {
+		webhookConfig, err := common.FromTerraformSafeString(opt.(string))
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode the kubeconfig for the audit webhook: %s", err))
+		}
+		actions = append(actions,
+			ssh.DoMessageInfo("Uploading the kubeconfig for the audit webhook to %s", common.DefAuditWebhookConfigPath),
+			ssh.DoMkdir(filepath.Dir(common.DefAuditWebhookConfigPath)),
+			ssh.DoUploadBytesToFile(webhookConfig, common.DefAuditWebhookConfigPath),
+			ssh.DoExec(fmt.Sprintf("chmod 600 %s", common.DefAuditWebhookConfigPath)))
+	}
+	return actions
+}
@@ -0,0 +1,96 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// wait up to 10 minutes for the control plane...
+	controlPlaneWaitTimes = 40
+
+	// ... checking it every 15 seconds
+	controlPlaneWaitInterval = 15 * time.Second
+
+	// command for getting the nodes where the CoreDNS pods have been scheduled
+	kubectlGetCoreDNSNodesCmd = `--namespace=kube-system get pods --selector=k8s-app=kube-dns -o=jsonpath='{.items[*].spec.nodeName}'`
+)
+
+// getScheduledNodes returns the nodes in the output of `kubectlGetCoreDNSNodesCmd`
+func getScheduledNodes(lines []string) []string {
+	res := []string{}
+	for _, line := range lines {
+		res = append(res, strings.Fields(line)...)
+	}
+	return res
+}
+
+// doCheckControlPlaneReady checks that the API server reports a healthy `/readyz`
+// and that CoreDNS has been scheduled, returning an error otherwise
+func doCheckControlPlaneReady(d *schema.ResourceData) ssh.Action {
+	kubeconfig := getKubeconfigFromResourceData(d)
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		if ok, _ := ssh.CheckLocalFileExists(kubeconfig).Check(ctx); !ok {
+			return ssh.ActionError(fmt.Sprintf("no local kubeconfig found at %q: the control plane has not been initialized yet", kubeconfig))
+		}
+
+		res := ssh.DoSendingExecOutputToDevNull(doRemoteKubectl(d, "get", "--raw=/readyz")).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("the API server is not ready yet: %s", res.Error()))
+		}
+
+		lines := []string{}
+		res = ssh.DoSendingExecOutputToFunc(
+			doRemoteKubectl(d, kubectlGetCoreDNSNodesCmd),
+			func(s string) {
+				lines = append(lines, s)
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the CoreDNS pods: %s", res.Error()))
+		}
+		if nodes := getScheduledNodes(lines); len(nodes) == 0 {
+			return ssh.ActionError("CoreDNS has not been scheduled yet")
+		}
+		return nil
+	})
+}
+
+// doWaitForControlPlane waits until the control plane is ready before joining
+// a worker, so workers created in the same `apply` as the masters do not try
+// to join a cluster that is still being initialized
+func doWaitForControlPlane(d *schema.ResourceData) ssh.Action {
+	if !d.Get("wait_for_control_plane").(bool) {
+		return nil
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Waiting for the control plane to be ready..."),
+		ssh.DoWithException(
+			ssh.DoRetry(
+				ssh.Retry{Times: controlPlaneWaitTimes, Interval: controlPlaneWaitInterval},
+				doCheckControlPlaneReady(d)),
+			ssh.DoMessageWarn("the control plane did not become ready (see 'wait_for_control_plane' in the provisioner)")),
+		ssh.DoMessageInfo("The control plane is ready: joining the cluster."),
+	}
+}
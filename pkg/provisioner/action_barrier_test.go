@@ -0,0 +1,45 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetScheduledNodes(t *testing.T) {
+	if nodes := getScheduledNodes([]string{"", "  "}); len(nodes) != 0 {
+		t.Fatalf("Error: no nodes expected: %v", nodes)
+	}
+	if nodes := getScheduledNodes([]string{"master-0 master-1", ""}); len(nodes) != 2 || nodes[1] != "master-1" {
+		t.Fatalf("Error: unexpected nodes: %v", nodes)
+	}
+}
+
+func TestDoWaitForControlPlane(t *testing.T) {
+	raw := map[string]interface{}{
+		"wait_for_control_plane": false,
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if action := doWaitForControlPlane(d); action != nil {
+		t.Fatalf("Error: no action expected when 'wait_for_control_plane' is disabled")
+	}
+
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if action := doWaitForControlPlane(d); action == nil {
+		t.Fatalf("Error: the control plane should be waited by default")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// chronyScript installs chrony, replaces the NTP servers with $SERVERS (when provided)
+// and waits until the clock is synchronized
+const chronyScript = `#!/bin/sh
+log()   { echo "[chrony setup] $@" ; }
+abort() { log "FATAL!!!!: $@" ; exit 1 ; }
+
+if ! command -v chronyd >/dev/null 2>&1 ; then
+	log "installing chrony"
+	if command -v apt-get >/dev/null 2>&1 ; then
+		apt-get update && apt-get install -y chrony || abort "could not install chrony"
+	elif command -v zypper >/dev/null 2>&1 ; then
+		zypper --non-interactive install -y chrony || abort "could not install chrony"
+	else
+		YUM=yum
+		command -v dnf >/dev/null 2>&1 && YUM=dnf
+		$YUM install -y chrony || abort "could not install chrony"
+	fi
+fi
+
+CONF=/etc/chrony.conf
+[ -f /etc/chrony/chrony.conf ] && CONF=/etc/chrony/chrony.conf
+
+if [ -n "$SERVERS" ] ; then
+	log "using the NTP servers: $SERVERS"
+	sed -i -e 's/^\(pool\|server\|sourcedir\) /#\1 /' $CONF
+	for SERVER in $SERVERS ; do
+		echo "server $SERVER iburst" >> $CONF
+	done
+fi
+# step the clock (instead of slewing it) if it is too far off
+grep -q '^makestep' $CONF || echo "makestep 1 3" >> $CONF
+
+# stop any other NTP client that could be fighting for the clock
+systemctl disable --now systemd-timesyncd ntp ntpd >/dev/null 2>&1
+
+SERVICE=chronyd
+systemctl list-unit-files | grep -q '^chrony.service' && SERVICE=chrony
+systemctl enable $SERVICE  || abort "could not enable $SERVICE"
+systemctl restart $SERVICE || abort "could not start $SERVICE"
+
+log "waiting for the clock to be synchronized"
+chronyc -a makestep >/dev/null 2>&1
+chronyc waitsync 30 1 || log "WARNING: the clock is not synchronized yet"
+`
+
+// isChronyEnabled returns true if chrony must be installed in the node
+func isChronyEnabled(d *schema.ResourceData) bool {
+	_, ok := d.GetOk("chrony")
+	return ok
+}
+
+// getChronyServersFromResourceData returns the NTP servers for chrony
+// (or an empty list for using the default servers in the distro)
+func getChronyServersFromResourceData(d *schema.ResourceData) []string {
+	res := []string{}
+	if opt, ok := d.GetOk("chrony.0.servers"); ok {
+		for _, s := range opt.([]interface{}) {
+			res = append(res, s.(string))
+		}
+	}
+	return res
+}
+
+// doSetupChrony installs and enables chrony, so the clocks in all the nodes
+// are synchronized (skewed clocks break the TLS certificates validation and etcd)
+func doSetupChrony(d *schema.ResourceData) ssh.Action {
+	if !isChronyEnabled(d) {
+		return nil
+	}
+
+	env := map[string]string{
+		"SERVERS": strings.Join(getChronyServersFromResourceData(d), " "),
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Setting up chrony for the time synchronization..."),
+		ssh.DoExecScriptWithEnv([]byte(chronyScript), env),
+	}
+}
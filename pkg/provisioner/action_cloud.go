@@ -0,0 +1,203 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// awsProviderIDScript prints the provider ID of an EC2 instance,
+// obtained from the instance metadata (IMDSv2, falling back to IMDSv1)
+const awsProviderIDScript = `#!/bin/sh
+IMDS=http://169.254.169.254/latest
+TOKEN="$(curl -sf -m 5 -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' 2>/dev/null)"
+md() {
+	if [ -n "$TOKEN" ] ; then
+		curl -sf -m 5 -H "X-aws-ec2-metadata-token: $TOKEN" $IMDS/meta-data/$1
+	else
+		curl -sf -m 5 $IMDS/meta-data/$1
+	fi
+}
+ZONE="$(md placement/availability-zone)"
+ID="$(md instance-id)"
+[ -n "$ZONE" ] && [ -n "$ID" ] && echo "provider-id=aws:///$ZONE/$ID"
+exit 0
+`
+
+// vsphereProviderIDScript prints the provider ID of a vSphere VM, obtained
+// from the VM serial number (ie, "VMware-42 1a 2b 3c ... 4d-5e 6f ...")
+const vsphereProviderIDScript = `#!/bin/sh
+SERIAL="$(cat /sys/class/dmi/id/product_serial 2>/dev/null)"
+case "$SERIAL" in
+VMware-*) ;;
+*) exit 0 ;;
+esac
+UUID="$(echo "${SERIAL#VMware-}" | tr -d ' -' | tr 'A-F' 'a-f')"
+[ ${#UUID} -eq 32 ] || exit 0
+echo "provider-id=vsphere://$(echo "$UUID" | sed -E 's/(.{8})(.{4})(.{4})(.{4})(.{12})/\1-\2-\3-\4-\5/')"
+exit 0
+`
+
+// kubelet argument used for setting the provider ID
+const kubeletProviderIDArg = "provider-id"
+
+// providerIDScripts are the scripts used for detecting the provider ID of a node
+var providerIDScripts = map[string]string{
+	"aws":     awsProviderIDScript,
+	"vsphere": vsphereProviderIDScript,
+}
+
+// parseProviderID parses a "provider-id=<ID>" line, returning the ID
+// (or an empty string if the line is not valid)
+func parseProviderID(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, kubeletProviderIDArg+"=") {
+		return ""
+	}
+	id := strings.TrimPrefix(line, kubeletProviderIDArg+"=")
+	if !strings.Contains(id, "://") {
+		return ""
+	}
+	return id
+}
+
+// doAddCloudProviderID detects the provider ID of the node (from the cloud
+// instance metadata) and sets it in the kubelet's `--provider-id` for the
+// `command` ("init" or "join") configuration
+func doAddCloudProviderID(d *schema.ResourceData, command string) ssh.Action {
+	cloudProvider := d.Get("config.cloud_provider").(string)
+	script, ok := providerIDScripts[cloudProvider]
+	if !ok {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		providerID := ""
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExecScript([]byte(script)),
+			func(s string) {
+				if id := parseProviderID(s); len(id) > 0 {
+					providerID = id
+				}
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if len(providerID) == 0 {
+			return ssh.DoMessageWarn("Could not detect the %s provider ID of this node from the instance metadata", cloudProvider)
+		}
+
+		err := updateKubeletExtraArgs(d, command, func(args map[string]string) {
+			args[kubeletProviderIDArg] = providerID
+		})
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return ssh.DoMessageInfo("Node provider ID: %s", providerID)
+	})
+}
+
+// doLoadVSphereCSI loads the vSphere CSI driver (if enabled), creating
+// the secret with its configuration first
+func doLoadVSphereCSI(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.vsphere_csi_config")
+	if !ok || len(opt.(string)) == 0 {
+		return nil
+	}
+	config, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the vSphere CSI configuration: %s", err))
+	}
+
+	secret := strings.Join([]string{
+		"apiVersion: v1",
+		"kind: Namespace",
+		"metadata:",
+		"  name: " + common.DefVSphereCSINamespace,
+		"---",
+		"apiVersion: v1",
+		"kind: Secret",
+		"metadata:",
+		"  name: vsphere-config-secret",
+		"  namespace: " + common.DefVSphereCSINamespace,
+		"type: Opaque",
+		"data:",
+		"  csi-vsphere.conf: " + base64.StdEncoding.EncodeToString(config),
+	}, "\n") + "\n"
+
+	kubeletRootDir := getKubeletRootDirFromResourceData(d)
+	if kubeletRootDir == common.DefKubeletRootDir {
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Loading the vSphere CSI driver from %q", common.DefVSphereCSIManifest),
+			doRemoteKubectlApply(d, []ssh.Manifest{
+				{Inline: secret},
+				{URL: common.DefVSphereCSIManifest},
+			}),
+		}
+	}
+
+	// the node plugin mounts the kubelet directory with a bidirectional mount propagation:
+	// it must use the same kubelet root directory as the kubelets
+	remoteManifest, err := ssh.GetTempFilename()
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not create temporary file: %s", err))
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the vSphere CSI driver from %q (with the kubelet in %s)", common.DefVSphereCSIManifest, kubeletRootDir),
+		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: secret}}),
+		ssh.DoExec(fmt.Sprintf("curl -fsSL %s | sed -e 's|%s|%s|g' > %s",
+			common.DefVSphereCSIManifest, common.DefKubeletRootDir, kubeletRootDir, remoteManifest)),
+		doRemoteKubectl(d, "apply", "--validate=false", "-f", remoteManifest),
+		ssh.DoDeleteFile(remoteManifest),
+	}
+}
+
+// doUploadCloudConfig uploads the cloud config to the control plane nodes, where
+// it is mounted in the API server and the controller manager (and where the cloud
+// controller manager reads it from the host for some cloud providers)
+func doUploadCloudConfig(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.cloud_config")
+	if !ok || len(opt.(string)) == 0 {
+		return nil
+	}
+	config, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the cloud config: %s", err))
+	}
+
+	path := d.Get("config.cloud_config_path").(string)
+	if len(path) == 0 {
+		path = common.DefCloudConfigFilename
+		if d.Get("config.cloud_provider").(string) == "azure" {
+			path = common.DefAzureCloudConfigFilename
+		}
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading the cloud config to %s", path),
+		ssh.DoMkdir(filepath.Dir(path)),
+		ssh.DoUploadBytesToFile(config, path),
+		ssh.DoExec(fmt.Sprintf("chmod 600 %s", path)),
+	}
+}
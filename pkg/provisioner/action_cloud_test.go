@@ -0,0 +1,37 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"provider-id=aws:///us-east-1a/i-0123456789abcdef0\r", "aws:///us-east-1a/i-0123456789abcdef0"},
+		{"provider-id=vsphere://421a2b3c-4d5e-6f70-8192-a3b4c5d6e7f8", "vsphere://421a2b3c-4d5e-6f70-8192-a3b4c5d6e7f8"},
+		{"provider-id=", ""},
+		{"provider-id=i-0123456789abcdef0", ""},
+		{"curl: (28) Connection timed out", ""},
+	}
+	for _, test := range tests {
+		if id := parseProviderID(test.line); id != test.expected {
+			t.Fatalf("Error: unexpected provider ID for %q: %q (expected %q)", test.line, id, test.expected)
+		}
+	}
+}
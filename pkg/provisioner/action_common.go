@@ -15,8 +15,8 @@
 package provisioner
 
 import (
-	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path"
@@ -64,6 +64,10 @@ func getKubeadmIgnoredChecksArg(d *schema.ResourceData) string {
 			ignoredChecks = append(ignoredChecks, check.(string))
 		}
 	}
+	if isVIPEnabled(d) {
+		// the kube-vip static pod is created before running kubeadm
+		ignoredChecks = append(ignoredChecks, kubeVipIgnoredCheck)
+	}
 	ignoredChecks = common.StringSliceUnique(ignoredChecks) // remove all the duplicates
 
 	if len(ignoredChecks) > 0 {
@@ -94,17 +98,23 @@ func doExecKubeadmWithConfig(d *schema.ResourceData, command string, cfg string,
 	return ssh.DoExec(fmt.Sprintf("%s %s %s", kubeadm_path, command, strings.Join(allArgs, " ")))
 }
 
+// number of lines of the kubelet journal attached to the errors in `kubeadm`
+const kubeletJournalLines = 20
+
 // doKubeadm is the common kubeadm call, both for the `init` as well as well as for the `join`.
 func doKubeadm(d *schema.ResourceData, kubeadmConfigFilename string, command string, args ...string) ssh.Action {
 	// run kubeadm... if something goes wrong, delete the "kubeadm-*.conf" file created
-	// otherwise, back up the config file
+	// (attaching the kubelet logs to the error), otherwise, back up the config file
 	actions := ssh.ActionList{
 		ssh.DoMessageInfo("Starting kubeadm..."),
 		ssh.DoWithException(
-			ssh.ActionList{
-				doUploadKubeadmConfig(d, command, kubeadmConfigFilename),
-				doExecKubeadmWithConfig(d, command, kubeadmConfigFilename, args...),
-			},
+			ssh.DoAppendingOutputToError(
+				ssh.ActionList{
+					doUploadKubeadmConfig(d, command, kubeadmConfigFilename),
+					doExecKubeadmWithConfig(d, command, kubeadmConfigFilename, args...),
+				},
+				"Last lines in the kubelet journal",
+				ssh.DoExec(fmt.Sprintf("journalctl -u kubelet --no-pager -n %d", kubeletJournalLines))),
 			ssh.ActionList{
 				ssh.DoMessageWarn("kubeadm failed: dumping logs..."),
 				ssh.DoMessageWarn("- kubelet logs:"),
@@ -120,6 +130,25 @@ func doKubeadm(d *schema.ResourceData, kubeadmConfigFilename string, command str
 	return actions
 }
 
+// doPullImages pulls the control plane images, using a temporary copy of the init configuration
+// (so the images repository and versions are the same that will be used by `kubeadm init`)
+func doPullImages(d *schema.ResourceData) ssh.Action {
+	kubeadmConfigFilename, err := ssh.GetTempFilename()
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+	}
+
+	return ssh.DoWithCleanup(
+		ssh.ActionList{
+			ssh.DoMessageInfo("Pulling images..."),
+			doUploadKubeadmConfig(d, "init", kubeadmConfigFilename),
+			ssh.DoExec(fmt.Sprintf("%s config images pull --config=%s", getKubeadmFromResourceData(d), kubeadmConfigFilename)),
+		},
+		ssh.ActionList{
+			ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename)),
+		})
+}
+
 // doMaybeResetWorker maybe "reset"s with kubeadm if /etc/kubernetes/kubeadm-* exists
 func doMaybeResetWorker(d *schema.ResourceData, kubeadmConfigFilename string) ssh.Action {
 	return ssh.DoIf(
@@ -132,6 +161,18 @@ func doMaybeResetWorker(d *schema.ResourceData, kubeadmConfigFilename string) ss
 		})
 }
 
+// doResetNode resets the node after a failed `kubeadm init` or `join`, so
+// it can be retried from a clean state
+func doResetNode(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionList{
+		ssh.DoMessageWarn("resetting the node with 'kubeadm reset'"),
+		ssh.DoTry(doExecKubeadmWithConfig(d, "reset", "", "--force")),
+		ssh.DoTry(ssh.DoExec(fmt.Sprintf("rm -rf /etc/kubernetes/manifests %s/pki %s %s",
+			getKubeletRootDirFromResourceData(d), common.DefKubeadmInitConfPath, common.DefKubeadmJoinConfPath))),
+		ssh.DoFlushCache(),
+	}
+}
+
 func doUploadKubeadmConfig(d *schema.ResourceData, command string, kubeadmConfigFilename string) ssh.Action {
 	return ssh.ActionFunc(func(context.Context) ssh.Action {
 		// we must delay the {init|join}Config retrieval as some other functions
@@ -155,6 +196,61 @@ func doUploadKubeadmConfig(d *schema.ResourceData, command string, kubeadmConfig
 	})
 }
 
+// doCreateAPIServerCert creates the API server certificate with the address used for
+// connecting to this node as an extra SAN, before `kubeadm init` or `kubeadm join` run
+// (kubeadm keeps an existing certificate as long as it includes all the SANs it expects).
+// The address is not added to the cluster configuration, as every control plane node
+// would get the SANs of the other nodes.
+func doCreateAPIServerCert(d *schema.ResourceData, command string, host string) ssh.Action {
+	if !getAutoSANsFromResourceData(d) {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(context.Context) ssh.Action {
+		// (the configurations can be modified until the very last moment)
+		certConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for the API server certificate: %s", err))
+		}
+		if command == "join" {
+			joinConfig, _, err := common.JoinConfigFromResourceData(d)
+			if err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
+			}
+			// the certificate must be valid for this node's name and advertised address
+			if joinConfig.ControlPlane != nil {
+				certConfig.LocalAPIEndpoint = joinConfig.ControlPlane.LocalAPIEndpoint
+			}
+			certConfig.NodeRegistration = joinConfig.NodeRegistration
+		}
+
+		sans := common.AppendCertSANs(certConfig.APIServer.CertSANs, host)
+		if len(sans) == len(certConfig.APIServer.CertSANs) {
+			return nil
+		}
+		certConfig.APIServer.CertSANs = sans
+
+		configBytes, err := common.InitConfigToYAML(certConfig)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not serialize the configuration for the API server certificate: %s", err))
+		}
+		kubeadmConfigFilename, err := ssh.GetTempFilename()
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+		}
+
+		return ssh.DoWithCleanup(
+			ssh.ActionList{
+				ssh.DoMessageInfo("Creating the API server certificate (with %s as an extra SAN)", host),
+				ssh.DoUploadBytesToFile(configBytes, kubeadmConfigFilename),
+				ssh.DoExec(fmt.Sprintf("%s init phase certs apiserver --config=%s", getKubeadmFromResourceData(d), kubeadmConfigFilename)),
+			},
+			ssh.ActionList{
+				ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename)),
+			})
+	})
+}
+
 // doUploadCerts upload the certificates from the serialized `d.config` to the remote machine
 // we only do this on the control plane machines
 func doUploadCerts(d *schema.ResourceData) ssh.Action {
@@ -195,7 +291,23 @@ func doLoadCloudProviderManager(d *schema.ResourceData) ssh.Action {
 	}
 
 	manifest := ssh.Manifest{Inline: assets.CloudProviderCode}
-	err := manifest.ReplaceConfig(common.GetProvisionerConfig(d))
+	if code, ok := common.CloudProviderManifests[cloudProvider]; ok {
+		manifest = ssh.Manifest{Inline: code}
+	}
+	// the data in the Secret must use the standard Base64 encoding
+	config := map[string]interface{}{}
+	for k, v := range common.GetProvisionerConfig(d) {
+		config[k] = v
+	}
+	if cloudConfig, ok := config["cloud_config"].(string); ok && len(cloudConfig) > 0 {
+		data, err := common.FromTerraformSafeString(cloudConfig)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode the cloud config: %s", err))
+		}
+		config["cloud_config"] = base64.StdEncoding.EncodeToString(data)
+	}
+
+	err := manifest.ReplaceConfig(config)
 	if err != nil {
 		return ssh.ActionError(fmt.Sprintf("could not replace variables in cloud controller manager manifest for %q: %s", cloudProvider, err))
 	}
@@ -247,22 +359,18 @@ func doUploadResolvConf(d *schema.ResourceData) ssh.Action {
 		return nil
 	}
 
-	upstreamStr := dRaw.(string)
-	if len(upstreamStr) == 0 {
+	servers := strings.Fields(dRaw.(string))
+	if len(servers) == 0 {
 		return nil
 	}
 
-	buf := bytes.Buffer{}
-	servers := strings.Split(strings.TrimSpace(upstreamStr), " ")
-	for _, server := range servers {
-		if server == "" {
-			continue
-		}
-		buf.WriteString(fmt.Sprintf("nameserver %s\n", server))
+	search := []string{}
+	if opt, ok := d.GetOk("config.dns_search"); ok {
+		search = strings.Fields(opt.(string))
 	}
 
 	return ssh.ActionList{
 		ssh.DoMessageInfo("Using user-provided upstream DNS resolvers: %+v", servers),
-		ssh.DoUploadBytesToFile(buf.Bytes(), common.DefResolvUpstreamConf),
+		ssh.DoUploadBytesToFile(common.NewResolvConf(servers, search), common.DefResolvUpstreamConf),
 	}
 }
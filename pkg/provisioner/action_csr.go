@@ -0,0 +1,101 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// wait for the kubelet serving CSR for (about) two minutes
+	csrApprovalRetryTimes    = 12
+	csrApprovalRetryInterval = 10 * time.Second
+)
+
+// doApproveKubeletServingCSR approves the CSR created by the kubelet in this node for its
+// serving certificate (so `kubectl logs/exec` and the metrics-server can verify it)
+func doApproveKubeletServingCSR(d *schema.ResourceData) ssh.Action {
+	if !isConfigEnabled(d, "kubelet_serving_certs") {
+		return nil
+	}
+
+	node := ssh.KubeNode{}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Approving the kubelet serving certificate..."),
+		DoGetNodename(d, &node),
+		// (not an error: the CSRs are also approved when the kubeadm resource is refreshed)
+		ssh.DoTry(
+			ssh.DoWithException(
+				ssh.DoRetry(
+					ssh.Retry{Times: csrApprovalRetryTimes, Interval: csrApprovalRetryInterval},
+					ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+						return doApprovePendingServingCSRs(d, node.Nodename)
+					})),
+				ssh.DoMessageWarn("The kubelet serving certificate could not be approved: it will be approved in the next refresh"))),
+	}
+}
+
+// doApprovePendingServingCSRs approves the pending kubelet serving CSRs for a node,
+// failing when there are no CSRs to approve
+func doApprovePendingServingCSRs(d *schema.ResourceData, nodename string) ssh.Action {
+	if len(nodename) == 0 {
+		return ssh.ActionError("could not get the name of this node")
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var nodeBuf, csrsBuf bytes.Buffer
+		res := ssh.ActionList{
+			ssh.DoSendingExecOutputToWriter(doRemoteKubectl(d, "get", "node", nodename, "-o", "json"), &nodeBuf),
+			ssh.DoSendingExecOutputToWriter(doRemoteKubectl(d, "get", "csr", "-o", "json"), &csrsBuf),
+		}.Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+
+		node := corev1.Node{}
+		if err := json.Unmarshal(nodeBuf.Bytes(), &node); err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not parse node %q: %s", nodename, err))
+		}
+		csrs := certificatesv1beta1.CertificateSigningRequestList{}
+		if err := json.Unmarshal(csrsBuf.Bytes(), &csrs); err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not parse the CSRs: %s", err))
+		}
+
+		pending := common.GetPendingKubeletServingCSRs(csrs.Items, &node)
+		if len(pending) == 0 {
+			return ssh.ActionError(fmt.Sprintf("no kubelet serving CSR found for node %q", nodename))
+		}
+
+		args := []string{"certificate", "approve"}
+		for _, csr := range pending {
+			args = append(args, csr.Name)
+		}
+		return ssh.ActionList{
+			doRemoteKubectl(d, args...),
+			ssh.DoMessageInfo("Kubelet serving certificate approved for %q", nodename),
+		}
+	})
+}
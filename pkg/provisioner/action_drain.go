@@ -20,18 +20,33 @@ import (
 	"github.com/hashicorp/terraform/helper/schema"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+// doRemoveNode removes the node from the cluster: it is drained and deleted, removed from the
+// etcd cluster and reset (depending on the `destroy` settings)
 func doRemoveNode(d *schema.ResourceData) ssh.Action {
-	return ssh.ActionList{
+	actions := ssh.ActionList{
 		ssh.DoMessageInfo("Preparing to remove node from cluster..."),
 		ssh.DoTry(doDrainKubernetesNode(d)),
-		ssh.DoTry(doRemoveIfMember(d)),
 	}
+	// (only the nodes in a stacked etcd are members of the etcd cluster)
+	if getEtcdModeFromResourceData(d) == common.EtcdModeStacked {
+		actions = append(actions, ssh.DoTry(doRemoveIfMember(d)))
+	}
+	if d.Get("destroy.0.skip_reset").(bool) {
+		actions = append(actions, ssh.DoMessageInfo("Skipping the reset of the node"))
+	} else {
+		actions = append(actions, ssh.DoTry(doResetNode(d)))
+	}
+	return actions
 }
 
-// doDrainKubernetesNode drains a Kubernetes node
+// doDrainKubernetesNode drains a Kubernetes node and deletes it from the cluster
 func doDrainKubernetesNode(d *schema.ResourceData) ssh.Action {
+	skipDrain := d.Get("destroy.0.skip_drain").(bool)
+	force := d.Get("destroy.0.force_delete_node").(bool)
+
 	localKubeNode := ssh.KubeNode{}
 
 	actions := ssh.ActionList{
@@ -41,10 +56,21 @@ func doDrainKubernetesNode(d *schema.ResourceData) ssh.Action {
 			if localKubeNode.IsEmpty() {
 				return ssh.DoMessageWarn("could not find Kubernetes nodename for this node")
 			}
-			// drain the node with "nodename"
-			return ssh.ActionList{
+			drain := ssh.ActionList{
 				doKubectlDrainNode(d, localKubeNode.Nodename),
 				ssh.DoMessageInfo("Kubernetes node %q has been drained", localKubeNode.Nodename),
+			}
+			switch {
+			case skipDrain:
+				drain = ssh.ActionList{ssh.DoMessageInfo("Skipping the drain of Kubernetes node %q", localKubeNode.Nodename)}
+			case force:
+				// the node is deleted even when the drain fails (ie, it times out)
+				drain = ssh.ActionList{ssh.DoTry(drain)}
+			}
+
+			// drain the node with "nodename"
+			return ssh.ActionList{
+				drain,
 				doKubectlDeleteNode(d, localKubeNode.Nodename),
 				ssh.DoMessageInfo("Kubernetes node %q has been deleted", localKubeNode.Nodename),
 			}
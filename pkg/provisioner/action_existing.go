@@ -0,0 +1,87 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// the node is adopted (ie, `kubeadm init/join` is skipped) when it looks healthy
+	onExistingAdopt = "adopt"
+
+	// the node is reset before running `kubeadm init/join`
+	onExistingReset = "reset"
+
+	// the provisioning fails
+	onExistingFail = "fail"
+
+	// kubeconfig written by the kubelet TLS bootstrap
+	kubeletKubeconfig = "/etc/kubernetes/kubelet.conf"
+
+	// static pod manifest for the API server in masters
+	apiServerManifest = "/etc/kubernetes/manifests/kube-apiserver.yaml"
+)
+
+// checkNodeProvisioned checks if the node has been initialized/joined before
+func checkNodeProvisioned() ssh.CheckerFunc {
+	return ssh.CheckOr(
+		ssh.CheckFileExists(kubeletKubeconfig),
+		ssh.CheckFileExists(apiServerManifest),
+		ssh.CheckFileExists(ssh.DefAdminKubeconfig),
+	)
+}
+
+// doOnExistingNode runs the `provision` actions, checking before if the node has
+// been initialized/joined before. In that case, depending on the `on_existing`
+// argument, the provisioning is skipped when the `adopt` checker is true (ie,
+// the node looks healthy) or the node is reset and provisioned again ("adopt"),
+// the node is always reset and provisioned again ("reset"), or we just fail ("fail").
+func doOnExistingNode(d *schema.ResourceData, adopt ssh.Checker, provision ssh.Action) ssh.Action {
+	var existing ssh.Action
+	switch getOnExistingFromResourceData(d) {
+	case onExistingFail:
+		existing = ssh.DoAbort("this node has been initialized/joined before (%s or %s found): "+
+			"reset it manually or set 'on_existing' to %q or %q", kubeletKubeconfig, apiServerManifest,
+			onExistingAdopt, onExistingReset)
+	case onExistingReset:
+		existing = ssh.ActionList{
+			ssh.DoMessageWarn("this node has been initialized/joined before: resetting it"),
+			doResetNode(d),
+			provision,
+		}
+	default:
+		existing = ssh.DoIfElse(
+			adopt,
+			ssh.DoMessageInfo("This node has been initialized/joined before and looks healthy: adopting it"),
+			ssh.ActionList{
+				ssh.DoMessageWarn("this node has been initialized/joined before but it does not look healthy: resetting it"),
+				doResetNode(d),
+				provision,
+			})
+	}
+
+	return ssh.DoIfElse(checkNodeProvisioned(), existing, provision)
+}
+
+// checkWorkerAlive checks if a worker has joined the cluster and the kubelet is running
+func checkWorkerAlive() ssh.CheckerFunc {
+	return ssh.CheckAnd(
+		ssh.CheckFileExists(kubeletKubeconfig),
+		ssh.CheckServiceActive("kubelet.service"),
+	)
+}
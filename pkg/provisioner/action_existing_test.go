@@ -0,0 +1,65 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestOnExistingNode(t *testing.T) {
+	tests := []struct {
+		onExisting  string
+		responses   []string
+		provisioned bool
+		fails       bool
+	}{
+		// a fresh node is always provisioned
+		{onExisting: "fail", responses: []string{"CONDITION_FAILED", "CONDITION_FAILED", "CONDITION_FAILED"}, provisioned: true},
+		// a node with a kubelet.conf...
+		{onExisting: "fail", responses: []string{"CONDITION_SUCCEEDED"}, fails: true},
+		// ... is adopted when the adopt check is true
+		{onExisting: "adopt", responses: []string{"CONDITION_SUCCEEDED", "CONDITION_SUCCEEDED"}},
+	}
+
+	for _, test := range tests {
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+			"on_existing": test.onExisting,
+		})
+
+		provisioned := false
+		provision := ssh.ActionFunc(func(context.Context) ssh.Action {
+			provisioned = true
+			return nil
+		})
+
+		ctx := ssh.NewTestingContextWithResponses(test.responses)
+		res := doOnExistingNode(d, ssh.CheckExec("true"), provision).Apply(ctx)
+		if ssh.IsError(res) != test.fails {
+			t.Fatalf("Error: unexpected result with on_existing=%q: %v", test.onExisting, res)
+		}
+		if test.fails && !strings.Contains(res.Error(), "on_existing") {
+			t.Fatalf("Error: the error does not mention 'on_existing': %s", res.Error())
+		}
+		if provisioned != test.provisioned {
+			t.Fatalf("Error: unexpected provisioning with on_existing=%q: %t", test.onExisting, provisioned)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getFirewallPorts returns the list of ports that must be opened in the
+// firewall for the role of the node and the CNI driver
+func getFirewallPorts(master bool, cni string, flannelBackend string) []string {
+	ports := []string{}
+	if master {
+		ports = append(ports, common.DefMasterFirewallPorts...)
+	} else {
+		ports = append(ports, common.DefWorkerFirewallPorts...)
+	}
+
+	switch {
+	case cni == "flannel" && flannelBackend == "udp":
+		ports = append(ports, "8285/udp")
+	case cni == "flannel" && flannelBackend == "host-gw":
+		// no encapsulation: nothing to open
+	default:
+		ports = append(ports, common.DefCNIFirewallPorts[cni]...)
+	}
+	return ports
+}
+
+// doOpenFirewall opens the ports required by Kubernetes in firewalld
+// or ufw, when `manage_firewall` is enabled
+func doOpenFirewall(d *schema.ResourceData, master bool) ssh.Action {
+	if !d.Get("manage_firewall").(bool) {
+		return nil
+	}
+
+	cni := d.Get("config.cni_plugin").(string)
+	flannelBackend := d.Get("config.flannel_backend").(string)
+	ports := getFirewallPorts(master, cni, flannelBackend)
+	if len(d.Get("config.metallb_manifest").(string)) > 0 {
+		ports = append(ports, common.DefMetalLBFirewallPorts...)
+	}
+	return ssh.DoOpenFirewallPorts(ports...)
+}
@@ -0,0 +1,47 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestGetFirewallPorts(t *testing.T) {
+	contains := func(ports []string, port string) bool {
+		for _, p := range ports {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	}
+
+	ports := getFirewallPorts(true, "flannel", "vxlan")
+	for _, port := range []string{"6443/tcp", "2379-2380/tcp", "8472/udp"} {
+		if !contains(ports, port) {
+			t.Fatalf("Error: %q not found in the master ports: %v", port, ports)
+		}
+	}
+
+	ports = getFirewallPorts(false, "weave", "")
+	if contains(ports, "6443/tcp") || !contains(ports, "10250/tcp") || !contains(ports, "6783-6784/udp") {
+		t.Fatalf("Error: unexpected worker ports: %v", ports)
+	}
+
+	ports = getFirewallPorts(false, "flannel", "host-gw")
+	if contains(ports, "8472/udp") {
+		t.Fatalf("Error: VXLAN port should not be opened with host-gw: %v", ports)
+	}
+}
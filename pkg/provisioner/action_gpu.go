@@ -0,0 +1,94 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// nvidiaToolkitScript installs the NVIDIA container toolkit and configures
+// the container runtime ($RUNTIME) for using it by default
+const nvidiaToolkitScript = `#!/bin/sh
+REPO=https://nvidia.github.io/libnvidia-container/stable
+
+log()   { echo "[nvidia setup] $@" ; }
+abort() { log "FATAL!!!!: $@" ; exit 1 ; }
+
+if ! command -v nvidia-ctk >/dev/null 2>&1 ; then
+	if command -v apt-get >/dev/null 2>&1 ; then
+		KEYRING=/usr/share/keyrings/nvidia-container-toolkit-keyring.gpg
+		curl -fsSL https://nvidia.github.io/libnvidia-container/gpgkey | gpg --dearmor --yes -o $KEYRING || \
+			abort "could not get the NVIDIA repository key"
+		curl -fsSL $REPO/deb/nvidia-container-toolkit.list | \
+			sed -e "s#deb https://#deb [signed-by=$KEYRING] https://#g" > /etc/apt/sources.list.d/nvidia-container-toolkit.list
+		apt-get update && apt-get install -y nvidia-container-toolkit || abort "could not install the NVIDIA container toolkit"
+	elif command -v zypper >/dev/null 2>&1 ; then
+		zypper --non-interactive ar $REPO/rpm/nvidia-container-toolkit.repo
+		zypper --non-interactive --gpg-auto-import-keys install -y nvidia-container-toolkit || \
+			abort "could not install the NVIDIA container toolkit"
+	else
+		YUM=yum
+		command -v dnf >/dev/null 2>&1 && YUM=dnf
+		curl -fsSL -o /etc/yum.repos.d/nvidia-container-toolkit.repo $REPO/rpm/nvidia-container-toolkit.repo
+		$YUM install -y nvidia-container-toolkit || abort "could not install the NVIDIA container toolkit"
+	fi
+fi
+
+command -v nvidia-smi >/dev/null 2>&1 || log "WARNING: nvidia-smi not found: the NVIDIA driver must be installed in this node"
+
+log "configuring $RUNTIME for using the NVIDIA runtime by default"
+nvidia-ctk runtime configure --runtime=$RUNTIME --set-as-default || abort "could not configure $RUNTIME"
+`
+
+// isGPUEnabled returns true if this is a GPU node
+func isGPUEnabled(d *schema.ResourceData) bool {
+	_, ok := d.GetOk("gpu")
+	return ok
+}
+
+// doInstallGPUToolkit installs the NVIDIA container toolkit and sets the NVIDIA
+// runtime handler as the default one in the container runtime
+// NOTE: the runtime is restarted later on, in doPrepareCRI()
+func doInstallGPUToolkit(d *schema.ResourceData) ssh.Action {
+	if !isGPUEnabled(d) {
+		return nil
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Installing the NVIDIA container toolkit..."),
+		ssh.DoExecScriptWithEnv([]byte(nvidiaToolkitScript), map[string]string{"RUNTIME": engine}),
+	}
+}
+
+// doLoadGPUDevicePlugin deploys the NVIDIA device plugin DaemonSet
+func doLoadGPUDevicePlugin(d *schema.ResourceData) ssh.Action {
+	if !isGPUEnabled(d) || !d.Get("gpu.0.device_plugin").(bool) {
+		return nil
+	}
+
+	manifest := ssh.NewManifest(d.Get("gpu.0.device_plugin_manifest").(string))
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the NVIDIA device plugin..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGPUActions(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if doInstallGPUToolkit(d) != nil || doLoadGPUDevicePlugin(d) != nil {
+		t.Fatalf("Error: no GPU actions expected without a 'gpu' block")
+	}
+
+	raw := map[string]interface{}{
+		"gpu": []interface{}{
+			map[string]interface{}{
+				"device_plugin": false,
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if doInstallGPUToolkit(d) == nil {
+		t.Fatalf("Error: the NVIDIA toolkit should be installed")
+	}
+	if doLoadGPUDevicePlugin(d) != nil {
+		t.Fatalf("Error: the device plugin should not be loaded")
+	}
+}
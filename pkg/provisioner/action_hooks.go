@@ -0,0 +1,71 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// hookPoints are the points where the user-defined hooks can be run
+var hookPoints = []string{"pre_setup", "post_init", "post_join"}
+
+// hook is a user-defined script
+type hook struct {
+	inline string
+	path   string
+}
+
+// getHooksFromResourceData returns the hooks that must be run at some point
+func getHooksFromResourceData(d *schema.ResourceData, on string) []hook {
+	res := []hook{}
+	for _, h := range d.Get("hook").([]interface{}) {
+		m := h.(map[string]interface{})
+		if m["on"].(string) != on {
+			continue
+		}
+		res = append(res, hook{inline: m["inline"].(string), path: m["path"].(string)})
+	}
+	return res
+}
+
+// doRunHooks runs the user-defined hooks for some point of the provisioning,
+// failing when any of them fails
+func doRunHooks(d *schema.ResourceData, on string) ssh.Action {
+	hooks := getHooksFromResourceData(d, on)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo(fmt.Sprintf("Running %d %s hook(s)...", len(hooks), on)),
+	}
+	for _, h := range hooks {
+		switch {
+		case len(h.inline) > 0 && len(h.path) > 0:
+			return ssh.ActionError(fmt.Sprintf("%s hook: only one of 'inline' or 'path' can be provided", on))
+		case len(h.inline) > 0:
+			actions = append(actions, ssh.DoExecScript([]byte("#!/bin/sh\n"+h.inline)))
+		case len(h.path) > 0:
+			actions = append(actions, ssh.DoExec(fmt.Sprintf("sh %s", h.path)))
+		default:
+			return ssh.ActionError(fmt.Sprintf("%s hook: no 'inline' or 'path' provided", on))
+		}
+	}
+	return actions
+}
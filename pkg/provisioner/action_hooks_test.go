@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestGetHooks(t *testing.T) {
+	raw := map[string]interface{}{
+		"hook": []interface{}{
+			map[string]interface{}{
+				"on":     "pre_setup",
+				"inline": "mount /dev/sdb /data",
+			},
+			map[string]interface{}{
+				"on":   "post_join",
+				"path": "/usr/local/bin/install-agent.sh",
+			},
+			map[string]interface{}{
+				"on":     "pre_setup",
+				"inline": "echo something",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	if hooks := getHooksFromResourceData(d, "pre_setup"); len(hooks) != 2 || hooks[1].inline != "echo something" {
+		t.Fatalf("Error: unexpected pre_setup hooks: %+v", hooks)
+	}
+	if hooks := getHooksFromResourceData(d, "post_join"); len(hooks) != 1 || hooks[0].path != "/usr/local/bin/install-agent.sh" {
+		t.Fatalf("Error: unexpected post_join hooks: %+v", hooks)
+	}
+	if doRunHooks(d, "post_init") != nil {
+		t.Fatalf("Error: no action expected for post_init")
+	}
+
+	// hooks with both an inline script and a path are invalid
+	raw = map[string]interface{}{
+		"hook": []interface{}{
+			map[string]interface{}{
+				"on":     "post_init",
+				"inline": "echo something",
+				"path":   "/tmp/script.sh",
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if !ssh.IsError(doRunHooks(d, "post_init")) {
+		t.Fatalf("Error: an error was expected for a hook with both 'inline' and 'path'")
+	}
+}
@@ -25,8 +25,12 @@ import (
 )
 
 // doKubeadmInit runs the `kubeadm init`
-func doKubeadmInit(d *schema.ResourceData) ssh.Action {
+func doKubeadmInit(d *schema.ResourceData, host string) ssh.Action {
 	extraArgs := []string{"--skip-token-print"}
+	if isCiliumKubeProxyReplacement(d) {
+		extraArgs = append(extraArgs, "--skip-phases=addon/kube-proxy")
+	}
+	extraArgs = append(extraArgs, getStaticPodPatchesArgs(d)...)
 
 	// get the join configuration
 	initConfig, _, err := common.InitConfigFromResourceData(d)
@@ -43,38 +47,86 @@ func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 	}
 
 	actions := ssh.ActionList{
-		// * if a "admin.conf" is there and the cluster is alive, do nothing
-		//   (just try to reload CNI, Helm and so)
-		// * if a partial setup is detected (ie, cluster is not alive but some manifests are there...)
-		//   try to reset the node
+		// * if the node has been initialized before, adopt it, reset it or fail (depending
+		//   on `on_existing`): it is adopted when a "admin.conf" is there and the cluster
+		//   is alive (and then we just try to reload CNI, Helm and so)
 		// * in any other case, do a regular "kubeadm init"
 		doDeleteLocalKubeconfig(d),
-		ssh.DoIfElse(
+		doOnExistingNode(
+			d,
 			checkAdminConfAlive(d),
 			ssh.ActionList{
-				ssh.DoMessageInfo("There is a 'admin.conf' in this master pointing to a live cluster: skipping any setup"),
-			},
-			ssh.ActionList{
+				doExposeControlPlaneMetrics(d),
+				doDetectCRISocket(d, "init"),
+				doAddHardwareLabels(d, "init"),
+				doAddTaints(d, "init"),
+				doAddCloudProviderID(d, "init"),
 				ssh.DoRetry(
-					ssh.Retry{Times: 3, Interval: 15 * time.Second},
+					getRetryFromResourceData(d, ssh.Retry{Times: 3, Interval: 15 * time.Second}),
 					ssh.ActionList{
 						doMaybeResetMaster(d, common.DefKubeadmInitConfPath),
 						doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
+						doCreateAPIServerCert(d, "init", host),
+						doUploadCloudConfig(d),
+						doUploadSchedulerConfig(d),
+						doUploadAdmissionConfig(d),
+						doUploadAuditConfig(d),
+						doUploadEgressSelectorConfig(d),
+						doUploadStaticPodPatches(d),
+						doCreateKubeVip(d, "init"),
 						ssh.DoMessageInfo("Initializing the cluster with 'kubadm init'..."),
 						doKubeadm(d, common.DefKubeadmInitConfPath, "init", extraArgs...),
 					},
 				),
+				doUploadControlPlaneCerts(d),
 			},
 		),
+		doCreateKonnectivityServer(d),
 		// we always download the kubeconfig and try to do a "kubeactl apply -f" of manifests
 		doDownloadKubeconfig(d),
+	}
+	return actions
+}
+
+// doLoadAddons loads the addons (and the extra manifests) in the cluster,
+// after the `kubeadm init`
+func doLoadAddons(d *schema.ResourceData, host string) ssh.Action {
+	return ssh.ActionList{
+		doLoadRBAC(d),
 		doLoadCNI(d),
+		doLoadKonnectivityAgent(d, host),
 		doLoadDashboard(d),
+		doLoadCoreDNSForwardZones(d),
+		doLoadNodeLocalDNS(d),
+		doLoadMetricsServer(d),
+		doLoadLocalPathProvisioner(d),
 		doLoadHelm(d),
+		doLoadMetalLBConfig(d),
+		doLoadCertManagerIssuer(d),
 		doLoadCloudProviderManager(d),
+		doLoadVSphereCSI(d),
 		doLoadExtraManifests(d),
 	}
-	return actions
+}
+
+// doUploadControlPlaneCerts uploads the (encrypted) control plane certificates
+// to the `kubeadm-certs` secret, so machines not managed by the provisioner
+// can `kubeadm join --control-plane --certificate-key`.
+// Note well: kubeadm removes this secret after two hours.
+func doUploadControlPlaneCerts(d *schema.ResourceData) ssh.Action {
+	key, ok := d.GetOk("config.certificate_key")
+	if !ok || len(key.(string)) == 0 {
+		return nil
+	}
+
+	return ssh.DoTry(
+		ssh.ActionList{
+			ssh.DoMessageInfo("Uploading the control plane certificates to the 'kubeadm-certs' secret"),
+			doExecKubeadmWithConfig(d, "init phase upload-certs", "",
+				"--upload-certs",
+				fmt.Sprintf("--certificate-key=%s", key.(string)),
+				fmt.Sprintf("--config=%s.bak", common.DefKubeadmInitConfPath)),
+		})
 }
 
 // doMaybeResetMaster maybe "reset"s the master with kubeadm if
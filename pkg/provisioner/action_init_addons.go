@@ -17,9 +17,12 @@ package provisioner
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
@@ -46,15 +49,195 @@ func doLoadDashboard(d *schema.ResourceData) ssh.Action {
 	}
 }
 
-// doLoadExtraManifests loads some extra manifests
-func doLoadExtraManifests(d *schema.ResourceData) ssh.Action {
-	manifestsOpt, ok := d.GetOk("manifests")
-	if !ok {
+// doLoadMetricsServer loads the metrics-server (if enabled), waiting until it is available
+func doLoadMetricsServer(d *schema.ResourceData) ssh.Action {
+	if !isConfigEnabled(d, "metrics_server_enabled") {
+		return nil
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Loading the metrics-server from %q", common.DefMetricsServerManifest),
+		doRemoteKubectlApply(d, []ssh.Manifest{{URL: common.DefMetricsServerManifest}}),
+	}
+
+	if isConfigEnabled(d, "metrics_server_insecure_tls") {
+		// the kubelets use self-signed certificates by default
+		hasFlag := fmt.Sprintf("%s --kubeconfig=%s -n kube-system get deployment metrics-server -o jsonpath='{.spec.template.spec.containers[0].args}' | grep -q kubelet-insecure-tls",
+			getKubectlFromResourceData(d), ssh.DefAdminKubeconfig)
+		patch := `'[{"op": "add", "path": "/spec/template/spec/containers/0/args/-", "value": "--kubelet-insecure-tls"}]'`
+		actions = append(actions,
+			ssh.DoIf(
+				ssh.CheckNot(ssh.CheckExec(hasFlag)),
+				doRemoteKubectl(d, "-n", "kube-system", "patch", "deployment", "metrics-server", "--type=json", "-p", patch)))
+	}
+
+	return append(actions,
+		ssh.DoMessageInfo("Waiting for the metrics-server to be available..."),
+		doRemoteKubectl(d, "-n", "kube-system", "wait", "--for=condition=Available", "deployment/metrics-server", "--timeout=5m"))
+}
+
+// doLoadNodeLocalDNS loads NodeLocal DNSCache (if enabled)
+func doLoadNodeLocalDNS(d *schema.ResourceData) ssh.Action {
+	if ip, ok := d.GetOk("config.node_local_dns_ip"); !ok || len(ip.(string)) == 0 {
+		return nil
+	}
+
+	zones, err := getDNSForwardZonesFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the DNS forward zones: %s", err))
+	}
+
+	// the forward zones must be resolved by CoreDNS (instead of the upstream servers)
+	config := map[string]interface{}{}
+	for k, v := range common.GetProvisionerConfig(d) {
+		config[k] = v
+	}
+	config["dns_forward_zones"] = zones
+
+	manifest := ssh.Manifest{Inline: assets.NodeLocalDNSManifestCode}
+	if err := manifest.ReplaceConfig(config); err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not replace variables in the NodeLocal DNSCache manifest: %s", err))
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading NodeLocal DNSCache"),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+	}
+}
+
+// doLoadLocalPathProvisioner loads the local-path provisioner (if enabled),
+// maybe marking its StorageClass as the default one
+func doLoadLocalPathProvisioner(d *schema.ResourceData) ssh.Action {
+	if !isConfigEnabled(d, "local_path_enabled") {
+		return nil
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Loading the local-path provisioner from %q", common.DefLocalPathManifest),
+		doRemoteKubectlApply(d, []ssh.Manifest{{URL: common.DefLocalPathManifest}}),
+	}
+
+	if isConfigEnabled(d, "local_path_default") {
+		patch := `'{"metadata": {"annotations": {"storageclass.kubernetes.io/is-default-class": "true"}}}'`
+		actions = append(actions,
+			ssh.DoMessageInfo("Setting %q as the default StorageClass", common.DefLocalPathStorageClass),
+			doRemoteKubectl(d, "patch", "storageclass", common.DefLocalPathStorageClass, "-p", patch))
+	}
+
+	return actions
+}
+
+// doLoadAddonConfig applies some addon configuration (once the addon has been
+// installed with Helm) from the manifest in the `key` config
+func doLoadAddonConfig(d *schema.ResourceData, key string, addon string) ssh.Action {
+	opt, ok := d.GetOk("config." + key)
+	if !ok || len(opt.(string)) == 0 {
+		return nil
+	}
+	manifest, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the %s configuration: %s", addon, err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Configuring %s...", addon),
+		// the webhooks of the addon could take some time to be ready
+		ssh.DoRetry(
+			ssh.Retry{Times: 10, Interval: 10 * time.Second},
+			doRemoteKubectlApply(d, []ssh.Manifest{{Inline: string(manifest)}})),
+	}
+}
+
+// doLoadMetalLBConfig applies the MetalLB configuration (once MetalLB has
+// been installed with Helm)
+func doLoadMetalLBConfig(d *schema.ResourceData) ssh.Action {
+	return doLoadAddonConfig(d, "metallb_manifest", "MetalLB")
+}
+
+// doLoadCertManagerIssuer creates the cert-manager ClusterIssuer (once
+// cert-manager has been installed with Helm)
+func doLoadCertManagerIssuer(d *schema.ResourceData) ssh.Action {
+	return doLoadAddonConfig(d, "cert_manager_issuer", "cert-manager")
+}
+
+// doLoadRBAC creates the RBAC objects (ClusterRoles and bindings) declared in the
+// resource, right after the initialization of the cluster
+func doLoadRBAC(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.rbac_manifest")
+	if !ok || len(opt.(string)) == 0 {
 		return nil
 	}
+	manifest, err := common.FromTerraformSafeString(opt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the RBAC manifest: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Creating the RBAC objects..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: string(manifest)}}),
+	}
+}
+
+// getClusterManifests returns the manifests declared in the `kubeadm` resource,
+// with the cluster facts (ie, `{{.dns_domain}}`) replaced
+func getClusterManifests(d *schema.ResourceData) ([]ssh.Manifest, error) {
+	opt, ok := d.GetOk("config.manifests")
+	if !ok || len(opt.(string)) == 0 {
+		return nil, nil
+	}
+	contents, err := common.ManifestsFromTerraformSafeString(opt.(string))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the manifests: %s", err)
+	}
+
+	config := common.GetProvisionerConfig(d)
 	manifests := []ssh.Manifest{}
-	for _, v := range manifestsOpt.([]interface{}) {
-		manifests = append(manifests, ssh.NewManifest(v.(string)))
+	for _, c := range contents {
+		manifest := ssh.Manifest{Inline: c}
+		if strings.HasPrefix(c, "http://") || strings.HasPrefix(c, "https://") {
+			manifest = ssh.Manifest{URL: c}
+		}
+		if err := manifest.ReplaceConfig(config); err != nil {
+			return nil, fmt.Errorf("could not replace variables in manifest: %s", err)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// doLoadClusterManifests (re)applies the manifests declared in the `kubeadm` resource,
+// so the changes in them are applied in the "reconfigure" phase
+func doLoadClusterManifests(d *schema.ResourceData) ssh.Action {
+	manifests, err := getClusterManifests(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if len(manifests) == 0 {
+		return nil
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo(fmt.Sprintf("Applying %d manifests", len(manifests))),
+		doRemoteKubectlApply(d, manifests),
+	}
+}
+
+// doLoadExtraManifests loads the manifests declared in the `kubeadm` resource
+// as well as the extra manifests in the provisioner
+func doLoadExtraManifests(d *schema.ResourceData) ssh.Action {
+	manifests, err := getClusterManifests(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	manifestsOpt, ok := d.GetOk("manifests")
+	if ok {
+		for _, v := range manifestsOpt.([]interface{}) {
+			manifests = append(manifests, ssh.NewManifest(v.(string)))
+		}
+	}
+	if len(manifests) == 0 {
+		if !ok {
+			return nil
+		}
+		return ssh.DoMessageWarn("Could not find valid manifests to load")
 	}
 	if len(manifests) == 0 {
 		return ssh.DoMessageWarn("Could not find valid manifests to load")
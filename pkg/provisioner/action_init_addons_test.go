@@ -0,0 +1,66 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetClusterManifests(t *testing.T) {
+	s, err := common.ManifestsToTerraformSafeString([]string{
+		"https://example.com/{{.kube_version}}/manifest.yaml",
+		"kind: ConfigMap\ndata:\n  domain: {{.dns_domain}}\n",
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"kube_version": "v1.27.3",
+			"dns_domain":   "cluster.local",
+			"config_path":  "/tmp/kubeconfig",
+			"manifests":    s,
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	manifests, err := getClusterManifests(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(manifests) != 2 {
+		t.Fatalf("Error: unexpected number of manifests: %d", len(manifests))
+	}
+	if manifests[0].URL != "https://example.com/v1.27.3/manifest.yaml" {
+		t.Fatalf("Error: unexpected URL: %q", manifests[0].URL)
+	}
+	if manifests[1].Inline != "kind: ConfigMap\ndata:\n  domain: cluster.local\n" {
+		t.Fatalf("Error: unexpected manifest: %q", manifests[1].Inline)
+	}
+	if action := doLoadClusterManifests(d); action == nil || ssh.IsError(action) {
+		t.Fatalf("Error: unexpected action for applying the manifests: %v", action)
+	}
+
+	// nothing is applied when there are no manifests
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if action := doLoadClusterManifests(d); action != nil {
+		t.Fatalf("Error: unexpected action without manifests: %v", action)
+	}
+}
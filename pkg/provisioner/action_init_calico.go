@@ -0,0 +1,60 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// namespace where the Tigera operator creates the Calico components
+	calicoNamespace = "calico-system"
+
+	// time we wait for the calico-node DaemonSet to be ready
+	calicoReadyTimeout = "5m"
+)
+
+// doLoadCalicoOperator loads the Tigera operator, waiting until the
+// Installation CRD is established (so the Calico manifest can be applied)
+func doLoadCalicoOperator(d *schema.ResourceData) ssh.Action {
+	version := getCNIVersionFromResourceData(d, "calico")
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the Tigera operator for Calico %s", version),
+		// the operator CRDs are too big for the annotations added by a client-side "apply"
+		doRemoteKubectl(d, "apply", "--server-side", "--force-conflicts", "-f",
+			fmt.Sprintf(common.DefCalicoOperatorManifestFormat, version)),
+		doRemoteKubectl(d, "wait", "--for=condition=established", "--timeout=60s",
+			"crd/installations.operator.tigera.io"),
+	}
+}
+
+// doWaitCalico waits until the calico-node DaemonSet (created by the
+// operator after the Installation is applied) is ready
+func doWaitCalico(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Waiting for the calico-node DaemonSet to be ready..."),
+		ssh.DoRetry(
+			ssh.Retry{Times: 30, Interval: 10 * time.Second},
+			doRemoteKubectl(d, "get", "daemonset", "calico-node", "-n", calicoNamespace)),
+		doRemoteKubectl(d, "rollout", "status", "daemonset/calico-node", "-n", calicoNamespace,
+			"--timeout="+calicoReadyTimeout),
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestCalicoManifest(t *testing.T) {
+	config := map[string]interface{}{
+		"cni_pod_cidr":         "10.10.0.0/16",
+		"calico_encapsulation": "VXLAN",
+	}
+
+	manifest := common.CNIPluginsManifestsTemplates["calico"]
+	if err := manifest.ReplaceConfig(config); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	for _, expected := range []string{"cidr: 10.10.0.0/16", "encapsulation: VXLAN"} {
+		if !strings.Contains(manifest.Inline, expected) {
+			t.Fatalf("Error: %q not found in the Calico manifest:\n%s", expected, manifest.Inline)
+		}
+	}
+	if strings.Contains(manifest.Inline, "mtu:") {
+		t.Fatalf("Error: no MTU should be set in the Calico manifest:\n%s", manifest.Inline)
+	}
+
+	config["calico_mtu"] = "1400"
+	manifest = common.CNIPluginsManifestsTemplates["calico"]
+	if err := manifest.ReplaceConfig(config); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if !strings.Contains(manifest.Inline, "    mtu: 1400\n    ipPools:") {
+		t.Fatalf("Error: wrong MTU in the Calico manifest:\n%s", manifest.Inline)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// ciliumInstallScript is a script that downloads the cilium CLI (when not present)
+// and installs Cilium, waiting until it is ready
+const ciliumInstallScript = `#!/bin/sh
+set -e
+export KUBECONFIG=/etc/kubernetes/admin.conf
+
+if ! command -v cilium >/dev/null 2>&1 ; then
+	ARCH=amd64
+	[ "$(uname -m)" = "aarch64" ] && ARCH=arm64
+	curl -L --fail --silent --show-error -o /tmp/cilium-cli.tar.gz \
+		https://github.com/cilium/cilium-cli/releases/download/%s/cilium-linux-$ARCH.tar.gz
+	tar -xzf /tmp/cilium-cli.tar.gz -C /usr/local/bin
+	rm -f /tmp/cilium-cli.tar.gz
+fi
+
+API_HOST="%s"
+[ -n "$API_HOST" ] || API_HOST="$(hostname -I | awk '{ print $1 }')"
+
+if kubectl -n kube-system get daemonset cilium >/dev/null 2>&1 ; then
+	echo "Cilium is already installed"
+else
+	cilium install %s --set k8sServiceHost=$API_HOST
+fi
+cilium status --wait
+`
+
+// ciliumOptions are the options for installing Cilium
+type ciliumOptions struct {
+	version              string
+	mtu                  string
+	kubeProxyReplacement bool
+	apiPort              string
+	tunnel               string
+	podCIDR              string
+	hubble               bool
+}
+
+// getCiliumInstallArgs returns the arguments for `cilium install`
+// (except the API server host, that could be detected in the node)
+func getCiliumInstallArgs(opts ciliumOptions) []string {
+	args := []string{
+		"--version", opts.version,
+		// use the pods CIDRs assigned by the controller manager
+		"--set", "ipam.mode=kubernetes",
+		"--set", fmt.Sprintf("kubeProxyReplacement=%t", opts.kubeProxyReplacement),
+		"--set", "k8sServicePort=" + opts.apiPort,
+	}
+
+	if opts.tunnel == "disabled" {
+		args = append(args,
+			"--set", "routingMode=native",
+			"--set", "ipv4NativeRoutingCIDR="+opts.podCIDR,
+			"--set", "autoDirectNodeRoutes=true")
+	} else {
+		args = append(args,
+			"--set", "routingMode=tunnel",
+			"--set", "tunnelProtocol="+opts.tunnel)
+	}
+
+	if len(opts.mtu) > 0 {
+		args = append(args, "--set", "mtu="+opts.mtu)
+	}
+
+	if opts.hubble {
+		args = append(args,
+			"--set", "hubble.enabled=true",
+			"--set", "hubble.relay.enabled=true")
+	}
+	return args
+}
+
+// isCiliumKubeProxyReplacement returns true if Cilium will be used as a replacement of kube-proxy
+func isCiliumKubeProxyReplacement(d *schema.ResourceData) bool {
+	return d.Get("config.cni_plugin").(string) == "cilium" && isConfigEnabled(d, "cilium_kube_proxy_replacement")
+}
+
+// doLoadCilium installs Cilium with the cilium CLI
+func doLoadCilium(d *schema.ResourceData) ssh.Action {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for Cilium: %s", err))
+	}
+
+	// Cilium must talk to the API server directly when kube-proxy is not present
+	apiHost := initConfig.LocalAPIEndpoint.AdvertiseAddress
+	apiPort := strconv.Itoa(int(initConfig.LocalAPIEndpoint.BindPort))
+	if len(initConfig.ControlPlaneEndpoint) > 0 {
+		host, port, err := net.SplitHostPort(initConfig.ControlPlaneEndpoint)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not parse the control plane endpoint %q: %s", initConfig.ControlPlaneEndpoint, err))
+		}
+		apiHost, apiPort = host, port
+	}
+	if apiPort == "0" {
+		apiPort = strconv.Itoa(common.DefAPIServerPort)
+	}
+
+	tunnel := common.DefCiliumTunnel
+	if opt, ok := d.GetOk("config.cilium_tunnel"); ok && len(opt.(string)) > 0 {
+		tunnel = opt.(string)
+	}
+
+	opts := ciliumOptions{
+		version:              getCNIVersionFromResourceData(d, "cilium"),
+		mtu:                  d.Get("config.cni_mtu").(string),
+		kubeProxyReplacement: isCiliumKubeProxyReplacement(d),
+		apiPort:              apiPort,
+		tunnel:               tunnel,
+		podCIDR:              d.Get("config.cni_pod_cidr").(string),
+		hubble:               isConfigEnabled(d, "cilium_hubble"),
+	}
+
+	script := fmt.Sprintf(ciliumInstallScript, common.DefCiliumCLIVersion, apiHost,
+		strings.Join(getCiliumInstallArgs(opts), " "))
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Installing Cilium %s", opts.version),
+		ssh.DoExecScript([]byte(script)),
+	}
+}
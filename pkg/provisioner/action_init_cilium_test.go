@@ -0,0 +1,50 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetCiliumInstallArgs(t *testing.T) {
+	args := strings.Join(getCiliumInstallArgs(ciliumOptions{
+		version:              "1.15.0",
+		mtu:                  "1400",
+		kubeProxyReplacement: true,
+		apiPort:              "6443",
+		tunnel:               "geneve",
+		hubble:               true,
+	}), " ")
+	for _, expected := range []string{"--version 1.15.0", "mtu=1400", "kubeProxyReplacement=true", "k8sServicePort=6443", "tunnelProtocol=geneve", "hubble.enabled=true"} {
+		if !strings.Contains(args, expected) {
+			t.Fatalf("Error: %q not found in the cilium args: %s", expected, args)
+		}
+	}
+
+	args = strings.Join(getCiliumInstallArgs(ciliumOptions{
+		apiPort: "6443",
+		tunnel:  "disabled",
+		podCIDR: "10.244.0.0/16",
+	}), " ")
+	for _, expected := range []string{"kubeProxyReplacement=false", "routingMode=native", "ipv4NativeRoutingCIDR=10.244.0.0/16"} {
+		if !strings.Contains(args, expected) {
+			t.Fatalf("Error: %q not found in the cilium args: %s", expected, args)
+		}
+	}
+	if strings.Contains(args, "tunnelProtocol") || strings.Contains(args, "hubble") {
+		t.Fatalf("Error: unexpected args for native routing without Hubble: %s", args)
+	}
+}
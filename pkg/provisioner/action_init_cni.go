@@ -24,10 +24,19 @@ import (
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+// getCNIVersionFromResourceData returns the version of the CNI plugin (or the default one)
+func getCNIVersionFromResourceData(d *schema.ResourceData, plugin string) string {
+	if opt, ok := d.GetOk("config.cni_version"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.DefCNIVersions[strings.ToLower(plugin)]
+}
+
 // doLoadCNI loads the CNI driver
 func doLoadCNI(d *schema.ResourceData) ssh.Action {
 	manifest := ssh.Manifest{}
 	var message ssh.Action
+	var pre, post ssh.Action
 
 	if cniPluginManifestOpt, ok := d.GetOk("config.cni_plugin_manifest"); ok {
 		cniPluginManifest := strings.TrimSpace(cniPluginManifestOpt.(string))
@@ -41,6 +50,9 @@ func doLoadCNI(d *schema.ResourceData) ssh.Action {
 	} else {
 		if cniPluginOpt, ok := d.GetOk("config.cni_plugin"); ok {
 			cniPlugin := strings.TrimSpace(strings.ToLower(cniPluginOpt.(string)))
+			if cniPlugin == "cilium" {
+				return doLoadCilium(d)
+			}
 			if len(cniPlugin) > 0 {
 				ssh.Debug("verifying CNI plugin: %s", cniPlugin)
 				if m, ok := common.CNIPluginsManifestsTemplates[cniPlugin]; ok {
@@ -50,6 +62,10 @@ func doLoadCNI(d *schema.ResourceData) ssh.Action {
 					panic("unknown CNI driver: should have been caught at the validation stage")
 				}
 				message = ssh.DoMessageInfo(fmt.Sprintf("Loading CNI plugin %q", cniPlugin))
+				if cniPlugin == "calico" {
+					// our Calico manifest is just the configuration for the operator
+					pre, post = doLoadCalicoOperator(d), doWaitCalico(d)
+				}
 			}
 		}
 	}
@@ -58,13 +74,21 @@ func doLoadCNI(d *schema.ResourceData) ssh.Action {
 		return ssh.DoMessageWarn("no CNI driver is going to be loaded")
 	}
 
-	err := manifest.ReplaceConfig(common.GetProvisionerConfig(d))
+	// configurations created by previous versions could have no CNI version
+	config := common.GetProvisionerConfig(d)
+	if v, ok := config["cni_version"]; !ok || len(v.(string)) == 0 {
+		config["cni_version"] = getCNIVersionFromResourceData(d, d.Get("config.cni_plugin").(string))
+	}
+
+	err := manifest.ReplaceConfig(config)
 	if err != nil {
 		return ssh.ActionError(fmt.Sprintf("could not replace variables in manifest: %s", err))
 	}
 
 	return ssh.ActionList{
 		message,
+		pre,
 		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+		post,
 	}
 }
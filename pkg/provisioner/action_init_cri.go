@@ -15,11 +15,106 @@
 package provisioner
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+// criSocketDetectScript prints a "socket=<path>" line for every socket in $SOCKETS
+// where a CRI runtime is listening (checked with crictl, when it is available)
+const criSocketDetectScript = `
+for s in $SOCKETS ; do
+	[ -S "$s" ] || continue
+	if command -v crictl >/dev/null 2>&1 ; then
+		crictl --runtime-endpoint "unix://$s" version >/dev/null 2>&1 || continue
+	fi
+	echo "socket=$s"
+done
+exit 0
+`
+
+// getCRISocketCandidates returns the CRI sockets to probe in the node: the
+// candidates for the `engine` first, and then the sockets of any other engine
+func getCRISocketCandidates(engine string) []string {
+	res := append([]string{}, common.DefCriSocketCandidates[engine]...)
+	for _, e := range []string{"containerd", "crio", "docker"} {
+		if e != engine {
+			res = append(res, common.DefCriSocketCandidates[e]...)
+		}
+	}
+	return res
+}
+
+// parseCRISocket parses a line printed by the criSocketDetectScript
+func parseCRISocket(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "socket=") {
+		return ""
+	}
+	return strings.TrimPrefix(line, "socket=")
+}
+
+// setCRISocket sets the CRI socket in the node registration options,
+// as well as the corresponding kubelet args
+func setCRISocket(nr *kubeadmapi.NodeRegistrationOptions, socket string) {
+	nr.CRISocket = socket
+	if nr.KubeletExtraArgs == nil {
+		nr.KubeletExtraArgs = map[string]string{}
+	}
+	nr.KubeletExtraArgs["container-runtime-endpoint"] = fmt.Sprintf("unix://%s", socket)
+	if socket != common.DefCriSocket["docker"] {
+		nr.KubeletExtraArgs["container-runtime"] = "remote"
+	}
+}
+
+// doDetectCRISocket probes the node for a listening CRI socket and uses it in
+// the `command` ("init" or "join") configuration. The CRI socket set by the
+// user in the `runtime` is used as an override, and no detection is done then.
+func doDetectCRISocket(d *schema.ResourceData, command string) ssh.Action {
+	if opt, ok := d.GetOk("config.cri_socket"); ok && len(opt.(string)) > 0 {
+		return ssh.DoMessageInfo("Using the CRI socket %s", opt.(string))
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		detected := []string{}
+		env := map[string]string{"SOCKETS": strings.Join(getCRISocketCandidates(engine), " ")}
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExecScriptWithEnv([]byte(criSocketDetectScript), env),
+			func(s string) {
+				if socket := parseCRISocket(s); len(socket) > 0 {
+					ssh.GetLoggerFromContext(ctx).Debug("CRI socket detected: %s", socket)
+					detected = append(detected, socket)
+				}
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if len(detected) == 0 {
+			return ssh.DoMessageWarn("No CRI socket detected in the node: using the default socket for %s", engine)
+		}
+
+		socket := detected[0]
+		if err := updateNodeRegistration(d, command, func(nr *kubeadmapi.NodeRegistrationOptions) {
+			setCRISocket(nr, socket)
+		}); err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return ssh.DoMessageInfo("Using the CRI socket %s detected in the node", socket)
+	})
+}
+
 // doPrepareCRI preparse the CRI in the target node
 func doPrepareCRI() ssh.Action {
 	return ssh.ActionList{
@@ -31,5 +126,11 @@ func doPrepareCRI() ssh.Action {
 		ssh.DoIf(
 			ssh.CheckServiceExists("docker.service"),
 			ssh.DoRestartService("docker.service")),
+		ssh.DoIf(
+			ssh.CheckServiceExists("cri-docker.service"),
+			ssh.DoRestartService("cri-docker.service")),
+		ssh.DoIf(
+			ssh.CheckServiceExists("containerd.service"),
+			ssh.DoRestartService("containerd.service")),
 	}
 }
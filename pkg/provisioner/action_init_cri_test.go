@@ -0,0 +1,93 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetCRISocketCandidates(t *testing.T) {
+	candidates := getCRISocketCandidates("docker")
+	if candidates[0] != "/var/run/cri-dockerd.sock" {
+		t.Fatalf("Error: the engine sockets should be probed first: %v", candidates)
+	}
+	total := 0
+	for _, sockets := range common.DefCriSocketCandidates {
+		total += len(sockets)
+	}
+	if len(candidates) != total {
+		t.Fatalf("Error: unexpected number of candidates: %v", candidates)
+	}
+}
+
+func TestParseCRISocket(t *testing.T) {
+	for line, expected := range map[string]string{
+		"socket=/var/run/crio/crio.sock\r":         "/var/run/crio/crio.sock",
+		"  socket=/run/containerd/containerd.sock": "/run/containerd/containerd.sock",
+		"Removing /tmp/something":                  "",
+	} {
+		if socket := parseCRISocket(line); socket != expected {
+			t.Fatalf("Error: unexpected socket for %q: %q", line, socket)
+		}
+	}
+}
+
+func TestUpdateNodeRegistrationCRISocket(t *testing.T) {
+	joinConfig, err := common.NewJoinConfig(common.ClusterSpec{
+		Token:   "82eb2m.999999idy9l74yha",
+		Runtime: common.RuntimeSpec{Engine: "docker"},
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"join": common.ToTerraformSafeString(joinConfigBytes),
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	socket := "/var/run/cri-dockerd.sock"
+	if err := updateNodeRegistration(d, "join", func(nr *kubeadmapi.NodeRegistrationOptions) {
+		setCRISocket(nr, socket)
+	}); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	joinConfig, _, err = common.JoinConfigFromResourceData(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if joinConfig.NodeRegistration.CRISocket != socket {
+		t.Fatalf("Error: unexpected CRI socket: %q", joinConfig.NodeRegistration.CRISocket)
+	}
+	args := joinConfig.NodeRegistration.KubeletExtraArgs
+	if args["container-runtime-endpoint"] != "unix://"+socket || args["container-runtime"] != "remote" {
+		t.Fatalf("Error: unexpected kubelet args: %v", args)
+	}
+	if args["network-plugin"] != "cni" {
+		t.Fatalf("Error: the other kubelet args have been lost: %v", args)
+	}
+}
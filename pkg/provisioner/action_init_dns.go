@@ -0,0 +1,123 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	corefileForwardZonesBegin = "# BEGIN forward zones (managed by terraform-provider-kubeadm)"
+	corefileForwardZonesEnd   = "# END forward zones"
+)
+
+// corefileForwardZonesScript replaces the forward zones in the CoreDNS Corefile
+// created by kubeadm (keeping everything else), so CoreDNS reloads it
+const corefileForwardZonesScript = `#!/bin/sh
+KUBECTL="%s --kubeconfig=%s"
+COREFILE=$(mktemp)
+trap "rm -f $COREFILE" EXIT
+
+$KUBECTL -n kube-system get configmap coredns -o jsonpath='{.data.Corefile}' > $COREFILE || exit 1
+sed -i -e '/^%s/,/^%s/d' $COREFILE
+[ -n "$(tail -c1 $COREFILE)" ] && echo >> $COREFILE
+cat <<'EOF' >> $COREFILE
+%s
+%s%s
+EOF
+$KUBECTL -n kube-system create configmap coredns --from-file=Corefile=$COREFILE --dry-run -o yaml | \
+	$KUBECTL replace -f -
+`
+
+// getDNSForwardZonesFromResourceData returns the DNS zones forwarded to some specific servers
+func getDNSForwardZonesFromResourceData(d *schema.ResourceData) ([]common.DNSForwardZoneSpec, error) {
+	opt, ok := d.GetOk("config.dns_forward_zones")
+	if !ok || len(opt.(string)) == 0 {
+		return []common.DNSForwardZoneSpec{}, nil
+	}
+	return common.DNSForwardZonesFromTerraformSafeString(opt.(string))
+}
+
+// doLoadCoreDNSForwardZones adds the forward zones to the CoreDNS configuration
+func doLoadCoreDNSForwardZones(d *schema.ResourceData) ssh.Action {
+	zones, err := getDNSForwardZonesFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the DNS forward zones: %s", err))
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+
+	script := fmt.Sprintf(corefileForwardZonesScript,
+		getKubectlFromResourceData(d), ssh.DefAdminKubeconfig,
+		corefileForwardZonesBegin, corefileForwardZonesEnd,
+		corefileForwardZonesBegin, common.NewCorefileForwardZones(zones), corefileForwardZonesEnd)
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Configuring the DNS forward zones in CoreDNS..."),
+		ssh.DoExecScript([]byte(script)),
+	}
+}
+
+// corednsImageScript sets the image in the CoreDNS Deployment (keeping the current
+// tag when no version is provided), so the CoreDNS pods are rolled out with it
+const corednsImageScript = `#!/bin/sh
+KUBECTL="%s --kubeconfig=%s"
+REPO=%s
+TAG=%s
+
+CURRENT=$($KUBECTL -n kube-system get deployment coredns -o jsonpath='{.spec.template.spec.containers[0].image}') || exit 1
+[ -n "$TAG" ] || TAG=${CURRENT##*:}
+[ "$CURRENT" = "$REPO/coredns:$TAG" ] && { echo ">>> CoreDNS is already using $CURRENT" ; exit 0 ; }
+echo ">>> updating the CoreDNS image: $CURRENT -> $REPO/coredns:$TAG"
+$KUBECTL -n kube-system set image deployment/coredns coredns=$REPO/coredns:$TAG
+`
+
+// getCoreDNSImageScript returns the script for updating the CoreDNS image
+func getCoreDNSImageScript(kubectl, kubeconfig, repo, tag string) []byte {
+	return []byte(fmt.Sprintf(corednsImageScript, kubectl, kubeconfig, ssh.ShellQuote(repo), ssh.ShellQuote(tag)))
+}
+
+// doUpdateCoreDNSImage updates the image in the CoreDNS Deployment when a custom
+// CoreDNS image has been set in the `images`, as kubeadm only uses it when
+// deploying CoreDNS in `kubeadm init`
+func doUpdateCoreDNSImage(d *schema.ResourceData) ssh.Action {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for init'ing: %s", err))
+	}
+	if len(initConfig.DNS.ImageRepository) == 0 && len(initConfig.DNS.ImageTag) == 0 {
+		return nil
+	}
+
+	repo := initConfig.DNS.ImageRepository
+	if len(repo) == 0 {
+		repo = initConfig.ImageRepository
+	}
+	if len(repo) == 0 {
+		repo = common.DefImagesRepository
+	}
+	script := getCoreDNSImageScript(getKubectlFromResourceData(d), ssh.DefAdminKubeconfig, repo, initConfig.DNS.ImageTag)
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Updating the CoreDNS image..."),
+		ssh.DoExecScript(script),
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestUploadResolvConf(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"dns_upstream": " 8.8.8.8 8.8.4.4",
+			"dns_search":   "corp.example.com example.com",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	if res := doUploadResolvConf(d).Apply(ctx); ssh.IsError(res) {
+		t.Fatalf("Error: %s", res)
+	}
+	expected := "nameserver 8.8.8.8\nnameserver 8.8.4.4\nsearch corp.example.com example.com\n"
+	found := false
+	for _, contents := range *uploads {
+		if contents == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Error: the resolv.conf was not uploaded: %v", *uploads)
+	}
+}
+
+func TestLoadNodeLocalDNSForwardZones(t *testing.T) {
+	zones, err := common.DNSForwardZonesToTerraformSafeString([]common.DNSForwardZoneSpec{
+		{Zone: "corp.example.com", Servers: []string{"10.0.0.53"}},
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	kubeconfig, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(kubeconfig.Name())
+	if _, err := kubeconfig.WriteString("apiVersion: v1\nkind: Config\n"); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"config_path":       kubeconfig.Name(),
+			"dns_domain":        "cluster.local",
+			"cluster_dns_ip":    "10.96.0.10",
+			"node_local_dns_ip": "169.254.20.10",
+			"dns_forward_zones": zones,
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	if res := doLoadNodeLocalDNS(d).Apply(ctx); ssh.IsError(res) {
+		t.Fatalf("Error: %s", res)
+	}
+	found := false
+	for _, contents := range *uploads {
+		if strings.Contains(contents, "corp.example.com:53 {") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Error: the forward zone was not added to the NodeLocal DNSCache configuration: %v", *uploads)
+	}
+}
+
+func TestGetCoreDNSImageScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a fake kubectl that reports the current image and records the image set
+	kubectl := filepath.Join(dir, "kubectl")
+	record := filepath.Join(dir, "record")
+	fake := "#!/bin/sh\ncase \"$*\" in\n*\" get \"*) echo k8s.gcr.io/coredns:1.3.1 ;;\n*) echo \"$@\" > " + record + " ;;\nesac\n"
+	if err := ioutil.WriteFile(kubectl, []byte(fake), 0755); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	tests := []struct {
+		repo     string
+		tag      string
+		expected string
+	}{
+		{repo: "registry.example.com", tag: "1.6.2", expected: "coredns=registry.example.com/coredns:1.6.2"},
+		{repo: "registry.example.com", tag: "", expected: "coredns=registry.example.com/coredns:1.3.1"},
+		{repo: "k8s.gcr.io", tag: "1.3.1", expected: ""},
+	}
+	for _, test := range tests {
+		os.Remove(record)
+		code := getCoreDNSImageScript(kubectl, "/etc/kubernetes/admin.conf", test.repo, test.tag)
+		if out, err := exec.Command("sh", "-c", string(code)).CombinedOutput(); err != nil {
+			t.Fatalf("Error: the script failed: %s\n%s\n%s", err, out, code)
+		}
+		res, _ := ioutil.ReadFile(record)
+		if !strings.Contains(string(res), test.expected) || (test.expected == "" && len(res) > 0) {
+			t.Fatalf("Error: unexpected kubectl invocation for %q:%q: %q", test.repo, test.tag, res)
+		}
+	}
+}
@@ -16,59 +16,114 @@ package provisioner
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
-	"k8s.io/helm/cmd/helm/installer"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
-const (
-	defHelmReplicas  = 1
-	defHelmNamespace = "kube-system"
-	// defHelmNodeselector = "node-role.kubernetes.io/master="
-	defHelmNodeselector = ""
-)
+// helmInstallScript is a script that installs the helm binary (when the
+// same version is not already installed)
+const helmInstallScript = `#!/bin/sh
+set -e
+VERSION="%s"
+if ! helm version --short 2>/dev/null | grep -q "^$VERSION" ; then
+	case "$(uname -m)" in
+	aarch64) ARCH=arm64 ;;
+	armv7l)  ARCH=arm ;;
+	ppc64le) ARCH=ppc64le ;;
+	s390x)   ARCH=s390x ;;
+	*)       ARCH=amd64 ;;
+	esac
+	curl -L --fail --silent --show-error -o /tmp/helm.tar.gz https://get.helm.sh/helm-$VERSION-linux-$ARCH.tar.gz
+	tar -xzf /tmp/helm.tar.gz -C /tmp linux-$ARCH/helm
+	mv /tmp/linux-$ARCH/helm /usr/local/bin/helm
+	rm -rf /tmp/helm.tar.gz /tmp/linux-$ARCH
+fi
+helm version --short
+`
 
-// doLoadHelm loads Helm (if enabled)
-func doLoadHelm(d *schema.ResourceData) ssh.Action {
-	opt, ok := d.GetOk("config.helm_enabled")
-	if !ok {
-		return ssh.DoMessageWarn("Helm will not be loaded")
+// helmKubeconfig is the kubeconfig used by helm in the first master
+const helmKubeconfig = "/etc/kubernetes/admin.conf"
+
+// getHelmChartsFromResourceData returns the charts that must be installed
+func getHelmChartsFromResourceData(d *schema.ResourceData) ([]common.HelmChartSpec, error) {
+	opt, ok := d.GetOk("config.helm_charts")
+	if !ok || len(opt.(string)) == 0 {
+		return []common.HelmChartSpec{}, nil
 	}
-	enabled, err := strconv.ParseBool(opt.(string))
-	if err != nil {
-		return ssh.ActionError("could not parse helm_enabled in provisioner")
+	return common.HelmChartsFromTerraformSafeString(opt.(string))
+}
+
+// getHelmChartArgs returns the `helm` arguments for installing (or upgrading) a chart
+func getHelmChartArgs(chart common.HelmChartSpec, valuesFile string) []string {
+	namespace := chart.Namespace
+	if len(namespace) == 0 {
+		namespace = common.DefHelmNamespace
 	}
-	if !enabled {
-		return ssh.DoMessageWarn("Helm will not be loaded")
+
+	args := []string{"upgrade", "--install", chart.Name, chart.Chart,
+		"--namespace", namespace, "--create-namespace",
+		"--kubeconfig", helmKubeconfig, "--wait"}
+	if len(chart.Repo) > 0 {
+		args = append(args, "--repo", chart.Repo)
+	}
+	if len(chart.Version) > 0 {
+		args = append(args, "--version", chart.Version)
+	}
+	if len(valuesFile) > 0 {
+		args = append(args, "--values", valuesFile)
 	}
+	return args
+}
 
-	opts := installer.Options{
-		Namespace:                    defHelmNamespace,
-		AutoMountServiceAccountToken: true,
-		EnableHostNetwork:            false,
-		NodeSelectors:                defHelmNodeselector,
-		UseCanary:                    false,
-		Replicas:                     defHelmReplicas,
-		// TODO: we shoud have options for enabling TLS and so...
+// doInstallHelmChart installs a chart with Helm
+func doInstallHelmChart(chart common.HelmChartSpec) ssh.Action {
+	if len(chart.Values) == 0 {
+		return ssh.DoExec("helm " + strings.Join(getHelmChartArgs(chart, ""), " "))
 	}
-	manifests, err := installer.TillerManifests(&opts)
+
+	valuesFile, err := ssh.GetTempFilename()
 	if err != nil {
-		return ssh.ActionError(fmt.Sprintf("could not get Tiller manifests for installing Helm: %s", err))
+		return ssh.ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
 	}
+	return ssh.DoWithCleanup(
+		ssh.ActionList{
+			ssh.DoUploadBytesToFile([]byte(chart.Values), valuesFile),
+			ssh.DoExec("helm " + strings.Join(getHelmChartArgs(chart, valuesFile), " ")),
+		},
+		ssh.ActionList{
+			ssh.DoTry(ssh.DoDeleteFile(valuesFile)),
+		})
+}
 
-	allManifests := strings.Join(manifests, "\n---\n")
-	ssh.Debug("Helm manifests: %s", allManifests)
+// doLoadHelm installs Helm in the first master (if enabled), and
+// then the charts
+func doLoadHelm(d *schema.ResourceData) ssh.Action {
+	if !isConfigEnabled(d, "helm_enabled") {
+		return ssh.DoMessageWarn("Helm will not be loaded")
+	}
 
-	kubeconfig := getKubeconfigFromResourceData(d)
+	version := common.DefHelmVersion
+	if opt, ok := d.GetOk("config.helm_version"); ok && len(opt.(string)) > 0 {
+		version = opt.(string)
+	}
 
-	return ssh.ActionList{
-		ssh.DoMessageInfo("Loading Helm..."),
-		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: allManifests}}),
-		ssh.DoMessageInfo("Now you should initialize the client with 'helm --kubeconfig=%s init'", kubeconfig),
-		ssh.DoMessageInfo("Then you can install charts with something like 'helm install --kubeconfig=%s --generate-name ...'", kubeconfig),
+	charts, err := getHelmChartsFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not parse the Helm charts: %s", err))
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Installing Helm %s...", version),
+		ssh.DoExecScript([]byte(fmt.Sprintf(helmInstallScript, version))),
+	}
+	for _, chart := range charts {
+		actions = append(actions,
+			ssh.DoMessageInfo("Installing chart %q as %q in namespace %q...", chart.Chart, chart.Name, chart.Namespace),
+			doInstallHelmChart(chart))
 	}
+	return actions
 }
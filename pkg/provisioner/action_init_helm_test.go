@@ -18,38 +18,44 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/hashicorp/terraform/helper/schema"
-
-	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
-func TestDoLoadHelm(t *testing.T) {
-	// responses from the fake remote machine
-	responses := []string{
-		"CONDITION_SUCCEEDED",
+func TestGetHelmChartArgs(t *testing.T) {
+	chart := common.HelmChartSpec{
+		Name:    "ingress",
+		Chart:   "ingress-nginx",
+		Repo:    "https://kubernetes.github.io/ingress-nginx",
+		Version: "4.8.3",
+	}
+
+	args := strings.Join(getHelmChartArgs(chart, "/tmp/values.yaml"), " ")
+	expected := "upgrade --install ingress ingress-nginx --namespace default --create-namespace --kubeconfig /etc/kubernetes/admin.conf --wait " +
+		"--repo https://kubernetes.github.io/ingress-nginx --version 4.8.3 --values /tmp/values.yaml"
+	if args != expected {
+		t.Fatalf("Error: unexpected helm args:\n%s\nexpected:\n%s", args, expected)
 	}
 
-	t.Skip("FIXME: it seems config.helm_enabled is not properly set...")
-	d := schema.ResourceData{}
-	_ = d.Set("install.0.kubectl_path", "")
-	_ = d.Set("config.helm_enabled", "true")
+	chart = common.HelmChartSpec{Name: "db", Chart: "./charts/db", Namespace: "storage"}
+	args = strings.Join(getHelmChartArgs(chart, ""), " ")
+	if !strings.Contains(args, "--namespace storage") || strings.Contains(args, "--repo") || strings.Contains(args, "--values") {
+		t.Fatalf("Error: unexpected helm args: %s", args)
+	}
+}
 
-	ctx, uploads := ssh.NewTestingContextForUploads(responses)
-	actions := ssh.ActionList{
-		doLoadHelm(&d),
+func TestHelmChartsSerialization(t *testing.T) {
+	charts := []common.HelmChartSpec{
+		{Name: "ingress", Chart: "ingress-nginx", Values: "controller:\n  replicaCount: 2\n"},
 	}
-	res := actions.Apply(ctx)
-	if ssh.IsError(res) {
-		t.Fatalf("Error: %s", res.Error())
+	s, err := common.HelmChartsToTerraformSafeString(charts)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
 	}
-	t.Logf("Uploads: %+v", *uploads)
-	if len(*uploads) == 0 {
-		t.Fatalf("Error: no uploads performed")
+	parsed, err := common.HelmChartsFromTerraformSafeString(s)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
 	}
-	for _, manifest := range *uploads {
-		if !strings.Contains(manifest, "tiller") {
-			t.Fatalf("Error: no 'tiller' found in manifest:\n%s", manifest)
-		}
-		break
+	if len(parsed) != 1 || parsed[0].Values != charts[0].Values {
+		t.Fatalf("Error: unexpected charts after deserialization: %+v", parsed)
 	}
 }
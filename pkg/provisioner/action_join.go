@@ -56,24 +56,32 @@ func doKubeadmJoinWorker(d *schema.ResourceData) ssh.Action {
 			ssh.ActionList{
 				doCheckLocalKubeconfigExists(d),
 			}),
+		doWaitForControlPlane(d),
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
 			ssh.ActionList{
 				doRefreshToken(d),
 			}),
-		ssh.DoRetry(
-			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
-			ssh.ActionList{
-				doMaybeResetWorker(d, common.DefKubeadmJoinConfPath),
-				ssh.DoMessageInfo("Trying to join the cluster as a worker with 'kubadm join'..."),
-				doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
-			}),
+		doDetectCRISocket(d, "join"),
+		doAddHardwareLabels(d, "join"),
+		doAddTaints(d, "join"),
+		doAddCloudProviderID(d, "join"),
+		doOnExistingNode(
+			d,
+			checkWorkerAlive(),
+			ssh.DoRetry(
+				getRetryFromResourceData(d, ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval}),
+				ssh.ActionList{
+					doMaybeResetWorker(d, common.DefKubeadmJoinConfPath),
+					ssh.DoMessageInfo("Trying to join the cluster as a worker with 'kubadm join'..."),
+					doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
+				})),
 	}
 	return actions
 }
 
 // doKubeadmJoinControlPlane runs the `kubeadm join` for another control-plane machine
-func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
+func doKubeadmJoinControlPlane(d *schema.ResourceData, host string) ssh.Action {
 	// get the joinConfiguration from the 'config.join' in the ResourceData
 	joinConfig, _, err := common.JoinConfigFromResourceData(d)
 	if err != nil {
@@ -109,6 +117,13 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError(err.Error())
 	}
 
+	// with an external etcd, this node must not be added as a new etcd member
+	extraArgs := []string{}
+	if getEtcdModeFromResourceData(d) == common.EtcdModeExternal {
+		extraArgs = append(extraArgs, "--skip-phases=control-plane-join/etcd")
+	}
+	extraArgs = append(extraArgs, getStaticPodPatchesArgs(d)...)
+
 	actions := ssh.ActionList{
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
@@ -120,14 +135,32 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 			ssh.ActionList{
 				doRefreshToken(d),
 			}),
-		ssh.DoRetry(
-			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
-			ssh.ActionList{
-				ssh.DoMessageInfo("Trying to join the cluster control-plane with 'kubadm join'..."),
-				doMaybeResetMaster(d, common.DefKubeadmJoinConfPath),
-				doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
-				doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
-			}),
+		doDetectCRISocket(d, "join"),
+		doAddHardwareLabels(d, "join"),
+		doAddTaints(d, "join"),
+		doAddCloudProviderID(d, "join"),
+		doOnExistingNode(
+			d,
+			checkAdminConfAlive(d),
+			ssh.DoRetry(
+				getRetryFromResourceData(d, ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval}),
+				ssh.ActionList{
+					ssh.DoMessageInfo("Trying to join the cluster control-plane with 'kubadm join'..."),
+					doMaybeResetMaster(d, common.DefKubeadmJoinConfPath),
+					doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
+					doCreateAPIServerCert(d, "join", host),
+					doUploadCloudConfig(d),
+					doUploadSchedulerConfig(d),
+					doUploadAdmissionConfig(d),
+					doUploadAuditConfig(d),
+					doUploadEgressSelectorConfig(d),
+					doUploadStaticPodPatches(d),
+					doKubeadm(d, common.DefKubeadmJoinConfPath, "join", extraArgs...),
+				})),
+		// (the VIP is already served by the other masters, so it can be created after joining)
+		doCreateKubeVip(d, "join"),
+		doCreateKonnectivityServer(d),
+		doExposeControlPlaneMetrics(d),
 	}
 	return actions
 }
@@ -0,0 +1,123 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// kubelet arguments that do not make sense in Windows nodes
+var windowsIgnoredKubeletArgs = []string{
+	"resolv-conf",
+	"cni-bin-dir",
+	"cni-conf-dir",
+}
+
+// doExecPowershell runs some PowerShell code in the remote Windows machine
+func doExecPowershell(code string) ssh.Action {
+	return ssh.DoExec(fmt.Sprintf(`powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -Command "%s"`, code))
+}
+
+// doWindowsSetup installs containerd, kubelet and kubeadm in a Windows node
+func doWindowsSetup(d *schema.ResourceData) ssh.Action {
+	if !d.Get("install.0.auto").(bool) {
+		return ssh.DoMessageInfo("kubeadm will not be automatically installed in this Windows node")
+	}
+
+	kubernetesVersion := common.DefKubernetesVersion
+	if opt, ok := d.GetOk("config.kube_version"); ok && len(opt.(string)) > 0 {
+		kubernetesVersion = opt.(string)
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Installing kubeadm in the Windows node (Kubernetes %s)...", kubernetesVersion),
+		doExecPowershell(fmt.Sprintf("New-Item -ItemType Directory -Force -Path '%s' | Out-Null", common.DefWindowsInstallDir)),
+		ssh.DoUploadBytesToFileDirect([]byte(assets.KubeadmSetupWindowsScriptCode), common.DefWindowsSetupScriptPath),
+		ssh.DoExec(fmt.Sprintf("powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -File %s -KubernetesVersion %s -InstallDir %s",
+			common.DefWindowsSetupScriptPath, kubernetesVersion, common.DefWindowsInstallDir)),
+	}
+}
+
+// doUploadWindowsJoinConfig uploads a kubeadm join configuration that has
+// been adapted for Windows nodes (ie, using the containerd named pipe)
+func doUploadWindowsJoinConfig(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionFunc(func(context.Context) ssh.Action {
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
+		}
+
+		joinConfig.NodeRegistration.Name = getNodenameFromResourceData(d)
+		joinConfig.NodeRegistration.CRISocket = common.DefWindowsCriSocket
+
+		kubeletArgs := map[string]string{}
+		for k, v := range joinConfig.NodeRegistration.KubeletExtraArgs {
+			kubeletArgs[k] = v
+		}
+		for _, arg := range windowsIgnoredKubeletArgs {
+			delete(kubeletArgs, arg)
+		}
+		kubeletArgs["container-runtime"] = "remote"
+		kubeletArgs["container-runtime-endpoint"] = common.DefWindowsCriSocket
+		joinConfig.NodeRegistration.KubeletExtraArgs = kubeletArgs
+
+		configBytes, err := common.JoinConfigToYAML(joinConfig)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
+		}
+		return ssh.DoUploadBytesToFileDirect(configBytes, common.DefWindowsKubeadmJoinConfPath)
+	})
+}
+
+// doKubeadmJoinWindowsWorker joins a Windows node to the cluster as a worker
+// NOTE: Windows nodes cannot be part of the control plane
+func doKubeadmJoinWindowsWorker(d *schema.ResourceData) ssh.Action {
+	if len(getJoinFromResourceData(d)) == 0 {
+		return ssh.ActionError("Windows nodes cannot be used for initializing the cluster: a \"join\" argument must be provided")
+	}
+	if isControlPlaneFromResourceData(d) {
+		return ssh.ActionError("Windows nodes can only be used as workers")
+	}
+
+	kubeadm := common.DefWindowsInstallDir + "/kubeadm.exe"
+	args := []string{
+		"join",
+		fmt.Sprintf("--config=%s", common.DefWindowsKubeadmJoinConfPath),
+	}
+	if ignored := getKubeadmIgnoredChecksArg(d); len(ignored) > 0 {
+		args = append(args, ignored)
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Provisioning Windows node"),
+		doCheckLocalKubeconfigExists(d),
+		doWindowsSetup(d),
+		ssh.DoRetry(
+			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
+			ssh.ActionList{
+				doUploadWindowsJoinConfig(d),
+				ssh.DoMessageInfo("Trying to join the cluster as a Windows worker with 'kubadm join'..."),
+				ssh.DoExec(fmt.Sprintf("%s %s", kubeadm, strings.Join(args, " "))),
+			}),
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetOSFromResourceData(t *testing.T) {
+	tests := []struct {
+		raw      map[string]interface{}
+		connType string
+		expected string
+	}{
+		{map[string]interface{}{}, "ssh", "linux"},
+		{map[string]interface{}{}, "winrm", "windows"},
+		{map[string]interface{}{"os": "Windows"}, "ssh", "windows"},
+		{map[string]interface{}{"os": "linux"}, "ssh", "linux"},
+	}
+	for _, test := range tests {
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, test.raw)
+		if res := getOSFromResourceData(d, test.connType); res != test.expected {
+			t.Fatalf("Error: unexpected OS for %+v with %q: %q", test.raw, test.connType, res)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getKernelModulesCode returns the contents of the modules-load.d file
+func getKernelModulesCode(modules []string) []byte {
+	return []byte("# kernel modules required by Kubernetes\n" + strings.Join(modules, "\n") + "\n")
+}
+
+// getSysctlsCode returns the contents of the sysctl.d file, with the keys sorted
+func getSysctlsCode(sysctls map[string]string) []byte {
+	keys := []string{}
+	for k := range sysctls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{"# sysctls required by Kubernetes"}
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s = %s", k, sysctls[k]))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// doConfigureKernel loads the kernel modules and sets the sysctls required
+// by Kubernetes, persisting them so they are restored after a reboot
+func doConfigureKernel(d *schema.ResourceData) ssh.Action {
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Loading kernel modules and setting sysctls..."),
+		ssh.DoMkdir(filepath.Dir(common.DefKernelModulesPath)),
+		ssh.DoUploadBytesToFile(getKernelModulesCode(common.DefKernelModules), common.DefKernelModulesPath),
+	}
+	for _, module := range common.DefKernelModules {
+		actions = append(actions,
+			ssh.DoTry(ssh.DoExec(fmt.Sprintf("modprobe %s", module))))
+	}
+
+	// the sysctls must be set after loading br_netfilter
+	actions = append(actions,
+		ssh.DoMkdir(filepath.Dir(common.DefSysctlsPath)),
+		ssh.DoUploadBytesToFile(getSysctlsCode(getSysctlsFromResourceData(d)), common.DefSysctlsPath),
+		ssh.DoExec("sysctl --system >/dev/null"),
+	)
+	return actions
+}
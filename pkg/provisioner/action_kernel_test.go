@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetSysctls(t *testing.T) {
+	raw := map[string]interface{}{
+		"sysctls": map[string]interface{}{
+			"net.ipv4.ip_forward":         "0",
+			"fs.inotify.max_user_watches": "524288",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	sysctls := getSysctlsFromResourceData(d)
+	if sysctls["net.bridge.bridge-nf-call-iptables"] != "1" {
+		t.Fatalf("Error: default sysctl not found: %+v", sysctls)
+	}
+	if sysctls["net.ipv4.ip_forward"] != "0" || sysctls["fs.inotify.max_user_watches"] != "524288" {
+		t.Fatalf("Error: user sysctls not found: %+v", sysctls)
+	}
+
+	code := string(getSysctlsCode(sysctls))
+	expected := "fs.inotify.max_user_watches = 524288\nnet.bridge.bridge-nf-call-ip6tables = 1\n"
+	if !strings.Contains(code, expected) {
+		t.Fatalf("Error: unexpected sysctls file:\n%s", code)
+	}
+}
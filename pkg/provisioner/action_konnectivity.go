@@ -0,0 +1,281 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// konnectivityServerScript creates the credentials for the konnectivity-server (a client
+// certificate signed by the cluster CA) and its static pod in a control plane node
+// (environment: KUBECTL, KUBECONFIG, IMAGE, AGENT_PORT, DIR and SOCKET)
+const konnectivityServerScript = `#!/bin/sh
+set -e
+
+if [ ! -f "$KUBECONFIG" ] ; then
+	echo ">>> creating the kubeconfig for the konnectivity-server"
+	TMP="$(mktemp -d)"
+	trap 'rm -rf "$TMP"' EXIT
+	openssl req -subj "/CN=system:konnectivity-server" -new -newkey rsa:2048 -nodes \
+		-out "$TMP/konnectivity.csr" -keyout "$TMP/konnectivity.key"
+	openssl x509 -req -in "$TMP/konnectivity.csr" \
+		-CA /etc/kubernetes/pki/ca.crt -CAkey /etc/kubernetes/pki/ca.key -CAcreateserial \
+		-out "$TMP/konnectivity.crt" -days 375 -sha256
+	SERVER="$($KUBECTL config view --kubeconfig=/etc/kubernetes/admin.conf -o jsonpath='{.clusters[0].cluster.server}')"
+	$KUBECTL --kubeconfig "$KUBECONFIG" config set-credentials system:konnectivity-server \
+		--client-certificate "$TMP/konnectivity.crt" --client-key "$TMP/konnectivity.key" --embed-certs=true
+	$KUBECTL --kubeconfig "$KUBECONFIG" config set-cluster kubernetes \
+		--server "$SERVER" --certificate-authority /etc/kubernetes/pki/ca.crt --embed-certs=true
+	$KUBECTL --kubeconfig "$KUBECONFIG" config set-context system:konnectivity-server@kubernetes \
+		--cluster kubernetes --user system:konnectivity-server
+	$KUBECTL --kubeconfig "$KUBECONFIG" config use-context system:konnectivity-server@kubernetes
+	chmod 600 "$KUBECONFIG"
+fi
+
+echo ">>> creating the konnectivity-server static pod"
+mkdir -p "$DIR" /etc/kubernetes/manifests
+cat > /etc/kubernetes/manifests/konnectivity-server.yaml <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: konnectivity-server
+  namespace: kube-system
+spec:
+  priorityClassName: system-cluster-critical
+  hostNetwork: true
+  containers:
+    - name: konnectivity-server-container
+      image: $IMAGE
+      command: ["/proxy-server"]
+      args:
+        - "--logtostderr=true"
+        - "--uds-name=$SOCKET"
+        - "--delete-existing-uds-file"
+        - "--cluster-cert=/etc/kubernetes/pki/apiserver.crt"
+        - "--cluster-key=/etc/kubernetes/pki/apiserver.key"
+        - "--mode=grpc"
+        - "--server-port=0"
+        - "--agent-port=$AGENT_PORT"
+        - "--admin-port=8133"
+        - "--health-port=8134"
+        - "--agent-namespace=kube-system"
+        - "--agent-service-account=konnectivity-agent"
+        - "--kubeconfig=$KUBECONFIG"
+        - "--authentication-audience=system:konnectivity-server"
+      livenessProbe:
+        httpGet:
+          scheme: HTTP
+          host: 127.0.0.1
+          port: 8134
+          path: /healthz
+        initialDelaySeconds: 30
+        timeoutSeconds: 60
+      ports:
+        - name: agentport
+          containerPort: $AGENT_PORT
+          hostPort: $AGENT_PORT
+        - name: adminport
+          containerPort: 8133
+          hostPort: 8133
+        - name: healthport
+          containerPort: 8134
+          hostPort: 8134
+      volumeMounts:
+        - name: k8s-certs
+          mountPath: /etc/kubernetes/pki
+          readOnly: true
+        - name: kubeconfig
+          mountPath: $KUBECONFIG
+          readOnly: true
+        - name: konnectivity-uds
+          mountPath: $DIR
+          readOnly: false
+  volumes:
+    - name: k8s-certs
+      hostPath:
+        path: /etc/kubernetes/pki
+    - name: kubeconfig
+      hostPath:
+        path: $KUBECONFIG
+        type: FileOrCreate
+    - name: konnectivity-uds
+      hostPath:
+        path: $DIR
+        type: DirectoryOrCreate
+EOF
+`
+
+// konnectivityAgentManifest is the manifest for the konnectivity agents, running in all the nodes
+// (arguments: image, server host and agent port)
+const konnectivityAgentManifest = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:konnectivity-server
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: system:auth-delegator
+subjects:
+  - apiGroup: rbac.authorization.k8s.io
+    kind: User
+    name: system:konnectivity-server
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: konnectivity-agent
+  namespace: kube-system
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: konnectivity-agent
+  namespace: kube-system
+  labels:
+    k8s-app: konnectivity-agent
+spec:
+  selector:
+    matchLabels:
+      k8s-app: konnectivity-agent
+  template:
+    metadata:
+      labels:
+        k8s-app: konnectivity-agent
+    spec:
+      priorityClassName: system-cluster-critical
+      serviceAccountName: konnectivity-agent
+      tolerations:
+        - key: "CriticalAddonsOnly"
+          operator: "Exists"
+        - key: "node-role.kubernetes.io/master"
+          operator: "Exists"
+          effect: "NoSchedule"
+        - key: "node-role.kubernetes.io/control-plane"
+          operator: "Exists"
+          effect: "NoSchedule"
+      containers:
+        - name: konnectivity-agent
+          image: %s
+          command: ["/proxy-agent"]
+          args:
+            - "--logtostderr=true"
+            - "--ca-cert=/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+            - "--proxy-server-host=%s"
+            - "--proxy-server-port=%s"
+            - "--admin-server-port=8133"
+            - "--health-server-port=8134"
+            - "--service-account-token-path=/var/run/secrets/tokens/konnectivity-agent-token"
+          volumeMounts:
+            - mountPath: /var/run/secrets/tokens
+              name: konnectivity-agent-token
+          livenessProbe:
+            httpGet:
+              port: 8134
+              path: /healthz
+            initialDelaySeconds: 15
+            timeoutSeconds: 15
+      volumes:
+        - name: konnectivity-agent-token
+          projected:
+            sources:
+              - serviceAccountToken:
+                  path: konnectivity-agent-token
+                  audience: system:konnectivity-server
+`
+
+// isKonnectivityEnabled returns true when the API server reaches the cluster through the konnectivity service
+func isKonnectivityEnabled(d *schema.ResourceData) bool {
+	return isConfigEnabled(d, "konnectivity_enabled")
+}
+
+// getKonnectivityImage returns the image for the konnectivity `component` ("server" or "agent")
+func getKonnectivityImage(d *schema.ResourceData, component string) string {
+	v := d.Get("config.konnectivity_version").(string)
+	if len(v) == 0 {
+		v = common.DefKonnectivityVersion
+	}
+	return fmt.Sprintf("%s/proxy-%s:%s", common.DefKonnectivityImagesRepo, component, v)
+}
+
+// getKonnectivityAgentPort returns the port where the konnectivity-server listens for the agents
+func getKonnectivityAgentPort(d *schema.ResourceData) string {
+	if port := d.Get("config.konnectivity_agent_port").(string); len(port) > 0 {
+		return port
+	}
+	return fmt.Sprintf("%d", common.DefKonnectivityAgentPort)
+}
+
+// doUploadEgressSelectorConfig uploads the egress selector configuration to the control plane
+// nodes, so the API server reaches the cluster through the konnectivity-server
+func doUploadEgressSelectorConfig(d *schema.ResourceData) ssh.Action {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+
+	config, err := common.NewEgressSelectorConfig(getKubeVersionFromResourceData(d))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not create the egress selector configuration: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading the egress selector configuration to %s", common.DefEgressSelectorConfigPath),
+		ssh.DoMkdir(common.DefKonnectivityDir),
+		ssh.DoUploadBytesToFile([]byte(config), common.DefEgressSelectorConfigPath),
+	}
+}
+
+// doCreateKonnectivityServer creates the konnectivity-server static pod in a control plane node
+func doCreateKonnectivityServer(d *schema.ResourceData) ssh.Action {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+
+	env := map[string]string{
+		"KUBECTL":    getKubectlFromResourceData(d),
+		"KUBECONFIG": common.DefKonnectivityKubeconfigPath,
+		"IMAGE":      getKonnectivityImage(d, "server"),
+		"AGENT_PORT": getKonnectivityAgentPort(d),
+		"DIR":        common.DefKonnectivityDir,
+		"SOCKET":     common.DefKonnectivitySocketPath,
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Creating the konnectivity-server"),
+		ssh.DoExecScriptWithEnv([]byte(konnectivityServerScript), env),
+	}
+}
+
+// doLoadKonnectivityAgent loads the konnectivity agents, connecting to the konnectivity-server
+// in the `host` (the first control plane node) when no other server host has been provided
+func doLoadKonnectivityAgent(d *schema.ResourceData, host string) ssh.Action {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+
+	serverHost := d.Get("config.konnectivity_server_host").(string)
+	if len(serverHost) == 0 {
+		serverHost = host
+	}
+
+	manifest := fmt.Sprintf(konnectivityAgentManifest, getKonnectivityImage(d, "agent"), serverHost, getKonnectivityAgentPort(d))
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the konnectivity agents (connecting to %s)", serverHost),
+		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: manifest}}),
+	}
+}
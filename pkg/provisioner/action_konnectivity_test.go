@@ -0,0 +1,60 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestKonnectivity(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if doUploadEgressSelectorConfig(d) != nil || doCreateKonnectivityServer(d) != nil || doLoadKonnectivityAgent(d, "10.0.0.1") != nil {
+		t.Fatalf("Error: unexpected konnectivity actions when disabled")
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"kube_version":         "v1.27.3",
+			"konnectivity_enabled": "true",
+			"konnectivity_version": "v0.1.2",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if image := getKonnectivityImage(d, "agent"); image != "registry.k8s.io/kas-network-proxy/proxy-agent:v0.1.2" {
+		t.Fatalf("Error: unexpected image: %q", image)
+	}
+	if port := getKonnectivityAgentPort(d); port != "8132" {
+		t.Fatalf("Error: unexpected agent port: %q", port)
+	}
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	res := doUploadEgressSelectorConfig(d).Apply(ctx)
+	if ssh.IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if len(*uploads) != 1 {
+		t.Fatalf("Error: unexpected number of uploads: %d", len(*uploads))
+	}
+	for _, config := range *uploads {
+		if !strings.Contains(config, "apiVersion: apiserver.k8s.io/v1beta1") || !strings.Contains(config, "konnectivity-server.socket") {
+			t.Fatalf("Error: unexpected egress selector configuration:\n%s", config)
+		}
+	}
+}
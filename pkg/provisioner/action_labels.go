@@ -0,0 +1,164 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// hardwareDetectScript is a script that prints some "feature=value" lines
+// for the hardware detected in the node
+const hardwareDetectScript = `#!/bin/sh
+if [ -e /dev/nvidia0 ] || lspci 2>/dev/null | grep -qi nvidia ; then
+	echo "gpu=nvidia"
+elif lspci 2>/dev/null | grep -iE 'vga|3d|display' | grep -qiE 'amd|ati' ; then
+	echo "gpu=amd"
+fi
+
+ROOT_DEV="$(lsblk -no PKNAME "$(findmnt -no SOURCE /)" 2>/dev/null | head -n1)"
+if [ -n "$ROOT_DEV" ] && [ -f /sys/block/$ROOT_DEV/queue/rotational ] ; then
+	if [ "$(cat /sys/block/$ROOT_DEV/queue/rotational)" = "0" ] ; then
+		echo "storage=ssd"
+	else
+		echo "storage=hdd"
+	fi
+fi
+
+grep -q avx512f /proc/cpuinfo && echo "cpu-avx512=true"
+exit 0
+`
+
+// kubelet argument used for setting the node labels
+const kubeletNodeLabelsArg = "node-labels"
+
+var hardwareFeatureRegex = regexp.MustCompile(`^([a-z0-9-]+)=([a-z0-9.-]+)$`)
+
+// parseHardwareFeature parses a "feature=value" line, returning the
+// feature and the value (or empty strings if the line is not valid)
+func parseHardwareFeature(line string) (string, string) {
+	m := hardwareFeatureRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", ""
+	}
+	return m[1], m[2]
+}
+
+// mergeNodeLabels adds some labels to a comma-separated list of labels (the
+// format used in the kubelet `--node-labels`), replacing existing values
+func mergeNodeLabels(current string, labels map[string]string) string {
+	all := map[string]string{}
+	for _, kv := range strings.Split(current, ",") {
+		if kv = strings.TrimSpace(kv); len(kv) == 0 {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			all[parts[0]] = parts[1]
+		} else {
+			all[parts[0]] = ""
+		}
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+
+	res := []string{}
+	for k, v := range all {
+		res = append(res, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// doAddHardwareLabels detects some hardware features in the node and
+// adds the corresponding labels in the kubelet's `--node-labels` for
+// the `command` ("init" or "join") configuration
+func doAddHardwareLabels(d *schema.ResourceData, command string) ssh.Action {
+	prefix := getHardwareLabelsPrefixFromResourceData(d)
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		labels := map[string]string{}
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExecScript([]byte(hardwareDetectScript)),
+			func(s string) {
+				if feature, value := parseHardwareFeature(s); len(feature) > 0 {
+					ssh.GetLoggerFromContext(ctx).Debug("hardware feature detected: %s=%s", feature, value)
+					labels[fmt.Sprintf("%s/%s", prefix, feature)] = value
+				}
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if len(labels) == 0 {
+			return ssh.DoMessageInfo("No hardware features detected")
+		}
+
+		err := updateKubeletExtraArgs(d, command, func(args map[string]string) {
+			args[kubeletNodeLabelsArg] = mergeNodeLabels(args[kubeletNodeLabelsArg], labels)
+		})
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+
+		return ssh.DoMessageInfo("Node will be labeled with: %s", mergeNodeLabels("", labels))
+	})
+}
+
+// updateKubeletExtraArgs updates the kubelet extra args in the `command`
+// ("init" or "join") configuration
+func updateKubeletExtraArgs(d *schema.ResourceData, command string, update func(args map[string]string)) error {
+	return updateNodeRegistration(d, command, func(nr *kubeadmapi.NodeRegistrationOptions) {
+		if nr.KubeletExtraArgs == nil {
+			nr.KubeletExtraArgs = map[string]string{}
+		}
+		update(nr.KubeletExtraArgs)
+	})
+}
+
+// updateNodeRegistration updates the node registration options in the `command`
+// ("init" or "join") configuration
+func updateNodeRegistration(d *schema.ResourceData, command string, update func(nr *kubeadmapi.NodeRegistrationOptions)) error {
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		update(&initConfig.NodeRegistration)
+		return common.InitConfigToResourceData(d, initConfig)
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		update(&joinConfig.NodeRegistration)
+		return common.JoinConfigToResourceData(d, joinConfig)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseHardwareFeature(t *testing.T) {
+	tests := []struct {
+		line    string
+		feature string
+		value   string
+	}{
+		{"gpu=nvidia", "gpu", "nvidia"},
+		{"  storage=ssd\r", "storage", "ssd"},
+		{"cpu-avx512=true", "cpu-avx512", "true"},
+		{"Removing /tmp/something", "", ""},
+		{"gpu=", "", ""},
+	}
+	for _, test := range tests {
+		feature, value := parseHardwareFeature(test.line)
+		if feature != test.feature || value != test.value {
+			t.Fatalf("Error: unexpected result for %q: %q=%q", test.line, feature, value)
+		}
+	}
+}
+
+func TestMergeNodeLabels(t *testing.T) {
+	labels := map[string]string{
+		"hardware.kubeadm.io/gpu":     "nvidia",
+		"hardware.kubeadm.io/storage": "ssd",
+	}
+	res := mergeNodeLabels("zone=a, hardware.kubeadm.io/gpu=amd", labels)
+	expected := "hardware.kubeadm.io/gpu=nvidia,hardware.kubeadm.io/storage=ssd,zone=a"
+	if res != expected {
+		t.Fatalf("Error: unexpected labels: %q (expected %q)", res, expected)
+	}
+}
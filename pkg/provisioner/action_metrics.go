@@ -0,0 +1,45 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strconv"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// doExposeControlPlaneMetrics opens the ports used by the control plane
+// metrics in the firewall (if enabled)
+// NOTE: the bind addresses are set by the provider in the kubeadm configuration
+func doExposeControlPlaneMetrics(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.metrics_exposed")
+	if !ok {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(opt.(string))
+	if err != nil {
+		return ssh.ActionError("could not parse metrics_exposed in provisioner")
+	}
+	if !enabled {
+		return nil
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Control plane metrics will be exposed"),
+		ssh.DoOpenFirewallPorts(common.DefControlPlaneMetricsPorts...),
+	}
+}
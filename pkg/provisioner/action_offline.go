@@ -0,0 +1,143 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+var (
+	// remote directory for the packages in offline installations
+	offlinePackagesDir = path.Join(common.DefOfflineDir, "packages")
+
+	// remote path for the images tarball in offline installations
+	offlineImagesPath = path.Join(common.DefOfflineDir, "images.tar")
+)
+
+// isOffline returns true if we are doing an offline installation
+func isOffline(d *schema.ResourceData) bool {
+	return len(getOfflinePackagesDirFromResourceData(d)) > 0 ||
+		len(getOfflineRepoURLFromResourceData(d)) > 0 ||
+		len(getOfflineImagesFromResourceData(d)) > 0
+}
+
+// getOfflinePackages returns the list of packages in a local directory
+func getOfflinePackages(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		switch filepath.Ext(f.Name()) {
+		case ".deb", ".rpm":
+			res = append(res, filepath.Join(dir, f.Name()))
+		}
+	}
+	return res, nil
+}
+
+// doUploadOffline uploads the packages and images for an offline installation
+func doUploadOffline(d *schema.ResourceData) ssh.Action {
+	if !isOffline(d) {
+		return nil
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Preparing offline installation..."),
+	}
+
+	if dir := getOfflinePackagesDirFromResourceData(d); len(dir) > 0 {
+		packages, err := getOfflinePackages(dir)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not read packages directory %q: %s", dir, err))
+		}
+		if len(packages) == 0 {
+			return ssh.ActionError(fmt.Sprintf("no deb/rpm packages found in %q", dir))
+		}
+
+		actions = append(actions,
+			ssh.DoExec(fmt.Sprintf("rm -rf %s", offlinePackagesDir)),
+			ssh.DoMkdir(offlinePackagesDir))
+		for _, p := range packages {
+			actions = append(actions, ssh.DoStreamFileToFile(p, path.Join(offlinePackagesDir, filepath.Base(p))))
+		}
+	}
+
+	if images := getOfflineImagesFromResourceData(d); len(images) > 0 {
+		if strings.HasPrefix(images, "http://") || strings.HasPrefix(images, "https://") {
+			actions = append(actions,
+				ssh.DoMkdir(common.DefOfflineDir),
+				ssh.DoMessageInfo("Downloading images from %q", images),
+				ssh.DoExec(fmt.Sprintf("curl -sSL -o %s %q", offlineImagesPath, images)))
+		} else {
+			actions = append(actions, ssh.DoStreamFileToFile(images, offlineImagesPath))
+		}
+	}
+
+	return actions
+}
+
+// getOfflineSetupEnv returns the environment for the setup script in offline installations
+func getOfflineSetupEnv(d *schema.ResourceData) map[string]string {
+	env := map[string]string{}
+	if len(getOfflinePackagesDirFromResourceData(d)) > 0 {
+		env["OFFLINE_PACKAGES_DIR"] = offlinePackagesDir
+	}
+	if url := getOfflineRepoURLFromResourceData(d); len(url) > 0 {
+		env["OFFLINE_REPO_URL"] = url
+	}
+	return env
+}
+
+// doImportOfflineImages imports the images tarball in the container runtime
+func doImportOfflineImages(d *schema.ResourceData) ssh.Action {
+	if len(getOfflineImagesFromResourceData(d)) == 0 {
+		return nil
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+
+	var cmd string
+	switch engine {
+	case "containerd":
+		cmd = fmt.Sprintf("ctr -n k8s.io images import %s", offlineImagesPath)
+	case "crio":
+		cmd = fmt.Sprintf("podman load -i %s", offlineImagesPath)
+	default:
+		cmd = fmt.Sprintf("docker load -i %s", offlineImagesPath)
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Importing container images from %s...", offlineImagesPath),
+		ssh.DoExec(cmd),
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetOfflinePackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"kubeadm.rpm", "kubelet.rpm", "README.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("test"), 0644); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+	}
+
+	packages, err := getOfflinePackages(dir)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("Error: unexpected packages: %v", packages)
+	}
+
+	raw := map[string]interface{}{
+		"offline": []interface{}{
+			map[string]interface{}{
+				"packages_dir": dir,
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if !isOffline(d) {
+		t.Fatalf("Error: offline installation not detected")
+	}
+	env := getOfflineSetupEnv(d)
+	if env["OFFLINE_PACKAGES_DIR"] != offlinePackagesDir {
+		t.Fatalf("Error: unexpected environment for the setup script: %v", env)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getStaticPodPatchesFromResourceData returns the patch files for the static pods
+func getStaticPodPatchesFromResourceData(d *schema.ResourceData) (map[string]string, error) {
+	opt, ok := d.GetOk("config.static_pod_patches")
+	if !ok || len(opt.(string)) == 0 {
+		return nil, nil
+	}
+	return common.PatchFilesFromTerraformSafeString(opt.(string))
+}
+
+// getStaticPodPatchesArgs returns the kubeadm arguments for using the patches (if any)
+func getStaticPodPatchesArgs(d *schema.ResourceData) []string {
+	if opt, ok := d.GetOk("config.static_pod_patches"); !ok || len(opt.(string)) == 0 {
+		return []string{}
+	}
+	return []string{common.GetPatchesFlag(getKubeVersionFromResourceData(d), common.DefPatchesDir)}
+}
+
+// doUploadStaticPodPatches uploads the patches for the static pods to the
+// control plane nodes, replacing any previous patches
+func doUploadStaticPodPatches(d *schema.ResourceData) ssh.Action {
+	files, err := getStaticPodPatchesFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the static pod patches: %s", err))
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading %d patches for the static pods to %s", len(files), common.DefPatchesDir),
+		ssh.DoExec(fmt.Sprintf("rm -rf %s", common.DefPatchesDir)),
+		ssh.DoMkdir(common.DefPatchesDir),
+	}
+	for _, name := range names {
+		actions = append(actions, ssh.DoUploadBytesToFile([]byte(files[name]), filepath.Join(common.DefPatchesDir, name)))
+	}
+	return actions
+}
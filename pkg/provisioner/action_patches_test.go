@@ -0,0 +1,65 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestDoUploadStaticPodPatches(t *testing.T) {
+	s, err := common.PatchFilesToTerraformSafeString(map[string]string{
+		"etcd0a+strategic.json": `{"spec":{"priorityClassName":"high"}}`,
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"kube_version":       "v1.21.2",
+			"static_pod_patches": s,
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	args := getStaticPodPatchesArgs(d)
+	if len(args) != 1 || args[0] != "--experimental-patches=/etc/kubernetes/patches" {
+		t.Fatalf("Error: unexpected kubeadm args: %v", args)
+	}
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	if res := doUploadStaticPodPatches(d).Apply(ctx); ssh.IsError(res) {
+		t.Fatalf("Error: %s", res)
+	}
+	found := false
+	for _, contents := range *uploads {
+		if contents == `{"spec":{"priorityClassName":"high"}}` {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Error: the patch was not uploaded: %v", *uploads)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if args := getStaticPodPatchesArgs(d); len(args) != 0 {
+		t.Fatalf("Error: no args expected without patches: %v", args)
+	}
+}
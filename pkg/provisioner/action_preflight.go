@@ -0,0 +1,410 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// preflightFactsScript is a script that prints some "fact=value" lines
+// with the information needed for the preflight checks
+const preflightFactsScript = `#!/bin/sh
+echo "time=$(date +%%s)"
+echo "cpus=$(nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo)"
+echo "memory=$(awk '/^MemTotal:/ { print int($2 / 1024) }' /proc/meminfo)"
+
+# the free space (in MB) in the filesystem for the kubelet root directory (or its closest parent)
+DIR="%s"
+while [ ! -d "$DIR" ] ; do DIR="$(dirname "$DIR")" ; done
+echo "disk=$(df -Pm "$DIR" 2>/dev/null | awk 'NR == 2 { print $4 }')"
+
+if [ "$(grep -vc ^Filename /proc/swaps)" = "0" ] ; then
+	echo "swap=off"
+else
+	echo "swap=on"
+fi
+
+if modprobe br_netfilter 2>/dev/null || [ -d /proc/sys/net/bridge ] ; then
+	echo "br_netfilter=ok"
+else
+	echo "br_netfilter=failed"
+fi
+
+if [ "$(stat -fc %%T /sys/fs/cgroup 2>/dev/null)" = "cgroup2fs" ] ; then
+	echo "cgroups=v2"
+else
+	echo "cgroups=v1"
+fi
+
+# the cgroup driver used by the container runtime
+DRIVER=""
+case "%s" in
+docker)
+	DRIVER="$(docker info -f '{{.CgroupDriver}}' 2>/dev/null)"
+	;;
+containerd)
+	if grep -qE '^[^#]*(SystemdCgroup|systemd_cgroup) *= *true' /etc/containerd/config.toml 2>/dev/null ; then
+		DRIVER="systemd"
+	elif [ -f /etc/containerd/config.toml ] ; then
+		DRIVER="cgroupfs"
+	fi
+	;;
+crio)
+	DRIVER="$(cat /etc/crio/crio.conf /etc/crio/crio.conf.d/*.conf 2>/dev/null | sed -n 's/^ *cgroup_manager *= *"\(.*\)"/\1/p' | tail -1)"
+	;;
+esac
+echo "cgroup_driver=${DRIVER:-unknown}"
+
+SYNC="$(timedatectl show -p NTPSynchronized --value 2>/dev/null)"
+[ -n "$SYNC" ] || SYNC="$(timedatectl 2>/dev/null | awk -F': ' '/synchronized/ { print $2 }')"
+echo "time_sync=${SYNC:-unknown}"
+
+for PORT in %s ; do
+	if (ss -ltnH 2>/dev/null || netstat -ltn 2>/dev/null) | awk '{ print $4 }' | grep -qE "[:.]$PORT\$" ; then
+		echo "port_$PORT=used"
+	else
+		echo "port_$PORT=free"
+	fi
+done
+exit 0
+`
+
+// preflightChecks is the list of checks that can be skipped
+var preflightChecks = []string{"ports", "swap", "br_netfilter", "cgroups", "cgroup_driver", "time_sync", "clock_skew", "cpus", "memory", "disk"}
+
+// preflightResourceChecks are the checks for the resources in the node
+var preflightResourceChecks = []string{"cpus", "memory", "disk"}
+
+// ports that must be free in the control plane and in the workers
+var (
+	preflightMasterPorts = []int{6443, 2379, 2380, 10250, 10251, 10252}
+	preflightWorkerPorts = []int{10250}
+)
+
+// minimum resources for the control plane and for the workers
+const (
+	preflightMasterMinCPUs   = 2
+	preflightMasterMinMemory = 1700
+	preflightWorkerMinCPUs   = 1
+	preflightWorkerMinMemory = 1024
+)
+
+// maximum difference between the clock in the node and the local clock
+const preflightMaxClockSkew = 5 * time.Second
+
+// the first Kubernetes version that supports cgroups v2
+var cgroupsV2MinVersion = version.MustParseGeneric("v1.25.0")
+
+// preflightOptions are the options for the preflight checks
+type preflightOptions struct {
+	master       bool
+	kubeVersion  string
+	engine       string
+	cgroupDriver string
+	minCPUs      int
+	minMemory    int
+	minDisk      int
+	skip         []string
+
+	// resources checks that fail are only reported as warnings
+	ignoreResources bool
+
+	// the local time before and after getting the facts, and the maximum clock skew
+	started      time.Time
+	finished     time.Time
+	maxClockSkew time.Duration
+}
+
+// preflightResult is the result of a preflight check
+type preflightResult struct {
+	check   string
+	failed  bool
+	warning bool
+	message string
+}
+
+func (r preflightResult) String() string {
+	status := "ok"
+	if r.failed {
+		status = "FAILED"
+	} else if r.warning {
+		status = "warning"
+	}
+	return fmt.Sprintf("%s: %s (%s)", r.check, status, r.message)
+}
+
+// parsePreflightFacts parses the "fact=value" lines printed by the facts script
+func parsePreflightFacts(lines []string) map[string]string {
+	facts := map[string]string{}
+	for _, line := range lines {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && len(parts[0]) > 0 {
+			facts[parts[0]] = parts[1]
+		}
+	}
+	return facts
+}
+
+// getClockSkew returns the difference between the time in the node (in seconds)
+// and the local time when the node was queried (zero if it is in that interval)
+func getClockSkew(nodeTime int64, started time.Time, finished time.Time) time.Duration {
+	// the node time is truncated to seconds
+	from, to := time.Unix(nodeTime, 0), time.Unix(nodeTime+1, 0)
+	switch {
+	case to.Before(started):
+		return to.Sub(started)
+	case from.After(finished):
+		return from.Sub(finished)
+	}
+	return 0
+}
+
+// evaluatePreflight evaluates the preflight checks for the facts obtained in a node
+func evaluatePreflight(facts map[string]string, opts preflightOptions) []preflightResult {
+	skip := map[string]bool{}
+	for _, s := range opts.skip {
+		skip[s] = true
+	}
+
+	ignored := map[string]bool{}
+	if opts.ignoreResources {
+		for _, s := range preflightResourceChecks {
+			ignored[s] = true
+		}
+	}
+
+	results := []preflightResult{}
+	add := func(check string, failed bool, warning bool, format string, args ...interface{}) {
+		if skip[check] {
+			return
+		}
+		if failed && ignored[check] {
+			failed, warning = false, true
+		}
+		results = append(results, preflightResult{check, failed, warning, fmt.Sprintf(format, args...)})
+	}
+
+	ports := preflightWorkerPorts
+	if opts.master {
+		ports = preflightMasterPorts
+	}
+	used := []string{}
+	for _, port := range ports {
+		if facts[fmt.Sprintf("port_%d", port)] == "used" {
+			used = append(used, strconv.Itoa(port))
+		}
+	}
+	add("ports", len(used) > 0, false, "ports in use: [%s]", strings.Join(used, ","))
+
+	add("swap", facts["swap"] != "off", false, "swap is %s", facts["swap"])
+	add("br_netfilter", facts["br_netfilter"] != "ok", false, "br_netfilter module %s", facts["br_netfilter"])
+
+	cgroupsFailed := false
+	if facts["cgroups"] == "v2" {
+		v, err := version.ParseGeneric(opts.kubeVersion)
+		cgroupsFailed = err != nil || v.LessThan(cgroupsV2MinVersion)
+	}
+	add("cgroups", cgroupsFailed, false, "cgroups %s with Kubernetes %s", facts["cgroups"], opts.kubeVersion)
+
+	switch driver := facts["cgroup_driver"]; driver {
+	case "", "unknown":
+		add("cgroup_driver", false, true, "could not determine the cgroup driver used by %s", opts.engine)
+	case opts.cgroupDriver:
+		add("cgroup_driver", false, false, "%s and the kubelet use the %s cgroup driver", opts.engine, driver)
+	default:
+		add("cgroup_driver", true, false, "%s uses the %s cgroup driver but the kubelet will use %s: set the same driver in `runtime.cgroup_driver` or in the %s configuration",
+			opts.engine, driver, opts.cgroupDriver, opts.engine)
+	}
+
+	switch facts["time_sync"] {
+	case "yes":
+		add("time_sync", false, false, "clock synchronized")
+	case "no":
+		add("time_sync", true, false, "clock not synchronized")
+	default:
+		add("time_sync", false, true, "could not determine if the clock is synchronized")
+	}
+
+	nodeTime, err := strconv.ParseInt(facts["time"], 10, 64)
+	if err != nil || opts.started.IsZero() {
+		add("clock_skew", false, true, "could not determine the clock skew")
+	} else {
+		maxClockSkew := preflightMaxClockSkew
+		if opts.maxClockSkew > 0 {
+			maxClockSkew = opts.maxClockSkew
+		}
+		skew := getClockSkew(nodeTime, opts.started, opts.finished)
+		abs := skew
+		if abs < 0 {
+			abs = -abs
+		}
+		add("clock_skew", abs > maxClockSkew, false, "clock skew %s (maximum %s)", skew, maxClockSkew)
+	}
+
+	minCPUs, minMemory := preflightWorkerMinCPUs, preflightWorkerMinMemory
+	if opts.master {
+		minCPUs, minMemory = preflightMasterMinCPUs, preflightMasterMinMemory
+	}
+	if opts.minCPUs > 0 {
+		minCPUs = opts.minCPUs
+	}
+	if opts.minMemory > 0 {
+		minMemory = opts.minMemory
+	}
+	cpus, _ := strconv.Atoi(facts["cpus"])
+	add("cpus", cpus < minCPUs, false, "%d CPUs (minimum %d)", cpus, minCPUs)
+	memory, _ := strconv.Atoi(facts["memory"])
+	add("memory", memory < minMemory, false, "%d MB of memory (minimum %d MB)", memory, minMemory)
+
+	if disk, err := strconv.Atoi(facts["disk"]); err != nil {
+		add("disk", false, opts.minDisk > 0, "could not determine the free disk space")
+	} else {
+		add("disk", disk < opts.minDisk*1024, false, "%d GB of free disk space (minimum %d GB)", disk/1024, opts.minDisk)
+	}
+
+	return results
+}
+
+// getMinResourceFromResourceData returns the minimum for some resource ("cpus", "memory"
+// or "disk") in this node: the one in the `preflight` block or the one for the role of
+// the node in the `min_resources` of the resource (or 0 when none has been provided)
+func getMinResourceFromResourceData(d *schema.ResourceData, master bool, kind string) int {
+	if v := d.Get("preflight.0.min_" + kind).(int); v > 0 {
+		return v
+	}
+	role := "worker"
+	if master {
+		role = "master"
+	}
+	if opt, ok := d.GetOk(fmt.Sprintf("config.min_%s_%s", role, kind)); ok {
+		if v, err := strconv.Atoi(opt.(string)); err == nil {
+			return v
+		}
+	}
+	return 0
+}
+
+// doPreflight runs the preflight checks in the node, failing with a
+// report when some check does not pass
+// When there is no `preflight` block, only the resources are checked (when
+// some minimum resources have been provided in the resource)
+func doPreflight(d *schema.ResourceData, master bool) ssh.Action {
+	skip := []string{}
+	if _, ok := d.GetOk("preflight"); !ok {
+		hasMinResources := false
+		for _, kind := range preflightResourceChecks {
+			hasMinResources = hasMinResources || getMinResourceFromResourceData(d, master, kind) > 0
+		}
+		if !hasMinResources {
+			return nil
+		}
+		for _, check := range preflightChecks {
+			if !common.StringSliceContains(preflightResourceChecks, check) {
+				skip = append(skip, check)
+			}
+		}
+	} else if !getPreflightEnabledFromResourceData(d) {
+		return nil
+	}
+
+	kubeVersion := common.DefKubernetesVersion
+	if opt, ok := d.GetOk("config.kube_version"); ok && len(opt.(string)) > 0 {
+		kubeVersion = opt.(string)
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+	cgroupDriver := common.DefCgroupDriver[engine]
+	if opt, ok := d.GetOk("config.cgroup_driver"); ok && len(opt.(string)) > 0 {
+		cgroupDriver = opt.(string)
+	}
+
+	opts := preflightOptions{
+		master:          master,
+		kubeVersion:     kubeVersion,
+		engine:          engine,
+		cgroupDriver:    cgroupDriver,
+		minCPUs:         getMinResourceFromResourceData(d, master, "cpus"),
+		minMemory:       getMinResourceFromResourceData(d, master, "memory"),
+		minDisk:         getMinResourceFromResourceData(d, master, "disk"),
+		skip:            append(skip, getPreflightSkipFromResourceData(d)...),
+		maxClockSkew:    getDurationFromResourceData(d, "preflight.0.max_clock_skew"),
+		ignoreResources: d.Get("ignore_resource_checks").(bool),
+	}
+	if getManageSwapFromResourceData(d) == "allow" {
+		opts.skip = append(opts.skip, "swap")
+	}
+
+	ports := preflightWorkerPorts
+	if master {
+		ports = preflightMasterPorts
+	}
+	portsStr := []string{}
+	for _, port := range ports {
+		portsStr = append(portsStr, strconv.Itoa(port))
+	}
+	script := fmt.Sprintf(preflightFactsScript, getKubeletRootDirFromResourceData(d), engine, strings.Join(portsStr, " "))
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		lines := []string{}
+		opts.started = time.Now()
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExecScript([]byte(script)),
+			func(s string) {
+				lines = append(lines, s)
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		opts.finished = time.Now()
+
+		facts := parsePreflightFacts(lines)
+		ssh.GetLoggerFromContext(ctx).Debug("preflight facts: %+v", facts)
+
+		actions := ssh.ActionList{ssh.DoMessageInfo("Preflight checks:")}
+		failed := []string{}
+		for _, r := range evaluatePreflight(facts, opts) {
+			switch {
+			case r.failed:
+				failed = append(failed, r.check)
+				actions = append(actions, ssh.DoMessageWarn("  %s", r))
+			case r.warning:
+				actions = append(actions, ssh.DoMessageWarn("  %s", r))
+			default:
+				actions = append(actions, ssh.DoMessageInfo("  %s", r))
+			}
+		}
+		// print the report before failing, as an error in the list would stop it
+		if res := actions.Apply(ctx); ssh.IsError(res) {
+			return res
+		}
+		if len(failed) > 0 {
+			return ssh.ActionError(fmt.Sprintf("preflight checks failed: %s (they can be skipped with `preflight.skip`, or with `ignore_resource_checks` for the resources)", strings.Join(failed, ", ")))
+		}
+		return nil
+	})
+}
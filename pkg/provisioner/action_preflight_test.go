@@ -0,0 +1,201 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestEvaluatePreflight(t *testing.T) {
+	facts := parsePreflightFacts([]string{
+		"cpus=1",
+		"memory=2048",
+		"swap=off",
+		"br_netfilter=ok",
+		"cgroups=v2",
+		"time_sync=unknown",
+		"port_6443=used",
+		"port_10250=free",
+		"some garbage",
+	})
+
+	failedChecks := func(results []preflightResult) map[string]bool {
+		res := map[string]bool{}
+		for _, r := range results {
+			if r.failed {
+				res[r.check] = true
+			}
+		}
+		return res
+	}
+
+	// a master with an old Kubernetes version
+	failed := failedChecks(evaluatePreflight(facts, preflightOptions{master: true, kubeVersion: "v1.15.0"}))
+	for _, check := range []string{"ports", "cgroups", "cpus"} {
+		if !failed[check] {
+			t.Fatalf("Error: check %q should have failed: %+v", check, failed)
+		}
+	}
+	for _, check := range []string{"swap", "br_netfilter", "time_sync", "memory"} {
+		if failed[check] {
+			t.Fatalf("Error: check %q should not have failed: %+v", check, failed)
+		}
+	}
+
+	// a worker with a recent Kubernetes version
+	failed = failedChecks(evaluatePreflight(facts, preflightOptions{master: false, kubeVersion: "v1.26.1"}))
+	if len(failed) > 0 {
+		t.Fatalf("Error: no check should have failed for the worker: %+v", failed)
+	}
+
+	// overrides and skipped checks
+	results := evaluatePreflight(facts, preflightOptions{
+		master:      true,
+		kubeVersion: "v1.15.0",
+		minCPUs:     1,
+		minMemory:   4096,
+		skip:        []string{"ports", "cgroups"},
+	})
+	failed = failedChecks(results)
+	if len(failed) != 1 || !failed["memory"] {
+		t.Fatalf("Error: only the memory check should have failed: %+v", failed)
+	}
+	for _, r := range results {
+		if r.check == "ports" || r.check == "cgroups" {
+			t.Fatalf("Error: check %q should have been skipped", r.check)
+		}
+	}
+}
+
+func TestEvaluatePreflightCgroupDriver(t *testing.T) {
+	check := func(driver string, opts preflightOptions) preflightResult {
+		opts.kubeVersion = "v1.26.1"
+		for _, r := range evaluatePreflight(parsePreflightFacts([]string{"cgroup_driver=" + driver}), opts) {
+			if r.check == "cgroup_driver" {
+				return r
+			}
+		}
+		t.Fatalf("Error: no cgroup_driver check found")
+		return preflightResult{}
+	}
+
+	if r := check("systemd", preflightOptions{engine: "containerd", cgroupDriver: "systemd"}); r.failed || r.warning {
+		t.Fatalf("Error: the same cgroup driver should not fail: %s", r)
+	}
+	if r := check("systemd", preflightOptions{engine: "docker", cgroupDriver: "cgroupfs"}); !r.failed {
+		t.Fatalf("Error: a cgroup driver mismatch should fail: %s", r)
+	}
+	if r := check("unknown", preflightOptions{engine: "crio", cgroupDriver: "systemd"}); r.failed || !r.warning {
+		t.Fatalf("Error: an unknown cgroup driver should only be a warning: %s", r)
+	}
+}
+
+func TestEvaluatePreflightClockSkew(t *testing.T) {
+	started := time.Unix(1700000000, 0)
+	finished := started.Add(2 * time.Second)
+
+	check := func(nodeTime string, opts preflightOptions) preflightResult {
+		opts.kubeVersion = "v1.26.1"
+		for _, r := range evaluatePreflight(parsePreflightFacts([]string{"time=" + nodeTime}), opts) {
+			if r.check == "clock_skew" {
+				return r
+			}
+		}
+		t.Fatalf("Error: no clock_skew check found")
+		return preflightResult{}
+	}
+
+	opts := preflightOptions{started: started, finished: finished}
+	for _, nodeTime := range []string{"1699999996", "1700000001", "1700000007"} {
+		if r := check(nodeTime, opts); r.failed || r.warning {
+			t.Fatalf("Error: a node time of %s should not fail: %s", nodeTime, r)
+		}
+	}
+	for _, nodeTime := range []string{"1699999990", "1700000010"} {
+		if r := check(nodeTime, opts); !r.failed {
+			t.Fatalf("Error: a node time of %s should fail: %s", nodeTime, r)
+		}
+	}
+	opts.maxClockSkew = 10 * time.Second
+	if r := check("1700000010", opts); r.failed {
+		t.Fatalf("Error: a custom maximum clock skew should not fail: %s", r)
+	}
+	if r := check("1700000001", preflightOptions{}); r.failed || !r.warning {
+		t.Fatalf("Error: an unknown clock skew should only be a warning: %s", r)
+	}
+
+	if skew := getClockSkew(1699999990, started, finished); skew != -9*time.Second {
+		t.Fatalf("Error: unexpected clock skew: %s", skew)
+	}
+	if skew := getClockSkew(1700000010, started, finished); skew != 8*time.Second {
+		t.Fatalf("Error: unexpected clock skew: %s", skew)
+	}
+}
+
+func TestEvaluatePreflightResources(t *testing.T) {
+	facts := parsePreflightFacts([]string{"cpus=2", "memory=2048", "disk=10240"})
+
+	results := map[string]preflightResult{}
+	for _, r := range evaluatePreflight(facts, preflightOptions{kubeVersion: "v1.26.1", minCPUs: 4, minDisk: 20}) {
+		results[r.check] = r
+	}
+	for _, check := range []string{"cpus", "disk"} {
+		if !results[check].failed {
+			t.Fatalf("Error: check %q should have failed: %s", check, results[check])
+		}
+	}
+	if results["memory"].failed {
+		t.Fatalf("Error: the memory check should not have failed: %s", results["memory"])
+	}
+
+	// the resources checks are only warnings when ignored
+	for _, r := range evaluatePreflight(facts, preflightOptions{kubeVersion: "v1.26.1", minCPUs: 4, minDisk: 20, ignoreResources: true}) {
+		if r.check != "cpus" && r.check != "memory" && r.check != "disk" {
+			continue
+		}
+		if r.failed {
+			t.Fatalf("Error: check %q should not have failed: %s", r.check, r)
+		}
+		if (r.check == "cpus" || r.check == "disk") && !r.warning {
+			t.Fatalf("Error: check %q should be a warning: %s", r.check, r)
+		}
+	}
+}
+
+func TestMinResourceFromResourceData(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"min_master_cpus": "4",
+			"min_worker_disk": "50",
+		},
+		"preflight": []interface{}{
+			map[string]interface{}{"min_disk": 100},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	if v := getMinResourceFromResourceData(d, true, "cpus"); v != 4 {
+		t.Fatalf("Error: unexpected minimum CPUs for a master: %d", v)
+	}
+	if v := getMinResourceFromResourceData(d, false, "cpus"); v != 0 {
+		t.Fatalf("Error: unexpected minimum CPUs for a worker: %d", v)
+	}
+	if v := getMinResourceFromResourceData(d, false, "disk"); v != 100 {
+		t.Fatalf("Error: the preflight block should override the minimum disk: %d", v)
+	}
+}
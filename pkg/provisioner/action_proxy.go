@@ -0,0 +1,107 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// proxyDropinName is the name of the systemd drop-in with the proxy environment
+const proxyDropinName = "http-proxy.conf"
+
+// getProxyEnv returns the proxy environment variables (in upper and lower case),
+// adding some extra hosts (ie, the node address) to the NO_PROXY
+func getProxyEnv(d *schema.ResourceData, extraNoProxy ...string) map[string]string {
+	env := map[string]string{}
+
+	httpProxy := d.Get("config.proxy_http").(string)
+	httpsProxy := d.Get("config.proxy_https").(string)
+	if len(httpProxy) == 0 && len(httpsProxy) == 0 {
+		return env
+	}
+
+	noProxy := []string{}
+	if s := d.Get("config.proxy_no_proxy").(string); len(s) > 0 {
+		noProxy = strings.Split(s, ",")
+	}
+	for _, h := range extraNoProxy {
+		if len(h) > 0 {
+			noProxy = append(noProxy, h)
+		}
+	}
+
+	set := func(name, value string) {
+		if len(value) > 0 {
+			env[strings.ToUpper(name)] = value
+			env[strings.ToLower(name)] = value
+		}
+	}
+	set("HTTP_PROXY", httpProxy)
+	set("HTTPS_PROXY", httpsProxy)
+	set("NO_PROXY", strings.Join(noProxy, ","))
+	return env
+}
+
+// getProxyDropinCode returns a systemd drop-in with the proxy environment
+func getProxyDropinCode(env map[string]string) []byte {
+	keys := []string{}
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{"[Service]"}
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("Environment=\"%s=%s\"", k, env[k]))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// doConfigureProxy writes the proxy environment in systemd drop-ins
+// for the container runtime and the kubelet
+// NOTE: the runtime is restarted later on, in doPrepareCRI()
+func doConfigureProxy(d *schema.ResourceData, nodeAddress string) ssh.Action {
+	env := getProxyEnv(d, nodeAddress)
+	if len(env) == 0 {
+		return nil
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+
+	code := getProxyDropinCode(env)
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Configuring the HTTP/HTTPS proxy..."),
+	}
+	// (the runtime engines are named after their services)
+	for _, service := range []string{engine, "kubelet"} {
+		dir := fmt.Sprintf("/etc/systemd/system/%s.service.d", service)
+		actions = append(actions,
+			ssh.DoMkdir(dir),
+			ssh.DoUploadBytesToFile(code, path.Join(dir, proxyDropinName)))
+	}
+	actions = append(actions, ssh.DoExec("systemctl daemon-reload"))
+	return actions
+}
@@ -0,0 +1,54 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestProxyEnv(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"proxy_http":     "http://proxy.internal:3128",
+			"proxy_no_proxy": "localhost,10.244.0.0/16",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	env := getProxyEnv(d, "192.168.1.10")
+	if env["HTTP_PROXY"] != "http://proxy.internal:3128" || env["http_proxy"] != env["HTTP_PROXY"] {
+		t.Fatalf("Error: unexpected HTTP proxy: %+v", env)
+	}
+	if _, ok := env["HTTPS_PROXY"]; ok {
+		t.Fatalf("Error: no HTTPS proxy expected: %+v", env)
+	}
+	if env["NO_PROXY"] != "localhost,10.244.0.0/16,192.168.1.10" {
+		t.Fatalf("Error: unexpected NO_PROXY: %q", env["NO_PROXY"])
+	}
+
+	code := string(getProxyDropinCode(env))
+	if !strings.HasPrefix(code, "[Service]\n") || !strings.Contains(code, `Environment="HTTP_PROXY=http://proxy.internal:3128"`) {
+		t.Fatalf("Error: unexpected drop-in:\n%s", code)
+	}
+
+	// no proxy configured
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if env := getProxyEnv(d, "192.168.1.10"); len(env) > 0 {
+		t.Fatalf("Error: no proxy environment expected: %+v", env)
+	}
+}
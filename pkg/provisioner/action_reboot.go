@@ -0,0 +1,154 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// rebootRequiredScript prints "reboot_required=<reason>" when the node must be rebooted
+// (ie, after installing a new kernel), or "reboot_required=no" otherwise
+const rebootRequiredScript = `#!/bin/sh
+if [ -f /var/run/reboot-required ] ; then
+	echo "reboot_required=reboot-required flag"
+elif [ -f /run/reboot-needed ] ; then
+	echo "reboot_required=reboot-needed flag"
+elif command -v needs-restarting >/dev/null 2>&1 && ! needs-restarting -r >/dev/null 2>&1 ; then
+	echo "reboot_required=needs-restarting"
+elif command -v zypper >/dev/null 2>&1 && ! zypper needs-rebooting >/dev/null 2>&1 ; then
+	echo "reboot_required=zypper needs-rebooting"
+elif grep -qs 'systemd.unified_cgroup_hierarchy=1' /etc/default/grub && \
+	! grep -qs 'systemd.unified_cgroup_hierarchy=1' /proc/cmdline ; then
+	echo "reboot_required=cgroups v2 enabled in the kernel command line"
+else
+	echo "reboot_required=no"
+fi
+exit 0
+`
+
+// rebootScript reboots the node in the background, so the command returns
+// before the connection is dropped
+const rebootScript = `#!/bin/sh
+nohup sh -c 'sleep 2 ; systemctl reboot || reboot' >/dev/null 2>&1 &
+exit 0
+`
+
+// command for getting an ID that changes on every boot
+const bootIDCmd = "cat /proc/sys/kernel/random/boot_id"
+
+// interval for checking if the node is back after a reboot
+const rebootCheckInterval = 10 * time.Second
+
+// isRebootEnabled returns true if the node must be rebooted when required
+func isRebootEnabled(d *schema.ResourceData) bool {
+	if _, ok := d.GetOk("reboot"); !ok {
+		return false
+	}
+	return d.Get("reboot.0.enabled").(bool)
+}
+
+// getRebootTimeoutFromResourceData returns the maximum time for the node to come back
+func getRebootTimeoutFromResourceData(d *schema.ResourceData) time.Duration {
+	if timeout := getDurationFromResourceData(d, "reboot.0.timeout"); timeout > 0 {
+		return timeout
+	}
+	return 10 * time.Minute
+}
+
+// getRebootReason returns the reason for rebooting the node in the output
+// of `rebootRequiredScript` (or an empty string when no reboot is required)
+func getRebootReason(lines []string) string {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "reboot_required=") {
+			if reason := strings.TrimPrefix(line, "reboot_required="); reason != "no" {
+				return reason
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// getRemoteOutput runs a command (or script) in the node and returns its output
+func getRemoteOutput(ctx context.Context, action ssh.Action) ([]string, ssh.Action) {
+	lines := []string{}
+	res := ssh.DoSendingExecOutputToFunc(action, func(s string) {
+		lines = append(lines, s)
+	}).Apply(ctx)
+	return lines, res
+}
+
+// doRebootIfRequired reboots the node when some setup action requires it (ie, a
+// kernel upgrade pulled by some package), waiting until the node is reachable again
+// (local containers cannot be rebooted)
+func doRebootIfRequired(d *schema.ResourceData, connType string) ssh.Action {
+	if !isRebootEnabled(d) {
+		return nil
+	}
+	if isLocalConnType(connType) {
+		return ssh.DoMessageWarn("local containers cannot be rebooted: ignoring the 'reboot' block")
+	}
+
+	timeout := getRebootTimeoutFromResourceData(d)
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		lines, res := getRemoteOutput(ctx, ssh.DoExecScript([]byte(rebootRequiredScript)))
+		if ssh.IsError(res) {
+			return res
+		}
+		reason := getRebootReason(lines)
+		if len(reason) == 0 {
+			return ssh.DoMessageInfo("No reboot required.")
+		}
+
+		lines, res = getRemoteOutput(ctx, ssh.DoExec(bootIDCmd))
+		if ssh.IsError(res) {
+			return res
+		}
+		bootID := strings.TrimSpace(strings.Join(lines, ""))
+
+		// the node is back when we can run a command and the boot ID has changed
+		checkRebooted := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			lines, res := getRemoteOutput(ctx, ssh.DoExec(bootIDCmd))
+			if ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("the node is not reachable yet: %s", res.Error()))
+			}
+			if current := strings.TrimSpace(strings.Join(lines, "")); len(current) == 0 || current == bootID {
+				return ssh.ActionError("the node has not been rebooted yet")
+			}
+			return nil
+		})
+
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Rebooting the node (%s)...", reason),
+			ssh.DoExecScript([]byte(rebootScript)),
+			ssh.DoMessageInfo("Waiting for the node to come back (for up to %s)...", timeout),
+			ssh.DoWithException(
+				ssh.DoRetry(
+					ssh.Retry{Times: int(timeout/rebootCheckInterval) + 1, Interval: rebootCheckInterval},
+					checkRebooted),
+				ssh.DoMessageWarn("the node did not come back after %s (see 'reboot.timeout' in the provisioner)", timeout)),
+			ssh.DoMessageInfo("The node has been rebooted."),
+		}
+	})
+}
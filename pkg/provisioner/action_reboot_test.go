@@ -0,0 +1,60 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetRebootReason(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{[]string{"reboot_required=no"}, ""},
+		{[]string{"some output", " reboot_required=reboot-required flag "}, "reboot-required flag"},
+		{[]string{"reboot_required=needs-restarting", "reboot_required=no"}, "needs-restarting"},
+		{[]string{"garbage"}, ""},
+	}
+
+	for i, test := range tests {
+		if reason := getRebootReason(test.lines); reason != test.expected {
+			t.Fatalf("Error: test %d: unexpected reboot reason %q", i, reason)
+		}
+	}
+}
+
+func TestRebootFromResourceData(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if isRebootEnabled(d) {
+		t.Fatalf("Error: the reboot should be disabled by default")
+	}
+
+	raw := map[string]interface{}{
+		"reboot": []interface{}{
+			map[string]interface{}{"timeout": "5m"},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if !isRebootEnabled(d) {
+		t.Fatalf("Error: the reboot should be enabled with a 'reboot' block")
+	}
+	if timeout := getRebootTimeoutFromResourceData(d); timeout != 5*time.Minute {
+		t.Fatalf("Error: unexpected reboot timeout: %s", timeout)
+	}
+}
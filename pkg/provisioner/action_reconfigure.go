@@ -0,0 +1,121 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// the file where kubeadm saves the kubelet flags
+var kubeletFlagsFilename = path.Join(common.DefKubeletRootDir, "kubeadm-flags.env")
+
+// getKubeletFlagsCode returns a script that updates (or adds) the kubelet
+// args in the kubeadm flags file, restarting the kubelet
+func getKubeletFlagsCode(args map[string]string) []byte {
+	keys := []string{}
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString("#!/bin/sh\nset -e\n")
+	b.WriteString(fmt.Sprintf("FLAGS=%q\n", kubeletFlagsFilename))
+	b.WriteString("[ -f \"$FLAGS\" ] || { echo \">>> $FLAGS not found: is this node part of a cluster?\" ; exit 1 ; }\n")
+	b.WriteString(". \"$FLAGS\"\n")
+	b.WriteString("ARGS=\"$KUBELET_KUBEADM_ARGS\"\n")
+	for _, k := range keys {
+		sedExpr := ssh.ShellQuote(fmt.Sprintf("s|--%s=[^ ]*||g", k))
+		b.WriteString(fmt.Sprintf("VALUE=%s\n", ssh.ShellQuote(args[k])))
+		b.WriteString(fmt.Sprintf("ARGS=\"$(printf '%%s\\n' \"$ARGS\" | sed -e %s) --%s=$VALUE\"\n", sedExpr, k))
+	}
+	b.WriteString("ARGS=\"$(printf '%s\\n' \"$ARGS\" | sed -e 's|^ *||')\"\n")
+	b.WriteString("printf 'KUBELET_KUBEADM_ARGS=\"%s\"\\n' \"$ARGS\" > \"$FLAGS\"\n")
+	b.WriteString("echo \">>> restarting the kubelet\"\n")
+	b.WriteString("systemctl restart kubelet\n")
+	return b.Bytes()
+}
+
+// doKubeadmReconfigure applies the current configuration in a node that is already
+// in the cluster (for settings that have been updated in the kubeadm resource):
+// * in the first master, the `kubeadm-config` and `kubelet-config` ConfigMaps are updated
+//   the manifests in the `kubeadm` resource are applied again and the CoreDNS image is updated.
+// * in the masters, the control plane static pods are re-created from the `kubeadm-config`.
+// * in all the nodes, the kubelet configuration is downloaded from the `kubelet-config`,
+//   the kubelet args are updated and the kubelet is restarted.
+func doKubeadmReconfigure(d *schema.ResourceData, isMaster bool, isSeeder bool) ssh.Action {
+	var kubeletArgs map[string]string
+	if isSeeder {
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for init'ing: %s", err))
+		}
+		kubeletArgs = initConfig.NodeRegistration.KubeletExtraArgs
+	} else {
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
+		}
+		kubeletArgs = joinConfig.NodeRegistration.KubeletExtraArgs
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Reconfiguring the node with the current cluster configuration..."),
+	}
+
+	if isSeeder {
+		actions = append(actions,
+			ssh.DoMessageInfo("Updating the kubeadm-config and kubelet-config ConfigMaps..."),
+			doUploadKubeadmConfig(d, "init", common.DefKubeadmInitConfPath),
+			doExecKubeadmWithConfig(d, "init phase upload-config all", "",
+				fmt.Sprintf("--config=%s", common.DefKubeadmInitConfPath)),
+			ssh.DoTry(ssh.DoMoveFile(common.DefKubeadmInitConfPath, common.DefKubeadmInitConfPath+".bak")),
+			doLoadClusterManifests(d),
+			doUpdateCoreDNSImage(d),
+		)
+	}
+
+	if isMaster {
+		actions = append(actions,
+			ssh.DoMessageInfo("Re-creating the control plane static pods..."),
+			doUploadStaticPodPatches(d),
+			doExecKubeadmWithConfig(d, "upgrade node phase control-plane", "", getStaticPodPatchesArgs(d)...),
+		)
+	}
+
+	actions = append(actions,
+		ssh.DoMessageInfo("Updating the kubelet configuration..."),
+		doExecKubeadmWithConfig(d, "upgrade node phase kubelet-config", ""),
+	)
+
+	if len(kubeletArgs) > 0 {
+		actions = append(actions,
+			ssh.DoMessageInfo("Updating the kubelet args..."),
+			ssh.DoExecScript(getKubeletFlagsCode(kubeletArgs)),
+		)
+	} else {
+		actions = append(actions, ssh.DoRestartService("kubelet.service"))
+	}
+
+	return actions
+}
@@ -0,0 +1,83 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetKubeletFlagsCode(t *testing.T) {
+	code := string(getKubeletFlagsCode(map[string]string{
+		"feature-gates": "RotateKubeletServerCertificate=true",
+		"max-pods":      "200",
+	}))
+
+	for _, expected := range []string{
+		`FLAGS="/var/lib/kubelet/kubeadm-flags.env"`,
+		`VALUE='RotateKubeletServerCertificate=true'`,
+		`sed -e 's|--feature-gates=[^ ]*||g') --feature-gates=$VALUE"`,
+		`sed -e 's|--max-pods=[^ ]*||g') --max-pods=$VALUE"`,
+		"systemctl restart kubelet",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("Error: %q not found in script:\n%s", expected, code)
+		}
+	}
+
+	// the args must be sorted, so the script is stable
+	if strings.Index(code, "--feature-gates") > strings.Index(code, "--max-pods") {
+		t.Fatalf("Error: args are not sorted:\n%s", code)
+	}
+}
+
+func TestGetKubeletFlagsCodeQuoting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeadm-flags")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	flags := filepath.Join(dir, "kubeadm-flags.env")
+	current := `KUBELET_KUBEADM_ARGS="--max-pods=110 --node-labels=a=b"` + "\n"
+	if err := ioutil.WriteFile(flags, []byte(current), 0644); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	// values with some characters used by the shell and sed must not break the script
+	code := string(getKubeletFlagsCode(map[string]string{
+		"max-pods":    "200",
+		"node-labels": "zone=a|b,tier=$(front)'s",
+	}))
+	code = strings.Replace(code, kubeletFlagsFilename, flags, -1)
+	code = strings.Replace(code, "systemctl restart kubelet", "true", -1)
+
+	if out, err := exec.Command("sh", "-c", code).CombinedOutput(); err != nil {
+		t.Fatalf("Error: the script failed: %s\n%s\n%s", err, out, code)
+	}
+
+	res, err := ioutil.ReadFile(flags)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := `KUBELET_KUBEADM_ARGS="--max-pods=200 --node-labels=zone=a|b,tier=$(front)'s"` + "\n"
+	if string(res) != expected {
+		t.Fatalf("Error: unexpected flags file:\n%s\nexpected:\n%s", res, expected)
+	}
+}
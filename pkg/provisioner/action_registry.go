@@ -0,0 +1,242 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getRegistriesFromResourceData returns the registries configured in the `kubeadm` resource
+func getRegistriesFromResourceData(d *schema.ResourceData) ([]common.RegistrySpec, error) {
+	opt, ok := d.GetOk("config.registries")
+	if !ok || len(opt.(string)) == 0 {
+		return []common.RegistrySpec{}, nil
+	}
+	return common.RegistriesFromTerraformSafeString(opt.(string))
+}
+
+// registryHosts returns the hosts for a registry: the upstream and the mirrors
+func registryHosts(r common.RegistrySpec) []string {
+	hosts := []string{common.RegistryHost(r.Upstream)}
+	for _, mirror := range r.Mirrors {
+		hosts = append(hosts, common.RegistryHost(mirror))
+	}
+	return hosts
+}
+
+// registryURL returns a URL for a mirror, adding a scheme when not present
+func registryURL(mirror string) string {
+	if strings.Contains(mirror, "://") {
+		return mirror
+	}
+	return "https://" + mirror
+}
+
+// getContainerdHostsCode returns the containerd hosts.toml for a registry
+func getContainerdHostsCode(r common.RegistrySpec) []byte {
+	upstream := common.RegistryHost(r.Upstream)
+	caPath := path.Join(common.DefContainerdCertsDir, upstream, "ca.crt")
+
+	server := "https://" + upstream
+	if upstream == "docker.io" {
+		server = "https://registry-1.docker.io"
+	}
+
+	lines := []string{fmt.Sprintf("server = %q", server)}
+	if len(r.CA) > 0 {
+		lines = append(lines, fmt.Sprintf("ca = %q", caPath))
+	}
+	for _, mirror := range r.Mirrors {
+		lines = append(lines, "", fmt.Sprintf("[host.%q]", registryURL(mirror)))
+		lines = append(lines, `  capabilities = ["pull", "resolve"]`)
+		if len(r.CA) > 0 {
+			lines = append(lines, fmt.Sprintf("  ca = %q", caPath))
+		}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// getCrioRegistryCode returns the registries.conf.d file for a registry in CRI-O
+func getCrioRegistryCode(r common.RegistrySpec) []byte {
+	upstream := common.RegistryHost(r.Upstream)
+	lines := []string{
+		"[[registry]]",
+		fmt.Sprintf("prefix = %q", upstream),
+		fmt.Sprintf("location = %q", upstream),
+	}
+	for _, mirror := range r.Mirrors {
+		lines = append(lines, "", "[[registry.mirror]]", fmt.Sprintf("location = %q", common.RegistryHost(mirror)))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// getDockerDaemonCode returns the docker daemon.json, with the mirrors for the Docker Hub
+// (docker does not support mirrors for other registries) and, optionally, the cgroup driver
+func getDockerDaemonCode(registries []common.RegistrySpec, cgroupDriver string) []byte {
+	mirrors := []string{}
+	for _, r := range registries {
+		if common.RegistryHost(r.Upstream) != "docker.io" {
+			continue
+		}
+		for _, mirror := range r.Mirrors {
+			mirrors = append(mirrors, registryURL(mirror))
+		}
+	}
+	if len(mirrors) == 0 {
+		return nil
+	}
+
+	daemon := map[string]interface{}{"registry-mirrors": mirrors}
+	if len(cgroupDriver) > 0 {
+		daemon["exec-opts"] = []string{"native.cgroupdriver=" + cgroupDriver}
+	}
+	data, _ := json.MarshalIndent(daemon, "", "  ")
+	return append(data, '\n')
+}
+
+// getDockerDaemonCgroupCode returns the daemon.json written by the setup script
+// when it only sets the cgroup driver
+func getDockerDaemonCgroupCode(cgroupDriver string) string {
+	return fmt.Sprintf(`{"exec-opts":["native.cgroupdriver=%s"]}`, cgroupDriver)
+}
+
+// getRegistriesAuthCode returns a docker-like config.json with the credentials
+// for all the registries (and their mirrors)
+func getRegistriesAuthCode(registries []common.RegistrySpec) []byte {
+	auths := map[string]interface{}{}
+	for _, r := range registries {
+		if len(r.Username) == 0 {
+			continue
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(r.Username + ":" + r.Password))
+		for _, host := range registryHosts(r) {
+			auths[host] = map[string]string{"auth": auth}
+		}
+	}
+	if len(auths) == 0 {
+		return nil
+	}
+
+	data, _ := json.MarshalIndent(map[string]interface{}{"auths": auths}, "", "  ")
+	return append(data, '\n')
+}
+
+// doConfigureRegistries configures the mirrors, credentials and CAs for the
+// registries in the container runtime
+// NOTE: the runtime is restarted later on, in doPrepareCRI()
+func doConfigureRegistries(d *schema.ResourceData) ssh.Action {
+	registries, err := getRegistriesFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not parse the registries configuration: %s", err))
+	}
+	if len(registries) == 0 {
+		return nil
+	}
+
+	engine := common.DefRuntimeEngine
+	if opt, ok := d.GetOk("config.runtime_engine"); ok && len(opt.(string)) > 0 {
+		engine = opt.(string)
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo(fmt.Sprintf("Configuring %d registries for %s...", len(registries), engine)),
+	}
+
+	for _, r := range registries {
+		upstream := common.RegistryHost(r.Upstream)
+		switch engine {
+		case "containerd":
+			dir := path.Join(common.DefContainerdCertsDir, upstream)
+			actions = append(actions,
+				ssh.DoMkdir(dir),
+				ssh.DoUploadBytesToFile(getContainerdHostsCode(r), path.Join(dir, "hosts.toml")))
+			if len(r.CA) > 0 {
+				actions = append(actions, ssh.DoUploadBytesToFile([]byte(r.CA), path.Join(dir, "ca.crt")))
+			}
+		case "crio":
+			name := fmt.Sprintf("90-kubeadm-%s.conf", strings.Replace(upstream, ":", "-", -1))
+			actions = append(actions,
+				ssh.DoMkdir(common.DefCrioRegistriesDir),
+				ssh.DoUploadBytesToFile(getCrioRegistryCode(r), path.Join(common.DefCrioRegistriesDir, name)))
+		}
+
+		if len(r.CA) > 0 && engine != "containerd" {
+			certsDir := common.DefDockerCertsDir
+			if engine == "crio" {
+				certsDir = common.DefCrioCertsDir
+			}
+			for _, host := range registryHosts(r) {
+				dir := path.Join(certsDir, host)
+				actions = append(actions,
+					ssh.DoMkdir(dir),
+					ssh.DoUploadBytesToFile([]byte(r.CA), path.Join(dir, "ca.crt")))
+			}
+		}
+	}
+
+	switch engine {
+	case "containerd":
+		// make sure containerd looks for the hosts.toml files
+		actions = append(actions,
+			ssh.DoExec(fmt.Sprintf("sed -i -e 's|config_path = \"\"|config_path = \"%s\"|' %s",
+				common.DefContainerdCertsDir, common.DefContainerdConfigPath)))
+	case "docker":
+		if code := getDockerDaemonCode(registries, ""); code != nil {
+			// the setup script could have written a daemon.json with just the cgroup driver:
+			// we can replace it as long as we keep the same driver
+			driver := d.Get("config.cgroup_driver").(string)
+			if len(driver) == 0 {
+				driver = common.DefCgroupDriver[engine]
+			}
+			isCgroupOnly := ssh.CheckExec(fmt.Sprintf(`[ "$(tr -d ' \n' < %s)" = '%s' ]`,
+				common.DefDockerDaemonConfigPath, getDockerDaemonCgroupCode(driver)))
+
+			actions = append(actions,
+				ssh.DoIfElse(
+					ssh.CheckFileExists(common.DefDockerDaemonConfigPath),
+					ssh.DoIfElse(
+						isCgroupOnly,
+						ssh.DoUploadBytesToFile(getDockerDaemonCode(registries, driver), common.DefDockerDaemonConfigPath),
+						ssh.DoMessageWarn(fmt.Sprintf("%s already exists: the Docker Hub mirrors must be added manually", common.DefDockerDaemonConfigPath))),
+					ssh.DoUploadBytesToFile(code, common.DefDockerDaemonConfigPath)))
+		}
+	}
+
+	// the kubelet uses these credentials for pulling images (with any runtime)
+	// (the kubelet looks for them in its root directory)
+	if code := getRegistriesAuthCode(registries); code != nil {
+		kubeletRootDir := getKubeletRootDirFromResourceData(d)
+		actions = append(actions,
+			ssh.DoMkdir(kubeletRootDir),
+			ssh.DoUploadBytesToFile(code, path.Join(kubeletRootDir, "config.json")))
+		if engine == "docker" {
+			// ... and docker uses these ones for the images pulled by kubeadm
+			actions = append(actions,
+				ssh.DoMkdir("/root/.docker"),
+				ssh.DoUploadBytesToFile(code, "/root/.docker/config.json"))
+		}
+	}
+
+	return actions
+}
@@ -0,0 +1,86 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestRegistries(t *testing.T) {
+	registries := []common.RegistrySpec{
+		{
+			Upstream: "docker.io",
+			Mirrors:  []string{"https://mirror.internal:5000"},
+			Username: "user",
+			Password: "secret",
+			CA:       "-----BEGIN CERTIFICATE-----",
+		},
+		{
+			Upstream: "quay.io",
+			Mirrors:  []string{"quay-mirror.internal"},
+		},
+	}
+
+	s, err := common.RegistriesToTerraformSafeString(registries)
+	if err != nil {
+		t.Fatalf("Error: could not serialize the registries: %s", err)
+	}
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"registries": s,
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	parsed, err := getRegistriesFromResourceData(d)
+	if err != nil || len(parsed) != 2 || parsed[0].Password != "secret" {
+		t.Fatalf("Error: unexpected registries: %+v (%v)", parsed, err)
+	}
+
+	hosts := string(getContainerdHostsCode(registries[0]))
+	for _, expected := range []string{
+		`server = "https://registry-1.docker.io"`,
+		`[host."https://mirror.internal:5000"]`,
+		`ca = "/etc/containerd/certs.d/docker.io/ca.crt"`,
+	} {
+		if !strings.Contains(hosts, expected) {
+			t.Fatalf("Error: %q not found in hosts.toml:\n%s", expected, hosts)
+		}
+	}
+
+	crio := string(getCrioRegistryCode(registries[1]))
+	if !strings.Contains(crio, `prefix = "quay.io"`) || !strings.Contains(crio, `location = "quay-mirror.internal"`) {
+		t.Fatalf("Error: unexpected CRI-O registries configuration:\n%s", crio)
+	}
+
+	daemon := string(getDockerDaemonCode(registries, ""))
+	if !strings.Contains(daemon, "https://mirror.internal:5000") || strings.Contains(daemon, "quay-mirror") || strings.Contains(daemon, "exec-opts") {
+		t.Fatalf("Error: unexpected docker daemon.json:\n%s", daemon)
+	}
+
+	daemon = string(getDockerDaemonCode(registries, "systemd"))
+	if !strings.Contains(daemon, "native.cgroupdriver=systemd") {
+		t.Fatalf("Error: cgroup driver not found in docker daemon.json:\n%s", daemon)
+	}
+
+	auth := string(getRegistriesAuthCode(registries))
+	if !strings.Contains(auth, `"mirror.internal:5000"`) || !strings.Contains(auth, `"docker.io"`) || strings.Contains(auth, "quay.io") {
+		t.Fatalf("Error: unexpected credentials:\n%s", auth)
+	}
+}
@@ -15,13 +15,21 @@
 package provisioner
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// osReleaseCmd gets the distro info in the node
+	osReleaseCmd = "cat /etc/os-release 2>/dev/null || true"
 )
 
 // doKubeadmSetup tries to install kubeadm in the remote machine
@@ -31,17 +39,17 @@ import (
 // 3) an inlined user-provided script
 func doKubeadmSetup(d *schema.ResourceData) ssh.Action {
 	if _, ok := d.GetOk("install"); ok {
+		if d.Get("install.0.auto").(bool) {
+			return doKubeadmSetupAuto(d)
+		}
+
 		code := ""
 		descr := ""
-		auto := d.Get("install.0.auto").(bool)
+		env := map[string]string{}
 		inline := d.Get("install.0.inline").(string)
 		script := d.Get("install.0.script").(string)
 
-		if auto {
-			ssh.Debug("will upload the builtin auto-installation script")
-			descr = "Uploading and running built-in kubeadm installation script..."
-			code = assets.KubeadmSetupScriptCode
-		} else if len(inline) > 0 {
+		if len(inline) > 0 {
 			ssh.Debug("will upload auto-installation script from inlined script: %d bytes", len(inline))
 			descr = "Uploading and running inlined installation script..."
 			code = "#!/bin/sh\n" + inline
@@ -56,12 +64,193 @@ func doKubeadmSetup(d *schema.ResourceData) ssh.Action {
 			code = string(contents)
 		}
 
+		// the installation could need some proxy for downloading packages
+		for k, v := range getProxyEnv(d) {
+			env[k] = v
+		}
+
 		return ssh.ActionList{
 			ssh.DoMessage(descr),
-			ssh.DoExecScript([]byte(code)),
+			ssh.DoExecScriptWithEnv([]byte(code), env),
 		}
 	}
 	return ssh.ActionList{
 		ssh.DoMessageWarn("no auto-installation: assuming kubeadm is installed in the target node."),
 	}
 }
+
+// doKubeadmSetupAuto runs the built-in auto-installation script, rendered for
+// the distro in this node (so a cluster can mix nodes with different distros)
+func doKubeadmSetupAuto(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		params := getSetupParamsFromResourceData(d)
+		if params.Distro == assets.SetupDistroAuto {
+			var res ssh.Action
+			params.Distro, params.Release, res = getNodeSetupDistro(ctx)
+			if ssh.IsError(res) {
+				return res
+			}
+		}
+
+		ssh.GetLoggerFromContext(ctx).Debug("will upload the builtin auto-installation script for %s", params.Distro)
+		code, err := assets.RenderSetupScript(params)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not render the kubeadm setup script: %s", err))
+		}
+		return ssh.ActionList{
+			ssh.DoMessage("Uploading and running built-in kubeadm installation script (for %s)...", params.Distro),
+			ssh.DoExecScriptWithEnv(code, getSetupEnv(d)),
+		}
+	})
+}
+
+// doDetectInstallMode switches to the "binaries" installation mode in the nodes where
+// the auto-installation script would install the release binaries (ie, in Flatcar), so
+// the kubelet service, the dropin, kubeadm and kubectl are used from the right paths.
+// It must be run before the other actions are created, as they get these paths.
+func doDetectInstallMode(d *schema.ResourceData) ssh.Action {
+	if !d.Get("install.0.auto").(bool) || getSetupParamsFromResourceData(d).Distro != assets.SetupDistroAuto {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		distro, _, res := getNodeSetupDistro(ctx)
+		if ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not detect the distro: using the %q installation mode", getInstallModeFromResourceData(d))
+		}
+		if distro != assets.SetupDistroBinaries {
+			return nil
+		}
+
+		ssh.GetLoggerFromContext(ctx).Debug("the release binaries will be installed in this distro: using the binaries mode")
+		install := d.Get("install").([]interface{})
+		install[0].(map[string]interface{})["mode"] = "binaries"
+		if err := d.Set("install", install); err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not set the installation mode: %s", err))
+		}
+		return nil
+	})
+}
+
+// getNodeSetupDistro gets the distro (and release) in the node from its /etc/os-release.
+// The output is received line by line, without the line breaks, so they are added here.
+func getNodeSetupDistro(ctx context.Context) (assets.SetupDistro, string, ssh.Action) {
+	lines := []string{}
+	res := ssh.DoSendingExecOutputToFunc(ssh.DoExec(osReleaseCmd), func(s string) {
+		lines = append(lines, s)
+	}).Apply(ctx)
+	if ssh.IsError(res) {
+		return assets.SetupDistroAuto, "", res
+	}
+	distro, release := getSetupDistroFromOSRelease(strings.Join(lines, "\n"))
+	return distro, release, nil
+}
+
+// getSetupParamsFromResourceData returns the parameters for rendering the auto-installation
+// script. The distro is only known here for the installations that do not depend on it.
+func getSetupParamsFromResourceData(d *schema.ResourceData) assets.SetupParams {
+	params := assets.SetupParams{
+		Distro:      assets.SetupDistroAuto,
+		KubeVersion: getInstallVersionFromResourceData(d),
+		Proxy:       getProxyEnv(d),
+		Repo:        getSetupRepoFromResourceData(d),
+	}
+	if engine, ok := d.GetOk("config.runtime_engine"); ok {
+		params.Runtime = engine.(string)
+	}
+	if len(getOfflineSetupEnv(d)) > 0 {
+		params.Distro = assets.SetupDistroOffline
+	} else if getInstallModeFromResourceData(d) == "binaries" {
+		params.Distro = assets.SetupDistroBinaries
+	}
+	return params
+}
+
+// getSetupEnv returns the environment for the auto-installation script, with
+// the settings that do not depend on the distro
+func getSetupEnv(d *schema.ResourceData) map[string]string {
+	env := map[string]string{}
+	if d.Get("config.cri_dockerd").(string) == "true" {
+		env["CRI_DOCKERD"] = "true"
+	}
+	if driver, ok := d.GetOk("config.cgroup_driver"); ok {
+		env["CGROUP_DRIVER"] = driver.(string)
+	}
+	if image, ok := d.GetOk("config.sandbox_image"); ok {
+		env["SANDBOX_IMAGE"] = image.(string)
+	}
+	if dir, ok := d.GetOk("config.containerd_root_dir"); ok {
+		env["CONTAINERD_ROOT_DIR"] = dir.(string)
+	}
+	if dir, ok := d.GetOk("config.containerd_state_dir"); ok {
+		env["CONTAINERD_STATE_DIR"] = dir.(string)
+	}
+	env["SELINUX"] = d.Get("selinux").(string)
+	if apparmor := d.Get("apparmor").(string); len(apparmor) > 0 {
+		env["APPARMOR"] = apparmor
+	}
+	for k, v := range getOfflineSetupEnv(d) {
+		env[k] = v
+	}
+	return env
+}
+
+// getSetupRepoFromResourceData returns the packages repositories (and their
+// signatures) configuration for the auto-installation script
+func getSetupRepoFromResourceData(d *schema.ResourceData) assets.SetupRepo {
+	repo := assets.SetupRepo{
+		URL:       d.Get("install.0.repo_url").(string),
+		GPGKey:    d.Get("install.0.repo_gpg_key").(string),
+		CRIOURL:   d.Get("install.0.crio_repo_url").(string),
+		DockerURL: d.Get("install.0.docker_repo_url").(string),
+	}
+	if _, ok := d.GetOk("install"); ok && !d.Get("install.0.repo_gpg_check").(bool) {
+		repo.SkipGPGCheck = true
+	} else if fp := d.Get("install.0.repo_gpg_fingerprint").(string); len(fp) > 0 {
+		repo.GPGFingerprint = common.NormalizeGPGFingerprint(fp)
+	}
+	return repo
+}
+
+// getSetupDistroFromOSRelease returns the distro (and its release) from the
+// contents of /etc/os-release, or `auto` when it must be detected by the script
+func getSetupDistroFromOSRelease(osRelease string) (assets.SetupDistro, string) {
+	vars := map[string]string{}
+	for _, line := range strings.Split(osRelease, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) == 2 {
+			vars[kv[0]] = strings.Trim(kv[1], `"'`)
+		}
+	}
+
+	id := strings.ToLower(vars["ID"])
+	like := strings.Fields(strings.ToLower(vars["ID_LIKE"]))
+	release := strings.SplitN(vars["VERSION_ID"], ".", 2)[0]
+	is := func(names ...string) bool {
+		for _, name := range names {
+			if id == name {
+				return true
+			}
+			for _, l := range like {
+				if l == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case id == "amzn":
+		return assets.SetupDistroAmazon, release
+	case is("flatcar", "coreos"):
+		return assets.SetupDistroBinaries, ""
+	case is("debian", "ubuntu"):
+		return assets.SetupDistroApt, release
+	case is("suse", "opensuse", "sles"):
+		return assets.SetupDistroZypper, release
+	case is("rhel", "centos", "fedora"):
+		return assets.SetupDistroYum, release
+	}
+	return assets.SetupDistroAuto, ""
+}
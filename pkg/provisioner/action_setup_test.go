@@ -0,0 +1,287 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestInstallModeBinaries(t *testing.T) {
+	raw := map[string]interface{}{
+		"install": []interface{}{
+			map[string]interface{}{
+				"auto": true,
+				"mode": "binaries",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	if p := getKubeadmFromResourceData(d); p != "/opt/bin/kubeadm" {
+		t.Fatalf("Error: unexpected kubeadm path: %q", p)
+	}
+	if p := getKubectlFromResourceData(d); p != "/opt/bin/kubectl" {
+		t.Fatalf("Error: unexpected kubectl path: %q", p)
+	}
+	if p := getServicePathFromResourceData(d); p != common.DefBinariesKubeletServicePath {
+		t.Fatalf("Error: unexpected kubelet.service path: %q", p)
+	}
+	if p := getDropinPathFromResourceData(d); p != common.DefBinariesKubeadmDropinPath {
+		t.Fatalf("Error: unexpected dropin path: %q", p)
+	}
+
+	service := string(getKubeletServiceCodeFromResourceData(d))
+	if strings.Contains(service, "/usr/bin/kubelet") || !strings.Contains(service, "/opt/bin/kubelet") {
+		t.Fatalf("Error: unexpected kubelet path in kubelet.service:\n%s", service)
+	}
+
+	// the default paths should be used with the packages mode
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if p := getKubeadmFromResourceData(d); p != common.DefKubeadmPath {
+		t.Fatalf("Error: unexpected kubeadm path: %q", p)
+	}
+	if p := getServicePathFromResourceData(d); p != common.DefKubeletServicePath {
+		t.Fatalf("Error: unexpected kubelet.service path: %q", p)
+	}
+}
+
+func TestDetectInstallMode(t *testing.T) {
+	cases := []struct {
+		osRelease string
+		mode      string
+	}{
+		{"NAME=\"Flatcar Container Linux by Kinvolk\"\nID=flatcar\nVERSION_ID=3510.2.0\n", "binaries"},
+		{"NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"22.04\"\n", "packages"},
+		{"", "packages"},
+	}
+	for _, c := range cases {
+		raw := map[string]interface{}{
+			"install": []interface{}{
+				map[string]interface{}{
+					"auto": true,
+				},
+			},
+		}
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+		ctx := ssh.NewTestingContextWithResponses([]string{c.osRelease})
+		if res := doDetectInstallMode(d).Apply(ctx); ssh.IsError(res) {
+			t.Fatalf("Error: %s", res.Error())
+		}
+		if mode := getInstallModeFromResourceData(d); mode != c.mode {
+			t.Fatalf("Error: unexpected installation mode for %q: %q", c.osRelease, mode)
+		}
+		if c.mode != "binaries" {
+			continue
+		}
+
+		// the paths used in the actions must be the ones for the release binaries
+		if p := getKubeadmFromResourceData(d); p != "/opt/bin/kubeadm" {
+			t.Fatalf("Error: unexpected kubeadm path: %q", p)
+		}
+		if p := getDropinPathFromResourceData(d); p != common.DefBinariesKubeadmDropinPath {
+			t.Fatalf("Error: unexpected dropin path: %q", p)
+		}
+		if dropin := string(getKubeadmDropinCodeFromResourceData(d)); strings.Contains(dropin, "/usr/bin/kubelet") {
+			t.Fatalf("Error: unexpected kubelet path in the dropin:\n%s", dropin)
+		}
+		if distro := getSetupParamsFromResourceData(d).Distro; distro != assets.SetupDistroBinaries {
+			t.Fatalf("Error: unexpected distro for the installation script: %q", distro)
+		}
+	}
+
+	// nothing is detected when the mode cannot change
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if action := doDetectInstallMode(d); action != nil {
+		t.Fatalf("Error: the installation mode should not be detected without the auto-installation")
+	}
+}
+
+func TestGetInstallVersion(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"kube_version": "v1.15.0",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if v := getInstallVersionFromResourceData(d); v != "v1.15.0" {
+		t.Fatalf("Error: unexpected version: %q", v)
+	}
+
+	raw["install"] = []interface{}{
+		map[string]interface{}{
+			"auto":    true,
+			"version": "v1.15.3",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if v := getInstallVersionFromResourceData(d); v != "v1.15.3" {
+		t.Fatalf("Error: unexpected version: %q", v)
+	}
+}
+
+func TestGetSetupRepo(t *testing.T) {
+	raw := map[string]interface{}{
+		"install": []interface{}{
+			map[string]interface{}{
+				"auto":                 true,
+				"repo_url":             "https://mirror.example.com/kubernetes/deb",
+				"repo_gpg_key":         "https://mirror.example.com/kubernetes.key",
+				"repo_gpg_fingerprint": "de15 b144 86cd 377b 9e87 6e1a 2346 54da 9a29 6436",
+				"crio_repo_url":        "https://mirror.example.com/crio",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	repo := getSetupRepoFromResourceData(d)
+	if repo.URL != "https://mirror.example.com/kubernetes/deb" ||
+		repo.GPGKey != "https://mirror.example.com/kubernetes.key" ||
+		repo.CRIOURL != "https://mirror.example.com/crio" {
+		t.Fatalf("Error: unexpected repositories for the setup script: %+v", repo)
+	}
+	if repo.GPGFingerprint != "DE15B14486CD377B9E876E1A234654DA9A296436" {
+		t.Fatalf("Error: unexpected fingerprint: %q", repo.GPGFingerprint)
+	}
+	if repo.SkipGPGCheck {
+		t.Fatalf("Error: signatures checks should be enabled by default: %+v", repo)
+	}
+
+	// the fingerprint is ignored when the signatures are not checked
+	raw["install"].([]interface{})[0].(map[string]interface{})["repo_gpg_check"] = false
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	repo = getSetupRepoFromResourceData(d)
+	if !repo.SkipGPGCheck {
+		t.Fatalf("Error: signatures checks should be disabled: %+v", repo)
+	}
+	if len(repo.GPGFingerprint) > 0 {
+		t.Fatalf("Error: unexpected fingerprint: %+v", repo)
+	}
+
+	// nothing should be set when there is no `install` block
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if repo := getSetupRepoFromResourceData(d); repo != (assets.SetupRepo{}) {
+		t.Fatalf("Error: unexpected repositories for the setup script: %+v", repo)
+	}
+}
+
+func TestGetSetupDistroFromOSRelease(t *testing.T) {
+	cases := []struct {
+		osRelease string
+		distro    assets.SetupDistro
+		release   string
+	}{
+		{"NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"22.04\"\n", assets.SetupDistroApt, "22"},
+		{"NAME=\"SLES\"\nID=\"sles\"\nID_LIKE=\"suse\"\nVERSION_ID=\"15.4\"\n", assets.SetupDistroZypper, "15"},
+		{"NAME=\"Rocky Linux\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.2\"\n", assets.SetupDistroYum, "9"},
+		{"NAME=\"Amazon Linux\"\nID=\"amzn\"\nID_LIKE=\"fedora\"\nVERSION_ID=\"2023\"\n", assets.SetupDistroAmazon, "2023"},
+		{"NAME=\"Flatcar Container Linux by Kinvolk\"\nID=flatcar\nVERSION_ID=3510.2.0\n", assets.SetupDistroBinaries, ""},
+		{"NAME=\"Arch Linux\"\nID=arch\n", assets.SetupDistroAuto, ""},
+		{"", assets.SetupDistroAuto, ""},
+	}
+	for _, c := range cases {
+		distro, release := getSetupDistroFromOSRelease(c.osRelease)
+		if distro != c.distro || release != c.release {
+			t.Fatalf("Error: unexpected distro for %q: %q %q", c.osRelease, distro, release)
+		}
+	}
+}
+
+func TestRenderSetupScript(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"kube_version":   "v1.28.2",
+			"runtime_engine": "containerd",
+			"proxy_http":     "http://proxy.example.com:3128",
+		},
+		"install": []interface{}{
+			map[string]interface{}{
+				"auto":     true,
+				"repo_url": "https://mirror.example.com/kubernetes",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	params := getSetupParamsFromResourceData(d)
+	if params.Distro != assets.SetupDistroAuto {
+		t.Fatalf("Error: the distro should be detected in the node: %q", params.Distro)
+	}
+
+	// a control plane in Ubuntu and a worker in SLES get their own scripts
+	params.Distro = assets.SetupDistroApt
+	ubuntu, err := assets.RenderSetupScript(params)
+	if err != nil {
+		t.Fatalf("Error: could not render the setup script: %s", err)
+	}
+	params.Distro, params.Release = assets.SetupDistroZypper, "15"
+	sles, err := assets.RenderSetupScript(params)
+	if err != nil {
+		t.Fatalf("Error: could not render the setup script: %s", err)
+	}
+
+	for _, script := range []string{string(ubuntu), string(sles)} {
+		for _, expected := range []string{
+			"KUBE_VERSION='v1.28.2'",
+			"RUNTIME='containerd'",
+			"PKG_REPO='https://mirror.example.com/kubernetes'",
+			"PKG_REPO_GPG_CHECK=true",
+			"export HTTP_PROXY='http://proxy.example.com:3128'",
+		} {
+			if !strings.Contains(script, expected) {
+				t.Fatalf("Error: %q not found in the setup script:\n%s", expected, script)
+			}
+		}
+	}
+	if !strings.Contains(string(ubuntu), "\ninstall_apt \"$RELEASE\"") || strings.Contains(string(ubuntu), "install_zypper()") {
+		t.Fatalf("Error: unexpected setup script for Ubuntu:\n%s", ubuntu)
+	}
+	if !strings.Contains(string(sles), "\ninstall_zypper \"$RELEASE\"") || strings.Contains(string(sles), "install_apt()") {
+		t.Fatalf("Error: unexpected setup script for SLES:\n%s", sles)
+	}
+
+	// versions before 1.24 are not in pkgs.k8s.io, so the legacy repositories are used for them
+	delete(raw["config"].(map[string]interface{}), "kube_version")
+	delete(raw["install"].([]interface{})[0].(map[string]interface{}), "repo_url")
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	params = getSetupParamsFromResourceData(d)
+	params.Distro = assets.SetupDistroApt
+	legacy, err := assets.RenderSetupScript(params)
+	if err != nil {
+		t.Fatalf("Error: could not render the setup script: %s", err)
+	}
+	for _, expected := range []string{
+		"KUBE_VERSION='v1.15.0'",
+		"PKG_REPO=''",
+		"PKG_APT_REPO=${PKG_REPO:-https://apt.kubernetes.io}",
+		"-lt 24 ] ; then\n    PKG_REPO_LEGACY=true",
+	} {
+		if !strings.Contains(string(legacy), expected) {
+			t.Fatalf("Error: %q not found in the setup script:\n%s", expected, legacy)
+		}
+	}
+
+	// the distro is not detected with the binaries
+	raw["install"].([]interface{})[0].(map[string]interface{})["mode"] = "binaries"
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if distro := getSetupParamsFromResourceData(d).Distro; distro != assets.SetupDistroBinaries {
+		t.Fatalf("Error: unexpected distro: %q", distro)
+	}
+}
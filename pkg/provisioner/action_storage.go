@@ -0,0 +1,86 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// storageMountScript is the script used for formatting and mounting a dedicated disk.
+// It must be idempotent: the device is only formatted when it has no filesystem
+// and the fstab entry (by UUID) is only added once.
+const storageMountScript = `#!/bin/sh
+set -e
+
+DEV="%s"
+MNT="%s"
+FS="%s"
+
+[ -b "$DEV" ] || { echo "$DEV is not a block device" ; exit 1 ; }
+
+if [ -z "$(blkid -o value -s TYPE $DEV)" ] ; then
+	echo "Formatting $DEV as $FS..."
+	mkfs -t $FS $DEV
+fi
+
+UUID="$(blkid -o value -s UUID $DEV)"
+TYPE="$(blkid -o value -s TYPE $DEV)"
+[ -n "$UUID" ] || { echo "could not get the UUID of $DEV" ; exit 1 ; }
+
+mkdir -p $MNT
+if ! grep -q "^UUID=$UUID " /etc/fstab ; then
+	echo "Adding $DEV (UUID=$UUID) to /etc/fstab"
+	echo "UUID=$UUID $MNT $TYPE defaults 0 2" >> /etc/fstab
+fi
+
+if ! mountpoint -q $MNT ; then
+	echo "Mounting $DEV at $MNT"
+	mount $MNT
+fi
+`
+
+// doPrepareStorage formats and mounts the dedicated disks for etcd and kubelet
+// (when provided) before kubeadm is run
+func doPrepareStorage(d *schema.ResourceData) ssh.Action {
+	fs := getStorageFilesystemFromResourceData(d)
+
+	mounts := []struct {
+		property string
+		dir      string
+	}{
+		{"storage.0.etcd_device", getEtcdDataDirFromResourceData(d)},
+		{"storage.0.kubelet_device", getKubeletRootDirFromResourceData(d)},
+	}
+
+	actions := ssh.ActionList{}
+	for _, mount := range mounts {
+		devOpt, ok := d.GetOk(mount.property)
+		if !ok || len(devOpt.(string)) == 0 {
+			continue
+		}
+		dev := devOpt.(string)
+
+		ssh.Debug("will use %q for %s", dev, mount.dir)
+		actions = append(actions,
+			ssh.DoMessageInfo(fmt.Sprintf("Preparing %s for %s...", dev, mount.dir)),
+			ssh.DoExecScript([]byte(fmt.Sprintf(storageMountScript, dev, mount.dir, fs))))
+	}
+
+	return actions
+}
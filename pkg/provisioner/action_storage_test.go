@@ -0,0 +1,80 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestDoPrepareStorage(t *testing.T) {
+	raw := map[string]interface{}{
+		"storage": []interface{}{
+			map[string]interface{}{
+				"etcd_device": "/dev/vdb",
+				"filesystem":  "xfs",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	res := doPrepareStorage(d).Apply(ctx)
+	if ssh.IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if len(*uploads) != 1 {
+		t.Fatalf("Error: unexpected number of uploads: %d", len(*uploads))
+	}
+	for _, script := range *uploads {
+		for _, expected := range []string{`DEV="/dev/vdb"`, `MNT="/var/lib/etcd"`, `FS="xfs"`} {
+			if !strings.Contains(script, expected) {
+				t.Fatalf("Error: %q not found in script:\n%s", expected, script)
+			}
+		}
+	}
+}
+
+func TestDoPrepareStorageEtcdDataDir(t *testing.T) {
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"etcd_data_dir": "/data/etcd",
+		},
+		"storage": []interface{}{
+			map[string]interface{}{
+				"etcd_device": "/dev/vdb",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+	ctx, uploads := ssh.NewTestingContextForUploads([]string{})
+	res := doPrepareStorage(d).Apply(ctx)
+	if ssh.IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if len(*uploads) != 1 {
+		t.Fatalf("Error: unexpected number of uploads: %d", len(*uploads))
+	}
+	for _, script := range *uploads {
+		if !strings.Contains(script, `MNT="/data/etcd"`) {
+			t.Fatalf("Error: the device is not mounted at the etcd data dir:\n%s", script)
+		}
+	}
+}
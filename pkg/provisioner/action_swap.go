@@ -0,0 +1,43 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// disableSwapScript turns off the swap and comments the swap
+// entries in the fstab, so it is not enabled again after a reboot
+const disableSwapScript = `#!/bin/sh
+swapoff -a
+if [ -f /etc/fstab ] && grep -qE '^[^#].*[[:space:]]swap[[:space:]]' /etc/fstab ; then
+	sed -i.bak -E 's@^([^#].*[[:space:]]swap[[:space:]].*)$@#\1@' /etc/fstab
+fi
+exit 0
+`
+
+// doDisableSwap disables the swap in the node when `manage_swap = "disable"`
+func doDisableSwap(d *schema.ResourceData) ssh.Action {
+	if getManageSwapFromResourceData(d) != "disable" {
+		return nil
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Disabling swap..."),
+		ssh.DoExecScript([]byte(disableSwapScript)),
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestKubeletSysconfigSwap(t *testing.T) {
+	tests := map[string]bool{
+		"":        true,
+		"allow":   true,
+		"disable": false,
+	}
+	for mode, failSwapOff := range tests {
+		raw := map[string]interface{}{
+			"manage_swap": mode,
+		}
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+
+		code := string(getKubeletSysconfigCodeFromResourceData(d))
+		if strings.Contains(code, "--fail-swap-on=false") != failSwapOff {
+			t.Fatalf("Error: unexpected kubelet sysconfig for manage_swap=%q:\n%s", mode, code)
+		}
+		if (doDisableSwap(d) != nil) != (mode == "disable") {
+			t.Fatalf("Error: unexpected swap action for manage_swap=%q", mode)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// getTaintsFromResourceData returns the taints for the node: the default
+// taints for its role and then the `taints` provided
+func getTaintsFromResourceData(d *schema.ResourceData) ([]corev1.Taint, error) {
+	res := []corev1.Taint{}
+	if isControlPlaneFromResourceData(d) {
+		res = append(res, common.ControlPlaneTaints(getKubeVersionFromResourceData(d))...)
+	}
+	for _, s := range d.Get("taints").([]interface{}) {
+		taint, err := common.ParseTaint(s.(string))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, taint)
+	}
+	return res, nil
+}
+
+// doAddTaints sets the taints of the node in the `command` ("init" or "join")
+// configuration. Nothing is done when no `taints` have been provided, so
+// kubeadm uses the defaults for the role.
+func doAddTaints(d *schema.ResourceData, command string) ssh.Action {
+	if len(d.Get("taints").([]interface{})) == 0 {
+		return nil
+	}
+
+	taints, err := getTaintsFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	err = updateNodeRegistration(d, command, func(nr *kubeadmapi.NodeRegistrationOptions) {
+		nr.Taints = taints
+	})
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	descr := []string{}
+	for _, taint := range taints {
+		descr = append(descr, taint.ToString())
+	}
+	return ssh.DoMessageInfo("Node will be tainted with: %s", strings.Join(descr, ", "))
+}
@@ -0,0 +1,111 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetRole(t *testing.T) {
+	tests := []struct {
+		join     string
+		role     string
+		expected string
+	}{
+		{"", "", roleControlPlane},
+		{"10.0.0.1", "", roleWorker},
+		{"10.0.0.1", "master", roleControlPlane},
+		{"10.0.0.1", "control-plane", roleControlPlane},
+		{"", "worker", roleWorker},
+	}
+	for _, test := range tests {
+		raw := map[string]interface{}{
+			"join": test.join,
+			"role": test.role,
+		}
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+		if role := getRoleFromResourceData(d); role != test.expected {
+			t.Fatalf("Error: unexpected role for join=%q and role=%q: %q", test.join, test.role, role)
+		}
+	}
+
+	if ws, errs := validateRole("master", "role"); len(ws) == 0 || len(errs) > 0 {
+		t.Fatalf("Error: \"master\" should be deprecated: %v %v", ws, errs)
+	}
+	if _, errs := validateRole("etcd", "role"); len(errs) == 0 {
+		t.Fatalf("Error: \"etcd\" should not be a valid role")
+	}
+}
+
+func TestDoAddTaints(t *testing.T) {
+	joinConfig, err := common.NewJoinConfig(common.ClusterSpec{
+		Version: "v1.28.2",
+		Token:   "82eb2m.999999idy9l74yha",
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"config": map[string]interface{}{
+			"join":         common.ToTerraformSafeString(joinConfigBytes),
+			"kube_version": "v1.28.2",
+		},
+		"join":   "10.0.0.1",
+		"role":   "control-plane",
+		"taints": []interface{}{"dedicated=infra:NoSchedule"},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if action := doAddTaints(d, "join"); action == nil {
+		t.Fatalf("Error: no action for adding the taints")
+	}
+
+	joinConfig, _, err = common.JoinConfigFromResourceData(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	taints := joinConfig.NodeRegistration.Taints
+	if len(taints) != 2 || taints[0].Key != "node-role.kubernetes.io/control-plane" ||
+		taints[1].Key != "dedicated" || taints[1].Value != "infra" || taints[1].Effect != corev1.TaintEffectNoSchedule {
+		t.Fatalf("Error: unexpected taints: %v", taints)
+	}
+
+	// workers have no default taints
+	raw["role"] = "worker"
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	taints, err = getTaintsFromResourceData(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(taints) != 1 || taints[0].Key != "dedicated" {
+		t.Fatalf("Error: unexpected taints: %v", taints)
+	}
+
+	// nothing is done when no taints are provided
+	delete(raw, "taints")
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if action := doAddTaints(d, "join"); action != nil {
+		t.Fatalf("Error: unexpected action for the default taints")
+	}
+}
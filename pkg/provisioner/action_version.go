@@ -0,0 +1,118 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// checkKubeadmVersionSkew checks that a kubeadm version can deploy the cluster version,
+// ignoring the kubeadm versions that cannot be parsed (ie, some distro-specific version)
+func checkKubeadmVersionSkew(kubeadmVersion string, kubeVersion string) error {
+	if len(kubeadmVersion) == 0 {
+		return nil
+	}
+	if _, err := version.ParseGeneric(kubeadmVersion); err != nil {
+		ssh.Debug("could not parse the kubeadm version %q: the version skew will not be checked", kubeadmVersion)
+		return nil
+	}
+	return common.CheckKubeadmVersionSkew(kubeadmVersion, kubeVersion)
+}
+
+// checkInstallVersion checks that the kubeadm package in some `install.version` can
+// deploy a cluster version
+func checkInstallVersion(installVersion string, kubeVersion string) error {
+	if err := checkKubeadmVersionSkew(installVersion, kubeVersion); err != nil {
+		return fmt.Errorf("invalid install.version: %s", err)
+	}
+	return nil
+}
+
+// checkInstallVersionSkew checks that the kubeadm package that will be installed (in
+// `install.version`) can deploy the cluster, before doing anything in the node
+// (as a fallback for the values that were not known in validateInstallVersionSkew)
+func checkInstallVersionSkew(d *schema.ResourceData) error {
+	opt, ok := d.GetOk("install.0.version")
+	if !ok {
+		return nil
+	}
+	return checkInstallVersion(opt.(string), getKubeVersionFromResourceData(d))
+}
+
+// validateInstallVersionSkew checks the `install.version` in the plan, when both the
+// `install.version` and the `config` are known (ie, when the cluster already exists)
+func validateInstallVersionSkew(c *terraform.ResourceConfig) error {
+	if c.IsComputed("install.0.version") || c.IsComputed("config.kube_version") {
+		return nil
+	}
+	installVersion, ok := c.Get("install.0.version")
+	if !ok {
+		return nil
+	}
+	installVersionS, ok := installVersion.(string)
+	if !ok || len(installVersionS) == 0 {
+		return nil
+	}
+
+	// the whole `config` is unknown until the kubeadm resource has been created
+	config, ok := c.Get("config")
+	if !ok {
+		return nil
+	}
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	kubeVersion := common.DefKubernetesVersion
+	if v, ok := configMap["kube_version"].(string); ok && len(v) > 0 {
+		kubeVersion = v
+	}
+	return checkInstallVersion(installVersionS, kubeVersion)
+}
+
+// doCheckKubeadmVersion checks that the kubeadm installed in the node can deploy the cluster,
+// so we fail before initting/joining instead of letting kubeadm fail halfway
+func doCheckKubeadmVersion(d *schema.ResourceData) ssh.Action {
+	kubeVersion := getKubeVersionFromResourceData(d)
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var kubeadmVersion string
+		res := ssh.DoSendingExecOutputToFunc(
+			ssh.DoExec(fmt.Sprintf("%s version -o short", getKubeadmFromResourceData(d))),
+			func(s string) {
+				if s = strings.TrimSpace(s); len(s) > 0 {
+					kubeadmVersion = s
+				}
+			}).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not get the kubeadm version: the version skew will not be checked")
+		}
+
+		ssh.GetLoggerFromContext(ctx).Debug("kubeadm version: %q", kubeadmVersion)
+		if err := checkKubeadmVersionSkew(kubeadmVersion, kubeVersion); err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return nil
+	})
+}
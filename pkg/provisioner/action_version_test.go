@@ -0,0 +1,99 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestCheckInstallVersionSkew(t *testing.T) {
+	tests := []struct {
+		installVersion string
+		kubeVersion    string
+		valid          bool
+	}{
+		{"", "v1.27.3", true},
+		{"1.27.3-00", "v1.27.3", true},
+		{"v1.28.0", "v1.27.3", true},
+		{"1.29.1-1.1", "v1.27.3", false},
+		{"v1.27.3", "v1.28.0", false},
+		{"stable", "v1.28.0", true}, // (not a version we can check)
+	}
+	for _, test := range tests {
+		raw := map[string]interface{}{
+			"config": map[string]interface{}{
+				"kube_version": test.kubeVersion,
+			},
+		}
+		if len(test.installVersion) > 0 {
+			raw["install"] = []interface{}{
+				map[string]interface{}{
+					"version": test.installVersion,
+				},
+			}
+		}
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+		err := checkInstallVersionSkew(d)
+		if test.valid && err != nil {
+			t.Fatalf("Error: unexpected error for kubeadm %q and %s: %s", test.installVersion, test.kubeVersion, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatalf("Error: no error for kubeadm %q and %s", test.installVersion, test.kubeVersion)
+		}
+	}
+}
+
+func TestValidateInstallVersionSkew(t *testing.T) {
+	tests := []struct {
+		config         interface{}
+		installVersion string
+		valid          bool
+	}{
+		{map[string]interface{}{"kube_version": "v1.27.3"}, "1.27.3-00", true},
+		{map[string]interface{}{"kube_version": "v1.27.3"}, "1.29.1-1.1", false},
+		{map[string]interface{}{"kube_version": "v1.28.0"}, "v1.27.3", false},
+		// the default version of the cluster
+		{map[string]interface{}{}, "v1.17.0", false},
+		// unknown values are checked when applying
+		{map[string]interface{}{"kube_version": config.UnknownVariableValue}, "v1.27.3", true},
+		{config.UnknownVariableValue, "v1.27.3", true},
+		{map[string]interface{}{"kube_version": "v1.28.0"}, config.UnknownVariableValue, true},
+	}
+	for _, test := range tests {
+		raw := map[string]interface{}{
+			"config": test.config,
+			"install": []interface{}{
+				map[string]interface{}{
+					"version": test.installVersion,
+				},
+			},
+		}
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("Error: could not create the raw config: %s", err)
+		}
+		_, errs := Provisioner().Validate(terraform.NewResourceConfig(rawConfig))
+		if test.valid && len(errs) > 0 {
+			t.Fatalf("Error: unexpected errors for %v and %q: %v", test.config, test.installVersion, errs)
+		}
+		if !test.valid && len(errs) == 0 {
+			t.Fatalf("Error: no error for %v and %q", test.config, test.installVersion)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// kubeVipScript creates the kube-vip static pod, detecting the network
+// interface for the VIP when it is not provided
+// (arguments: VIP, interface, kube-vip version, API server port and kubeconfig)
+const kubeVipScript = `#!/bin/sh
+VIP="%s"
+IFACE="%s"
+[ -n "$IFACE" ] || IFACE="$(ip -o route get "$VIP" 2>/dev/null | sed -n 's/.* dev \([^ ]*\).*/\1/p')"
+if [ -z "$IFACE" ] ; then
+	echo ">>> could not detect the network interface for the VIP $VIP"
+	exit 1
+fi
+
+echo ">>> creating the kube-vip static pod for $VIP in $IFACE"
+mkdir -p /etc/kubernetes/manifests
+cat > /etc/kubernetes/manifests/kube-vip.yaml <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-vip
+  namespace: kube-system
+spec:
+  containers:
+    - name: kube-vip
+      image: ghcr.io/kube-vip/kube-vip:%s
+      imagePullPolicy: IfNotPresent
+      args: ["manager"]
+      env:
+        - name: vip_arp
+          value: "true"
+        - name: port
+          value: "%d"
+        - name: vip_interface
+          value: "$IFACE"
+        - name: vip_cidr
+          value: "32"
+        - name: cp_enable
+          value: "true"
+        - name: cp_namespace
+          value: kube-system
+        - name: vip_leaderelection
+          value: "true"
+        - name: vip_leasename
+          value: plndr-cp-lock
+        - name: vip_leaseduration
+          value: "5"
+        - name: vip_renewdeadline
+          value: "3"
+        - name: vip_retryperiod
+          value: "1"
+        - name: address
+          value: "$VIP"
+      securityContext:
+        capabilities:
+          add: ["NET_ADMIN", "NET_RAW"]
+      volumeMounts:
+        - mountPath: /etc/kubernetes/admin.conf
+          name: kubeconfig
+  hostAliases:
+    - hostnames: ["kubernetes"]
+      ip: 127.0.0.1
+  hostNetwork: true
+  volumes:
+    - name: kubeconfig
+      hostPath:
+        path: %s
+EOF
+`
+
+// the kubeadm preflight check that fails when the static pod has been created
+const kubeVipIgnoredCheck = "DirAvailable--etc-kubernetes-manifests"
+
+// starting with Kubernetes 1.29, the admin.conf has no permissions until
+// the cluster has been bootstrapped, so the super-admin.conf must be used
+var kubeVipSuperAdminMinVersion = version.MustParseGeneric("v1.29.0")
+
+// isVIPEnabled returns true if the control plane VIP is managed with kube-vip
+func isVIPEnabled(d *schema.ResourceData) bool {
+	vip, ok := d.GetOk("config.vip_address")
+	return ok && len(vip.(string)) > 0
+}
+
+// getKubeVipKubeconfig returns the kubeconfig used by kube-vip for the
+// `command` ("init" or "join") and the Kubernetes version
+func getKubeVipKubeconfig(command string, kubeVersion string) string {
+	if command == "init" {
+		if v, err := version.ParseGeneric(kubeVersion); err == nil && !v.LessThan(kubeVipSuperAdminMinVersion) {
+			return "/etc/kubernetes/super-admin.conf"
+		}
+	}
+	return ssh.DefAdminKubeconfig
+}
+
+// doCreateKubeVip creates the kube-vip static pod in a control plane node, so
+// the control plane endpoint can be a floating VIP
+func doCreateKubeVip(d *schema.ResourceData, command string) ssh.Action {
+	if !isVIPEnabled(d) {
+		return nil
+	}
+
+	kubeVersion := common.DefKubernetesVersion
+	if opt, ok := d.GetOk("config.kube_version"); ok && len(opt.(string)) > 0 {
+		kubeVersion = opt.(string)
+	}
+
+	script := fmt.Sprintf(kubeVipScript,
+		d.Get("config.vip_address").(string),
+		d.Get("config.vip_interface").(string),
+		d.Get("config.vip_version").(string),
+		common.DefAPIServerPort,
+		getKubeVipKubeconfig(command, kubeVersion))
+
+	return ssh.DoExecScript([]byte(script))
+}
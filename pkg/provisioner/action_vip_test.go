@@ -0,0 +1,38 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestGetKubeVipKubeconfig(t *testing.T) {
+	tests := []struct {
+		command     string
+		kubeVersion string
+		expected    string
+	}{
+		{"init", "v1.28.4", ssh.DefAdminKubeconfig},
+		{"init", "v1.29.0", "/etc/kubernetes/super-admin.conf"},
+		{"join", "v1.29.0", ssh.DefAdminKubeconfig},
+	}
+	for _, test := range tests {
+		if kubeconfig := getKubeVipKubeconfig(test.command, test.kubeVersion); kubeconfig != test.expected {
+			t.Fatalf("Error: unexpected kubeconfig for %s with %s: %q", test.command, test.kubeVersion, kubeconfig)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package provisioner
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"strings"
 
@@ -52,9 +53,7 @@ func doRemoteKubectlApply(d *schema.ResourceData, manifests []ssh.Manifest) ssh.
 
 // doKubectlDrainNode runs a kubectl for draining a node
 func doKubectlDrainNode(d *schema.ResourceData, nodename string) ssh.Action {
-	args := []string{"drain",
-		"--delete-local-data=true", "--force=true", "--ignore-daemonsets=true",
-		nodename}
+	args := getDrainArgs(d, nodename)
 
 	ssh.Debug("running 'kubectl drain' command for %q", nodename)
 	return ssh.ActionList{
@@ -63,6 +62,16 @@ func doKubectlDrainNode(d *schema.ResourceData, nodename string) ssh.Action {
 	}
 }
 
+// getDrainArgs returns the `kubectl` args for draining a node
+func getDrainArgs(d *schema.ResourceData, nodename string) []string {
+	args := []string{"drain",
+		"--delete-local-data=true", "--force=true", "--ignore-daemonsets=true"}
+	if timeout := getDurationFromResourceData(d, "destroy.0.drain_timeout"); timeout > 0 {
+		args = append(args, fmt.Sprintf("--timeout=%s", timeout))
+	}
+	return append(args, nodename)
+}
+
 // doKubectlDeleteNode deletes the node from the cluster (so it will be forgotten forever)
 func doKubectlDeleteNode(d *schema.ResourceData, nodename string) ssh.Action {
 	args := []string{"delete", "node", nodename}
@@ -79,7 +88,7 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 	// maybe we can get it just from the `ResourceData`
 	nodename := getNodenameFromResourceData(d)
 	if len(nodename) > 0 {
-		ssh.Debug("got nodename %q from resource data", node.Nodename)
+		ssh.Debug("got nodename %q from resource data", nodename)
 		node.Nodename = nodename
 		return nil
 	}
@@ -90,15 +99,15 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 	// otherwise, access the remote host
 	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
 		// first, get the machine ID
-		ssh.Debug("trying to get the machine ID...")
+		ssh.GetLoggerFromContext(ctx).Debug("trying to get the machine ID...")
 		var buf bytes.Buffer
 		res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(machineIDCmd), &buf).Apply(ctx)
 		if ssh.IsError(res) {
 			return res
 		}
-		ssh.Debug("... output: %q", buf.String())
+		ssh.GetLoggerFromContext(ctx).Debug("... output: %q", buf.String())
 		machineID := strings.TrimSpace(buf.String())
-		ssh.Debug("... machineID: %q", machineID)
+		ssh.GetLoggerFromContext(ctx).Debug("... machineID: %q", machineID)
 
 		res = ssh.DoSendingExecOutputToFunc(
 			ssh.DoRemoteKubectl(kubectl, kubeconfig, kubectlGetNodenameCmd),
@@ -106,17 +115,17 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 				if len(s) == 0 {
 					return
 				}
-				ssh.Debug("trying to find nodename in %q", s)
+				ssh.GetLoggerFromContext(ctx).Debug("trying to find nodename in %q", s)
 				if strings.Contains(s, machineID) {
 					// parse:
 					// bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
 					fields := strings.Fields(s)
 					if len(fields) < 2 {
-						ssh.Debug("could not get the nodename from fields: %+v", fields)
+						ssh.GetLoggerFromContext(ctx).Debug("could not get the nodename from fields: %+v", fields)
 						return
 					}
 					node.Nodename = strings.TrimSpace(fields[1])
-					ssh.Debug("... detected nodename %q", node.Nodename)
+					ssh.GetLoggerFromContext(ctx).Debug("... detected nodename %q", node.Nodename)
 				}
 			}).Apply(ctx)
 
@@ -15,7 +15,9 @@
 package provisioner
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -52,3 +54,40 @@ bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
 		t.Fatalf("Error: wrong nodename %q", node.Nodename)
 	}
 }
+
+func TestDestroySettings(t *testing.T) {
+	raw := map[string]interface{}{
+		"drain": true,
+		"config": map[string]interface{}{
+			"timeout_delete": "20m",
+		},
+	}
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if timeout := getTimeoutFromResourceData(d); timeout != 20*time.Minute {
+		t.Fatalf("Error: unexpected destroy timeout: %s", timeout)
+	}
+	if args := strings.Join(getDrainArgs(d, "worker-0"), " "); strings.Contains(args, "--timeout") || !strings.HasSuffix(args, " worker-0") {
+		t.Fatalf("Error: unexpected drain args: %s", args)
+	}
+
+	raw["destroy"] = []interface{}{
+		map[string]interface{}{
+			"drain_timeout": "2m",
+			"timeout":       "5m",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if timeout := getTimeoutFromResourceData(d); timeout != 5*time.Minute {
+		t.Fatalf("Error: the destroy timeout has not been overridden: %s", timeout)
+	}
+	if args := strings.Join(getDrainArgs(d, "worker-0"), " "); !strings.Contains(args, "--timeout=2m0s worker-0") {
+		t.Fatalf("Error: unexpected drain args: %s", args)
+	}
+
+	// the destroy timeout is not used when creating the node
+	raw["drain"] = false
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, raw)
+	if timeout := getTimeoutFromResourceData(d); timeout != 0 {
+		t.Fatalf("Error: unexpected create timeout: %s", timeout)
+	}
+}
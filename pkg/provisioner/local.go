@@ -0,0 +1,186 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// connection types for nodes running as local containers
+	localConnTypeDocker = "docker"
+	localConnTypeLXD    = "lxd"
+
+	// default timeout for connecting to a local container
+	defLocalConnectTimeout = 5 * time.Minute
+
+	// path used for uploading scripts in local containers
+	defLocalScriptPath = "/tmp/terraform-kubeadm-script.sh"
+)
+
+// isLocalConnType returns true if the connection type is for a local container
+func isLocalConnType(connType string) bool {
+	return connType == localConnTypeDocker || connType == localConnTypeLXD
+}
+
+// localCommunicator is a communicator for privileged containers running in
+// the Terraform host, where commands are run with "docker exec" or "lxc exec".
+// The "host" in the connection is the name (or ID) of the container.
+type localCommunicator struct {
+	engine    string
+	container string
+	timeout   time.Duration
+}
+
+// newLocalCommunicator creates a new communicator from the connection info
+func newLocalCommunicator(s *terraform.InstanceState) (*localCommunicator, error) {
+	connInfo := s.Ephemeral.ConnInfo
+
+	engine := connInfo["type"]
+	if !isLocalConnType(engine) {
+		return nil, fmt.Errorf("unsupported local connection type %q", engine)
+	}
+
+	container := connInfo["host"]
+	if container == "" {
+		return nil, fmt.Errorf("no container name provided in the \"host\" of the connection")
+	}
+
+	timeout := defLocalConnectTimeout
+	if t := connInfo["timeout"]; t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection timeout %q: %s", t, err)
+		}
+		timeout = parsed
+	}
+
+	return &localCommunicator{
+		engine:    engine,
+		container: container,
+		timeout:   timeout,
+	}, nil
+}
+
+// execArgs returns the command line for running `command` in the container
+func (c *localCommunicator) execArgs(command string) []string {
+	switch c.engine {
+	case localConnTypeLXD:
+		return []string{"lxc", "exec", c.container, "--", "sh", "-c", command}
+	default:
+		return []string{"docker", "exec", "-i", c.container, "sh", "-c", command}
+	}
+}
+
+// run runs a command in the container, waiting for it to finish
+func (c *localCommunicator) run(command string, stdin io.Reader) error {
+	args := c.execArgs(command)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%q failed in %s: %s: %s", command, c.container, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Connect checks the container is running and accepting commands
+func (c *localCommunicator) Connect(o terraform.UIOutput) error {
+	if o != nil {
+		o.Output(fmt.Sprintf("Connecting to %s container %s...", c.engine, c.container))
+	}
+	return c.run("true", nil)
+}
+
+// Disconnect is a no-op for local containers
+func (c *localCommunicator) Disconnect() error {
+	return nil
+}
+
+// Timeout returns the timeout for connecting to the container
+func (c *localCommunicator) Timeout() time.Duration {
+	return c.timeout
+}
+
+// ScriptPath returns the path where scripts are uploaded
+func (c *localCommunicator) ScriptPath() string {
+	return defLocalScriptPath
+}
+
+// Start runs a remote command in the container, without waiting for it
+func (c *localCommunicator) Start(rc *remote.Cmd) error {
+	rc.Init()
+
+	args := c.execArgs(rc.Command)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = rc.Stdin
+	cmd.Stdout = rc.Stdout
+	cmd.Stderr = rc.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			rc.SetExitStatus(exitErr.ExitCode(), nil)
+			return
+		}
+		rc.SetExitStatus(0, err)
+	}()
+
+	return nil
+}
+
+// Upload copies the contents of `input` to the `path` in the container
+func (c *localCommunicator) Upload(path string, input io.Reader) error {
+	return c.run(fmt.Sprintf("cat > %s", ssh.ShellQuote(path)), input)
+}
+
+// UploadScript uploads a script to `path`, making it executable
+func (c *localCommunicator) UploadScript(path string, input io.Reader) error {
+	if err := c.Upload(path, input); err != nil {
+		return err
+	}
+	return c.run(fmt.Sprintf("chmod 0777 %s", ssh.ShellQuote(path)), nil)
+}
+
+// UploadDir copies the local directory `src` to `dst` in the container
+func (c *localCommunicator) UploadDir(dst string, src string) error {
+	var args []string
+	switch c.engine {
+	case localConnTypeLXD:
+		args = []string{"lxc", "file", "push", "-r", "-p", src + "/.", c.container + dst}
+	default:
+		args = []string{"docker", "cp", src + "/.", c.container + ":" + dst}
+	}
+
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("could not upload %s to %s:%s: %s: %s", src, c.container, dst, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestLocalCommunicator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeadm-local")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a fake "docker" that runs "docker exec -i <container> <cmd...>" locally
+	fake := "#!/bin/sh\nshift 3\nexec \"$@\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(fake), 0755); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := &terraform.InstanceState{
+		Ephemeral: terraform.EphemeralState{
+			ConnInfo: map[string]string{"type": "docker", "host": "node-0"},
+		},
+	}
+	comm, err := newLocalCommunicator(s)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if args := strings.Join(comm.execArgs("ls"), " "); args != "docker exec -i node-0 sh -c ls" {
+		t.Fatalf("Error: unexpected command line: %s", args)
+	}
+	if err := comm.Connect(nil); err != nil {
+		t.Fatalf("Error: could not connect: %s", err)
+	}
+
+	dst := filepath.Join(dir, "some file")
+	if err := comm.Upload(dst, strings.NewReader("hello")); err != nil {
+		t.Fatalf("Error: could not upload: %s", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := &remote.Cmd{Command: "cat '" + dst + "'; exit 3", Stdout: &stdout}
+	if err := comm.Start(cmd); err != nil {
+		t.Fatalf("Error: could not start: %s", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatalf("Error: exit status not propagated")
+	} else if exitErr, ok := err.(*remote.ExitError); !ok || exitErr.ExitStatus != 3 {
+		t.Fatalf("Error: unexpected error: %s", err)
+	}
+	if stdout.String() != "hello" {
+		t.Fatalf("Error: unexpected output: %q", stdout.String())
+	}
+
+	s.Ephemeral.ConnInfo["type"] = "lxd"
+	comm, _ = newLocalCommunicator(s)
+	if args := strings.Join(comm.execArgs("ls"), " "); args != "lxc exec node-0 -- sh -c ls" {
+		t.Fatalf("Error: unexpected command line: %s", args)
+	}
+}
@@ -23,8 +23,37 @@ import (
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 
-	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// the roles of the nodes
+const (
+	roleControlPlane = "control-plane"
+	roleWorker       = "worker"
+
+	// (deprecated) alias for the "control-plane" role
+	roleMaster = "master"
+)
+
+// the provisioning steps, in the order they are run in a node (some of them are also
+// used as steps in the logs and in the progress events)
+const (
+	stepReconfigure      = "reconfigure"
+	stepSetup            = "setup"
+	stepPrepareNode      = "prepare-node"
+	stepPullImages       = "pull-images"
+	stepPrepared         = "prepared"
+	stepActivate         = "activate"
+	stepCheckVersion     = "check-version"
+	stepPreflight        = "preflight"
+	stepInit             = "init"
+	stepAddons           = "addons"
+	stepJoinControlPlane = "join-control-plane"
+	stepJoin             = "join"
+	stepPostInit         = "post-init"
+	stepPostJoin         = "post-join"
+	stepFinish           = "finish"
 )
 
 var (
@@ -45,27 +74,106 @@ func applyFn(ctx context.Context) error {
 	s := ctx.Value(schema.ProvRawStateKey).(*terraform.InstanceState)
 	o := ctx.Value(schema.ProvOutputKey).(terraform.UIOutput)
 
-	//ssh.Debug("kubeadm provisioner: configuration:\n%s\n", spew.Sdump(d))
-	ssh.Debug("connection:\n%s\n", spew.Sdump(connData))
-	ssh.Debug("instance state:\n%s\n", spew.Sdump(s))
+	// make sure the secrets are not printed in the debug messages
+	for _, k := range []string{"password", "private_key", "bastion_password", "bastion_private_key"} {
+		ssh.AddSecret(s.Ephemeral.ConnInfo[k])
+	}
+	common.RegisterSecrets(common.GetProvisionerConfig(d))
+
+	// all the log messages for this node will include the host
+	host := s.Ephemeral.ConnInfo["host"]
+	logger := ssh.NewLogger(ssh.LogFields{"host": host})
+	ctx = ssh.WithLogger(ctx, logger)
+
+	//logger.Debug("kubeadm provisioner: configuration:\n%s\n", spew.Sdump(d))
+	logger.Trace("connection:\n%s\n", spew.Sdump(connData))
+	logger.Trace("instance state:\n%s\n", spew.Sdump(s))
 
-	// ensure that this is a linux machine
-	if s.Ephemeral.ConnInfo["type"] != "ssh" {
-		return fmt.Errorf("Unsupported connection type: %s. This provisioner currently only supports linux", s.Ephemeral.ConnInfo["type"])
+	// ensure that we support the connection type for this OS
+	connType := s.Ephemeral.ConnInfo["type"]
+	nodeOS := getOSFromResourceData(d, connType)
+	switch nodeOS {
+	case "windows":
+		if connType != "ssh" && connType != "winrm" {
+			return fmt.Errorf("Unsupported connection type: %s. Only ssh and winrm are supported for Windows nodes", connType)
+		}
+	default:
+		if connType != "ssh" && !isLocalConnType(connType) {
+			return fmt.Errorf("Unsupported connection type: %s. Only ssh, docker and lxd are supported for Linux nodes", connType)
+		}
 	}
 
-	preventSudo := d.Get("prevent_sudo").(bool)
-	useSudo := !preventSudo && s.Ephemeral.ConnInfo["user"] != "root"
+	// commands in local containers are always run as root
+	preventSudo := d.Get("prevent_sudo").(bool) || isLocalConnType(connType)
+	useSudo := nodeOS == "linux" && !preventSudo && s.Ephemeral.ConnInfo["user"] != "root"
+
+	// the `timeouts` in the kubeadm resource are the deadline for the whole provisioning
+	if timeout := getTimeoutFromResourceData(d); timeout > 0 {
+		logger.Debug("provisioning deadline: %s", timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// build a communicator for the provisioner to use
-	comm, err := getCommunicator(ctx, o, s)
+	connectTimeout := getSSHConnectTimeoutFromResourceData(d)
+	keepalive := getSSHKeepaliveFromResourceData(d)
+	maxSessions := d.Get("ssh.0.max_sessions").(int)
+	comm, err := getCommunicator(ctx, o, s, connectTimeout, maxSessions)
 	if err != nil {
 		o.Output("Error when creating communicator")
 		return err
 	}
+	defer comm.Disconnect()
+
+	// the keepalive must be stopped (before disconnecting) when this apply is done
+	if keepalive > 0 {
+		keepaliveCtx, stopKeepalive := context.WithCancel(ctx)
+		defer stopKeepalive()
+		go doKeepalive(keepaliveCtx, comm, keepalive)
+	}
 
 	// add some extra things to the context
 	newCtx := ssh.WithValues(ctx, o, o, comm, useSudo)
+	ssh.SetExecTimeoutInContext(newCtx, getSSHExecTimeoutFromResourceData(d))
+
+	// kill the remote processes (ie, a `kubeadm init`) when the apply is cancelled
+	// or a command times out (only in Linux nodes: Windows nodes run PowerShell)
+	ssh.SetKillProcessesInContext(newCtx, nodeOS == "linux")
+
+	// maybe log all the commands (and their output) to a file
+	if logDir := getLogDirFromResourceData(d); len(logDir) > 0 {
+		sessionLog, err := openSessionLog(logDir, host)
+		if err != nil {
+			return fmt.Errorf("could not open session log in %q: %s", logDir, err)
+		}
+		defer sessionLog.Close()
+		ssh.SetSessionLogInContext(newCtx, sessionLog)
+	}
+
+	// maybe write the progress events (for the phases) to a JSON lines file
+	if progressFile := getProgressFileFromResourceData(d); len(progressFile) > 0 {
+		f, err := openProgressFile(progressFile)
+		if err != nil {
+			return fmt.Errorf("could not open the progress file %q: %s", progressFile, err)
+		}
+		defer f.Close()
+		ssh.SetProgressInContext(newCtx, host, ssh.NewProgressWriter(f))
+	}
+
+	// load the secrets that are not kept in the Terraform state
+	if err := loadSecretsFromVault(newCtx, d); err != nil {
+		return err
+	}
+	common.RegisterSecrets(common.GetProvisionerConfig(d))
+
+	// some distros (ie, Flatcar) use the release binaries, so the paths used in the
+	// actions must be known before creating them
+	if nodeOS == "linux" {
+		if err := applyActions(newCtx, host, doDetectInstallMode(d)); err != nil {
+			return err
+		}
+	}
 
 	//
 	// resource destruction
@@ -73,9 +181,22 @@ func applyFn(ctx context.Context) error {
 
 	drain := d.Get("drain").(bool)
 	if drain {
-		ssh.Debug("node will be drained")
+		if nodeOS == "windows" {
+			return applyActions(newCtx, host, ssh.DoMessageWarn("draining Windows nodes is not supported yet"))
+		}
+		logger.Info("node will be drained")
 		action := doRemoveNode(d)
-		return action.Apply(newCtx)
+		return applyActions(newCtx, host, action)
+	}
+
+	// fail early if the kubeadm that will be installed cannot deploy the cluster
+	if err := checkInstallVersionSkew(d); err != nil {
+		return err
+	}
+
+	// Windows nodes have their own (limited) provisioning
+	if nodeOS == "windows" {
+		return applyActions(newCtx, host, doKubeadmJoinWindowsWorker(d))
 	}
 
 	//
@@ -92,55 +213,170 @@ func applyFn(ctx context.Context) error {
 		actions = append(actions, ssh.DoMessageInfo("New resource: provisioning"))
 	}
 
-	// add the actions for installing kubeadm
-	actions = append(actions, doKubeadmSetup(d))
+	role := getRoleFromResourceData(d)
+	phase := getPhaseFromResourceData(d)
+	newCtx = ssh.WithLogger(newCtx, logger.With("role", role).With("phase", phase))
 
-	// determine what to do (init, join or join --control-plane) depending on the argument provided
+	// determine what to do (init, join or join --control-plane) depending on the `join` and the `role`
+	steps, err := getProvisionSteps(d)
+	if err != nil {
+		return applyActions(newCtx, host, ssh.ActionError(err.Error()))
+	}
+	for _, step := range steps {
+		actions = append(actions, getProvisionStepAction(d, step, host, connType))
+	}
+
+	if phase == "reconfigure" {
+		return applyActions(newCtx, host, actions)
+	}
+	return applyActions(newCtx, host, ssh.DoWithCleanup(
+		actions,
+		ssh.DoCleanupLeftovers()))
+}
+
+// getProvisionSteps returns the steps for provisioning a node in the current `phase`, doing
+// an init, a join or a join --control-plane depending on the `join` and the `role`
+func getProvisionSteps(d *schema.ResourceData) ([]string, error) {
 	join := getJoinFromResourceData(d)
 	role := getRoleFromResourceData(d)
+	controlPlane := role == roleControlPlane
+	phase := getPhaseFromResourceData(d)
+
+	if phase == "reconfigure" {
+		// apply the (updated) configuration in a node that is already in the cluster
+		return []string{stepReconfigure}, nil
+	}
+
+	steps := []string{}
+	if phase == "all" || phase == "prepare" {
+		steps = append(steps, stepSetup, stepPrepareNode)
+	}
+
+	if phase == "prepare" {
+		// pre-pull the images and stop here: the cluster will be started in the "activate" phase
+		if controlPlane && len(getOfflineImagesFromResourceData(d)) == 0 {
+			steps = append(steps, stepPullImages)
+		}
+		return append(steps, stepPrepared), nil
+	}
+
+	if phase == "activate" {
+		steps = append(steps, stepActivate)
+	}
+
+	// check the node meets the requirements before initting/joining
+	steps = append(steps, stepCheckVersion, stepPreflight)
+
+	switch {
+	case len(join) == 0 && controlPlane:
+		steps = append(steps, stepInit, stepAddons, stepPostInit)
+	case len(join) == 0:
+		return nil, fmt.Errorf("role is %q while no \"join\" argument has been provided", role)
+	case controlPlane:
+		steps = append(steps, stepJoinControlPlane, stepPostJoin)
+	default:
+		steps = append(steps, stepJoin, stepPostJoin)
+	}
+
+	return append(steps, stepFinish), nil
+}
 
-	// some common actions to do BEFORE doing initting/joining
-	actions = append(actions,
-		ssh.DoMessageInfo("Checking we have the required binaries..."),
-		doCheckCommonBinaries(d),
-		doPrepareCRI(),
-		doUploadResolvConf(d),
-		ssh.DoEnableService("kubelet.service"),
-		ssh.DoUploadBytesToFile([]byte(assets.KubeletSysconfigCode), getSysconfigPathFromResourceData(d)),
-		ssh.DoUploadBytesToFile([]byte(assets.KubeletServiceCode), getServicePathFromResourceData(d)),
-		ssh.DoUploadBytesToFile([]byte(assets.KubeadmDropinCode), getDropinPathFromResourceData(d)),
-	)
-
-	if len(join) == 0 {
-		switch role {
-		case "worker":
-			actions = append(actions, ssh.ActionError(fmt.Sprintf("role is %q while no \"join\" argument has been provided", role)))
-		default:
-			actions = append(actions, doKubeadmInit(d))
+// getProvisionStepAction returns the actions for a provisioning step
+func getProvisionStepAction(d *schema.ResourceData, step string, host string, connType string) ssh.Action {
+	controlPlane := getRoleFromResourceData(d) == roleControlPlane
+
+	switch step {
+	case stepReconfigure:
+		return ssh.DoWithLogStep(stepReconfigure, doKubeadmReconfigure(d, controlPlane, len(getJoinFromResourceData(d)) == 0))
+
+	case stepSetup:
+		// prepare the dedicated disks (if any) and install kubeadm
+		return ssh.DoWithLogStep(stepSetup, ssh.ActionList{
+			doRunHooks(d, "pre_setup"),
+			doPrepareStorage(d),
+			doDisableSwap(d),
+			doConfigureKernel(d),
+			doConfigureProxy(d, host),
+			doSetupChrony(d),
+			doUploadOffline(d),
+			doKubeadmSetup(d),
+			doOpenFirewall(d, controlPlane),
+			doRebootIfRequired(d, connType),
+		})
+
+	case stepPrepareNode:
+		// some common actions to do BEFORE doing initting/joining
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Checking we have the required binaries..."),
+			doCheckArch(d),
+			doCheckCommonBinaries(d),
+			doInstallGPUToolkit(d),
+			doConfigureRegistries(d),
+			doPrepareCRI(),
+			doImportOfflineImages(d),
+			doUploadResolvConf(d),
+			ssh.DoEnableService("kubelet.service"),
+			ssh.DoUploadBytesToFile(getKubeletSysconfigCodeFromResourceData(d), getSysconfigPathFromResourceData(d)),
+			ssh.DoUploadBytesToFile(getKubeletServiceCodeFromResourceData(d), getServicePathFromResourceData(d)),
+			ssh.DoUploadBytesToFile(getKubeadmDropinCodeFromResourceData(d), getDropinPathFromResourceData(d)),
 		}
-	} else {
-		switch role {
-		case "master":
-			actions = append(actions, doKubeadmJoinControlPlane(d))
-		case "worker":
-			actions = append(actions, doKubeadmJoinWorker(d))
-		case "":
-			actions = append(actions, doKubeadmJoinWorker(d))
-		default:
-			actions = append(actions, ssh.ActionError(fmt.Sprintf("unknown provisioning profile: join is %q and role is %q", join, role)))
+
+	case stepPullImages:
+		return doPullImages(d)
+
+	case stepPrepared:
+		return ssh.DoMessageInfo("Node prepared: it will be added to the cluster in the \"activate\" phase")
+
+	case stepActivate:
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Activating a previously prepared node..."),
+			doCheckCommonBinaries(d),
+		}
+
+	case stepCheckVersion:
+		return doCheckKubeadmVersion(d)
+
+	case stepPreflight:
+		return ssh.DoWithLogStep(stepPreflight, doPreflight(d, controlPlane))
+
+	case stepInit:
+		return ssh.DoWithLogStep(stepInit, doKubeadmInit(d, host))
+
+	case stepAddons:
+		return ssh.DoWithLogStep(stepAddons, doLoadAddons(d, host))
+
+	case stepJoinControlPlane:
+		return ssh.DoWithLogStep(stepJoinControlPlane, doKubeadmJoinControlPlane(d, host))
+
+	case stepJoin:
+		return ssh.DoWithLogStep(stepJoin, doKubeadmJoinWorker(d))
+
+	case stepPostInit:
+		return doRunHooks(d, "post_init")
+
+	case stepPostJoin:
+		return doRunHooks(d, "post_join")
+
+	case stepFinish:
+		// ... and some common actions to do AFTER initting/joining
+		return ssh.ActionList{
+			doApproveKubeletServingCSR(d),
+			doLoadGPUDevicePlugin(d),
+			ssh.DoMessageInfo("Gathering some info about this node..."),
+			doCheckLocalKubeconfigIsAlive(d),
+			doPrintEtcdStatus(d),
 		}
 	}
 
-	// ... and some common actions to do AFTER initting/joining
-	actions = append(actions,
-		ssh.DoMessageInfo("Gathering some info about this node..."),
-		doCheckLocalKubeconfigIsAlive(d),
-		doPrintEtcdStatus(d),
-	)
+	return ssh.ActionError(fmt.Sprintf("unknown provisioning step %q", step))
+}
 
-	return ssh.ActionList{
-		ssh.DoWithCleanup(
-			actions,
-			ssh.DoCleanupLeftovers()),
-	}.Apply(newCtx)
+// applyActions runs some actions in the node, returning an error (that
+// includes the host) if something fails
+func applyActions(ctx context.Context, host string, action ssh.Action) error {
+	res := ssh.ActionList{action}.Apply(ctx)
+	if ssh.IsError(res) {
+		return fmt.Errorf("provisioning of %s failed: %s", host, res.Error())
+	}
+	return nil
 }
@@ -15,11 +15,15 @@
 package provisioner
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
 
 func TestResourceProvisioner_impl(t *testing.T) {
@@ -59,6 +63,151 @@ func TestResourceProvider_Validate_bad(t *testing.T) {
 	}
 }
 
+func TestGetRetryFromResourceData(t *testing.T) {
+	def := ssh.Retry{Times: 6, Interval: 30 * time.Second}
+
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if r := getRetryFromResourceData(d, def); r.Times != 6 || r.Cleanup != nil {
+		t.Fatalf("Error: the default retry was not returned: %+v", r)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"retry": []interface{}{
+			map[string]interface{}{"attempts": 5, "interval": "1m"},
+		},
+	})
+	r := getRetryFromResourceData(d, def)
+	if r.Times != 5 || r.Interval != time.Minute || r.Backoff != 2.0 || r.Cleanup == nil {
+		t.Fatalf("Error: unexpected retry: %+v", r)
+	}
+}
+
+func TestGetProvisionSteps(t *testing.T) {
+	setup := []string{stepSetup, stepPrepareNode}
+	checks := []string{stepCheckVersion, stepPreflight}
+	initSteps := []string{stepInit, stepAddons, stepPostInit, stepFinish}
+	joinSteps := []string{stepJoin, stepPostJoin, stepFinish}
+	joinControlPlaneSteps := []string{stepJoinControlPlane, stepPostJoin, stepFinish}
+
+	concat := func(lists ...[]string) []string {
+		res := []string{}
+		for _, l := range lists {
+			res = append(res, l...)
+		}
+		return res
+	}
+
+	cases := []struct {
+		name     string
+		raw      map[string]interface{}
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "init",
+			raw:      map[string]interface{}{},
+			expected: concat(setup, checks, initSteps),
+		},
+		{
+			name:     "init with the deprecated master role",
+			raw:      map[string]interface{}{"role": "master"},
+			expected: concat(setup, checks, initSteps),
+		},
+		{
+			name:     "join a worker",
+			raw:      map[string]interface{}{"join": "10.0.0.1"},
+			expected: concat(setup, checks, joinSteps),
+		},
+		{
+			name:     "join a control plane",
+			raw:      map[string]interface{}{"join": "10.0.0.1", "role": "control-plane"},
+			expected: concat(setup, checks, joinControlPlaneSteps),
+		},
+		{
+			name: "worker without join",
+			raw:  map[string]interface{}{"role": "worker"},
+			err:  true,
+		},
+		{
+			name:     "prepare a control plane",
+			raw:      map[string]interface{}{"phase": "prepare"},
+			expected: concat(setup, []string{stepPullImages, stepPrepared}),
+		},
+		{
+			name: "prepare a control plane with offline images",
+			raw: map[string]interface{}{
+				"phase":   "prepare",
+				"offline": []interface{}{map[string]interface{}{"images": "/tmp/images.tar"}},
+			},
+			expected: concat(setup, []string{stepPrepared}),
+		},
+		{
+			name:     "prepare a worker",
+			raw:      map[string]interface{}{"phase": "prepare", "join": "10.0.0.1"},
+			expected: concat(setup, []string{stepPrepared}),
+		},
+		{
+			name:     "prepare a worker without join",
+			raw:      map[string]interface{}{"phase": "prepare", "role": "worker"},
+			expected: concat(setup, []string{stepPrepared}),
+		},
+		{
+			name:     "activate a control plane",
+			raw:      map[string]interface{}{"phase": "activate"},
+			expected: concat([]string{stepActivate}, checks, initSteps),
+		},
+		{
+			name:     "activate a worker",
+			raw:      map[string]interface{}{"phase": "activate", "join": "10.0.0.1"},
+			expected: concat([]string{stepActivate}, checks, joinSteps),
+		},
+		{
+			name:     "activate a control plane that joins",
+			raw:      map[string]interface{}{"phase": "activate", "join": "10.0.0.1", "role": "control-plane"},
+			expected: concat([]string{stepActivate}, checks, joinControlPlaneSteps),
+		},
+		{
+			name: "activate a worker without join",
+			raw:  map[string]interface{}{"phase": "activate", "role": "worker"},
+			err:  true,
+		},
+		{
+			name:     "reconfigure a control plane",
+			raw:      map[string]interface{}{"phase": "reconfigure"},
+			expected: []string{stepReconfigure},
+		},
+		{
+			name:     "reconfigure a worker",
+			raw:      map[string]interface{}{"phase": "reconfigure", "join": "10.0.0.1"},
+			expected: []string{stepReconfigure},
+		},
+	}
+
+	for _, c := range cases {
+		d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, c.raw)
+		steps, err := getProvisionSteps(d)
+		if c.err {
+			if err == nil {
+				t.Fatalf("Error: %s: an error was expected, got %v", c.name, steps)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: %s: %s", c.name, err)
+		}
+		if strings.Join(steps, ",") != strings.Join(c.expected, ",") {
+			t.Fatalf("Error: %s: unexpected steps:\n%v\nexpected:\n%v", c.name, steps, c.expected)
+		}
+
+		// all the steps must be known (some of them can have no actions, ie, the hooks)
+		for _, step := range steps {
+			if action := getProvisionStepAction(d, step, "10.0.0.2", "ssh"); ssh.IsError(action) {
+				t.Fatalf("Error: %s: unexpected action for step %q: %s", c.name, step, action.Error())
+			}
+		}
+	}
+}
+
 func testConfig(t *testing.T, c map[string]interface{}) *terraform.ResourceConfig {
 	r, err := config.NewRawConfig(c)
 	if err != nil {
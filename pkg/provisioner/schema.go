@@ -16,13 +16,17 @@ package provisioner
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
@@ -30,8 +34,9 @@ func Provisioner() terraform.ResourceProvisioner {
 	return &schema.Provisioner{
 		Schema: map[string]*schema.Schema{
 			"config": {
-				Type:     schema.TypeMap,
-				Required: true,
+				Type:      schema.TypeMap,
+				Required:  true,
+				Sensitive: true,
 				Elem: &schema.Resource{
 					Schema: common.ProvisionerConfigElements,
 				},
@@ -46,8 +51,14 @@ func Provisioner() terraform.ResourceProvisioner {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Default:      "",
-				Description:  "role of this machine: master or worker",
-				ValidateFunc: validation.StringInSlice([]string{"master", "worker"}, true),
+				Description:  "role of this machine: control-plane or worker (defaults to control-plane for the seeder and worker otherwise)",
+				ValidateFunc: validateRole,
+			},
+			"taints": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: common.ValidateTaint},
+				Description: "taints (`key[=value]:effect`) for this node, in addition to the default taints for its role",
 			},
 			"ignore_checks": {
 				Type:        schema.TypeList,
@@ -55,6 +66,13 @@ func Provisioner() terraform.ResourceProvisioner {
 				Optional:    true,
 				Description: "list of preflight checks to ignore by kubeadm",
 			},
+			"phase": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "all",
+				Description:  "provisioning phase: prepare (install and configure everything, but do not start the cluster), activate (init/join a prepared node), all or reconfigure (apply the current configuration in a node already in the cluster)",
+				ValidateFunc: validation.StringInSlice([]string{"all", "prepare", "activate", "reconfigure"}, false),
+			},
 			"drain": {
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -71,7 +89,47 @@ func Provisioner() terraform.ResourceProvisioner {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Description:  "for masters, IP/DNS:port to listen at",
-				ValidateFunc: common.ValidateHostPort,
+				ValidateFunc: common.ValidateHostOptionalPort,
+			},
+			"os": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				Description:  "operating system of this machine: linux or windows (defaults to windows for WinRM connections, linux otherwise)",
+				ValidateFunc: validation.StringInSlice([]string{"linux", "windows"}, true),
+			},
+			"manage_swap": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				Description:  "disable the swap in the node (`disable`) or let the kubelet run with swap (`allow`)",
+				ValidateFunc: validation.StringInSlice([]string{"", "disable", "allow"}, false),
+			},
+			"sysctls": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "additional sysctls to set (and persist) in the node",
+			},
+			"manage_firewall": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "open the ports required by Kubernetes in the firewall (firewalld or ufw)",
+			},
+			"selinux": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "permissive",
+				Description:  "SELinux mode set by the built-in installation script: permissive, enforcing or disabled",
+				ValidateFunc: validation.StringInSlice([]string{"permissive", "enforcing", "disabled"}, false),
+			},
+			"apparmor": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				Description:  "enable or disable AppArmor in the built-in installation script (not touched by default)",
+				ValidateFunc: validation.StringInSlice([]string{"", "enabled", "disabled"}, false),
 			},
 			"prevent_sudo": {
 				Type:        schema.TypeBool,
@@ -79,12 +137,50 @@ func Provisioner() terraform.ResourceProvisioner {
 				Default:     false,
 				Description: "prevent the use of sudo",
 			},
+			"on_existing": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      onExistingAdopt,
+				Description:  "what to do when the node has been initialized/joined before: adopt, reset or fail",
+				ValidateFunc: validation.StringInSlice([]string{onExistingAdopt, onExistingReset, onExistingFail}, false),
+			},
+			"wait_for_control_plane": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "wait for the control plane to be ready before joining a worker",
+			},
 			"manifests": {
 				Type:        schema.TypeList,
 				Elem:        &schema.Schema{Type: schema.TypeString},
 				Optional:    true,
 				Description: "list of manifests to load in the API server once the master is setup",
 			},
+			"hook": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "user-defined scripts run at some points of the provisioning",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"on": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "when the script is run: pre_setup, post_init or post_join",
+							ValidateFunc: validation.StringInSlice(hookPoints, false),
+						},
+						"inline": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "contents of the script",
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "path of a script in the node",
+						},
+					},
+				},
+			},
 			"install": {
 				// NOTE: default values for nested blocks are not available if the "install" block
 				// has not been provided at all.
@@ -115,6 +211,47 @@ func Provisioner() terraform.ResourceProvisioner {
 							Optional:    true,
 							Description: "kubeadm version to install.",
 						},
+						"repo_url": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "URL of the packages repository (defaults to the pkgs.k8s.io repository for the Kubernetes minor version)",
+						},
+						"repo_gpg_key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "URL of the GPG key for the packages repository",
+						},
+						"repo_gpg_fingerprint": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "fingerprint the GPG key of the packages repository must have",
+							ValidateFunc: common.ValidateGPGFingerprint,
+						},
+						"repo_gpg_check": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "check the signatures of the packages repositories",
+						},
+						"crio_repo_url": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "base URL of the CRI-O repositories (defaults to the openSUSE Kubic repositories)",
+							ValidateFunc: common.ValidateURL,
+						},
+						"docker_repo_url": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "URL of the Docker CE .repo file used for installing containerd in RedHat-like distros",
+							ValidateFunc: common.ValidateURL,
+						},
+						"mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefInstallMode,
+							Description:  "installation mode for the auto-installation script: packages or binaries",
+							ValidateFunc: validation.StringInSlice([]string{"packages", "binaries"}, false),
+						},
 						"sysconfig_path": {
 							Type:        schema.TypeString,
 							Default:     common.DefKubeletSysconfigPath,
@@ -148,13 +285,330 @@ func Provisioner() terraform.ResourceProvisioner {
 					},
 				},
 			},
+			"log_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "directory where a log file (`<host>.log`) with all the commands run in this node (and their output) will be written",
+			},
+			"progress_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "file where the progress events (phases started/completed in this node) are appended, in JSON lines format",
+			},
+			"hardware_labels": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "detect some hardware features (GPUs, SSDs, AVX512...) and add labels to the node",
+						},
+						"prefix": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefHardwareLabelsPrefix,
+							Description:  fmt.Sprintf("prefix for the labels (defaults to %s).", common.DefHardwareLabelsPrefix),
+							ValidateFunc: common.ValidateDNSName,
+						},
+					},
+				},
+			},
+			"storage": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"etcd_device": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  fmt.Sprintf("block device used for storing the etcd data (mounted at the etcd data_dir, %s by default).", common.DefEtcdDataDir),
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"kubelet_device": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  fmt.Sprintf("block device used for storing the kubelet data (mounted at %s).", common.DefKubeletRootDir),
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"filesystem": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefStorageFilesystem,
+							Description:  fmt.Sprintf("filesystem used when formatting the devices (defaults to %s).", common.DefStorageFilesystem),
+							ValidateFunc: validation.StringInSlice(common.DefStorageFilesystems, false),
+						},
+					},
+				},
+			},
+			"offline": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"packages_dir": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "local directory with the deb/rpm packages to install",
+							ConflictsWith: []string{"offline.0.repo_url"},
+						},
+						"repo_url": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "URL of a repository in an internal mirror with the packages to install",
+							ConflictsWith: []string{"offline.0.packages_dir"},
+						},
+						"images": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "local path (or URL in an internal mirror) of a tarball with the container images",
+						},
+					},
+				},
+			},
+			"ignore_resource_checks": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "only warn when the node does not have the minimum resources (CPUs, memory and disk)",
+			},
+			"preflight": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "run the preflight checks before initting/joining the node",
+						},
+						"skip": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "list of preflight checks to skip",
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(preflightChecks, false),
+							},
+						},
+						"min_cpus": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "minimum number of CPUs (defaults to 2 in masters and 1 in workers)",
+						},
+						"min_memory": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "minimum memory, in MB (defaults to 1700 in masters and 1024 in workers)",
+						},
+						"min_disk": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "minimum free disk space for the kubelet root directory, in GB",
+						},
+						"max_clock_skew": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum difference between the clock in the node and the local clock (defaults to 5s)",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"destroy": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "how the node is removed from the cluster when `drain` is true",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"skip_drain": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "do not evict the pods in the node with `kubectl drain` before deleting it",
+						},
+						"skip_reset": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "do not run a `kubeadm reset` in the node after removing it from the cluster",
+						},
+						"force_delete_node": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "delete the node from the cluster even when the drain fails",
+						},
+						"drain_timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum time for draining the node (default: no limit)",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum time for removing the node (default: the `delete` timeout of the kubeadm resource)",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"reboot": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "reboot the node after the setup when it is required (ie, after a kernel upgrade)",
+						},
+						"timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "10m",
+							Description:  "maximum time for the node to come back after the reboot",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"chrony": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"servers": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "NTP servers (defaults to the servers configured in the distro)",
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: common.ValidateDNSNameOrIP,
+							},
+						},
+					},
+				},
+			},
+			"gpu": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"device_plugin": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "deploy the NVIDIA device plugin after the node has joined the cluster",
+						},
+						"device_plugin_manifest": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefNvidiaDevicePluginManifest,
+							Description: "manifest (URL or local file) for the NVIDIA device plugin",
+						},
+					},
+				},
+			},
+			"ssh": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"connect_timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum time for establishing the SSH connection (defaults to the `timeout` in the `connection`).",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"exec_timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum time for running any remote command (no limit by default).",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"keepalive_interval": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "interval for checking the SSH connection is alive, reconnecting when necessary (disabled by default).",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"max_sessions": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "maximum number of concurrent sessions in the SSH connection to the node, shared by all the provisioners for the same node (defaults to 8).",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+					},
+				},
+			},
+			"retry": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"attempts": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      3,
+							Description:  "number of attempts for the `kubeadm init` or `join`.",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"interval": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "15s",
+							Description:  "time to wait before the first retry.",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"backoff": {
+							Type:         schema.TypeFloat,
+							Optional:     true,
+							Default:      2.0,
+							Description:  "factor the interval is multiplied by after every retry.",
+							ValidateFunc: validation.FloatBetween(1.0, 10.0),
+						},
+						"cleanup": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "run a `kubeadm reset` and clean the state directories before retrying.",
+						},
+					},
+				},
+			},
 		},
 
 		ApplyFunc: applyFn,
 
-		// note: we cannot "validate" config passed from the provisioner, as the
-		// validation is done before that config is created
+		// note: we cannot "validate" config passed from the provisioner when it is
+		// not known yet (ie, when the kubeadm resource is created in the same plan),
+		// so the same checks are done again in the applyFn
+		ValidateFunc: validateFn,
+	}
+}
+
+// validateFn validates the provisioner arguments that are known at plan time
+func validateFn(c *terraform.ResourceConfig) (ws []string, es []error) {
+	if err := validateInstallVersionSkew(c); err != nil {
+		es = append(es, err)
 	}
+	return
 }
 
 //
@@ -169,12 +623,40 @@ func getJoinFromResourceData(d *schema.ResourceData) string {
 	return ""
 }
 
-// getRoleFromResourceData returns the "role" host from the ResourceData
+// validateRole validates the `role`, warning about the deprecated "master"
+func validateRole(v interface{}, k string) (ws []string, errors []error) {
+	switch strings.TrimSpace(v.(string)) {
+	case "", roleControlPlane, roleWorker:
+	case roleMaster:
+		ws = append(ws, fmt.Sprintf("%q: %q is deprecated, use %q", k, roleMaster, roleControlPlane))
+	default:
+		errors = append(errors, fmt.Errorf("%q must be %q or %q", k, roleControlPlane, roleWorker))
+	}
+	return
+}
+
+// getRoleFromResourceData returns the role of the node: the `role` provided or,
+// by default, a control plane for the seeder (with no `join`) and a worker otherwise
 func getRoleFromResourceData(d *schema.ResourceData) string {
+	role := ""
 	if opt, ok := d.GetOk("role"); ok {
-		return strings.TrimSpace(opt.(string))
+		role = strings.TrimSpace(opt.(string))
 	}
-	return ""
+	switch role {
+	case roleMaster:
+		return roleControlPlane
+	case "":
+		if len(getJoinFromResourceData(d)) == 0 {
+			return roleControlPlane
+		}
+		return roleWorker
+	}
+	return role
+}
+
+// isControlPlaneFromResourceData returns true if the node is part of the control plane
+func isControlPlaneFromResourceData(d *schema.ResourceData) bool {
+	return getRoleFromResourceData(d) == roleControlPlane
 }
 
 // getKubeconfigFromResourceData returns the kubeconfig parameter passed in the `config_path`
@@ -205,6 +687,10 @@ func getServicePathFromResourceData(d *schema.ResourceData) string {
 	if len(servicePath) == 0 {
 		servicePath = common.DefKubeletServicePath
 	}
+	// /usr can be read-only when installing the binaries
+	if servicePath == common.DefKubeletServicePath && getInstallModeFromResourceData(d) == "binaries" {
+		servicePath = common.DefBinariesKubeletServicePath
+	}
 	return servicePath
 }
 
@@ -213,15 +699,22 @@ func getDropinPathFromResourceData(d *schema.ResourceData) string {
 	if len(dropinPath) == 0 {
 		dropinPath = common.DefKubeadmDropinPath
 	}
+	if dropinPath == common.DefKubeadmDropinPath && getInstallModeFromResourceData(d) == "binaries" {
+		dropinPath = common.DefBinariesKubeadmDropinPath
+	}
 	return dropinPath
 }
 
 // getKubeadmFromResourceData returns the kubeadm binary path from the config
 func getKubeadmFromResourceData(d *schema.ResourceData) string {
+	kubeadmPath := common.DefKubeadmPath
 	if kubeadmPathOpt, ok := d.GetOk("install.0.kubeadm_path"); ok {
-		return kubeadmPathOpt.(string)
+		kubeadmPath = kubeadmPathOpt.(string)
+	}
+	if kubeadmPath == common.DefKubeadmPath && getInstallModeFromResourceData(d) == "binaries" {
+		kubeadmPath = path.Join(common.DefBinariesDir, common.DefKubeadmPath)
 	}
-	return common.DefKubeadmPath
+	return kubeadmPath
 }
 
 // getTokenFromResourceData returns the current token in the ResourceData
@@ -237,10 +730,14 @@ func getTokenFromResourceData(d *schema.ResourceData) string {
 
 // getKubectlFromResourceData returns the kubectl binary path from the config
 func getKubectlFromResourceData(d *schema.ResourceData) string {
+	kubectlPath := common.DefKubectlPath
 	if kubectlPathOpt, ok := d.GetOk("install.0.kubectl_path"); ok {
-		return kubectlPathOpt.(string)
+		kubectlPath = kubectlPathOpt.(string)
 	}
-	return common.DefKubectlPath
+	if kubectlPath == common.DefKubectlPath && getInstallModeFromResourceData(d) == "binaries" {
+		kubectlPath = path.Join(common.DefBinariesDir, common.DefKubectlPath)
+	}
+	return kubectlPath
 }
 
 // getNodenameFromResourceData returns the nodename specified in the ResourceData
@@ -250,3 +747,283 @@ func getNodenameFromResourceData(d *schema.ResourceData) string {
 	}
 	return ""
 }
+
+// getDurationFromResourceData returns a duration from the ResourceData,
+// or 0 if not present (or invalid)
+func getDurationFromResourceData(d *schema.ResourceData, key string) time.Duration {
+	if opt, ok := d.GetOk(key); ok {
+		if res, err := time.ParseDuration(opt.(string)); err == nil {
+			return res
+		}
+	}
+	return 0
+}
+
+// getSSHConnectTimeoutFromResourceData returns the timeout for establishing the SSH connection
+func getSSHConnectTimeoutFromResourceData(d *schema.ResourceData) time.Duration {
+	return getDurationFromResourceData(d, "ssh.0.connect_timeout")
+}
+
+// getSSHExecTimeoutFromResourceData returns the timeout for running remote commands
+func getSSHExecTimeoutFromResourceData(d *schema.ResourceData) time.Duration {
+	return getDurationFromResourceData(d, "ssh.0.exec_timeout")
+}
+
+// getOnExistingFromResourceData returns what to do with nodes initialized/joined before
+func getOnExistingFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("on_existing"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return onExistingAdopt
+}
+
+// getEtcdModeFromResourceData returns the etcd topology (stacked or external)
+func getEtcdModeFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("config.etcd_mode"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.EtcdModeStacked
+}
+
+// getAutoSANsFromResourceData returns true when the address of the control plane
+// nodes must be added to their API server certificate
+func getAutoSANsFromResourceData(d *schema.ResourceData) bool {
+	if opt, ok := d.GetOk("config.api_auto_sans"); ok && len(opt.(string)) > 0 {
+		return opt.(string) == "true"
+	}
+	return true
+}
+
+// getRetryFromResourceData returns the retry configuration for `kubeadm init`/`join`,
+// or `def` when no `retry` block has been provided
+func getRetryFromResourceData(d *schema.ResourceData, def ssh.Retry) ssh.Retry {
+	if _, ok := d.GetOk("retry"); !ok {
+		return def
+	}
+
+	res := ssh.Retry{
+		Times:    d.Get("retry.0.attempts").(int),
+		Interval: getDurationFromResourceData(d, "retry.0.interval"),
+		Backoff:  d.Get("retry.0.backoff").(float64),
+	}
+	if d.Get("retry.0.cleanup").(bool) {
+		res.Cleanup = doResetNode(d)
+	}
+	return res
+}
+
+// getTimeoutFromResourceData returns the deadline for provisioning the node, from
+// the `timeouts` of the kubeadm resource (or 0 if there is no deadline). When removing
+// the node, the `destroy.timeout` takes precedence.
+func getTimeoutFromResourceData(d *schema.ResourceData) time.Duration {
+	op := "create"
+	switch {
+	case d.Get("drain").(bool):
+		if timeout := getDurationFromResourceData(d, "destroy.0.timeout"); timeout > 0 {
+			return timeout
+		}
+		op = "delete"
+	case getPhaseFromResourceData(d) == "reconfigure":
+		op = "update"
+	}
+	return getDurationFromResourceData(d, "config.timeout_"+op)
+}
+
+// getSSHKeepaliveFromResourceData returns the interval for the SSH keepalives
+func getSSHKeepaliveFromResourceData(d *schema.ResourceData) time.Duration {
+	return getDurationFromResourceData(d, "ssh.0.keepalive_interval")
+}
+
+// getStorageFilesystemFromResourceData returns the filesystem used for formatting the dedicated disks
+func getStorageFilesystemFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("storage.0.filesystem"); ok {
+		return opt.(string)
+	}
+	return common.DefStorageFilesystem
+}
+
+// getKubeletRootDirFromResourceData returns the directory for the kubelet data
+func getKubeletRootDirFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("config.kubelet_root_dir"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.DefKubeletRootDir
+}
+
+// getEtcdDataDirFromResourceData returns the etcd data directory configured in the cluster
+func getEtcdDataDirFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("config.etcd_data_dir"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.DefEtcdDataDir
+}
+
+// getLogDirFromResourceData returns the directory for the session logs (or "" if none)
+func getLogDirFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("log_dir"); ok {
+		return strings.TrimSpace(opt.(string))
+	}
+	return ""
+}
+
+// getProgressFileFromResourceData returns the file for the progress events, or "" if disabled
+func getProgressFileFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("progress_file"); ok {
+		return strings.TrimSpace(opt.(string))
+	}
+	return ""
+}
+
+// getHardwareLabelsPrefixFromResourceData returns the prefix for the hardware labels,
+// or "" if the hardware labels are not enabled
+func getHardwareLabelsPrefixFromResourceData(d *schema.ResourceData) string {
+	if !d.Get("hardware_labels.0.enabled").(bool) {
+		return ""
+	}
+	if opt, ok := d.GetOk("hardware_labels.0.prefix"); ok {
+		return opt.(string)
+	}
+	return common.DefHardwareLabelsPrefix
+}
+
+// getOSFromResourceData returns the operating system of the node, using the
+// connection type when it has not been provided
+func getOSFromResourceData(d *schema.ResourceData, connType string) string {
+	if opt, ok := d.GetOk("os"); ok && len(opt.(string)) > 0 {
+		return strings.ToLower(opt.(string))
+	}
+	if connType == "winrm" {
+		return "windows"
+	}
+	return "linux"
+}
+
+// getPhaseFromResourceData returns the provisioning phase ("all", "prepare" or "activate")
+func getPhaseFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("phase"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return "all"
+}
+
+// getInstallModeFromResourceData returns the installation mode ("packages" or "binaries")
+func getInstallModeFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("install.0.mode"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.DefInstallMode
+}
+
+// getKubeletServiceCodeFromResourceData returns the kubelet.service file contents,
+// using the right path for the kubelet executable
+func getKubeletServiceCodeFromResourceData(d *schema.ResourceData) []byte {
+	return []byte(replaceKubeletPath(d, assets.KubeletServiceCode))
+}
+
+// getKubeletSysconfigCodeFromResourceData returns the kubelet sysconfig file contents:
+// the kubelet will refuse to start with swap enabled when we disable it
+func getKubeletSysconfigCodeFromResourceData(d *schema.ResourceData) []byte {
+	if getManageSwapFromResourceData(d) == "disable" {
+		return []byte(strings.Replace(assets.KubeletSysconfigCode, "--fail-swap-on=false", "", -1))
+	}
+	return []byte(assets.KubeletSysconfigCode)
+}
+
+// getKubeadmDropinCodeFromResourceData returns the kubeadm dropin file contents,
+// using the right path for the kubelet executable
+func getKubeadmDropinCodeFromResourceData(d *schema.ResourceData) []byte {
+	return []byte(replaceKubeletPath(d, assets.KubeadmDropinCode))
+}
+
+func replaceKubeletPath(d *schema.ResourceData, code string) string {
+	if getInstallModeFromResourceData(d) != "binaries" {
+		return code
+	}
+	return strings.Replace(code, "/usr/bin/kubelet", path.Join(common.DefBinariesDir, "kubelet"), -1)
+}
+
+// getOfflinePackagesDirFromResourceData returns the local directory with the packages for offline installations
+func getOfflinePackagesDirFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("offline.0.packages_dir"); ok {
+		return opt.(string)
+	}
+	return ""
+}
+
+// getOfflineRepoURLFromResourceData returns the URL of the internal mirror for offline installations
+func getOfflineRepoURLFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("offline.0.repo_url"); ok {
+		return opt.(string)
+	}
+	return ""
+}
+
+// getOfflineImagesFromResourceData returns the path (or URL) of the images tarball for offline installations
+func getOfflineImagesFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("offline.0.images"); ok {
+		return opt.(string)
+	}
+	return ""
+}
+
+// getKubeVersionFromResourceData returns the Kubernetes version of the cluster
+func getKubeVersionFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("config.kube_version"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	return common.DefKubernetesVersion
+}
+
+// getInstallVersionFromResourceData returns the version of the packages to install:
+// the `install.version` or, by default, the version of the cluster
+func getInstallVersionFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("install.0.version"); ok && len(opt.(string)) > 0 {
+		return opt.(string)
+	}
+	if opt, ok := d.GetOk("config.kube_version"); ok {
+		return opt.(string)
+	}
+	return ""
+}
+
+// getPreflightEnabledFromResourceData returns true if the preflight checks must be run
+func getPreflightEnabledFromResourceData(d *schema.ResourceData) bool {
+	if _, ok := d.GetOk("preflight"); !ok {
+		return false
+	}
+	return d.Get("preflight.0.enabled").(bool)
+}
+
+// getPreflightSkipFromResourceData returns the list of preflight checks to skip
+func getPreflightSkipFromResourceData(d *schema.ResourceData) []string {
+	res := []string{}
+	if opt, ok := d.GetOk("preflight.0.skip"); ok {
+		for _, s := range opt.([]interface{}) {
+			res = append(res, s.(string))
+		}
+	}
+	return res
+}
+
+// getManageSwapFromResourceData returns how the swap must be managed in the node
+func getManageSwapFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("manage_swap"); ok {
+		return opt.(string)
+	}
+	return ""
+}
+
+// getSysctlsFromResourceData returns the sysctls for the node: the
+// default ones plus (or overridden by) the `sysctls` provided
+func getSysctlsFromResourceData(d *schema.ResourceData) map[string]string {
+	res := map[string]string{}
+	for k, v := range common.DefSysctls {
+		res[k] = v
+	}
+	if opt, ok := d.GetOk("sysctls"); ok {
+		for k, v := range opt.(map[string]interface{}) {
+			res[k] = v.(string)
+		}
+	}
+	return res
+}
@@ -16,35 +16,160 @@ package provisioner
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/terraform/communicator"
+	"github.com/hashicorp/terraform/communicator/remote"
 	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
 
-// getCommunicator gets a new communicator for the remote machine
-func getCommunicator(ctx context.Context, o terraform.UIOutput, s *terraform.InstanceState) (communicator.Communicator, error) {
-	// Get a new communicator
-	comm, err := communicator.New(s)
+// getCommunicator gets a communicator for the remote machine
+// A `connectTimeout` can be provided for overriding the timeout in the connection.
+// The SSH connections are shared (through the connections pool) with the
+// other provisioners for the same host, running a maximum of `maxSessions` concurrent
+// sessions in them. The communicator must be disconnected by the caller.
+func getCommunicator(ctx context.Context, o terraform.UIOutput, s *terraform.InstanceState, connectTimeout time.Duration, maxSessions int) (communicator.Communicator, error) {
+	var comm communicator.Communicator
+	var err error
+	if isLocalConnType(s.Ephemeral.ConnInfo["type"]) {
+		comm, err = newLocalCommunicator(s)
+		if err == nil {
+			err = connectCommunicator(ctx, o, comm, connectTimeout)
+		}
+	} else {
+		comm, err = ssh.DefaultConnPool.Get(getConnPoolKey(s), maxSessions, func() (communicator.Communicator, error) {
+			comm, err := communicator.New(s)
+			if err != nil {
+				return nil, err
+			}
+			if err := connectCommunicator(ctx, o, comm, connectTimeout); err != nil {
+				return nil, err
+			}
+			return comm, nil
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	retryCtx, cancel := context.WithTimeout(ctx, comm.Timeout())
+	// note well: the caller must disconnect the communicator, as we could still
+	// need it after the context is done (ie, for killing the remote processes)
+	return comm, nil
+}
+
+// connPoolCredentials are the connection settings used for authenticating (and
+// verifying) the host, so connections with different credentials are not shared
+var connPoolCredentials = []string{
+	"password",
+	"private_key",
+	"certificate",
+	"host_key",
+	"agent",
+	"agent_identity",
+	"bastion_password",
+	"bastion_private_key",
+	"bastion_host_key",
+	"https",
+	"insecure",
+	"use_ntlm",
+	"cacert",
+}
+
+// getConnPoolKey returns the key that identifies the host in the connections pool,
+// with a fingerprint of the credentials (so they are not leaked in the logs)
+func getConnPoolKey(s *terraform.InstanceState) string {
+	connInfo := s.Ephemeral.ConnInfo
+	key := fmt.Sprintf("%s://%s@%s:%s", connInfo["type"], connInfo["user"], connInfo["host"], connInfo["port"])
+	if bastion := connInfo["bastion_host"]; len(bastion) > 0 {
+		key += fmt.Sprintf(" (via %s@%s:%s)", connInfo["bastion_user"], bastion, connInfo["bastion_port"])
+	}
+
+	h := sha256.New()
+	for _, k := range connPoolCredentials {
+		fmt.Fprintf(h, "%s=%q\n", k, connInfo[k])
+	}
+	return key + fmt.Sprintf(" [credentials %x]", h.Sum(nil)[:8])
+}
+
+// connectCommunicator connects the communicator, retrying until the `connectTimeout`
+// (or the timeout in the communicator) expires
+func connectCommunicator(ctx context.Context, o terraform.UIOutput, comm communicator.Communicator, connectTimeout time.Duration) error {
+
+	if connectTimeout <= 0 {
+		connectTimeout = comm.Timeout()
+	}
+
+	retryCtx, cancel := context.WithTimeout(ctx, connectTimeout)
 	defer cancel()
 
 	// Wait and retry until we establish the connection
-	err = communicator.Retry(retryCtx, func() error {
+	return communicator.Retry(retryCtx, func() error {
 		return comm.Connect(o)
 	})
-	if err != nil {
+}
+
+// doKeepalive runs a no-op command every `interval` until the context is done,
+// so we keep some traffic in the connection. The context must be cancelled before
+// the communicator is disconnected. If the connection has been dropped,
+// the communicator will reconnect when opening the new session.
+func doKeepalive(ctx context.Context, comm communicator.Communicator, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			cmd := &remote.Cmd{Command: "true"}
+			if err := comm.Start(cmd); err != nil {
+				ssh.GetLoggerFromContext(ctx).Warn("keepalive failed: %s", err)
+				continue
+			}
+
+			// do not get stuck in a session that never finishes
+			waitCh := make(chan struct{})
+			go func() {
+				_ = cmd.Wait()
+				close(waitCh)
+			}()
+			select {
+			case <-waitCh:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// openProgressFile opens (in append mode) the file for the progress events. Many
+// provisioners can write to the same file, but every event is written at once.
+func openProgressFile(path string) (*os.File, error) {
+	if dir := filepath.Dir(path); len(dir) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+}
+
+// openSessionLog opens (in append mode) the session log for `host` in `dir`
+func openSessionLog(dir string, host string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
 
-	// Wait for the context to end and then disconnect
-	go func() {
-		<-ctx.Done()
-		_ = comm.Disconnect()
-	}()
+	f, err := os.OpenFile(filepath.Join(dir, host+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
 
-	return comm, err
+	_, _ = fmt.Fprintf(f, "%s # session started for %s\n", time.Now().Format(time.RFC3339), host)
+	return f, nil
 }
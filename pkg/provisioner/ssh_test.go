@@ -0,0 +1,135 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// keepaliveCommunicator counts the commands started, finishing them immediately
+type keepaliveCommunicator struct {
+	ssh.DummyCommunicator
+
+	lock     sync.Mutex
+	commands []string
+}
+
+func (c *keepaliveCommunicator) Start(cmd *remote.Cmd) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cmd.Init()
+	c.commands = append(c.commands, cmd.Command)
+	cmd.SetExitStatus(0, nil)
+	return nil
+}
+
+func (c *keepaliveCommunicator) count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.commands)
+}
+
+func TestKeepalive(t *testing.T) {
+	comm := &keepaliveCommunicator{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		doKeepalive(ctx, comm, 10*time.Millisecond)
+		close(done)
+	}()
+
+	for i := 0; i < 100 && comm.count() < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if comm.count() < 3 {
+		t.Fatalf("Error: the keepalive has not run: %d commands", comm.count())
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error: the keepalive did not stop after cancelling the context")
+	}
+	stopped := comm.count()
+	time.Sleep(50 * time.Millisecond)
+	if comm.count() != stopped {
+		t.Fatalf("Error: the keepalive is still running after being stopped")
+	}
+}
+
+func TestKeepaliveStuckSession(t *testing.T) {
+	// the commands in the DummyCommunicator never finish, but the keepalive
+	// must stop anyway when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		doKeepalive(ctx, ssh.DummyCommunicator{}, 10*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error: the keepalive did not stop after cancelling the context")
+	}
+}
+
+func TestGetConnPoolKey(t *testing.T) {
+	state := func(connInfo map[string]string) *terraform.InstanceState {
+		info := map[string]string{
+			"type": "ssh",
+			"user": "root",
+			"host": "10.0.0.1",
+			"port": "22",
+		}
+		for k, v := range connInfo {
+			info[k] = v
+		}
+		return &terraform.InstanceState{Ephemeral: terraform.EphemeralState{ConnInfo: info}}
+	}
+
+	key := getConnPoolKey(state(map[string]string{"password": "secret"}))
+	if !strings.HasPrefix(key, "ssh://root@10.0.0.1:22 ") || strings.Contains(key, "secret") {
+		t.Fatalf("Error: unexpected key: %q", key)
+	}
+	if other := getConnPoolKey(state(map[string]string{"password": "secret"})); other != key {
+		t.Fatalf("Error: the key is not stable: %q != %q", other, key)
+	}
+
+	// connections with different credentials must not be shared
+	for _, connInfo := range []map[string]string{
+		{"password": "other"},
+		{"private_key": "some-key"},
+		{"password": "secret", "bastion_host": "bastion", "bastion_private_key": "some-key"},
+	} {
+		if other := getConnPoolKey(state(connInfo)); other == key {
+			t.Fatalf("Error: same key for different credentials: %v", connInfo)
+		}
+	}
+}
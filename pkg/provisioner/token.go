@@ -135,10 +135,10 @@ func DoGetCurrentRemoteTokens(d *schema.ResourceData, kts KubeadmTokensSet) ssh.
 	return ssh.ActionList{
 		ssh.DoSendingExecOutputToWriter(DoExecKubeadmToken(d, "list"), &buf),
 		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-			ssh.Debug("parsing kubeadm output")
-			ssh.Debug("%s", buf.String())
+			ssh.GetLoggerFromContext(ctx).Debug("parsing kubeadm output")
+			ssh.GetLoggerFromContext(ctx).Debug("%s", buf.String())
 			if err := kts.FromString(buf.String()); err != nil {
-				ssh.Debug("error when parsing 'kubeadm token' output: %s", err)
+				ssh.GetLoggerFromContext(ctx).Warn("error when parsing 'kubeadm token' output: %s", err)
 				return ssh.ActionError(fmt.Sprintf("Could not parse kubeadm output: %s", err))
 			}
 			return nil
@@ -150,7 +150,7 @@ func DoGetCurrentRemoteTokens(d *schema.ResourceData, kts KubeadmTokensSet) ssh.
 func DoSetNewToken(d *schema.ResourceData, newToken string) ssh.Action {
 	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
 		// update the token in "config.join"
-		ssh.Debug("getting current join configuration")
+		ssh.GetLoggerFromContext(ctx).Debug("getting current join configuration")
 		joinConfig, _, err := common.JoinConfigFromResourceData(d)
 		if err != nil {
 			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
@@ -180,17 +180,17 @@ func checkTokenIsValid(d *schema.ResourceData, tokens KubeadmTokensSet) ssh.Chec
 			return false, nil
 		}
 
-		ssh.Debug("%d tokens obtained", len(tokens))
+		ssh.GetLoggerFromContext(ctx).Debug("%d tokens obtained", len(tokens))
 		if len(tokens) == 0 {
 			_ = ssh.DoMessageWarn("no tokens obtained").Apply(ctx)
 		}
 
 		for _, token := range tokens {
 			if token.Token == currentToken {
-				ssh.Debug("current token, %q, found in the list of tokens", currentToken)
+				ssh.GetLoggerFromContext(ctx).Debug("current token, %q, found in the list of tokens", currentToken)
 
 				if token.IsExpired(time.Now()) {
-					ssh.Debug("token %q seems to be expired", currentToken)
+					ssh.GetLoggerFromContext(ctx).Debug("token %q seems to be expired", currentToken)
 					return false, nil
 				}
 				return true, nil
@@ -220,7 +220,7 @@ func doRefreshToken(d *schema.ResourceData) ssh.Action {
 			ssh.DoMessageInfo("%q is still a valid token", curTokenInJoinConfig),
 			ssh.ActionList{
 				ssh.DoMessageWarn("%q is not valid token anymore: will create a new token %q...", curTokenInJoinConfig, newToken),
-				ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("create --ttl=%s %s", newJoinTokenTTL, newToken))),
+				ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("create --ttl=%s --description=%s %s", newJoinTokenTTL, common.TokenDescription, newToken))),
 				DoSetNewToken(d, newToken),
 				ssh.DoMessageInfo("New token %q created successfully.", newToken),
 			}),
@@ -0,0 +1,59 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// loadSecretsFromVault loads the secrets (the bootstrap token and the certificate key)
+// from Vault when they are not stored in the Terraform state, updating the `config`.
+// The provisioner cannot access the provider configuration, so the Vault token
+// must be provided in the environment.
+func loadSecretsFromVault(ctx context.Context, d *schema.ResourceData) error {
+	path := d.Get("config.vault_secrets_path").(string)
+	if len(path) == 0 {
+		return nil
+	}
+
+	address := d.Get("config.vault_address").(string)
+	if len(address) == 0 {
+		address = os.Getenv(common.DefVaultAddressEnv)
+	}
+	token := os.Getenv(common.DefVaultTokenEnv)
+	if len(token) == 0 {
+		return fmt.Errorf("secrets are stored in Vault at %q, but %s is not set", path, common.DefVaultTokenEnv)
+	}
+
+	ssh.GetLoggerFromContext(ctx).Debug("loading secrets from Vault at %q", path)
+	secrets, err := common.NewVaultClient(address, token).ReadSecrets(path)
+	if err != nil {
+		return err
+	}
+
+	config := common.GetProvisionerConfig(d)
+	if err := common.SetTokenInConfig(config, secrets["token"]); err != nil {
+		return err
+	}
+	config["certificate_key"] = secrets["certificate_key"]
+	return d.Set("config", config)
+}